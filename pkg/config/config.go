@@ -36,24 +36,251 @@ type AppConfig struct {
 	UploadFileSettings UploadFileSettings `yaml:"upload_file_settings"`
 	RecorderInfo       RecorderInfo       `yaml:"recorder_info"`
 	SharedNotePad      SharedNotePad      `yaml:"shared_notepad"`
+	Ingress            IngressConf        `yaml:"ingress"`
 }
 
 type ClientInfo struct {
-	Port           int                      `yaml:"port"`
-	Debug          bool                     `yaml:"debug"`
-	Path           string                   `yaml:"path"`
-	ApiKey         string                   `yaml:"api_key"`
-	Secret         string                   `yaml:"secret"`
+	Port   int    `yaml:"port"`
+	Debug  bool   `yaml:"debug"`
+	Path   string `yaml:"path"`
+	ApiKey string `yaml:"api_key"`
+	Secret string `yaml:"secret"`
+	// ApiKeys are additional key/secret pairs, useful to rotate credentials
+	ApiKeys        []ApiKeyPair             `yaml:"api_keys"`
 	WebhookConf    WebhookConf              `yaml:"webhook_conf"`
 	PrometheusConf PrometheusConf           `yaml:"prometheus"`
 	ProxyHeader    string                   `yaml:"proxy_header"`
 	CopyrightConf  *plugnmeet.CopyrightConf `yaml:"copyright_conf"`
+	// JoinTokenSigning to sign join tokens using asymmetric keys
+	JoinTokenSigning JoinTokenSigning `yaml:"join_token_signing"`
+	// JoinTokenClaims to interoperate with gateways those validate claims strictly
+	JoinTokenClaims JoinTokenClaims `yaml:"join_token_claims"`
+	// Oidc will allow calling /auth endpoints using OIDC bearer token
+	Oidc OidcConf `yaml:"oidc"`
+	// RequestSignature will require timestamp as part of HASH-SIGNATURE
+	RequestSignature RequestSignatureConf `yaml:"request_signature"`
+	// RateLimit for token generation & verification
+	RateLimit RateLimitConf `yaml:"rate_limit"`
+	// RequireVerifyNonce will require nonce from /api/getVerifyNonce during verify token
+	RequireVerifyNonce bool `yaml:"require_verify_nonce"`
+	// Ldap will allow users to log in using LDAP/Active Directory credentials
+	Ldap LdapConf `yaml:"ldap"`
+	// ChatPersistence is used for rooms with persist_chat
+	ChatPersistence ChatPersistenceConf `yaml:"chat_persistence"`
+	// ChatFilter to filter chat messages relayed by the websocket
+	ChatFilter ChatFilterConf `yaml:"chat_filter"`
+	// Reactions relayed by the websocket
+	Reactions ReactionsConf `yaml:"reactions"`
+	// ChatRateLimit of non-moderators in the websocket relay
+	ChatRateLimit ChatRateLimitConf `yaml:"chat_rate_limit"`
+	// ChatTranslation for participants those have set preferred language
+	ChatTranslation ChatTranslationConf `yaml:"chat_translation"`
+	// ChatHistory will be sent to late joiners
+	ChatHistory ChatHistoryConf `yaml:"chat_history"`
+	// ChatPins by moderators
+	ChatPins ChatPinsConf `yaml:"chat_pins"`
+	// LinkPreview of urls in chat messages
+	LinkPreview LinkPreviewConf `yaml:"link_preview"`
+	// WhiteboardState will be kept by the server for late joiners & restore
+	WhiteboardState WhiteboardStateConf `yaml:"whiteboard_state"`
+	// WhiteboardPointer will throttle pointers & laser pointer in the websocket relay
+	WhiteboardPointer WhiteboardPointerConf `yaml:"whiteboard_pointer"`
+}
+
+type WhiteboardStateConf struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxElements per page, default 5000
+	MaxElements int `yaml:"max_elements"`
+	// ExportUrlTTL of download urls of exported boards, default 24h
+	ExportUrlTTL time.Duration `yaml:"export_url_ttl"`
+	// AttachToRecordings will store the final board as pdf next to the recordings
+	AttachToRecordings bool `yaml:"attach_to_recordings"`
+}
+
+type WhiteboardPointerConf struct {
+	// MaxPerSecond of pointer messages of each participant, default 20
+	MaxPerSecond int `yaml:"max_per_second"`
+}
+
+type LinkPreviewConf struct {
+	Enabled bool `yaml:"enabled"`
+	// Timeout of fetching the page, default 5s
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxBodyKB will be read from the page, default 512
+	MaxBodyKB int `yaml:"max_body_kb"`
+	// CacheTTL of previews, default 24h
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+type ChatPinsConf struct {
+	// MaxPins per room, default 3
+	MaxPins int `yaml:"max_pins"`
+}
+
+type ChatHistoryConf struct {
+	Enabled bool `yaml:"enabled"`
+	// DefaultCount of public messages, default 20. It can be overridden per room
+	DefaultCount int `yaml:"default_count"`
+	// MaxCount of messages kept per room, default 100
+	MaxCount int `yaml:"max_count"`
+}
+
+type ChatTranslationConf struct {
+	Enabled bool `yaml:"enabled"`
+	// Provider: google, deepl or libretranslate
+	Provider string `yaml:"provider"`
+	// CacheTTL of translated messages, default 1h
+	CacheTTL       time.Duration       `yaml:"cache_ttl"`
+	Google         GoogleTranslateConf `yaml:"google"`
+	Deepl          DeeplTranslateConf  `yaml:"deepl"`
+	LibreTranslate LibreTranslateConf  `yaml:"libretranslate"`
+}
+
+type GoogleTranslateConf struct {
+	ApiKey string `yaml:"api_key"`
+}
+
+type DeeplTranslateConf struct {
+	ApiKey string `yaml:"api_key"`
+	// Url empty means it will be selected based on the key
+	Url string `yaml:"url"`
+}
+
+type LibreTranslateConf struct {
+	Url    string `yaml:"url"`
+	ApiKey string `yaml:"api_key"`
+}
+
+type ChatRateLimitConf struct {
+	// MessagesPerMinute of each participant, 0 means unlimited
+	MessagesPerMinute int `yaml:"messages_per_minute"`
+	// NotifyModeratorsAfter throttled messages within a minute, default 3
+	NotifyModeratorsAfter int `yaml:"notify_moderators_after"`
+}
+
+// ReactionsConf default 10s window & the built-in emoji list
+type ReactionsConf struct {
+	// Window of the counts sent to clients
+	Window  time.Duration `yaml:"window"`
+	Allowed []string      `yaml:"allowed"`
+}
+
+type ChatFilterConf struct {
+	Enabled bool `yaml:"enabled"`
+	// Mode: words (default) or regex. Rooms' chat_filter_words will use the same mode
+	Mode  string   `yaml:"mode"`
+	Words []string `yaml:"words"`
+	// Action for matched messages: mask (default), reject or flag to moderators
+	Action string `yaml:"action"`
+}
+
+// ChatPersistenceConf to write chat messages in batches, default 100 messages or every 2s
+type ChatPersistenceConf struct {
+	BatchSize     int           `yaml:"batch_size"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
+}
+
+type LdapConf struct {
+	Enable bool `yaml:"enable"`
+	// Url e.g. ldaps://ad.example.com:636
+	Url                string        `yaml:"url"`
+	InsecureSkipVerify bool          `yaml:"insecure_skip_verify"`
+	Timeout            time.Duration `yaml:"timeout"`
+	// BindDn & BindPassword of service account to search users
+	BindDn       string `yaml:"bind_dn"`
+	BindPassword string `yaml:"bind_password"`
+	BaseDn       string `yaml:"base_dn"`
+	// UserAttribute to search by username, e.g. sAMAccountName or uid
+	UserAttribute  string `yaml:"user_attribute"`
+	NameAttribute  string `yaml:"name_attribute"`
+	GroupAttribute string `yaml:"group_attribute"`
+	// ModeratorGroups & AttendeeGroups are DNs of groups,
+	// those can be overridden per room during create
+	ModeratorGroups []string `yaml:"moderator_groups"`
+	// AttendeeGroups empty means any valid user can join as attendee
+	AttendeeGroups []string `yaml:"attendee_groups"`
+}
+
+type RateLimitConf struct {
+	Enable bool `yaml:"enable"`
+	// Window of sliding window, default 1m
+	Window time.Duration `yaml:"window"`
+	// PerIp & PerRoom are max requests within window, 0 means unlimited
+	PerIp   int `yaml:"per_ip"`
+	PerRoom int `yaml:"per_room"`
+}
+
+type RequestSignatureConf struct {
+	// RequireTimestamp will require HASH-TIMESTAMP (unix seconds) & HASH-NONCE headers
+	// & signature will be calculated using method, path, timestamp, nonce & body
+	RequireTimestamp bool `yaml:"require_timestamp"`
+	// MaxSkew is the allowed difference between server & request time, default 5m
+	MaxSkew time.Duration `yaml:"max_skew"`
+}
+
+type OidcConf struct {
+	Enable bool `yaml:"enable"`
+	// Issuer should serve /.well-known/openid-configuration
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+	// RolesClaim can be nested using dot, e.g. realm_access.roles
+	RolesClaim string `yaml:"roles_claim"`
+	// RoleScopes maps OIDC role to scopes, e.g. rooms:read, recordings:manage or admin
+	RoleScopes map[string][]string `yaml:"role_scopes"`
+}
+
+type JoinTokenSigning struct {
+	// ActiveKid will be used to sign new tokens, if empty then HS256 with api secret
+	ActiveKid string                `yaml:"active_kid"`
+	Keys      []JoinTokenSigningKey `yaml:"keys"`
+}
+
+type JoinTokenClaims struct {
+	// Issuer default is api_key
+	Issuer string `yaml:"issuer"`
+	// Audience will be added to the token & required during verification if set
+	Audience []string `yaml:"audience"`
+	// Ttl default is livekit_info.token_validity
+	Ttl time.Duration `yaml:"ttl"`
+	// ClockSkew is the tolerance during verification, default 1m
+	ClockSkew time.Duration `yaml:"clock_skew"`
+}
+
+// JoinTokenSigningKey supports RSA (RS256) & Ed25519 (EdDSA) keys in PEM format.
+// For retired keys, public_key_file is enough to verify already issued tokens.
+type JoinTokenSigningKey struct {
+	Kid            string `yaml:"kid"`
+	PrivateKeyFile string `yaml:"private_key_file"`
+	PublicKeyFile  string `yaml:"public_key_file"`
+}
+
+type ApiKeyPair struct {
+	Key    string `yaml:"key"`
+	Secret string `yaml:"secret"`
+	// Scopes to restrict the key, e.g. rooms:read. Empty means full access
+	Scopes []string `yaml:"scopes"`
+	// RecordingRetentionDays for rooms created by the key, 0 means default
+	RecordingRetentionDays int `yaml:"recording_retention_days"`
+	// RecordingQuotaMB for all recordings of rooms created by the key, 0 means default
+	RecordingQuotaMB int64 `yaml:"recording_quota_mb"`
 }
 
 type WebhookConf struct {
 	Enable              bool   `yaml:"enable"`
 	Url                 string `yaml:"url,omitempty"`
 	EnableForPerMeeting bool   `yaml:"enable_for_per_meeting"`
+	// EnableBatching will send events of a room within BatchWindow as single composite event
+	EnableBatching bool          `yaml:"enable_batching"`
+	BatchWindow    time.Duration `yaml:"batch_window"`
+	// MinInterval between starting two deliveries to the same url
+	MinInterval time.Duration `yaml:"min_interval"`
+	// Concurrency of deliveries to the same url, default 4.
+	// Events of the same room will always be delivered one by one in order
+	Concurrency int `yaml:"concurrency"`
+	// MaxQueueSize of pending events per room for an url, default 1000
+	MaxQueueSize int `yaml:"max_queue_size"`
+	// Timeout of a single delivery, default 10s
+	Timeout time.Duration `yaml:"timeout"`
 }
 
 type PrometheusConf struct {
@@ -73,6 +300,8 @@ type LivekitInfo struct {
 	ApiKey        string        `yaml:"api_key"`
 	Secret        string        `yaml:"secret"`
 	TokenValidity time.Duration `yaml:"token_validity"`
+	// Regions will map region name with livekit node id
+	Regions map[string]string `yaml:"regions"`
 }
 
 type RedisInfo struct {
@@ -85,6 +314,10 @@ type RedisInfo struct {
 	SentinelUsername  string   `yaml:"sentinel_username"`
 	SentinelPassword  string   `yaml:"sentinel_password"`
 	SentinelAddresses []string `yaml:"sentinel_addresses"`
+	// DefaultKeyTTL for transient keys of a room without duration
+	DefaultKeyTTL time.Duration `yaml:"default_key_ttl"`
+	// KeyTTLMargin will be added with room duration
+	KeyTTLMargin time.Duration `yaml:"key_ttl_margin"`
 }
 
 type MySqlInfo struct {
@@ -101,11 +334,280 @@ type UploadFileSettings struct {
 	MaxSize      uint64   `yaml:"max_size"`
 	KeepForever  bool     `yaml:"keep_forever"`
 	AllowedTypes []string `yaml:"allowed_types"`
+	// ChatAttachment empty values will use above settings
+	ChatAttachment ChatAttachmentConf `yaml:"chat_attachment"`
+	// OfficeConversion of whiteboard files those were requested with async
+	OfficeConversion OfficeConversionConf `yaml:"office_conversion"`
+	// Whiteboard documents, empty values will use above settings
+	Whiteboard WhiteboardUploadConf `yaml:"whiteboard"`
+}
+
+type WhiteboardUploadConf struct {
+	// MaxSize in MB
+	MaxSize uint64 `yaml:"max_size"`
+	// MaxPages of the document after conversion, default 500
+	MaxPages     int      `yaml:"max_pages"`
+	AllowedTypes []string `yaml:"allowed_types"`
+}
+
+type OfficeConversionConf struct {
+	// Workers is number of concurrent conversions per server, default 1
+	Workers int `yaml:"workers"`
+	// Timeout of a conversion, default 5m
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+type ChatAttachmentConf struct {
+	// MaxSize in MB
+	MaxSize      uint64   `yaml:"max_size"`
+	AllowedTypes []string `yaml:"allowed_types"`
+	// UseS3 will upload attachments to the bucket of recorder_info.storage.s3
+	UseS3 bool `yaml:"use_s3"`
 }
 
 type RecorderInfo struct {
 	RecordingFilesPath string        `yaml:"recording_files_path"`
 	TokenValidity      time.Duration `yaml:"token_validity"`
+	// TrashDays of deleted recordings, those can be restored within the days. Default 7
+	TrashDays int `yaml:"trash_days"`
+	// SignedUrlMaxValidity is max expiry of signed download urls, default 24h
+	SignedUrlMaxValidity time.Duration          `yaml:"signed_url_max_validity"`
+	Storage              RecordingStorageConf   `yaml:"storage"`
+	Retention            RecordingRetentionConf `yaml:"retention"`
+	TrackRecording       TrackRecordingConf     `yaml:"track_recording"`
+	Transcription        TranscriptionConf      `yaml:"transcription"`
+	Output               RecordingOutputConf    `yaml:"output"`
+	Quota                RecordingQuotaConf     `yaml:"quota"`
+	Thumbnails           RecordingThumbnailConf `yaml:"thumbnails"`
+	Transcode            RecordingTranscodeConf `yaml:"transcode"`
+	Rtmp                 RtmpBroadcastConf      `yaml:"rtmp"`
+	Hls                  HlsStreamConf          `yaml:"hls"`
+	Slate                BroadcastSlateConf     `yaml:"slate"`
+}
+
+// BroadcastSlateConf is the default slate which will be shown when the broadcast was paused
+type BroadcastSlateConf struct {
+	ImageUrl string `yaml:"image_url"`
+	Message  string `yaml:"message"`
+	// EgressLayout of the custom egress template which can render the slate,
+	// empty means livekit egress outputs will keep showing the room
+	EgressLayout string `yaml:"egress_layout"`
+}
+
+// HlsStreamConf is used to publish composite of the room as HLS stream using livekit egress
+type HlsStreamConf struct {
+	Enabled bool `yaml:"enabled"`
+	// Layout of the room composite egress, default speaker-dark
+	Layout string `yaml:"layout"`
+	// SegmentDuration in seconds, default 6
+	SegmentDuration uint32 `yaml:"segment_duration"`
+	// UseS3 will upload segments to the bucket of storage.s3,
+	// otherwise those will be written in recording_files_path & served by /hls
+	UseS3 bool `yaml:"use_s3"`
+	// EgressFilesPath is the path inside livekit egress where recording_files_path
+	// was mounted, default value of track_recording will be used
+	EgressFilesPath string `yaml:"egress_files_path"`
+	// PlaybackBaseUrl will be prepended to the playlist, e.g. https://cdn.example.com.
+	// For local storage it should be url of this server, otherwise relative url will be used
+	PlaybackBaseUrl string `yaml:"playback_base_url"`
+}
+
+// RtmpBroadcastConf is used when broadcasting to multiple destinations using livekit egress
+type RtmpBroadcastConf struct {
+	// Layout of the room composite egress, default speaker-dark
+	Layout string `yaml:"layout"`
+	// MaxDestinations of a session, default 5
+	MaxDestinations int `yaml:"max_destinations"`
+	// SrtLatency in milliseconds & SrtPassphrase will be used for srt destinations,
+	// if those weren't sent with the request
+	SrtLatency    int    `yaml:"srt_latency"`
+	SrtPassphrase string `yaml:"srt_passphrase"`
+	// EncryptionKey to encrypt stream keys of saved destinations,
+	// default derived from client secret. Changing it will make saved keys unusable.
+	EncryptionKey string `yaml:"encryption_key"`
+	// MinBitrate in kbps & MaxDroppedFramesPercent reported by the recorder
+	// to consider the broadcast unhealthy, 0 means won't check
+	MinBitrate              int     `yaml:"min_bitrate"`
+	MaxDroppedFramesPercent float64 `yaml:"max_dropped_frames_percent"`
+	// StatsStaleAfter will consider the broadcast disconnected
+	// if the recorder hasn't reported stats, default 30s
+	StatsStaleAfter time.Duration `yaml:"stats_stale_after"`
+	// ReconnectWindow to retry broadcasting by the recorder when the connection has dropped,
+	// before ending it with error. 0 means won't retry
+	ReconnectWindow time.Duration `yaml:"reconnect_window"`
+	// ReconnectInitialDelay will be doubled after each attempt up to 30s, default 2s
+	ReconnectInitialDelay time.Duration         `yaml:"reconnect_initial_delay"`
+	Encoding              BroadcastEncodingConf `yaml:"encoding"`
+}
+
+// BroadcastEncodingConf is default encoder settings of the broadcast,
+// those can be changed by the start request. Default 1280x720, 30fps, 3000kbps video & 128kbps audio
+type BroadcastEncodingConf struct {
+	Width     int `yaml:"width"`
+	Height    int `yaml:"height"`
+	Framerate int `yaml:"framerate"`
+	// VideoBitrate & AudioBitrate in kbps
+	VideoBitrate int `yaml:"video_bitrate"`
+	AudioBitrate int `yaml:"audio_bitrate"`
+	// MaxVideoBitrate which can be requested, 0 means no limit
+	MaxVideoBitrate int `yaml:"max_video_bitrate"`
+}
+
+type RecordingTranscodeConf struct {
+	Enabled bool `yaml:"enabled"`
+	// Workers is number of concurrent transcodes per server, default 1
+	Workers  int                `yaml:"workers"`
+	Profiles []TranscodeProfile `yaml:"profiles"`
+}
+
+// TranscodeProfile is a rendition of the recording, it will be skipped
+// if height of the recording isn't bigger than the profile
+type TranscodeProfile struct {
+	// Name should be unique, e.g. 720p. It will be used in the file name
+	Name   string `yaml:"name"`
+	Height int    `yaml:"height"`
+	// VideoBitrate & AudioBitrate in ffmpeg format, e.g. 2500k
+	VideoBitrate string `yaml:"video_bitrate"`
+	AudioBitrate string `yaml:"audio_bitrate"`
+}
+
+type RecordingThumbnailConf struct {
+	Enabled bool `yaml:"enabled"`
+	// Count of thumbnails, those will be taken at equal intervals. Default 3
+	Count int `yaml:"count"`
+	// Width of thumbnails, default 320. Height will keep aspect ratio
+	Width int `yaml:"width"`
+	// PosterWidth default 1280
+	PosterWidth int `yaml:"poster_width"`
+}
+
+// RecordingQuotaConf is the default storage quota of the recordings, 0 means unlimited
+type RecordingQuotaConf struct {
+	// RoomMB is total size of the recordings of a room id
+	RoomMB int64 `yaml:"room_mb"`
+	// ApiKeyMB is total size of the recordings of rooms created by an api key.
+	// It can be overridden using recording_quota_mb of api key
+	ApiKeyMB int64 `yaml:"api_key_mb"`
+}
+
+// RecordingOutputConf is default output of the recordings.
+// Empty values will let the recorder use its own defaults
+type RecordingOutputConf struct {
+	// Container: mp4 or webm
+	Container string `yaml:"container"`
+	// VideoCodec: h264 or vp9
+	VideoCodec string `yaml:"video_codec"`
+	// AudioCodec: aac or opus
+	AudioCodec string `yaml:"audio_codec"`
+	Width      int    `yaml:"width"`
+	Height     int    `yaml:"height"`
+	// Layout: grid, speaker or presentation. Empty will let the recorder decide
+	Layout string `yaml:"layout"`
+}
+
+type TranscriptionConf struct {
+	Enabled bool `yaml:"enabled"`
+	// Provider: whisper, azure or google
+	Provider string `yaml:"provider"`
+	// Language of the recordings, e.g. en-US. Empty means auto-detect if provider supports
+	Language string `yaml:"language"`
+	// Workers is number of concurrent transcriptions per server, default 1
+	Workers int `yaml:"workers"`
+	// MaxAttempts of a failed transcription, default 3
+	MaxAttempts int                   `yaml:"max_attempts"`
+	Whisper     WhisperTranscribeConf `yaml:"whisper"`
+	Azure       AzureTranscribeConf   `yaml:"azure"`
+	Google      GoogleTranscribeConf  `yaml:"google"`
+}
+
+type WhisperTranscribeConf struct {
+	// Url of OpenAI compatible server, default https://api.openai.com/v1
+	Url    string `yaml:"url"`
+	ApiKey string `yaml:"api_key"`
+	Model  string `yaml:"model"`
+}
+
+type AzureTranscribeConf struct {
+	Region          string `yaml:"region"`
+	SubscriptionKey string `yaml:"subscription_key"`
+}
+
+type GoogleTranscribeConf struct {
+	ApiKey string `yaml:"api_key"`
+}
+
+type TrackRecordingConf struct {
+	// Mode: composite (default), individual or both.
+	// It can be overridden per room
+	Mode string `yaml:"mode"`
+	// EgressFilesPath is the path inside livekit egress where
+	// recording_files_path was mounted
+	EgressFilesPath string `yaml:"egress_files_path"`
+}
+
+type RecordingRetentionConf struct {
+	// DefaultDays 0 means recordings will be kept forever.
+	// It can be overridden per api key or per room
+	DefaultDays int `yaml:"default_days"`
+	// CheckInterval of the expired recordings, default 1h
+	CheckInterval time.Duration `yaml:"check_interval"`
+}
+
+type RecordingStorageConf struct {
+	// Driver: local (default), s3, gcs or azure
+	Driver string `yaml:"driver"`
+	// DeleteLocal will delete local file after successful upload
+	DeleteLocal bool `yaml:"delete_local"`
+	// PartSize in bytes for multipart upload, default 8MiB
+	PartSize int64 `yaml:"part_size"`
+	// MaxRetries of a failed request, default 3
+	MaxRetries int `yaml:"max_retries"`
+	// UploadAttempts of a failed upload, those will be retried later in background. Default 5
+	UploadAttempts int              `yaml:"upload_attempts"`
+	S3             S3StorageConf    `yaml:"s3"`
+	Gcs            GcsStorageConf   `yaml:"gcs"`
+	Azure          AzureStorageConf `yaml:"azure"`
+}
+
+type S3StorageConf struct {
+	// Endpoint is required for S3 compatible servers, e.g. http://minio:9000
+	Endpoint  string `yaml:"endpoint"`
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	Prefix    string `yaml:"prefix"`
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+	// UsePathStyle should be true for MinIO
+	UsePathStyle bool `yaml:"use_path_style"`
+	// ServerSideEncryption e.g. AES256 or aws:kms
+	ServerSideEncryption string `yaml:"server_side_encryption"`
+}
+
+type GcsStorageConf struct {
+	Bucket string `yaml:"bucket"`
+	Prefix string `yaml:"prefix"`
+	// CredentialsFile of service account in json format
+	CredentialsFile string `yaml:"credentials_file"`
+	// KmsKeyName to encrypt using customer managed key
+	KmsKeyName string `yaml:"kms_key_name"`
+}
+
+type AzureStorageConf struct {
+	// Endpoint default https://<account_name>.blob.core.windows.net
+	Endpoint    string `yaml:"endpoint"`
+	AccountName string `yaml:"account_name"`
+	AccountKey  string `yaml:"account_key"`
+	Container   string `yaml:"container"`
+	Prefix      string `yaml:"prefix"`
+	// EncryptionScope to encrypt using the scope's key
+	EncryptionScope string `yaml:"encryption_scope"`
+}
+
+// IngressConf is used to publish external rtmp streams into the room using livekit ingress
+type IngressConf struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxPerRoom default 3
+	MaxPerRoom int `yaml:"max_per_room"`
 }
 
 type SharedNotePad struct {
@@ -275,6 +777,25 @@ func (a *AppConfig) IncreaseRoomDuration(roomId string, duration uint64) uint64
 	return 0
 }
 
+// FastForwardRoomDuration will move the start time of the room backward
+// so that the duration will expire earlier. Useful to test room end.
+func (a *AppConfig) FastForwardRoomDuration(roomId string, minutes uint64) bool {
+	a.Lock()
+	defer a.Unlock()
+	if r, ok := a.roomWithDuration[roomId]; ok {
+		// StartedAt is unsigned, so we'll stop at zero instead of wrapping
+		if r.StartedAt > minutes*60 {
+			r.StartedAt = r.StartedAt - (minutes * 60)
+		} else {
+			r.StartedAt = 0
+		}
+		a.roomWithDuration[roomId] = r
+		return true
+	}
+
+	return false
+}
+
 func (a *AppConfig) readClientFiles() {
 	// if enable debug mode then we won't cache files
 	// otherwise changes of files won't be load
@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"github.com/mynaparrot/plugnmeet-server/pkg/models"
+)
+
+func HandleGetRedisUsage(c *fiber.Ctx) error {
+	m := models.NewRedisUsageModel()
+	usage, err := m.GetMemoryUsage()
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"usage":  usage,
+	})
+}
+
+func HandleSimulateRoomExpiry(c *fiber.Ctx) error {
+	req := new(models.SimulateRoomExpiryReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	m := models.NewSchedulerModel()
+	err = m.SimulateRoomExpiry(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+	})
+}
+
+func HandleReconcileRooms(c *fiber.Ctx) error {
+	m := models.NewSchedulerModel()
+	result, err := m.ReconcileRooms()
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"result": result,
+	})
+}
+
+func HandleListApiKeys(c *fiber.Ctx) error {
+	m := models.NewApiKeysModel()
+	keys, err := m.ListApiKeys()
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"keys":   keys,
+	})
+}
+
+func HandleAddApiKey(c *fiber.Ctx) error {
+	req := new(models.AddApiKeyReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	m := models.NewApiKeysModel()
+	info, err := m.AddApiKey(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"msg":     "success",
+		"api_key": info,
+	})
+}
+
+func HandleRevokeApiKey(c *fiber.Ctx) error {
+	req := new(models.RevokeApiKeyReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	requestedBy, _ := c.Locals("apiKey").(string)
+	actor := requestedBy
+	// OIDC requests are served using the primary key, so it can't be revoked by them
+	if identity, ok := c.Locals("oidcIdentity").(*models.OidcIdentity); ok {
+		requestedBy = config.AppCnf.Client.ApiKey
+		actor = "oidc:" + identity.Subject
+	}
+
+	m := models.NewApiKeysModel()
+	err = m.RevokeApiKey(req, requestedBy)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	details := "revoked api key: " + req.Key
+	if req.ReplacedBy != "" {
+		details += ", replaced by: " + req.ReplacedBy
+	}
+	models.NewAuditLogModel().AddAuditLog(&models.AuditLog{
+		Action:    "revoke_api_key",
+		Actor:     actor,
+		ActorIp:   c.IP(),
+		Details:   details,
+		Succeeded: true,
+	})
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+	})
+}
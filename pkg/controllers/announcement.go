@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mynaparrot/plugnmeet-protocol/utils"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"github.com/mynaparrot/plugnmeet-server/pkg/models"
+)
+
+func HandleSendAnnouncement(c *fiber.Ctx) error {
+	isAdmin := c.Locals("isAdmin")
+	roomId := c.Locals("roomId")
+	requestedUserId := c.Locals("requestedUserId")
+
+	if isAdmin != true {
+		return utils.SendCommonResponse(c, false, "only admin can perform this task")
+	}
+
+	req := new(models.SendAnnouncementReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+	req.RoomId = roomId.(string)
+	req.RequestedUserId = requestedUserId.(string)
+
+	rs := models.NewRoomService()
+	announcement, err := rs.SendAnnouncement(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status":       true,
+		"msg":          "success",
+		"announcement": announcement,
+	})
+}
+
+func HandleGetAnnouncements(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+
+	rs := models.NewRoomService()
+	announcements, err := rs.GetAnnouncements(roomId.(string))
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status":        true,
+		"msg":           "success",
+		"announcements": announcements,
+	})
+}
@@ -1,10 +1,6 @@
 package controllers
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
-	"crypto/subtle"
-	"encoding/hex"
 	"github.com/gofiber/fiber/v2"
 	"github.com/livekit/protocol/auth"
 	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
@@ -14,19 +10,31 @@ import (
 	"github.com/mynaparrot/plugnmeet-server/version"
 	"google.golang.org/protobuf/proto"
 	"strings"
+	"time"
 )
 
 // HandleAuthHeaderCheck will check auth values
 // It will accept 2 header values: API-KEY & HASH-SIGNATURE
 // HASH-SIGNATURE will require to calculated hmac sha256 using
-// body + Secret key
+// body + Secret key. If request_signature.require_timestamp was enabled
+// then HASH-TIMESTAMP & HASH-NONCE headers will be required & signature will use
+// method, path with query string, timestamp, nonce & body separated by new line
 // Deprecated API-SECRET will be removed in next release
+// If OIDC was enabled then Authorization: Bearer token can be used instead
 func HandleAuthHeaderCheck(c *fiber.Ctx) error {
+	if config.AppCnf.Client.Oidc.Enable {
+		if bearer := c.Get("Authorization"); strings.HasPrefix(bearer, "Bearer ") {
+			return handleOidcAuth(c, strings.TrimPrefix(bearer, "Bearer "))
+		}
+	}
+
 	apiKey := c.Get("API-KEY", "")
 	signature := c.Get("HASH-SIGNATURE", "")
 	body := c.Body()
 
-	if apiKey != config.AppCnf.Client.ApiKey {
+	// multiple key/secret pairs can be active, so we'll resolve secret by key
+	keyInfo, err := models.NewApiKeysModel().LookupApiKey(apiKey)
+	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"status": false,
 			"msg":    "invalid API key",
@@ -39,23 +47,66 @@ func HandleAuthHeaderCheck(c *fiber.Ctx) error {
 		})
 	}
 
-	status := false
-	if signature != "" {
-		mac := hmac.New(sha256.New, []byte(config.AppCnf.Client.Secret))
-		mac.Write(body)
-		expectedSignature := hex.EncodeToString(mac.Sum(nil))
-		if subtle.ConstantTimeCompare([]byte(expectedSignature), []byte(signature)) == 1 {
-			status = true
+	// with timestamp & nonce, captured requests can't be replayed
+	rsc := config.AppCnf.Client.RequestSignature
+	var timestamp, nonce string
+	if rsc.RequireTimestamp {
+		timestamp = c.Get("HASH-TIMESTAMP", "")
+		if !models.IsValidRequestTimestamp(timestamp, rsc.MaxSkew, time.Now()) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"status": false,
+				"msg":    "missing or stale hash timestamp",
+			})
 		}
+		nonce = c.Get("HASH-NONCE", "")
 	}
 
-	if !status {
+	payload := models.RequestSignaturePayload(c.Method(), c.OriginalURL(), timestamp, nonce, body)
+	if !models.VerifyRequestSignature(keyInfo.Secret, payload, signature) {
 		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
 			"status": false,
 			"msg":    "can't verify provided information",
 		})
 	}
+	if rsc.RequireTimestamp {
+		if err = models.ConsumeRequestNonce(apiKey, nonce, rsc.MaxSkew); err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"status": false,
+				"msg":    err.Error(),
+			})
+		}
+	}
+
+	// restricted keys can access only the allowed endpoints
+	if !models.HasScope(keyInfo.GrantedScopes(), models.RequiredScope(c.Path())) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status": false,
+			"msg":    "you don't have permission to access this endpoint",
+		})
+	}
+
+	c.Locals("apiKey", apiKey)
+	return c.Next()
+}
+
+func handleOidcAuth(c *fiber.Ctx, token string) error {
+	m := models.NewOidcModel()
+	identity, err := m.VerifyToken(token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	if !identity.HasScope(models.RequiredScope(c.Path())) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status": false,
+			"msg":    "you don't have permission to access this endpoint",
+		})
+	}
 
+	c.Locals("oidcIdentity", identity)
 	return c.Next()
 }
 
@@ -84,16 +135,6 @@ func HandleGenerateJoinToken(c *fiber.Ctx) error {
 		})
 	}
 
-	// don't generate token if user is blocked
-	rs := models.NewRoomService()
-	exist := rs.IsUserExistInBlockList(req.RoomId, req.UserInfo.UserId)
-	if exist {
-		return c.JSON(fiber.Map{
-			"status": false,
-			"msg":    "this user is blocked to join this session",
-		})
-	}
-
 	rm := models.NewRoomModel()
 	ri, _ := rm.GetRoomInfo(req.RoomId, "", 1)
 	if ri.Id == 0 {
@@ -103,8 +144,20 @@ func HandleGenerateJoinToken(c *fiber.Ctx) error {
 		})
 	}
 
+	// single_use & join_mode aren't part of GenerateTokenReq
+	opts := new(struct {
+		SingleUse bool   `json:"single_use"`
+		JoinMode  string `json:"join_mode"`
+	})
+	_ = c.BodyParser(opts)
+
 	m := models.NewAuthTokenModel()
-	token, err := m.DoGenerateToken(req)
+	var token string
+	if opts.JoinMode == models.JoinModeViewer {
+		token, err = m.DoGenerateViewerToken(req)
+	} else {
+		token, err = m.DoGenerateTokenWithPermissions(req, parseUserMediaPermissions(c))
+	}
 	if err != nil {
 		return c.JSON(fiber.Map{
 			"status": false,
@@ -112,6 +165,16 @@ func HandleGenerateJoinToken(c *fiber.Ctx) error {
 		})
 	}
 
+	if opts.SingleUse {
+		err = m.MarkTokenSingleUse(token)
+		if err != nil {
+			return c.JSON(fiber.Map{
+				"status": false,
+				"msg":    err.Error(),
+			})
+		}
+	}
+
 	return c.JSON(fiber.Map{
 		"status": true,
 		"msg":    "success",
@@ -119,6 +182,17 @@ func HandleGenerateJoinToken(c *fiber.Ctx) error {
 	})
 }
 
+// parseUserMediaPermissions will parse media permissions from user_info,
+// as those aren't part of GenerateTokenReq
+func parseUserMediaPermissions(c *fiber.Ctx) *models.UserMediaPermissions {
+	extra := new(struct {
+		UserInfo *models.UserMediaPermissions `json:"user_info"`
+	})
+	_ = c.BodyParser(extra)
+
+	return extra.UserInfo
+}
+
 func HandleVerifyToken(c *fiber.Ctx) error {
 	roomId := c.Locals("roomId")
 	requestedUserId := c.Locals("requestedUserId")
@@ -128,6 +202,17 @@ func HandleVerifyToken(c *fiber.Ctx) error {
 	if exist {
 		return utils.SendCommonResponse(c, false, "notifications.you-are-blocked")
 	}
+	if rs.IsIpExistInBlockList(roomId.(string), c.IP()) {
+		return utils.SendCommonResponse(c, false, "notifications.you-are-blocked")
+	}
+	if !rs.LoadRoomOptions(roomId.(string)).IsIpAllowed(c.IP()) {
+		return utils.SendCommonResponse(c, false, "notifications.ip-not-allowed")
+	}
+	_ = rs.SaveParticipantIp(roomId.(string), requestedUserId.(string), c.IP())
+	userAgent := c.Get("User-Agent")
+	rs.UpdateParticipantPresence(roomId.(string), requestedUserId.(string), func(p *models.ParticipantPresence) {
+		p.Device = userAgent
+	})
 
 	req := new(plugnmeet.VerifyTokenReq)
 	err := proto.Unmarshal(c.Body(), req)
@@ -144,6 +229,32 @@ func HandleVerifyToken(c *fiber.Ctx) error {
 	c.Locals("claims", nil)
 
 	au := models.NewAuthTokenModel()
+	if config.AppCnf.Client.RequireVerifyNonce {
+		err = au.ConsumeVerifyNonce(c.Get("VERIFY-NONCE"), c.Get("Authorization"), userAgent)
+		if err != nil {
+			return utils.SendCommonResponse(c, false, err.Error())
+		}
+	}
+
+	// single-use token or guest link can't be used again, except by the same user to rejoin
+	session := models.TokenSession(c.IP(), userAgent)
+	rejoin, err := au.CheckSingleUseToken(c.Get("Authorization"), session, roomId.(string), requestedUserId.(string))
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	// second connection with same identity will be handled based on room policy,
+	// rejoin using single-use token will always replace the old connection
+	if !rejoin {
+		identity, err := rs.ResolveDuplicateJoin(roomId.(string), claims.Identity, c.IP()+"|"+userAgent)
+		if err != nil {
+			return utils.SendCommonResponse(c, false, err.Error())
+		}
+		claims.Identity = identity
+	} else {
+		rs.AllowIdentityReplace(roomId.(string), claims.Identity)
+	}
+
 	token, err := au.GenerateLivekitToken(claims)
 	if err != nil {
 		return utils.SendCommonResponse(c, false, err.Error())
@@ -166,23 +277,28 @@ func HandleVerifyToken(c *fiber.Ctx) error {
 		ServerVersion: &v,
 	}
 
-	if !*req.IsProduction {
-		return utils.SendProtoResponse(c, res)
-	}
+	if *req.IsProduction {
+		// if production then we'll check if room is active or not
+		// if not active then we don't allow to join user
+		// livekit also don't allow but throw 500 error which make confused to user.
+		m := models.NewRoomAuthModel()
+		status, msg := m.IsRoomActive(&plugnmeet.IsRoomActiveReq{
+			RoomId: roomId.(string),
+		})
 
-	// if production then we'll check if room is active or not
-	// if not active then we don't allow to join user
-	// livekit also don't allow but throw 500 error which make confused to user.
-	m := models.NewRoomAuthModel()
-	status, msg := m.IsRoomActive(&plugnmeet.IsRoomActiveReq{
-		RoomId: roomId.(string),
-	})
+		if !status {
+			return utils.SendCommonResponse(c, status, msg)
+		}
+		res.Msg = msg
+	}
 
-	if !status {
-		return utils.SendCommonResponse(c, status, msg)
+	// all the checks have passed, so now the link can be marked as used
+	if !rejoin {
+		if err = au.ConsumeSingleUseToken(c.Get("Authorization"), session); err != nil {
+			return utils.SendCommonResponse(c, false, err.Error())
+		}
 	}
 
-	res.Msg = msg
 	return utils.SendProtoResponse(c, res)
 }
 
@@ -205,7 +321,8 @@ func HandleVerifyHeaderToken(c *fiber.Ctx) error {
 	}
 
 	info := &models.ValidateTokenReq{
-		Token: authToken,
+		Token:   authToken,
+		Session: models.TokenSession(c.IP(), c.Get("User-Agent")),
 	}
 
 	claims, err := m.DoValidateToken(info, false)
@@ -249,6 +366,7 @@ func HandleRenewToken(c *fiber.Ctx) error {
 			"msg":    "missing required fields",
 		})
 	}
+	info.Session = models.TokenSession(c.IP(), c.Get("User-Agent"))
 
 	token, err := m.DoRenewToken(info)
 	if err != nil {
@@ -264,3 +382,163 @@ func HandleRenewToken(c *fiber.Ctx) error {
 		"token":  token,
 	})
 }
+
+// HandleRenewTokenForAuth will renew token from backend, it's possible to renew recently expired token too
+func HandleRenewTokenForAuth(c *fiber.Ctx) error {
+	info := new(models.ValidateTokenReq)
+	err := c.BodyParser(info)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	if info.Token == "" || info.RoomId == "" {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    "missing required fields",
+		})
+	}
+
+	m := models.NewAuthTokenModel()
+	token, err := m.DoRenewTokenWithLeeway(info)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "token renewed",
+		"token":  token,
+	})
+}
+
+// HandleGenerateGuestJoinLink will generate single-use join url with expiry
+func HandleGenerateGuestJoinLink(c *fiber.Ctx) error {
+	req := new(plugnmeet.GenerateTokenReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	err = req.Validate()
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	if req.UserInfo == nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    "UserInfo required",
+		})
+	}
+
+	// link options aren't part of GenerateTokenReq
+	opts := new(models.GuestJoinLinkOpts)
+	_ = c.BodyParser(opts)
+	opts.Permissions = parseUserMediaPermissions(c)
+
+	rm := models.NewRoomModel()
+	ri, _ := rm.GetRoomInfo(req.RoomId, "", 1)
+	if ri.Id == 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    "room is not active. create room first",
+		})
+	}
+
+	m := models.NewAuthTokenModel()
+	token, err := m.GenerateGuestJoinToken(req, opts)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"token":  token,
+		"url":    c.BaseURL() + "/?access_token=" + token,
+	})
+}
+
+// HandleGetJWKS will return public keys, so that other services can verify join tokens
+func HandleGetJWKS(c *fiber.Ctx) error {
+	return c.JSON(models.GetJoinTokenJWKS())
+}
+
+// HandleRevokeToken will revoke the token or all tokens of the user
+// & remove the user from the room
+func HandleRevokeToken(c *fiber.Ctx) error {
+	req := new(models.RevokeTokenReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	m := models.NewTokenRevocationModel()
+	revoked, err := m.RevokeToken(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	apiKey, _ := c.Locals("apiKey").(string)
+	models.NewAuditLogModel().AddAuditLog(&models.AuditLog{
+		Action:    "revoke_token",
+		RoomId:    req.RoomId,
+		Actor:     apiKey,
+		ActorIp:   c.IP(),
+		Details:   "revoked tokens of user: " + req.UserId,
+		Succeeded: true,
+	})
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"msg":     "success",
+		"revoked": revoked,
+	})
+}
+
+// HandleGetVerifyNonce will issue nonce which should be sent
+// as VERIFY-NONCE header value during verify token
+func HandleGetVerifyNonce(c *fiber.Ctx) error {
+	m := models.NewAuthTokenModel()
+	nonce, err := m.GenerateVerifyNonce(c.Get("Authorization"), c.Get("User-Agent"))
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"nonce":  nonce,
+	})
+}
@@ -32,11 +32,28 @@ func HandleCreateBreakoutRooms(c *fiber.Ctx) error {
 		return SendBreakoutRoomResponse(c, res)
 	}
 
+	// body is protobuf, so naming scheme will come as query
+	naming := &models.BreakoutRoomNaming{
+		Scheme:   c.Query("naming_scheme"),
+		Prefix:   c.Query("naming_prefix"),
+		Template: c.Query("naming_template"),
+	}
+	err = naming.Validate()
+	if err != nil {
+		res.Msg = err.Error()
+		return SendBreakoutRoomResponse(c, res)
+	}
+
 	req.RoomId = roomId.(string)
 	req.RequestedUserId = requestedUserId.(string)
 
+	placement := &models.RoomPlacement{
+		NodeId: c.Query("node_id"),
+		Region: c.Query("region"),
+	}
+
 	m := models.NewBreakoutRoomModel()
-	err = m.CreateBreakoutRooms(req)
+	err = m.CreateBreakoutRooms(req, naming, placement)
 	if err != nil {
 		res.Msg = err.Error()
 		return SendBreakoutRoomResponse(c, res)
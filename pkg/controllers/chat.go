@@ -0,0 +1,236 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mynaparrot/plugnmeet-protocol/utils"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"github.com/mynaparrot/plugnmeet-server/pkg/models"
+)
+
+// HandleExportChat will return all messages of the session
+func HandleExportChat(c *fiber.Ctx) error {
+	return exportChat(c, &models.ChatExportViewer{
+		IsAdmin: true,
+	})
+}
+
+// HandleExportChatForAPI will return messages those are visible to the user
+func HandleExportChatForAPI(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+	if c.Query("room_id") != roomId {
+		return utils.SendCommonResponse(c, false, "roomId in token mismatched")
+	}
+
+	isAdmin, _ := c.Locals("isAdmin").(bool)
+	userId, _ := c.Locals("requestedUserId").(string)
+	return exportChat(c, &models.ChatExportViewer{
+		UserId:  userId,
+		IsAdmin: isAdmin,
+	})
+}
+
+func exportChat(c *fiber.Ctx, viewer *models.ChatExportViewer) error {
+	req := new(models.ExportChatReq)
+	err := c.QueryParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	if !viewer.IsAdmin {
+		// others can export the current session only
+		rm := models.NewRoomModel()
+		room, _ := rm.GetRoomInfo(req.RoomId, "", 1)
+		if room.Id == 0 {
+			return utils.SendCommonResponse(c, false, "room isn't running")
+		}
+		req.RoomSid = room.Sid
+	}
+
+	m := models.NewChatExportModel()
+	data, fileName, err := m.ExportChat(req, viewer)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	c.Attachment(fileName)
+	return c.Send(data)
+}
+
+func HandleDeleteChatMessage(c *fiber.Ctx) error {
+	isAdmin := c.Locals("isAdmin")
+	roomId := c.Locals("roomId")
+	requestedUserId := c.Locals("requestedUserId")
+
+	if isAdmin != true {
+		return utils.SendCommonResponse(c, false, "only admin can perform this task")
+	}
+
+	req := new(models.DeleteChatMessageReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+	req.RoomId = roomId.(string)
+	req.RequestedUserId = requestedUserId.(string)
+
+	rs := models.NewRoomService()
+	err = rs.DeleteChatMessage(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return utils.SendCommonResponse(c, true, "success")
+}
+
+func HandleMuteUserChat(c *fiber.Ctx) error {
+	isAdmin := c.Locals("isAdmin")
+	roomId := c.Locals("roomId")
+
+	if isAdmin != true {
+		return utils.SendCommonResponse(c, false, "only admin can perform this task")
+	}
+
+	req := new(models.MuteUserChatReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+	req.RoomId = roomId.(string)
+
+	rs := models.NewRoomService()
+	mutedUntil, err := rs.MuteUserChat(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status":      true,
+		"msg":         "success",
+		"muted_until": mutedUntil,
+	})
+}
+
+func HandlePinChatMessage(c *fiber.Ctx) error {
+	return pinChatMessage(c, true)
+}
+
+func HandleUnpinChatMessage(c *fiber.Ctx) error {
+	return pinChatMessage(c, false)
+}
+
+func pinChatMessage(c *fiber.Ctx, pin bool) error {
+	isAdmin := c.Locals("isAdmin")
+	roomId := c.Locals("roomId")
+	requestedUserId := c.Locals("requestedUserId")
+
+	if isAdmin != true {
+		return utils.SendCommonResponse(c, false, "only admin can perform this task")
+	}
+
+	req := new(models.PinChatMessageReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+	req.RoomId = roomId.(string)
+	req.RequestedUserId = requestedUserId.(string)
+
+	rs := models.NewRoomService()
+	if pin {
+		err = rs.PinChatMessage(req)
+	} else {
+		err = rs.UnpinChatMessage(req)
+	}
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return utils.SendCommonResponse(c, true, "success")
+}
+
+func HandleGetChatPins(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+
+	rs := models.NewRoomService()
+	pins, err := rs.GetChatPins(roomId.(string))
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"pins":   pins,
+	})
+}
+
+// HandleSetChatTranslation will let the user receive chat messages in preferred language
+func HandleSetChatTranslation(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+	requestedUserId := c.Locals("requestedUserId")
+
+	req := new(models.SetChatTranslationReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+	req.RoomId = roomId.(string)
+	req.UserId = requestedUserId.(string)
+
+	rs := models.NewRoomService()
+	err = rs.SetChatTranslation(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return utils.SendCommonResponse(c, true, "success")
+}
+
+// HandleGetReactionCounts will return counts of each emoji in the current window
+func HandleGetReactionCounts(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+
+	rs := models.NewRoomService()
+	counts := rs.GetReactionCounts(roomId.(string))
+
+	return c.JSON(fiber.Map{
+		"status":    true,
+		"msg":       "success",
+		"reactions": counts,
+	})
+}
@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"bufio"
+	"github.com/gofiber/fiber/v2"
+	"github.com/mynaparrot/plugnmeet-server/pkg/models"
+)
+
+// HandleEventStream will stream events as NDJSON using chunked response
+func HandleEventStream(c *fiber.Ctx) error {
+	req := new(models.EventStreamReq)
+	if len(c.Body()) > 0 {
+		err := c.BodyParser(req)
+		if err != nil {
+			return c.JSON(fiber.Map{
+				"status": false,
+				"msg":    err.Error(),
+			})
+		}
+	}
+
+	// never trust api_key from body
+	req.ApiKey, _ = c.Locals("apiKey").(string)
+
+	c.Set("Content-Type", "application/x-ndjson")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		m := models.NewEventStreamModel()
+		m.StreamEvents(req, w)
+	})
+
+	return nil
+}
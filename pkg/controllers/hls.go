@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"github.com/mynaparrot/plugnmeet-server/pkg/models"
+)
+
+func HandleStartHls(c *fiber.Ctx) error {
+	return handleHlsReq(c, func(roomId string) (interface{}, error) {
+		return models.NewRecordingModel().StartHls(roomId)
+	})
+}
+
+func HandleStopHls(c *fiber.Ctx) error {
+	return handleHlsReq(c, func(roomId string) (interface{}, error) {
+		return nil, models.NewRecordingModel().StopHls(roomId)
+	})
+}
+
+func HandleGetHlsInfo(c *fiber.Ctx) error {
+	return handleHlsReq(c, func(roomId string) (interface{}, error) {
+		return models.NewRecordingModel().GetHlsStream(roomId)
+	})
+}
+
+func handleHlsReq(c *fiber.Ctx, fn func(roomId string) (interface{}, error)) error {
+	req := new(models.HlsReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	hls, err := fn(req.RoomId)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	res := fiber.Map{
+		"status": true,
+		"msg":    "success",
+	}
+	if hls != nil {
+		res["hls"] = hls
+	}
+	return c.JSON(res)
+}
+
+// HandleHls can be used by admin of the room to start or stop HLS stream
+func HandleHls(c *fiber.Ctx) error {
+	isAdmin := c.Locals("isAdmin")
+	roomId := c.Locals("roomId")
+
+	if isAdmin != true {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    "only admin can start HLS streaming",
+		})
+	}
+
+	req := new(models.HlsTaskReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	m := models.NewRecordingModel()
+	res := fiber.Map{
+		"status": true,
+		"msg":    "success",
+	}
+	if req.Task == "start" {
+		hls, err := m.StartHls(roomId.(string))
+		if err != nil {
+			return c.JSON(fiber.Map{
+				"status": false,
+				"msg":    err.Error(),
+			})
+		}
+		res["hls"] = hls
+	} else if err = m.StopHls(roomId.(string)); err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(res)
+}
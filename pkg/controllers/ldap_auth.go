@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"github.com/mynaparrot/plugnmeet-server/pkg/models"
+)
+
+// HandleLdapLogin will exchange LDAP credentials for a join token
+func HandleLdapLogin(c *fiber.Ctx) error {
+	req := new(models.LdapLoginReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	rm := models.NewRoomModel()
+	ri, _ := rm.GetRoomInfo(req.RoomId, "", 1)
+	if ri.Id == 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    "room is not active",
+		})
+	}
+
+	m := models.NewLdapAuthModel()
+	res, err := m.Login(req)
+
+	audit := &models.AuditLog{
+		Action:    "ldap_login",
+		RoomId:    req.RoomId,
+		RoomSid:   ri.Sid,
+		Actor:     req.Username,
+		ActorIp:   c.IP(),
+		Succeeded: err == nil,
+	}
+	if err != nil {
+		audit.Details = err.Error()
+	}
+	models.NewAuditLogModel().AddAuditLog(audit)
+
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":   true,
+		"msg":      "success",
+		"token":    res.Token,
+		"is_admin": res.IsAdmin,
+	})
+}
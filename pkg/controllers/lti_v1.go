@@ -137,7 +137,7 @@ func HandleLTIV1EndRoom(c *fiber.Ctx) error {
 	m := models.NewRoomAuthModel()
 	status, msg := m.EndRoom(&plugnmeet.RoomEndReq{
 		RoomId: roomId.(string),
-	})
+	}, models.RoomEndReasonEndedByModerator)
 
 	return c.JSON(fiber.Map{
 		"status": status,
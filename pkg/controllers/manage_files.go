@@ -45,6 +45,22 @@ func HandleFileUpload(c *fiber.Ctx) error {
 		})
 	}
 
+	if req.Purpose == models.ManageFilePurposeChat {
+		res, err := m.UploadChatAttachment(c)
+		if err != nil {
+			_ = c.SendStatus(fiber.StatusBadRequest)
+			return c.JSON(fiber.Map{
+				"status": false,
+				"msg":    err.Error(),
+			})
+		}
+		return c.JSON(fiber.Map{
+			"status":     true,
+			"msg":        "file uploaded successfully",
+			"attachment": res,
+		})
+	}
+
 	if req.Resumable {
 		res, err := m.ResumableFileUpload(c)
 		if err != nil {
@@ -94,6 +110,24 @@ func HandleDownloadUploadedFile(c *fiber.Ctx) error {
 	return c.SendFile(file)
 }
 
+func HandleDownloadChatAttachment(c *fiber.Ctx) error {
+	a, file, remote, err := models.VerifyChatAttachmentUrl(c.Params("sid"), c.Params("fileId"), c.Query("expires"), c.Query("sig"))
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	if remote {
+		return c.Redirect(file)
+	}
+
+	c.Set("Content-Disposition", "attachment; filename="+strconv.Quote(a.FileName))
+	c.Set("Content-Type", a.MimeType)
+	return c.SendFile(file)
+}
+
 func HandleConvertWhiteboardFile(c *fiber.Ctx) error {
 	req := new(models.ManageFile)
 	err := c.BodyParser(req)
@@ -119,6 +153,21 @@ func HandleConvertWhiteboardFile(c *fiber.Ctx) error {
 	}
 
 	m := models.NewManageFileModel(req)
+	if req.Async {
+		status, err := m.EnqueueWhiteboardConversion()
+		if err != nil {
+			return c.JSON(fiber.Map{
+				"status": false,
+				"msg":    err.Error(),
+			})
+		}
+		return c.JSON(fiber.Map{
+			"status":     true,
+			"msg":        "success",
+			"conversion": status,
+		})
+	}
+
 	res, err := m.ConvertWhiteboardFile()
 	if err != nil {
 		return c.JSON(fiber.Map{
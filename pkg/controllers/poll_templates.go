@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mynaparrot/plugnmeet-protocol/utils"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"github.com/mynaparrot/plugnmeet-server/pkg/models"
+)
+
+// templates of the room will be under the api key which has created the room
+func roomPollTemplatesApiKey(c *fiber.Ctx) (string, string, bool) {
+	isAdmin := c.Locals("isAdmin")
+	roomId := c.Locals("roomId")
+	if isAdmin != true {
+		return "", "", false
+	}
+	return models.NewRoomService().LoadRoomOptions(roomId.(string)).ApiKey, roomId.(string), true
+}
+
+func HandleSavePollTemplate(c *fiber.Ctx) error {
+	apiKey, _ := c.Locals("apiKey").(string)
+	return savePollTemplate(c, apiKey, "")
+}
+
+func HandleListPollTemplates(c *fiber.Ctx) error {
+	apiKey, _ := c.Locals("apiKey").(string)
+	return listPollTemplates(c, apiKey)
+}
+
+func HandleDeletePollTemplate(c *fiber.Ctx) error {
+	apiKey, _ := c.Locals("apiKey").(string)
+	return deletePollTemplate(c, apiKey)
+}
+
+// HandleLaunchPollTemplate will create poll from the template in any active room
+func HandleLaunchPollTemplate(c *fiber.Ctx) error {
+	req := new(models.LaunchPollTemplateReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+	if req.RoomId == "" {
+		return utils.SendCommonResponse(c, false, "room_id required")
+	}
+
+	apiKey, _ := c.Locals("apiKey").(string)
+	return launchPollTemplate(c, apiKey, req.RoomId, "system", req.TemplateId)
+}
+
+func HandleSavePollTemplateForAPI(c *fiber.Ctx) error {
+	apiKey, roomId, ok := roomPollTemplatesApiKey(c)
+	if !ok {
+		return utils.SendCommonResponse(c, false, "only admin can perform this task")
+	}
+	return savePollTemplate(c, apiKey, roomId)
+}
+
+func HandleListPollTemplatesForAPI(c *fiber.Ctx) error {
+	apiKey, _, ok := roomPollTemplatesApiKey(c)
+	if !ok {
+		return utils.SendCommonResponse(c, false, "only admin can perform this task")
+	}
+	return listPollTemplates(c, apiKey)
+}
+
+func HandleDeletePollTemplateForAPI(c *fiber.Ctx) error {
+	apiKey, _, ok := roomPollTemplatesApiKey(c)
+	if !ok {
+		return utils.SendCommonResponse(c, false, "only admin can perform this task")
+	}
+	return deletePollTemplate(c, apiKey)
+}
+
+// HandleLaunchPollTemplateForAPI will create poll from the template in the room of the token
+func HandleLaunchPollTemplateForAPI(c *fiber.Ctx) error {
+	apiKey, roomId, ok := roomPollTemplatesApiKey(c)
+	if !ok {
+		return utils.SendCommonResponse(c, false, "only admin can perform this task")
+	}
+
+	req := new(models.LaunchPollTemplateReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	requestedUserId := c.Locals("requestedUserId")
+	return launchPollTemplate(c, apiKey, roomId, requestedUserId.(string), req.TemplateId)
+}
+
+func savePollTemplate(c *fiber.Ctx, apiKey, roomId string) error {
+	req := new(models.SavePollTemplateReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	t, err := models.NewPollTemplatesModel().SaveTemplate(apiKey, roomId, req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status":   true,
+		"msg":      "success",
+		"template": t,
+	})
+}
+
+func listPollTemplates(c *fiber.Ctx, apiKey string) error {
+	list, err := models.NewPollTemplatesModel().ListTemplates(apiKey)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status":    true,
+		"msg":       "success",
+		"templates": list,
+	})
+}
+
+func deletePollTemplate(c *fiber.Ctx, apiKey string) error {
+	req := new(models.DeletePollTemplateReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	err = models.NewPollTemplatesModel().DeleteTemplate(apiKey, req.TemplateId)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return utils.SendCommonResponse(c, true, "success")
+}
+
+func launchPollTemplate(c *fiber.Ctx, apiKey, roomId, userId, templateId string) error {
+	pollId, err := models.NewPollTemplatesModel().LaunchTemplate(apiKey, roomId, userId, templateId)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"msg":     "success",
+		"poll_id": pollId,
+	})
+}
@@ -3,6 +3,8 @@ package controllers
 import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-protocol/utils"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
 	"github.com/mynaparrot/plugnmeet-server/pkg/models"
 	"google.golang.org/protobuf/proto"
 	"strconv"
@@ -27,10 +29,14 @@ func HandleCreatePoll(c *fiber.Ctx) error {
 		return SendPollResponse(c, res)
 	}
 
+	// settings aren't part of CreatePollReq
+	settings := new(models.PollSettings)
+	_ = c.QueryParser(settings)
+
 	req.RoomId = roomId.(string)
 	req.UserId = requestedUserId.(string)
 	m := models.NewPollsModel()
-	err, pollId := m.CreatePoll(req, isAdmin.(bool))
+	err, pollId := m.CreatePoll(req, isAdmin.(bool), settings)
 	if err != nil {
 		res.Msg = err.Error()
 		return SendPollResponse(c, res)
@@ -141,6 +147,146 @@ func HandleUserSubmitResponse(c *fiber.Ctx) error {
 	return SendPollResponse(c, res)
 }
 
+// HandleSubmitPollBallot will accept ballot of multiple & ranked choice polls
+func HandleSubmitPollBallot(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+	isAdmin := c.Locals("isAdmin")
+	requestedUserId := c.Locals("requestedUserId")
+
+	req := new(models.SubmitPollBallotReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	req.RoomId = roomId.(string)
+	req.UserId = requestedUserId.(string)
+	m := models.NewPollsModel()
+	err = m.SubmitPollBallot(req, isAdmin.(bool))
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return utils.SendCommonResponse(c, true, "success")
+}
+
+// HandleSubmitPollText will accept response of text polls
+func HandleSubmitPollText(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+	isAdmin := c.Locals("isAdmin")
+	requestedUserId := c.Locals("requestedUserId")
+
+	req := new(models.SubmitPollTextReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	req.RoomId = roomId.(string)
+	req.UserId = requestedUserId.(string)
+	m := models.NewPollsModel()
+	err = m.SubmitPollText(req, isAdmin.(bool))
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return utils.SendCommonResponse(c, true, "success")
+}
+
+// HandleModeratePollText will approve or reject response of text polls
+func HandleModeratePollText(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+	isAdmin := c.Locals("isAdmin")
+	requestedUserId := c.Locals("requestedUserId")
+
+	if isAdmin != true {
+		return utils.SendCommonResponse(c, false, "only admin can perform this task")
+	}
+
+	req := new(models.ModeratePollTextReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	req.RoomId = roomId.(string)
+	req.UserId = requestedUserId.(string)
+	m := models.NewPollsModel()
+	resp, err := m.ModeratePollText(req, true)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status":   true,
+		"msg":      "success",
+		"response": resp,
+	})
+}
+
+// HandleListPollTextResponses will return moderation queue with ?status=pending
+func HandleListPollTextResponses(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+	isAdmin := c.Locals("isAdmin")
+	pollId := c.Params("pollId")
+
+	if isAdmin != true {
+		return utils.SendCommonResponse(c, false, "only admin can perform this task")
+	}
+
+	m := models.NewPollsModel()
+	responses, err := m.ListPollTextResponses(roomId.(string), pollId, c.Query("status"))
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status":    true,
+		"msg":       "success",
+		"responses": responses,
+	})
+}
+
+func HandleGetPollWordCloud(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+	isAdmin := c.Locals("isAdmin")
+	pollId := c.Params("pollId")
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	m := models.NewPollsModel()
+	terms, err := m.GetPollWordCloud(roomId.(string), pollId, limit, isAdmin.(bool))
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"terms":  terms,
+	})
+}
+
 func HandleClosePoll(c *fiber.Ctx) error {
 	roomId := c.Locals("roomId")
 	isAdmin := c.Locals("isAdmin")
@@ -227,6 +373,81 @@ func HandleGetResponsesResult(c *fiber.Ctx) error {
 	return SendPollResponse(c, res)
 }
 
+func HandleGetPollSettings(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+	pollId := c.Params("pollId")
+
+	m := models.NewPollsModel()
+	return c.JSON(fiber.Map{
+		"status":   true,
+		"msg":      "success",
+		"settings": m.GetPollSettings(roomId.(string), pollId),
+	})
+}
+
+// HandleGetPollResults will return results of any poll type with points of ranked choice polls
+func HandleGetPollResults(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+	isAdmin := c.Locals("isAdmin")
+	pollId := c.Params("pollId")
+
+	m := models.NewPollsModel()
+	result, err := m.GetPollResults(roomId.(string), pollId, isAdmin.(bool))
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"result": result,
+	})
+}
+
+// HandleExportPolls will return results of all polls of the session
+func HandleExportPolls(c *fiber.Ctx) error {
+	return exportPolls(c)
+}
+
+// HandleExportPollsForAPI will let moderators export polls of the room
+func HandleExportPollsForAPI(c *fiber.Ctx) error {
+	isAdmin := c.Locals("isAdmin")
+	roomId := c.Locals("roomId")
+
+	if isAdmin != true {
+		return utils.SendCommonResponse(c, false, "only admin can perform this task")
+	}
+	if c.Query("room_id") != roomId {
+		return utils.SendCommonResponse(c, false, "roomId in token mismatched")
+	}
+
+	return exportPolls(c)
+}
+
+func exportPolls(c *fiber.Ctx) error {
+	req := new(models.ExportPollsReq)
+	err := c.QueryParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	m := models.NewPollsModel()
+	data, fileName, err := m.ExportPolls(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	c.Attachment(fileName)
+	return c.Send(data)
+}
+
 func HandleGetPollsStats(c *fiber.Ctx) error {
 	roomId := c.Locals("roomId")
 	res := new(plugnmeet.PollResponse)
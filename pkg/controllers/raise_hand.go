@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mynaparrot/plugnmeet-server/pkg/models"
+)
+
+func HandleRaiseHand(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+	requestedUserId := c.Locals("requestedUserId")
+
+	m := models.NewRaiseHandModel()
+	err := m.RaiseHand(roomId.(string), requestedUserId.(string))
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+	})
+}
+
+// HandleLowerHand will lower hand of requested user.
+// Admin can lower hand of other user by sending user_id
+func HandleLowerHand(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+	requestedUserId := c.Locals("requestedUserId")
+	isAdmin := c.Locals("isAdmin")
+
+	req := new(models.RaiseHandReq)
+	if len(c.Body()) > 0 {
+		err := c.BodyParser(req)
+		if err != nil {
+			return c.JSON(fiber.Map{
+				"status": false,
+				"msg":    err.Error(),
+			})
+		}
+	}
+
+	userId := requestedUserId.(string)
+	if req.UserId != "" && req.UserId != userId {
+		if !isAdmin.(bool) {
+			return c.JSON(fiber.Map{
+				"status": false,
+				"msg":    "only admin can perform this task",
+			})
+		}
+		userId = req.UserId
+	}
+
+	m := models.NewRaiseHandModel()
+	err := m.LowerHand(roomId.(string), userId)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+	})
+}
+
+func HandleClearHands(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+	isAdmin := c.Locals("isAdmin")
+
+	if !isAdmin.(bool) {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    "only admin can perform this task",
+		})
+	}
+
+	m := models.NewRaiseHandModel()
+	err := m.ClearHands(roomId.(string))
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+	})
+}
+
+func HandleGetRaisedHands(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+
+	m := models.NewRaiseHandModel()
+	hands, err := m.GetQueue(roomId.(string))
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"hands":  hands,
+	})
+}
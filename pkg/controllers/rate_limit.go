@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mynaparrot/plugnmeet-server/pkg/models"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// HandleJoinRateLimit will limit token generation & verification requests
+// per ip & per room using redis sliding window
+func HandleJoinRateLimit(c *fiber.Ctx) error {
+	return handleJoinRateLimit(c, c.IP())
+}
+
+// HandleApiJoinRateLimit will limit requests per room only, because
+// backend of the integrator will request tokens of all the users from the same ip
+func HandleApiJoinRateLimit(c *fiber.Ctx) error {
+	return handleJoinRateLimit(c, "")
+}
+
+func handleJoinRateLimit(c *fiber.Ctx, ip string) error {
+	// for /api group roomId will be available from token
+	roomId, _ := c.Locals("roomId").(string)
+	if roomId == "" {
+		req := new(struct {
+			RoomId string `json:"room_id"`
+		})
+		_ = c.BodyParser(req)
+		roomId = req.RoomId
+	}
+
+	endpoint := c.Path()[strings.LastIndex(c.Path(), "/")+1:]
+	m := models.NewRateLimitModel()
+	allowed, retryAfter := m.Allow(endpoint, ip, roomId)
+	if !allowed {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+			"status": false,
+			"msg":    "too many requests, please try again later",
+		})
+	}
+
+	return c.Next()
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
 	"github.com/mynaparrot/plugnmeet-protocol/utils"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
 	"github.com/mynaparrot/plugnmeet-server/pkg/models"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/protobuf/proto"
@@ -48,16 +49,76 @@ func HandleRecording(c *fiber.Ctx) error {
 
 	if room.IsRecording == 1 && req.Task == plugnmeet.RecordingTasks_START_RECORDING {
 		return utils.SendCommonResponse(c, false, "notifications.recording-already-running")
-	} else if room.IsRecording == 0 && req.Task == plugnmeet.RecordingTasks_STOP_RECORDING {
+	} else if room.IsRecording == 0 && (req.Task == plugnmeet.RecordingTasks_STOP_RECORDING ||
+		req.Task == models.RecordingTasksPauseRecording || req.Task == models.RecordingTasksResumeRecording) {
 		return utils.SendCommonResponse(c, false, "notifications.recording-not-running")
 	}
 
+	individual := m.IsIndividualRecordingMode(room.RoomId)
+	if req.Task == models.RecordingTasksPauseRecording || req.Task == models.RecordingTasksResumeRecording {
+		if individual {
+			return utils.SendCommonResponse(c, false, "notifications.recording-pause-not-supported")
+		}
+		paused := m.IsRecordingPaused(room.Sid)
+		if paused && req.Task == models.RecordingTasksPauseRecording {
+			return utils.SendCommonResponse(c, false, "notifications.recording-already-paused")
+		} else if !paused && req.Task == models.RecordingTasksResumeRecording {
+			return utils.SendCommonResponse(c, false, "notifications.recording-not-paused")
+		}
+	}
+
 	if room.IsActiveRTMP == 1 && req.Task == plugnmeet.RecordingTasks_START_RTMP {
 		return utils.SendCommonResponse(c, false, "notifications.rtmp-already-running")
 	} else if room.IsActiveRTMP == 0 && req.Task == plugnmeet.RecordingTasks_STOP_RTMP {
 		return utils.SendCommonResponse(c, false, "notifications.rtmp-not-running")
 	}
 
+	if req.Task == plugnmeet.RecordingTasks_START_RECORDING {
+		if err = m.CheckRecordingQuota(room.RoomId, room.Sid); err != nil {
+			return utils.SendCommonResponse(c, false, err.Error())
+		}
+	}
+
+	if req.Task == plugnmeet.RecordingTasks_START_RECORDING && !individual {
+		// output options aren't part of RecordingReq
+		output := new(models.RecordingOutputOptions)
+		if err = c.QueryParser(output); err != nil {
+			return utils.SendCommonResponse(c, false, err.Error())
+		}
+		if check := config.AppCnf.DoValidateReq(output); len(check) > 0 {
+			return utils.SendCommonResponse(c, false, "invalid recording output: "+check[0].FailedField)
+		}
+		m.Output, err = models.ResolveRecordingOutput(output)
+		if err != nil {
+			return utils.SendCommonResponse(c, false, err.Error())
+		}
+		// the recorder will render the layout from room metadata
+		if output.Layout != "" {
+			if err = m.SetCompositeLayout(room.RoomId, output.Layout); err != nil {
+				return utils.SendCommonResponse(c, false, err.Error())
+			}
+		}
+	}
+
+	// participants should acknowledge before the recording starts
+	if req.Task == plugnmeet.RecordingTasks_START_RECORDING && m.IsRecordingConsentRequired(room.RoomId) {
+		m.RecordingReq = req
+		err = m.RequestRecordingConsent(room.RoomId, room.Sid, individual)
+		if err != nil {
+			return utils.SendCommonResponse(c, false, err.Error())
+		}
+		return utils.SendCommonResponse(c, true, "notifications.recording-consent-requested")
+	}
+
+	// individual track recording doesn't need the recorder
+	if individual && req.Task == plugnmeet.RecordingTasks_START_RECORDING {
+		m.StartIndividualRecording(room.RoomId, room.Sid)
+		return utils.SendCommonResponse(c, true, "success")
+	} else if individual && req.Task == plugnmeet.RecordingTasks_STOP_RECORDING {
+		m.StopIndividualRecording(room.RoomId, room.Sid)
+		return utils.SendCommonResponse(c, true, "success")
+	}
+
 	// we need to get custom design value
 	m.RecordingReq = req
 	err = m.SendMsgToRecorder(req.Task, room.RoomId, room.Sid, nil)
@@ -120,6 +181,53 @@ func HandleRTMP(c *fiber.Ctx) error {
 		return utils.SendCommonResponse(c, false, "RTMP broadcasting not running")
 	}
 
+	// broadcasting to destinations isn't handled by the recorder
+	if req.Task == plugnmeet.RecordingTasks_STOP_RTMP && m.HasRtmpDestinations(room.Sid) {
+		err = m.StopRtmpDestination(&models.StopRtmpDestinationReq{
+			RoomId: room.RoomId,
+		})
+		if err != nil {
+			return utils.SendCommonResponse(c, false, err.Error())
+		}
+		return utils.SendCommonResponse(c, true, "success")
+	}
+
+	// recorder isn't broadcasting while reconnecting
+	if req.Task == plugnmeet.RecordingTasks_STOP_RTMP && m.CancelBroadcastReconnect(room.Sid) {
+		return utils.SendCommonResponse(c, true, "success")
+	}
+
+	if req.Task == plugnmeet.RecordingTasks_START_RTMP {
+		// layout isn't part of RecordingReq
+		output := &models.RecordingOutputOptions{
+			Layout: c.Query("layout", config.AppCnf.RecorderInfo.Output.Layout),
+		}
+		if check := config.AppCnf.DoValidateReq(output); len(check) > 0 {
+			return utils.SendCommonResponse(c, false, "invalid layout")
+		}
+		if c.Query("layout") != "" {
+			if err = m.SetCompositeLayout(room.RoomId, output.Layout); err != nil {
+				return utils.SendCommonResponse(c, false, err.Error())
+			}
+		}
+		m.Output = output
+
+		encoding := new(models.BroadcastEncoding)
+		if err = c.QueryParser(encoding); err != nil {
+			return utils.SendCommonResponse(c, false, err.Error())
+		}
+		if check := config.AppCnf.DoValidateReq(encoding); len(check) > 0 {
+			return c.JSON(fiber.Map{
+				"status": false,
+				"msg":    check,
+			})
+		}
+		m.Encoding, err = models.ResolveBroadcastEncoding(encoding)
+		if err != nil {
+			return utils.SendCommonResponse(c, false, err.Error())
+		}
+	}
+
 	// we need to get custom design value
 	m.RecordingReq = req
 	err = m.SendMsgToRecorder(req.Task, room.RoomId, room.Sid, req.RtmpUrl)
@@ -149,3 +257,458 @@ func HandleRecorderEvents(c *fiber.Ctx) error {
 
 	return c.SendStatus(fiber.StatusOK)
 }
+
+func HandleRecorderHeartbeat(c *fiber.Ctx) error {
+	req := new(models.RecorderHeartbeatReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	m := models.NewRecordingModel()
+	err = m.RecorderHeartbeat(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+	})
+}
+
+func HandleGetRecorderNodes(c *fiber.Ctx) error {
+	m := models.NewRecordingModel()
+	nodes, err := m.GetRecorderNodes()
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":    true,
+		"msg":       "success",
+		"recorders": nodes,
+	})
+}
+
+func HandleGetFailedRecordingUploads(c *fiber.Ctx) error {
+	m := models.NewRecordingModel()
+	jobs, err := m.GetFailedRecordingUploads()
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"msg":     "success",
+		"uploads": jobs,
+	})
+}
+
+func HandleRetryFailedRecordingUpload(c *fiber.Ctx) error {
+	req := new(models.RetryRecordingUploadReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	m := models.NewRecordingModel()
+	err = m.RetryFailedRecordingUpload(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+	})
+}
+
+func HandleAddRecordingMarker(c *fiber.Ctx) error {
+	isAdmin := c.Locals("isAdmin")
+	roomId := c.Locals("roomId")
+	requestedUserId := c.Locals("requestedUserId")
+
+	if isAdmin != true {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    "only admin can perform this task",
+		})
+	}
+
+	req := new(models.AddRecordingMarkerReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+	req.RoomId = roomId.(string)
+	req.UserId = requestedUserId.(string)
+
+	m := models.NewRecordingModel()
+	marker, err := m.AddRecordingMarker(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"marker": marker,
+	})
+}
+
+func HandleGetRecordingMarkers(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+
+	m := models.NewRecordingModel()
+	markers, err := m.GetRecordingMarkers(roomId.(string))
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"msg":     "success",
+		"markers": markers,
+	})
+}
+
+func HandleSubmitRecordingConsent(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+	requestedUserId := c.Locals("requestedUserId")
+
+	req := new(models.SubmitRecordingConsentReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	req.RoomId = roomId.(string)
+	req.UserId = requestedUserId.(string)
+
+	m := models.NewRecordingModel()
+	err = m.SubmitRecordingConsent(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+	})
+}
+
+func HandleStartRtmpDestinations(c *fiber.Ctx) error {
+	isAdmin := c.Locals("isAdmin")
+	roomId := c.Locals("roomId")
+
+	if isAdmin != true {
+		return utils.SendCommonResponse(c, false, "only admin can start rtmp")
+	}
+
+	req := new(models.StartRtmpDestinationsReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+	req.RoomId = roomId.(string)
+
+	m := models.NewRecordingModel()
+	destinations, err := m.StartRtmpDestinations(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":       true,
+		"msg":          "success",
+		"destinations": destinations,
+	})
+}
+
+func HandleStopRtmpDestination(c *fiber.Ctx) error {
+	isAdmin := c.Locals("isAdmin")
+	roomId := c.Locals("roomId")
+
+	if isAdmin != true {
+		return utils.SendCommonResponse(c, false, "only admin can stop rtmp")
+	}
+
+	req := new(models.StopRtmpDestinationReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	req.RoomId = roomId.(string)
+
+	m := models.NewRecordingModel()
+	err = m.StopRtmpDestination(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+	})
+}
+
+func HandleGetRtmpDestinations(c *fiber.Ctx) error {
+	isAdmin := c.Locals("isAdmin")
+	roomId := c.Locals("roomId")
+
+	if isAdmin != true {
+		return utils.SendCommonResponse(c, false, "only admin can check rtmp status")
+	}
+
+	m := models.NewRecordingModel()
+	destinations, err := m.GetRtmpDestinations(roomId.(string))
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":       true,
+		"msg":          "success",
+		"destinations": destinations,
+	})
+}
+
+// HandleRecorderBroadcastStats will be called by the recorder periodically while broadcasting
+func HandleRecorderBroadcastStats(c *fiber.Ctx) error {
+	req := new(models.BroadcastStatsReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	m := models.NewRecordingModel()
+	health, err := m.ReportBroadcastStats(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"health": health,
+	})
+}
+
+func HandleGetBroadcastHealth(c *fiber.Ctx) error {
+	req := new(models.BroadcastHealthReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	return getBroadcastHealth(c, req.RoomId)
+}
+
+func HandleGetBroadcastHealthForAPI(c *fiber.Ctx) error {
+	isAdmin := c.Locals("isAdmin")
+	roomId := c.Locals("roomId")
+
+	if isAdmin != true {
+		return utils.SendCommonResponse(c, false, "only admin can check rtmp status")
+	}
+
+	return getBroadcastHealth(c, roomId.(string))
+}
+
+func getBroadcastHealth(c *fiber.Ctx, roomId string) error {
+	m := models.NewRecordingModel()
+	health, err := m.GetBroadcastHealth(roomId)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"health": health,
+	})
+}
+
+// HandleSetCompositeLayout will change layout of the running recording & broadcasting
+func HandleSetCompositeLayout(c *fiber.Ctx) error {
+	isAdmin := c.Locals("isAdmin")
+	roomId := c.Locals("roomId")
+
+	if isAdmin != true {
+		return utils.SendCommonResponse(c, false, "only admin can change layout")
+	}
+
+	req := new(models.SetCompositeLayoutReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	m := models.NewRecordingModel()
+	err = m.SetCompositeLayout(roomId.(string), req.Layout)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return utils.SendCommonResponse(c, true, "success")
+}
+
+func HandlePauseBroadcast(c *fiber.Ctx) error {
+	isAdmin := c.Locals("isAdmin")
+	roomId := c.Locals("roomId")
+
+	if isAdmin != true {
+		return utils.SendCommonResponse(c, false, "only admin can pause broadcast")
+	}
+
+	req := new(models.PauseBroadcastReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	m := models.NewRecordingModel()
+	slate, err := m.PauseBroadcast(roomId.(string), req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"slate":  slate,
+	})
+}
+
+func HandleResumeBroadcast(c *fiber.Ctx) error {
+	isAdmin := c.Locals("isAdmin")
+	roomId := c.Locals("roomId")
+
+	if isAdmin != true {
+		return utils.SendCommonResponse(c, false, "only admin can resume broadcast")
+	}
+
+	m := models.NewRecordingModel()
+	err := m.ResumeBroadcast(roomId.(string))
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return utils.SendCommonResponse(c, true, "success")
+}
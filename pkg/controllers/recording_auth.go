@@ -3,6 +3,7 @@ package controllers
 import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
 	"github.com/mynaparrot/plugnmeet-server/pkg/models"
 )
 
@@ -22,9 +23,25 @@ func HandleFetchRecordings(c *fiber.Ctx) error {
 			"msg":    err.Error(),
 		})
 	}
+	// filters aren't part of FetchRecordingsReq
+	filters := new(models.RecordingFilters)
+	err = c.BodyParser(filters)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	check := config.AppCnf.DoValidateReq(filters)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
 
 	m := models.NewRecordingAuth()
-	result, err := m.FetchRecordings(req)
+	result, err := m.FilterRecordings(req, filters)
 
 	if err != nil {
 		return c.JSON(fiber.Map{
@@ -78,6 +95,38 @@ func HandleDeleteRecording(c *fiber.Ctx) error {
 	})
 }
 
+func HandleRestoreRecording(c *fiber.Ctx) error {
+	req := new(models.RestoreRecordingReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	m := models.NewRecordingAuth()
+	err = m.RestoreRecording(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+	})
+}
+
 func HandleGetDownloadToken(c *fiber.Ctx) error {
 	req := new(plugnmeet.GetDownloadTokenReq)
 	err := c.BodyParser(req)
@@ -112,6 +161,39 @@ func HandleGetDownloadToken(c *fiber.Ctx) error {
 	})
 }
 
+func HandleGetRecordingInfo(c *fiber.Ctx) error {
+	req := new(models.GetRecordingInfoReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	m := models.NewRecordingAuth()
+	result, err := m.GetRecordingInfo(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"result": result,
+	})
+}
+
 func HandleDownloadRecording(c *fiber.Ctx) error {
 	token := c.Params("token")
 
@@ -123,7 +205,7 @@ func HandleDownloadRecording(c *fiber.Ctx) error {
 	}
 
 	m := models.NewRecordingAuth()
-	file, err := m.VerifyRecordingToken(token)
+	file, remote, err := m.VerifyRecordingToken(token)
 
 	if err != nil {
 		return c.JSON(fiber.Map{
@@ -132,6 +214,156 @@ func HandleDownloadRecording(c *fiber.Ctx) error {
 		})
 	}
 
+	if remote {
+		return c.Redirect(file)
+	}
+
 	c.Attachment(file)
 	return c.SendFile(file, true)
 }
+
+func HandleGetSignedUrl(c *fiber.Ctx) error {
+	req := new(models.GetSignedUrlReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	m := models.NewRecordingAuth()
+	res, err := m.GetSignedUrl(req, c.BaseURL())
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":     true,
+		"msg":        "success",
+		"url":        res.Url,
+		"expires_at": res.ExpiresAt,
+	})
+}
+
+func HandleSignedDownloadRecording(c *fiber.Ctx) error {
+	m := models.NewRecordingAuth()
+	file, remote, err := m.VerifySignedUrl(c.Params("recordId"), c.Query("expires"), c.Query("sig"))
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	if remote {
+		return c.Redirect(file)
+	}
+
+	if c.Query("playback") == "1" {
+		// without compression, so that player can request ranges
+		return c.SendFile(file, false)
+	}
+	c.Attachment(file)
+	return c.SendFile(file, true)
+}
+
+func HandleDownloadTranscript(c *fiber.Ctx) error {
+	token := c.Params("token")
+
+	if len(token) == 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    "token require or invalid url",
+		})
+	}
+
+	m := models.NewRecordingAuth()
+	res, err := m.GetTranscriptByToken(token)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	c.Attachment(res.FileName)
+	c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+	return c.SendString(res.Transcript)
+}
+
+func HandleGetRecordingTracks(c *fiber.Ctx) error {
+	req := new(models.GetRecordingTracksReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	m := models.NewRecordingAuth()
+	tracks, err := m.GetRecordingTracks(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"tracks": tracks,
+	})
+}
+
+func HandleGetRecordingUsage(c *fiber.Ctx) error {
+	req := new(models.GetRecordingUsageReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	m := models.NewRecordingModel()
+	usage, err := m.GetRecordingUsage(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"usage":  usage,
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
 	"github.com/mynaparrot/plugnmeet-protocol/utils"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
 	"github.com/mynaparrot/plugnmeet-server/pkg/models"
 	"google.golang.org/protobuf/proto"
 )
@@ -39,8 +40,76 @@ func HandleRoomCreate(c *fiber.Ctx) error {
 		})
 	}
 
+	// extra options aren't part of CreateRoomReq
+	// so, we'll parse same body again
+	opts := new(models.RoomOptions)
+	err = c.BodyParser(opts)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	// logout_url, feedback_url, ip lists, broadcast branding & chat options can be sent with metadata too
+	extraMeta := new(struct {
+		Metadata struct {
+			models.RoomExitUrls
+			models.RoomIpAccess
+			BroadcastBranding *models.BroadcastBranding `json:"broadcast_branding"`
+			PersistChat       bool                      `json:"persist_chat"`
+			PrivateChatPolicy string                    `json:"private_chat_policy"`
+			ChatHistoryCount  int                       `json:"chat_history_count"`
+		} `json:"metadata"`
+	})
+	_ = c.BodyParser(extraMeta)
+	if extraMeta.Metadata.LogoutUrl != "" {
+		opts.LogoutUrl = extraMeta.Metadata.LogoutUrl
+	}
+	if extraMeta.Metadata.FeedbackUrl != "" {
+		opts.FeedbackUrl = extraMeta.Metadata.FeedbackUrl
+	}
+	if len(extraMeta.Metadata.IpAllowlist) > 0 {
+		opts.IpAllowlist = extraMeta.Metadata.IpAllowlist
+	}
+	if len(extraMeta.Metadata.IpDenylist) > 0 {
+		opts.IpDenylist = extraMeta.Metadata.IpDenylist
+	}
+	if extraMeta.Metadata.BroadcastBranding != nil {
+		opts.BroadcastBranding = extraMeta.Metadata.BroadcastBranding
+	}
+	if extraMeta.Metadata.PersistChat {
+		opts.PersistChat = true
+	}
+	if extraMeta.Metadata.PrivateChatPolicy != "" {
+		opts.PrivateChatPolicy = extraMeta.Metadata.PrivateChatPolicy
+	}
+	if extraMeta.Metadata.ChatHistoryCount > 0 {
+		opts.ChatHistoryCount = extraMeta.Metadata.ChatHistoryCount
+	}
+	if err = opts.RoomIpAccess.Validate(); err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	if err = opts.RoomExitUrls.Validate(); err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	// never trust api_key from body
+	opts.ApiKey, _ = c.Locals("apiKey").(string)
+	check := config.AppCnf.DoValidateReq(opts)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
 	m := models.NewRoomAuthModel()
-	status, msg, room := m.CreateRoom(req)
+	status, msg, room := m.CreateRoom(req, opts)
 
 	return c.JSON(fiber.Map{
 		"status":    status,
@@ -127,7 +196,7 @@ func HandleEndRoom(c *fiber.Ctx) error {
 	}
 
 	m := models.NewRoomAuthModel()
-	status, msg := m.EndRoom(req)
+	status, msg := m.EndRoom(req, models.RoomEndReasonEndedByApi)
 
 	return c.JSON(fiber.Map{
 		"status": status,
@@ -135,6 +204,38 @@ func HandleEndRoom(c *fiber.Ctx) error {
 	})
 }
 
+func HandleEndAllRooms(c *fiber.Ctx) error {
+	req := new(models.EndAllRoomsReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	m := models.NewRoomAuthModel()
+	results, err := m.EndAllRooms(req, models.RoomEndReasonEndedByApi)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	if len(results) == 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    "no matching room found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"msg":     "success",
+		"results": results,
+	})
+}
+
 func HandleEndRoomForAPI(c *fiber.Ctx) error {
 	isAdmin := c.Locals("isAdmin")
 	roomId := c.Locals("roomId")
@@ -154,7 +255,7 @@ func HandleEndRoomForAPI(c *fiber.Ctx) error {
 	}
 
 	m := models.NewRoomAuthModel()
-	status, msg := m.EndRoom(req)
+	status, msg := m.EndRoom(req, models.RoomEndReasonEndedByModerator)
 	return utils.SendCommonResponse(c, status, msg)
 }
 
@@ -180,3 +281,93 @@ func HandleChangeVisibilityForAPI(c *fiber.Ctx) error {
 	status, msg := m.ChangeVisibility(req)
 	return utils.SendCommonResponse(c, status, msg)
 }
+
+func HandleGetRoomTimeline(c *fiber.Ctx) error {
+	req := new(plugnmeet.IsRoomActiveReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	if req.RoomId == "" {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    "room_id required",
+		})
+	}
+
+	rs := models.NewRoomService()
+	events, err := rs.GetRoomTimeline(req.RoomId)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"events": events,
+	})
+}
+
+func HandleGetRoomParticipants(c *fiber.Ctx) error {
+	roomId := c.Params("roomId")
+	if roomId == "" {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    "room_id required",
+		})
+	}
+
+	rs := models.NewRoomService()
+	presence, err := rs.GetRoomPresence(roomId)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":       true,
+		"msg":          "success",
+		"participants": presence,
+	})
+}
+
+func HandleMergeRooms(c *fiber.Ctx) error {
+	req := new(models.MergeRoomsReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	m := models.NewRoomMergeModel()
+	err = m.MergeRooms(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+	})
+}
@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"github.com/mynaparrot/plugnmeet-server/pkg/models"
+)
+
+func HandleCreateIngress(c *fiber.Ctx) error {
+	req := new(models.CreateIngressReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return createIngress(c, req)
+}
+
+func HandleGetRoomIngress(c *fiber.Ctx) error {
+	req := new(models.GetRoomIngressReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return getRoomIngress(c, req)
+}
+
+func HandleDeleteIngress(c *fiber.Ctx) error {
+	req := new(models.IngressReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return deleteIngress(c, req)
+}
+
+func HandleCreateIngressForAPI(c *fiber.Ctx) error {
+	if c.Locals("isAdmin") != true {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    "only admin can perform this task",
+		})
+	}
+
+	req := new(models.CreateIngressReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	req.RoomId = c.Locals("roomId").(string)
+
+	return createIngress(c, req)
+}
+
+func HandleGetRoomIngressForAPI(c *fiber.Ctx) error {
+	if c.Locals("isAdmin") != true {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    "only admin can perform this task",
+		})
+	}
+
+	return getRoomIngress(c, &models.GetRoomIngressReq{
+		RoomId: c.Locals("roomId").(string),
+	})
+}
+
+func HandleDeleteIngressForAPI(c *fiber.Ctx) error {
+	if c.Locals("isAdmin") != true {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    "only admin can perform this task",
+		})
+	}
+
+	req := new(models.IngressReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	req.RoomId = c.Locals("roomId").(string)
+
+	return deleteIngress(c, req)
+}
+
+func createIngress(c *fiber.Ctx, req *models.CreateIngressReq) error {
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	m := models.NewRoomIngressModel()
+	ingress, err := m.CreateIngress(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"msg":     "success",
+		"ingress": ingress,
+	})
+}
+
+func getRoomIngress(c *fiber.Ctx, req *models.GetRoomIngressReq) error {
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	m := models.NewRoomIngressModel()
+	list, err := m.GetRoomIngress(req.RoomId)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"msg":     "success",
+		"ingress": list,
+	})
+}
+
+func deleteIngress(c *fiber.Ctx, req *models.IngressReq) error {
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	m := models.NewRoomIngressModel()
+	err := m.DeleteIngress(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+	})
+}
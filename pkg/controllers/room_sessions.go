@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"github.com/mynaparrot/plugnmeet-server/pkg/models"
+)
+
+func HandleFetchSessions(c *fiber.Ctx) error {
+	req := new(models.FetchSessionsReq)
+	err := c.QueryParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	m := models.NewRoomSessionsModel()
+	sessions, total, err := m.FetchSessions(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	if total == 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    "no sessions found",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":   true,
+		"msg":      "success",
+		"total":    total,
+		"from":     req.Offset,
+		"limit":    req.Limit,
+		"sessions": sessions,
+	})
+}
+
+func HandleDeleteSessionArtifact(c *fiber.Ctx) error {
+	req := new(models.DeleteSessionArtifactReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	m := models.NewRoomSessionsModel()
+	res, err := m.DeleteSessionArtifact(req, c.IP())
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"msg":     "success",
+		"room_id": res.RoomId,
+		"deleted": res.Deleted,
+	})
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mynaparrot/plugnmeet-protocol/utils"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"github.com/mynaparrot/plugnmeet-server/pkg/models"
+)
+
+func HandleGetScreenAnnotation(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+
+	rs := models.NewRoomService()
+	s, err := rs.GetScreenAnnotationSession(roomId.(string))
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status":     true,
+		"msg":        "success",
+		"annotation": s,
+	})
+}
+
+// HandleStartScreenAnnotation can be used by moderators or the user who is sharing the screen
+func HandleStartScreenAnnotation(c *fiber.Ctx) error {
+	isAdmin := c.Locals("isAdmin")
+	roomId := c.Locals("roomId")
+	requestedUserId := c.Locals("requestedUserId")
+
+	req := new(models.StartScreenAnnotationReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+	req.RoomId = roomId.(string)
+	req.RequestedUserId = requestedUserId.(string)
+	req.IsAdmin = isAdmin == true
+
+	rs := models.NewRoomService()
+	s, err := rs.StartScreenAnnotation(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status":     true,
+		"msg":        "success",
+		"annotation": s,
+	})
+}
+
+func HandleStopScreenAnnotation(c *fiber.Ctx) error {
+	rs := models.NewRoomService()
+	err := rs.StopScreenAnnotation(screenAnnotationReq(c))
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return utils.SendCommonResponse(c, true, "success")
+}
+
+func HandleClearScreenAnnotations(c *fiber.Ctx) error {
+	rs := models.NewRoomService()
+	err := rs.ClearScreenAnnotations(screenAnnotationReq(c))
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return utils.SendCommonResponse(c, true, "success")
+}
+
+func screenAnnotationReq(c *fiber.Ctx) *models.ScreenAnnotationReq {
+	isAdmin := c.Locals("isAdmin")
+	roomId := c.Locals("roomId")
+	requestedUserId := c.Locals("requestedUserId")
+
+	return &models.ScreenAnnotationReq{
+		RoomId:          roomId.(string),
+		RequestedUserId: requestedUserId.(string),
+		IsAdmin:         isAdmin == true,
+	}
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mynaparrot/plugnmeet-server/pkg/models"
+)
+
+func HandleEnableSpeakerQueue(c *fiber.Ctx) error {
+	return handleSpeakerQueueTask(c, true, func(req *models.SpeakerQueueReq) error {
+		return models.NewSpeakerQueueModel().Enable(req)
+	})
+}
+
+func HandleDisableSpeakerQueue(c *fiber.Ctx) error {
+	return handleSpeakerQueueTask(c, true, func(req *models.SpeakerQueueReq) error {
+		return models.NewSpeakerQueueModel().Disable(req)
+	})
+}
+
+func HandleRequestFloor(c *fiber.Ctx) error {
+	return handleSpeakerQueueTask(c, false, func(req *models.SpeakerQueueReq) error {
+		return models.NewSpeakerQueueModel().RequestFloor(req)
+	})
+}
+
+func HandleWithdrawFloor(c *fiber.Ctx) error {
+	return handleSpeakerQueueTask(c, false, func(req *models.SpeakerQueueReq) error {
+		return models.NewSpeakerQueueModel().Withdraw(req)
+	})
+}
+
+func HandleNextSpeaker(c *fiber.Ctx) error {
+	return handleSpeakerQueueTask(c, true, func(req *models.SpeakerQueueReq) error {
+		return models.NewSpeakerQueueModel().Next(req)
+	})
+}
+
+func HandleGetSpeakerQueue(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+
+	m := models.NewSpeakerQueueModel()
+	q, err := m.GetQueue(roomId.(string))
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"queue":  q,
+	})
+}
+
+func handleSpeakerQueueTask(c *fiber.Ctx, adminOnly bool, task func(req *models.SpeakerQueueReq) error) error {
+	roomId := c.Locals("roomId")
+	requestedUserId := c.Locals("requestedUserId")
+	isAdmin := c.Locals("isAdmin")
+
+	if adminOnly && !isAdmin.(bool) {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    "only admin can perform this task",
+		})
+	}
+
+	req := new(models.SpeakerQueueReq)
+	if len(c.Body()) > 0 {
+		err := c.BodyParser(req)
+		if err != nil {
+			return c.JSON(fiber.Map{
+				"status": false,
+				"msg":    err.Error(),
+			})
+		}
+	}
+
+	req.RoomId = roomId.(string)
+	req.RequestedUserId = requestedUserId.(string)
+	req.IsAdmin = isAdmin.(bool)
+
+	err := task(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+	})
+}
@@ -0,0 +1,125 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"github.com/mynaparrot/plugnmeet-server/pkg/models"
+)
+
+func HandleAddStreamDestination(c *fiber.Ctx) error {
+	req := new(models.AddStreamDestinationReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	apiKey, _ := c.Locals("apiKey").(string)
+	d, err := models.NewStreamDestinationsModel().AddDestination(apiKey, req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":      true,
+		"msg":         "success",
+		"destination": d,
+	})
+}
+
+func HandleListStreamDestinations(c *fiber.Ctx) error {
+	apiKey, _ := c.Locals("apiKey").(string)
+	list, err := models.NewStreamDestinationsModel().ListDestinations(apiKey)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":       true,
+		"msg":          "success",
+		"destinations": list,
+	})
+}
+
+func HandleUpdateStreamDestination(c *fiber.Ctx) error {
+	req := new(models.UpdateStreamDestinationReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	apiKey, _ := c.Locals("apiKey").(string)
+	d, err := models.NewStreamDestinationsModel().UpdateDestination(apiKey, req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status":      true,
+		"msg":         "success",
+		"destination": d,
+	})
+}
+
+func HandleDeleteStreamDestination(c *fiber.Ctx) error {
+	req := new(models.DeleteStreamDestinationReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	apiKey, _ := c.Locals("apiKey").(string)
+	err = models.NewStreamDestinationsModel().DeleteDestination(apiKey, req.DestinationId)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
 	"github.com/mynaparrot/plugnmeet-protocol/utils"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
 	"github.com/mynaparrot/plugnmeet-server/pkg/models"
 	"google.golang.org/protobuf/proto"
 )
@@ -156,3 +157,97 @@ func HandleSwitchPresenter(c *fiber.Ctx) error {
 
 	return utils.SendCommonResponse(c, true, "success")
 }
+
+func HandleBanParticipant(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+	requestedUserId := c.Locals("requestedUserId")
+	isAdmin := c.Locals("isAdmin")
+
+	if !isAdmin.(bool) {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    "only admin can perform this task",
+		})
+	}
+
+	req := new(models.BanParticipantReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	if requestedUserId == req.UserId {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    "you can't ban yourself",
+		})
+	}
+
+	req.RoomId = roomId.(string)
+	m := models.NewUserModel()
+	ipBanned, err := m.BanParticipant(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	msg := "success"
+	if req.BanIp && !ipBanned {
+		msg = "user was banned but ip couldn't be banned"
+	}
+	return c.JSON(fiber.Map{
+		"status":    true,
+		"msg":       msg,
+		"ip_banned": ipBanned,
+	})
+}
+
+func HandleMuteAllMics(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+	requestedUserId := c.Locals("requestedUserId")
+	isAdmin := c.Locals("isAdmin")
+
+	if !isAdmin.(bool) {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    "only admin can perform this task",
+		})
+	}
+
+	req := new(models.MuteAllMicsReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	req.RoomId = roomId.(string)
+	req.RequestedUserId = requestedUserId.(string)
+	m := models.NewUserModel()
+	err = m.MuteAllMics(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+	})
+}
@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"github.com/mynaparrot/plugnmeet-server/pkg/models"
+)
+
+func HandleGetUserPreferences(c *fiber.Ctx) error {
+	req := new(models.UserPreferencesReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	apiKey, _ := c.Locals("apiKey").(string)
+	return getUserPreferences(c, apiKey, req.UserId)
+}
+
+func HandleSetUserPreferences(c *fiber.Ctx) error {
+	req := new(models.UserPreferencesReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	apiKey, _ := c.Locals("apiKey").(string)
+	return setUserPreferences(c, apiKey, req)
+}
+
+// HandleGetMyPreferences will return preferences of the requested user
+func HandleGetMyPreferences(c *fiber.Ctx) error {
+	requestedUserId := c.Locals("requestedUserId")
+	return getUserPreferences(c, roomApiKey(c), requestedUserId.(string))
+}
+
+// HandleSetMyPreferences will allow user to change own preferences only
+func HandleSetMyPreferences(c *fiber.Ctx) error {
+	requestedUserId := c.Locals("requestedUserId")
+
+	req := new(models.UserPreferencesReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	req.UserId = requestedUserId.(string)
+
+	return setUserPreferences(c, roomApiKey(c), req)
+}
+
+// roomApiKey will return the api key which created the room of the user
+func roomApiKey(c *fiber.Ctx) string {
+	roomId := c.Locals("roomId")
+	return models.NewRoomService().LoadRoomOptions(roomId.(string)).ApiKey
+}
+
+func getUserPreferences(c *fiber.Ctx, apiKey, userId string) error {
+	m := models.NewUserPreferencesModel()
+	p, err := m.GetPreferences(apiKey, userId)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+	if p == nil {
+		p = new(models.UserPreferences)
+	}
+
+	return c.JSON(fiber.Map{
+		"status":      true,
+		"msg":         "success",
+		"preferences": p,
+	})
+}
+
+func setUserPreferences(c *fiber.Ctx, apiKey string, req *models.UserPreferencesReq) error {
+	m := models.NewUserPreferencesModel()
+	err := m.SetPreferences(apiKey, req)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+	})
+}
@@ -13,6 +13,7 @@ import (
 type websocketController struct {
 	kws         *ikisocket.Websocket
 	token       string
+	session     string
 	participant config.ChatParticipant
 }
 
@@ -31,17 +32,21 @@ func newWebsocketController(kws *ikisocket.Websocket) *websocketController {
 		UUID:    kws.UUID,
 	}
 
+	session, _ := kws.Locals("session").(string)
+
 	return &websocketController{
 		kws:         kws,
 		participant: p,
 		token:       authToken,
+		session:     session,
 	}
 }
 
 func (c *websocketController) validation() bool {
 	m := models.NewAuthTokenModel()
 	info := &models.ValidateTokenReq{
-		Token: c.token,
+		Token:   c.token,
+		Session: c.session,
 	}
 
 	claims, err := m.DoValidateToken(info, false)
@@ -81,6 +86,8 @@ func (c *websocketController) addUser() {
 	config.AppCnf.AddChatUser(c.participant.RoomId, c.participant)
 	c.kws.SetAttribute("userId", c.participant.UserId)
 	c.kws.SetAttribute("roomId", c.participant.RoomId)
+	c.kws.SetAttribute("userSid", c.participant.UserSid)
+	c.kws.SetAttribute("session", c.session)
 }
 
 func HandleWebSocket() func(*fiber.Ctx) error {
@@ -90,6 +97,13 @@ func HandleWebSocket() func(*fiber.Ctx) error {
 
 		if isValid {
 			wc.addUser()
+			go func(roomId, userId string) {
+				models.SendChatHistory(roomId, userId)
+				models.SendChatPins(roomId, userId)
+				models.SendWhiteboardState(roomId, userId)
+				models.SendWhiteboardDrawPermissions(roomId, userId)
+				models.SendScreenAnnotations(roomId, userId)
+			}(wc.participant.RoomId, wc.participant.UserId)
 		} else {
 			kws.Close()
 		}
@@ -112,13 +126,44 @@ func SetupSocketListeners() {
 			DataMsg: dataMsg,
 			RoomId:  roomId,
 			IsAdmin: false,
+			Session: ep.Kws.GetStringAttribute("session"),
 		}
 		isAdmin := ep.Kws.GetAttribute("isAdmin")
 		if isAdmin != nil {
 			payload.IsAdmin = isAdmin.(bool)
 		}
 
+		userId := ep.Kws.GetStringAttribute("userId")
+		userSid := ep.Kws.GetStringAttribute("userSid")
+		if models.IsForgedSystemMsg(dataMsg) {
+			return
+		}
+		if !models.AllowChatMessage(roomId, userId, userSid, payload.IsAdmin, dataMsg) {
+			return
+		}
+		if !models.HandleReaction(roomId, userId, dataMsg) {
+			return
+		}
+		if !models.HandleChatMessageAction(roomId, userId, userSid, payload.IsAdmin, dataMsg) {
+			return
+		}
+		if !models.AllowWhiteboardMessage(roomId, userId, payload.IsAdmin, dataMsg) {
+			return
+		}
+		if !models.AllowScreenAnnotation(roomId, userId, payload.IsAdmin, dataMsg) {
+			return
+		}
+		if !models.AllowWhiteboardPointer(userId, payload) {
+			return
+		}
+
 		models.DistributeWebsocketMsgToRedisChannel(payload)
+		go models.CaptureRecordingChat(roomId, dataMsg)
+		go models.PersistChatMessage(roomId, dataMsg)
+		go models.TranslateChatMessage(roomId, dataMsg)
+		go models.UnfurlChatLink(roomId, dataMsg)
+		go models.PersistWhiteboardMessage(roomId, dataMsg)
+		go models.PersistScreenAnnotation(roomId, dataMsg)
 	})
 
 	// On disconnect event
@@ -127,6 +172,7 @@ func SetupSocketListeners() {
 		userId := ep.Kws.GetStringAttribute("userId")
 		// Remove the user from the local clients
 		config.AppCnf.RemoveChatParticipant(roomId, userId)
+		models.ForgetWhiteboardPointers(roomId, userId)
 	})
 
 	// This event is called when the server disconnects the user actively with .Close() method
@@ -135,6 +181,7 @@ func SetupSocketListeners() {
 		userId := ep.Kws.GetStringAttribute("userId")
 		// Remove the user from the local clients
 		config.AppCnf.RemoveChatParticipant(roomId, userId)
+		models.ForgetWhiteboardPointers(roomId, userId)
 	})
 
 	// On error event
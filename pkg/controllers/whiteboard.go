@@ -0,0 +1,316 @@
+package controllers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/mynaparrot/plugnmeet-protocol/utils"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"github.com/mynaparrot/plugnmeet-server/pkg/models"
+	"path/filepath"
+	"strconv"
+)
+
+func HandleGetWhiteboardState(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+
+	if !config.AppCnf.Client.WhiteboardState.Enabled {
+		return utils.SendCommonResponse(c, false, "whiteboard state isn't enabled")
+	}
+
+	rs := models.NewRoomService()
+	state, err := rs.GetWhiteboardState(roomId.(string))
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"state":  state,
+	})
+}
+
+func HandleGetWhiteboardPages(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+
+	rs := models.NewRoomService()
+	pages, err := rs.GetWhiteboardPages(roomId.(string))
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"pages":  pages,
+	})
+}
+
+// HandleManageWhiteboardPage will add, delete, move or switch the page for everyone
+func HandleManageWhiteboardPage(c *fiber.Ctx) error {
+	isAdmin := c.Locals("isAdmin")
+	roomId := c.Locals("roomId")
+
+	if isAdmin != true {
+		return utils.SendCommonResponse(c, false, "only admin can perform this task")
+	}
+
+	req := new(models.WhiteboardPageReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+	req.RoomId = roomId.(string)
+
+	rs := models.NewRoomService()
+	pages, err := rs.ManageWhiteboardPage(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"pages":  pages,
+	})
+}
+
+func HandleGetWhiteboardDrawPermissions(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+
+	rs := models.NewRoomService()
+	p, err := rs.GetWhiteboardDrawPermissions(roomId.(string))
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status":      true,
+		"msg":         "success",
+		"permissions": p,
+	})
+}
+
+// HandleSetWhiteboardDrawMode will lock the board to presenters or unlock for everyone
+func HandleSetWhiteboardDrawMode(c *fiber.Ctx) error {
+	isAdmin := c.Locals("isAdmin")
+	roomId := c.Locals("roomId")
+
+	if isAdmin != true {
+		return utils.SendCommonResponse(c, false, "only admin can perform this task")
+	}
+
+	req := new(models.WhiteboardDrawModeReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+	req.RoomId = roomId.(string)
+
+	rs := models.NewRoomService()
+	p, err := rs.SetWhiteboardDrawMode(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status":      true,
+		"msg":         "success",
+		"permissions": p,
+	})
+}
+
+// HandleSetWhiteboardDrawPermission will grant or revoke drawing of the user
+func HandleSetWhiteboardDrawPermission(c *fiber.Ctx) error {
+	isAdmin := c.Locals("isAdmin")
+	roomId := c.Locals("roomId")
+
+	if isAdmin != true {
+		return utils.SendCommonResponse(c, false, "only admin can perform this task")
+	}
+
+	req := new(models.WhiteboardDrawPermissionReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+	req.RoomId = roomId.(string)
+
+	rs := models.NewRoomService()
+	p, err := rs.SetWhiteboardDrawPermission(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status":      true,
+		"msg":         "success",
+		"permissions": p,
+	})
+}
+
+func HandleGetWhiteboardConversion(c *fiber.Ctx) error {
+	roomId := c.Locals("roomId")
+
+	rs := models.NewRoomService()
+	status, err := rs.GetWhiteboardConversionStatus(roomId.(string), c.Params("jobId"))
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status":     true,
+		"msg":        "success",
+		"conversion": status,
+	})
+}
+
+// HandleRestoreWhiteboard will replace the board with the board of a finished session
+func HandleRestoreWhiteboard(c *fiber.Ctx) error {
+	isAdmin := c.Locals("isAdmin")
+	roomId := c.Locals("roomId")
+
+	if isAdmin != true {
+		return utils.SendCommonResponse(c, false, "only admin can perform this task")
+	}
+
+	req := new(models.RestoreWhiteboardReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+	req.RoomId = roomId.(string)
+
+	rs := models.NewRoomService()
+	state, err := rs.RestoreWhiteboard(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"state":  state,
+	})
+}
+
+// HandleExportWhiteboardForAPI will export the board of the current session
+func HandleExportWhiteboardForAPI(c *fiber.Ctx) error {
+	isAdmin := c.Locals("isAdmin")
+	roomId := c.Locals("roomId")
+
+	if isAdmin != true {
+		return utils.SendCommonResponse(c, false, "only admin can perform this task")
+	}
+
+	req := new(models.ExportWhiteboardReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	req.RoomId = roomId.(string)
+	req.RoomSid = ""
+
+	return exportWhiteboard(c, req)
+}
+
+// HandleExportWhiteboard will export the board of active or finished session
+func HandleExportWhiteboard(c *fiber.Ctx) error {
+	req := new(models.ExportWhiteboardReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	if req.RoomSid == "" {
+		return utils.SendCommonResponse(c, false, "room_sid required")
+	}
+
+	return exportWhiteboard(c, req)
+}
+
+func exportWhiteboard(c *fiber.Ctx, req *models.ExportWhiteboardReq) error {
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	export, err := models.NewWhiteboardExportModel().ExportWhiteboard(req, c.BaseURL())
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status": true,
+		"msg":    "success",
+		"export": export,
+	})
+}
+
+func HandleListWhiteboardExports(c *fiber.Ctx) error {
+	req := new(models.ListWhiteboardExportsReq)
+	err := c.BodyParser(req)
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+	check := config.AppCnf.DoValidateReq(req)
+	if len(check) > 0 {
+		return c.JSON(fiber.Map{
+			"status": false,
+			"msg":    check,
+		})
+	}
+
+	list, err := models.NewWhiteboardExportModel().ListExports(req.RoomSid, c.BaseURL())
+	if err != nil {
+		return utils.SendCommonResponse(c, false, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"status":  true,
+		"msg":     "success",
+		"exports": list,
+	})
+}
+
+func HandleDownloadWhiteboardExport(c *fiber.Ctx) error {
+	file, err := models.VerifyWhiteboardExportUrl(c.Params("sid"), c.Params("exportId"), c.Params("file"), c.Query("expires"), c.Query("sig"))
+	if err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status": false,
+			"msg":    err.Error(),
+		})
+	}
+
+	c.Set("Content-Disposition", "attachment; filename="+strconv.Quote(filepath.Base(file)))
+	return c.SendFile(file)
+}
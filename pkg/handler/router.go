@@ -11,6 +11,7 @@ import (
 	"github.com/gofiber/websocket/v2"
 	"github.com/mynaparrot/plugnmeet-server/pkg/config"
 	"github.com/mynaparrot/plugnmeet-server/pkg/controllers"
+	"github.com/mynaparrot/plugnmeet-server/pkg/models"
 )
 
 func Router() *fiber.App {
@@ -48,6 +49,12 @@ func Router() *fiber.App {
 
 	app.Static("/assets", config.AppCnf.Client.Path+"/assets")
 	app.Static("/favicon.ico", config.AppCnf.Client.Path+"/assets/imgs/favicon.ico")
+	if config.AppCnf.RecorderInfo.Hls.Enabled && !config.AppCnf.RecorderInfo.Hls.UseS3 {
+		// playlist will be updated frequently, so it shouldn't be cached long
+		app.Static("/hls", config.AppCnf.RecorderInfo.RecordingFilesPath+"/hls", fiber.Static{
+			MaxAge: 1,
+		})
+	}
 
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.Render("index", nil)
@@ -57,7 +64,14 @@ func Router() *fiber.App {
 	})
 	app.Post("/webhook", controllers.HandleWebhook)
 	app.Get("/download/uploadedFile/:sid/*", controllers.HandleDownloadUploadedFile)
+	app.Get("/download/chatAttachment/:sid/:fileId", controllers.HandleDownloadChatAttachment)
+	app.Get("/download/whiteboardExport/:sid/:exportId/:file", controllers.HandleDownloadWhiteboardExport)
+	app.Get("/download/recording/signed/:recordId", controllers.HandleSignedDownloadRecording)
 	app.Get("/download/recording/:token", controllers.HandleDownloadRecording)
+	app.Get("/download/recording/:token/transcript", controllers.HandleDownloadTranscript)
+
+	// public keys to verify join tokens
+	app.Get("/.well-known/jwks.json", controllers.HandleGetJWKS)
 
 	// lti group
 	lti := app.Group("/lti")
@@ -71,45 +85,158 @@ func Router() *fiber.App {
 	ltiV1API.Post("/recording/download", controllers.HandleLTIV1GetRecordingDownloadToken)
 	ltiV1API.Post("/recording/delete", controllers.HandleLTIV1DeleteRecordings)
 
+	// ldap login, will return join token
+	ldap := app.Group("/ldap")
+	ldap.Post("/login", controllers.HandleJoinRateLimit, controllers.HandleLdapLogin)
+
 	// auth group, will require API-KEY & API-SECRET as header value
 	auth := app.Group("/auth", controllers.HandleAuthHeaderCheck)
 	auth.Post("/getClientFiles", controllers.HandleGetClientFiles)
+	auth.Post("/renewToken", controllers.HandleRenewTokenForAuth)
+	auth.Post("/revoke", controllers.HandleRevokeToken)
 
 	// for room
 	room := auth.Group("/room")
 	room.Post("/create", controllers.HandleRoomCreate)
-	room.Post("/getJoinToken", controllers.HandleGenerateJoinToken)
+	room.Post("/getJoinToken", controllers.HandleApiJoinRateLimit, controllers.HandleGenerateJoinToken)
+	room.Post("/getGuestJoinLink", controllers.HandleApiJoinRateLimit, controllers.HandleGenerateGuestJoinLink)
 	room.Post("/isRoomActive", controllers.HandleIsRoomActive)
 	room.Post("/getActiveRoomInfo", controllers.HandleGetActiveRoomInfo)
 	room.Post("/getActiveRoomsInfo", controllers.HandleGetActiveRoomsInfo)
 	room.Post("/endRoom", controllers.HandleEndRoom)
+	room.Post("/endAll", controllers.HandleEndAllRooms)
+	room.Post("/getTimeline", controllers.HandleGetRoomTimeline)
+	room.Post("/merge", controllers.HandleMergeRooms)
+	room.Post("/broadcast/status", controllers.HandleGetBroadcastHealth)
+	room.Post("/hls/start", controllers.HandleStartHls)
+	room.Post("/hls/stop", controllers.HandleStopHls)
+	room.Post("/hls/info", controllers.HandleGetHlsInfo)
+	room.Post("/ingress/create", controllers.HandleCreateIngress)
+	room.Post("/ingress/list", controllers.HandleGetRoomIngress)
+	room.Post("/ingress/delete", controllers.HandleDeleteIngress)
+	room.Get("/:roomId/participants", controllers.HandleGetRoomParticipants)
+	// archived sessions
+	auth.Get("/sessions", controllers.HandleFetchSessions)
+	auth.Post("/sessions/deleteArtifact", controllers.HandleDeleteSessionArtifact)
+	auth.Get("/chat/export", controllers.HandleExportChat)
+	auth.Get("/polls/export", controllers.HandleExportPolls)
+
+	// for user
+	user := auth.Group("/user")
+	user.Post("/getPreferences", controllers.HandleGetUserPreferences)
+	user.Post("/setPreferences", controllers.HandleSetUserPreferences)
+
+	// saved destinations for broadcasting
+	streamDestinations := auth.Group("/streamDestinations")
+	streamDestinations.Post("/add", controllers.HandleAddStreamDestination)
+	streamDestinations.Post("/list", controllers.HandleListStreamDestinations)
+	streamDestinations.Post("/update", controllers.HandleUpdateStreamDestination)
+	streamDestinations.Post("/delete", controllers.HandleDeleteStreamDestination)
+
+	// reusable polls of the api key
+	pollTemplates := auth.Group("/pollTemplates")
+	pollTemplates.Post("/add", controllers.HandleSavePollTemplate)
+	pollTemplates.Post("/list", controllers.HandleListPollTemplates)
+	pollTemplates.Post("/delete", controllers.HandleDeletePollTemplate)
+	pollTemplates.Post("/launch", controllers.HandleLaunchPollTemplate)
+
+	whiteboard := auth.Group("/whiteboard")
+	whiteboard.Post("/export", controllers.HandleExportWhiteboard)
+	whiteboard.Post("/exports", controllers.HandleListWhiteboardExports)
+
 	// for recording
 	recording := auth.Group("/recording")
 	recording.Post("/fetch", controllers.HandleFetchRecordings)
 	recording.Post("/delete", controllers.HandleDeleteRecording)
+	recording.Post("/restore", controllers.HandleRestoreRecording)
 	recording.Post("/getDownloadToken", controllers.HandleGetDownloadToken)
+	recording.Post("/getSignedUrl", controllers.HandleGetSignedUrl)
+	recording.Post("/info", controllers.HandleGetRecordingInfo)
+	recording.Post("/tracks", controllers.HandleGetRecordingTracks)
+	recording.Post("/usage", controllers.HandleGetRecordingUsage)
 
 	// to handle different events from recorder
 	recorder := auth.Group("/recorder")
 	recorder.Post("/notify", controllers.HandleRecorderEvents)
+	recorder.Post("/heartbeat", controllers.HandleRecorderHeartbeat)
+	recorder.Post("/broadcastStats", controllers.HandleRecorderBroadcastStats)
+
+	// for external analytics pipelines
+	events := auth.Group("/events")
+	events.Post("/stream", controllers.HandleEventStream)
+
+	// for server administration
+	admin := auth.Group("/admin")
+	admin.Post("/getRedisUsage", controllers.HandleGetRedisUsage)
+	admin.Post("/simulateRoomExpiry", controllers.HandleSimulateRoomExpiry)
+	admin.Post("/reconcile", controllers.HandleReconcileRooms)
+	admin.Post("/recorders", controllers.HandleGetRecorderNodes)
+	admin.Post("/recordingUploads/failed", controllers.HandleGetFailedRecordingUploads)
+	admin.Post("/recordingUploads/retry", controllers.HandleRetryFailedRecordingUpload)
+	admin.Post("/apiKeys/list", controllers.HandleListApiKeys)
+	admin.Post("/apiKeys/add", controllers.HandleAddApiKey)
+	admin.Post("/apiKeys/revoke", controllers.HandleRevokeApiKey)
 
 	// api group, will require sending token as Authorization header value
 	api := app.Group("/api", controllers.HandleVerifyHeaderToken)
-	api.Post("/verifyToken", controllers.HandleVerifyToken)
+	api.Post("/getVerifyNonce", controllers.HandleJoinRateLimit, controllers.HandleGetVerifyNonce)
+	api.Post("/verifyToken", controllers.HandleJoinRateLimit, controllers.HandleVerifyToken)
 	api.Post("/renewToken", controllers.HandleRenewToken)
 
 	api.Post("/recording", controllers.HandleRecording)
+	api.Post("/recording/markers", controllers.HandleAddRecordingMarker)
+	api.Post("/recording/layout", controllers.HandleSetCompositeLayout)
+	api.Get("/recording/markers", controllers.HandleGetRecordingMarkers)
+	api.Post("/recording/consent", controllers.HandleSubmitRecordingConsent)
 	api.Post("/rtmp", controllers.HandleRTMP)
+	api.Post("/rtmp/destinations", controllers.HandleStartRtmpDestinations)
+	api.Get("/rtmp/destinations", controllers.HandleGetRtmpDestinations)
+	api.Post("/rtmp/destinations/stop", controllers.HandleStopRtmpDestination)
+	api.Get("/rtmp/status", controllers.HandleGetBroadcastHealthForAPI)
+	api.Post("/pauseBroadcast", controllers.HandlePauseBroadcast)
+	api.Post("/resumeBroadcast", controllers.HandleResumeBroadcast)
+	api.Post("/hls", controllers.HandleHls)
+	api.Post("/ingress/create", controllers.HandleCreateIngressForAPI)
+	api.Get("/ingress", controllers.HandleGetRoomIngressForAPI)
+	api.Post("/ingress/delete", controllers.HandleDeleteIngressForAPI)
 	api.Post("/updateLockSettings", controllers.HandleUpdateUserLockSetting)
 	api.Post("/muteUnmuteTrack", controllers.HandleMuteUnMuteTrack)
+	api.Post("/muteAllMics", controllers.HandleMuteAllMics)
 	api.Post("/removeParticipant", controllers.HandleRemoveParticipant)
+	api.Post("/banParticipant", controllers.HandleBanParticipant)
 	api.Post("/dataMessage", controllers.HandleDataMessage)
 	api.Post("/endRoom", controllers.HandleEndRoomForAPI)
 	api.Post("/changeVisibility", controllers.HandleChangeVisibilityForAPI)
 	api.Post("/convertWhiteboardFile", controllers.HandleConvertWhiteboardFile)
+	api.Get("/whiteboard/state", controllers.HandleGetWhiteboardState)
+	api.Get("/whiteboard/conversion/:jobId", controllers.HandleGetWhiteboardConversion)
+	api.Get("/whiteboard/pages", controllers.HandleGetWhiteboardPages)
+	api.Post("/whiteboard/pages", controllers.HandleManageWhiteboardPage)
+	api.Get("/whiteboard/permissions", controllers.HandleGetWhiteboardDrawPermissions)
+	api.Post("/whiteboard/permissions/mode", controllers.HandleSetWhiteboardDrawMode)
+	api.Post("/whiteboard/permissions/user", controllers.HandleSetWhiteboardDrawPermission)
+	api.Get("/screenAnnotation", controllers.HandleGetScreenAnnotation)
+	api.Post("/screenAnnotation/start", controllers.HandleStartScreenAnnotation)
+	api.Post("/screenAnnotation/stop", controllers.HandleStopScreenAnnotation)
+	api.Post("/screenAnnotation/clear", controllers.HandleClearScreenAnnotations)
+	api.Post("/whiteboard/restore", controllers.HandleRestoreWhiteboard)
+	api.Post("/whiteboard/export", controllers.HandleExportWhiteboardForAPI)
 	api.Post("/externalMediaPlayer", controllers.HandleExternalMediaPlayer)
 	api.Post("/switchPresenter", controllers.HandleSwitchPresenter)
 	api.Post("/externalDisplayLink", controllers.HandleExternalDisplayLink)
+	api.Post("/setRoomLayout", controllers.HandleSetRoomLayout)
+	api.Get("/preferences", controllers.HandleGetMyPreferences)
+	api.Get("/chat/export", controllers.HandleExportChatForAPI)
+	api.Post("/chat/deleteMessage", controllers.HandleDeleteChatMessage)
+	api.Post("/chat/muteUser", controllers.HandleMuteUserChat)
+	api.Post("/chat/translation", controllers.HandleSetChatTranslation)
+	api.Post("/chat/pinMessage", controllers.HandlePinChatMessage)
+	api.Post("/chat/unpinMessage", controllers.HandleUnpinChatMessage)
+	api.Get("/chat/pins", controllers.HandleGetChatPins)
+	api.Post("/announcement", controllers.HandleSendAnnouncement)
+	api.Get("/announcements", controllers.HandleGetAnnouncements)
+	api.Get("/reactions", controllers.HandleGetReactionCounts)
+	api.Post("/preferences", controllers.HandleSetMyPreferences)
 
 	// etherpad group
 	etherpad := api.Group("/etherpad")
@@ -131,8 +258,36 @@ func Router() *fiber.App {
 	polls.Get("/userSelectedOption/:pollId/:userId", controllers.HandleUserSelectedOption)
 	polls.Get("/pollResponsesDetails/:pollId", controllers.HandleGetPollResponsesDetails)
 	polls.Get("/pollResponsesResult/:pollId", controllers.HandleGetResponsesResult)
+	polls.Get("/pollSettings/:pollId", controllers.HandleGetPollSettings)
+	polls.Get("/pollResults/:pollId", controllers.HandleGetPollResults)
+	polls.Get("/wordCloud/:pollId", controllers.HandleGetPollWordCloud)
+	polls.Get("/textResponses/:pollId", controllers.HandleListPollTextResponses)
+	polls.Get("/export", controllers.HandleExportPollsForAPI)
 	polls.Post("/submitResponse", controllers.HandleUserSubmitResponse)
+	polls.Post("/submitBallot", controllers.HandleSubmitPollBallot)
+	polls.Post("/submitText", controllers.HandleSubmitPollText)
+	polls.Post("/moderateText", controllers.HandleModeratePollText)
 	polls.Post("/closePoll", controllers.HandleClosePoll)
+	polls.Get("/templates", controllers.HandleListPollTemplatesForAPI)
+	polls.Post("/saveTemplate", controllers.HandleSavePollTemplateForAPI)
+	polls.Post("/deleteTemplate", controllers.HandleDeletePollTemplateForAPI)
+	polls.Post("/launchTemplate", controllers.HandleLaunchPollTemplateForAPI)
+
+	// raise hand group
+	raiseHand := api.Group("/raiseHand")
+	raiseHand.Post("/raise", controllers.HandleRaiseHand)
+	raiseHand.Post("/lower", controllers.HandleLowerHand)
+	raiseHand.Post("/clear", controllers.HandleClearHands)
+	raiseHand.Get("/queue", controllers.HandleGetRaisedHands)
+
+	// speaker queue group
+	speakerQueue := api.Group("/speakerQueue")
+	speakerQueue.Post("/enable", controllers.HandleEnableSpeakerQueue)
+	speakerQueue.Post("/disable", controllers.HandleDisableSpeakerQueue)
+	speakerQueue.Get("/get", controllers.HandleGetSpeakerQueue)
+	speakerQueue.Post("/request", controllers.HandleRequestFloor)
+	speakerQueue.Post("/withdraw", controllers.HandleWithdrawFloor)
+	speakerQueue.Post("/next", controllers.HandleNextSpeaker)
 
 	// breakout room group
 	breakoutRoom := api.Group("/breakoutRoom")
@@ -154,6 +309,8 @@ func Router() *fiber.App {
 	app.Use("/ws", func(c *fiber.Ctx) error {
 		if websocket.IsWebSocketUpgrade(c) {
 			c.Locals("allowed", true)
+			// to validate single-use token
+			c.Locals("session", models.TokenSession(c.IP(), c.Get("User-Agent")))
 			return c.Next()
 		}
 		return fiber.ErrUpgradeRequired
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+type SendAnnouncementReq struct {
+	RoomId          string `json:"-"`
+	RequestedUserId string `json:"-"`
+	Msg             string `json:"msg" validate:"required,max=2000"`
+	// IncludeBreakoutRooms will deliver the announcement to all running breakout rooms too
+	IncludeBreakoutRooms bool `json:"include_breakout_rooms"`
+}
+
+// Announcement will be shown as banner by clients
+type Announcement struct {
+	Id         string `json:"id"`
+	RoomId     string `json:"room_id"`
+	SenderId   string `json:"sender_id"`
+	SenderName string `json:"sender_name"`
+	Msg        string `json:"msg"`
+	SentAt     int64  `json:"sent_at"`
+	// FromParentRoom will be true in breakout rooms
+	FromParentRoom bool `json:"from_parent_room,omitempty"`
+}
+
+// SendAnnouncement will broadcast the announcement & store it in DB
+func (r *RoomService) SendAnnouncement(req *SendAnnouncementReq) (*Announcement, error) {
+	room, _ := NewRoomModel().GetRoomInfo(req.RoomId, "", 1)
+	if room.Id == 0 {
+		return nil, errors.New("notifications.room-not-active")
+	}
+
+	a := &Announcement{
+		Id:       uuid.NewString(),
+		RoomId:   req.RoomId,
+		SenderId: req.RequestedUserId,
+		Msg:      req.Msg,
+		SentAt:   time.Now().Unix(),
+	}
+	if p, err := r.LoadParticipantInfo(req.RoomId, req.RequestedUserId); err == nil {
+		a.SenderName = p.Name
+	}
+
+	broadcastSystemMsg(req.RoomId, DataMsgBodyType_ANNOUNCEMENT, a)
+	if req.IncludeBreakoutRooms {
+		rooms, err := NewBreakoutRoomModel().fetchBreakoutRooms(req.RoomId)
+		if err == nil {
+			ba := *a
+			ba.FromParentRoom = true
+			for _, br := range rooms {
+				if br.Started {
+					broadcastSystemMsg(br.Id, DataMsgBodyType_ANNOUNCEMENT, &ba)
+				}
+			}
+		}
+	}
+
+	r.AddRoomTimelineEvent(req.RoomId, &RoomTimelineEvent{
+		Type:   "announcement",
+		UserId: req.RequestedUserId,
+		Msg:    a.Id,
+	})
+	if err := insertAnnouncement(room.Sid, a); err != nil {
+		log.Errorln(err)
+	}
+
+	return a, nil
+}
+
+func insertAnnouncement(roomSid string, a *Announcement) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := "INSERT INTO " + config.AppCnf.FormatDBTable("announcements") + " (announcement_id, room_id, room_sid, sender_id, sender_name, msg, sent_at) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	_, err := config.AppCnf.DB.ExecContext(ctx, query, a.Id, a.RoomId, roomSid, a.SenderId, a.SenderName, a.Msg, a.SentAt)
+	return err
+}
+
+// GetAnnouncements will return announcements of the running session, oldest first
+func (r *RoomService) GetAnnouncements(roomId string) ([]*Announcement, error) {
+	room, _ := NewRoomModel().GetRoomInfo(roomId, "", 1)
+	if room.Id == 0 {
+		return nil, errors.New("notifications.room-not-active")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	query := "SELECT announcement_id, room_id, sender_id, sender_name, msg, sent_at FROM " + config.AppCnf.FormatDBTable("announcements") + " WHERE room_id = ? AND room_sid = ? ORDER BY id"
+	rows, err := config.AppCnf.DB.QueryContext(ctx, query, roomId, room.Sid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	announcements := make([]*Announcement, 0)
+	for rows.Next() {
+		a := new(Announcement)
+		if err = rows.Scan(&a.Id, &a.RoomId, &a.SenderId, &a.SenderName, &a.Msg, &a.SentAt); err != nil {
+			return nil, err
+		}
+		announcements = append(announcements, a)
+	}
+	return announcements, rows.Err()
+}
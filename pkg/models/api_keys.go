@@ -0,0 +1,292 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"time"
+)
+
+const (
+	// apiKeysKey holds the pairs registered at runtime using admin api
+	apiKeysKey = "pnm:api_keys"
+	// revokedApiKeysKey holds revoked keys, both from config & runtime
+	revokedApiKeysKey = "pnm:revoked_api_keys"
+)
+
+type ApiKeyInfo struct {
+	Key    string `json:"key"`
+	Secret string `json:"secret,omitempty"`
+	Source string `json:"source"`
+	// Scopes granted to the key, empty means full access
+	Scopes []string `json:"scopes,omitempty"`
+	// RecordingRetentionDays for rooms created by the key, 0 means default
+	RecordingRetentionDays int `json:"recording_retention_days,omitempty"`
+	// RecordingQuotaMB for all recordings of rooms created by the key, 0 means default
+	RecordingQuotaMB int64 `json:"recording_quota_mb,omitempty"`
+	CreatedAt        int64 `json:"created_at,omitempty"`
+	Revoked          bool  `json:"revoked"`
+}
+
+type AddApiKeyReq struct {
+	// Key & Secret will be generated if empty
+	Key                    string   `json:"key" validate:"omitempty,min=6,max=64"`
+	Secret                 string   `json:"secret" validate:"omitempty,min=24"`
+	Scopes                 []string `json:"scopes"`
+	RecordingRetentionDays int      `json:"recording_retention_days" validate:"min=0"`
+	RecordingQuotaMB       int64    `json:"recording_quota_mb" validate:"min=0"`
+}
+
+type RevokeApiKeyReq struct {
+	Key string `json:"key" validate:"required"`
+	// ReplacedBy key will get the stream destinations & poll templates of the revoked key
+	ReplacedBy string `json:"replaced_by"`
+}
+
+type apiKeysModel struct {
+	app *config.AppConfig
+	db  *sql.DB
+	rc  *redis.Client
+	ctx context.Context
+}
+
+func NewApiKeysModel() *apiKeysModel {
+	return &apiKeysModel{
+		app: config.AppCnf,
+		db:  config.AppCnf.DB,
+		rc:  config.AppCnf.RDS,
+		ctx: context.Background(),
+	}
+}
+
+// configApiKeys will return key/secret pairs from config including the primary one
+func (m *apiKeysModel) configApiKeys() []config.ApiKeyPair {
+	keys := []config.ApiKeyPair{
+		{
+			Key:    m.app.Client.ApiKey,
+			Secret: m.app.Client.Secret,
+		},
+	}
+	return append(keys, m.app.Client.ApiKeys...)
+}
+
+// GrantedScopes will return scopes of the key, admin if not restricted
+func (i *ApiKeyInfo) GrantedScopes() []string {
+	if len(i.Scopes) == 0 {
+		return []string{ScopeAdmin}
+	}
+	return i.Scopes
+}
+
+// LookupApiKey will return the key with secret if it's active
+func (m *apiKeysModel) LookupApiKey(key string) (*ApiKeyInfo, error) {
+	if key == "" {
+		return nil, errors.New("invalid API key")
+	}
+	revoked, err := m.rc.SIsMember(m.ctx, revokedApiKeysKey, key).Result()
+	if err != nil {
+		log.Errorln(err)
+		return nil, err
+	}
+	if revoked {
+		return nil, errors.New("API key was revoked")
+	}
+
+	for _, k := range m.configApiKeys() {
+		if k.Key == key {
+			return &ApiKeyInfo{
+				Key:                    k.Key,
+				Secret:                 k.Secret,
+				Source:                 "config",
+				Scopes:                 k.Scopes,
+				RecordingRetentionDays: k.RecordingRetentionDays,
+				RecordingQuotaMB:       k.RecordingQuotaMB,
+			}, nil
+		}
+	}
+
+	result, err := m.rc.HGet(m.ctx, apiKeysKey, key).Result()
+	if err == redis.Nil {
+		return nil, errors.New("invalid API key")
+	} else if err != nil {
+		log.Errorln(err)
+		return nil, err
+	}
+
+	info := new(ApiKeyInfo)
+	err = json.Unmarshal([]byte(result), info)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// ResolveSigningKey will return key & secret which should be used to sign outgoing request.
+// If the requested key isn't active anymore then primary key will be used.
+func (m *apiKeysModel) ResolveSigningKey(key string) (string, string) {
+	if key != "" {
+		info, err := m.LookupApiKey(key)
+		if err == nil {
+			return info.Key, info.Secret
+		}
+	}
+	return m.app.Client.ApiKey, m.app.Client.Secret
+}
+
+func (m *apiKeysModel) AddApiKey(r *AddApiKeyReq) (*ApiKeyInfo, error) {
+	if err := ValidateScopes(r.Scopes); err != nil {
+		return nil, err
+	}
+	info := &ApiKeyInfo{
+		Key:                    r.Key,
+		Secret:                 r.Secret,
+		Source:                 "runtime",
+		Scopes:                 r.Scopes,
+		CreatedAt:              time.Now().Unix(),
+		RecordingRetentionDays: r.RecordingRetentionDays,
+		RecordingQuotaMB:       r.RecordingQuotaMB,
+	}
+	if info.Key == "" {
+		info.Key = "API" + randomHex(8)
+	}
+	if info.Secret == "" {
+		info.Secret = randomHex(24)
+	}
+
+	for _, k := range m.configApiKeys() {
+		if k.Key == info.Key {
+			return nil, errors.New("key already exists")
+		}
+	}
+	revoked, _ := m.rc.SIsMember(m.ctx, revokedApiKeysKey, info.Key).Result()
+	if revoked {
+		return nil, errors.New("revoked key can't be reused")
+	}
+
+	marshal, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	added, err := m.rc.HSetNX(m.ctx, apiKeysKey, info.Key, marshal).Result()
+	if err != nil {
+		log.Errorln(err)
+		return nil, err
+	}
+	if !added {
+		return nil, errors.New("key already exists")
+	}
+
+	return info, nil
+}
+
+// RevokeApiKey will revoke the key, config keys will be revoked too
+// until removed from the revoked list. The key used for the request can't be revoked.
+func (m *apiKeysModel) RevokeApiKey(r *RevokeApiKeyReq, requestedBy string) error {
+	if requestedBy == "" {
+		return errors.New("can't find the key used for this request")
+	}
+	if r.Key == requestedBy {
+		return errors.New("can't revoke the key used for this request")
+	}
+	if _, err := m.LookupApiKey(r.Key); err != nil {
+		return err
+	}
+
+	if r.ReplacedBy != "" {
+		if r.ReplacedBy == r.Key {
+			return errors.New("key can't be replaced by itself")
+		}
+		if _, err := m.LookupApiKey(r.ReplacedBy); err != nil {
+			return err
+		}
+		if err := m.moveApiKeyResources(r.Key, r.ReplacedBy); err != nil {
+			return err
+		}
+	}
+
+	_, err := m.rc.SAdd(m.ctx, revokedApiKeysKey, r.Key).Result()
+	if err != nil {
+		log.Errorln(err)
+		return err
+	}
+	return nil
+}
+
+// moveApiKeyResources will move the resources those are stored per api key,
+// so that those will be available with the new key after rotation
+func (m *apiKeysModel) moveApiKeyResources(from, to string) error {
+	ctx, cancel := context.WithTimeout(m.ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"stream_destinations", "poll_templates"} {
+		_, err = tx.ExecContext(ctx, "UPDATE "+m.app.FormatDBTable(table)+" SET api_key = ? WHERE api_key = ?", to, from)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListApiKeys will return all the keys without secret
+func (m *apiKeysModel) ListApiKeys() ([]*ApiKeyInfo, error) {
+	revoked, err := m.rc.SMembers(m.ctx, revokedApiKeysKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	isRevoked := make(map[string]bool)
+	for _, k := range revoked {
+		isRevoked[k] = true
+	}
+
+	var keys []*ApiKeyInfo
+	for _, k := range m.configApiKeys() {
+		keys = append(keys, &ApiKeyInfo{
+			Key:                    k.Key,
+			Source:                 "config",
+			Scopes:                 k.Scopes,
+			Revoked:                isRevoked[k.Key],
+			RecordingRetentionDays: k.RecordingRetentionDays,
+			RecordingQuotaMB:       k.RecordingQuotaMB,
+		})
+	}
+
+	result, err := m.rc.HGetAll(m.ctx, apiKeysKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	var runtime []*ApiKeyInfo
+	for _, v := range result {
+		info := new(ApiKeyInfo)
+		if err = json.Unmarshal([]byte(v), info); err != nil {
+			continue
+		}
+		info.Secret = ""
+		info.Revoked = isRevoked[info.Key]
+		runtime = append(runtime, info)
+	}
+	sort.Slice(runtime, func(i, j int) bool {
+		return runtime[i].CreatedAt < runtime[j].CreatedAt
+	})
+
+	return append(keys, runtime...), nil
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+// scopes of /auth endpoints, used by api keys & OIDC tokens
+const (
+	ScopeAdmin            = "admin"
+	ScopeRoomsRead        = "rooms:read"
+	ScopeRoomsManage      = "rooms:manage"
+	ScopeRecordingsRead   = "recordings:read"
+	ScopeRecordingsManage = "recordings:manage"
+	ScopeAnalyticsRead    = "analytics:read"
+)
+
+var validScopes = map[string]bool{
+	ScopeAdmin:            true,
+	ScopeRoomsRead:        true,
+	ScopeRoomsManage:      true,
+	ScopeRecordingsRead:   true,
+	ScopeRecordingsManage: true,
+	ScopeAnalyticsRead:    true,
+}
+
+// authEndpointScopes maps /auth endpoints to the required scope.
+// Endpoints not in the list will require admin scope.
+var authEndpointScopes = map[string]string{
+	"/getClientFiles":             ScopeRoomsRead,
+	"/renewToken":                 ScopeRoomsManage,
+	"/revoke":                     ScopeRoomsManage,
+	"/room/create":                ScopeRoomsManage,
+	"/room/getJoinToken":          ScopeRoomsManage,
+	"/room/getGuestJoinLink":      ScopeRoomsManage,
+	"/room/isRoomActive":          ScopeRoomsRead,
+	"/room/getActiveRoomInfo":     ScopeRoomsRead,
+	"/room/getActiveRoomsInfo":    ScopeRoomsRead,
+	"/room/endRoom":               ScopeRoomsManage,
+	"/room/endAll":                ScopeRoomsManage,
+	"/room/merge":                 ScopeRoomsManage,
+	"/room/getTimeline":           ScopeAnalyticsRead,
+	"/room/broadcast/status":      ScopeRoomsRead,
+	"/room/hls/start":             ScopeRoomsManage,
+	"/room/hls/stop":              ScopeRoomsManage,
+	"/room/hls/info":              ScopeRoomsRead,
+	"/room/ingress/create":        ScopeRoomsManage,
+	"/room/ingress/list":          ScopeRoomsManage,
+	"/room/ingress/delete":        ScopeRoomsManage,
+	"/streamDestinations/add":     ScopeRoomsManage,
+	"/streamDestinations/list":    ScopeRoomsManage,
+	"/streamDestinations/update":  ScopeRoomsManage,
+	"/streamDestinations/delete":  ScopeRoomsManage,
+	"/sessions":                   ScopeAnalyticsRead,
+	"/sessions/deleteArtifact":    ScopeRecordingsManage,
+	"/chat/export":                ScopeAnalyticsRead,
+	"/polls/export":               ScopeAnalyticsRead,
+	"/events/stream":              ScopeAnalyticsRead,
+	"/pollTemplates/add":          ScopeRoomsManage,
+	"/pollTemplates/list":         ScopeRoomsRead,
+	"/pollTemplates/delete":       ScopeRoomsManage,
+	"/pollTemplates/launch":       ScopeRoomsManage,
+	"/whiteboard/export":          ScopeRecordingsRead,
+	"/whiteboard/exports":         ScopeRecordingsRead,
+	"/recording/fetch":            ScopeRecordingsRead,
+	"/recording/getDownloadToken": ScopeRecordingsRead,
+	"/recording/getSignedUrl":     ScopeRecordingsRead,
+	"/recording/info":             ScopeRecordingsRead,
+	"/recording/tracks":           ScopeRecordingsRead,
+	"/recording/usage":            ScopeRecordingsRead,
+	"/recording/delete":           ScopeRecordingsManage,
+	"/recording/restore":          ScopeRecordingsManage,
+}
+
+// RequiredScope will return the scope required for the /auth endpoint
+func RequiredScope(path string) string {
+	path = strings.TrimSuffix(strings.TrimPrefix(path, "/auth"), "/")
+	if s, ok := authEndpointScopes[path]; ok {
+		return s
+	}
+	// GET /auth/room/:roomId/participants
+	if strings.HasPrefix(path, "/room/") && strings.HasSuffix(path, "/participants") {
+		return ScopeRoomsRead
+	}
+	return ScopeAdmin
+}
+
+// HasScope will check if the required scope was granted.
+// Admin allows everything & manage scope will allow read too.
+func HasScope(granted []string, required string) bool {
+	for _, s := range granted {
+		if s == required || s == ScopeAdmin {
+			return true
+		}
+		if strings.HasSuffix(s, ":manage") && strings.TrimSuffix(s, ":manage")+":read" == required {
+			return true
+		}
+	}
+	return false
+}
+
+func ValidateScopes(scopes []string) error {
+	for _, s := range scopes {
+		if !validScopes[s] {
+			return errors.New("invalid scope: " + s)
+		}
+	}
+	return nil
+}
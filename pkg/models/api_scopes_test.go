@@ -0,0 +1,121 @@
+package models
+
+import (
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"reflect"
+	"testing"
+)
+
+func TestRequiredScope(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/auth/room/create", ScopeRoomsManage},
+		{"/auth/room/create/", ScopeRoomsManage},
+		{"/auth/room/isRoomActive", ScopeRoomsRead},
+		{"/auth/room/room01/participants", ScopeRoomsRead},
+		{"/auth/recording/fetch", ScopeRecordingsRead},
+		{"/auth/recording/delete", ScopeRecordingsManage},
+		{"/auth/room/getTimeline", ScopeAnalyticsRead},
+		{"/auth/apiKeys/add", ScopeAdmin},
+		{"/auth/unknown", ScopeAdmin},
+		{"/auth", ScopeAdmin},
+	}
+
+	for _, tt := range tests {
+		got := RequiredScope(tt.path)
+		if got != tt.want {
+			t.Errorf("RequiredScope(%s) = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		granted  []string
+		required string
+		want     bool
+	}{
+		{[]string{ScopeAdmin}, ScopeAdmin, true},
+		{[]string{ScopeAdmin}, ScopeRecordingsManage, true},
+		{[]string{ScopeRoomsRead}, ScopeRoomsRead, true},
+		{[]string{ScopeRoomsRead}, ScopeRoomsManage, false},
+		{[]string{ScopeRoomsManage}, ScopeRoomsRead, true},
+		{[]string{ScopeRoomsManage}, ScopeRecordingsRead, false},
+		{[]string{ScopeRecordingsManage}, ScopeRecordingsRead, true},
+		{[]string{ScopeRoomsRead, ScopeRecordingsRead}, ScopeRecordingsRead, true},
+		{[]string{ScopeRoomsManage}, ScopeAdmin, false},
+		{[]string{ScopeAnalyticsRead}, ScopeAdmin, false},
+		{nil, ScopeRoomsRead, false},
+	}
+
+	for _, tt := range tests {
+		got := HasScope(tt.granted, tt.required)
+		if got != tt.want {
+			t.Errorf("HasScope(%v, %s) = %v, want %v", tt.granted, tt.required, got, tt.want)
+		}
+	}
+}
+
+func TestValidateScopes(t *testing.T) {
+	tests := []struct {
+		scopes  []string
+		wantErr bool
+	}{
+		{nil, false},
+		{[]string{ScopeAdmin}, false},
+		{[]string{ScopeRoomsRead, ScopeRecordingsManage, ScopeAnalyticsRead}, false},
+		{[]string{ScopeRoomsRead, "rooms:write"}, true},
+		{[]string{""}, true},
+	}
+
+	for _, tt := range tests {
+		err := ValidateScopes(tt.scopes)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateScopes(%v) error = %v, wantErr %v", tt.scopes, err, tt.wantErr)
+		}
+	}
+}
+
+func TestApiKeyInfo_GrantedScopes(t *testing.T) {
+	tests := []struct {
+		scopes []string
+		want   []string
+	}{
+		{nil, []string{ScopeAdmin}},
+		{[]string{}, []string{ScopeAdmin}},
+		{[]string{ScopeRoomsRead}, []string{ScopeRoomsRead}},
+	}
+
+	for _, tt := range tests {
+		i := &ApiKeyInfo{Scopes: tt.scopes}
+		got := i.GrantedScopes()
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GrantedScopes() of %v = %v, want %v", tt.scopes, got, tt.want)
+		}
+	}
+}
+
+func TestOidcModel_GetRoles(t *testing.T) {
+	tests := []struct {
+		claim  string
+		claims map[string]interface{}
+		want   []string
+	}{
+		{"", map[string]interface{}{"roles": []interface{}{"pnm-admin", "viewer"}}, []string{"pnm-admin", "viewer"}},
+		{"", map[string]interface{}{"roles": "pnm-admin viewer"}, []string{"pnm-admin", "viewer"}},
+		{"", map[string]interface{}{"roles": []interface{}{"viewer", 1}}, []string{"viewer"}},
+		{"realm_access.roles", map[string]interface{}{"realm_access": map[string]interface{}{"roles": []interface{}{"viewer"}}}, []string{"viewer"}},
+		{"realm_access.roles", map[string]interface{}{"realm_access": "viewer"}, nil},
+		{"groups", map[string]interface{}{"roles": []interface{}{"viewer"}}, nil},
+	}
+
+	for _, tt := range tests {
+		m := &oidcModel{conf: config.OidcConf{RolesClaim: tt.claim}}
+		got := m.getRoles(tt.claims)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("getRoles(%v) with claim %s = %v, want %v", tt.claims, tt.claim, got, tt.want)
+		}
+	}
+}
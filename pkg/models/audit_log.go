@@ -0,0 +1,60 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+type AuditLog struct {
+	Action    string `json:"action"`
+	RoomId    string `json:"room_id"`
+	RoomSid   string `json:"room_sid"`
+	Actor     string `json:"actor"`
+	ActorIp   string `json:"actor_ip"`
+	Details   string `json:"details"`
+	Succeeded bool   `json:"succeeded"`
+}
+
+type auditLogModel struct {
+	app *config.AppConfig
+	db  *sql.DB
+	ctx context.Context
+}
+
+func NewAuditLogModel() *auditLogModel {
+	return &auditLogModel{
+		app: config.AppCnf,
+		db:  config.AppCnf.DB,
+		ctx: context.Background(),
+	}
+}
+
+// AddAuditLog will store the log in DB as well as in log file
+// so that we'll have the record even if DB insertion failed.
+func (m *auditLogModel) AddAuditLog(a *AuditLog) {
+	log.WithFields(log.Fields{
+		"audit":     a.Action,
+		"room_id":   a.RoomId,
+		"room_sid":  a.RoomSid,
+		"actor":     a.Actor,
+		"actor_ip":  a.ActorIp,
+		"succeeded": a.Succeeded,
+	}).Infoln(a.Details)
+
+	ctx, cancel := context.WithTimeout(m.ctx, 3*time.Second)
+	defer cancel()
+
+	succeeded := 0
+	if a.Succeeded {
+		succeeded = 1
+	}
+
+	query := "INSERT INTO " + m.app.FormatDBTable("audit_logs") + " (action, room_id, room_sid, actor, actor_ip, details, succeeded) VALUES (?, ?, ?, ?, ?, ?, ?)"
+	_, err := m.db.ExecContext(ctx, query, a.Action, a.RoomId, a.RoomSid, a.Actor, a.ActorIp, a.Details, succeeded)
+	if err != nil {
+		log.Errorln(err)
+	}
+}
@@ -6,8 +6,12 @@ import (
 	"github.com/livekit/protocol/auth"
 	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
 	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"time"
 )
 
+// renewTokenLeeway allows renewing the token which has been expired recently
+const renewTokenLeeway = 5 * time.Minute
+
 type authTokenModel struct {
 	app *config.AppConfig
 	rs  *RoomService
@@ -21,21 +25,46 @@ func NewAuthTokenModel() *authTokenModel {
 }
 
 func (a *authTokenModel) DoGenerateToken(g *plugnmeet.GenerateTokenReq) (string, error) {
+	return a.generateToken(g, a.joinTokenValidity(), nil)
+}
+
+// DoGenerateTokenWithPermissions will generate token with granular media permissions
+func (a *authTokenModel) DoGenerateTokenWithPermissions(g *plugnmeet.GenerateTokenReq, p *UserMediaPermissions) (string, error) {
+	return a.generateToken(g, a.joinTokenValidity(), p)
+}
+
+func (a *authTokenModel) generateToken(g *plugnmeet.GenerateTokenReq, validity time.Duration, p *UserMediaPermissions) (string, error) {
 	if g.UserInfo.UserMetadata == nil {
 		g.UserInfo.UserMetadata = new(plugnmeet.UserMetadata)
 	}
 
+	// don't generate token if user is blocked
+	if a.rs.IsUserExistInBlockList(g.RoomId, g.UserInfo.UserId) {
+		return "", errors.New("this user is blocked to join this session")
+	}
+
+	// pre-assigned moderators during room creation
+	if !g.UserInfo.IsAdmin {
+		opts := a.rs.LoadRoomOptions(g.RoomId)
+		if opts.IsModerator(g.UserInfo.UserId) {
+			g.UserInfo.IsAdmin = true
+		}
+	}
+
 	a.assignLockSettings(g)
+	p.applyToMetadata(g.UserInfo.UserMetadata)
+	if err := a.rs.saveUserMediaPermissions(g.RoomId, g.UserInfo.UserId, p); err != nil {
+		return "", err
+	}
 	if g.UserInfo.IsAdmin {
 		a.makePresenter(g)
 	}
 
-	metadata, err := json.Marshal(g.UserInfo.UserMetadata)
+	metadata, err := NewUserPreferencesModel().marshalMetadataWithPreferences(a.rs.LoadRoomOptions(g.RoomId).ApiKey, g.UserInfo.UserId, g.UserInfo.UserMetadata)
 	if err != nil {
 		return "", err
 	}
 
-	at := auth.NewAccessToken(a.app.Client.ApiKey, a.app.Client.Secret)
 	grant := &auth.VideoGrant{
 		RoomJoin:  true,
 		Room:      g.RoomId,
@@ -47,13 +76,14 @@ func (a *authTokenModel) DoGenerateToken(g *plugnmeet.GenerateTokenReq) (string,
 		grant.Recorder = true
 	}
 
-	at.AddGrant(grant).
-		SetIdentity(g.UserInfo.UserId).
-		SetName(g.UserInfo.Name).
-		SetMetadata(string(metadata)).
-		SetValidFor(a.app.LivekitInfo.TokenValidity)
+	p.applyToGrant(grant)
 
-	return at.ToJWT()
+	return a.signJoinToken(&auth.ClaimGrants{
+		Identity: g.UserInfo.UserId,
+		Name:     g.UserInfo.Name,
+		Video:    grant,
+		Metadata: string(metadata),
+	}, validity)
 }
 
 // GenerateLivekitToken will generate token to join livekit server
@@ -62,10 +92,27 @@ func (a *authTokenModel) DoGenerateToken(g *plugnmeet.GenerateTokenReq) (string,
 func (a *authTokenModel) GenerateLivekitToken(claims *auth.ClaimGrants) (string, error) {
 	at := auth.NewAccessToken(a.app.LivekitInfo.ApiKey, a.app.LivekitInfo.Secret)
 	grant := &auth.VideoGrant{
-		RoomJoin:  true,
-		Room:      claims.Video.Room,
-		RoomAdmin: claims.Video.RoomAdmin,
-		Hidden:    claims.Video.Hidden,
+		RoomJoin:       true,
+		Room:           claims.Video.Room,
+		RoomAdmin:      claims.Video.RoomAdmin,
+		Hidden:         claims.Video.Hidden,
+		CanPublish:     claims.Video.CanPublish,
+		CanSubscribe:   claims.Video.CanSubscribe,
+		CanPublishData: claims.Video.CanPublishData,
+	}
+
+	if !claims.Video.Recorder {
+		if err := a.checkParticipantLimits(claims.Video.Room, claims.Identity, isViewerMetadata(claims.Metadata)); err != nil {
+			return "", err
+		}
+	}
+
+	// non-moderators can't publish until host joined, view only users don't need to wait
+	viewOnly := claims.Video.CanPublish != nil && !*claims.Video.CanPublish
+	if !claims.Video.RoomAdmin && !claims.Video.Recorder && !viewOnly && a.rs.IsWaitingForHost(claims.Video.Room) {
+		canPublish := false
+		grant.CanPublish = &canPublish
+		a.rs.holdForHost(claims.Video.Room, claims.Identity)
 	}
 
 	at.AddGrant(grant).
@@ -181,7 +228,6 @@ func (a *authTokenModel) makePresenter(g *plugnmeet.GenerateTokenReq) {
 // Because we don't want to add any service settings which may
 // prevent to work recorder/rtmp bot as expected.
 func (a *authTokenModel) GenTokenForRecorder(g *plugnmeet.GenerateTokenReq) (string, error) {
-	at := auth.NewAccessToken(a.app.Client.ApiKey, a.app.Client.Secret)
 	// basic permission
 	grant := &auth.VideoGrant{
 		RoomJoin:  true,
@@ -190,33 +236,42 @@ func (a *authTokenModel) GenTokenForRecorder(g *plugnmeet.GenerateTokenReq) (str
 		Hidden:    g.UserInfo.IsHidden,
 	}
 
-	at.AddGrant(grant).
-		SetIdentity(g.UserInfo.UserId).
-		SetValidFor(a.app.LivekitInfo.TokenValidity)
-
-	return at.ToJWT()
+	return a.signJoinToken(&auth.ClaimGrants{
+		Identity: g.UserInfo.UserId,
+		Video:    grant,
+	}, a.joinTokenValidity())
 }
 
 type ValidateTokenReq struct {
 	Token  string `json:"token"`
 	RoomId string `json:"room_id"`
 	Sid    string `json:"sid"`
-	grant  *auth.APIKeyTokenVerifier
+	// Session of the client, see TokenSession
+	Session string `json:"-"`
+	grant   *auth.APIKeyTokenVerifier
 }
 
 // DoValidateToken can be use to validate both livekit & plugnmeet token
 func (a *authTokenModel) DoValidateToken(v *ValidateTokenReq, livekit bool) (*auth.ClaimGrants, error) {
+	if !livekit {
+		// plugNmeet token can be signed using asymmetric key
+		claims, err := a.parseJoinToken(v.Token, a.joinTokenClockSkew())
+		if err != nil {
+			return nil, err
+		}
+		// used single-use token is bound to the session which consumed it
+		if err = a.checkSingleUseTokenSession(v.Token, v.Session); err != nil {
+			return nil, err
+		}
+		return claims, nil
+	}
+
 	grant, err := auth.ParseAPIToken(v.Token)
 	if err != nil {
 		return nil, err
 	}
 
-	secret := a.app.Client.Secret
-	if livekit {
-		secret = a.app.LivekitInfo.Secret
-	}
-
-	claims, err := grant.Verify(secret)
+	claims, err := grant.Verify(a.app.LivekitInfo.Secret)
 	if err != nil {
 		return nil, err
 	}
@@ -251,6 +306,32 @@ func (a *authTokenModel) DoRenewToken(v *ValidateTokenReq) (string, error) {
 		return "", err
 	}
 
+	return a.renewToken(claims)
+}
+
+// DoRenewTokenWithLeeway will be used from backend, so the token can be expired recently.
+// The user must be still connected with the room.
+func (a *authTokenModel) DoRenewTokenWithLeeway(v *ValidateTokenReq) (string, error) {
+	claims, err := a.parseJoinToken(v.Token, renewTokenLeeway)
+	if err != nil {
+		return "", err
+	}
+	if claims.Video == nil || claims.Video.Room != v.RoomId {
+		return "", errors.New("roomId didn't match")
+	}
+
+	roomInfo, _ := NewRoomModel().GetRoomInfo(claims.Video.Room, v.Sid, 1)
+	if roomInfo.Id == 0 {
+		return "", errors.New("room isn't actively running")
+	}
+	if a.rs.IsUserExistInBlockList(claims.Video.Room, claims.Identity) {
+		return "", errors.New("this user is blocked to join this session")
+	}
+
+	return a.renewToken(claims)
+}
+
+func (a *authTokenModel) renewToken(claims *auth.ClaimGrants) (string, error) {
 	m := NewRoomService()
 	// load current information
 	p, err := m.LoadParticipantInfo(claims.Video.Room, claims.Identity)
@@ -258,11 +339,10 @@ func (a *authTokenModel) DoRenewToken(v *ValidateTokenReq) (string, error) {
 		return "", err
 	}
 
-	at := auth.NewAccessToken(a.app.Client.ApiKey, a.app.Client.Secret)
-	at.AddGrant(claims.Video).
-		SetIdentity(claims.Identity).
-		SetName(p.Name).
-		SetMetadata(p.Metadata).SetValidFor(a.app.LivekitInfo.TokenValidity)
-
-	return at.ToJWT()
+	return a.signJoinToken(&auth.ClaimGrants{
+		Identity: claims.Identity,
+		Name:     p.Name,
+		Video:    claims.Video,
+		Metadata: p.Metadata,
+	}, a.joinTokenValidity())
 }
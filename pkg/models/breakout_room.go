@@ -32,13 +32,14 @@ func NewBreakoutRoomModel() *breakoutRoom {
 	}
 }
 
-func (m *breakoutRoom) CreateBreakoutRooms(r *plugnmeet.CreateBreakoutRoomsReq) error {
+// CreateBreakoutRooms will create breakout rooms in the same livekit node of the parent room
+// unless placement was overridden, so that participants don't need to move across regions
+func (m *breakoutRoom) CreateBreakoutRooms(r *plugnmeet.CreateBreakoutRoomsReq, naming *BreakoutRoomNaming, placement *RoomPlacement) error {
 	mainRoom, err := m.roomService.LoadRoomInfo(r.RoomId)
 	if err != nil {
 		return err
 	}
-	meta := new(plugnmeet.RoomMetadata)
-	err = json.Unmarshal([]byte(mainRoom.Metadata), meta)
+	meta, err := ParseRoomMetadata(mainRoom.Metadata)
 	if err != nil {
 		return err
 	}
@@ -47,6 +48,7 @@ func (m *breakoutRoom) CreateBreakoutRooms(r *plugnmeet.CreateBreakoutRoomsReq)
 	meta.IsBreakoutRoom = true
 	meta.WelcomeMessage = r.WelcomeMsg
 	meta.ParentRoomId = r.RoomId
+	parentTitle := meta.RoomTitle
 
 	// disable few features
 	meta.RoomFeatures.BreakoutRoomFeatures.IsAllow = false
@@ -60,20 +62,42 @@ func (m *breakoutRoom) CreateBreakoutRooms(r *plugnmeet.CreateBreakoutRoomsReq)
 	meta.RoomFeatures.DisplayExternalLinkFeatures.IsActive = false
 	meta.RoomFeatures.ExternalMediaPlayerFeatures.IsActive = false
 
+	// livekit doesn't expose the node of a room, so we can only use
+	// the placement which was recorded during parent room creation
+	if placement == nil || (placement.NodeId == "" && placement.Region == "") {
+		parentOpts := m.roomService.LoadRoomOptions(r.RoomId)
+		placement = &parentOpts.RoomPlacement
+	}
+
 	e := make(map[string]bool)
+	keyTTL := m.roomService.RoomKeyTTL(r.RoomId)
+
+	for i, room := range r.Rooms {
+		// so that all the clients will see the same name
+		if room.Title == "" && naming != nil {
+			room.Title = naming.RoomTitle(i, len(r.Rooms), parentTitle)
+		}
 
-	for _, room := range r.Rooms {
 		bRoom := new(plugnmeet.CreateRoomReq)
 		bRoom.RoomId = fmt.Sprintf("%s:%s", r.RoomId, room.Id)
 		meta.RoomTitle = room.Title
 		bRoom.Metadata = meta
-		status, msg, _ := m.roomAuthModel.CreateRoom(bRoom)
+		opts := &RoomOptions{
+			RoomPlacement: *placement,
+		}
+		status, msg, _ := m.roomAuthModel.CreateRoom(bRoom, opts)
 
 		if !status {
 			log.Error(msg)
 			e[bRoom.RoomId] = true
 			continue
 		}
+		if opts.NodeId != "" {
+			m.roomService.AddRoomTimelineEvent(r.RoomId, &RoomTimelineEvent{
+				Type: "breakout_room_placed",
+				Msg:  bRoom.RoomId + "@" + opts.NodeId,
+			})
+		}
 
 		room.Duration = r.Duration
 		room.Created = uint64(time.Now().Unix())
@@ -90,6 +114,7 @@ func (m *breakoutRoom) CreateBreakoutRooms(r *plugnmeet.CreateBreakoutRoomsReq)
 		}
 		pp := m.rc.Pipeline()
 		pp.HSet(m.ctx, breakoutRoomKey+r.RoomId, val)
+		pp.Expire(m.ctx, breakoutRoomKey+r.RoomId, keyTTL)
 		_, err = pp.Exec(m.ctx)
 
 		if err != nil {
@@ -113,13 +138,13 @@ func (m *breakoutRoom) CreateBreakoutRooms(r *plugnmeet.CreateBreakoutRoomsReq)
 	}
 
 	// again here for update
-	origMeta := new(plugnmeet.RoomMetadata)
-	err = json.Unmarshal([]byte(mainRoom.Metadata), origMeta)
+	origMeta, err := ParseRoomMetadata(mainRoom.Metadata)
 	if err != nil {
 		return err
 	}
 	origMeta.RoomFeatures.BreakoutRoomFeatures.IsActive = true
 	_, err = m.roomService.UpdateRoomMetadataByStruct(r.RoomId, origMeta)
+	m.roomService.IncrRoomFeatureUsage(r.RoomId, RoomFeatureBreakoutRooms)
 
 	return err
 }
@@ -246,11 +271,15 @@ func (m *breakoutRoom) SendBreakoutRoomMsg(r *plugnmeet.BroadcastBreakoutRoomMsg
 }
 
 func (m *breakoutRoom) EndBreakoutRoom(r *plugnmeet.EndBreakoutRoomReq) error {
+	return m.endBreakoutRoom(r, RoomEndReasonEndedByModerator)
+}
+
+func (m *breakoutRoom) endBreakoutRoom(r *plugnmeet.EndBreakoutRoomReq, reason string) error {
 	_, err := m.fetchBreakoutRoom(r.RoomId, r.BreakoutRoomId)
 	if err != nil {
 		return err
 	}
-	_, err = m.roomService.EndRoom(r.BreakoutRoomId)
+	_, err = m.roomService.EndRoomWithReason(r.BreakoutRoomId, reason)
 	if err != nil {
 		log.Error(err)
 	}
@@ -268,16 +297,20 @@ func (m *breakoutRoom) EndBreakoutRoom(r *plugnmeet.EndBreakoutRoomReq) error {
 }
 
 func (m *breakoutRoom) EndBreakoutRooms(roomId string) error {
+	return m.endBreakoutRooms(roomId, RoomEndReasonEndedByModerator)
+}
+
+func (m *breakoutRoom) endBreakoutRooms(roomId, reason string) error {
 	rooms, err := m.fetchBreakoutRooms(roomId)
 	if err != nil {
 		return err
 	}
 
 	for _, r := range rooms {
-		_ = m.EndBreakoutRoom(&plugnmeet.EndBreakoutRoomReq{
+		_ = m.endBreakoutRoom(&plugnmeet.EndBreakoutRoomReq{
 			BreakoutRoomId: r.Id,
 			RoomId:         roomId,
-		})
+		}, reason)
 	}
 	return nil
 }
@@ -310,8 +343,7 @@ func (m *breakoutRoom) PostTaskAfterRoomEndWebhook(roomId, metadata string) erro
 	if metadata == "" {
 		return nil
 	}
-	meta := new(plugnmeet.RoomMetadata)
-	err := json.Unmarshal([]byte(metadata), meta)
+	meta, err := ParseRoomMetadata(metadata)
 	if err != nil {
 		return err
 	}
@@ -320,7 +352,7 @@ func (m *breakoutRoom) PostTaskAfterRoomEndWebhook(roomId, metadata string) erro
 		m.rc.HDel(m.ctx, breakoutRoomKey+meta.ParentRoomId, roomId)
 		_ = m.performPostHookTask(meta.ParentRoomId)
 	} else {
-		err = m.EndBreakoutRooms(roomId)
+		err = m.endBreakoutRooms(roomId, RoomEndReasonParentRoomEnded)
 		if err != nil {
 			return err
 		}
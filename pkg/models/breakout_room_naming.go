@@ -0,0 +1,71 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+const (
+	BreakoutRoomNamingNumbered     = "numbered"
+	BreakoutRoomNamingAlphabetical = "alphabetical"
+	BreakoutRoomNamingTemplate     = "template"
+
+	defaultBreakoutRoomNamingPrefix = "Group"
+)
+
+// BreakoutRoomNaming will be used to name breakout rooms those don't have title.
+// Template supports variables: {parent_title}, {number}, {letter}, {total}
+type BreakoutRoomNaming struct {
+	Scheme   string
+	Prefix   string
+	Template string
+}
+
+func (n *BreakoutRoomNaming) Validate() error {
+	switch n.Scheme {
+	case "", BreakoutRoomNamingNumbered, BreakoutRoomNamingAlphabetical:
+		return nil
+	case BreakoutRoomNamingTemplate:
+		if n.Template == "" {
+			return errors.New("naming_template required for template scheme")
+		}
+		return nil
+	}
+	return errors.New("invalid naming_scheme")
+}
+
+// RoomTitle will return title for the room of index (starting from 0)
+func (n *BreakoutRoomNaming) RoomTitle(index, total int, parentTitle string) string {
+	number := strconv.Itoa(index + 1)
+	letter := breakoutRoomLetter(index)
+	prefix := n.Prefix
+	if prefix == "" {
+		prefix = defaultBreakoutRoomNamingPrefix
+	}
+
+	switch n.Scheme {
+	case BreakoutRoomNamingAlphabetical:
+		return prefix + " " + letter
+	case BreakoutRoomNamingTemplate:
+		r := strings.NewReplacer(
+			"{parent_title}", parentTitle,
+			"{number}", number,
+			"{letter}", letter,
+			"{total}", strconv.Itoa(total),
+		)
+		return r.Replace(n.Template)
+	default:
+		return prefix + " " + number
+	}
+}
+
+// breakoutRoomLetter will convert index to A..Z, AA..AZ & so on
+func breakoutRoomLetter(index int) string {
+	var s []byte
+	for index >= 0 {
+		s = append([]byte{byte('A' + index%26)}, s...)
+		index = index/26 - 1
+	}
+	return string(s)
+}
@@ -0,0 +1,24 @@
+package models
+
+import "testing"
+
+func TestBreakoutRoomNaming_RoomTitle(t *testing.T) {
+	tests := []struct {
+		naming *BreakoutRoomNaming
+		index  int
+		want   string
+	}{
+		{&BreakoutRoomNaming{}, 0, "Group 1"},
+		{&BreakoutRoomNaming{Scheme: BreakoutRoomNamingNumbered, Prefix: "Room"}, 4, "Room 5"},
+		{&BreakoutRoomNaming{Scheme: BreakoutRoomNamingAlphabetical}, 1, "Group B"},
+		{&BreakoutRoomNaming{Scheme: BreakoutRoomNamingAlphabetical}, 26, "Group AA"},
+		{&BreakoutRoomNaming{Scheme: BreakoutRoomNamingTemplate, Template: "{parent_title} - {number}/{total} ({letter})"}, 2, "Math - 3/5 (C)"},
+	}
+
+	for _, tt := range tests {
+		got := tt.naming.RoomTitle(tt.index, 5, "Math")
+		if got != tt.want {
+			t.Errorf("RoomTitle() = %s, want %s", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package models
+
+// BroadcastBranding will be rendered by the recorder on top of the composite,
+// so that recordings & broadcasts carry branding of the tenant
+type BroadcastBranding struct {
+	LogoUrl   string `json:"logo_url,omitempty" validate:"omitempty,url,max=512"`
+	TitleText string `json:"title_text,omitempty" validate:"max=100"`
+	// BackgroundColor in hex, e.g. #1a1a1a
+	BackgroundColor string `json:"background_color,omitempty" validate:"omitempty,hexcolor"`
+}
+
+func (b *BroadcastBranding) isEmpty() bool {
+	return b.LogoUrl == "" && b.TitleText == "" && b.BackgroundColor == ""
+}
+
+// withBroadcastBranding will add branding of the room in the payload for the recorder.
+// Nothing will be added if the room doesn't have branding, so older recorders will keep working.
+func withBroadcastBranding(payload []byte, b *BroadcastBranding) []byte {
+	if b == nil || b.isEmpty() {
+		return payload
+	}
+	return withRecorderPayloadField(payload, "branding", b)
+}
@@ -0,0 +1,105 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/livekit/protocol/livekit"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+)
+
+// default encoder settings of the broadcast, same as 720p preset of livekit egress
+const (
+	defaultBroadcastWidth        = 1280
+	defaultBroadcastHeight       = 720
+	defaultBroadcastFramerate    = 30
+	defaultBroadcastVideoBitrate = 3000
+	defaultBroadcastAudioBitrate = 128
+)
+
+// BroadcastEncoding is encoder settings of the broadcast,
+// so that it can be tuned for the destination platform.
+// Those can't be sent as part of plugnmeet.RecordingReq,
+// so client will send as query of /api/rtmp
+type BroadcastEncoding struct {
+	Width     int `json:"width,omitempty" query:"width" validate:"omitempty,min=320,max=3840"`
+	Height    int `json:"height,omitempty" query:"height" validate:"omitempty,min=240,max=2160"`
+	Framerate int `json:"framerate,omitempty" query:"framerate" validate:"omitempty,min=15,max=60"`
+	// VideoBitrate & AudioBitrate in kbps
+	VideoBitrate int `json:"video_bitrate,omitempty" query:"video_bitrate" validate:"omitempty,min=500,max=20000"`
+	AudioBitrate int `json:"audio_bitrate,omitempty" query:"audio_bitrate" validate:"omitempty,min=32,max=320"`
+}
+
+// ResolveBroadcastEncoding will fill missing values from config or defaults & validate the combination
+func ResolveBroadcastEncoding(e *BroadcastEncoding) (*BroadcastEncoding, error) {
+	conf := config.AppCnf.RecorderInfo.Rtmp.Encoding
+	out := &BroadcastEncoding{
+		Width:        conf.Width,
+		Height:       conf.Height,
+		Framerate:    conf.Framerate,
+		VideoBitrate: conf.VideoBitrate,
+		AudioBitrate: conf.AudioBitrate,
+	}
+	if e != nil {
+		if e.Width > 0 || e.Height > 0 {
+			out.Width, out.Height = e.Width, e.Height
+		}
+		if e.Framerate > 0 {
+			out.Framerate = e.Framerate
+		}
+		if e.VideoBitrate > 0 {
+			out.VideoBitrate = e.VideoBitrate
+		}
+		if e.AudioBitrate > 0 {
+			out.AudioBitrate = e.AudioBitrate
+		}
+	}
+
+	if out.Width == 0 && out.Height == 0 {
+		out.Width, out.Height = defaultBroadcastWidth, defaultBroadcastHeight
+	}
+	if out.Framerate == 0 {
+		out.Framerate = defaultBroadcastFramerate
+	}
+	if out.VideoBitrate == 0 {
+		out.VideoBitrate = defaultBroadcastVideoBitrate
+	}
+	if out.AudioBitrate == 0 {
+		out.AudioBitrate = defaultBroadcastAudioBitrate
+	}
+
+	if (out.Width > 0) != (out.Height > 0) {
+		return nil, errors.New("both width & height are required")
+	}
+	if out.Width%2 != 0 || out.Height%2 != 0 {
+		return nil, errors.New("width & height must be even numbers")
+	}
+	if max := conf.MaxVideoBitrate; max > 0 && out.VideoBitrate > max {
+		return nil, errors.New(fmt.Sprintf("maximum video bitrate is %d kbps", max))
+	}
+	// too low bitrate for the resolution will make the stream unwatchable,
+	// 0.03 bits per pixel is the lowest acceptable value for h264
+	if minBitrate := out.Width * out.Height * out.Framerate * 3 / 100 / 1000; out.VideoBitrate < minBitrate {
+		return nil, errors.New(fmt.Sprintf("video bitrate should be at least %d kbps for %dx%d@%d", minBitrate, out.Width, out.Height, out.Framerate))
+	}
+
+	return out, nil
+}
+
+// egressEncodingOptions will convert the settings for livekit egress
+func (e *BroadcastEncoding) egressEncodingOptions() *livekit.EncodingOptions {
+	return &livekit.EncodingOptions{
+		Width:        int32(e.Width),
+		Height:       int32(e.Height),
+		Framerate:    int32(e.Framerate),
+		VideoBitrate: int32(e.VideoBitrate),
+		AudioBitrate: int32(e.AudioBitrate),
+	}
+}
+
+// withBroadcastEncoding will add encoder settings in the payload for the recorder
+func withBroadcastEncoding(payload []byte, e *BroadcastEncoding) []byte {
+	if e == nil {
+		return payload
+	}
+	return withRecorderPayloadField(payload, "encoding", e)
+}
@@ -0,0 +1,263 @@
+package models
+
+import (
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	BroadcastStateConnected    = "connected"
+	BroadcastStateReconnecting = "reconnecting"
+	BroadcastStateDisconnected = "disconnected"
+
+	// broadcastHealthKey keeps the last stats of the broadcast reported by the recorder
+	broadcastHealthKey         = "pnm:broadcast_health:"
+	broadcastHealthLock        = "pnm:broadcast_health_lock"
+	broadcastUnhealthyEvent    = "broadcast_unhealthy"
+	broadcastDisconnectedEvent = "broadcast_disconnected"
+	defaultBroadcastStaleAfter = 30 * time.Second
+)
+
+// BroadcastStatsReq should be sent by the recorder periodically while broadcasting
+type BroadcastStatsReq struct {
+	RecorderId string `json:"recorder_id" validate:"required"`
+	RoomId     string `json:"room_id" validate:"required,require-valid-Id"`
+	RoomSid    string `json:"room_sid" validate:"required"`
+	// Bitrate in kbps
+	Bitrate int     `json:"bitrate" validate:"min=0"`
+	Fps     float64 `json:"fps" validate:"min=0"`
+	// TotalFrames & DroppedFrames are counted from the beginning of the broadcast
+	TotalFrames   int64  `json:"total_frames" validate:"min=0"`
+	DroppedFrames int64  `json:"dropped_frames" validate:"min=0"`
+	State         string `json:"state" validate:"required,oneof=connected reconnecting disconnected"`
+	Error         string `json:"error"`
+}
+
+type BroadcastHealthReq struct {
+	RoomId string `json:"room_id" validate:"required,require-valid-Id"`
+}
+
+type BroadcastHealth struct {
+	RoomId        string  `json:"room_id"`
+	RoomSid       string  `json:"room_sid"`
+	RecorderId    string  `json:"recorder_id,omitempty"`
+	State         string  `json:"state"`
+	Bitrate       int     `json:"bitrate"`
+	Fps           float64 `json:"fps"`
+	TotalFrames   int64   `json:"total_frames"`
+	DroppedFrames int64   `json:"dropped_frames"`
+	// DroppedFramesPercent since the previous report
+	DroppedFramesPercent float64 `json:"dropped_frames_percent"`
+	Healthy              bool    `json:"healthy"`
+	// Reasons why the broadcast is unhealthy
+	Reasons   []string `json:"reasons,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	UpdatedAt int64    `json:"updated_at"`
+	// Destinations are used when broadcasting by livekit egress,
+	// egress doesn't report bitrate & frames.
+	Destinations []*RtmpDestination `json:"destinations,omitempty"`
+}
+
+// broadcastHealthNotifyEvent will add the health with common notify event
+type broadcastHealthNotifyEvent struct {
+	*plugnmeet.CommonNotifyEvent
+	Health *BroadcastHealth `json:"health"`
+}
+
+func broadcastStaleAfter() time.Duration {
+	if d := config.AppCnf.RecorderInfo.Rtmp.StatsStaleAfter; d > 0 {
+		return d
+	}
+	return defaultBroadcastStaleAfter
+}
+
+// ReportBroadcastStats will store stats of the broadcast & send webhook
+// when it has become unhealthy or disconnected
+func (rm *recordingModel) ReportBroadcastStats(r *BroadcastStatsReq) (*BroadcastHealth, error) {
+	room, _ := NewRoomModel().GetRoomInfo(r.RoomId, r.RoomSid, 1)
+	if room.Id == 0 {
+		return nil, errors.New("notifications.room-not-active")
+	}
+
+	prev, _ := rm.loadBroadcastHealth(r.RoomSid)
+	h := &BroadcastHealth{
+		RoomId:        r.RoomId,
+		RoomSid:       r.RoomSid,
+		RecorderId:    r.RecorderId,
+		State:         r.State,
+		Bitrate:       r.Bitrate,
+		Fps:           r.Fps,
+		TotalFrames:   r.TotalFrames,
+		DroppedFrames: r.DroppedFrames,
+		Error:         r.Error,
+		UpdatedAt:     time.Now().Unix(),
+	}
+
+	totalFrames, droppedFrames := r.TotalFrames, r.DroppedFrames
+	if prev != nil && prev.TotalFrames <= r.TotalFrames && prev.DroppedFrames <= r.DroppedFrames {
+		totalFrames -= prev.TotalFrames
+		droppedFrames -= prev.DroppedFrames
+	}
+	if totalFrames > 0 {
+		h.DroppedFramesPercent = float64(droppedFrames) * 100 / float64(totalFrames)
+	}
+
+	conf := rm.app.RecorderInfo.Rtmp
+	if h.State != BroadcastStateConnected {
+		h.Reasons = append(h.Reasons, "state "+h.State)
+	} else {
+		if conf.MinBitrate > 0 && h.Bitrate < conf.MinBitrate {
+			h.Reasons = append(h.Reasons, "low bitrate")
+		}
+		if conf.MaxDroppedFramesPercent > 0 && h.DroppedFramesPercent > conf.MaxDroppedFramesPercent {
+			h.Reasons = append(h.Reasons, "dropped frames")
+		}
+	}
+	h.Healthy = len(h.Reasons) == 0
+	rm.saveBroadcastHealth(h)
+
+	// webhook will be sent once until the state changes
+	switch {
+	case h.State == BroadcastStateDisconnected:
+		if prev == nil || prev.State != BroadcastStateDisconnected {
+			go rm.sendBroadcastHealthWebhook(broadcastDisconnectedEvent, h)
+			go rm.startBroadcastReconnect(h.RoomId, h.RoomSid, h.RecorderId)
+		}
+	case !h.Healthy:
+		if prev == nil || prev.Healthy || prev.State == BroadcastStateDisconnected {
+			go rm.sendBroadcastHealthWebhook(broadcastUnhealthyEvent, h)
+		}
+	}
+
+	return h, nil
+}
+
+// GetBroadcastHealth will return health of the running broadcast of the room
+func (rm *recordingModel) GetBroadcastHealth(roomId string) (*BroadcastHealth, error) {
+	room, _ := NewRoomModel().GetRoomInfo(roomId, "", 1)
+	if room.Id == 0 {
+		return nil, errors.New("notifications.room-not-active")
+	}
+	if room.IsActiveRTMP == 0 {
+		return nil, errors.New("RTMP broadcasting not running")
+	}
+
+	if h, err := rm.loadBroadcastHealth(room.Sid); err == nil {
+		return h, nil
+	}
+
+	// broadcasting by livekit egress
+	destinations := rm.loadRtmpDestinations(room.Sid)
+	if len(destinations) == 0 {
+		return nil, errors.New("no stats received from the broadcaster yet")
+	}
+	h := &BroadcastHealth{
+		RoomId:    room.RoomId,
+		RoomSid:   room.Sid,
+		State:     BroadcastStateDisconnected,
+		UpdatedAt: time.Now().Unix(),
+	}
+	for _, d := range destinations {
+		if d.Status == RtmpDestinationStatusActive {
+			h.State = BroadcastStateConnected
+		} else if d.Status == RtmpDestinationStatusFailed {
+			h.Reasons = append(h.Reasons, "destination "+d.Name+" failed")
+		}
+		h.Destinations = append(h.Destinations, d.masked())
+	}
+	h.Healthy = h.State == BroadcastStateConnected && len(h.Reasons) == 0
+
+	return h, nil
+}
+
+func (rm *recordingModel) loadBroadcastHealth(roomSid string) (*BroadcastHealth, error) {
+	result, err := rm.rds.Get(rm.ctx, broadcastHealthKey+roomSid).Result()
+	if err == redis.Nil {
+		return nil, errors.New("no broadcast health found")
+	} else if err != nil {
+		return nil, err
+	}
+
+	h := new(BroadcastHealth)
+	if err = json.Unmarshal([]byte(result), h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (rm *recordingModel) saveBroadcastHealth(h *BroadcastHealth) {
+	marshal, err := json.Marshal(h)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	if err = rm.rds.Set(rm.ctx, broadcastHealthKey+h.RoomSid, marshal, trackRecordingTTL).Err(); err != nil {
+		log.Errorln(err)
+	}
+}
+
+// deleteBroadcastHealth should be called when the broadcast has ended
+func (rm *recordingModel) deleteBroadcastHealth(roomSid string) {
+	if err := rm.rds.Del(rm.ctx, broadcastHealthKey+roomSid).Err(); err != nil {
+		log.Errorln(err)
+	}
+}
+
+func (rm *recordingModel) sendBroadcastHealthWebhook(event string, h *BroadcastHealth) {
+	msg := &broadcastHealthNotifyEvent{
+		CommonNotifyEvent: &plugnmeet.CommonNotifyEvent{
+			Event: &event,
+			Room: &plugnmeet.NotifyEventRoom{
+				Sid:    &h.RoomSid,
+				RoomId: &h.RoomId,
+			},
+		},
+		Health: h,
+	}
+	if err := NewWebhookNotifier().Notify(h.RoomSid, msg); err != nil {
+		log.Errorln(err)
+	}
+}
+
+// CheckBroadcastHealth will consider the broadcast disconnected
+// if the recorder hasn't reported stats for a while.
+// Only one server will perform it at a time.
+func (s *scheduler) CheckBroadcastHealth() {
+	locked, err := s.rc.SetNX(s.ctx, broadcastHealthLock, time.Now().Unix(), time.Minute).Result()
+	if err != nil || !locked {
+		return
+	}
+	defer s.rc.Del(s.ctx, broadcastHealthLock)
+
+	rm := NewRecordingModel()
+	stale := time.Now().Add(-broadcastStaleAfter()).Unix()
+	iter := s.rc.Scan(s.ctx, 0, broadcastHealthKey+"*", 100).Iterator()
+	for iter.Next(s.ctx) {
+		result, err := s.rc.Get(s.ctx, iter.Val()).Result()
+		if err != nil {
+			continue
+		}
+		h := new(BroadcastHealth)
+		if err = json.Unmarshal([]byte(result), h); err != nil {
+			continue
+		}
+		if h.State == BroadcastStateDisconnected || h.UpdatedAt >= stale {
+			continue
+		}
+
+		h.State = BroadcastStateDisconnected
+		h.Healthy = false
+		h.Reasons = []string{"no stats received from the broadcaster"}
+		rm.saveBroadcastHealth(h)
+		rm.sendBroadcastHealthWebhook(broadcastDisconnectedEvent, h)
+		rm.startBroadcastReconnect(h.RoomId, h.RoomSid, h.RecorderId)
+	}
+	if err = iter.Err(); err != nil {
+		log.Errorln(err)
+	}
+}
@@ -0,0 +1,260 @@
+package models
+
+import (
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+const (
+	// broadcastReconnectKey keeps the reconnection state of the broadcast by the recorder
+	broadcastReconnectKey  = "pnm:broadcast_reconnect:"
+	broadcastReconnectLock = "pnm:broadcast_reconnect_lock"
+	// broadcastReconnectEndedTTL to ignore late responses of the recorder after reconnection has ended
+	broadcastReconnectEndedTTL    = time.Minute
+	defaultBroadcastReconnectWait = 2 * time.Second
+	maxBroadcastReconnectWait     = 30 * time.Second
+)
+
+type broadcastReconnect struct {
+	RoomId        string             `json:"room_id"`
+	RoomSid       string             `json:"room_sid"`
+	RtmpUrl       string             `json:"rtmp_url"`
+	CustomDesign  string             `json:"custom_design,omitempty"`
+	Encoding      *BroadcastEncoding `json:"encoding,omitempty"`
+	Attempts      int                `json:"attempts"`
+	StartedAt     int64              `json:"started_at"`
+	NextAttemptAt int64              `json:"next_attempt_at"`
+	Ended         bool               `json:"ended,omitempty"`
+}
+
+// nextWait will double the wait after each attempt
+func (b *broadcastReconnect) nextWait(initial time.Duration) time.Duration {
+	wait := initial
+	for i := 0; i < b.Attempts && wait < maxBroadcastReconnectWait; i++ {
+		wait *= 2
+	}
+	if wait > maxBroadcastReconnectWait {
+		wait = maxBroadcastReconnectWait
+	}
+	return wait
+}
+
+func (rm *recordingModel) broadcastReconnectWait() time.Duration {
+	if d := rm.app.RecorderInfo.Rtmp.ReconnectInitialDelay; d > 0 {
+		return d
+	}
+	return defaultBroadcastReconnectWait
+}
+
+// startBroadcastReconnect will stop the dropped broadcast & retry it with backoff
+// until the reconnect window has passed. Returns false if reconnect isn't enabled.
+func (rm *recordingModel) startBroadcastReconnect(roomId, roomSid, recorderId string) bool {
+	if rm.app.RecorderInfo.Rtmp.ReconnectWindow <= 0 || recorderId == "" || recorderId == trackRecorderId {
+		return false
+	}
+	if _, err := rm.loadBroadcastReconnect(roomSid); err == nil {
+		// already reconnecting or has ended
+		return true
+	}
+
+	result, err := rm.rds.HGet(rm.ctx, recorderJobsKey+recorderId, recorderJobField(plugnmeet.RecordingTasks_START_RTMP, roomSid)).Result()
+	if err != nil {
+		return false
+	}
+	job := new(recorderJob)
+	if err = json.Unmarshal([]byte(result), job); err != nil || job.RtmpUrl == "" {
+		return false
+	}
+
+	now := time.Now()
+	b := &broadcastReconnect{
+		RoomId:        roomId,
+		RoomSid:       roomSid,
+		RtmpUrl:       job.RtmpUrl,
+		CustomDesign:  job.CustomDesign,
+		Encoding:      job.Encoding,
+		StartedAt:     now.Unix(),
+		NextAttemptAt: now.Add(rm.broadcastReconnectWait()).Unix(),
+	}
+	rm.saveBroadcastReconnect(b)
+
+	// ending response of the recorder will be ignored while reconnecting
+	if err = rm.SendMsgToRecorder(plugnmeet.RecordingTasks_STOP_RTMP, roomId, roomSid, nil); err != nil {
+		log.Errorln(err)
+	}
+
+	err = NewDataMessageModel().SendDataMessage(&plugnmeet.DataMessageReq{
+		MsgBodyType: plugnmeet.DataMsgBodyType_ALERT,
+		Msg:         "notifications.rtmp-reconnecting",
+		RoomId:      roomId,
+	})
+	if err != nil {
+		log.Errorln(err)
+	}
+
+	return true
+}
+
+// handleBroadcastReconnectResp will return true if the response of the recorder
+// was for reconnection, so that it won't be handled as a new broadcast
+func (rm *recordingModel) handleBroadcastReconnectResp(r *plugnmeet.RecorderToPlugNmeet) bool {
+	switch r.Task {
+	case plugnmeet.RecordingTasks_START_RTMP, plugnmeet.RecordingTasks_END_RTMP:
+	default:
+		return false
+	}
+	if r.From == "plugnmeet" {
+		// sent by us
+		return false
+	}
+
+	b, err := rm.loadBroadcastReconnect(r.RoomSid)
+	if err != nil {
+		if r.Task == plugnmeet.RecordingTasks_END_RTMP && !r.Status {
+			// connection has dropped & recorder has given up
+			return rm.startBroadcastReconnect(r.RoomId, r.RoomSid, r.RecorderId)
+		}
+		return false
+	}
+
+	if !b.Ended && r.Task == plugnmeet.RecordingTasks_START_RTMP && r.Status {
+		rm.broadcastReconnected(r.RoomId, r.RoomSid)
+	}
+	// failed attempt will be retried by the scheduler
+	return true
+}
+
+func (rm *recordingModel) broadcastReconnected(roomId, roomSid string) {
+	if err := rm.rds.Del(rm.ctx, broadcastReconnectKey+roomSid).Err(); err != nil {
+		log.Errorln(err)
+	}
+
+	err := NewDataMessageModel().SendDataMessage(&plugnmeet.DataMessageReq{
+		MsgBodyType: plugnmeet.DataMsgBodyType_INFO,
+		Msg:         "notifications.rtmp-reconnected",
+		RoomId:      roomId,
+	})
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+func (rm *recordingModel) loadBroadcastReconnect(roomSid string) (*broadcastReconnect, error) {
+	result, err := rm.rds.Get(rm.ctx, broadcastReconnectKey+roomSid).Result()
+	if err == redis.Nil {
+		return nil, errors.New("broadcast isn't reconnecting")
+	} else if err != nil {
+		return nil, err
+	}
+
+	b := new(broadcastReconnect)
+	if err = json.Unmarshal([]byte(result), b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (rm *recordingModel) saveBroadcastReconnect(b *broadcastReconnect) {
+	marshal, err := json.Marshal(b)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	ttl := trackRecordingTTL
+	if b.Ended {
+		ttl = broadcastReconnectEndedTTL
+	}
+	if err = rm.rds.Set(rm.ctx, broadcastReconnectKey+b.RoomSid, marshal, ttl).Err(); err != nil {
+		log.Errorln(err)
+	}
+}
+
+// endBroadcastReconnect will stop the running attempt & end the broadcast
+func (rm *recordingModel) endBroadcastReconnect(b *broadcastReconnect, status bool, msg string) {
+	b.Ended = true
+	rm.saveBroadcastReconnect(b)
+
+	if err := rm.SendMsgToRecorder(plugnmeet.RecordingTasks_STOP_RTMP, b.RoomId, b.RoomSid, nil); err != nil {
+		log.Errorln(err)
+	}
+	rm.HandleRecorderResp(&plugnmeet.RecorderToPlugNmeet{
+		From:    "plugnmeet",
+		Status:  status,
+		Task:    plugnmeet.RecordingTasks_END_RTMP,
+		Msg:     msg,
+		RoomId:  b.RoomId,
+		RoomSid: b.RoomSid,
+	})
+}
+
+// CancelBroadcastReconnect should be used when the broadcast was stopped by the user.
+// Returns false if the broadcast isn't reconnecting.
+func (rm *recordingModel) CancelBroadcastReconnect(roomSid string) bool {
+	b, err := rm.loadBroadcastReconnect(roomSid)
+	if err != nil || b.Ended {
+		return false
+	}
+	rm.endBroadcastReconnect(b, true, "success")
+	return true
+}
+
+// retryBroadcast will dispatch the broadcast again or end it when the window has passed
+func (rm *recordingModel) retryBroadcast(b *broadcastReconnect) {
+	room, _ := NewRoomModel().GetRoomInfo("", b.RoomSid, 1)
+	if room == nil || room.Id == 0 {
+		rm.rds.Del(rm.ctx, broadcastReconnectKey+b.RoomSid)
+		return
+	}
+
+	now := time.Now()
+	if now.After(time.Unix(b.StartedAt, 0).Add(rm.app.RecorderInfo.Rtmp.ReconnectWindow)) {
+		rm.endBroadcastReconnect(b, false, "broadcast has failed after reconnect attempts")
+		return
+	}
+	if now.Unix() < b.NextAttemptAt {
+		return
+	}
+
+	b.Attempts++
+	b.NextAttemptAt = now.Add(b.nextWait(rm.broadcastReconnectWait())).Unix()
+	rm.saveBroadcastReconnect(b)
+
+	rm.RecordingReq = &plugnmeet.RecordingReq{
+		Task:         plugnmeet.RecordingTasks_START_RTMP,
+		Sid:          b.RoomSid,
+		CustomDesign: &b.CustomDesign,
+	}
+	rm.Encoding = b.Encoding
+	if err := rm.SendMsgToRecorder(plugnmeet.RecordingTasks_START_RTMP, b.RoomId, b.RoomSid, &b.RtmpUrl); err != nil {
+		// will try again
+		log.Errorln(err)
+	}
+}
+
+// CheckBroadcastReconnects will retry dropped broadcasts.
+// Only one server will perform it at a time.
+func (s *scheduler) CheckBroadcastReconnects() {
+	locked, err := s.rc.SetNX(s.ctx, broadcastReconnectLock, time.Now().Unix(), time.Minute).Result()
+	if err != nil || !locked {
+		return
+	}
+	defer s.rc.Del(s.ctx, broadcastReconnectLock)
+
+	iter := s.rc.Scan(s.ctx, 0, broadcastReconnectKey+"*", 100).Iterator()
+	for iter.Next(s.ctx) {
+		rm := NewRecordingModel()
+		b, err := rm.loadBroadcastReconnect(strings.TrimPrefix(iter.Val(), broadcastReconnectKey))
+		if err != nil || b.Ended {
+			continue
+		}
+		rm.retryBroadcast(b)
+	}
+	if err = iter.Err(); err != nil {
+		log.Errorln(err)
+	}
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"errors"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	broadcastPausedEvent  = "broadcast_paused"
+	broadcastResumedEvent = "broadcast_resumed"
+)
+
+// BroadcastSlate will be rendered by the recorder instead of the room
+// while the broadcast is paused, so the stream won't be stopped
+type BroadcastSlate struct {
+	ImageUrl string `json:"image_url,omitempty"`
+	Message  string `json:"message,omitempty"`
+	PausedAt int64  `json:"paused_at"`
+}
+
+// PauseBroadcastReq values are optional, config will be used for empty values
+type PauseBroadcastReq struct {
+	ImageUrl string `json:"image_url" validate:"omitempty,url,max=512"`
+	Message  string `json:"message" validate:"max=255"`
+}
+
+type broadcastSlateUpdated struct {
+	// Slate nil means broadcast has resumed
+	Slate *BroadcastSlate `json:"slate"`
+}
+
+// broadcastSlateNotifyEvent will add the slate with common notify event
+type broadcastSlateNotifyEvent struct {
+	*plugnmeet.CommonNotifyEvent
+	Slate *BroadcastSlate `json:"slate,omitempty"`
+}
+
+// PauseBroadcast will switch rtmp & hls outputs to the slate
+func (rm *recordingModel) PauseBroadcast(roomId string, r *PauseBroadcastReq) (*BroadcastSlate, error) {
+	room, _ := NewRoomModel().GetRoomInfo(roomId, "", 1)
+	if room.Id == 0 {
+		return nil, errors.New("notifications.room-not-active")
+	}
+	if !rm.isBroadcasting(room.Sid, room.IsActiveRTMP) {
+		return nil, errors.New("broadcasting not running")
+	}
+
+	opts := rm.roomService.LoadRoomOptions(roomId)
+	if opts.BroadcastSlate != nil {
+		return nil, errors.New("broadcast already paused")
+	}
+
+	conf := rm.app.RecorderInfo.Slate
+	slate := &BroadcastSlate{
+		ImageUrl: conf.ImageUrl,
+		Message:  conf.Message,
+		PausedAt: time.Now().Unix(),
+	}
+	if r.ImageUrl != "" {
+		slate.ImageUrl = r.ImageUrl
+	}
+	if r.Message != "" {
+		slate.Message = r.Message
+	}
+
+	opts.BroadcastSlate = slate
+	if err := rm.updateBroadcastSlate(roomId, opts); err != nil {
+		return nil, err
+	}
+	if conf.EgressLayout != "" {
+		rm.updateEgressLayouts(room.Sid, conf.EgressLayout, conf.EgressLayout)
+	}
+
+	go rm.sendBroadcastSlateWebhook(broadcastPausedEvent, room.RoomId, room.Sid, slate)
+	return slate, nil
+}
+
+// ResumeBroadcast will switch rtmp & hls outputs back to the room
+func (rm *recordingModel) ResumeBroadcast(roomId string) error {
+	room, _ := NewRoomModel().GetRoomInfo(roomId, "", 1)
+	if room.Id == 0 {
+		return errors.New("notifications.room-not-active")
+	}
+
+	opts := rm.roomService.LoadRoomOptions(roomId)
+	if opts.BroadcastSlate == nil {
+		return errors.New("broadcast isn't paused")
+	}
+	opts.BroadcastSlate = nil
+	if err := rm.updateBroadcastSlate(roomId, opts); err != nil {
+		return err
+	}
+	if rm.app.RecorderInfo.Slate.EgressLayout != "" {
+		rm.updateEgressLayouts(room.Sid, rm.egressLayout(roomId, rm.app.RecorderInfo.Rtmp.Layout), rm.egressLayout(roomId, rm.app.RecorderInfo.Hls.Layout))
+	}
+
+	go rm.sendBroadcastSlateWebhook(broadcastResumedEvent, room.RoomId, room.Sid, nil)
+	return nil
+}
+
+// clearBroadcastSlate should be called when the broadcast has ended,
+// so that next broadcast won't start with the slate
+func (rm *recordingModel) clearBroadcastSlate(roomId, roomSid string) {
+	opts := rm.roomService.LoadRoomOptions(roomId)
+	if opts.BroadcastSlate == nil {
+		return
+	}
+	room, _ := NewRoomModel().GetRoomInfo(roomId, roomSid, 1)
+	if room.Id == 0 || rm.isBroadcasting(roomSid, room.IsActiveRTMP) {
+		return
+	}
+	opts.BroadcastSlate = nil
+	if err := rm.updateBroadcastSlate(roomId, opts); err != nil {
+		log.Errorln(err)
+	}
+}
+
+func (rm *recordingModel) isBroadcasting(roomSid string, isActiveRtmp int) bool {
+	if isActiveRtmp == 1 {
+		return true
+	}
+	s, err := rm.loadHlsStream(roomSid)
+	return err == nil && s.Status == HlsStatusActive
+}
+
+// updateBroadcastSlate will save the options & update metadata,
+// so that the recorder will render slate from room metadata
+func (rm *recordingModel) updateBroadcastSlate(roomId string, opts *RoomOptions) error {
+	_, meta, err := rm.roomService.LoadRoomWithMetadata(roomId)
+	if err != nil {
+		return err
+	}
+	if err = rm.roomService.SaveRoomOptions(roomId, opts); err != nil {
+		return err
+	}
+	if _, err = rm.roomService.UpdateRoomMetadataByStruct(roomId, meta); err != nil {
+		return err
+	}
+
+	broadcastSystemMsg(roomId, DataMsgBodyType_BROADCAST_SLATE_UPDATED, &broadcastSlateUpdated{
+		Slate: opts.BroadcastSlate,
+	})
+	return nil
+}
+
+func (rm *recordingModel) sendBroadcastSlateWebhook(event, roomId, roomSid string, slate *BroadcastSlate) {
+	msg := &broadcastSlateNotifyEvent{
+		CommonNotifyEvent: &plugnmeet.CommonNotifyEvent{
+			Event: &event,
+			Room: &plugnmeet.NotifyEventRoom{
+				Sid:    &roomSid,
+				RoomId: &roomId,
+			},
+		},
+		Slate: slate,
+	}
+	if err := NewWebhookNotifier().Notify(roomSid, msg); err != nil {
+		log.Errorln(err)
+	}
+}
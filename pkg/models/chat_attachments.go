@@ -0,0 +1,237 @@
+package models
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ManageFilePurposeChat = "chat"
+
+	// chatAttachmentsKey keeps attachments of the session, field is id of the file
+	chatAttachmentsKey = "pnm:chat_attachments:"
+	// chatAttachmentsDir inside the session directory of uploaded files
+	chatAttachmentsDir = "chat"
+)
+
+type ChatAttachment struct {
+	FileId   string `json:"file_id"`
+	FileName string `json:"file_name"`
+	FileSize int64  `json:"file_size"`
+	MimeType string `json:"mime_type"`
+	// Location is path inside upload directory or remote location of the object
+	Location  string `json:"-"`
+	Url       string `json:"url"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// storedChatAttachment is used to keep Location in redis, as it isn't part of the response
+type storedChatAttachment struct {
+	*ChatAttachment
+	Location string `json:"location"`
+}
+
+func chatAttachmentSettings() (maxSize uint64, allowedTypes []string) {
+	conf := config.AppCnf.UploadFileSettings
+	maxSize, allowedTypes = conf.MaxSize, conf.AllowedTypes
+	if conf.ChatAttachment.MaxSize > 0 {
+		maxSize = conf.ChatAttachment.MaxSize
+	}
+	if len(conf.ChatAttachment.AllowedTypes) > 0 {
+		allowedTypes = conf.ChatAttachment.AllowedTypes
+	}
+	return maxSize, allowedTypes
+}
+
+// UploadChatAttachment will validate & store the file in chat directory of the session
+// or in S3. Download url will be valid until the session ends.
+func (m *ManageFile) UploadChatAttachment(c *fiber.Ctx) (*ChatAttachment, error) {
+	room, _ := NewRoomModel().GetRoomInfo(m.RoomId, m.Sid, 1)
+	if room.Id == 0 {
+		return nil, errors.New("room isn't running")
+	}
+
+	reqf, err := c.FormFile("file")
+	if err != nil {
+		return nil, err
+	}
+	maxSize, allowedTypes := chatAttachmentSettings()
+	if reqf.Size > int64(maxSize*1024*1024) {
+		return nil, errors.New(fmt.Sprintf("file is too big. Max allow %dMB", maxSize))
+	}
+
+	file, err := reqf.Open()
+	if err != nil {
+		return nil, err
+	}
+	mtype, err := mimetype.DetectReader(file)
+	_ = file.Close()
+	if err != nil {
+		return nil, err
+	}
+	ext := strings.TrimPrefix(mtype.Extension(), ".")
+	allows := false
+	for _, t := range allowedTypes {
+		if ext == t {
+			allows = true
+			break
+		}
+	}
+	if !allows {
+		if ext == "" {
+			return nil, errors.New("invalid file")
+		}
+		return nil, errors.New(mtype.Extension() + " file type not allow")
+	}
+
+	a := &ChatAttachment{
+		FileId:   uuid.NewString(),
+		FileName: filepath.Base(reqf.Filename),
+		FileSize: reqf.Size,
+		MimeType: mtype.String(),
+	}
+	a.Location = fmt.Sprintf("%s/%s/%s.%s", m.Sid, chatAttachmentsDir, a.FileId, ext)
+	localFile := fmt.Sprintf("%s/%s", m.uploadFileSettings.Path, a.Location)
+	if err = os.MkdirAll(filepath.Dir(localFile), os.ModePerm); err != nil {
+		return nil, err
+	}
+	if err = c.SaveFile(reqf, localFile); err != nil {
+		return nil, err
+	}
+
+	if m.uploadFileSettings.ChatAttachment.UseS3 {
+		s, err := getRecordingStorage(RecordingStorageS3)
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		defer cancel()
+		location, err := s.Upload(ctx, localFile, a.Location)
+		if err != nil {
+			return nil, err
+		}
+		a.Location = location
+		_ = os.Remove(localFile)
+	}
+
+	ttl := m.rs.RoomKeyTTL(m.RoomId)
+	a.ExpiresAt = time.Now().Add(ttl).Unix()
+	a.Url = chatAttachmentUrl(c.BaseURL(), m.Sid, a.FileId, a.ExpiresAt)
+
+	marshal, err := json.Marshal(&storedChatAttachment{
+		ChatAttachment: a,
+		Location:       a.Location,
+	})
+	if err != nil {
+		return nil, err
+	}
+	pp := m.rs.rc.Pipeline()
+	pp.HSet(m.rs.ctx, chatAttachmentsKey+m.Sid, a.FileId, marshal)
+	pp.Expire(m.rs.ctx, chatAttachmentsKey+m.Sid, ttl)
+	if _, err = pp.Exec(m.rs.ctx); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func chatAttachmentUrl(baseUrl, roomSid, fileId string, expiresAt int64) string {
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expiresAt, 10))
+	q.Set("sig", signChatAttachmentUrl(roomSid, fileId, expiresAt))
+	return fmt.Sprintf("%s/download/chatAttachment/%s/%s?%s", baseUrl, url.PathEscape(roomSid), url.PathEscape(fileId), q.Encode())
+}
+
+// signChatAttachmentUrl will return HMAC-SHA256 of room sid, file id & expiry
+func signChatAttachmentUrl(roomSid, fileId string, expiresAt int64) string {
+	h := hmac.New(sha256.New, []byte(config.AppCnf.Client.Secret))
+	h.Write([]byte(roomSid + ":" + fileId + ":" + strconv.FormatInt(expiresAt, 10)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// VerifyChatAttachmentUrl will validate signature, expiry & the session.
+// It will return local file path or remote url.
+func VerifyChatAttachmentUrl(roomSid, fileId, expires, sig string) (a *ChatAttachment, file string, remote bool, err error) {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return nil, "", false, errors.New("invalid expires")
+	}
+	remaining := time.Until(time.Unix(expiresAt, 0))
+	if remaining <= 0 {
+		return nil, "", false, errors.New("url has expired")
+	}
+	if !hmac.Equal([]byte(signChatAttachmentUrl(roomSid, fileId, expiresAt)), []byte(sig)) {
+		return nil, "", false, errors.New("invalid signature")
+	}
+
+	// url will expire with the session
+	room, _ := NewRoomModel().GetRoomInfo("", roomSid, 1)
+	if room.Id == 0 {
+		return nil, "", false, errors.New("url has expired")
+	}
+
+	result, err := config.AppCnf.RDS.HGet(context.Background(), chatAttachmentsKey+roomSid, fileId).Result()
+	if err == redis.Nil {
+		return nil, "", false, errors.New("file not found")
+	} else if err != nil {
+		return nil, "", false, err
+	}
+	s := new(storedChatAttachment)
+	if err = json.Unmarshal([]byte(result), s); err != nil {
+		return nil, "", false, err
+	}
+
+	storage, err := recordingStorageFor(s.Location)
+	if err != nil {
+		return nil, "", false, err
+	}
+	if storage != nil {
+		file, err = storage.DownloadUrl(s.Location, remaining)
+		return s.ChatAttachment, file, true, err
+	}
+	return s.ChatAttachment, fmt.Sprintf("%s/%s", config.AppCnf.UploadFileSettings.Path, s.Location), false, nil
+}
+
+// DeleteChatAttachments will delete remote attachments of the session & return the count,
+// local files will be deleted with the session directory
+func (m *ManageFile) DeleteChatAttachments() int {
+	key := chatAttachmentsKey + m.Sid
+	result, err := m.rs.rc.HGetAll(m.rs.ctx, key).Result()
+	if err != nil {
+		log.Errorln(err)
+		return 0
+	}
+
+	var remote []string
+	for _, data := range result {
+		s := new(storedChatAttachment)
+		if err = json.Unmarshal([]byte(data), s); err == nil && strings.Contains(s.Location, "://") {
+			remote = append(remote, s.Location)
+		}
+	}
+	if len(remote) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		deleteStoredFiles(ctx, remote)
+	}
+	m.rs.rc.Del(m.rs.ctx, key)
+
+	return len(remote)
+}
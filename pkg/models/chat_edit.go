@@ -0,0 +1,197 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// chatMessagesKey keeps chat messages of the session, field is id of the message.
+// It will be used to validate edit & delete by the sender.
+const chatMessagesKey = "pnm:chat_messages:"
+
+// chatMessageAction is the body of edit_message & delete_message sent by clients
+type chatMessageAction struct {
+	MessageId string `json:"message_id"`
+	// Msg is the new message for edit_message
+	Msg string `json:"msg,omitempty"`
+	// EditedBy will be set by the server
+	EditedBy string `json:"edited_by,omitempty"`
+}
+
+func (r *RoomService) trackChatMessage(m *ChatMessageRecord) {
+	marshal, err := json.Marshal(m)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	key := chatMessagesKey + m.RoomId
+	pp := r.rc.Pipeline()
+	pp.HSet(r.ctx, key, m.MessageId, marshal)
+	pp.Expire(r.ctx, key, r.RoomKeyTTL(m.RoomId))
+	if _, err = pp.Exec(r.ctx); err != nil {
+		log.Errorln(err)
+	}
+}
+
+func (r *RoomService) loadTrackedChatMessage(roomId, messageId string) (*ChatMessageRecord, error) {
+	result, err := r.rc.HGet(r.ctx, chatMessagesKey+roomId, messageId).Result()
+	if err == redis.Nil {
+		return nil, errors.New("notifications.chat-message-not-found")
+	} else if err != nil {
+		return nil, err
+	}
+
+	m := new(ChatMessageRecord)
+	err = json.Unmarshal([]byte(result), m)
+	return m, err
+}
+
+func (r *RoomService) DeleteTrackedChatMessages(roomId string) (int64, error) {
+	return r.rc.Del(r.ctx, chatMessagesKey+roomId).Result()
+}
+
+// HandleChatMessageAction will validate edit_message & delete_message received from the websocket.
+// Only the original sender or a moderator can act. Message should be dropped if false was returned.
+// Other messages will be ignored.
+func HandleChatMessageAction(roomId, userId, userSid string, isAdmin bool, msg *plugnmeet.DataMessage) bool {
+	if msg.Type != plugnmeet.DataMsgType_USER || msg.Body == nil {
+		return true
+	}
+	if msg.Body.Type != DataMsgBodyType_EDIT_MESSAGE && msg.Body.Type != DataMsgBodyType_DELETE_MESSAGE {
+		return true
+	}
+
+	var err error
+	if msg.Body.From == nil || msg.Body.From.UserId != userId {
+		err = errors.New("sender mismatched")
+	} else if msg.Body.Type == DataMsgBodyType_EDIT_MESSAGE {
+		err = editChatMessage(roomId, userId, isAdmin, msg)
+		if err == nil {
+			return true
+		}
+	} else {
+		err = deleteOwnChatMessage(roomId, userId, isAdmin, msg)
+	}
+
+	if err != nil {
+		log.Warnln("chat message action of " + userId + " in room " + roomId + " was dropped: " + err.Error())
+		if userSid != "" {
+			sendChatPolicyAlert(roomId, userSid, err)
+		}
+	}
+	// delete will be sent to everyone as tombstone by the server
+	return false
+}
+
+func parseChatMessageAction(roomId, userId string, isAdmin bool, msg *plugnmeet.DataMessage) (*chatMessageAction, *ChatMessageRecord, error) {
+	action := new(chatMessageAction)
+	if err := json.Unmarshal([]byte(msg.Body.Msg), action); err != nil || action.MessageId == "" {
+		return nil, nil, errors.New("invalid message")
+	}
+
+	rs := NewRoomService()
+	m, err := rs.loadTrackedChatMessage(roomId, action.MessageId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if isAdmin {
+		return action, m, nil
+	}
+	if m.SenderId != userId {
+		return nil, nil, errors.New("notifications.chat-message-not-sender")
+	}
+	if rs.isChatMuted(roomId, userId) {
+		return nil, nil, errors.New("notifications.chat-muted")
+	}
+	return action, m, nil
+}
+
+// editChatMessage will change the message to be delivered to the same receivers of the original message
+func editChatMessage(roomId, userId string, isAdmin bool, msg *plugnmeet.DataMessage) error {
+	action, m, err := parseChatMessageAction(roomId, userId, isAdmin, msg)
+	if err != nil {
+		return err
+	}
+	if action.Msg == "" {
+		return errors.New("invalid message")
+	}
+
+	// edited message should be filtered same as new message
+	edited := &plugnmeet.DataMessage{
+		Type:      plugnmeet.DataMsgType_USER,
+		MessageId: &m.MessageId,
+		Body: &plugnmeet.DataMsgBody{
+			Type: plugnmeet.DataMsgBodyType_CHAT,
+			From: msg.Body.From,
+			Msg:  action.Msg,
+		},
+	}
+	if err = applyChatFilter(roomId, edited); err != nil {
+		return err
+	}
+
+	m.Msg = edited.Body.Msg
+	m.EditedAt = time.Now().Unix()
+	rs := NewRoomService()
+	rs.trackChatMessage(m)
+	rs.updateChatPin(roomId, m.MessageId, m)
+	if rs.LoadRoomOptions(roomId).PersistChat {
+		go updatePersistedChatMessage(roomId, m)
+	}
+
+	action.Msg = m.Msg
+	action.EditedBy = userId
+	marshal, err := json.Marshal(action)
+	if err != nil {
+		return err
+	}
+	msg.Body.Msg = string(marshal)
+	msg.To = nil
+	msg.Body.IsPrivate = nil
+	if m.Visibility == ChatVisibilityPrivate {
+		var isPrivate uint32 = 1
+		msg.To = &m.ToUserId
+		msg.Body.IsPrivate = &isPrivate
+	}
+
+	return nil
+}
+
+func deleteOwnChatMessage(roomId, userId string, isAdmin bool, msg *plugnmeet.DataMessage) error {
+	action, _, err := parseChatMessageAction(roomId, userId, isAdmin, msg)
+	if err != nil {
+		return err
+	}
+
+	return NewRoomService().DeleteChatMessage(&DeleteChatMessageReq{
+		RoomId:          roomId,
+		RequestedUserId: userId,
+		MessageId:       action.MessageId,
+	})
+}
+
+// updatePersistedChatMessage will insert the whole message, because the original
+// may not be written yet because of batching. In that case the original will be ignored.
+func updatePersistedChatMessage(roomId string, m *ChatMessageRecord) {
+	if m.RoomSid == "" {
+		room, _ := NewRoomModel().GetRoomInfo(roomId, "", 1)
+		if room.Id == 0 {
+			return
+		}
+		m.RoomSid = room.Sid
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	query := "INSERT INTO " + config.AppCnf.FormatDBTable("chat_messages") + " (message_id, room_id, room_sid, sender_id, sender_name, to_user_id, visibility, msg, sent_at, edited_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE msg = IF(deleted_at = 0, VALUES(msg), msg), edited_at = VALUES(edited_at)"
+	_, err := config.AppCnf.DB.ExecContext(ctx, query, m.MessageId, m.RoomId, m.RoomSid, m.SenderId, m.SenderName, m.ToUserId, m.Visibility, m.Msg, m.SentAt, m.EditedAt)
+	if err != nil {
+		log.Errorln(err)
+	}
+}
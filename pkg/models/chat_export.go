@@ -0,0 +1,183 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"strings"
+	"time"
+)
+
+const (
+	ChatExportFormatJson = "json"
+	ChatExportFormatCsv  = "csv"
+	ChatExportFormatTxt  = "txt"
+)
+
+type ExportChatReq struct {
+	RoomId string `query:"room_id" validate:"required,require-valid-Id"`
+	// RoomSid of the session, empty will use the last session of the room.
+	// It is ignored for non-admin users, they always get the current session.
+	RoomSid string `query:"room_sid"`
+	Format  string `query:"format" validate:"omitempty,oneof=json csv txt"`
+}
+
+// ChatExportViewer is used to apply private message visibility,
+// admin will get all messages & others will get public messages
+// with private messages those were sent or received by them.
+type ChatExportViewer struct {
+	UserId  string
+	IsAdmin bool
+}
+
+type chatExportModel struct {
+	app *config.AppConfig
+	db  *sql.DB
+	ctx context.Context
+}
+
+func NewChatExportModel() *chatExportModel {
+	return &chatExportModel{
+		app: config.AppCnf,
+		db:  config.AppCnf.DB,
+		ctx: context.Background(),
+	}
+}
+
+// ExportChat will return the transcript in requested format with file name
+func (m *chatExportModel) ExportChat(r *ExportChatReq, viewer *ChatExportViewer) ([]byte, string, error) {
+	roomSid := r.RoomSid
+	if roomSid == "" {
+		sid, err := m.lastSessionSid(r.RoomId)
+		if err != nil {
+			return nil, "", err
+		}
+		roomSid = sid
+	}
+
+	messages, err := m.loadChatMessages(r.RoomId, roomSid, viewer)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(messages) == 0 {
+		return nil, "", errors.New("no chat messages found")
+	}
+
+	format := r.Format
+	if format == "" {
+		format = ChatExportFormatJson
+	}
+	fileName := fmt.Sprintf("%s_chat.%s", roomSid, format)
+
+	switch format {
+	case ChatExportFormatCsv:
+		data, err := chatMessagesToCsv(messages)
+		return data, fileName, err
+	case ChatExportFormatTxt:
+		return chatMessagesToTxt(messages), fileName, nil
+	}
+	data, err := json.Marshal(messages)
+	return data, fileName, err
+}
+
+func (m *chatExportModel) lastSessionSid(roomId string) (string, error) {
+	ctx, cancel := context.WithTimeout(m.ctx, 3*time.Second)
+	defer cancel()
+
+	var sid string
+	err := m.db.QueryRowContext(ctx, "SELECT sid FROM "+m.app.FormatDBTable("room_info")+" WHERE roomId = ? ORDER BY id DESC LIMIT 1", roomId).Scan(&sid)
+	if err == sql.ErrNoRows {
+		return "", errors.New("no session found")
+	}
+	return sid, err
+}
+
+func (m *chatExportModel) loadChatMessages(roomId, roomSid string, viewer *ChatExportViewer) ([]*ChatMessageRecord, error) {
+	ctx, cancel := context.WithTimeout(m.ctx, 10*time.Second)
+	defer cancel()
+
+	query := "SELECT message_id, room_id, room_sid, sender_id, sender_name, to_user_id, visibility, msg, sent_at, edited_at, pinned_at FROM " + m.app.FormatDBTable("chat_messages") + " WHERE room_id = ? AND room_sid = ? AND deleted_at = 0"
+	args := []interface{}{roomId, roomSid}
+	if !viewer.IsAdmin {
+		query += " AND (visibility = ? OR sender_id = ? OR to_user_id = ?)"
+		args = append(args, ChatVisibilityPublic, viewer.UserId, viewer.UserId)
+	}
+	query += " ORDER BY sent_at, id"
+
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*ChatMessageRecord
+	for rows.Next() {
+		r := new(ChatMessageRecord)
+		err = rows.Scan(&r.MessageId, &r.RoomId, &r.RoomSid, &r.SenderId, &r.SenderName, &r.ToUserId, &r.Visibility, &r.Msg, &r.SentAt, &r.EditedAt, &r.PinnedAt)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, r)
+	}
+
+	return messages, rows.Err()
+}
+
+func chatMessagesToCsv(messages []*ChatMessageRecord) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+	_ = w.Write([]string{"sent_at", "sender_id", "sender_name", "visibility", "to_user_id", "msg", "edited_at", "pinned_at"})
+	for _, r := range messages {
+		editedAt, pinnedAt := "", ""
+		if r.EditedAt > 0 {
+			editedAt = time.Unix(r.EditedAt, 0).UTC().Format(time.RFC3339)
+		}
+		if r.PinnedAt > 0 {
+			pinnedAt = time.Unix(r.PinnedAt, 0).UTC().Format(time.RFC3339)
+		}
+		_ = w.Write([]string{
+			time.Unix(r.SentAt, 0).UTC().Format(time.RFC3339),
+			r.SenderId,
+			r.SenderName,
+			r.Visibility,
+			r.ToUserId,
+			r.Msg,
+			editedAt,
+			pinnedAt,
+		})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// chatMessagesToTxt will write one message per line, e.g.
+// [2022-01-02 15:04:05] John (private to user_02): hello
+func chatMessagesToTxt(messages []*ChatMessageRecord) []byte {
+	buf := new(bytes.Buffer)
+	for _, r := range messages {
+		buf.WriteString("[" + time.Unix(r.SentAt, 0).UTC().Format("2006-01-02 15:04:05") + "] ")
+		name := r.SenderName
+		if name == "" {
+			name = r.SenderId
+		}
+		buf.WriteString(name)
+		if r.Visibility == ChatVisibilityPrivate {
+			buf.WriteString(" (private to " + r.ToUserId + ")")
+		}
+		// one message should be in one line
+		buf.WriteString(": " + strings.ReplaceAll(r.Msg, "\n", " "))
+		if r.EditedAt > 0 {
+			buf.WriteString(" (edited)")
+		}
+		if r.PinnedAt > 0 {
+			buf.WriteString(" (pinned)")
+		}
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"errors"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// actions of the chat filter for matched messages
+const (
+	ChatFilterActionMask   = "mask"
+	ChatFilterActionReject = "reject"
+	ChatFilterActionFlag   = "flag"
+
+	ChatFilterModeWords = "words"
+	ChatFilterModeRegex = "regex"
+)
+
+// chatMessageFlagged will be sent to the moderators
+type chatMessageFlagged struct {
+	MessageId string   `json:"message_id"`
+	UserId    string   `json:"user_id"`
+	Name      string   `json:"name"`
+	Msg       string   `json:"msg"`
+	Matches   []string `json:"matches"`
+}
+
+// chatFilterPatterns of the deployment will be compiled once from config
+var chatFilterPatterns = struct {
+	sync.Once
+	patterns []*regexp.Regexp
+}{}
+
+// compileChatFilter will convert the list to patterns based on mode,
+// words will be matched as whole words without case
+func compileChatFilter(list []string, mode string) []*regexp.Regexp {
+	var patterns []*regexp.Regexp
+	if mode == ChatFilterModeRegex {
+		for _, p := range list {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				log.Errorln("invalid chat filter pattern " + p + ": " + err.Error())
+				continue
+			}
+			patterns = append(patterns, re)
+		}
+		return patterns
+	}
+
+	var words []string
+	for _, w := range list {
+		if w = strings.TrimSpace(w); w != "" {
+			words = append(words, regexp.QuoteMeta(w))
+		}
+	}
+	if len(words) > 0 {
+		patterns = append(patterns, regexp.MustCompile(`(?i)\b(`+strings.Join(words, "|")+`)\b`))
+	}
+	return patterns
+}
+
+func loadChatFilterPatterns(roomId string) []*regexp.Regexp {
+	conf := config.AppCnf.Client.ChatFilter
+	chatFilterPatterns.Do(func() {
+		chatFilterPatterns.patterns = compileChatFilter(conf.Words, conf.Mode)
+	})
+
+	patterns := chatFilterPatterns.patterns
+	if words := NewRoomService().LoadRoomOptions(roomId).ChatFilterWords; len(words) > 0 {
+		patterns = append(patterns, compileChatFilter(words, conf.Mode)...)
+	}
+	return patterns
+}
+
+// applyChatFilter will mask, reject or flag the chat message based on config.
+// Message will be changed in case of masking.
+func applyChatFilter(roomId string, msg *plugnmeet.DataMessage) error {
+	conf := config.AppCnf.Client.ChatFilter
+	if !conf.Enabled || msg.Type != plugnmeet.DataMsgType_USER || msg.Body == nil || msg.Body.Type != plugnmeet.DataMsgBodyType_CHAT {
+		return nil
+	}
+
+	var matches []string
+	for _, re := range loadChatFilterPatterns(roomId) {
+		matches = append(matches, re.FindAllString(msg.Body.Msg, -1)...)
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+
+	switch conf.Action {
+	case ChatFilterActionReject:
+		return errors.New("notifications.chat-message-rejected")
+	case ChatFilterActionFlag:
+		go flagChatMessage(roomId, msg, matches)
+	default:
+		for _, re := range loadChatFilterPatterns(roomId) {
+			msg.Body.Msg = re.ReplaceAllStringFunc(msg.Body.Msg, func(s string) string {
+				return strings.Repeat("*", len([]rune(s)))
+			})
+		}
+	}
+
+	return nil
+}
+
+// flagChatMessage will let moderators of the room know about the message,
+// message will be delivered as it is
+func flagChatMessage(roomId string, msg *plugnmeet.DataMessage, matches []string) {
+	flagged := &chatMessageFlagged{
+		MessageId: msg.GetMessageId(),
+		Msg:       msg.Body.Msg,
+		Matches:   matches,
+	}
+	if msg.Body.From != nil {
+		flagged.UserId = msg.Body.From.UserId
+		flagged.Name = msg.Body.From.GetName()
+	}
+	sendSystemMsgToModerators(roomId, DataMsgBodyType_CHAT_MESSAGE_FLAGGED, flagged)
+}
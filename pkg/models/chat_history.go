@@ -0,0 +1,144 @@
+package models
+
+import (
+	"context"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	// chatHistoryKey keeps ids of the latest public messages in order
+	chatHistoryKey          = "pnm:chat_history:"
+	defaultChatHistoryCount = 20
+	defaultChatHistoryMax   = 100
+)
+
+type chatHistory struct {
+	Messages []*ChatMessageRecord `json:"messages"`
+}
+
+func chatHistoryMax() int {
+	if m := config.AppCnf.Client.ChatHistory.MaxCount; m > 0 {
+		return m
+	}
+	return defaultChatHistoryMax
+}
+
+// GetChatHistoryCount will return number of messages to be sent to late joiners
+func (o *RoomOptions) GetChatHistoryCount() int {
+	count := o.ChatHistoryCount
+	if count == 0 {
+		count = config.AppCnf.Client.ChatHistory.DefaultCount
+	}
+	if count == 0 {
+		count = defaultChatHistoryCount
+	}
+	if limit := chatHistoryMax(); count > limit {
+		count = limit
+	}
+	return count
+}
+
+// addToChatHistory will keep the message id in history if the message is public
+func (r *RoomService) addToChatHistory(m *ChatMessageRecord) {
+	if !config.AppCnf.Client.ChatHistory.Enabled || m.Visibility != ChatVisibilityPublic {
+		return
+	}
+	key := chatHistoryKey + m.RoomId
+	pp := r.rc.Pipeline()
+	pp.RPush(r.ctx, key, m.MessageId)
+	pp.LTrim(r.ctx, key, int64(-chatHistoryMax()), -1)
+	pp.Expire(r.ctx, key, r.RoomKeyTTL(m.RoomId))
+	if _, err := pp.Exec(r.ctx); err != nil {
+		log.Errorln(err)
+	}
+}
+
+func (r *RoomService) DeleteChatHistory(roomId string) (int64, error) {
+	return r.rc.Del(r.ctx, chatHistoryKey+roomId).Result()
+}
+
+// SendChatHistory will send the latest public messages to the user who has just joined,
+// messages will be loaded from DB if those aren't in redis anymore
+func SendChatHistory(roomId, userId string) {
+	if !config.AppCnf.Client.ChatHistory.Enabled {
+		return
+	}
+
+	rs := NewRoomService()
+	opts := rs.LoadRoomOptions(roomId)
+	count := opts.GetChatHistoryCount()
+
+	messages, err := rs.loadChatHistory(roomId, count)
+	if err != nil {
+		log.Errorln(err)
+	}
+	if len(messages) == 0 && opts.PersistChat {
+		messages, err = loadPersistedChatHistory(roomId, count)
+		if err != nil {
+			log.Errorln(err)
+		}
+	}
+	if len(messages) == 0 {
+		return
+	}
+
+	sendSystemMsgToUser(roomId, userId, DataMsgBodyType_CHAT_HISTORY, &chatHistory{
+		Messages: messages,
+	})
+}
+
+func (r *RoomService) loadChatHistory(roomId string, count int) ([]*ChatMessageRecord, error) {
+	ids, err := r.rc.LRange(r.ctx, chatHistoryKey+roomId, int64(-count), -1).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	results, err := r.rc.HMGet(r.ctx, chatMessagesKey+roomId, ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]*ChatMessageRecord, 0, len(results))
+	for _, res := range results {
+		// deleted messages won't exist anymore
+		data, ok := res.(string)
+		if !ok {
+			continue
+		}
+		m := new(ChatMessageRecord)
+		if err = json.Unmarshal([]byte(data), m); err == nil {
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
+}
+
+func loadPersistedChatHistory(roomId string, count int) ([]*ChatMessageRecord, error) {
+	room, _ := NewRoomModel().GetRoomInfo(roomId, "", 1)
+	if room.Id == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	query := "SELECT message_id, room_id, room_sid, sender_id, sender_name, to_user_id, visibility, msg, sent_at, edited_at FROM " + config.AppCnf.FormatDBTable("chat_messages") + " WHERE room_id = ? AND room_sid = ? AND visibility = ? AND deleted_at = 0 ORDER BY sent_at DESC, id DESC LIMIT ?"
+	rows, err := config.AppCnf.DB.QueryContext(ctx, query, roomId, room.Sid, ChatVisibilityPublic, count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*ChatMessageRecord
+	for rows.Next() {
+		m := new(ChatMessageRecord)
+		err = rows.Scan(&m.MessageId, &m.RoomId, &m.RoomSid, &m.SenderId, &m.SenderName, &m.ToUserId, &m.Visibility, &m.Msg, &m.SentAt, &m.EditedAt)
+		if err != nil {
+			return nil, err
+		}
+		// oldest first
+		messages = append([]*ChatMessageRecord{m}, messages...)
+	}
+	return messages, rows.Err()
+}
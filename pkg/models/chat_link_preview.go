@@ -0,0 +1,306 @@
+package models
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"html"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// linkPreviewKey is the cache of previews, key is hash of the url
+	linkPreviewKey              = "pnm:link_preview:"
+	defaultLinkPreviewTimeout   = 5 * time.Second
+	defaultLinkPreviewCacheTTL  = 24 * time.Hour
+	defaultLinkPreviewMaxBodyKB = 512
+	// linkPreviewFailedTTL will stop fetching the same url again & again if it has failed
+	linkPreviewFailedTTL    = 10 * time.Minute
+	linkPreviewMaxRedirects = 3
+	linkPreviewMaxTextLen   = 300
+)
+
+var (
+	linkPreviewUrlRegex   = regexp.MustCompile(`https?://[^\s<>"']+`)
+	linkPreviewMetaRegex  = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	linkPreviewAttrRegex  = regexp.MustCompile(`(?is)([a-z][a-z:_-]*)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	linkPreviewTitleRegex = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+	// blockedNetworks those aren't part of net.IP helpers
+	linkPreviewBlockedNets = []*net.IPNet{
+		mustParseCIDR("100.64.0.0/10"), // carrier-grade NAT
+		mustParseCIDR("192.0.0.0/24"),
+		mustParseCIDR("198.18.0.0/15"),
+		mustParseCIDR("240.0.0.0/4"),
+	}
+)
+
+type LinkPreview struct {
+	Url         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+type chatLinkPreview struct {
+	MessageId string       `json:"message_id"`
+	Preview   *LinkPreview `json:"preview"`
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// isPublicIP will return false for loopback, private, link-local & other special addresses
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, n := range linkPreviewBlockedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// newLinkPreviewClient will check the address after DNS resolution for every connection
+// including redirects, so that users can't make the server request internal services
+func newLinkPreviewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !isPublicIP(ip) {
+				return errors.New("address isn't allowed: " + host)
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// never use proxy from environment, otherwise the check will be skipped
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= linkPreviewMaxRedirects {
+				return errors.New("too many redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return errors.New("scheme isn't allowed")
+			}
+			return nil
+		},
+	}
+}
+
+// UnfurlChatLink will send preview of the first link of the chat message
+// to the same receivers of the message
+func UnfurlChatLink(roomId string, msg *plugnmeet.DataMessage) {
+	if !config.AppCnf.Client.LinkPreview.Enabled {
+		return
+	}
+	if msg.Type != plugnmeet.DataMsgType_USER || msg.Body == nil || msg.Body.Type != plugnmeet.DataMsgBodyType_CHAT {
+		return
+	}
+	link := linkPreviewUrlRegex.FindString(msg.Body.Msg)
+	if link == "" {
+		return
+	}
+
+	rs := NewRoomService()
+	preview, err := rs.getLinkPreview(link)
+	if err != nil {
+		log.Debugln("link preview of " + link + " failed: " + err.Error())
+		return
+	}
+	if preview == nil {
+		return
+	}
+
+	// keep preview with the message, so that history & pins will have it
+	if m, err := rs.loadTrackedChatMessage(roomId, msg.GetMessageId()); err == nil {
+		m.LinkPreview = preview
+		rs.trackChatMessage(m)
+	}
+
+	v := &chatLinkPreview{
+		MessageId: msg.GetMessageId(),
+		Preview:   preview,
+	}
+	if msg.To == nil || *msg.To == "" {
+		broadcastSystemMsg(roomId, DataMsgBodyType_CHAT_LINK_PREVIEW, v)
+		return
+	}
+	sendSystemMsgToUser(roomId, *msg.To, DataMsgBodyType_CHAT_LINK_PREVIEW, v)
+	if msg.Body.From != nil {
+		sendSystemMsgToUser(roomId, msg.Body.From.UserId, DataMsgBodyType_CHAT_LINK_PREVIEW, v)
+	}
+}
+
+// getLinkPreview will return nil if the page doesn't have any information for preview
+func (r *RoomService) getLinkPreview(link string) (*LinkPreview, error) {
+	conf := config.AppCnf.Client.LinkPreview
+	sum := sha1.Sum([]byte(link))
+	key := linkPreviewKey + hex.EncodeToString(sum[:])
+
+	if cached, err := r.rc.Get(r.ctx, key).Result(); err == nil {
+		if cached == "" {
+			return nil, nil
+		}
+		preview := new(LinkPreview)
+		err = json.Unmarshal([]byte(cached), preview)
+		return preview, err
+	}
+
+	preview, err := fetchLinkPreview(link)
+	if err != nil || preview == nil {
+		// empty value to remember the failure
+		r.rc.Set(r.ctx, key, "", linkPreviewFailedTTL)
+		return nil, err
+	}
+
+	marshal, err := json.Marshal(preview)
+	if err != nil {
+		return nil, err
+	}
+	ttl := conf.CacheTTL
+	if ttl <= 0 {
+		ttl = defaultLinkPreviewCacheTTL
+	}
+	r.rc.Set(r.ctx, key, marshal, ttl)
+
+	return preview, nil
+}
+
+func fetchLinkPreview(link string) (*LinkPreview, error) {
+	conf := config.AppCnf.Client.LinkPreview
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New("scheme isn't allowed")
+	}
+
+	timeout := conf.Timeout
+	if timeout <= 0 {
+		timeout = defaultLinkPreviewTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "plugNmeet-link-preview/1.0")
+	req.Header.Set("Accept", "text/html")
+
+	res, err := newLinkPreviewClient(timeout).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return nil, errors.New("unexpected status " + res.Status)
+	}
+	if mt, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type")); mt != "text/html" {
+		return nil, errors.New("content isn't html")
+	}
+
+	maxKB := conf.MaxBodyKB
+	if maxKB <= 0 {
+		maxKB = defaultLinkPreviewMaxBodyKB
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, int64(maxKB)*1024))
+	if err != nil {
+		return nil, err
+	}
+
+	return parseLinkPreview(res.Request.URL, string(body)), nil
+}
+
+// parseLinkPreview will use OpenGraph tags & fallback to title and description
+func parseLinkPreview(pageUrl *url.URL, body string) *LinkPreview {
+	meta := make(map[string]string)
+	for _, tag := range linkPreviewMetaRegex.FindAllString(body, -1) {
+		attrs := make(map[string]string)
+		for _, a := range linkPreviewAttrRegex.FindAllStringSubmatch(tag, -1) {
+			attrs[strings.ToLower(a[1])] = a[2] + a[3]
+		}
+		name := attrs["property"]
+		if name == "" {
+			name = attrs["name"]
+		}
+		name = strings.ToLower(name)
+		if name != "" && attrs["content"] != "" {
+			if _, ok := meta[name]; !ok {
+				meta[name] = attrs["content"]
+			}
+		}
+	}
+
+	preview := &LinkPreview{
+		Url:         pageUrl.String(),
+		Title:       linkPreviewText(meta["og:title"]),
+		Description: linkPreviewText(meta["og:description"]),
+		SiteName:    linkPreviewText(meta["og:site_name"]),
+	}
+	if preview.Title == "" {
+		if m := linkPreviewTitleRegex.FindStringSubmatch(body); m != nil {
+			preview.Title = linkPreviewText(m[1])
+		}
+	}
+	if preview.Description == "" {
+		preview.Description = linkPreviewText(meta["description"])
+	}
+	if img := strings.TrimSpace(html.UnescapeString(meta["og:image"])); img != "" {
+		if iu, err := pageUrl.Parse(img); err == nil && (iu.Scheme == "http" || iu.Scheme == "https") {
+			preview.Image = iu.String()
+		}
+	}
+
+	if preview.Title == "" && preview.Description == "" {
+		return nil
+	}
+	return preview
+}
+
+func linkPreviewText(s string) string {
+	s = strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+	if r := []rune(s); len(r) > linkPreviewMaxTextLen {
+		s = string(r[:linkPreviewMaxTextLen]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"net"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"172.15.0.1", true},
+		{"198.20.0.1", true},
+		{"2001:4860:4860::8888", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"100.64.0.1", false},
+		{"192.0.0.8", false},
+		{"198.18.0.1", false},
+		{"fc00::1", false},
+		{"fe80::1", false},
+		{"ff02::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"::ffff:100.64.0.1", false},
+	}
+
+	for _, tt := range tests {
+		got := isPublicIP(net.ParseIP(tt.ip))
+		if got != tt.want {
+			t.Errorf("isPublicIP(%s) = %v, want %v", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestParseLinkPreview(t *testing.T) {
+	pageUrl, _ := url.Parse("https://example.com/blog/post")
+	longTitle := strings.Repeat("a", linkPreviewMaxTextLen+10)
+
+	tests := []struct {
+		name string
+		body string
+		want *LinkPreview
+	}{
+		{
+			"open graph",
+			`<html><head><title>Fallback</title>
+			<meta property="og:title" content="Post title">
+			<meta property="og:description" content="Post description">
+			<meta property="og:site_name" content="Example">
+			<meta property="og:image" content="/img/cover.png">
+			</head></html>`,
+			&LinkPreview{Url: "https://example.com/blog/post", Title: "Post title", Description: "Post description", SiteName: "Example", Image: "https://example.com/img/cover.png"},
+		},
+		{
+			"fallback to title & description",
+			`<title> Page
+			title </title><meta name="description" content="Page description">`,
+			&LinkPreview{Url: "https://example.com/blog/post", Title: "Page title", Description: "Page description"},
+		},
+		{
+			"single quotes & case insensitive",
+			`<META Content='Title' PROPERTY='OG:Title'><meta content="second" property="og:title">`,
+			&LinkPreview{Url: "https://example.com/blog/post", Title: "Title"},
+		},
+		{
+			"html entities",
+			`<meta property="og:title" content="Tom &amp; Jerry &#39;s  show">`,
+			&LinkPreview{Url: "https://example.com/blog/post", Title: "Tom & Jerry 's show"},
+		},
+		{
+			"protocol relative image",
+			`<meta property="og:title" content="Title"><meta property="og:image" content="//cdn.example.com/a.png">`,
+			&LinkPreview{Url: "https://example.com/blog/post", Title: "Title", Image: "https://cdn.example.com/a.png"},
+		},
+		{
+			"javascript image will be ignored",
+			`<meta property="og:title" content="Title"><meta property="og:image" content="javascript:alert(1)">`,
+			&LinkPreview{Url: "https://example.com/blog/post", Title: "Title"},
+		},
+		{
+			"long title",
+			`<title>` + longTitle + `</title>`,
+			&LinkPreview{Url: "https://example.com/blog/post", Title: longTitle[:linkPreviewMaxTextLen] + "…"},
+		},
+		{
+			"nothing to preview",
+			`<meta property="og:image" content="/img/cover.png"><p>hello</p>`,
+			nil,
+		},
+		{"empty", "", nil},
+	}
+
+	for _, tt := range tests {
+		got := parseLinkPreview(pageUrl, tt.body)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: parseLinkPreview() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
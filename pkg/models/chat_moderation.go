@@ -0,0 +1,121 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"time"
+)
+
+// chatMuteKey keeps muted users of the room, key will expire when the mute ends
+const chatMuteKey = "pnm:chat_mute:"
+
+type DeleteChatMessageReq struct {
+	RoomId          string `json:"-"`
+	RequestedUserId string `json:"-"`
+	MessageId       string `json:"message_id" validate:"required,max=64"`
+}
+
+type MuteUserChatReq struct {
+	RoomId string `json:"-"`
+	UserId string `json:"user_id" validate:"required"`
+	// Minutes 0 will unmute the user
+	Minutes int `json:"minutes" validate:"min=0,max=1440"`
+}
+
+// chatMessageDeleted is the tombstone, clients should remove the message
+type chatMessageDeleted struct {
+	MessageId string `json:"message_id"`
+	DeletedBy string `json:"deleted_by"`
+}
+
+type chatMuteUpdated struct {
+	UserId string `json:"user_id"`
+	// MutedUntil 0 means unmuted
+	MutedUntil int64 `json:"muted_until"`
+}
+
+func chatMuteUserKey(roomId, userId string) string {
+	return chatMuteKey + roomId + ":" + userId
+}
+
+// DeleteChatMessage will let clients remove the message & replace it in DB with the tombstone
+func (r *RoomService) DeleteChatMessage(req *DeleteChatMessageReq) error {
+	room, _ := NewRoomModel().GetRoomInfo(req.RoomId, "", 1)
+	if room.Id == 0 {
+		return errors.New("notifications.room-not-active")
+	}
+
+	broadcastSystemMsg(req.RoomId, DataMsgBodyType_CHAT_MESSAGE_DELETED, &chatMessageDeleted{
+		MessageId: req.MessageId,
+		DeletedBy: req.RequestedUserId,
+	})
+	r.rc.HDel(r.ctx, chatMessagesKey+req.RoomId, req.MessageId)
+	r.updateChatPin(req.RoomId, req.MessageId, nil)
+	r.AddRoomTimelineEvent(req.RoomId, &RoomTimelineEvent{
+		Type:   "chat_message_deleted",
+		UserId: req.RequestedUserId,
+		Msg:    req.MessageId,
+	})
+
+	if !r.LoadRoomOptions(req.RoomId).PersistChat {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(r.ctx, 3*time.Second)
+	defer cancel()
+	// message may not be written yet because of batching,
+	// in that case the tombstone will make the insertion ignored
+	query := "INSERT INTO " + config.AppCnf.FormatDBTable("chat_messages") + " (message_id, room_id, room_sid, msg, deleted_by, deleted_at) VALUES (?, ?, ?, '', ?, ?) ON DUPLICATE KEY UPDATE msg = '', deleted_by = VALUES(deleted_by), deleted_at = VALUES(deleted_at)"
+	_, err := config.AppCnf.DB.ExecContext(ctx, query, req.MessageId, req.RoomId, room.Sid, req.RequestedUserId, time.Now().Unix())
+	return err
+}
+
+// MuteUserChat will block chat messages of the user for the minutes,
+// moderators can't be muted
+func (r *RoomService) MuteUserChat(req *MuteUserChatReq) (int64, error) {
+	_, meta, err := r.LoadParticipantWithMetadata(req.RoomId, req.UserId)
+	if err != nil {
+		return 0, errors.New("user isn't active now")
+	}
+	if meta.IsAdmin {
+		return 0, errors.New("moderator's chat can't be muted")
+	}
+
+	key := chatMuteUserKey(req.RoomId, req.UserId)
+	var mutedUntil int64
+	if req.Minutes == 0 {
+		err = r.rc.Del(r.ctx, key).Err()
+	} else {
+		duration := time.Duration(req.Minutes) * time.Minute
+		mutedUntil = time.Now().Add(duration).Unix()
+		err = r.rc.Set(r.ctx, key, mutedUntil, duration).Err()
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	broadcastSystemMsg(req.RoomId, DataMsgBodyType_CHAT_MUTE_UPDATED, &chatMuteUpdated{
+		UserId:     req.UserId,
+		MutedUntil: mutedUntil,
+	})
+	r.AddRoomTimelineEvent(req.RoomId, &RoomTimelineEvent{
+		Type:   "chat_mute_updated",
+		UserId: req.UserId,
+		Msg:    strconv.Itoa(req.Minutes),
+	})
+
+	return mutedUntil, nil
+}
+
+// isChatMuted will return true if the user's chat was muted by moderator
+func (r *RoomService) isChatMuted(roomId, userId string) bool {
+	_, err := r.rc.Get(r.ctx, chatMuteUserKey(roomId, userId)).Result()
+	if err != nil && err != redis.Nil {
+		log.Errorln(err)
+	}
+	return err == nil
+}
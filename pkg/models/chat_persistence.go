@@ -0,0 +1,145 @@
+package models
+
+import (
+	"context"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ChatVisibilityPublic  = "public"
+	ChatVisibilityPrivate = "private"
+
+	defaultChatPersistBatchSize     = 100
+	defaultChatPersistFlushInterval = 2 * time.Second
+	// chatPersistQueueSize messages will be dropped if DB can't keep up
+	chatPersistQueueSize = 10000
+)
+
+type ChatMessageRecord struct {
+	MessageId  string `json:"message_id"`
+	RoomId     string `json:"room_id"`
+	RoomSid    string `json:"room_sid"`
+	SenderId   string `json:"sender_id"`
+	SenderName string `json:"sender_name"`
+	// ToUserId is the receiver of the private message
+	ToUserId   string `json:"to_user_id,omitempty"`
+	Visibility string `json:"visibility"`
+	Msg        string `json:"msg"`
+	SentAt     int64  `json:"sent_at"`
+	EditedAt   int64  `json:"edited_at,omitempty"`
+	PinnedAt   int64  `json:"pinned_at,omitempty"`
+	// LinkPreview will be kept in redis only
+	LinkPreview *LinkPreview `json:"link_preview,omitempty"`
+}
+
+var (
+	chatPersistQueue chan *ChatMessageRecord
+	chatPersistOnce  sync.Once
+)
+
+// PersistChatMessage will keep the chat message for edit & delete during the session
+// and queue it to be written in DB, if persist_chat was enabled for the room
+func PersistChatMessage(roomId string, msg *plugnmeet.DataMessage) {
+	if msg.Type != plugnmeet.DataMsgType_USER || msg.Body == nil || msg.Body.Type != plugnmeet.DataMsgBodyType_CHAT {
+		return
+	}
+
+	r := &ChatMessageRecord{
+		MessageId:  msg.GetMessageId(),
+		RoomId:     roomId,
+		Visibility: ChatVisibilityPublic,
+		Msg:        msg.Body.Msg,
+		SentAt:     time.Now().Unix(),
+	}
+	if msg.Body.From != nil {
+		r.SenderId = msg.Body.From.UserId
+		r.SenderName = msg.Body.From.GetName()
+	}
+	if msg.Body.IsPrivate != nil && *msg.Body.IsPrivate == 1 {
+		r.Visibility = ChatVisibilityPrivate
+		r.ToUserId = msg.GetTo()
+	}
+
+	rs := NewRoomService()
+	rs.trackChatMessage(r)
+	rs.addToChatHistory(r)
+	if !rs.LoadRoomOptions(roomId).PersistChat {
+		return
+	}
+
+	// room sid of the message was set by the client, so we won't use it
+	room, _ := NewRoomModel().GetRoomInfo(roomId, "", 1)
+	if room.Id == 0 {
+		return
+	}
+	r.RoomSid = room.Sid
+
+	chatPersistOnce.Do(startChatPersistWorker)
+	select {
+	case chatPersistQueue <- r:
+	default:
+		log.Warnln("chat persistence queue is full, message of room " + roomId + " was dropped")
+	}
+}
+
+func startChatPersistWorker() {
+	chatPersistQueue = make(chan *ChatMessageRecord, chatPersistQueueSize)
+	go chatPersistWorker()
+}
+
+// chatPersistWorker will write messages in batches,
+// when the batch is full or after the flush interval
+func chatPersistWorker() {
+	conf := config.AppCnf.Client.ChatPersistence
+	batchSize := conf.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultChatPersistBatchSize
+	}
+	interval := conf.FlushInterval
+	if interval <= 0 {
+		interval = defaultChatPersistFlushInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	batch := make([]*ChatMessageRecord, 0, batchSize)
+	for {
+		select {
+		case r := <-chatPersistQueue:
+			batch = append(batch, r)
+			if len(batch) >= batchSize {
+				insertChatMessages(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				insertChatMessages(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+func insertChatMessages(batch []*ChatMessageRecord) {
+	placeholders := make([]string, 0, len(batch))
+	args := make([]interface{}, 0, len(batch)*9)
+	for _, r := range batch {
+		placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?, ?, ?, ?)")
+		args = append(args, r.MessageId, r.RoomId, r.RoomSid, r.SenderId, r.SenderName, r.ToUserId, r.Visibility, r.Msg, r.SentAt)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// same message may be received again if the client has resent it
+	query := "INSERT IGNORE INTO " + config.AppCnf.FormatDBTable("chat_messages") + " (message_id, room_id, room_sid, sender_id, sender_name, to_user_id, visibility, msg, sent_at) VALUES " + strings.Join(placeholders, ", ")
+	if _, err := config.AppCnf.DB.ExecContext(ctx, query, args...); err != nil {
+		log.Errorln(err)
+	}
+}
@@ -0,0 +1,213 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"time"
+)
+
+const (
+	// chatPinsKey keeps pinned messages of the room, field is id of the message
+	chatPinsKey          = "pnm:chat_pins:"
+	defaultChatPinsLimit = 3
+)
+
+type PinChatMessageReq struct {
+	RoomId          string `json:"-"`
+	RequestedUserId string `json:"-"`
+	MessageId       string `json:"message_id" validate:"required,max=64"`
+}
+
+type chatPinsUpdated struct {
+	Pins []*ChatMessageRecord `json:"pins"`
+}
+
+func chatPinsLimit() int {
+	if l := config.AppCnf.Client.ChatPins.MaxPins; l > 0 {
+		return l
+	}
+	return defaultChatPinsLimit
+}
+
+// PinChatMessage will pin the public message for everyone
+func (r *RoomService) PinChatMessage(req *PinChatMessageReq) error {
+	m, err := r.loadTrackedChatMessage(req.RoomId, req.MessageId)
+	if err != nil {
+		return err
+	}
+	if m.Visibility != ChatVisibilityPublic {
+		return errors.New("private message can't be pinned")
+	}
+
+	key := chatPinsKey + req.RoomId
+	exist, err := r.rc.HExists(r.ctx, key, req.MessageId).Result()
+	if err != nil {
+		return err
+	}
+	if exist {
+		return nil
+	}
+	count, err := r.rc.HLen(r.ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if int(count) >= chatPinsLimit() {
+		return errors.New("notifications.chat-pins-limit-reached")
+	}
+
+	m.PinnedAt = time.Now().Unix()
+	if err = r.saveChatPin(m); err != nil {
+		return err
+	}
+	r.AddRoomTimelineEvent(req.RoomId, &RoomTimelineEvent{
+		Type:   "chat_message_pinned",
+		UserId: req.RequestedUserId,
+		Msg:    req.MessageId,
+	})
+
+	r.broadcastChatPins(req.RoomId)
+	if r.LoadRoomOptions(req.RoomId).PersistChat {
+		go updatePersistedChatPin(req.RoomId, m)
+	}
+	return nil
+}
+
+// UnpinChatMessage will remove the message from pins
+func (r *RoomService) UnpinChatMessage(req *PinChatMessageReq) error {
+	deleted, err := r.rc.HDel(r.ctx, chatPinsKey+req.RoomId, req.MessageId).Result()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return errors.New("message isn't pinned")
+	}
+
+	r.broadcastChatPins(req.RoomId)
+	if r.LoadRoomOptions(req.RoomId).PersistChat {
+		go updatePersistedChatPin(req.RoomId, &ChatMessageRecord{
+			MessageId: req.MessageId,
+			RoomId:    req.RoomId,
+		})
+	}
+	return nil
+}
+
+func (r *RoomService) saveChatPin(m *ChatMessageRecord) error {
+	marshal, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	key := chatPinsKey + m.RoomId
+	pp := r.rc.Pipeline()
+	pp.HSet(r.ctx, key, m.MessageId, marshal)
+	pp.Expire(r.ctx, key, r.RoomKeyTTL(m.RoomId))
+	_, err = pp.Exec(r.ctx)
+	return err
+}
+
+// updateChatPin will keep the pin same as edited or deleted message
+func (r *RoomService) updateChatPin(roomId, messageId string, edited *ChatMessageRecord) {
+	m, err := r.loadChatPin(roomId, messageId)
+	if err != nil || m == nil {
+		return
+	}
+	if edited == nil {
+		r.rc.HDel(r.ctx, chatPinsKey+roomId, messageId)
+	} else {
+		m.Msg, m.EditedAt = edited.Msg, edited.EditedAt
+		if err = r.saveChatPin(m); err != nil {
+			log.Errorln(err)
+		}
+	}
+	r.broadcastChatPins(roomId)
+}
+
+func (r *RoomService) loadChatPin(roomId, messageId string) (*ChatMessageRecord, error) {
+	result, err := r.rc.HGet(r.ctx, chatPinsKey+roomId, messageId).Result()
+	if err != nil {
+		return nil, err
+	}
+	m := new(ChatMessageRecord)
+	err = json.Unmarshal([]byte(result), m)
+	return m, err
+}
+
+// GetChatPins will return pinned messages, oldest pin first
+func (r *RoomService) GetChatPins(roomId string) ([]*ChatMessageRecord, error) {
+	result, err := r.rc.HGetAll(r.ctx, chatPinsKey+roomId).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pins := make([]*ChatMessageRecord, 0, len(result))
+	for _, data := range result {
+		m := new(ChatMessageRecord)
+		if err = json.Unmarshal([]byte(data), m); err == nil {
+			pins = append(pins, m)
+		}
+	}
+	sort.Slice(pins, func(i, j int) bool {
+		return pins[i].PinnedAt < pins[j].PinnedAt
+	})
+	return pins, nil
+}
+
+func (r *RoomService) DeleteChatPins(roomId string) (int64, error) {
+	return r.rc.Del(r.ctx, chatPinsKey+roomId).Result()
+}
+
+func (r *RoomService) broadcastChatPins(roomId string) {
+	pins, err := r.GetChatPins(roomId)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	broadcastSystemMsg(roomId, DataMsgBodyType_CHAT_PINS_UPDATED, &chatPinsUpdated{
+		Pins: pins,
+	})
+}
+
+// SendChatPins will send pinned messages to the user who has just joined
+func SendChatPins(roomId, userId string) {
+	pins, err := NewRoomService().GetChatPins(roomId)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	if len(pins) == 0 {
+		return
+	}
+	sendSystemMsgToUser(roomId, userId, DataMsgBodyType_CHAT_PINS_UPDATED, &chatPinsUpdated{
+		Pins: pins,
+	})
+}
+
+// updatePersistedChatPin will insert the whole message same as edit,
+// PinnedAt 0 means unpinned
+func updatePersistedChatPin(roomId string, m *ChatMessageRecord) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	table := config.AppCnf.FormatDBTable("chat_messages")
+	var err error
+	if m.PinnedAt == 0 {
+		_, err = config.AppCnf.DB.ExecContext(ctx, "UPDATE "+table+" SET pinned_at = 0 WHERE message_id = ?", m.MessageId)
+	} else {
+		if m.RoomSid == "" {
+			room, _ := NewRoomModel().GetRoomInfo(roomId, "", 1)
+			if room.Id == 0 {
+				return
+			}
+			m.RoomSid = room.Sid
+		}
+		query := "INSERT INTO " + table + " (message_id, room_id, room_sid, sender_id, sender_name, to_user_id, visibility, msg, sent_at, edited_at, pinned_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE pinned_at = VALUES(pinned_at)"
+		_, err = config.AppCnf.DB.ExecContext(ctx, query, m.MessageId, m.RoomId, m.RoomSid, m.SenderId, m.SenderName, m.ToUserId, m.Visibility, m.Msg, m.SentAt, m.EditedAt, m.PinnedAt)
+	}
+	if err != nil {
+		log.Errorln(err)
+	}
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"errors"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	log "github.com/sirupsen/logrus"
+)
+
+// policies of private chat, those will be applied for non-moderators only
+const (
+	PrivateChatEveryone   = "everyone"
+	PrivateChatModerators = "moderators"
+	PrivateChatDisabled   = "disabled"
+)
+
+func (o *RoomOptions) GetPrivateChatPolicy() string {
+	if o.PrivateChatPolicy == "" {
+		return PrivateChatEveryone
+	}
+	return o.PrivateChatPolicy
+}
+
+func isPrivateChat(msg *plugnmeet.DataMessage) bool {
+	if msg.Body.IsPrivate != nil && *msg.Body.IsPrivate == 1 {
+		return true
+	}
+	return msg.To != nil && *msg.To != ""
+}
+
+// AllowChatMessage will validate chat message received from the websocket,
+// because clients can't be trusted. Message should be dropped if false was returned,
+// user will be notified about the reason.
+func AllowChatMessage(roomId, userId, userSid string, isAdmin bool, msg *plugnmeet.DataMessage) bool {
+	err := checkChatPolicy(roomId, userId, isAdmin, msg)
+	if err == nil {
+		err = applyChatFilter(roomId, msg)
+	}
+	if err == nil {
+		return true
+	}
+
+	log.Warnln("chat message of " + userId + " in room " + roomId + " was dropped: " + err.Error())
+	if userSid != "" {
+		sendChatPolicyAlert(roomId, userSid, err)
+	}
+	return false
+}
+
+func checkChatPolicy(roomId, userId string, isAdmin bool, msg *plugnmeet.DataMessage) error {
+	if msg.Type != plugnmeet.DataMsgType_USER || msg.Body == nil || msg.Body.Type != plugnmeet.DataMsgBodyType_CHAT {
+		return nil
+	}
+	if msg.Body.From == nil || msg.Body.From.UserId != userId {
+		return errors.New("sender mismatched")
+	}
+	if isAdmin {
+		return nil
+	}
+
+	rs := NewRoomService()
+	if rs.isChatMuted(roomId, userId) {
+		return errors.New("notifications.chat-muted")
+	}
+	if err := rs.checkChatRateLimit(roomId, userId, msg); err != nil {
+		return err
+	}
+	if !isPrivateChat(msg) {
+		return nil
+	}
+
+	switch rs.LoadRoomOptions(roomId).GetPrivateChatPolicy() {
+	case PrivateChatDisabled:
+		return errors.New("notifications.private-chat-disabled")
+	case PrivateChatModerators:
+		if msg.To == nil {
+			return errors.New("notifications.private-chat-moderators-only")
+		}
+		_, meta, err := rs.LoadParticipantWithMetadata(roomId, *msg.To)
+		if err != nil || !meta.IsAdmin {
+			return errors.New("notifications.private-chat-moderators-only")
+		}
+	}
+
+	return nil
+}
+
+// sendChatPolicyAlert will let the user know why the message wasn't delivered
+func sendChatPolicyAlert(roomId, userSid string, err error) {
+	err = NewDataMessageModel().SendDataMessage(&plugnmeet.DataMessageReq{
+		MsgBodyType: plugnmeet.DataMsgBodyType_ALERT,
+		Msg:         err.Error(),
+		RoomId:      roomId,
+		SendTo:      []string{userSid},
+	})
+	if err != nil {
+		log.Errorln(err)
+	}
+}
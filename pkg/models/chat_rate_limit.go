@@ -0,0 +1,93 @@
+package models
+
+import (
+	"errors"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"time"
+)
+
+const (
+	// chatRateLimitKey keeps messages of the user within the window as sorted set
+	chatRateLimitKey = "pnm:chat_rate_limit:"
+	// chatRateViolationsKey counts throttled messages of the user within the window
+	chatRateViolationsKey  = "pnm:chat_rate_violations:"
+	chatRateLimitWindow    = time.Minute
+	defaultChatAbuseNotify = 3
+)
+
+// chatFloodingUser will be sent to the moderators
+type chatFloodingUser struct {
+	UserId     string `json:"user_id"`
+	Name       string `json:"name"`
+	Violations int64  `json:"violations"`
+}
+
+// checkChatRateLimit will throttle the user if messages per minute was exceeded.
+// Moderators will be notified once if the user keeps sending.
+func (r *RoomService) checkChatRateLimit(roomId, userId string, msg *plugnmeet.DataMessage) error {
+	conf := config.AppCnf.Client.ChatRateLimit
+	if conf.MessagesPerMinute <= 0 {
+		return nil
+	}
+
+	key := chatRateLimitKey + roomId + ":" + userId
+	now := time.Now()
+	member := strconv.FormatInt(now.UnixNano(), 10) + "-" + randomHex(4)
+	wait, err := slidingWindowScript.Run(r.ctx, r.rc, []string{key}, now.UnixMilli(), chatRateLimitWindow.Milliseconds(), member, conf.MessagesPerMinute).Int64()
+	if err != nil {
+		log.Errorln(err)
+		return nil
+	}
+	if wait <= 0 {
+		return nil
+	}
+
+	vKey := chatRateViolationsKey + roomId + ":" + userId
+	pp := r.rc.Pipeline()
+	violations := pp.Incr(r.ctx, vKey)
+	pp.Expire(r.ctx, vKey, chatRateLimitWindow)
+	if _, err = pp.Exec(r.ctx); err != nil {
+		log.Errorln(err)
+	}
+
+	notifyAt := int64(conf.NotifyModeratorsAfter)
+	if notifyAt <= 0 {
+		notifyAt = defaultChatAbuseNotify
+	}
+	if violations.Val() == notifyAt {
+		u := &chatFloodingUser{
+			UserId:     userId,
+			Violations: violations.Val(),
+		}
+		if msg.Body.From != nil {
+			u.Name = msg.Body.From.GetName()
+		}
+		go sendSystemMsgToModerators(roomId, DataMsgBodyType_CHAT_FLOODING, u)
+		r.AddRoomTimelineEvent(roomId, &RoomTimelineEvent{
+			Type:   "chat_flooding",
+			UserId: userId,
+		})
+	}
+
+	return errors.New("notifications.chat-rate-limited")
+}
+
+// sendSystemMsgToModerators will send the value to all active moderators of the room
+func sendSystemMsgToModerators(roomId string, mType plugnmeet.DataMsgBodyType, v interface{}) {
+	participants, err := NewRoomService().LoadParticipants(roomId)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	for _, p := range participants {
+		meta := new(plugnmeet.UserMetadata)
+		if err = json.Unmarshal([]byte(p.Metadata), meta); err != nil || !meta.IsAdmin {
+			continue
+		}
+		sendSystemMsgToUser(roomId, p.Identity, mType, v)
+	}
+}
@@ -0,0 +1,217 @@
+package models
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	TranslationProviderGoogle = "google"
+	TranslationProviderDeepl  = "deepl"
+	TranslationProviderLibre  = "libretranslate"
+
+	// chatTranslationLangKey keeps preferred language of the users, field is user id
+	chatTranslationLangKey = "pnm:chat_translation_lang:"
+	// chatTranslationKey is the cache of message + language pair
+	chatTranslationKey           = "pnm:chat_translation:"
+	defaultChatTranslationTTL    = time.Hour
+	chatTranslationTimeout       = 10 * time.Second
+	chatTranslationMaxMessageLen = 2000
+)
+
+// TranslationProvider translates text to the target language, source language will be detected
+type TranslationProvider interface {
+	Translate(ctx context.Context, text, targetLang string) (string, error)
+}
+
+type SetChatTranslationReq struct {
+	RoomId string `json:"-"`
+	UserId string `json:"-"`
+	// Language e.g. en, de, pt-BR. Empty will stop translation
+	Language string `json:"language" validate:"omitempty,min=2,max=10"`
+}
+
+// chatTranslation will be sent to users those have opted in
+type chatTranslation struct {
+	MessageId string `json:"message_id"`
+	Language  string `json:"language"`
+	Msg       string `json:"msg"`
+}
+
+// chatTranslationProvider of the deployment will be created once from config
+var chatTranslationProvider = struct {
+	sync.Once
+	provider TranslationProvider
+	err      error
+}{}
+
+func newTranslationProvider() (TranslationProvider, error) {
+	conf := config.AppCnf.Client.ChatTranslation
+	switch conf.Provider {
+	case TranslationProviderGoogle:
+		return newGoogleTranslator(conf.Google)
+	case TranslationProviderDeepl:
+		return newDeeplTranslator(conf.Deepl)
+	case TranslationProviderLibre:
+		return newLibreTranslator(conf.LibreTranslate)
+	}
+	return nil, errors.New("unknown translation provider: " + conf.Provider)
+}
+
+func loadTranslationProvider() (TranslationProvider, error) {
+	chatTranslationProvider.Do(func() {
+		chatTranslationProvider.provider, chatTranslationProvider.err = newTranslationProvider()
+	})
+	return chatTranslationProvider.provider, chatTranslationProvider.err
+}
+
+// SetChatTranslation will set or remove preferred language of the user
+func (r *RoomService) SetChatTranslation(req *SetChatTranslationReq) error {
+	if !config.AppCnf.Client.ChatTranslation.Enabled {
+		return errors.New("chat translation isn't enabled")
+	}
+
+	key := chatTranslationLangKey + req.RoomId
+	if req.Language == "" {
+		return r.rc.HDel(r.ctx, key, req.UserId).Err()
+	}
+	pp := r.rc.Pipeline()
+	pp.HSet(r.ctx, key, req.UserId, req.Language)
+	pp.Expire(r.ctx, key, r.RoomKeyTTL(req.RoomId))
+	_, err := pp.Exec(r.ctx)
+	return err
+}
+
+func (r *RoomService) DeleteChatTranslationLanguages(roomId string) (int64, error) {
+	return r.rc.Del(r.ctx, chatTranslationLangKey+roomId).Result()
+}
+
+// TranslateChatMessage will send translation of the chat message to the receivers
+// those have opted in, translation will be done once per language
+func TranslateChatMessage(roomId string, msg *plugnmeet.DataMessage) {
+	if !config.AppCnf.Client.ChatTranslation.Enabled {
+		return
+	}
+	if msg.Type != plugnmeet.DataMsgType_USER || msg.Body == nil || msg.Body.From == nil {
+		return
+	}
+
+	var messageId, text string
+	switch msg.Body.Type {
+	case plugnmeet.DataMsgBodyType_CHAT:
+		messageId, text = msg.GetMessageId(), msg.Body.Msg
+	case DataMsgBodyType_EDIT_MESSAGE:
+		// body was validated & rewritten by the server
+		action := new(chatMessageAction)
+		if err := json.Unmarshal([]byte(msg.Body.Msg), action); err != nil {
+			return
+		}
+		messageId, text = action.MessageId, action.Msg
+	default:
+		return
+	}
+	if text == "" || len(text) > chatTranslationMaxMessageLen {
+		return
+	}
+
+	rs := NewRoomService()
+	langs, err := rs.rc.HGetAll(rs.ctx, chatTranslationLangKey+roomId).Result()
+	if err != nil || len(langs) == 0 {
+		return
+	}
+
+	receivers := make(map[string][]string)
+	for userId, lang := range langs {
+		if userId == msg.Body.From.UserId {
+			continue
+		}
+		if msg.To != nil && *msg.To != "" && *msg.To != userId {
+			continue
+		}
+		receivers[lang] = append(receivers[lang], userId)
+	}
+	if len(receivers) == 0 {
+		return
+	}
+
+	provider, err := loadTranslationProvider()
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	for lang, users := range receivers {
+		translated, err := rs.translateChatText(provider, messageId, text, lang)
+		if err != nil {
+			log.Errorln("chat translation to " + lang + " failed: " + err.Error())
+			continue
+		}
+		for _, userId := range users {
+			sendSystemMsgToUser(roomId, userId, DataMsgBodyType_CHAT_TRANSLATION, &chatTranslation{
+				MessageId: messageId,
+				Language:  lang,
+				Msg:       translated,
+			})
+		}
+	}
+}
+
+// translateChatText will use cache if the same message was translated to the language before.
+// Hash of the text is part of the key, so that edited message will be translated again.
+func (r *RoomService) translateChatText(provider TranslationProvider, messageId, text, lang string) (string, error) {
+	sum := sha1.Sum([]byte(text))
+	key := chatTranslationKey + messageId + ":" + lang + ":" + hex.EncodeToString(sum[:4])
+
+	cached, err := r.rc.Get(r.ctx, key).Result()
+	if err == nil {
+		return cached, nil
+	} else if err != redis.Nil {
+		log.Errorln(err)
+	}
+
+	ctx, cancel := context.WithTimeout(r.ctx, chatTranslationTimeout)
+	defer cancel()
+	translated, err := provider.Translate(ctx, text, lang)
+	if err != nil {
+		return "", err
+	}
+
+	ttl := config.AppCnf.Client.ChatTranslation.CacheTTL
+	if ttl <= 0 {
+		ttl = defaultChatTranslationTTL
+	}
+	r.rc.Set(r.ctx, key, translated, ttl)
+
+	return translated, nil
+}
+
+func translationDo(client *http.Client, req *http.Request) ([]byte, error) {
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 300 {
+		if len(body) > 1024 {
+			body = body[:1024]
+		}
+		return nil, errors.New(fmt.Sprintf("translation request failed with status %d: %s", res.StatusCode, string(body)))
+	}
+	return body, nil
+}
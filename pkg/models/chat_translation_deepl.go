@@ -0,0 +1,71 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	deeplFreeEndpoint = "https://api-free.deepl.com/v2"
+	deeplProEndpoint  = "https://api.deepl.com/v2"
+)
+
+type deeplTranslator struct {
+	conf   config.DeeplTranslateConf
+	url    string
+	client *http.Client
+}
+
+func newDeeplTranslator(conf config.DeeplTranslateConf) (*deeplTranslator, error) {
+	if conf.ApiKey == "" {
+		return nil, errors.New("deepl api_key is required for translation")
+	}
+	u := conf.Url
+	if u == "" {
+		// keys of free plan end with :fx
+		u = deeplProEndpoint
+		if strings.HasSuffix(conf.ApiKey, ":fx") {
+			u = deeplFreeEndpoint
+		}
+	}
+	return &deeplTranslator{
+		conf:   conf,
+		url:    strings.TrimSuffix(u, "/"),
+		client: &http.Client{},
+	}, nil
+}
+
+func (d *deeplTranslator) Translate(ctx context.Context, text, targetLang string) (string, error) {
+	form := url.Values{}
+	form.Set("text", text)
+	form.Set("target_lang", strings.ToUpper(targetLang))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url+"/translate", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "DeepL-Auth-Key "+d.conf.ApiKey)
+	body, err := translationDo(d.client, req)
+	if err != nil {
+		return "", err
+	}
+
+	res := struct {
+		Translations []struct {
+			Text string `json:"text"`
+		} `json:"translations"`
+	}{}
+	if err = json.Unmarshal(body, &res); err != nil {
+		return "", err
+	}
+	if len(res.Translations) == 0 {
+		return "", errors.New("deepl returned empty translation")
+	}
+	return res.Translations[0].Text, nil
+}
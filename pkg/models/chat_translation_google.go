@@ -0,0 +1,66 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"html"
+	"net/http"
+	"net/url"
+)
+
+const googleTranslateEndpoint = "https://translation.googleapis.com/language/translate/v2"
+
+// googleTranslator uses Google Cloud Translation v2 (basic)
+type googleTranslator struct {
+	conf   config.GoogleTranslateConf
+	client *http.Client
+}
+
+func newGoogleTranslator(conf config.GoogleTranslateConf) (*googleTranslator, error) {
+	if conf.ApiKey == "" {
+		return nil, errors.New("google api_key is required for translation")
+	}
+	return &googleTranslator{
+		conf:   conf,
+		client: &http.Client{},
+	}, nil
+}
+
+func (g *googleTranslator) Translate(ctx context.Context, text, targetLang string) (string, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"q":      []string{text},
+		"target": targetLang,
+		"format": "text",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, googleTranslateEndpoint+"?key="+url.QueryEscape(g.conf.ApiKey), bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	body, err := translationDo(g.client, req)
+	if err != nil {
+		return "", err
+	}
+
+	res := struct {
+		Data struct {
+			Translations []struct {
+				TranslatedText string `json:"translatedText"`
+			} `json:"translations"`
+		} `json:"data"`
+	}{}
+	if err = json.Unmarshal(body, &res); err != nil {
+		return "", err
+	}
+	if len(res.Data.Translations) == 0 {
+		return "", errors.New("google returned empty translation")
+	}
+	return html.UnescapeString(res.Data.Translations[0].TranslatedText), nil
+}
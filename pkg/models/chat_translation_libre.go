@@ -0,0 +1,59 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"net/http"
+	"strings"
+)
+
+// libreTranslator can be used with self-hosted LibreTranslate server
+type libreTranslator struct {
+	conf   config.LibreTranslateConf
+	client *http.Client
+}
+
+func newLibreTranslator(conf config.LibreTranslateConf) (*libreTranslator, error) {
+	if conf.Url == "" {
+		return nil, errors.New("libretranslate url is required for translation")
+	}
+	conf.Url = strings.TrimSuffix(conf.Url, "/")
+	return &libreTranslator{
+		conf:   conf,
+		client: &http.Client{},
+	}, nil
+}
+
+func (l *libreTranslator) Translate(ctx context.Context, text, targetLang string) (string, error) {
+	payload, err := json.Marshal(map[string]string{
+		"q":       text,
+		"source":  "auto",
+		"target":  targetLang,
+		"format":  "text",
+		"api_key": l.conf.ApiKey,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.conf.Url+"/translate", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	body, err := translationDo(l.client, req)
+	if err != nil {
+		return "", err
+	}
+
+	res := struct {
+		TranslatedText string `json:"translatedText"`
+	}{}
+	if err = json.Unmarshal(body, &res); err != nil {
+		return "", err
+	}
+	return res.TranslatedText, nil
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"context"
+	"github.com/livekit/protocol/livekit"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// layouts of the composite recording & broadcasting
+const (
+	CompositeLayoutGrid    = "grid"
+	CompositeLayoutSpeaker = "speaker"
+	// CompositeLayoutPresentation will show presentation or screen share with thumbnails of participants
+	CompositeLayoutPresentation = "presentation"
+)
+
+// compositeEgressLayouts are templates of livekit egress for the layouts
+var compositeEgressLayouts = map[string]string{
+	CompositeLayoutGrid:         "grid-dark",
+	CompositeLayoutSpeaker:      "single-speaker-dark",
+	CompositeLayoutPresentation: "speaker-dark",
+}
+
+type SetCompositeLayoutReq struct {
+	Layout string `json:"layout" validate:"required,oneof=grid speaker presentation"`
+}
+
+type compositeLayoutUpdated struct {
+	Layout string `json:"layout"`
+}
+
+// egressLayout will return template for livekit egress,
+// layout selected for the room will get priority over configured one
+func (rm *recordingModel) egressLayout(roomId, configured string) string {
+	if l, ok := compositeEgressLayouts[rm.roomService.LoadRoomOptions(roomId).CompositeLayout]; ok {
+		return l
+	}
+	if configured != "" {
+		return configured
+	}
+	return defaultRtmpLayout
+}
+
+// SetCompositeLayout will store the layout in room metadata, so that the recorder
+// can render it & update layout of running egresses
+func (rm *recordingModel) SetCompositeLayout(roomId, layout string) error {
+	room, meta, err := rm.roomService.LoadRoomWithMetadata(roomId)
+	if err != nil {
+		return err
+	}
+
+	opts := rm.roomService.LoadRoomOptions(roomId)
+	opts.CompositeLayout = layout
+	if err = rm.roomService.SaveRoomOptions(roomId, opts); err != nil {
+		return err
+	}
+	// metadata will include the layout from room options
+	if _, err = rm.roomService.UpdateRoomMetadataByStruct(roomId, meta); err != nil {
+		return err
+	}
+	broadcastSystemMsg(roomId, DataMsgBodyType_COMPOSITE_LAYOUT_UPDATED, &compositeLayoutUpdated{
+		Layout: layout,
+	})
+
+	// slate will be kept until the broadcast resumes
+	if opts.BroadcastSlate == nil {
+		rm.updateEgressLayouts(room.Sid, compositeEgressLayouts[layout], compositeEgressLayouts[layout])
+	}
+
+	return nil
+}
+
+// updateEgressLayouts will change layout of running rtmp & hls egresses of the session,
+// errors will be logged only
+func (rm *recordingModel) updateEgressLayouts(roomSid, rtmpLayout, hlsLayout string) {
+	layouts := make(map[string]string)
+	if egressId, _ := rm.rds.Get(rm.ctx, rtmpEgressKey+roomSid).Result(); egressId != "" {
+		layouts[egressId] = rtmpLayout
+	}
+	if s, err := rm.loadHlsStream(roomSid); err == nil && s.Status == HlsStatusActive {
+		layouts[s.EgressId] = hlsLayout
+	}
+
+	for egressId, layout := range layouts {
+		ctx, cancel := context.WithTimeout(rm.ctx, 10*time.Second)
+		_, err := newEgressClient().UpdateLayout(ctx, &livekit.UpdateLayoutRequest{
+			EgressId: egressId,
+			Layout:   layout,
+		})
+		cancel()
+		if err != nil {
+			log.Errorln(err)
+		}
+	}
+}
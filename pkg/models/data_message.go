@@ -33,14 +33,16 @@ type ReqFrom struct {
 }
 
 type dataMessageModel struct {
-	db          *sql.DB
-	roomService *RoomService
+	db             *sql.DB
+	roomService    *RoomService
+	raiseHandModel *raiseHandModel
 }
 
 func NewDataMessageModel() *dataMessageModel {
 	return &dataMessageModel{
-		db:          config.AppCnf.DB,
-		roomService: NewRoomService(),
+		db:             config.AppCnf.DB,
+		roomService:    NewRoomService(),
+		raiseHandModel: NewRaiseHandModel(),
 	}
 }
 
@@ -75,16 +77,13 @@ func (m *dataMessageModel) raiseHand(r *plugnmeet.DataMessageReq) error {
 		}
 	}
 
-	reqPar, _ := m.roomService.LoadParticipantInfo(r.RoomId, r.RequestedUserId)
-
-	// now update user's metadata
-	metadata := new(plugnmeet.UserMetadata)
-	_ = json.Unmarshal([]byte(reqPar.Metadata), metadata)
-
-	metadata.RaisedHand = true
-	newMeta, err := json.Marshal(metadata)
+	reqPar, err := m.roomService.LoadParticipantInfo(r.RoomId, r.RequestedUserId)
+	if err != nil {
+		return err
+	}
 
-	_, err = m.roomService.UpdateParticipantMetadata(r.RoomId, r.RequestedUserId, string(newMeta))
+	// this will update user's metadata & queue
+	err = m.raiseHandModel.RaiseHand(r.RoomId, r.RequestedUserId)
 	if err != nil {
 		return err
 	}
@@ -126,24 +125,7 @@ func (m *dataMessageModel) raiseHand(r *plugnmeet.DataMessageReq) error {
 }
 
 func (m *dataMessageModel) lowerHand(r *plugnmeet.DataMessageReq) error {
-	reqPar, err := m.roomService.LoadParticipantInfo(r.RoomId, r.RequestedUserId)
-	if err != nil {
-		return err
-	}
-
-	// now update user's metadata
-	metadata := new(plugnmeet.UserMetadata)
-	_ = json.Unmarshal([]byte(reqPar.Metadata), metadata)
-
-	metadata.RaisedHand = false
-	newMeta, err := json.Marshal(metadata)
-
-	_, err = m.roomService.UpdateParticipantMetadata(r.RoomId, r.RequestedUserId, string(newMeta))
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return m.raiseHandModel.LowerHand(r.RoomId, r.RequestedUserId)
 }
 
 func (m *dataMessageModel) otherUserLowerHand(r *plugnmeet.DataMessageReq) error {
@@ -152,24 +134,7 @@ func (m *dataMessageModel) otherUserLowerHand(r *plugnmeet.DataMessageReq) error
 	}
 	userId := r.Msg
 
-	reqPar, err := m.roomService.LoadParticipantInfo(r.RoomId, userId)
-	if err != nil {
-		return err
-	}
-
-	// now update user's metadata
-	metadata := new(plugnmeet.UserMetadata)
-	_ = json.Unmarshal([]byte(reqPar.Metadata), metadata)
-
-	metadata.RaisedHand = false
-	newMeta, err := json.Marshal(metadata)
-
-	_, err = m.roomService.UpdateParticipantMetadata(r.RoomId, userId, string(newMeta))
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return m.raiseHandModel.LowerHand(r.RoomId, userId)
 }
 
 func (m *dataMessageModel) sendNotification(r *plugnmeet.DataMessageReq) error {
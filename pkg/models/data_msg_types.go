@@ -0,0 +1,126 @@
+package models
+
+import (
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	log "github.com/sirupsen/logrus"
+)
+
+// additional body types those aren't part of plugnmeet-protocol yet.
+// To avoid any conflict with upstream values, we'll start from 100.
+// Clients depend on these values, so never change or reuse any of them,
+// add new types here only & move them to plugnmeet-protocol when it will be upgraded.
+const (
+	DataMsgBodyType_SPEAKER_QUEUE_UPDATED          plugnmeet.DataMsgBodyType = 100
+	DataMsgBodyType_RAISE_HAND_QUEUE_UPDATED       plugnmeet.DataMsgBodyType = 101
+	DataMsgBodyType_ROOM_END_REASON                plugnmeet.DataMsgBodyType = 102
+	DataMsgBodyType_MOVE_TO_ROOM                   plugnmeet.DataMsgBodyType = 103
+	DataMsgBodyType_USER_REMOVED                   plugnmeet.DataMsgBodyType = 104
+	DataMsgBodyType_WAITING_FOR_HOST               plugnmeet.DataMsgBodyType = 105
+	DataMsgBodyType_ROOM_LAYOUT_UPDATED            plugnmeet.DataMsgBodyType = 106
+	DataMsgBodyType_RECORDING_CONSENT_REQUEST      plugnmeet.DataMsgBodyType = 107
+	DataMsgBodyType_COMPOSITE_LAYOUT_UPDATED       plugnmeet.DataMsgBodyType = 108
+	DataMsgBodyType_BROADCAST_SLATE_UPDATED        plugnmeet.DataMsgBodyType = 109
+	DataMsgBodyType_CHAT_MESSAGE_DELETED           plugnmeet.DataMsgBodyType = 110
+	DataMsgBodyType_CHAT_MUTE_UPDATED              plugnmeet.DataMsgBodyType = 111
+	DataMsgBodyType_CHAT_MESSAGE_FLAGGED           plugnmeet.DataMsgBodyType = 112
+	DataMsgBodyType_REACTION                       plugnmeet.DataMsgBodyType = 113
+	DataMsgBodyType_REACTION_COUNTS                plugnmeet.DataMsgBodyType = 114
+	DataMsgBodyType_CHAT_FLOODING                  plugnmeet.DataMsgBodyType = 115
+	DataMsgBodyType_EDIT_MESSAGE                   plugnmeet.DataMsgBodyType = 116
+	DataMsgBodyType_DELETE_MESSAGE                 plugnmeet.DataMsgBodyType = 117
+	DataMsgBodyType_CHAT_TRANSLATION               plugnmeet.DataMsgBodyType = 118
+	DataMsgBodyType_CHAT_HISTORY                   plugnmeet.DataMsgBodyType = 119
+	DataMsgBodyType_CHAT_PINS_UPDATED              plugnmeet.DataMsgBodyType = 120
+	DataMsgBodyType_CHAT_LINK_PREVIEW              plugnmeet.DataMsgBodyType = 121
+	DataMsgBodyType_ANNOUNCEMENT                   plugnmeet.DataMsgBodyType = 122
+	DataMsgBodyType_POLL_RESULTS                   plugnmeet.DataMsgBodyType = 123
+	DataMsgBodyType_POLL_TEXT_PENDING              plugnmeet.DataMsgBodyType = 124
+	DataMsgBodyType_WHITEBOARD_STATE               plugnmeet.DataMsgBodyType = 125
+	DataMsgBodyType_WHITEBOARD_CONVERSION_PROGRESS plugnmeet.DataMsgBodyType = 126
+	DataMsgBodyType_WHITEBOARD_PAGES_UPDATED       plugnmeet.DataMsgBodyType = 127
+	DataMsgBodyType_WHITEBOARD_DRAW_PERMISSIONS    plugnmeet.DataMsgBodyType = 128
+	DataMsgBodyType_SCREEN_ANNOTATION              plugnmeet.DataMsgBodyType = 129
+	DataMsgBodyType_SCREEN_ANNOTATION_UPDATED      plugnmeet.DataMsgBodyType = 130
+	DataMsgBodyType_SCREEN_ANNOTATIONS_CLEARED     plugnmeet.DataMsgBodyType = 131
+	DataMsgBodyType_LASER_POINTER                  plugnmeet.DataMsgBodyType = 132
+)
+
+// relaySystemMsgTypes are sent by the server only & clients need them as it is,
+// so websocket will simply relay them to the room or the user
+var relaySystemMsgTypes = map[plugnmeet.DataMsgBodyType]bool{
+	DataMsgBodyType_SPEAKER_QUEUE_UPDATED:          true,
+	DataMsgBodyType_RAISE_HAND_QUEUE_UPDATED:       true,
+	DataMsgBodyType_ROOM_END_REASON:                true,
+	DataMsgBodyType_MOVE_TO_ROOM:                   true,
+	DataMsgBodyType_USER_REMOVED:                   true,
+	DataMsgBodyType_WAITING_FOR_HOST:               true,
+	DataMsgBodyType_ROOM_LAYOUT_UPDATED:            true,
+	DataMsgBodyType_RECORDING_CONSENT_REQUEST:      true,
+	DataMsgBodyType_COMPOSITE_LAYOUT_UPDATED:       true,
+	DataMsgBodyType_BROADCAST_SLATE_UPDATED:        true,
+	DataMsgBodyType_CHAT_MESSAGE_DELETED:           true,
+	DataMsgBodyType_CHAT_MUTE_UPDATED:              true,
+	DataMsgBodyType_CHAT_MESSAGE_FLAGGED:           true,
+	DataMsgBodyType_REACTION_COUNTS:                true,
+	DataMsgBodyType_CHAT_FLOODING:                  true,
+	DataMsgBodyType_CHAT_TRANSLATION:               true,
+	DataMsgBodyType_CHAT_HISTORY:                   true,
+	DataMsgBodyType_CHAT_PINS_UPDATED:              true,
+	DataMsgBodyType_CHAT_LINK_PREVIEW:              true,
+	DataMsgBodyType_ANNOUNCEMENT:                   true,
+	DataMsgBodyType_POLL_RESULTS:                   true,
+	DataMsgBodyType_POLL_TEXT_PENDING:              true,
+	DataMsgBodyType_WHITEBOARD_STATE:               true,
+	DataMsgBodyType_WHITEBOARD_CONVERSION_PROGRESS: true,
+	DataMsgBodyType_WHITEBOARD_PAGES_UPDATED:       true,
+	DataMsgBodyType_WHITEBOARD_DRAW_PERMISSIONS:    true,
+	DataMsgBodyType_SCREEN_ANNOTATION_UPDATED:      true,
+	DataMsgBodyType_SCREEN_ANNOTATIONS_CLEARED:     true,
+}
+
+func isRelaySystemMsgType(t plugnmeet.DataMsgBodyType) bool {
+	return relaySystemMsgTypes[t]
+}
+
+// IsForgedSystemMsg will return true if the client has sent a body type
+// which can only be sent by the server
+func IsForgedSystemMsg(msg *plugnmeet.DataMessage) bool {
+	return msg.Body != nil && isRelaySystemMsgType(msg.Body.Type)
+}
+
+// broadcastSystemMsg will send the value as json to everyone of the room using websocket
+func broadcastSystemMsg(roomId string, mType plugnmeet.DataMsgBodyType, v interface{}) {
+	sendSystemMsgToUser(roomId, "", mType, v)
+}
+
+// sendSystemMsgToUser will send the value as json to the user only,
+// if userId is empty then everyone of the room will receive
+func sendSystemMsgToUser(roomId, userId string, mType plugnmeet.DataMsgBodyType, v interface{}) {
+	marshal, err := json.Marshal(v)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	payload := &plugnmeet.DataMessage{
+		Type:   plugnmeet.DataMsgType_SYSTEM,
+		RoomId: roomId,
+		Body: &plugnmeet.DataMsgBody{
+			Type: mType,
+			From: &plugnmeet.DataMsgReqFrom{
+				Sid: "SYSTEM",
+			},
+			Msg: string(marshal),
+		},
+	}
+	if userId != "" {
+		payload.To = &userId
+	}
+
+	DistributeWebsocketMsgToRedisChannel(&WebsocketToRedis{
+		Type:    "sendMsg",
+		DataMsg: payload,
+		RoomId:  roomId,
+	})
+}
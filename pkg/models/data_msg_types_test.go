@@ -0,0 +1,92 @@
+package models
+
+import (
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+// TestSystemMsgTypesAreRelayable makes sure every type sent by the server
+// using system message helpers is a part of relaySystemMsgTypes
+func TestSystemMsgTypesAreRelayable(t *testing.T) {
+	// position of mType argument
+	helpers := map[string]int{
+		"broadcastSystemMsg":        1,
+		"sendSystemMsgToUser":       2,
+		"sendSystemMsgToModerators": 1,
+	}
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	relayable := make(map[string]bool)
+	var sent []*ast.CallExpr
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			ast.Inspect(f, func(n ast.Node) bool {
+				switch x := n.(type) {
+				case *ast.ValueSpec:
+					if len(x.Names) == 1 && x.Names[0].Name == "relaySystemMsgTypes" {
+						for _, e := range x.Values[0].(*ast.CompositeLit).Elts {
+							relayable[types.ExprString(e.(*ast.KeyValueExpr).Key)] = true
+						}
+					}
+				case *ast.CallExpr:
+					if fn, ok := x.Fun.(*ast.Ident); ok {
+						if _, ok = helpers[fn.Name]; ok {
+							sent = append(sent, x)
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+
+	found := 0
+	for _, call := range sent {
+		arg := types.ExprString(call.Args[helpers[call.Fun.(*ast.Ident).Name]])
+		if !strings.Contains(arg, "DataMsgBodyType_") {
+			// forwarded by another helper
+			continue
+		}
+		found++
+		if !relayable[arg] {
+			t.Errorf("%s: %s isn't relayable", fset.Position(call.Pos()), arg)
+		}
+	}
+
+	if found == 0 {
+		t.Fatal("no system message was found")
+	}
+}
+
+func TestIsForgedSystemMsg(t *testing.T) {
+	tests := []struct {
+		msg  *plugnmeet.DataMessage
+		want bool
+	}{
+		{&plugnmeet.DataMessage{Type: plugnmeet.DataMsgType_SYSTEM, Body: &plugnmeet.DataMsgBody{Type: DataMsgBodyType_USER_REMOVED}}, true},
+		{&plugnmeet.DataMessage{Type: plugnmeet.DataMsgType_USER, Body: &plugnmeet.DataMsgBody{Type: DataMsgBodyType_ANNOUNCEMENT}}, true},
+		{&plugnmeet.DataMessage{Type: plugnmeet.DataMsgType_SYSTEM, Body: &plugnmeet.DataMsgBody{Type: DataMsgBodyType_SCREEN_ANNOTATIONS_CLEARED}}, true},
+		{&plugnmeet.DataMessage{Type: plugnmeet.DataMsgType_USER, Body: &plugnmeet.DataMsgBody{Type: plugnmeet.DataMsgBodyType_CHAT}}, false},
+		{&plugnmeet.DataMessage{Type: plugnmeet.DataMsgType_USER, Body: &plugnmeet.DataMsgBody{Type: DataMsgBodyType_REACTION}}, false},
+		{&plugnmeet.DataMessage{Type: plugnmeet.DataMsgType_SYSTEM}, false},
+	}
+
+	for _, tt := range tests {
+		if got := IsForgedSystemMsg(tt.msg); got != tt.want {
+			t.Errorf("IsForgedSystemMsg(%s) = %v, want %v", tt.msg.String(), got, tt.want)
+		}
+	}
+}
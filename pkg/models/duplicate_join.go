@@ -0,0 +1,177 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"time"
+)
+
+const (
+	activeIdentitiesKey = "pnm:active_identities:"
+	// identityClientsKey keeps the client of the last verified connection of identity
+	identityClientsKey = "pnm:identity_clients:"
+	// identityReplacesKey keeps the deadline till new connection can replace the old one
+	identityReplacesKey = "pnm:identity_replaces:"
+	// suffixedIdentitiesKey keeps the user id of suffixed identity
+	suffixedIdentitiesKey  = "pnm:suffixed_identities:"
+	identityReplaceTimeout = time.Minute
+)
+
+// policies for second connection with the same identity
+const (
+	// DuplicateJoinReplaceOld is the default behavior of livekit
+	DuplicateJoinReplaceOld   = "replace_old"
+	DuplicateJoinRejectNew    = "reject_new"
+	DuplicateJoinAllowSuffix  = "allow_suffix"
+	maxDuplicateJoinSuffixNum = 20
+)
+
+func (o *RoomOptions) GetDuplicateJoinPolicy() string {
+	switch o.DuplicateJoinPolicy {
+	case DuplicateJoinRejectNew, DuplicateJoinAllowSuffix:
+		return o.DuplicateJoinPolicy
+	}
+	return DuplicateJoinReplaceOld
+}
+
+// ResolveDuplicateJoin will be used during token verification.
+// It will return the identity which should be used to join livekit.
+// client is used to detect page reload of the same user, e.g. ip & user agent
+func (r *RoomService) ResolveDuplicateJoin(roomId, identity, client string) (string, error) {
+	policy := r.LoadRoomOptions(roomId).GetDuplicateJoinPolicy()
+	if policy == DuplicateJoinReplaceOld || !r.isIdentityActive(roomId, identity) {
+		r.saveIdentityClient(roomId, identity, client)
+		return identity, nil
+	}
+
+	if policy == DuplicateJoinRejectNew {
+		// page reload of the same user will replace the old connection
+		if r.getIdentityClient(roomId, identity) != client {
+			return "", errors.New("notifications.duplicate-join-rejected")
+		}
+		r.AllowIdentityReplace(roomId, identity)
+		return identity, nil
+	}
+
+	for i := 2; i <= maxDuplicateJoinSuffixNum; i++ {
+		id := fmt.Sprintf("%s_%d", identity, i)
+		if !r.isIdentityActive(roomId, id) {
+			key := suffixedIdentitiesKey + roomId
+			pp := r.rc.Pipeline()
+			pp.HSet(r.ctx, key, id, identity)
+			pp.Expire(r.ctx, key, r.RoomKeyTTL(roomId))
+			_, _ = pp.Exec(r.ctx)
+			return id, nil
+		}
+	}
+
+	return "", errors.New("notifications.too-many-connections-with-same-user")
+}
+
+// AllowIdentityReplace will let the next connection of identity replace the old one
+// even if the room is using reject_new policy
+func (r *RoomService) AllowIdentityReplace(roomId, identity string) {
+	key := identityReplacesKey + roomId
+	pp := r.rc.Pipeline()
+	pp.HSet(r.ctx, key, identity, time.Now().Add(identityReplaceTimeout).Unix())
+	pp.Expire(r.ctx, key, r.RoomKeyTTL(roomId))
+	_, _ = pp.Exec(r.ctx)
+}
+
+func (r *RoomService) consumeIdentityReplace(roomId, identity string) bool {
+	key := identityReplacesKey + roomId
+	deadline, err := r.rc.HGet(r.ctx, key, identity).Result()
+	if err != nil {
+		return false
+	}
+	r.rc.HDel(r.ctx, key, identity)
+
+	ts, _ := strconv.ParseInt(deadline, 10, 64)
+	return ts >= time.Now().Unix()
+}
+
+func (r *RoomService) saveIdentityClient(roomId, identity, client string) {
+	key := identityClientsKey + roomId
+	pp := r.rc.Pipeline()
+	pp.HSet(r.ctx, key, identity, client)
+	pp.Expire(r.ctx, key, r.RoomKeyTTL(roomId))
+	_, _ = pp.Exec(r.ctx)
+}
+
+func (r *RoomService) getIdentityClient(roomId, identity string) string {
+	client, _ := r.rc.HGet(r.ctx, identityClientsKey+roomId, identity).Result()
+	return client
+}
+
+// GetUserIdByIdentity will return the user id of the identity
+// because with allow_suffix policy the identity can be different
+func (r *RoomService) GetUserIdByIdentity(roomId, identity string) string {
+	userId, err := r.rc.HGet(r.ctx, suffixedIdentitiesKey+roomId, identity).Result()
+	if err != nil || userId == "" {
+		return identity
+	}
+	return userId
+}
+
+// getUserIdentities will return all the identities of the user
+func (r *RoomService) getUserIdentities(roomId, userId string) []string {
+	identities := []string{userId}
+	suffixed, err := r.rc.HGetAll(r.ctx, suffixedIdentitiesKey+roomId).Result()
+	if err != nil {
+		log.Errorln(err)
+		return identities
+	}
+	for id, uid := range suffixed {
+		if uid == userId {
+			identities = append(identities, id)
+		}
+	}
+	return identities
+}
+
+func (r *RoomService) isIdentityActive(roomId, identity string) bool {
+	exist, err := r.rc.HExists(r.ctx, activeIdentitiesKey+roomId, identity).Result()
+	if err != nil {
+		log.Errorln(err)
+		return false
+	}
+	return exist
+}
+
+// trackActiveIdentity & untrackActiveIdentity will be used from webhook.
+// we'll store participant sid so that if livekit replaced old connection,
+// the left event of old connection won't remove the new one.
+func (r *RoomService) trackActiveIdentity(roomId, identity, sid string) {
+	key := activeIdentitiesKey + roomId
+	old, _ := r.rc.HGet(r.ctx, key, identity).Result()
+	if old != "" && old != sid {
+		policy := r.LoadRoomOptions(roomId).GetDuplicateJoinPolicy()
+		log.Infoln(fmt.Sprintf("duplicate join of %s in room %s, policy: %s", identity, roomId, policy))
+
+		// both connections may have passed the verification at the same time,
+		// livekit has already replaced the old one, so the newer will be removed
+		if policy == DuplicateJoinRejectNew && !r.consumeIdentityReplace(roomId, identity) {
+			_, _ = r.RemoveParticipant(roomId, identity)
+			return
+		}
+	}
+
+	pp := r.rc.Pipeline()
+	pp.HSet(r.ctx, key, identity, sid)
+	pp.Expire(r.ctx, key, r.RoomKeyTTL(roomId))
+	_, _ = pp.Exec(r.ctx)
+}
+
+func (r *RoomService) untrackActiveIdentity(roomId, identity, sid string) {
+	key := activeIdentitiesKey + roomId
+	old, _ := r.rc.HGet(r.ctx, key, identity).Result()
+	if old == sid {
+		r.rc.HDel(r.ctx, key, identity)
+	}
+}
+
+func (r *RoomService) DeleteActiveIdentities(roomId string) (int64, error) {
+	return r.rc.Del(r.ctx, activeIdentitiesKey+roomId, identityClientsKey+roomId, identityReplacesKey+roomId, suffixedIdentitiesKey+roomId).Result()
+}
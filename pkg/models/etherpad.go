@@ -103,6 +103,7 @@ func (m *EtherpadModel) CreateSession(roomId string) (*plugnmeet.CreateEtherpadS
 	if err != nil {
 		log.Errorln(err)
 	}
+	m.rs.IncrRoomFeatureUsage(roomId, RoomFeatureSharedNotepad)
 
 	res.Status = true
 	res.Msg = "success"
@@ -164,8 +165,10 @@ func (m *EtherpadModel) CleanAfterRoomEnd(roomId, metadata string) error {
 		return nil
 	}
 
-	roomMeta := new(plugnmeet.RoomMetadata)
-	_ = json.Unmarshal([]byte(metadata), roomMeta)
+	roomMeta, err := ParseRoomMetadata(metadata)
+	if err != nil {
+		return err
+	}
 
 	if roomMeta.RoomFeatures.SharedNotePadFeatures == nil {
 		return nil
@@ -176,7 +179,7 @@ func (m *EtherpadModel) CleanAfterRoomEnd(roomId, metadata string) error {
 		return nil
 	}
 
-	err := m.CleanPad(roomId, np.NodeId, np.NotePadId)
+	err = m.CleanPad(roomId, np.NodeId, np.NotePadId)
 	return err
 }
 
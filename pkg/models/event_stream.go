@@ -0,0 +1,146 @@
+package models
+
+import (
+	"bufio"
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	eventStreamChannel        = "plug-n-meet-event-stream"
+	eventStreamHeartbeatAfter = 15 * time.Second
+)
+
+// StreamEvent is the normalized format of every event of the stream
+type StreamEvent struct {
+	Type    string      `json:"type"`
+	RoomId  string      `json:"room_id,omitempty"`
+	RoomSid string      `json:"room_sid,omitempty"`
+	Time    int64       `json:"time"`
+	Event   interface{} `json:"event,omitempty"`
+}
+
+type EventStreamReq struct {
+	// if empty then events of all the rooms will be streamed
+	RoomId string `json:"room_id"`
+	// ApiKey of the caller, only events of the rooms created by the key will be streamed
+	ApiKey string `json:"-"`
+}
+
+// streamEventMsg will be distributed to the streams of all servers
+type streamEventMsg struct {
+	ApiKey string       `json:"api_key"`
+	Event  *StreamEvent `json:"event"`
+}
+
+type eventStreamModel struct {
+	rc  *redis.Client
+	ctx context.Context
+}
+
+func NewEventStreamModel() *eventStreamModel {
+	return &eventStreamModel{
+		rc:  config.AppCnf.RDS,
+		ctx: context.Background(),
+	}
+}
+
+// PublishEvent will distribute the event to all the active streams of any server.
+// If nobody is listening then we won't do anything.
+func (m *eventStreamModel) PublishEvent(room *notifyRoom, roomSid string, msg interface{}) {
+	subs, err := m.rc.PubSubNumSub(m.ctx, eventStreamChannel).Result()
+	if err != nil || subs[eventStreamChannel] == 0 {
+		return
+	}
+
+	e := &streamEventMsg{
+		ApiKey: room.apiKey,
+		Event: &StreamEvent{
+			Type:    "event",
+			RoomId:  room.roomId,
+			RoomSid: roomSid,
+			Time:    time.Now().Unix(),
+			Event:   msg,
+		},
+	}
+
+	marshal, err := json.Marshal(e)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	m.rc.Publish(m.ctx, eventStreamChannel, marshal)
+}
+
+// StreamEvents will write events as NDJSON until the client disconnect.
+// A heartbeat line will be sent periodically to detect the disconnection.
+func (m *eventStreamModel) StreamEvents(r *EventStreamReq, w *bufio.Writer) {
+	pubsub := m.rc.Subscribe(m.ctx, eventStreamChannel)
+	defer pubsub.Close()
+
+	_, err := pubsub.Receive(m.ctx)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	heartbeat := time.NewTicker(eventStreamHeartbeatAfter)
+	defer heartbeat.Stop()
+
+	// first heartbeat to let client know that stream has started
+	if !m.writeLine(w, &StreamEvent{Type: "heartbeat", Time: time.Now().Unix()}) {
+		return
+	}
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-heartbeat.C:
+			if !m.writeLine(w, &StreamEvent{Type: "heartbeat", Time: time.Now().Unix()}) {
+				return
+			}
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			e := new(streamEventMsg)
+			err = json.Unmarshal([]byte(msg.Payload), e)
+			if err != nil || e.Event == nil {
+				log.Errorln(err)
+				continue
+			}
+			if e.ApiKey == "" {
+				// rooms without key belong to the primary key
+				e.ApiKey = config.AppCnf.Client.ApiKey
+			}
+			if r.ApiKey != "" && r.ApiKey != e.ApiKey {
+				continue
+			}
+			if r.RoomId != "" && r.RoomId != e.Event.RoomId {
+				continue
+			}
+			if !m.writeLine(w, e.Event) {
+				return
+			}
+		}
+	}
+}
+
+func (m *eventStreamModel) writeLine(w *bufio.Writer, e *StreamEvent) bool {
+	marshal, err := json.Marshal(e)
+	if err != nil {
+		log.Errorln(err)
+		return true
+	}
+
+	_, err = w.Write(append(marshal, '\n'))
+	if err != nil {
+		return false
+	}
+	// flush error means client has disconnected
+	return w.Flush() == nil
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"time"
+)
+
+const defaultGuestLinkExpiry = 24 * time.Hour
+
+type GuestJoinLinkOpts struct {
+	// ExpiresIn in seconds, default 24 hours
+	ExpiresIn   uint64                `json:"expires_in"`
+	Permissions *UserMediaPermissions `json:"-"`
+}
+
+// GenerateGuestJoinToken will generate token which can be used only once.
+// The nonce of the token will be stored in redis until expiry.
+func (a *authTokenModel) GenerateGuestJoinToken(g *plugnmeet.GenerateTokenReq, o *GuestJoinLinkOpts) (string, error) {
+	expiry := defaultGuestLinkExpiry
+	if o != nil && o.ExpiresIn > 0 {
+		expiry = time.Duration(o.ExpiresIn) * time.Second
+	}
+
+	var permissions *UserMediaPermissions
+	if o != nil {
+		permissions = o.Permissions
+	}
+	token, err := a.generateToken(g, expiry, permissions)
+	if err != nil {
+		return "", err
+	}
+
+	err = a.markTokenSingleUse(token, expiry)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
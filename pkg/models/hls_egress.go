@@ -0,0 +1,230 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+	"github.com/livekit/protocol/livekit"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+const (
+	HlsStatusActive = "active"
+	HlsStatusEnded  = "ended"
+	HlsStatusFailed = "failed"
+
+	// hlsEgressKey keeps the hls stream of the session
+	hlsEgressKey              = "pnm:hls_egress:"
+	hlsStartedEvent           = "hls_started"
+	hlsEndedEvent             = "hls_ended"
+	hlsPlaylistName           = "index.m3u8"
+	defaultHlsSegmentDuration = 6
+)
+
+type HlsStream struct {
+	EgressId string `json:"egress_id"`
+	RoomId   string `json:"room_id"`
+	RoomSid  string `json:"room_sid"`
+	// PlaylistUrl can be used by any HLS player, viewers won't need to join the room
+	PlaylistUrl string `json:"playlist_url"`
+	// Storage: local or s3
+	Storage   string `json:"storage"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	StartedAt int64  `json:"started_at"`
+	EndedAt   int64  `json:"ended_at,omitempty"`
+}
+
+type HlsReq struct {
+	RoomId string `json:"room_id" validate:"required,require-valid-Id"`
+}
+
+// HlsTaskReq is used by admin of the room
+type HlsTaskReq struct {
+	Task string `json:"task" validate:"required,oneof=start stop"`
+}
+
+// hlsNotifyEvent will add the stream with common notify event
+type hlsNotifyEvent struct {
+	*plugnmeet.CommonNotifyEvent
+	Hls *HlsStream `json:"hls"`
+}
+
+// StartHls will publish composite of the room as HLS stream using livekit egress.
+// Segments will be written to local recording path or uploaded to S3.
+func (rm *recordingModel) StartHls(roomId string) (*HlsStream, error) {
+	conf := rm.app.RecorderInfo.Hls
+	if !conf.Enabled {
+		return nil, errors.New("HLS streaming isn't enabled")
+	}
+	room, _ := NewRoomModel().GetRoomInfo(roomId, "", 1)
+	if room.Id == 0 {
+		return nil, errors.New("notifications.room-not-active")
+	}
+	if s, err := rm.loadHlsStream(room.Sid); err == nil && s.Status == HlsStatusActive {
+		return nil, errors.New("HLS streaming already running")
+	}
+
+	segmentDuration := conf.SegmentDuration
+	if segmentDuration == 0 {
+		segmentDuration = defaultHlsSegmentDuration
+	}
+	layout := rm.egressLayout(room.RoomId, conf.Layout)
+
+	// e.g. hls/RM_xxx/1668000000/index.m3u8
+	dir := fmt.Sprintf("hls/%s/%d", room.Sid, time.Now().Unix())
+	output := &livekit.SegmentedFileOutput{
+		Protocol:        livekit.SegmentedFileProtocol_HLS_PROTOCOL,
+		PlaylistName:    hlsPlaylistName,
+		SegmentDuration: segmentDuration,
+	}
+	stream := &HlsStream{
+		RoomId:  room.RoomId,
+		RoomSid: room.Sid,
+		Storage: RecordingStorageLocal,
+		Status:  HlsStatusActive,
+	}
+
+	if conf.UseS3 {
+		s3 := rm.app.RecorderInfo.Storage.S3
+		key := strings.TrimPrefix(s3.Prefix+"/"+dir, "/")
+		output.FilenamePrefix = key + "/segment"
+		output.Output = &livekit.SegmentedFileOutput_S3{
+			S3: &livekit.S3Upload{
+				AccessKey:      s3.AccessKey,
+				Secret:         s3.SecretKey,
+				Region:         s3.Region,
+				Endpoint:       s3.Endpoint,
+				Bucket:         s3.Bucket,
+				ForcePathStyle: s3.UsePathStyle,
+			},
+		}
+		stream.Storage = RecordingStorageS3
+		stream.PlaylistUrl = strings.TrimSuffix(conf.PlaybackBaseUrl, "/") + "/" + key + "/" + hlsPlaylistName
+	} else {
+		egressFilesPath := conf.EgressFilesPath
+		if egressFilesPath == "" {
+			egressFilesPath = rm.app.RecorderInfo.TrackRecording.EgressFilesPath
+		}
+		output.FilenamePrefix = strings.TrimSuffix(egressFilesPath, "/") + "/" + dir + "/segment"
+		// will be served by /hls route
+		stream.PlaylistUrl = strings.TrimSuffix(conf.PlaybackBaseUrl, "/") + "/" + dir + "/" + hlsPlaylistName
+	}
+
+	ctx, cancel := context.WithTimeout(rm.ctx, 10*time.Second)
+	defer cancel()
+	info, err := newEgressClient().StartRoomCompositeEgress(ctx, &livekit.RoomCompositeEgressRequest{
+		RoomName: room.RoomId,
+		Layout:   layout,
+		Output: &livekit.RoomCompositeEgressRequest_Segments{
+			Segments: output,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	stream.EgressId = info.EgressId
+	stream.StartedAt = time.Now().Unix()
+
+	rm.saveHlsStream(stream)
+	go rm.sendHlsWebhook(hlsStartedEvent, stream)
+
+	return stream, nil
+}
+
+// StopHls will stop the egress, stream will be updated when livekit informs us
+func (rm *recordingModel) StopHls(roomId string) error {
+	room, _ := NewRoomModel().GetRoomInfo(roomId, "", 1)
+	if room.Id == 0 {
+		return errors.New("notifications.room-not-active")
+	}
+	s, err := rm.loadHlsStream(room.Sid)
+	if err != nil || s.Status != HlsStatusActive {
+		return errors.New("HLS streaming not running")
+	}
+
+	ctx, cancel := context.WithTimeout(rm.ctx, 10*time.Second)
+	defer cancel()
+	_, err = newEgressClient().StopEgress(ctx, &livekit.StopEgressRequest{
+		EgressId: s.EgressId,
+	})
+	return err
+}
+
+// GetHlsStream will return the last hls stream of the current session
+func (rm *recordingModel) GetHlsStream(roomId string) (*HlsStream, error) {
+	room, _ := NewRoomModel().GetRoomInfo(roomId, "", 1)
+	if room.Id == 0 {
+		return nil, errors.New("notifications.room-not-active")
+	}
+	return rm.loadHlsStream(room.Sid)
+}
+
+func (rm *recordingModel) loadHlsStream(roomSid string) (*HlsStream, error) {
+	result, err := rm.rds.Get(rm.ctx, hlsEgressKey+roomSid).Result()
+	if err == redis.Nil {
+		return nil, errors.New("no HLS stream found")
+	} else if err != nil {
+		return nil, err
+	}
+
+	s := new(HlsStream)
+	if err = json.Unmarshal([]byte(result), s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (rm *recordingModel) saveHlsStream(s *HlsStream) {
+	marshal, err := json.Marshal(s)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	if err = rm.rds.Set(rm.ctx, hlsEgressKey+s.RoomSid, marshal, trackRecordingTTL).Err(); err != nil {
+		log.Errorln(err)
+	}
+}
+
+// HlsEgressEnded will update the stream when the egress has ended
+func (rm *recordingModel) HlsEgressEnded(e *livekit.EgressInfo) {
+	if e == nil {
+		return
+	}
+	s, err := rm.loadHlsStream(e.RoomId)
+	if err != nil || s.EgressId != e.EgressId || s.Status != HlsStatusActive {
+		// not started by us
+		return
+	}
+
+	s.Status = HlsStatusEnded
+	if e.Status != livekit.EgressStatus_EGRESS_COMPLETE {
+		s.Status = HlsStatusFailed
+		s.Error = e.Error
+	}
+	s.EndedAt = time.Now().Unix()
+	rm.saveHlsStream(s)
+	rm.clearBroadcastSlate(s.RoomId, s.RoomSid)
+	rm.sendHlsWebhook(hlsEndedEvent, s)
+}
+
+func (rm *recordingModel) sendHlsWebhook(event string, s *HlsStream) {
+	msg := &hlsNotifyEvent{
+		CommonNotifyEvent: &plugnmeet.CommonNotifyEvent{
+			Event: &event,
+			Room: &plugnmeet.NotifyEventRoom{
+				Sid:    &s.RoomSid,
+				RoomId: &s.RoomId,
+			},
+		},
+		Hls: s,
+	}
+	if err := NewWebhookNotifier().Notify(s.RoomSid, msg); err != nil {
+		log.Errorln(err)
+	}
+}
@@ -0,0 +1,250 @@
+package models
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/livekit/protocol/auth"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+	"os"
+	"sync"
+	"time"
+)
+
+type joinTokenKey struct {
+	kid        string
+	alg        jose.SignatureAlgorithm
+	privateKey crypto.Signer
+	publicKey  crypto.PublicKey
+}
+
+// joinTokenKeys will be loaded once from config
+var joinTokenKeys = struct {
+	sync.Once
+	keys   map[string]*joinTokenKey
+	active *joinTokenKey
+}{}
+
+func loadJoinTokenKeys() {
+	joinTokenKeys.Do(func() {
+		joinTokenKeys.keys = make(map[string]*joinTokenKey)
+		conf := config.AppCnf.Client.JoinTokenSigning
+
+		for _, k := range conf.Keys {
+			key, err := readJoinTokenKey(k)
+			if err != nil {
+				log.Errorln(fmt.Sprintf("can't load join token key %s: %s", k.Kid, err.Error()))
+				continue
+			}
+			joinTokenKeys.keys[k.Kid] = key
+		}
+
+		if conf.ActiveKid != "" {
+			key, ok := joinTokenKeys.keys[conf.ActiveKid]
+			if !ok || key.privateKey == nil {
+				log.Errorln("private key of active_kid wasn't found, HS256 will be used")
+				return
+			}
+			joinTokenKeys.active = key
+		}
+	})
+}
+
+// readJoinTokenKey will read private key if available, otherwise public key.
+// Retired keys can have public key only, so that already issued tokens can be verified.
+func readJoinTokenKey(k config.JoinTokenSigningKey) (*joinTokenKey, error) {
+	key := &joinTokenKey{
+		kid: k.Kid,
+	}
+
+	if k.PrivateKeyFile != "" {
+		block, err := readPemFile(k.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		var priv interface{}
+		priv, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			priv, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+		}
+		signer, ok := priv.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key")
+		}
+		key.privateKey = signer
+		key.publicKey = signer.Public()
+	} else {
+		block, err := readPemFile(k.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key.publicKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch key.publicKey.(type) {
+	case *rsa.PublicKey:
+		key.alg = jose.RS256
+	case ed25519.PublicKey:
+		key.alg = jose.EdDSA
+	default:
+		return nil, errors.New("only RSA & Ed25519 keys are supported")
+	}
+
+	return key, nil
+}
+
+func readPemFile(file string) (*pem.Block, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid pem file: " + file)
+	}
+	return block, nil
+}
+
+func (a *authTokenModel) joinTokenIssuer() string {
+	if a.app.Client.JoinTokenClaims.Issuer != "" {
+		return a.app.Client.JoinTokenClaims.Issuer
+	}
+	return a.app.Client.ApiKey
+}
+
+func (a *authTokenModel) joinTokenValidity() time.Duration {
+	if a.app.Client.JoinTokenClaims.Ttl > 0 {
+		return a.app.Client.JoinTokenClaims.Ttl
+	}
+	return a.app.LivekitInfo.TokenValidity
+}
+
+func (a *authTokenModel) joinTokenClockSkew() time.Duration {
+	if a.app.Client.JoinTokenClaims.ClockSkew > 0 {
+		return a.app.Client.JoinTokenClaims.ClockSkew
+	}
+	return jwt.DefaultLeeway
+}
+
+// signJoinToken will sign using active asymmetric key if configured,
+// otherwise HS256 with api secret as before.
+// Every token will have an unique jti, so that it can be revoked.
+func (a *authTokenModel) signJoinToken(grants *auth.ClaimGrants, validity time.Duration) (string, error) {
+	loadJoinTokenKeys()
+	signingKey := jose.SigningKey{Algorithm: jose.HS256, Key: []byte(a.app.Client.Secret)}
+	opts := (&jose.SignerOptions{}).WithType("JWT")
+	if key := joinTokenKeys.active; key != nil {
+		signingKey = jose.SigningKey{Algorithm: key.alg, Key: key.privateKey}
+		opts = opts.WithHeader("kid", key.kid)
+	}
+
+	sig, err := jose.NewSigner(signingKey, opts)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	cl := jwt.Claims{
+		ID:        uuid.NewString(),
+		Issuer:    a.joinTokenIssuer(),
+		Audience:  a.app.Client.JoinTokenClaims.Audience,
+		Subject:   grants.Identity,
+		NotBefore: jwt.NewNumericDate(now),
+		Expiry:    jwt.NewNumericDate(now.Add(validity)),
+	}
+
+	token, err := jwt.Signed(sig).Claims(cl).Claims(grants).CompactSerialize()
+	if err != nil {
+		return "", err
+	}
+	if grants.Video != nil {
+		NewTokenRevocationModel().trackIssuedToken(grants.Video.Room, grants.Identity, cl.ID, now.Add(validity))
+	}
+
+	return token, nil
+}
+
+// parseJoinToken will verify the token & make sure it wasn't revoked
+func (a *authTokenModel) parseJoinToken(token string, leeway time.Duration) (*auth.ClaimGrants, error) {
+	claims, out, err := a.parseJoinTokenClaims(token, leeway)
+	if err != nil {
+		return nil, err
+	}
+	if out.ID != "" && NewTokenRevocationModel().IsTokenRevoked(out.ID) {
+		return nil, errors.New("token has been revoked")
+	}
+
+	return claims, nil
+}
+
+// parseJoinTokenClaims will verify the token based on the algorithm of the header
+func (a *authTokenModel) parseJoinTokenClaims(token string, leeway time.Duration) (*auth.ClaimGrants, *jwt.Claims, error) {
+	tok, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tok.Headers) == 0 {
+		return nil, nil, errors.New("invalid token")
+	}
+
+	var key interface{}
+	h := tok.Headers[0]
+	if h.Algorithm == string(jose.HS256) {
+		key = []byte(a.app.Client.Secret)
+	} else {
+		loadJoinTokenKeys()
+		k, ok := joinTokenKeys.keys[h.KeyID]
+		if !ok || string(k.alg) != h.Algorithm {
+			return nil, nil, errors.New("unknown signing key")
+		}
+		key = k.publicKey
+	}
+
+	out := new(jwt.Claims)
+	claims := new(auth.ClaimGrants)
+	if err = tok.Claims(key, out, claims); err != nil {
+		return nil, nil, err
+	}
+	expected := jwt.Expected{
+		Issuer:   a.joinTokenIssuer(),
+		Audience: a.app.Client.JoinTokenClaims.Audience,
+		Time:     time.Now(),
+	}
+	if err = out.ValidateWithLeeway(expected, leeway); err != nil {
+		return nil, nil, err
+	}
+	claims.Identity = out.Subject
+
+	return claims, out, nil
+}
+
+// GetJoinTokenJWKS will return public keys to verify join tokens
+func GetJoinTokenJWKS() *jose.JSONWebKeySet {
+	loadJoinTokenKeys()
+	set := &jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{},
+	}
+	for _, k := range joinTokenKeys.keys {
+		set.Keys = append(set.Keys, jose.JSONWebKey{
+			Key:       k.publicKey,
+			KeyID:     k.kid,
+			Algorithm: string(k.alg),
+			Use:       "sig",
+		})
+	}
+	return set
+}
@@ -0,0 +1,172 @@
+package models
+
+import (
+	"crypto/tls"
+	"errors"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"strings"
+	"time"
+)
+
+const defaultLdapTimeout = 10 * time.Second
+
+type LdapLoginReq struct {
+	RoomId   string `json:"room_id" validate:"required,require-valid-Id"`
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type LdapLoginRes struct {
+	Token   string `json:"token"`
+	IsAdmin bool   `json:"is_admin"`
+}
+
+// RoomLdapGroups can be set during room create to override
+// moderator_groups & attendee_groups of ldap config
+type RoomLdapGroups struct {
+	ModeratorGroups []string `json:"moderator_groups,omitempty"`
+	AttendeeGroups  []string `json:"attendee_groups,omitempty"`
+}
+
+type ldapAuthModel struct {
+	conf config.LdapConf
+	rs   *RoomService
+}
+
+func NewLdapAuthModel() *ldapAuthModel {
+	return &ldapAuthModel{
+		conf: config.AppCnf.Client.Ldap,
+		rs:   NewRoomService(),
+	}
+}
+
+// Login will verify credentials with LDAP server & generate join token.
+// Members of moderator groups will join as admin.
+func (m *ldapAuthModel) Login(r *LdapLoginReq) (*LdapLoginRes, error) {
+	if !m.conf.Enable {
+		return nil, errors.New("ldap login isn't enabled")
+	}
+
+	groups := &RoomLdapGroups{
+		ModeratorGroups: m.conf.ModeratorGroups,
+		AttendeeGroups:  m.conf.AttendeeGroups,
+	}
+	if opts := m.rs.LoadRoomOptions(r.RoomId); opts.LdapGroups != nil {
+		groups = opts.LdapGroups
+	}
+
+	entry, err := m.authenticate(r.Username, r.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	memberOf := entry.GetAttributeValues(m.groupAttribute())
+	isAdmin := ldapInAnyGroup(memberOf, groups.ModeratorGroups)
+	if !isAdmin && len(groups.AttendeeGroups) > 0 && !ldapInAnyGroup(memberOf, groups.AttendeeGroups) {
+		return nil, errors.New("you aren't allowed to join this room")
+	}
+
+	name := r.Username
+	if n := entry.GetAttributeValue(m.nameAttribute()); n != "" {
+		name = n
+	}
+
+	token, err := NewAuthTokenModel().DoGenerateToken(&plugnmeet.GenerateTokenReq{
+		RoomId: r.RoomId,
+		UserInfo: &plugnmeet.UserInfo{
+			UserId:  r.Username,
+			Name:    name,
+			IsAdmin: isAdmin,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &LdapLoginRes{
+		Token:   token,
+		IsAdmin: isAdmin,
+	}, nil
+}
+
+// authenticate will search the user using service account
+// then bind as the user to verify the password
+func (m *ldapAuthModel) authenticate(username, password string) (*ldap.Entry, error) {
+	timeout := m.conf.Timeout
+	if timeout == 0 {
+		timeout = defaultLdapTimeout
+	}
+
+	l, err := ldap.DialURL(m.conf.Url,
+		ldap.DialWithDialer(&net.Dialer{Timeout: timeout}),
+		ldap.DialWithTLSConfig(&tls.Config{InsecureSkipVerify: m.conf.InsecureSkipVerify}),
+	)
+	if err != nil {
+		log.Errorln(err)
+		return nil, errors.New("can't connect to ldap server")
+	}
+	defer l.Close()
+	l.SetTimeout(timeout)
+
+	if m.conf.BindDn != "" {
+		if err = l.Bind(m.conf.BindDn, m.conf.BindPassword); err != nil {
+			log.Errorln("ldap service account bind failed: " + err.Error())
+			return nil, errors.New("can't connect to ldap server")
+		}
+	}
+
+	userAttribute := m.conf.UserAttribute
+	if userAttribute == "" {
+		userAttribute = "uid"
+	}
+	res, err := l.Search(ldap.NewSearchRequest(
+		m.conf.BaseDn, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(timeout.Seconds()), false,
+		"("+userAttribute+"="+ldap.EscapeFilter(username)+")",
+		[]string{m.nameAttribute(), m.groupAttribute()},
+		nil,
+	))
+	if err != nil || len(res.Entries) != 1 {
+		// don't reveal if the user exists
+		return nil, errors.New("invalid credentials")
+	}
+
+	// go-ldap rejects empty password, so it can't be used as unauthenticated bind
+	if err = l.Bind(res.Entries[0].DN, password); err != nil {
+		if !ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			log.Errorln("ldap user bind failed: " + err.Error())
+		}
+		return nil, errors.New("invalid credentials")
+	}
+
+	return res.Entries[0], nil
+}
+
+func (m *ldapAuthModel) nameAttribute() string {
+	if m.conf.NameAttribute != "" {
+		return m.conf.NameAttribute
+	}
+	return "displayName"
+}
+
+func (m *ldapAuthModel) groupAttribute() string {
+	if m.conf.GroupAttribute != "" {
+		return m.conf.GroupAttribute
+	}
+	return "memberOf"
+}
+
+// ldapInAnyGroup compares DNs case-insensitively
+func ldapInAnyGroup(memberOf, groups []string) bool {
+	for _, g := range groups {
+		for _, mg := range memberOf {
+			if strings.EqualFold(strings.TrimSpace(g), strings.TrimSpace(mg)) {
+				return true
+			}
+		}
+	}
+	return false
+}
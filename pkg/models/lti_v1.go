@@ -223,7 +223,7 @@ func (m *LTIV1) LTIV1JoinRoom(c *plugnmeet.LtiClaims) (string, error) {
 
 func (m *LTIV1) createRoomSession(c *plugnmeet.LtiClaims) (bool, string, *livekit.Room) {
 	req := utils.PrepareLTIV1RoomCreateReq(c)
-	return m.authModel.CreateRoom(req)
+	return m.authModel.CreateRoom(req, nil)
 }
 
 func (m *LTIV1) joinRoom(c *plugnmeet.LtiClaims) (string, error) {
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/gabriel-vasile/mimetype"
@@ -19,11 +20,15 @@ import (
 )
 
 type ManageFile struct {
-	Sid                string `json:"sid" validate:"required"`
-	RoomId             string `json:"roomId" validate:"required"`
-	UserId             string `json:"userId" validate:"required"`
-	FilePath           string `json:"file_path"`
-	Resumable          bool   `json:"resumable"`
+	Sid       string `json:"sid" validate:"required"`
+	RoomId    string `json:"roomId" validate:"required"`
+	UserId    string `json:"userId" validate:"required"`
+	FilePath  string `json:"file_path"`
+	Resumable bool   `json:"resumable"`
+	// Async will convert whiteboard file in background
+	Async bool `json:"async"`
+	// Purpose chat will store the file as chat attachment
+	Purpose            string `json:"purpose" query:"purpose" validate:"omitempty,oneof=chat"`
 	uploadFileSettings *config.UploadFileSettings
 	fileExtension      string
 	fileMimeType       string
@@ -104,6 +109,7 @@ func (m *ManageFile) ResumableFileUpload(c *fiber.Ctx) (*UploadedFileResponse, e
 		}
 
 	default:
+		var limits *WhiteboardUploadLimits
 		if req.ResumableChunkNumber == 1 {
 			// we'll check if meeting is already running or not
 			rm := NewRoomModel()
@@ -114,10 +120,10 @@ func (m *ManageFile) ResumableFileUpload(c *fiber.Ctx) (*UploadedFileResponse, e
 			}
 
 			// check if file size is OK
-			if req.ResumableTotalSize > int64(m.uploadFileSettings.MaxSize*1024*1024) {
+			limits = m.whiteboardUploadLimits()
+			if err = limits.checkSize(req.ResumableTotalSize); err != nil {
 				_ = c.SendStatus(fiber.StatusBadRequest)
-				msg := fmt.Sprintf("file is too big. Max allow %dMB", m.uploadFileSettings.MaxSize)
-				return nil, errors.New(msg)
+				return nil, err
 			}
 		}
 
@@ -138,7 +144,7 @@ func (m *ManageFile) ResumableFileUpload(c *fiber.Ctx) (*UploadedFileResponse, e
 		// we'll check the first one only.
 		if req.ResumableChunkNumber == 1 {
 			fo, _ := reqf.Open()
-			err = m.validateMimeType(fo)
+			err = m.validateMimeType(fo, limits.AllowedTypes)
 			if err != nil {
 				_ = c.SendStatus(fiber.StatusUnsupportedMediaType)
 				return nil, err
@@ -192,14 +198,13 @@ func (m *ManageFile) ResumableFileUpload(c *fiber.Ctx) (*UploadedFileResponse, e
 	return res, nil
 }
 
-func (m *ManageFile) validateMimeType(file multipart.File) error {
+func (m *ManageFile) validateMimeType(file multipart.File, allowedTypes []string) error {
 	defer file.Close()
 	mtype, err := mimetype.DetectReader(file)
 	if err != nil {
 		return err
 	}
 
-	allowedTypes := m.uploadFileSettings.AllowedTypes
 	sort.Strings(allowedTypes)
 
 	m.fileMimeType = mtype.String()
@@ -283,6 +288,47 @@ func (m *ManageFile) DeleteRoomUploadedDir() error {
 	return err
 }
 
+// CopyRoomUploadedDir will copy all the uploaded files of the room to other room
+func (m *ManageFile) CopyRoomUploadedDir(targetSid string) error {
+	src := fmt.Sprintf("%s/%s", m.uploadFileSettings.Path, m.Sid)
+	dst := fmt.Sprintf("%s/%s", m.uploadFileSettings.Path, targetSid)
+
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		// nothing was uploaded
+		return nil
+	}
+
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, os.ModePerm)
+		}
+
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		out, err := os.Create(target)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		_, err = io.Copy(out, in)
+		return err
+	})
+}
+
 type convertStatus struct {
 	status bool
 	err    error
@@ -294,6 +340,23 @@ type ConvertWhiteboardFileRes struct {
 	TotalPages int    `json:"total_pages"`
 }
 
+// officePdfExportVariant will return the filter of soffice to convert the file to pdf
+func officePdfExportVariant(ext string) (string, bool) {
+	switch ext {
+	case ".docx", ".doc", ".odt", ".txt", ".rtf", ".xml":
+		return "pdf:writer_pdf_Export", true
+	case ".xlsx", ".xls", ".ods", ".csv":
+		return "pdf:calc_pdf_Export", true
+	case ".pptx", ".ppt", ".odp":
+		return "pdf:impress_pdf_Export", true
+	case ".vsd", ".odg":
+		return "pdf:draw_pdf_Export", true
+	case ".html":
+		return "pdf:writer_web_pdf_Export", true
+	}
+	return "", false
+}
+
 func (m *ManageFile) ConvertWhiteboardFile() (*ConvertWhiteboardFileRes, error) {
 	// check if mutool installed in correct path
 	if _, err := os.Stat("/usr/bin/mutool"); err != nil {
@@ -314,6 +377,11 @@ func (m *ManageFile) ConvertWhiteboardFile() (*ConvertWhiteboardFileRes, error)
 		return nil, err
 	}
 
+	limits := m.whiteboardUploadLimits()
+	if err = limits.checkFile(info, mtype); err != nil {
+		return nil, err
+	}
+
 	fileId := uuid.NewString()
 	outputDir := fmt.Sprintf("%s/%s/%s", m.uploadFileSettings.Path, m.Sid, fileId)
 	err = os.MkdirAll(outputDir, os.ModePerm)
@@ -322,25 +390,7 @@ func (m *ManageFile) ConvertWhiteboardFile() (*ConvertWhiteboardFileRes, error)
 		return nil, err
 	}
 
-	needConvertToPdf := false
-	variant := "pdf:writer_pdf_Export"
-	switch mtype.Extension() {
-	case ".docx", ".doc", ".odt", ".txt", ".rtf", ".xml":
-		needConvertToPdf = true
-	case ".xlsx", ".xls", ".ods", ".csv":
-		needConvertToPdf = true
-		variant = "pdf:calc_pdf_Export"
-	case ".pptx", ".ppt", ".odp":
-		needConvertToPdf = true
-		variant = "pdf:impress_pdf_Export"
-	case ".vsd", ".odg":
-		needConvertToPdf = true
-		variant = "pdf:draw_pdf_Export"
-	case ".html":
-		needConvertToPdf = true
-		variant = "pdf:writer_web_pdf_Export"
-	}
-
+	variant, needConvertToPdf := officePdfExportVariant(mtype.Extension())
 	if needConvertToPdf {
 		// check if soffice installed in correct path
 		if _, err = os.Stat("/usr/bin/soffice"); err != nil {
@@ -370,6 +420,11 @@ func (m *ManageFile) ConvertWhiteboardFile() (*ConvertWhiteboardFileRes, error)
 		file = fmt.Sprintf("%s/%s", outputDir, newFile)
 	}
 
+	if _, err = limits.checkPdfPages(context.Background(), file); err != nil {
+		_ = os.RemoveAll(outputDir)
+		return nil, err
+	}
+
 	status := make(chan convertStatus)
 	go func(file, outputDir string) {
 		cmd := exec.Command("/usr/bin/mutool", "convert", "-o", outputDir+"/page_%d.png", file)
@@ -390,6 +445,11 @@ func (m *ManageFile) ConvertWhiteboardFile() (*ConvertWhiteboardFileRes, error)
 
 	pattern := filepath.Join(outputDir, "*.png")
 	totalPages, _ := filepath.Glob(pattern)
+	// page count wasn't available before rendering
+	if err = limits.checkPages(len(totalPages)); err != nil {
+		_ = os.RemoveAll(outputDir)
+		return nil, err
+	}
 
 	res := &ConvertWhiteboardFileRes{
 		FileName:   info.Name(),
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"github.com/livekit/protocol/auth"
+	"github.com/livekit/protocol/livekit"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+)
+
+// UserMediaPermissions aren't part of plugnmeet.UserInfo,
+// it will be sent inside user_info during token generation.
+// nil value means allowed.
+type UserMediaPermissions struct {
+	CanPublishAudio *bool `json:"can_publish_audio"`
+	CanPublishVideo *bool `json:"can_publish_video"`
+	CanShareScreen  *bool `json:"can_share_screen"`
+	CanSubscribe    *bool `json:"can_subscribe"`
+}
+
+func isDenied(v *bool) bool {
+	return v != nil && !*v
+}
+
+// applyToMetadata will lock the denied sources. Livekit grant can't restrict a single source,
+// so clients will hide the buttons & server will mute the track if it was published anyway.
+func (p *UserMediaPermissions) applyToMetadata(meta *plugnmeet.UserMetadata) {
+	if p == nil {
+		return
+	}
+	if meta.LockSettings == nil {
+		meta.LockSettings = new(plugnmeet.LockSettings)
+	}
+	p.applyToLockSettings(meta.LockSettings)
+}
+
+// applyToLockSettings will keep the denied sources locked,
+// so that moderator's lock settings changes can't unlock those
+func (p *UserMediaPermissions) applyToLockSettings(l *plugnmeet.LockSettings) {
+	if p == nil || l == nil {
+		return
+	}
+
+	lock := true
+	if isDenied(p.CanPublishAudio) {
+		l.LockMicrophone = &lock
+	}
+	if isDenied(p.CanPublishVideo) {
+		l.LockWebcam = &lock
+	}
+	if isDenied(p.CanShareScreen) {
+		l.LockScreenSharing = &lock
+	}
+}
+
+// canPublish will be false if all the sources were denied
+func (p *UserMediaPermissions) canPublish() bool {
+	return p == nil || !(isDenied(p.CanPublishAudio) && isDenied(p.CanPublishVideo) && isDenied(p.CanShareScreen))
+}
+
+// isSourceDenied will be used to enforce the permissions when the track was published
+func (p *UserMediaPermissions) isSourceDenied(source livekit.TrackSource) bool {
+	if p == nil {
+		return false
+	}
+	switch source {
+	case livekit.TrackSource_MICROPHONE:
+		return isDenied(p.CanPublishAudio)
+	case livekit.TrackSource_CAMERA:
+		return isDenied(p.CanPublishVideo)
+	case livekit.TrackSource_SCREEN_SHARE, livekit.TrackSource_SCREEN_SHARE_AUDIO:
+		return isDenied(p.CanShareScreen)
+	}
+	return false
+}
+
+// applyToGrant will map the permissions with livekit grant,
+// user will be view only if all the sources were denied
+func (p *UserMediaPermissions) applyToGrant(grant *auth.VideoGrant) {
+	if p == nil {
+		return
+	}
+	if isDenied(p.CanSubscribe) {
+		canSubscribe := false
+		grant.CanSubscribe = &canSubscribe
+	}
+	if !p.canPublish() {
+		canPublish := false
+		grant.CanPublish = &canPublish
+	}
+}
+
+// saveUserMediaPermissions will store the permissions in room options,
+// so that those can be enforced during the session. nil will remove the old one.
+func (r *RoomService) saveUserMediaPermissions(roomId, userId string, p *UserMediaPermissions) error {
+	if p == nil && r.LoadRoomOptions(roomId).MediaPermissions[userId] == nil {
+		return nil
+	}
+
+	_, err := r.UpdateRoomOptions(roomId, func(o *RoomOptions) error {
+		if p == nil {
+			delete(o.MediaPermissions, userId)
+			return nil
+		}
+		if o.MediaPermissions == nil {
+			o.MediaPermissions = make(map[string]*UserMediaPermissions)
+		}
+		o.MediaPermissions[userId] = p
+		return nil
+	})
+	return err
+}
+
+// loadUserMediaPermissions will return nil if nothing was restricted for the user
+func (r *RoomService) loadUserMediaPermissions(roomId, identity string) *UserMediaPermissions {
+	return r.LoadRoomOptions(roomId).MediaPermissions[r.GetUserIdByIdentity(roomId, identity)]
+}
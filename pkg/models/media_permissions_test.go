@@ -0,0 +1,60 @@
+package models
+
+import (
+	"github.com/livekit/protocol/livekit"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"testing"
+)
+
+func TestUserMediaPermissionsApplyToLockSettings(t *testing.T) {
+	allow, deny := true, false
+	unlock := false
+	p := &UserMediaPermissions{
+		CanPublishAudio: &deny,
+		CanPublishVideo: &allow,
+	}
+
+	// moderator has unlocked everything
+	l := &plugnmeet.LockSettings{
+		LockMicrophone:    &unlock,
+		LockWebcam:        &unlock,
+		LockScreenSharing: &unlock,
+	}
+	p.applyToLockSettings(l)
+
+	if !*l.LockMicrophone {
+		t.Error("denied microphone should be kept locked")
+	}
+	if *l.LockWebcam || *l.LockScreenSharing {
+		t.Error("allowed sources shouldn't be locked")
+	}
+
+	var nilPermissions *UserMediaPermissions
+	nilPermissions.applyToLockSettings(l)
+	if !nilPermissions.canPublish() || nilPermissions.isSourceDenied(livekit.TrackSource_MICROPHONE) {
+		t.Error("nil permissions should allow everything")
+	}
+}
+
+func TestUserMediaPermissionsSources(t *testing.T) {
+	deny := false
+	p := &UserMediaPermissions{
+		CanPublishAudio: &deny,
+		CanShareScreen:  &deny,
+	}
+
+	if !p.isSourceDenied(livekit.TrackSource_MICROPHONE) || !p.isSourceDenied(livekit.TrackSource_SCREEN_SHARE_AUDIO) {
+		t.Error("denied sources should be reported")
+	}
+	if p.isSourceDenied(livekit.TrackSource_CAMERA) {
+		t.Error("camera wasn't denied")
+	}
+	if !p.canPublish() {
+		t.Error("user can still publish camera")
+	}
+
+	p.CanPublishVideo = &deny
+	if p.canPublish() {
+		t.Error("user can't publish anything")
+	}
+}
@@ -0,0 +1,205 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// oidcKeysRefreshInterval is the minimum interval to re-fetch keys
+// if the token was signed by an unknown key
+const oidcKeysRefreshInterval = time.Minute
+
+var oidcHttpClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
+
+// oidcProvider will cache discovery document & keys of the issuer
+var oidcProvider = struct {
+	sync.Mutex
+	jwksUri   string
+	keys      *jose.JSONWebKeySet
+	fetchedAt time.Time
+}{}
+
+type OidcIdentity struct {
+	Subject string
+	Email   string
+	Roles   []string
+	Scopes  []string
+}
+
+func (i *OidcIdentity) HasScope(scope string) bool {
+	return HasScope(i.Scopes, scope)
+}
+
+type oidcModel struct {
+	conf config.OidcConf
+}
+
+func NewOidcModel() *oidcModel {
+	return &oidcModel{
+		conf: config.AppCnf.Client.Oidc,
+	}
+}
+
+// VerifyToken will verify the bearer token with issuer keys
+// and map roles of the token to scopes
+func (m *oidcModel) VerifyToken(token string) (*OidcIdentity, error) {
+	if !m.conf.Enable {
+		return nil, errors.New("OIDC isn't enabled")
+	}
+
+	tk, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, err
+	}
+	if len(tk.Headers) == 0 {
+		return nil, errors.New("invalid token header")
+	}
+
+	key, err := m.getKey(tk.Headers[0].KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := jwt.Claims{}
+	extra := make(map[string]interface{})
+	if err = tk.Claims(key, &out, &extra); err != nil {
+		return nil, err
+	}
+
+	expected := jwt.Expected{
+		Issuer: m.conf.Issuer,
+		Time:   time.Now(),
+	}
+	if m.conf.Audience != "" {
+		expected.Audience = jwt.Audience{m.conf.Audience}
+	}
+	if err = out.Validate(expected); err != nil {
+		return nil, err
+	}
+
+	identity := &OidcIdentity{
+		Subject: out.Subject,
+		Roles:   m.getRoles(extra),
+	}
+	if email, ok := extra["email"].(string); ok {
+		identity.Email = email
+	}
+	for _, r := range identity.Roles {
+		identity.Scopes = append(identity.Scopes, m.conf.RoleScopes[r]...)
+	}
+
+	return identity, nil
+}
+
+// getRoles will read roles from the configured claim,
+// value can be string or array of strings
+func (m *oidcModel) getRoles(claims map[string]interface{}) []string {
+	claim := m.conf.RolesClaim
+	if claim == "" {
+		claim = "roles"
+	}
+
+	var v interface{} = claims
+	for _, p := range strings.Split(claim, ".") {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = obj[p]
+	}
+
+	var roles []string
+	switch r := v.(type) {
+	case string:
+		roles = strings.Fields(r)
+	case []interface{}:
+		for _, i := range r {
+			if s, ok := i.(string); ok {
+				roles = append(roles, s)
+			}
+		}
+	}
+
+	return roles
+}
+
+// getKey will return key from cache, if not found then keys will be re-fetched
+// so that key rotation of the issuer will be picked up
+func (m *oidcModel) getKey(kid string) (*jose.JSONWebKey, error) {
+	oidcProvider.Lock()
+	defer oidcProvider.Unlock()
+
+	if oidcProvider.keys != nil {
+		if keys := oidcProvider.keys.Key(kid); len(keys) > 0 {
+			return &keys[0], nil
+		}
+		if time.Since(oidcProvider.fetchedAt) < oidcKeysRefreshInterval {
+			return nil, errors.New("no key found for kid: " + kid)
+		}
+	}
+
+	err := m.fetchKeys()
+	if err != nil {
+		log.Errorln(err)
+		return nil, err
+	}
+
+	if keys := oidcProvider.keys.Key(kid); len(keys) > 0 {
+		return &keys[0], nil
+	}
+	return nil, errors.New("no key found for kid: " + kid)
+}
+
+// fetchKeys must be called with lock
+func (m *oidcModel) fetchKeys() error {
+	if oidcProvider.jwksUri == "" {
+		discovery := new(struct {
+			Issuer  string `json:"issuer"`
+			JwksUri string `json:"jwks_uri"`
+		})
+		url := strings.TrimSuffix(m.conf.Issuer, "/") + "/.well-known/openid-configuration"
+		if err := oidcGetJson(url, discovery); err != nil {
+			return err
+		}
+		if discovery.Issuer != m.conf.Issuer {
+			return fmt.Errorf("issuer didn't match, expected: %s, got: %s", m.conf.Issuer, discovery.Issuer)
+		}
+		if discovery.JwksUri == "" {
+			return errors.New("jwks_uri is missing in discovery document")
+		}
+		oidcProvider.jwksUri = discovery.JwksUri
+	}
+
+	keys := new(jose.JSONWebKeySet)
+	if err := oidcGetJson(oidcProvider.jwksUri, keys); err != nil {
+		return err
+	}
+	oidcProvider.keys = keys
+	oidcProvider.fetchedAt = time.Now()
+
+	return nil
+}
+
+func oidcGetJson(url string, v interface{}) error {
+	res, err := oidcHttpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", res.StatusCode, url)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
@@ -0,0 +1,227 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	log "github.com/sirupsen/logrus"
+)
+
+// SubmitPollBallotReq is used for multiple & ranked choice polls, because
+// SubmitPollResponseReq can have only one option
+type SubmitPollBallotReq struct {
+	RoomId string `json:"-"`
+	UserId string `json:"-"`
+	PollId string `json:"poll_id" validate:"required"`
+	Name   string `json:"name"`
+	// Options are in order of preference for ranked choice polls
+	Options []uint64 `json:"options" validate:"required,min=1"`
+}
+
+// pollBallot will be stored without user for anonymous polls
+type pollBallot struct {
+	UserId  string   `json:"user_id,omitempty"`
+	Name    string   `json:"name,omitempty"`
+	Options []uint64 `json:"options"`
+}
+
+func pollBallotsKey(roomId, pollId string) string {
+	return fmt.Sprintf("%s%s:ballots:%s", pollsKey, roomId, pollId)
+}
+
+func (m *newPollsModel) getPollType(roomId, pollId string) string {
+	err, v := m.GetPollResponsesByField(roomId, pollId, pollTypeField)
+	if err != nil || v == "" {
+		return PollTypeSingle
+	}
+	return v
+}
+
+func (m *newPollsModel) getPollInfo(roomId, pollId string) (*plugnmeet.PollInfo, error) {
+	result, err := m.rc.HGet(m.ctx, pollsKey+roomId, pollId).Result()
+	if err != nil {
+		return nil, errors.New("poll not found")
+	}
+	info := new(plugnmeet.PollInfo)
+	err = json.Unmarshal([]byte(result), info)
+	return info, err
+}
+
+// validatePollBallot will make sure options exist & aren't repeated
+func validatePollBallot(info *plugnmeet.PollInfo, settings *PollSettings, options []uint64) error {
+	if len(options) == 0 {
+		return errors.New("no option selected")
+	}
+	valid := make(map[uint64]bool, len(info.Options))
+	for _, o := range info.Options {
+		valid[uint64(o.Id)] = true
+	}
+
+	seen := make(map[uint64]bool, len(options))
+	for _, o := range options {
+		if !valid[o] {
+			return errors.New(fmt.Sprintf("invalid option %d", o))
+		}
+		if seen[o] {
+			return errors.New(fmt.Sprintf("option %d selected more than once", o))
+		}
+		seen[o] = true
+	}
+
+	if settings.Type == PollTypeMultiple && settings.MaxSelections > 0 && len(options) > settings.MaxSelections {
+		return errors.New(fmt.Sprintf("maximum %d options can be selected", settings.MaxSelections))
+	}
+	return nil
+}
+
+// SubmitPollBallot will accept ballot of any poll type. Multiple choice ballot will count
+// every selected option. Ranked choice ballot will count first preference & Borda points,
+// the first preference gets number of options as points, the next one less.
+func (m *newPollsModel) SubmitPollBallot(r *SubmitPollBallotReq, isAdmin bool) error {
+	if err := m.checkPollOpen(r.RoomId, r.PollId); err != nil {
+		return err
+	}
+	info, err := m.getPollInfo(r.RoomId, r.PollId)
+	if err != nil {
+		return err
+	}
+	settings := m.GetPollSettings(r.RoomId, r.PollId)
+
+	if settings.Type == PollTypeSingle {
+		if len(r.Options) != 1 {
+			return errors.New("only one option can be selected")
+		}
+		if err = validatePollBallot(info, settings, r.Options); err != nil {
+			return err
+		}
+		return m.UserSubmitResponse(&plugnmeet.SubmitPollResponseReq{
+			RoomId:         r.RoomId,
+			UserId:         r.UserId,
+			Name:           r.Name,
+			PollId:         r.PollId,
+			SelectedOption: r.Options[0],
+		}, isAdmin)
+	}
+
+	if settings.Type == PollTypeText {
+		return errors.New("this poll accepts text only")
+	}
+	if err = validatePollBallot(info, settings, r.Options); err != nil {
+		return err
+	}
+
+	vKey := pollVotersKey(r.RoomId, r.PollId)
+	added, err := m.rc.SAdd(m.ctx, vKey, r.UserId).Result()
+	if err != nil {
+		return err
+	}
+	if added == 0 {
+		return errors.New("user already voted")
+	}
+
+	ballot := &pollBallot{
+		Options: r.Options,
+	}
+	if !settings.Anonymous {
+		ballot.UserId = r.UserId
+		ballot.Name = r.Name
+	}
+	marshal, err := json.Marshal(ballot)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s%s:respondents:%s", pollsKey, r.RoomId, r.PollId)
+	bKey := pollBallotsKey(r.RoomId, r.PollId)
+	ttl := m.rs.RoomKeyTTL(r.RoomId)
+
+	pp := m.rc.Pipeline()
+	pp.RPush(m.ctx, bKey, marshal)
+	pp.Expire(m.ctx, bKey, ttl)
+	pp.Expire(m.ctx, vKey, ttl)
+	pp.HIncrBy(m.ctx, key, "total_resp", 1)
+	if settings.Type == PollTypeRanked {
+		pp.HIncrBy(m.ctx, key, fmt.Sprintf("%d_count", r.Options[0]), 1)
+		for i, o := range r.Options {
+			pp.HIncrBy(m.ctx, key, fmt.Sprintf("%d_points", o), int64(len(info.Options)-i))
+		}
+	} else {
+		for _, o := range r.Options {
+			pp.HIncrBy(m.ctx, key, fmt.Sprintf("%d_count", o), 1)
+		}
+	}
+	if _, err = pp.Exec(m.ctx); err != nil {
+		return err
+	}
+
+	userId := r.UserId
+	if settings.Anonymous {
+		userId = ""
+	}
+	_ = m.broadcastNotification(r.RoomId, userId, r.PollId, plugnmeet.DataMsgBodyType_NEW_POLL_RESPONSE, isAdmin)
+
+	return nil
+}
+
+func (m *newPollsModel) loadPollBallots(roomId, pollId string) ([]*pollBallot, error) {
+	result, err := m.rc.LRange(m.ctx, pollBallotsKey(roomId, pollId), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	ballots := make([]*pollBallot, 0, len(result))
+	for _, v := range result {
+		b := new(pollBallot)
+		if err = json.Unmarshal([]byte(v), b); err != nil {
+			log.Errorln(err)
+			continue
+		}
+		ballots = append(ballots, b)
+	}
+	return ballots, nil
+}
+
+// userBallotOption will return the first option of the ballot
+func (m *newPollsModel) userBallotOption(roomId, pollId, userId string) (uint64, error) {
+	voted, err := m.rc.SIsMember(m.ctx, pollVotersKey(roomId, pollId), userId).Result()
+	if err != nil || !voted {
+		return 0, err
+	}
+	if m.IsAnonymousPoll(roomId, pollId) {
+		return AnonymousPollVotedOption, nil
+	}
+
+	ballots, err := m.loadPollBallots(roomId, pollId)
+	if err != nil {
+		return 0, err
+	}
+	for _, b := range ballots {
+		if b.UserId == userId && len(b.Options) > 0 {
+			return b.Options[0], nil
+		}
+	}
+	return AnonymousPollVotedOption, nil
+}
+
+// GetPollResults will return aggregated results of any poll type.
+// Like GetResponsesResult, users need to wait until the poll was closed.
+// Individual responses will be included for admin only.
+func (m *newPollsModel) GetPollResults(roomId, pollId string, isAdmin bool) (*PollResult, error) {
+	info, err := m.getPollInfo(roomId, pollId)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsRunning && !isAdmin {
+		return nil, errors.New("need to wait until poll close")
+	}
+
+	result, err := m.buildPollResult(roomId, info)
+	if err != nil {
+		return nil, err
+	}
+	if !isAdmin {
+		result.Responses = nil
+	}
+	return result, nil
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"testing"
+)
+
+func TestValidatePollBallot(t *testing.T) {
+	info := &plugnmeet.PollInfo{
+		Options: []*plugnmeet.CreatePollOptions{
+			{Id: 1, Text: "Red"},
+			{Id: 2, Text: "Green"},
+			{Id: 3, Text: "Blue"},
+		},
+	}
+	single := &PollSettings{Type: PollTypeSingle}
+	multiple := &PollSettings{Type: PollTypeMultiple}
+	multipleMax2 := &PollSettings{Type: PollTypeMultiple, MaxSelections: 2}
+	ranked := &PollSettings{Type: PollTypeRanked}
+
+	tests := []struct {
+		settings *PollSettings
+		options  []uint64
+		wantErr  string
+	}{
+		{single, []uint64{2}, ""},
+		{single, nil, "no option selected"},
+		{single, []uint64{4}, "invalid option 4"},
+		{single, []uint64{0}, "invalid option 0"},
+		{multiple, []uint64{1, 2, 3}, ""},
+		{multiple, []uint64{3, 1}, ""},
+		{multiple, []uint64{1, 1}, "option 1 selected more than once"},
+		{multipleMax2, []uint64{1, 3}, ""},
+		{multipleMax2, []uint64{1, 2, 3}, "maximum 2 options can be selected"},
+		{ranked, []uint64{3, 1, 2}, ""},
+		// partial ranking is allowed
+		{ranked, []uint64{2}, ""},
+		{ranked, []uint64{3, 2, 3}, "option 3 selected more than once"},
+		{ranked, []uint64{1, 5}, "invalid option 5"},
+	}
+
+	for _, tt := range tests {
+		err := validatePollBallot(info, tt.settings, tt.options)
+		gotErr := ""
+		if err != nil {
+			gotErr = err.Error()
+		}
+		if gotErr != tt.wantErr {
+			t.Errorf("validatePollBallot(%s, %v) error = %q, want %q", tt.settings.Type, tt.options, gotErr, tt.wantErr)
+		}
+	}
+}
+
+func TestValidatePollSettings(t *testing.T) {
+	tests := []struct {
+		settings *PollSettings
+		want     *PollSettings
+		wantErr  bool
+	}{
+		{&PollSettings{}, &PollSettings{Type: PollTypeSingle}, false},
+		{&PollSettings{Type: PollTypeSingle, MaxSelections: 2, Moderated: true}, &PollSettings{Type: PollTypeSingle}, false},
+		{&PollSettings{Type: PollTypeMultiple, MaxSelections: 2}, &PollSettings{Type: PollTypeMultiple, MaxSelections: 2}, false},
+		{&PollSettings{Type: PollTypeMultiple, MaxSelections: 4}, nil, true},
+		{&PollSettings{Type: PollTypeMultiple, MaxSelections: -1}, nil, true},
+		{&PollSettings{Type: PollTypeRanked, MaxSelections: 2}, &PollSettings{Type: PollTypeRanked}, false},
+		{&PollSettings{Type: PollTypeText, MaxSelections: 2, Moderated: true}, &PollSettings{Type: PollTypeText, Moderated: true}, false},
+		{&PollSettings{Type: "approval"}, nil, true},
+		{&PollSettings{Duration: pollMaxDuration}, &PollSettings{Type: PollTypeSingle, Duration: pollMaxDuration}, false},
+		{&PollSettings{Duration: pollMaxDuration + 1}, nil, true},
+		{&PollSettings{Duration: -1}, nil, true},
+	}
+
+	for _, tt := range tests {
+		err := validatePollSettings(tt.settings, 3)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("validatePollSettings(%+v) error = %v, wantErr %v", tt.settings, err, tt.wantErr)
+			continue
+		}
+		if err == nil && *tt.settings != *tt.want {
+			t.Errorf("validatePollSettings() = %+v, want %+v", tt.settings, tt.want)
+		}
+	}
+}
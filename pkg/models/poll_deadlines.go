@@ -0,0 +1,68 @@
+package models
+
+import (
+	"github.com/go-redis/redis/v8"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pollFinalResults will be sent to everyone when timed poll was closed
+type pollFinalResults struct {
+	PollId string                         `json:"poll_id"`
+	Result *plugnmeet.PollResponsesResult `json:"result"`
+	// Details will have points of ranked choice polls
+	Details *PollResult `json:"details,omitempty"`
+}
+
+// CloseExpiredPolls will close timed polls those time is over.
+// Poll will be removed from deadlines first, so that only one server will close it.
+func (m *newPollsModel) CloseExpiredPolls() {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	members, err := m.rc.ZRangeByScore(m.ctx, pollDeadlinesKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: now,
+	}).Result()
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	for _, member := range members {
+		removed, err := m.rc.ZRem(m.ctx, pollDeadlinesKey, member).Result()
+		if err != nil || removed == 0 {
+			continue
+		}
+		parts := strings.SplitN(member, "|", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		roomId, pollId := parts[0], parts[1]
+
+		err = m.ClosePoll(&plugnmeet.ClosePollReq{
+			RoomId: roomId,
+			PollId: pollId,
+			UserId: "system",
+		}, true)
+		if err != nil {
+			log.Errorln("closing timed poll " + pollId + " failed: " + err.Error())
+			continue
+		}
+
+		result, err := m.GetResponsesResult(roomId, pollId)
+		if err != nil {
+			log.Errorln(err)
+			continue
+		}
+		v := &pollFinalResults{
+			PollId: pollId,
+			Result: result,
+		}
+		if m.getPollType(roomId, pollId) != PollTypeSingle {
+			v.Details, _ = m.GetPollResults(roomId, pollId, false)
+		}
+		broadcastSystemMsg(roomId, DataMsgBodyType_POLL_RESULTS, v)
+	}
+}
@@ -0,0 +1,418 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	PollExportFormatJson = "json"
+	PollExportFormatCsv  = "csv"
+	// PollExportFormatXls is SpreadsheetML which can be opened by Excel
+	PollExportFormatXls = "xls"
+)
+
+type ExportPollsReq struct {
+	RoomId string `query:"room_id" validate:"required,require-valid-Id"`
+	// RoomSid of the session, empty will use the last session of the room
+	RoomSid string `query:"room_sid"`
+	Format  string `query:"format" validate:"omitempty,oneof=json csv xls"`
+}
+
+type PollResult struct {
+	PollId         string              `json:"poll_id"`
+	Question       string              `json:"question"`
+	Type           string              `json:"type,omitempty"`
+	Anonymous      bool                `json:"anonymous"`
+	TotalResponses int64               `json:"total_responses"`
+	CreatedBy      string              `json:"created_by"`
+	Created        int64               `json:"created"`
+	Options        []*PollResultOption `json:"options"`
+	// Responses will be empty for anonymous polls except text polls
+	Responses []*PollUserResponse `json:"responses,omitempty"`
+	// Terms of approved responses of text polls
+	Terms []*PollTerm `json:"terms,omitempty"`
+}
+
+type PollResultOption struct {
+	Id   uint32 `json:"id"`
+	Text string `json:"text"`
+	// VoteCount is number of first preferences for ranked choice polls
+	VoteCount int64 `json:"vote_count"`
+	// Points is Borda count of ranked choice polls
+	Points int64 `json:"points,omitempty"`
+}
+
+type PollUserResponse struct {
+	UserId   string `json:"user_id"`
+	Name     string `json:"name"`
+	OptionId uint64 `json:"option_id"`
+	// Options of multiple & ranked choice polls, OptionId will be the first one
+	Options []uint64 `json:"options,omitempty"`
+	// Text of approved response of text polls
+	Text string `json:"text,omitempty"`
+}
+
+// collectPollResults will build results of all polls of the room from redis
+func (m *newPollsModel) collectPollResults(roomId string) ([]*PollResult, error) {
+	err, polls := m.ListPolls(roomId)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*PollResult, 0, len(polls))
+	for _, p := range polls {
+		r, err := m.buildPollResult(roomId, p)
+		if err != nil {
+			log.Errorln(err)
+			continue
+		}
+		results = append(results, r)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Created < results[j].Created
+	})
+	return results, nil
+}
+
+func (m *newPollsModel) buildPollResult(roomId string, p *plugnmeet.PollInfo) (*PollResult, error) {
+	err, resp := m.GetPollResponsesDetails(roomId, p.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &PollResult{
+		PollId:    p.Id,
+		Question:  p.Question,
+		Type:      resp[pollTypeField],
+		Anonymous: resp[pollAnonymousField] == "1",
+		CreatedBy: p.CreatedBy,
+		Created:   p.Created,
+	}
+	if r.Type == "" {
+		r.Type = PollTypeSingle
+	}
+	r.TotalResponses, _ = strconv.ParseInt(resp["total_resp"], 10, 64)
+	for _, o := range p.Options {
+		ro := &PollResultOption{
+			Id:   o.Id,
+			Text: o.Text,
+		}
+		ro.VoteCount, _ = strconv.ParseInt(resp[fmt.Sprintf("%d_count", o.Id)], 10, 64)
+		if r.Type == PollTypeRanked {
+			ro.Points, _ = strconv.ParseInt(resp[fmt.Sprintf("%d_points", o.Id)], 10, 64)
+		}
+		r.Options = append(r.Options, ro)
+	}
+	if r.Type == PollTypeText {
+		return r, m.addPollTextResults(roomId, r)
+	}
+	if r.Anonymous {
+		return r, nil
+	}
+
+	if r.Type != PollTypeSingle {
+		ballots, err := m.loadPollBallots(roomId, p.Id)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range ballots {
+			if len(b.Options) == 0 {
+				continue
+			}
+			r.Responses = append(r.Responses, &PollUserResponse{
+				UserId:   b.UserId,
+				Name:     b.Name,
+				OptionId: b.Options[0],
+				Options:  b.Options,
+			})
+		}
+		return r, nil
+	}
+
+	if resp["all_respondents"] != "" {
+		var respondents []string
+		if err = json.Unmarshal([]byte(resp["all_respondents"]), &respondents); err == nil {
+			for _, v := range respondents {
+				// format userId:option_id:name
+				parts := strings.SplitN(v, ":", 3)
+				if len(parts) < 2 {
+					continue
+				}
+				ur := &PollUserResponse{
+					UserId: parts[0],
+				}
+				ur.OptionId, _ = strconv.ParseUint(parts[1], 10, 64)
+				if len(parts) == 3 {
+					ur.Name = parts[2]
+				}
+				r.Responses = append(r.Responses, ur)
+			}
+		}
+	}
+	return r, nil
+}
+
+// addPollTextResults will add terms & approved responses, responses of anonymous polls won't have user
+func (m *newPollsModel) addPollTextResults(roomId string, r *PollResult) error {
+	var err error
+	r.Terms, err = m.topPollTerms(roomId, r.PollId, maxPollWordCloudLimit)
+	if err != nil {
+		return err
+	}
+	responses, err := m.ListPollTextResponses(roomId, r.PollId, PollTextStatusApproved)
+	if err != nil {
+		return err
+	}
+	for _, t := range responses {
+		r.Responses = append(r.Responses, &PollUserResponse{
+			UserId: t.UserId,
+			Name:   t.Name,
+			Text:   t.Text,
+		})
+	}
+	return nil
+}
+
+// ArchivePollResults will store results of the session in DB, because
+// polls will be removed from redis when the session ends
+func (m *newPollsModel) ArchivePollResults(roomId, roomSid string) error {
+	results, err := m.collectPollResults(roomId)
+	if err != nil || len(results) == 0 {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, 10*time.Second)
+	defer cancel()
+
+	placeholders := make([]string, 0, len(results))
+	args := make([]interface{}, 0, len(results)*5)
+	for _, r := range results {
+		marshal, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		placeholders = append(placeholders, "(?, ?, ?, ?, ?)")
+		args = append(args, r.PollId, roomId, roomSid, string(marshal), r.Created)
+	}
+
+	query := "INSERT IGNORE INTO " + config.AppCnf.FormatDBTable("poll_results") + " (poll_id, room_id, room_sid, result, created_at) VALUES " + strings.Join(placeholders, ", ")
+	_, err = config.AppCnf.DB.ExecContext(ctx, query, args...)
+	return err
+}
+
+// ExportPolls will return results of the session in requested format with file name.
+// Results of the running session will be taken from redis.
+func (m *newPollsModel) ExportPolls(r *ExportPollsReq) ([]byte, string, error) {
+	var results []*PollResult
+	var err error
+
+	roomSid := r.RoomSid
+	room, _ := NewRoomModel().GetRoomInfo(r.RoomId, "", 1)
+	if room.Id > 0 && (roomSid == "" || roomSid == room.Sid) {
+		roomSid = room.Sid
+		results, err = m.collectPollResults(r.RoomId)
+	} else {
+		if roomSid == "" {
+			roomSid, err = NewChatExportModel().lastSessionSid(r.RoomId)
+			if err != nil {
+				return nil, "", err
+			}
+		}
+		results, err = loadArchivedPollResults(r.RoomId, roomSid)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if len(results) == 0 {
+		return nil, "", errors.New("no polls found")
+	}
+
+	format := r.Format
+	if format == "" {
+		format = PollExportFormatJson
+	}
+	fileName := fmt.Sprintf("%s_polls.%s", roomSid, format)
+
+	switch format {
+	case PollExportFormatCsv:
+		data, err := pollResultsToCsv(results)
+		return data, fileName, err
+	case PollExportFormatXls:
+		data, err := pollResultsToXls(results)
+		return data, fileName, err
+	}
+	data, err := json.Marshal(results)
+	return data, fileName, err
+}
+
+func loadArchivedPollResults(roomId, roomSid string) ([]*PollResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := config.AppCnf.DB.QueryContext(ctx, "SELECT result FROM "+config.AppCnf.FormatDBTable("poll_results")+" WHERE room_id = ? AND room_sid = ? ORDER BY created_at, id", roomId, roomSid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*PollResult
+	for rows.Next() {
+		var data sql.NullString
+		if err = rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		r := new(PollResult)
+		if err = json.Unmarshal([]byte(data.String), r); err != nil {
+			log.Errorln(err)
+			continue
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+func pollOptionText(r *PollResult, id uint64) string {
+	for _, o := range r.Options {
+		if uint64(o.Id) == id {
+			return o.Text
+		}
+	}
+	return ""
+}
+
+// pollResponseCells will return option ids & texts of the response,
+// options of ranked choice polls will be in order of preference
+func pollResponseCells(r *PollResult, u *PollUserResponse) (string, string) {
+	if r.Type == PollTypeText {
+		return "", u.Text
+	}
+	if len(u.Options) == 0 {
+		return strconv.FormatUint(u.OptionId, 10), pollOptionText(r, u.OptionId)
+	}
+	sep := ", "
+	if r.Type == PollTypeRanked {
+		sep = " > "
+	}
+	ids := make([]string, len(u.Options))
+	texts := make([]string, len(u.Options))
+	for i, o := range u.Options {
+		ids[i] = strconv.FormatUint(o, 10)
+		texts[i] = pollOptionText(r, o)
+	}
+	return strings.Join(ids, ","), strings.Join(texts, sep)
+}
+
+// pollResultsRows will return rows of options & responses sheets
+func pollResultsRows(results []*PollResult) (options [][]string, responses [][]string) {
+	options = append(options, []string{"poll_id", "question", "type", "anonymous", "total_responses", "option_id", "option", "vote_count", "points"})
+	responses = append(responses, []string{"poll_id", "question", "user_id", "name", "option_id", "option"})
+	for _, r := range results {
+		pollType := r.Type
+		if pollType == "" {
+			pollType = PollTypeSingle
+		}
+		for _, o := range r.Options {
+			options = append(options, []string{
+				r.PollId,
+				r.Question,
+				pollType,
+				strconv.FormatBool(r.Anonymous),
+				strconv.FormatInt(r.TotalResponses, 10),
+				strconv.FormatUint(uint64(o.Id), 10),
+				o.Text,
+				strconv.FormatInt(o.VoteCount, 10),
+				strconv.FormatInt(o.Points, 10),
+			})
+		}
+		// terms of text polls will be written as options without id
+		for _, t := range r.Terms {
+			options = append(options, []string{
+				r.PollId,
+				r.Question,
+				pollType,
+				strconv.FormatBool(r.Anonymous),
+				strconv.FormatInt(r.TotalResponses, 10),
+				"",
+				t.Term,
+				strconv.FormatInt(t.Count, 10),
+				"0",
+			})
+		}
+		for _, u := range r.Responses {
+			ids, texts := pollResponseCells(r, u)
+			responses = append(responses, []string{
+				r.PollId,
+				r.Question,
+				u.UserId,
+				u.Name,
+				ids,
+				texts,
+			})
+		}
+	}
+	return options, responses
+}
+
+// pollResultsToCsv will write options & responses as two sections separated by an empty line
+func pollResultsToCsv(results []*PollResult) ([]byte, error) {
+	options, responses := pollResultsRows(results)
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+	_ = w.WriteAll(options)
+	if len(responses) > 1 {
+		_ = w.Write([]string{})
+		_ = w.WriteAll(responses)
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// pollResultsToXls will write SpreadsheetML 2003 workbook with options & responses sheets
+func pollResultsToXls(results []*PollResult) ([]byte, error) {
+	options, responses := pollResultsRows(results)
+
+	buf := new(bytes.Buffer)
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<?mso-application progid="Excel.Sheet"?>` + "\n")
+	buf.WriteString(`<Workbook xmlns="urn:schemas-microsoft-com:office:spreadsheet" xmlns:ss="urn:schemas-microsoft-com:office:spreadsheet">` + "\n")
+	sheets := []struct {
+		name string
+		rows [][]string
+	}{
+		{"Polls", options},
+		{"Responses", responses},
+	}
+	for _, s := range sheets {
+		buf.WriteString(`<Worksheet ss:Name="` + s.name + `"><Table>` + "\n")
+		for _, row := range s.rows {
+			buf.WriteString("<Row>")
+			for _, cell := range row {
+				buf.WriteString(`<Cell><Data ss:Type="String">`)
+				if err := xml.EscapeText(buf, []byte(cell)); err != nil {
+					return nil, err
+				}
+				buf.WriteString("</Data></Cell>")
+			}
+			buf.WriteString("</Row>\n")
+		}
+		buf.WriteString("</Table></Worksheet>\n")
+	}
+	buf.WriteString("</Workbook>\n")
+
+	return buf.Bytes(), nil
+}
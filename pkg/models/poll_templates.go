@@ -0,0 +1,240 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"time"
+)
+
+// PollTemplate is reusable poll of the api key
+type PollTemplate struct {
+	TemplateId string                `json:"template_id"`
+	Name       string                `json:"name"`
+	Question   string                `json:"question"`
+	Options    []*PollTemplateOption `json:"options"`
+	Settings   *PollSettings         `json:"settings"`
+	CreatedAt  int64                 `json:"created_at"`
+	ModifiedAt int64                 `json:"modified_at"`
+}
+
+type PollTemplateOption struct {
+	Id   uint32 `json:"id"`
+	Text string `json:"text" validate:"required,max=255"`
+}
+
+// SavePollTemplateReq will copy question, options & settings from PollId if it was set.
+// PollId can be used from the room only.
+type SavePollTemplateReq struct {
+	Name     string                `json:"name" validate:"required,max=100"`
+	PollId   string                `json:"poll_id"`
+	Question string                `json:"question" validate:"required_without=PollId,max=1000"`
+	Options  []*PollTemplateOption `json:"options" validate:"max=50,dive"`
+	Settings *PollSettings         `json:"settings"`
+}
+
+type DeletePollTemplateReq struct {
+	TemplateId string `json:"template_id" validate:"required"`
+}
+
+// LaunchPollTemplateReq will use the room of the token for /api
+type LaunchPollTemplateReq struct {
+	TemplateId string `json:"template_id" validate:"required"`
+	RoomId     string `json:"room_id"`
+}
+
+type pollTemplatesModel struct {
+	app *config.AppConfig
+	db  *sql.DB
+	ctx context.Context
+}
+
+func NewPollTemplatesModel() *pollTemplatesModel {
+	return &pollTemplatesModel{
+		app: config.AppCnf,
+		db:  config.AppCnf.DB,
+		ctx: context.Background(),
+	}
+}
+
+// SaveTemplate will save the template for the api key.
+// roomId is required to copy a running or closed poll of the room.
+func (m *pollTemplatesModel) SaveTemplate(apiKey, roomId string, r *SavePollTemplateReq) (*PollTemplate, error) {
+	t := &PollTemplate{
+		TemplateId: uuid.NewString(),
+		Name:       r.Name,
+		Question:   r.Question,
+		Options:    r.Options,
+		Settings:   r.Settings,
+		CreatedAt:  time.Now().Unix(),
+	}
+	t.ModifiedAt = t.CreatedAt
+
+	if r.PollId != "" {
+		if roomId == "" {
+			return nil, errors.New("poll_id can be used from the room only")
+		}
+		if err := copyPollToTemplate(roomId, r.PollId, t); err != nil {
+			return nil, err
+		}
+	}
+	if t.Settings == nil {
+		t.Settings = new(PollSettings)
+	}
+	t.Settings.ClosesAt = 0
+	for i, o := range t.Options {
+		if o.Id == 0 {
+			o.Id = uint32(i + 1)
+		}
+	}
+	if err := validatePollSettings(t.Settings, len(t.Options)); err != nil {
+		return nil, err
+	}
+	if t.Settings.Type != PollTypeText && len(t.Options) < 2 {
+		return nil, errors.New("at least two options are required")
+	}
+
+	options, err := json.Marshal(t.Options)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := json.Marshal(t.Settings)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, 3*time.Second)
+	defer cancel()
+	query := "INSERT INTO " + m.app.FormatDBTable("poll_templates") + " (template_id, api_key, name, question, options, settings, created_at, modified_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err = m.db.ExecContext(ctx, query, t.TemplateId, apiKey, t.Name, t.Question, string(options), string(settings), t.CreatedAt, t.ModifiedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func copyPollToTemplate(roomId, pollId string, t *PollTemplate) error {
+	pm := NewPollsModel()
+	info, err := pm.getPollInfo(roomId, pollId)
+	if err != nil {
+		return err
+	}
+
+	t.Question = info.Question
+	t.Options = nil
+	for _, o := range info.Options {
+		t.Options = append(t.Options, &PollTemplateOption{
+			Id:   o.Id,
+			Text: o.Text,
+		})
+	}
+	t.Settings = pm.GetPollSettings(roomId, pollId)
+	if t.Settings.ClosesAt > 0 {
+		t.Settings.Duration = t.Settings.ClosesAt - info.Created
+	}
+	return nil
+}
+
+// ListTemplates will return templates of the api key
+func (m *pollTemplatesModel) ListTemplates(apiKey string) ([]*PollTemplate, error) {
+	ctx, cancel := context.WithTimeout(m.ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, "SELECT template_id, name, question, options, settings, created_at, modified_at FROM "+m.app.FormatDBTable("poll_templates")+" WHERE api_key = ? ORDER BY id", apiKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []*PollTemplate{}
+	for rows.Next() {
+		t, err := scanPollTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, t)
+	}
+
+	return list, rows.Err()
+}
+
+func (m *pollTemplatesModel) GetTemplate(apiKey, templateId string) (*PollTemplate, error) {
+	ctx, cancel := context.WithTimeout(m.ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, "SELECT template_id, name, question, options, settings, created_at, modified_at FROM "+m.app.FormatDBTable("poll_templates")+" WHERE template_id = ? AND api_key = ?", templateId, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("no template found")
+	}
+	return scanPollTemplate(rows)
+}
+
+func scanPollTemplate(rows *sql.Rows) (*PollTemplate, error) {
+	t := new(PollTemplate)
+	var options, settings string
+	if err := rows.Scan(&t.TemplateId, &t.Name, &t.Question, &options, &settings, &t.CreatedAt, &t.ModifiedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(options), &t.Options); err != nil {
+		return nil, err
+	}
+	t.Settings = new(PollSettings)
+	if err := json.Unmarshal([]byte(settings), t.Settings); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (m *pollTemplatesModel) DeleteTemplate(apiKey, templateId string) error {
+	ctx, cancel := context.WithTimeout(m.ctx, 3*time.Second)
+	defer cancel()
+
+	res, err := m.db.ExecContext(ctx, "DELETE FROM "+m.app.FormatDBTable("poll_templates")+" WHERE template_id = ? AND api_key = ?", templateId, apiKey)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New("no template found")
+	}
+	return nil
+}
+
+// LaunchTemplate will create poll from the template in the active room
+func (m *pollTemplatesModel) LaunchTemplate(apiKey, roomId, userId, templateId string) (string, error) {
+	room, _ := NewRoomModel().GetRoomInfo(roomId, "", 1)
+	if room.Id == 0 {
+		return "", errors.New("room isn't active")
+	}
+	t, err := m.GetTemplate(apiKey, templateId)
+	if err != nil {
+		return "", err
+	}
+
+	req := &plugnmeet.CreatePollReq{
+		RoomId:   roomId,
+		UserId:   userId,
+		Question: t.Question,
+	}
+	for _, o := range t.Options {
+		req.Options = append(req.Options, &plugnmeet.CreatePollOptions{
+			Id:   o.Id,
+			Text: o.Text,
+		})
+	}
+
+	err, pollId := NewPollsModel().CreatePoll(req, true, t.Settings)
+	return pollId, err
+}
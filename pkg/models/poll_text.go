@@ -0,0 +1,312 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// status of text responses
+const (
+	PollTextStatusPending  = "pending"
+	PollTextStatusApproved = "approved"
+	PollTextStatusRejected = "rejected"
+
+	defaultPollWordCloudLimit = 50
+	maxPollWordCloudLimit     = 200
+)
+
+// pollStopWords won't be counted in word cloud
+var pollStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "but": true,
+	"by": true, "for": true, "from": true, "has": true, "have": true, "i": true, "in": true, "is": true,
+	"it": true, "its": true, "me": true, "my": true, "not": true, "of": true, "on": true, "or": true,
+	"so": true, "that": true, "the": true, "this": true, "to": true, "was": true, "we": true, "were": true,
+	"will": true, "with": true, "you": true, "your": true,
+}
+
+type SubmitPollTextReq struct {
+	RoomId string `json:"-"`
+	UserId string `json:"-"`
+	PollId string `json:"poll_id" validate:"required"`
+	Name   string `json:"name"`
+	Text   string `json:"text" validate:"required,max=140"`
+}
+
+type ModeratePollTextReq struct {
+	RoomId     string `json:"-"`
+	UserId     string `json:"-"`
+	PollId     string `json:"poll_id" validate:"required"`
+	ResponseId string `json:"response_id" validate:"required"`
+	Approve    bool   `json:"approve"`
+}
+
+// PollTextResponse will be stored without user for anonymous polls
+type PollTextResponse struct {
+	Id      string   `json:"id"`
+	UserId  string   `json:"user_id,omitempty"`
+	Name    string   `json:"name,omitempty"`
+	Text    string   `json:"text"`
+	Status  string   `json:"status"`
+	Matches []string `json:"matches,omitempty"`
+	Created int64    `json:"created"`
+}
+
+type PollTerm struct {
+	Term  string `json:"term"`
+	Count int64  `json:"count"`
+}
+
+// pollTextPending will be sent to the moderators
+type pollTextPending struct {
+	PollId   string            `json:"poll_id"`
+	Response *PollTextResponse `json:"response"`
+}
+
+func pollTextsKey(roomId, pollId string) string {
+	return fmt.Sprintf("%s%s:texts:%s", pollsKey, roomId, pollId)
+}
+
+func pollTermsKey(roomId, pollId string) string {
+	return fmt.Sprintf("%s%s:terms:%s", pollsKey, roomId, pollId)
+}
+
+// tokenizePollText will return unique lower case terms without stop words
+func tokenizePollText(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\''
+	})
+
+	seen := make(map[string]bool, len(words))
+	var terms []string
+	for _, w := range words {
+		w = strings.Trim(w, "'")
+		if len([]rune(w)) < 2 || pollStopWords[w] || seen[w] {
+			continue
+		}
+		seen[w] = true
+		terms = append(terms, w)
+	}
+	return terms
+}
+
+// SubmitPollText will accept response of text poll. Response will be held in moderation queue
+// if the poll is moderated or the chat filter has matched it, otherwise counted immediately.
+func (m *newPollsModel) SubmitPollText(r *SubmitPollTextReq, isAdmin bool) error {
+	if err := m.checkPollOpen(r.RoomId, r.PollId); err != nil {
+		return err
+	}
+	settings := m.GetPollSettings(r.RoomId, r.PollId)
+	if settings.Type != PollTypeText {
+		return errors.New("this poll doesn't accept text")
+	}
+	text := strings.Join(strings.Fields(r.Text), " ")
+	if text == "" {
+		return errors.New("empty response")
+	}
+
+	vKey := pollVotersKey(r.RoomId, r.PollId)
+	added, err := m.rc.SAdd(m.ctx, vKey, r.UserId).Result()
+	if err != nil {
+		return err
+	}
+	if added == 0 {
+		return errors.New("user already voted")
+	}
+
+	resp := &PollTextResponse{
+		Id:      uuid.NewString(),
+		Text:    text,
+		Status:  PollTextStatusApproved,
+		Created: time.Now().Unix(),
+	}
+	if !settings.Anonymous {
+		resp.UserId = r.UserId
+		resp.Name = r.Name
+	}
+	if config.AppCnf.Client.ChatFilter.Enabled {
+		for _, re := range loadChatFilterPatterns(r.RoomId) {
+			resp.Matches = append(resp.Matches, re.FindAllString(text, -1)...)
+		}
+	}
+	if settings.Moderated || len(resp.Matches) > 0 {
+		resp.Status = PollTextStatusPending
+	}
+
+	marshal, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s%s:respondents:%s", pollsKey, r.RoomId, r.PollId)
+	tKey := pollTextsKey(r.RoomId, r.PollId)
+	ttl := m.rs.RoomKeyTTL(r.RoomId)
+
+	pp := m.rc.Pipeline()
+	pp.HSet(m.ctx, tKey, resp.Id, marshal)
+	pp.Expire(m.ctx, tKey, ttl)
+	pp.Expire(m.ctx, vKey, ttl)
+	pp.HIncrBy(m.ctx, key, "total_resp", 1)
+	if resp.Status == PollTextStatusApproved {
+		m.countPollTerms(pp, r.RoomId, r.PollId, text, 1)
+	}
+	if _, err = pp.Exec(m.ctx); err != nil {
+		return err
+	}
+
+	if resp.Status == PollTextStatusPending {
+		sendSystemMsgToModerators(r.RoomId, DataMsgBodyType_POLL_TEXT_PENDING, &pollTextPending{
+			PollId:   r.PollId,
+			Response: resp,
+		})
+	}
+
+	_ = m.broadcastNotification(r.RoomId, resp.UserId, r.PollId, plugnmeet.DataMsgBodyType_NEW_POLL_RESPONSE, isAdmin)
+
+	return nil
+}
+
+func (m *newPollsModel) countPollTerms(pp redis.Pipeliner, roomId, pollId, text string, incr int64) {
+	terms := tokenizePollText(text)
+	if len(terms) == 0 {
+		return
+	}
+	key := pollTermsKey(roomId, pollId)
+	for _, t := range terms {
+		pp.HIncrBy(m.ctx, key, t, incr)
+	}
+	pp.Expire(m.ctx, key, m.rs.RoomKeyTTL(roomId))
+}
+
+// ModeratePollText will approve or reject the response. Approved response can be rejected later,
+// in that case the terms will be removed from word cloud.
+func (m *newPollsModel) ModeratePollText(r *ModeratePollTextReq, isAdmin bool) (*PollTextResponse, error) {
+	tKey := pollTextsKey(r.RoomId, r.PollId)
+	resp := new(PollTextResponse)
+
+	err := m.rc.Watch(m.ctx, func(tx *redis.Tx) error {
+		result, err := tx.HGet(m.ctx, tKey, r.ResponseId).Result()
+		if err == redis.Nil {
+			return errors.New("response not found")
+		} else if err != nil {
+			return err
+		}
+		if err = json.Unmarshal([]byte(result), resp); err != nil {
+			return err
+		}
+
+		status := PollTextStatusRejected
+		if r.Approve {
+			status = PollTextStatusApproved
+		}
+		if resp.Status == status {
+			return nil
+		}
+		previous := resp.Status
+		resp.Status = status
+		marshal, err := json.Marshal(resp)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(m.ctx, func(pp redis.Pipeliner) error {
+			pp.HSet(m.ctx, tKey, resp.Id, marshal)
+			if status == PollTextStatusApproved {
+				m.countPollTerms(pp, r.RoomId, r.PollId, resp.Text, 1)
+			} else if previous == PollTextStatusApproved {
+				m.countPollTerms(pp, r.RoomId, r.PollId, resp.Text, -1)
+			}
+			return nil
+		})
+		return err
+	}, tKey)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = m.broadcastNotification(r.RoomId, r.UserId, r.PollId, plugnmeet.DataMsgBodyType_NEW_POLL_RESPONSE, isAdmin)
+	return resp, nil
+}
+
+// ListPollTextResponses will return responses by status, empty status will return all
+func (m *newPollsModel) ListPollTextResponses(roomId, pollId, status string) ([]*PollTextResponse, error) {
+	result, err := m.rc.HVals(m.ctx, pollTextsKey(roomId, pollId)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*PollTextResponse, 0, len(result))
+	for _, v := range result {
+		resp := new(PollTextResponse)
+		if err = json.Unmarshal([]byte(v), resp); err != nil {
+			log.Errorln(err)
+			continue
+		}
+		if status != "" && resp.Status != status {
+			continue
+		}
+		responses = append(responses, resp)
+	}
+
+	sort.Slice(responses, func(i, j int) bool {
+		return responses[i].Created < responses[j].Created
+	})
+	return responses, nil
+}
+
+// GetPollWordCloud will return top terms of approved responses.
+// Like GetResponsesResult, users need to wait until the poll was closed.
+func (m *newPollsModel) GetPollWordCloud(roomId, pollId string, limit int, isAdmin bool) ([]*PollTerm, error) {
+	info, err := m.getPollInfo(roomId, pollId)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsRunning && !isAdmin {
+		return nil, errors.New("need to wait until poll close")
+	}
+	return m.topPollTerms(roomId, pollId, limit)
+}
+
+func (m *newPollsModel) topPollTerms(roomId, pollId string, limit int) ([]*PollTerm, error) {
+	if limit <= 0 {
+		limit = defaultPollWordCloudLimit
+	} else if limit > maxPollWordCloudLimit {
+		limit = maxPollWordCloudLimit
+	}
+
+	result, err := m.rc.HGetAll(m.ctx, pollTermsKey(roomId, pollId)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	terms := make([]*PollTerm, 0, len(result))
+	for t, v := range result {
+		count, _ := strconv.ParseInt(v, 10, 64)
+		if count > 0 {
+			terms = append(terms, &PollTerm{
+				Term:  t,
+				Count: count,
+			})
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Count == terms[j].Count {
+			return terms[i].Term < terms[j].Term
+		}
+		return terms[i].Count > terms[j].Count
+	})
+	if len(terms) > limit {
+		terms = terms[:limit]
+	}
+	return terms, nil
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenizePollText(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"The quick brown fox", []string{"quick", "brown", "fox"}},
+		{"Go, go & GO!", []string{"go"}},
+		{"it's a dog's life", []string{"it's", "dog's", "life"}},
+		{"'quoted' words", []string{"quoted", "words"}},
+		{"x y z ok", []string{"ok"}},
+		{"version 2 or v2.0", []string{"version", "v2"}},
+		{"naïve café Straße", []string{"naïve", "café", "straße"}},
+		{"to be or not to be", nil},
+	}
+
+	for _, tt := range tests {
+		got := tokenizePollText(tt.text)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tokenizePollText(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
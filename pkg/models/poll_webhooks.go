@@ -0,0 +1,53 @@
+package models
+
+import (
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	pollCreatedEvent = "poll_created"
+	pollClosedEvent  = "poll_closed"
+)
+
+// pollNotifyEvent will add the poll with common notify event.
+// Counts will be empty for poll_created.
+type pollNotifyEvent struct {
+	*plugnmeet.CommonNotifyEvent
+	Poll     *PollResult   `json:"poll"`
+	Settings *PollSettings `json:"settings"`
+	ClosedBy string        `json:"closed_by,omitempty"`
+}
+
+func (m *newPollsModel) sendPollWebhook(event, roomId, pollId string) {
+	room, _ := NewRoomModel().GetRoomInfo(roomId, "", 1)
+	if room.Id == 0 {
+		return
+	}
+	info, err := m.getPollInfo(roomId, pollId)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	result, err := m.buildPollResult(roomId, info)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	msg := &pollNotifyEvent{
+		CommonNotifyEvent: &plugnmeet.CommonNotifyEvent{
+			Event: &event,
+			Room: &plugnmeet.NotifyEventRoom{
+				Sid:    &room.Sid,
+				RoomId: &roomId,
+			},
+		},
+		Poll:     result,
+		Settings: m.GetPollSettings(roomId, pollId),
+		ClosedBy: info.ClosedBy,
+	}
+	if err = NewWebhookNotifier().Notify(room.Sid, msg); err != nil {
+		log.Errorln(err)
+	}
+}
@@ -10,26 +10,74 @@ import (
 	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
 	"github.com/mynaparrot/plugnmeet-server/pkg/config"
 	log "github.com/sirupsen/logrus"
+	"math"
 	"strconv"
 	"strings"
 	"time"
 )
 
-const pollsKey = "pnm:polls:"
+const (
+	pollsKey = "pnm:polls:"
+	// pollAnonymousField of respondent hash, votes of anonymous polls can't be attributed
+	pollAnonymousField = "anonymous"
+	// pollClosesAtField of respondent hash is unix time when timed poll will be closed
+	pollClosesAtField = "closes_at"
+	// pollDeadlinesKey keeps timed polls of all rooms, member is roomId|pollId & score is closes_at
+	pollDeadlinesKey = "pnm:poll_deadlines"
+	pollMaxDuration  = 24 * 60 * 60
+	// pollTypeField of respondent hash, will be empty for single choice polls
+	pollTypeField          = "type"
+	pollMaxSelectionsField = "max_selections"
+	// pollModeratedField of respondent hash, text responses will need approval
+	pollModeratedField = "moderated"
+)
+
+const (
+	PollTypeSingle   = "single"
+	PollTypeMultiple = "multiple"
+	PollTypeRanked   = "ranked"
+	PollTypeText     = "text"
+)
+
+// AnonymousPollVotedOption will be returned as selected option if the user has voted
+// in anonymous poll, because the option of the user isn't stored
+const AnonymousPollVotedOption uint64 = math.MaxUint64
+
+// PollSettings aren't part of CreatePollReq, so those will be sent as query
+type PollSettings struct {
+	Anonymous bool `query:"anonymous" json:"anonymous"`
+	// Duration in seconds, poll will be closed automatically
+	Duration int64 `query:"duration" json:"duration,omitempty"`
+	// ClosesAt will be calculated from Duration
+	ClosesAt int64 `query:"-" json:"closes_at,omitempty"`
+	// Type of the poll, default single
+	Type string `query:"type" json:"type"`
+	// MaxSelections is the limit of options for multiple choice polls, 0 means all options
+	MaxSelections int `query:"max_selections" json:"max_selections,omitempty"`
+	// Moderated text polls will keep every response in moderation queue until approved
+	Moderated bool `query:"moderated" json:"moderated,omitempty"`
+}
 
 type newPollsModel struct {
 	rc  *redis.Client
 	ctx context.Context
+	rs  *RoomService
 }
 
 func NewPollsModel() *newPollsModel {
 	return &newPollsModel{
 		rc:  config.AppCnf.RDS,
 		ctx: context.Background(),
+		rs:  NewRoomService(),
 	}
 }
 
-func (m *newPollsModel) CreatePoll(r *plugnmeet.CreatePollReq, isAdmin bool) (error, string) {
+func (m *newPollsModel) CreatePoll(r *plugnmeet.CreatePollReq, isAdmin bool, settings *PollSettings) (error, string) {
+	if settings != nil {
+		if err := validatePollSettings(settings, len(r.Options)); err != nil {
+			return err, ""
+		}
+	}
 	r.PollId = uuid.NewString()
 
 	// first add to room
@@ -39,16 +87,49 @@ func (m *newPollsModel) CreatePoll(r *plugnmeet.CreatePollReq, isAdmin bool) (er
 	}
 
 	// now create empty respondent hash
-	err = m.createRespondentHash(r)
+	err = m.createRespondentHash(r, settings)
 	if err != nil {
 		return err, ""
 	}
 
+	if settings != nil && settings.ClosesAt > 0 {
+		m.rc.ZAdd(m.ctx, pollDeadlinesKey, &redis.Z{
+			Score:  float64(settings.ClosesAt),
+			Member: r.RoomId + "|" + r.PollId,
+		})
+	}
+
 	_ = m.broadcastNotification(r.RoomId, r.UserId, r.PollId, plugnmeet.DataMsgBodyType_POLL_CREATED, isAdmin)
+	m.rs.IncrRoomFeatureUsage(r.RoomId, RoomFeaturePolls)
+	go m.sendPollWebhook(pollCreatedEvent, r.RoomId, r.PollId)
 
 	return nil, r.PollId
 }
 
+// validatePollSettings will set default type & reset values those aren't valid for the type
+func validatePollSettings(settings *PollSettings, totalOptions int) error {
+	if settings.Duration < 0 || settings.Duration > pollMaxDuration {
+		return errors.New(fmt.Sprintf("duration should be between 0 and %d seconds", pollMaxDuration))
+	}
+	switch settings.Type {
+	case "", PollTypeSingle:
+		settings.Type = PollTypeSingle
+		settings.MaxSelections = 0
+	case PollTypeMultiple:
+		if settings.MaxSelections < 0 || settings.MaxSelections > totalOptions {
+			return errors.New(fmt.Sprintf("max_selections should be between 0 and %d", totalOptions))
+		}
+	case PollTypeRanked, PollTypeText:
+		settings.MaxSelections = 0
+	default:
+		return errors.New("invalid poll type")
+	}
+	if settings.Type != PollTypeText {
+		settings.Moderated = false
+	}
+	return nil
+}
+
 // addPollToRoom will insert poll to room hash
 func (m *newPollsModel) addPollToRoom(r *plugnmeet.CreatePollReq) error {
 	p := &plugnmeet.PollInfo{
@@ -72,6 +153,7 @@ func (m *newPollsModel) addPollToRoom(r *plugnmeet.CreatePollReq) error {
 
 	pp := m.rc.Pipeline()
 	pp.HSet(m.ctx, pollsKey+r.RoomId, pollVal)
+	pp.Expire(m.ctx, pollsKey+r.RoomId, m.rs.RoomKeyTTL(r.RoomId))
 	_, err = pp.Exec(m.ctx)
 
 	return err
@@ -79,20 +161,47 @@ func (m *newPollsModel) addPollToRoom(r *plugnmeet.CreatePollReq) error {
 
 // createRespondentHash will create initial hash
 // format for all_respondents array value = userId:option_id
-func (m *newPollsModel) createRespondentHash(r *plugnmeet.CreatePollReq) error {
+func (m *newPollsModel) createRespondentHash(r *plugnmeet.CreatePollReq, settings *PollSettings) error {
 	key := fmt.Sprintf("%s%s:respondents:%s", pollsKey, r.RoomId, r.PollId)
 
 	v := make(map[string]interface{})
 	v["total_resp"] = 0
 	v["all_respondents"] = nil
+	if settings != nil && settings.Anonymous {
+		v[pollAnonymousField] = 1
+	}
+	if settings != nil && settings.Duration > 0 {
+		settings.ClosesAt = time.Now().Unix() + settings.Duration
+		v[pollClosesAtField] = settings.ClosesAt
+	}
+	isRanked := false
+	if settings != nil && settings.Type != "" && settings.Type != PollTypeSingle {
+		v[pollTypeField] = settings.Type
+		v[pollMaxSelectionsField] = settings.MaxSelections
+		isRanked = settings.Type == PollTypeRanked
+		if settings.Type == PollTypeText && settings.Moderated {
+			v[pollModeratedField] = 1
+		}
+	}
 
 	for _, o := range r.Options {
 		c := fmt.Sprintf("%d_count", o.Id)
 		v[c] = 0
+		if isRanked {
+			v[fmt.Sprintf("%d_points", o.Id)] = 0
+		}
 	}
 
+	ttl := m.rs.RoomKeyTTL(r.RoomId)
 	pp := m.rc.Pipeline()
 	pp.HSet(m.ctx, key, v)
+	pp.Expire(m.ctx, key, ttl)
+	// other keys of the poll will be created on response
+	m.rs.trackRoomDynamicKeys(pp, r.RoomId, ttl, key,
+		pollVotersKey(r.RoomId, r.PollId),
+		pollBallotsKey(r.RoomId, r.PollId),
+		pollTextsKey(r.RoomId, r.PollId),
+		pollTermsKey(r.RoomId, r.PollId))
 	_, err := pp.Exec(m.ctx)
 
 	return err
@@ -135,6 +244,17 @@ func (m *newPollsModel) GetPollResponsesByField(roomId, pollId, field string) (e
 }
 
 func (m *newPollsModel) UserSelectedOption(roomId, pollId, userId string) (uint64, error) {
+	if m.getPollType(roomId, pollId) != PollTypeSingle {
+		return m.userBallotOption(roomId, pollId, userId)
+	}
+	if m.IsAnonymousPoll(roomId, pollId) {
+		voted, err := m.rc.SIsMember(m.ctx, pollVotersKey(roomId, pollId), userId).Result()
+		if err != nil || !voted {
+			return 0, err
+		}
+		return AnonymousPollVotedOption, nil
+	}
+
 	err, allRespondents := m.GetPollResponsesByField(roomId, pollId, "all_respondents")
 	if err != nil {
 		return 0, err
@@ -170,7 +290,58 @@ type userResponseCommonFields struct {
 	AllRespondents string `redis:"all_respondents"`
 }
 
+func pollVotersKey(roomId, pollId string) string {
+	return fmt.Sprintf("%s%s:voters:%s", pollsKey, roomId, pollId)
+}
+
+// IsAnonymousPoll will return true if votes of the poll can't be attributed to users
+func (m *newPollsModel) IsAnonymousPoll(roomId, pollId string) bool {
+	err, v := m.GetPollResponsesByField(roomId, pollId, pollAnonymousField)
+	return err == nil && v == "1"
+}
+
+func (m *newPollsModel) GetPollSettings(roomId, pollId string) *PollSettings {
+	s := &PollSettings{
+		Anonymous: m.IsAnonymousPoll(roomId, pollId),
+		Type:      m.getPollType(roomId, pollId),
+	}
+	if err, v := m.GetPollResponsesByField(roomId, pollId, pollClosesAtField); err == nil {
+		s.ClosesAt, _ = strconv.ParseInt(v, 10, 64)
+	}
+	if err, v := m.GetPollResponsesByField(roomId, pollId, pollMaxSelectionsField); err == nil {
+		s.MaxSelections, _ = strconv.Atoi(v)
+	}
+	if err, v := m.GetPollResponsesByField(roomId, pollId, pollModeratedField); err == nil {
+		s.Moderated = v == "1"
+	}
+	return s
+}
+
+// checkPollOpen will prevent votes after the poll was closed or the time is over
+func (m *newPollsModel) checkPollOpen(roomId, pollId string) error {
+	info, err := m.getPollInfo(roomId, pollId)
+	if err != nil {
+		return err
+	}
+	if !info.IsRunning {
+		return errors.New("poll has been closed")
+	}
+	if s := m.GetPollSettings(roomId, pollId); s.ClosesAt > 0 && time.Now().Unix() >= s.ClosesAt {
+		return errors.New("poll has been closed")
+	}
+	return nil
+}
+
 func (m *newPollsModel) UserSubmitResponse(r *plugnmeet.SubmitPollResponseReq, isAdmin bool) error {
+	if err := m.checkPollOpen(r.RoomId, r.PollId); err != nil {
+		return err
+	}
+	if m.getPollType(r.RoomId, r.PollId) != PollTypeSingle {
+		return errors.New("this poll accepts ballots only")
+	}
+	if m.IsAnonymousPoll(r.RoomId, r.PollId) {
+		return m.submitAnonymousResponse(r, isAdmin)
+	}
 	key := fmt.Sprintf("%s%s:respondents:%s", pollsKey, r.RoomId, r.PollId)
 
 	err := m.rc.Watch(m.ctx, func(tx *redis.Tx) error {
@@ -226,6 +397,34 @@ func (m *newPollsModel) UserSubmitResponse(r *plugnmeet.SubmitPollResponseReq, i
 	return nil
 }
 
+// submitAnonymousResponse will store only aggregate counts,
+// voters will be kept without option to prevent voting twice
+func (m *newPollsModel) submitAnonymousResponse(r *plugnmeet.SubmitPollResponseReq, isAdmin bool) error {
+	key := fmt.Sprintf("%s%s:respondents:%s", pollsKey, r.RoomId, r.PollId)
+	vKey := pollVotersKey(r.RoomId, r.PollId)
+
+	added, err := m.rc.SAdd(m.ctx, vKey, r.UserId).Result()
+	if err != nil {
+		return err
+	}
+	if added == 0 {
+		return errors.New("user already voted")
+	}
+
+	pp := m.rc.Pipeline()
+	pp.Expire(m.ctx, vKey, m.rs.RoomKeyTTL(r.RoomId))
+	pp.HIncrBy(m.ctx, key, "total_resp", 1)
+	pp.HIncrBy(m.ctx, key, fmt.Sprintf("%d_count", r.SelectedOption), 1)
+	if _, err = pp.Exec(m.ctx); err != nil {
+		return err
+	}
+
+	// voter won't be sent, so that clients can't relate it with counts
+	_ = m.broadcastNotification(r.RoomId, "", r.PollId, plugnmeet.DataMsgBodyType_NEW_POLL_RESPONSE, isAdmin)
+
+	return nil
+}
+
 func (m *newPollsModel) broadcastNotification(roomId, userId, pollId string, mType plugnmeet.DataMsgBodyType, isAdmin bool) error {
 	payload := &plugnmeet.DataMessage{
 		Type:   plugnmeet.DataMsgType_SYSTEM,
@@ -288,8 +487,10 @@ func (m *newPollsModel) ClosePoll(r *plugnmeet.ClosePollReq, isAdmin bool) error
 	if err != nil {
 		return err
 	}
+	m.rc.ZRem(m.ctx, pollDeadlinesKey, r.RoomId+"|"+r.PollId)
 
 	_ = m.broadcastNotification(r.RoomId, r.UserId, r.PollId, plugnmeet.DataMsgBodyType_POLL_CLOSED, isAdmin)
+	go m.sendPollWebhook(pollClosedEvent, r.RoomId, r.PollId)
 
 	return nil
 }
@@ -303,7 +504,8 @@ func (m *newPollsModel) CleanUpPolls(roomId string) error {
 
 	for _, p := range polls {
 		key := fmt.Sprintf("%s%s:respondents:%s", pollsKey, roomId, p.Id)
-		pp.Del(m.ctx, key)
+		pp.Del(m.ctx, key, pollVotersKey(roomId, p.Id), pollBallotsKey(roomId, p.Id), pollTextsKey(roomId, p.Id), pollTermsKey(roomId, p.Id))
+		pp.ZRem(m.ctx, pollDeadlinesKey, roomId+"|"+p.Id)
 	}
 
 	roomKey := pollsKey + roomId
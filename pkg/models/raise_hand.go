@@ -0,0 +1,140 @@
+package models
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+const raisedHandsKey = "pnm:raised_hands:"
+
+type RaisedHand struct {
+	UserId   string `json:"user_id"`
+	RaisedAt int64  `json:"raised_at"` // in milliseconds
+}
+
+type RaiseHandReq struct {
+	UserId string `json:"user_id"`
+}
+
+type raiseHandModel struct {
+	rc  *redis.Client
+	ctx context.Context
+	rs  *RoomService
+}
+
+func NewRaiseHandModel() *raiseHandModel {
+	return &raiseHandModel{
+		rc:  config.AppCnf.RDS,
+		ctx: context.Background(),
+		rs:  NewRoomService(),
+	}
+}
+
+// RaiseHand will add user at the end of the queue.
+// If user already in the queue then the position won't change.
+func (m *raiseHandModel) RaiseHand(roomId, userId string) error {
+	err := m.updateMetadata(roomId, userId, true)
+	if err != nil {
+		return err
+	}
+
+	pp := m.rc.Pipeline()
+	pp.ZAddNX(m.ctx, raisedHandsKey+roomId, &redis.Z{
+		Score:  float64(time.Now().UnixMilli()),
+		Member: userId,
+	})
+	pp.Expire(m.ctx, raisedHandsKey+roomId, m.rs.RoomKeyTTL(roomId))
+	_, err = pp.Exec(m.ctx)
+	if err != nil {
+		return err
+	}
+
+	m.broadcastQueue(roomId)
+	return nil
+}
+
+func (m *raiseHandModel) LowerHand(roomId, userId string) error {
+	_, err := m.rc.ZRem(m.ctx, raisedHandsKey+roomId, userId).Result()
+	if err != nil {
+		return err
+	}
+
+	err = m.updateMetadata(roomId, userId, false)
+	if err != nil {
+		// user may have left already
+		log.Infoln(err)
+	}
+
+	m.broadcastQueue(roomId)
+	return nil
+}
+
+// ClearHands will lower hands of everyone
+func (m *raiseHandModel) ClearHands(roomId string) error {
+	hands, err := m.GetQueue(roomId)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.rc.Del(m.ctx, raisedHandsKey+roomId).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, h := range hands {
+		err = m.updateMetadata(roomId, h.UserId, false)
+		if err != nil {
+			// user may have left already
+			log.Infoln(err)
+		}
+	}
+
+	m.broadcastQueue(roomId)
+	return nil
+}
+
+// GetQueue will return raised hands in order
+func (m *raiseHandModel) GetQueue(roomId string) ([]*RaisedHand, error) {
+	result, err := m.rc.ZRangeWithScores(m.ctx, raisedHandsKey+roomId, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	hands := make([]*RaisedHand, 0, len(result))
+	for _, z := range result {
+		hands = append(hands, &RaisedHand{
+			UserId:   z.Member.(string),
+			RaisedAt: int64(z.Score),
+		})
+	}
+
+	return hands, nil
+}
+
+func (m *raiseHandModel) DeleteQueue(roomId string) (int64, error) {
+	return m.rc.Del(m.ctx, raisedHandsKey+roomId).Result()
+}
+
+func (m *raiseHandModel) updateMetadata(roomId, userId string, raised bool) error {
+	_, meta, err := m.rs.LoadParticipantWithMetadata(roomId, userId)
+	if err != nil {
+		return err
+	}
+
+	meta.RaisedHand = raised
+	_, err = m.rs.UpdateParticipantMetadataByStruct(roomId, userId, meta)
+
+	return err
+}
+
+func (m *raiseHandModel) broadcastQueue(roomId string) {
+	hands, err := m.GetQueue(roomId)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	broadcastSystemMsg(roomId, DataMsgBodyType_RAISE_HAND_QUEUE_UPDATED, hands)
+}
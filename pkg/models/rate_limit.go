@@ -0,0 +1,106 @@
+package models
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"time"
+)
+
+const (
+	rateLimitKey           = "pnm:rate_limit:"
+	defaultRateLimitWindow = time.Minute
+)
+
+// slidingWindowScript will remove entries older than window and add the request to all the keys
+// if limit of none of them was reached. Otherwise, nothing will be added & it will return ms to wait.
+// ARGV: now, window, member, then limit of every key.
+var slidingWindowScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local wait = 0
+
+for i, key in ipairs(KEYS) do
+	redis.call("ZREMRANGEBYSCORE", key, "-inf", now - window)
+	if redis.call("ZCARD", key) >= tonumber(ARGV[3 + i]) then
+		local oldest = redis.call("ZRANGE", key, 0, 0, "WITHSCORES")
+		wait = math.max(wait, tonumber(oldest[2]) + window - now)
+	end
+end
+if wait > 0 then
+	return wait
+end
+
+for _, key in ipairs(KEYS) do
+	redis.call("ZADD", key, now, ARGV[3])
+	redis.call("PEXPIRE", key, window)
+end
+return 0
+`)
+
+type rateLimitModel struct {
+	conf config.RateLimitConf
+	rc   *redis.Client
+	ctx  context.Context
+}
+
+func NewRateLimitModel() *rateLimitModel {
+	return &rateLimitModel{
+		conf: config.AppCnf.Client.RateLimit,
+		rc:   config.AppCnf.RDS,
+		ctx:  context.Background(),
+	}
+}
+
+// Allow will check both per ip & per room limit of the endpoint, empty ip or roomId will be skipped.
+// The request will be counted only if both limits allow it.
+// If not allowed, retryAfter will be returned.
+// In case of redis error request will be allowed, so that users can join.
+func (m *rateLimitModel) Allow(endpoint, ip, roomId string) (bool, time.Duration) {
+	if !m.conf.Enable {
+		return true, 0
+	}
+
+	keys, limits := m.keys(endpoint, ip, roomId)
+	if len(keys) == 0 {
+		return true, 0
+	}
+
+	return m.allow(keys, limits)
+}
+
+// keys will return the keys & limits those need to be checked
+func (m *rateLimitModel) keys(endpoint, ip, roomId string) (keys []string, limits []interface{}) {
+	if m.conf.PerIp > 0 && ip != "" {
+		keys = append(keys, rateLimitKey+endpoint+":ip:"+ip)
+		limits = append(limits, m.conf.PerIp)
+	}
+	if m.conf.PerRoom > 0 && roomId != "" {
+		keys = append(keys, rateLimitKey+endpoint+":room:"+roomId)
+		limits = append(limits, m.conf.PerRoom)
+	}
+	return keys, limits
+}
+
+func (m *rateLimitModel) allow(keys []string, limits []interface{}) (bool, time.Duration) {
+	window := m.conf.Window
+	if window == 0 {
+		window = defaultRateLimitWindow
+	}
+
+	now := time.Now()
+	member := strconv.FormatInt(now.UnixNano(), 10) + "-" + randomHex(4)
+	args := append([]interface{}{now.UnixMilli(), window.Milliseconds(), member}, limits...)
+	wait, err := slidingWindowScript.Run(m.ctx, m.rc, keys, args...).Int64()
+	if err != nil {
+		log.Errorln(err)
+		return true, 0
+	}
+	if wait > 0 {
+		return false, time.Duration(wait) * time.Millisecond
+	}
+
+	return true, 0
+}
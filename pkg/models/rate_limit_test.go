@@ -0,0 +1,84 @@
+package models
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimitModel_Keys(t *testing.T) {
+	conf := config.RateLimitConf{Enable: true, PerIp: 10, PerRoom: 30}
+
+	tests := []struct {
+		conf       config.RateLimitConf
+		ip         string
+		roomId     string
+		wantKeys   []string
+		wantLimits []interface{}
+	}{
+		{conf, "10.0.0.1", "room01", []string{rateLimitKey + "verifyToken:ip:10.0.0.1", rateLimitKey + "verifyToken:room:room01"}, []interface{}{10, 30}},
+		{conf, "", "room01", []string{rateLimitKey + "verifyToken:room:room01"}, []interface{}{30}},
+		{conf, "10.0.0.1", "", []string{rateLimitKey + "verifyToken:ip:10.0.0.1"}, []interface{}{10}},
+		{conf, "", "", nil, nil},
+		{config.RateLimitConf{Enable: true, PerRoom: 30}, "10.0.0.1", "room01", []string{rateLimitKey + "verifyToken:room:room01"}, []interface{}{30}},
+	}
+
+	for _, tt := range tests {
+		m := &rateLimitModel{conf: tt.conf}
+		keys, limits := m.keys("verifyToken", tt.ip, tt.roomId)
+		if !reflect.DeepEqual(keys, tt.wantKeys) || !reflect.DeepEqual(limits, tt.wantLimits) {
+			t.Errorf("keys(%s, %s) = %v %v, want %v %v", tt.ip, tt.roomId, keys, limits, tt.wantKeys, tt.wantLimits)
+		}
+	}
+}
+
+// TestSlidingWindowScript requires redis, e.g. PNM_TEST_REDIS_ADDR=127.0.0.1:6379
+func TestSlidingWindowScript(t *testing.T) {
+	addr := os.Getenv("PNM_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("PNM_TEST_REDIS_ADDR is not set")
+	}
+	ctx := context.Background()
+	rc := redis.NewClient(&redis.Options{Addr: addr})
+	defer rc.Close()
+
+	prefix := rateLimitKey + "test:" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	ipKey, roomKey := prefix+":ip", prefix+":room"
+	defer rc.Del(ctx, ipKey, roomKey)
+
+	const window = 1000
+	// ip allows 2 & room allows 3 requests within the window
+	tests := []struct {
+		keys     []string
+		limits   []interface{}
+		now      int64
+		wantWait int64
+	}{
+		{[]string{ipKey, roomKey}, []interface{}{2, 3}, 0, 0},
+		{[]string{ipKey, roomKey}, []interface{}{2, 3}, 100, 0},
+		// ip is full, so nothing will be recorded for the room
+		{[]string{ipKey, roomKey}, []interface{}{2, 3}, 200, 800},
+		{[]string{roomKey}, []interface{}{3}, 300, 0},
+		{[]string{roomKey}, []interface{}{3}, 400, 600},
+		{[]string{ipKey, roomKey}, []interface{}{2, 3}, 450, 550},
+		// first request is out of the window now
+		{[]string{ipKey, roomKey}, []interface{}{2, 3}, 1001, 0},
+		{[]string{ipKey, roomKey}, []interface{}{2, 3}, 1002, 98},
+	}
+
+	for i, tt := range tests {
+		args := append([]interface{}{tt.now, window, "m" + strconv.Itoa(i)}, tt.limits...)
+		wait, err := slidingWindowScript.Run(ctx, rc, tt.keys, args...).Int64()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait != tt.wantWait {
+			t.Errorf("step %d: wait = %d, want %d", i, wait, tt.wantWait)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// reactionsKey keeps reactions of the sliding window as sorted set, score is time in milliseconds
+	reactionsKey = "pnm:reactions:"
+	// reactionsBroadcastLock will let counts be broadcast once in reactionsBroadcastDelay
+	reactionsBroadcastLock  = "pnm:reactions_broadcast:"
+	reactionsBroadcastDelay = time.Second
+	defaultReactionsWindow  = 10 * time.Second
+)
+
+var defaultAllowedReactions = []string{"👍", "👎", "👏", "❤️", "😂", "😮", "🎉", "🙌"}
+
+type ReactionCounts struct {
+	// Window in seconds
+	Window    int64            `json:"window"`
+	Counts    map[string]int64 `json:"counts"`
+	UpdatedAt int64            `json:"updated_at"`
+}
+
+func reactionsWindow() time.Duration {
+	if w := config.AppCnf.Client.Reactions.Window; w > 0 {
+		return w
+	}
+	return defaultReactionsWindow
+}
+
+func isAllowedReaction(emoji string) bool {
+	allowed := config.AppCnf.Client.Reactions.Allowed
+	if len(allowed) == 0 {
+		allowed = defaultAllowedReactions
+	}
+	for _, a := range allowed {
+		if a == emoji {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleReaction will validate & count the reaction received from the websocket.
+// Returns false if the message should be dropped. Other messages will be ignored.
+func HandleReaction(roomId, userId string, msg *plugnmeet.DataMessage) bool {
+	if msg.Type != plugnmeet.DataMsgType_USER || msg.Body == nil || msg.Body.Type != DataMsgBodyType_REACTION {
+		return true
+	}
+	if msg.Body.From == nil || msg.Body.From.UserId != userId || !isAllowedReaction(msg.Body.Msg) {
+		return false
+	}
+	// reactions are always for everyone
+	msg.To = nil
+
+	rs := NewRoomService()
+	now := time.Now()
+	key := reactionsKey + roomId
+	pp := rs.rc.Pipeline()
+	pp.ZAdd(rs.ctx, key, &redis.Z{
+		Score:  float64(now.UnixMilli()),
+		Member: msg.Body.Msg + "|" + uuid.NewString(),
+	})
+	pp.Expire(rs.ctx, key, reactionsWindow())
+	pp.HIncrBy(rs.ctx, roomStatsKey+roomId, roomStatReactionPrefix+msg.Body.Msg, 1)
+	if _, err := pp.Exec(rs.ctx); err != nil {
+		log.Errorln(err)
+	}
+	rs.IncrRoomFeatureUsage(roomId, RoomFeatureReactions)
+
+	// counts will be sent after the delay, so that reactions in between will be included
+	locked, err := rs.rc.SetNX(rs.ctx, reactionsBroadcastLock+roomId, now.Unix(), reactionsBroadcastDelay).Result()
+	if err == nil && locked {
+		time.AfterFunc(reactionsBroadcastDelay, func() {
+			broadcastSystemMsg(roomId, DataMsgBodyType_REACTION_COUNTS, rs.GetReactionCounts(roomId))
+		})
+	}
+
+	return true
+}
+
+// GetReactionCounts will return counts of each emoji in the sliding window
+func (r *RoomService) GetReactionCounts(roomId string) *ReactionCounts {
+	window := reactionsWindow()
+	now := time.Now()
+	counts := &ReactionCounts{
+		Window:    int64(window.Seconds()),
+		Counts:    make(map[string]int64),
+		UpdatedAt: now.Unix(),
+	}
+
+	key := reactionsKey + roomId
+	min := strconv.FormatInt(now.Add(-window).UnixMilli(), 10)
+	r.rc.ZRemRangeByScore(r.ctx, key, "-inf", "("+min)
+	members, err := r.rc.ZRange(r.ctx, key, 0, -1).Result()
+	if err != nil {
+		log.Errorln(err)
+		return counts
+	}
+	for _, m := range members {
+		if i := strings.LastIndex(m, "|"); i > 0 {
+			counts.Counts[m[:i]]++
+		}
+	}
+
+	return counts
+}
@@ -0,0 +1,371 @@
+package models
+
+import (
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	recordersKey = "pnm:recorders"
+	// recorderJobsKey keeps dispatched jobs of the recorder until those end
+	recorderJobsKey      = "pnm:recorder_jobs:"
+	recorderFailoverLock = "pnm:recorder_failover_lock"
+
+	RecorderTypeRecorder = "recorder"
+	RecorderTypeRtmp     = "rtmp"
+
+	// we can think maximum 8 seconds delay for valid node
+	recorderPingValidity = 8 * time.Second
+	// jobs of the node will be moved to another node after this
+	recorderDeadAfter    = 30 * time.Second
+	maxRecorderFailovers = 3
+)
+
+type recorderInfo struct {
+	RecorderId      string `json:"-"`
+	MaxLimit        int    `json:"maxLimit"`
+	CurrentProgress int    `json:"currentProgress"`
+	LastPing        int64  `json:"lastPing"`
+	// Type: recorder, rtmp or empty if the node can do both
+	Type string `json:"type,omitempty"`
+	// Jobs dispatched by us those haven't ended yet
+	Jobs int `json:"-"`
+}
+
+func (r *recorderInfo) isHealthy() bool {
+	return r.LastPing >= time.Now().Add(-recorderPingValidity).Unix()
+}
+
+// activeJobs will take the bigger value, as the node may not
+// report a job which was dispatched just now
+func (r *recorderInfo) activeJobs() int {
+	if r.Jobs > r.CurrentProgress {
+		return r.Jobs
+	}
+	return r.CurrentProgress
+}
+
+func (r *recorderInfo) load() float64 {
+	if r.MaxLimit <= 0 {
+		return 1
+	}
+	return float64(r.activeJobs()) / float64(r.MaxLimit)
+}
+
+func (r *recorderInfo) canHandle(userId string) bool {
+	switch r.Type {
+	case RecorderTypeRecorder:
+		return userId == config.RECORDER_BOT
+	case RecorderTypeRtmp:
+		return userId == config.RTMP_BOT
+	}
+	return true
+}
+
+type recorderJob struct {
+	Task         plugnmeet.RecordingTasks `json:"task"`
+	RoomId       string                   `json:"room_id"`
+	RoomSid      string                   `json:"room_sid"`
+	RtmpUrl      string                   `json:"rtmp_url,omitempty"`
+	CustomDesign string                   `json:"custom_design,omitempty"`
+	Output       *RecordingOutputOptions  `json:"output,omitempty"`
+	Encoding     *BroadcastEncoding       `json:"encoding,omitempty"`
+	DispatchedAt int64                    `json:"dispatched_at"`
+	Failovers    int                      `json:"failovers"`
+}
+
+// recorderJobField will return field of the job in recorder jobs hash
+func recorderJobField(task plugnmeet.RecordingTasks, roomSid string) string {
+	switch task {
+	case plugnmeet.RecordingTasks_START_RTMP, plugnmeet.RecordingTasks_END_RTMP:
+		return "rtmp:" + roomSid
+	}
+	return "recording:" + roomSid
+}
+
+func (rm *recordingModel) getAllRecorders() ([]*recorderInfo, error) {
+	result, err := rm.rds.HGetAll(rm.ctx, recordersKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var recorders []*recorderInfo
+	for id, data := range result {
+		recorder := new(recorderInfo)
+		err = json.Unmarshal([]byte(data), recorder)
+		if err != nil {
+			continue
+		}
+		recorder.RecorderId = id
+		recorders = append(recorders, recorder)
+	}
+	if len(recorders) == 0 {
+		return recorders, nil
+	}
+
+	pp := rm.rds.Pipeline()
+	counts := make([]*redis.IntCmd, len(recorders))
+	for i, r := range recorders {
+		counts[i] = pp.HLen(rm.ctx, recorderJobsKey+r.RecorderId)
+	}
+	if _, err = pp.Exec(rm.ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+	for i, r := range recorders {
+		r.Jobs = int(counts[i].Val())
+	}
+
+	return recorders, nil
+}
+
+// selectRecorder will return the least loaded healthy node which can handle the task
+func (rm *recordingModel) selectRecorder(userId string) (string, error) {
+	recorders, err := rm.getAllRecorders()
+	if err != nil {
+		return "", err
+	}
+
+	var available []*recorderInfo
+	for _, r := range recorders {
+		if r.isHealthy() && r.canHandle(userId) && r.activeJobs() < r.MaxLimit {
+			available = append(available, r)
+		}
+	}
+	if len(available) < 1 {
+		return "", nil
+	}
+
+	// let's sort it based on active jobs & max limit.
+	sort.Slice(available, func(i int, j int) bool {
+		return available[i].load() < available[j].load()
+	})
+
+	// we'll return the first one
+	return available[0].RecorderId, nil
+}
+
+// addRecorderJob will keep the job, so that it can be moved to another node if the recorder dies
+func (rm *recordingModel) addRecorderJob(rq *plugnmeet.PlugNmeetToRecorder) {
+	if rq.RecorderId == "" {
+		return
+	}
+	if rq.Task != plugnmeet.RecordingTasks_START_RECORDING && rq.Task != plugnmeet.RecordingTasks_START_RTMP {
+		return
+	}
+
+	job := &recorderJob{
+		Task:         rq.Task,
+		RoomId:       rq.RoomId,
+		RoomSid:      rq.RoomSid,
+		RtmpUrl:      rq.GetRtmpUrl(),
+		DispatchedAt: time.Now().Unix(),
+		Failovers:    rm.failovers,
+		Output:       rm.Output,
+		Encoding:     rm.Encoding,
+	}
+	if rm.RecordingReq != nil {
+		job.CustomDesign = rm.RecordingReq.GetCustomDesign()
+	}
+	marshal, err := json.Marshal(job)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	err = rm.rds.HSet(rm.ctx, recorderJobsKey+rq.RecorderId, recorderJobField(rq.Task, rq.RoomSid), marshal).Err()
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+// updateRecorderJob will remove the job when the recorder has ended it or failed to start it
+func (rm *recordingModel) updateRecorderJob(r *plugnmeet.RecorderToPlugNmeet) {
+	if r.RecorderId == "" || r.RecorderId == trackRecorderId {
+		return
+	}
+
+	remove := false
+	switch r.Task {
+	case plugnmeet.RecordingTasks_START_RECORDING, plugnmeet.RecordingTasks_START_RTMP:
+		remove = !r.Status
+	case plugnmeet.RecordingTasks_END_RECORDING, plugnmeet.RecordingTasks_END_RTMP:
+		remove = true
+	}
+	if !remove {
+		return
+	}
+
+	err := rm.rds.HDel(rm.ctx, recorderJobsKey+r.RecorderId, recorderJobField(r.Task, r.RoomSid)).Err()
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+type RecorderHeartbeatReq struct {
+	RecorderId      string `json:"recorder_id" validate:"required"`
+	MaxLimit        int    `json:"max_limit" validate:"required,min=1"`
+	CurrentProgress int    `json:"current_progress" validate:"min=0"`
+	Type            string `json:"type" validate:"omitempty,oneof=recorder rtmp"`
+}
+
+// RecorderHeartbeat will register or update the node.
+// Nodes can update pnm:recorders directly too.
+func (rm *recordingModel) RecorderHeartbeat(r *RecorderHeartbeatReq) error {
+	marshal, err := json.Marshal(&recorderInfo{
+		MaxLimit:        r.MaxLimit,
+		CurrentProgress: r.CurrentProgress,
+		LastPing:        time.Now().Unix(),
+		Type:            r.Type,
+	})
+	if err != nil {
+		return err
+	}
+	return rm.rds.HSet(rm.ctx, recordersKey, r.RecorderId, marshal).Err()
+}
+
+type RecorderNode struct {
+	RecorderId      string `json:"recorder_id"`
+	Type            string `json:"type"`
+	MaxLimit        int    `json:"max_limit"`
+	CurrentProgress int    `json:"current_progress"`
+	Jobs            int    `json:"jobs"`
+	LastPing        int64  `json:"last_ping"`
+	Healthy         bool   `json:"healthy"`
+}
+
+// GetRecorderNodes will return all registered nodes with health & load
+func (rm *recordingModel) GetRecorderNodes() ([]*RecorderNode, error) {
+	recorders, err := rm.getAllRecorders()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]*RecorderNode, 0, len(recorders))
+	for _, r := range recorders {
+		nodes = append(nodes, &RecorderNode{
+			RecorderId:      r.RecorderId,
+			Type:            r.Type,
+			MaxLimit:        r.MaxLimit,
+			CurrentProgress: r.CurrentProgress,
+			Jobs:            r.Jobs,
+			LastPing:        r.LastPing,
+			Healthy:         r.isHealthy(),
+		})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].RecorderId < nodes[j].RecorderId
+	})
+
+	return nodes, nil
+}
+
+// CheckRecorderNodes will move jobs of dead nodes to healthy nodes.
+// Only one server will perform it at a time.
+func (s *scheduler) CheckRecorderNodes() {
+	locked, err := s.rc.SetNX(s.ctx, recorderFailoverLock, time.Now().Unix(), time.Minute).Result()
+	if err != nil || !locked {
+		return
+	}
+	defer s.rc.Del(s.ctx, recorderFailoverLock)
+
+	rm := NewRecordingModel()
+	recorders, err := rm.getAllRecorders()
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	lastPings := make(map[string]int64)
+	for _, r := range recorders {
+		lastPings[r.RecorderId] = r.LastPing
+	}
+
+	dead := time.Now().Add(-recorderDeadAfter).Unix()
+	iter := s.rc.Scan(s.ctx, 0, recorderJobsKey+"*", 100).Iterator()
+	for iter.Next(s.ctx) {
+		recorderId := strings.TrimPrefix(iter.Val(), recorderJobsKey)
+		if lastPings[recorderId] >= dead {
+			continue
+		}
+		log.Warnln("recorder " + recorderId + " isn't responding, moving its jobs to other nodes")
+		s.failoverRecorderJobs(recorderId)
+		s.rc.HDel(s.ctx, recordersKey, recorderId)
+	}
+	if err = iter.Err(); err != nil {
+		log.Errorln(err)
+	}
+}
+
+func (s *scheduler) failoverRecorderJobs(recorderId string) {
+	jobs, err := s.rc.HGetAll(s.ctx, recorderJobsKey+recorderId).Result()
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	s.rc.Del(s.ctx, recorderJobsKey+recorderId)
+
+	for _, data := range jobs {
+		job := new(recorderJob)
+		if err = json.Unmarshal([]byte(data), job); err != nil {
+			continue
+		}
+		if err = s.failoverRecorderJob(recorderId, job); err != nil {
+			log.Errorln("can't move job of room " + job.RoomId + ": " + err.Error())
+		}
+	}
+}
+
+func (s *scheduler) failoverRecorderJob(recorderId string, job *recorderJob) error {
+	rm := NewRecordingModel()
+	endTask := plugnmeet.RecordingTasks_END_RECORDING
+	if job.Task == plugnmeet.RecordingTasks_START_RTMP {
+		endTask = plugnmeet.RecordingTasks_END_RTMP
+	}
+	// room status will be updated & end webhook will be sent as the job has ended with error
+	endJob := func(msg string) {
+		rm.HandleRecorderResp(&plugnmeet.RecorderToPlugNmeet{
+			From:       "plugnmeet",
+			Status:     false,
+			Task:       endTask,
+			Msg:        msg,
+			RoomId:     job.RoomId,
+			RoomSid:    job.RoomSid,
+			RecorderId: recorderId,
+		})
+	}
+
+	room, _ := NewRoomModel().GetRoomInfo("", job.RoomSid, 1)
+	if room == nil || room.Id == 0 {
+		// room has ended, nothing to do
+		return nil
+	}
+	if job.Failovers >= maxRecorderFailovers {
+		endJob("recorder has stopped responding")
+		return errors.New("max failovers reached")
+	}
+
+	rm.failovers = job.Failovers + 1
+	rm.Output = job.Output
+	rm.Encoding = job.Encoding
+	rm.RecordingReq = &plugnmeet.RecordingReq{
+		Task:         job.Task,
+		Sid:          job.RoomSid,
+		CustomDesign: &job.CustomDesign,
+	}
+	var rtmpUrl *string
+	if job.RtmpUrl != "" {
+		rtmpUrl = &job.RtmpUrl
+	}
+
+	if err := rm.SendMsgToRecorder(job.Task, job.RoomId, job.RoomSid, rtmpUrl); err != nil {
+		endJob("recorder has stopped responding")
+		return err
+	}
+	return nil
+}
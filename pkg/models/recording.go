@@ -12,7 +12,6 @@ import (
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/protobuf/encoding/protojson"
 	"net/url"
-	"sort"
 	"strconv"
 	"time"
 )
@@ -24,6 +23,9 @@ type recordingModel struct {
 	rds          *redis.Client
 	ctx          context.Context
 	RecordingReq *plugnmeet.RecordingReq // we need to get custom design value
+	Output       *RecordingOutputOptions
+	Encoding     *BroadcastEncoding
+	failovers    int // how many times the job was moved to another recorder
 }
 
 func NewRecordingModel() *recordingModel {
@@ -52,6 +54,13 @@ type RecorderResp struct {
 }
 
 func (rm *recordingModel) HandleRecorderResp(r *plugnmeet.RecorderToPlugNmeet) {
+	// it should be checked before removing the job
+	reconnecting := rm.handleBroadcastReconnectResp(r)
+	rm.updateRecorderJob(r)
+	if reconnecting {
+		return
+	}
+
 	switch r.Task {
 	case plugnmeet.RecordingTasks_START_RECORDING:
 		rm.recordingStarted(r)
@@ -61,6 +70,14 @@ func (rm *recordingModel) HandleRecorderResp(r *plugnmeet.RecorderToPlugNmeet) {
 		rm.recordingEnded(r)
 		go rm.sendToWebhookNotifier(r)
 
+	case RecordingTasksPauseRecording:
+		rm.recordingPaused(r)
+		go rm.sendToWebhookNotifier(r)
+
+	case RecordingTasksResumeRecording:
+		rm.recordingResumed(r)
+		go rm.sendToWebhookNotifier(r)
+
 	case plugnmeet.RecordingTasks_START_RTMP:
 		rm.rtmpStarted(r)
 		go rm.sendToWebhookNotifier(r)
@@ -70,11 +87,17 @@ func (rm *recordingModel) HandleRecorderResp(r *plugnmeet.RecorderToPlugNmeet) {
 		go rm.sendToWebhookNotifier(r)
 
 	case plugnmeet.RecordingTasks_RECORDING_PROCEEDED:
+		added := true
 		err := rm.addRecording(r)
 		if err != nil {
 			log.Errorln(err)
+			added = false
 		}
-		go rm.sendToWebhookNotifier(r)
+		// integrators will be notified before post-processing,
+		// because uploading a large file can take long time
+		info := rm.inspectRecordingFile(r)
+		rm.sendRecordingProceededWebhook(r, info)
+		rm.enqueueRecordingPostProcess(r, added, info.Duration)
 	}
 }
 
@@ -93,6 +116,14 @@ func (rm *recordingModel) recordingStarted(r *plugnmeet.RecorderToPlugNmeet) {
 
 	roomMeta.IsRecording = true
 	_, _ = rm.roomService.UpdateRoomMetadataByStruct(r.RoomId, roomMeta)
+	rm.roomService.IncrRoomFeatureUsage(r.RoomId, RoomFeatureRecording)
+	rm.saveRecordingRetention(r.RoomId, r.RoomSid)
+	rm.saveRecordingTags(r.RoomId, r.RoomSid)
+	rm.saveRecordingApiKey(r.RoomId, r.RoomSid)
+	rm.startRecordingSegments(r.RoomSid)
+	rm.resetRecordingMarkers(r.RoomSid)
+	rm.resetRecordingChat(r.RoomSid)
+	go rm.startTrackRecording(r)
 
 	// send message to room
 	dm := NewDataMessageModel()
@@ -113,6 +144,8 @@ func (rm *recordingModel) recordingEnded(r *plugnmeet.RecorderToPlugNmeet) {
 	if err != nil {
 		log.Infoln(err)
 	}
+	rm.endRecordingSegments(r.RoomSid)
+	go rm.stopTrackRecording(r.RoomSid)
 
 	// update room metadata
 	_, roomMeta, err := rm.roomService.LoadRoomWithMetadata(r.RoomId)
@@ -191,6 +224,7 @@ func (rm *recordingModel) rtmpStarted(r *plugnmeet.RecorderToPlugNmeet) {
 
 	roomMeta.IsActiveRtmp = true
 	_, _ = rm.roomService.UpdateRoomMetadataByStruct(r.RoomId, roomMeta)
+	rm.roomService.IncrRoomFeatureUsage(r.RoomId, RoomFeatureRtmp)
 
 	// send message to room
 	dm := NewDataMessageModel()
@@ -211,6 +245,8 @@ func (rm *recordingModel) rtmpEnded(r *plugnmeet.RecorderToPlugNmeet) {
 	if err != nil {
 		log.Infoln(err)
 	}
+	rm.deleteBroadcastHealth(r.RoomSid)
+	rm.clearBroadcastSlate(r.RoomId, r.RoomSid)
 
 	// update room metadata
 	_, roomMeta, err := rm.roomService.LoadRoomWithMetadata(r.RoomId)
@@ -307,6 +343,14 @@ func (rm *recordingModel) addRecording(r *plugnmeet.RecorderToPlugNmeet) error {
 	ri := NewRoomModel()
 	roomInfo, _ := ri.GetRoomInfo("", r.RoomSid, 0)
 
+	var duration int64
+	pausedSegments := []byte("[]")
+	if s, err := rm.LoadRecordingSegments(r.RoomSid); err == nil {
+		duration = s.RecordedDuration()
+		pausedSegments, _ = json.Marshal(s.PausedSegments)
+	}
+	markers, _ := json.Marshal(rm.loadRecordingMarkers(r.RoomSid))
+
 	db := rm.db
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -317,12 +361,12 @@ func (rm *recordingModel) addRecording(r *plugnmeet.RecorderToPlugNmeet) error {
 	}
 	defer tx.Rollback()
 	stmt, err := tx.Prepare("INSERT INTO " + rm.app.FormatDBTable("recordings") +
-		" (record_id, room_id, room_sid, recorder_id, file_path, size, creation_time, room_creation_time) VALUES (?, ?, ?, ?, ?, ?, ?, ?)")
+		" (record_id, room_id, room_sid, recorder_id, file_path, size, creation_time, room_creation_time, expires_at, duration, paused_segments, markers, tags, api_key) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		return err
 	}
 
-	_, err = stmt.Exec(r.RecordingId, r.RoomId, roomInfo.Sid, r.RecorderId, r.FilePath, fmt.Sprintf("%.2f", r.FileSize), time.Now().Unix(), roomInfo.CreationTime)
+	_, err = stmt.Exec(r.RecordingId, r.RoomId, roomInfo.Sid, r.RecorderId, r.FilePath, fmt.Sprintf("%.2f", r.FileSize), time.Now().Unix(), roomInfo.CreationTime, rm.recordingExpiresAt(r.RoomSid), duration, string(pausedSegments), string(markers), rm.recordingTags(r.RoomSid), rm.recordingApiKey(r.RoomSid))
 	if err != nil {
 		return err
 	}
@@ -334,14 +378,22 @@ func (rm *recordingModel) addRecording(r *plugnmeet.RecorderToPlugNmeet) error {
 	if err != nil {
 		return err
 	}
+	rm.saveRecordingConsents(r.RecordingId, r.RoomSid)
 
 	return nil
 }
 
 func (rm *recordingModel) sendToWebhookNotifier(r *plugnmeet.RecorderToPlugNmeet) {
-	tk := r.Task.String()
 	n := NewWebhookNotifier()
-	msg := &plugnmeet.CommonNotifyEvent{
+	err := n.Notify(r.RoomSid, rm.recordingNotifyEvent(r))
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+func (rm *recordingModel) recordingNotifyEvent(r *plugnmeet.RecorderToPlugNmeet) *plugnmeet.CommonNotifyEvent {
+	tk := recordingTaskName(r.Task)
+	return &plugnmeet.CommonNotifyEvent{
 		Event: &tk,
 		Room: &plugnmeet.NotifyEventRoom{
 			Sid:    &r.RoomSid,
@@ -355,10 +407,6 @@ func (rm *recordingModel) sendToWebhookNotifier(r *plugnmeet.RecorderToPlugNmeet
 			FileSize:    &r.FileSize,
 		},
 	}
-	err := n.Notify(r.RoomSid, msg)
-	if err != nil {
-		log.Errorln(err)
-	}
 }
 
 type RecorderReq struct {
@@ -398,13 +446,22 @@ func (rm *recordingModel) SendMsgToRecorder(task plugnmeet.RecordingTasks, roomI
 	}
 
 	payload, _ := protojson.Marshal(toSend)
+	switch task {
+	case plugnmeet.RecordingTasks_START_RECORDING, plugnmeet.RecordingTasks_START_RTMP:
+		payload = withRecordingOutput(payload, rm.Output)
+		payload = withBroadcastBranding(payload, rm.roomService.LoadRoomOptions(roomId).BroadcastBranding)
+	}
+	if task == plugnmeet.RecordingTasks_START_RTMP {
+		payload = withBroadcastEncoding(payload, rm.Encoding)
+	}
 	rm.rds.Publish(rm.ctx, "plug-n-meet-recorder", string(payload))
+	rm.addRecorderJob(toSend)
 
 	return nil
 }
 
 func (rm *recordingModel) addTokenAndRecorder(rq *plugnmeet.PlugNmeetToRecorder, userId string) error {
-	recorderId, err := rm.selectRecorder()
+	recorderId, err := rm.selectRecorder(userId)
 	if err != nil {
 		return err
 	}
@@ -438,55 +495,3 @@ func (rm *recordingModel) addTokenAndRecorder(rq *plugnmeet.PlugNmeetToRecorder,
 
 	return nil
 }
-
-type recorderInfo struct {
-	RecorderId      string
-	MaxLimit        int   `json:"maxLimit"`
-	CurrentProgress int   `json:"currentProgress"`
-	LastPing        int64 `json:"lastPing"`
-}
-
-func (rm *recordingModel) getAllRecorders() ([]*recorderInfo, error) {
-	ctx := context.Background()
-	res := rm.rds.HGetAll(ctx, "pnm:recorders")
-	result, err := res.Result()
-	if err != nil {
-		return nil, err
-	}
-
-	var recorders []*recorderInfo
-	valid := time.Now().Unix() - 8 // we can think maximum 8 seconds delay for valid node
-
-	for id, data := range result {
-		recorder := new(recorderInfo)
-		err = json.Unmarshal([]byte(data), recorder)
-		if err != nil {
-			continue
-		}
-		if recorder.LastPing >= valid {
-			recorder.RecorderId = id
-			recorders = append(recorders, recorder)
-		}
-	}
-
-	return recorders, err
-}
-
-func (rm *recordingModel) selectRecorder() (string, error) {
-	recorders, err := rm.getAllRecorders()
-	if err != nil {
-		return "", err
-	}
-	if len(recorders) < 1 {
-		return "", nil
-	}
-	// let's sort it based on active processes & max limit.
-	sort.Slice(recorders, func(i int, j int) bool {
-		iA := (recorders[i].CurrentProgress) / recorders[i].MaxLimit
-		jA := (recorders[j].CurrentProgress) / recorders[j].MaxLimit
-		return iA < jA
-	})
-
-	// we'll return the first one
-	return recorders[0].RecorderId, nil
-}
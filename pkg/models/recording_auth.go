@@ -29,88 +29,7 @@ func NewRecordingAuth() *authRecording {
 }
 
 func (a *authRecording) FetchRecordings(r *plugnmeet.FetchRecordingsReq) (*plugnmeet.FetchRecordingsRes, error) {
-	db := a.db
-	ctx, cancel := context.WithTimeout(a.ctx, 3*time.Second)
-	defer cancel()
-
-	limit := r.Limit
-	orderBy := "DESC"
-
-	if limit == 0 {
-		limit = 20
-	}
-	if r.OrderBy == "ASC" {
-		orderBy = "ASC"
-	}
-
-	var rows *sql.Rows
-	var err error
-
-	switch {
-	case len(r.RoomIds) > 0:
-		var args []interface{}
-		for _, rd := range r.RoomIds {
-			args = append(args, rd)
-		}
-		args = append(args, r.From)
-		args = append(args, limit)
-
-		query := "SELECT record_id, room_id, room_sid, file_path, size, creation_time, room_creation_time FROM " + a.app.FormatDBTable("recordings") + " WHERE room_id IN (?" + strings.Repeat(",?", len(r.RoomIds)-1) + ") ORDER BY id " + orderBy + " LIMIT ?,?"
-
-		rows, err = db.QueryContext(ctx, query, args...)
-	default:
-		rows, err = db.QueryContext(ctx, "SELECT record_id, room_id, room_sid, file_path, size, creation_time, room_creation_time FROM "+a.app.FormatDBTable("recordings")+" ORDER BY id "+orderBy+" LIMIT ?,?", r.From, limit)
-	}
-
-	if err != nil {
-		return nil, err
-	}
-
-	defer rows.Close()
-	var recordings []*plugnmeet.RecordingInfo
-
-	for rows.Next() {
-		var recording plugnmeet.RecordingInfo
-		var rSid sql.NullString
-
-		err = rows.Scan(&recording.RecordId, &recording.RoomId, &rSid, &recording.FilePath, &recording.FileSize, &recording.CreationTime, &recording.RoomCreationTime)
-		if err != nil {
-			fmt.Println(err)
-		}
-		recording.RoomSid = rSid.String
-		recordings = append(recordings, &recording)
-	}
-
-	// get total number of recordings
-	var row *sql.Row
-	switch {
-	case len(r.RoomIds) > 0:
-		var args []interface{}
-		for _, rd := range r.RoomIds {
-			args = append(args, rd)
-		}
-		query := "SELECT COUNT(*) AS total FROM " + a.app.FormatDBTable("recordings") + " WHERE room_id IN (?" + strings.Repeat(",?", len(r.RoomIds)-1) + ")"
-		row = db.QueryRowContext(ctx, query, args...)
-	default:
-		row = db.QueryRowContext(ctx, "SELECT COUNT(*) AS total FROM "+a.app.FormatDBTable("recordings"))
-	}
-
-	var total int64
-	_ = row.Scan(&total)
-
-	result := &plugnmeet.FetchRecordingsRes{
-		TotalRecordings: total,
-		From:            r.From,
-		Limit:           limit,
-		OrderBy:         orderBy,
-		RecordingsList:  recordings,
-	}
-
-	if result.GetTotalRecordings() == 0 {
-		result.TotalRecordings = 0
-	}
-
-	return result, nil
+	return a.FilterRecordings(r, nil)
 }
 
 // FetchRecording to get single recording information from DB
@@ -119,7 +38,7 @@ func (a *authRecording) FetchRecording(recordId string) (*plugnmeet.RecordingInf
 	ctx, cancel := context.WithTimeout(a.ctx, 3*time.Second)
 	defer cancel()
 
-	row := db.QueryRowContext(ctx, "SELECT record_id, room_id, room_sid, file_path, size, creation_time, room_creation_time FROM "+a.app.FormatDBTable("recordings")+" WHERE record_id = ?", recordId)
+	row := db.QueryRowContext(ctx, "SELECT record_id, room_id, room_sid, file_path, size, creation_time, room_creation_time FROM "+a.app.FormatDBTable("recordings")+" WHERE record_id = ? AND deleted_at = 0", recordId)
 
 	recording := new(plugnmeet.RecordingInfo)
 	var rSid sql.NullString
@@ -146,58 +65,20 @@ type DeleteRecordingReq struct {
 	RecordId string `json:"record_id" validate:"required"`
 }
 
+// DeleteRecording will move the recording to trash, file will be kept
+// until trash days have passed so that it can be restored
 func (a *authRecording) DeleteRecording(r *plugnmeet.DeleteRecordingReq) error {
-	recording, err := a.FetchRecording(r.RecordId)
-	if err != nil {
+	if _, err := a.FetchRecording(r.RecordId); err != nil {
 		return err
 	}
 
-	path := fmt.Sprintf("%s/%s", config.AppCnf.RecorderInfo.RecordingFilesPath, recording.FilePath)
-
-	// delete main file
-	err = os.Remove(path)
-	if err != nil {
-		// if file not exist then we can delete it from record without showing any error
-		if !os.IsNotExist(err) {
-			ms := strings.SplitN(err.Error(), "/", -1)
-			return errors.New(ms[3])
-		}
-	}
-
-	// delete compressed, if any
-	_ = os.Remove(path + ".fiber.gz")
-
-	// no error, so we'll delete record from DB
-	db := a.db
 	ctx, cancel := context.WithTimeout(a.ctx, 3*time.Second)
 	defer cancel()
 
-	tx, err := db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-	stmt, err := tx.Prepare("DELETE FROM " + a.app.FormatDBTable("recordings") + " WHERE record_id = ?")
-	if err != nil {
-		return err
-	}
-
-	_, err = stmt.Exec(r.RecordId)
-	if err != nil {
-		return err
-	}
-
-	err = tx.Commit()
-	if err != nil {
-		return err
-	}
-
-	err = stmt.Close()
-	if err != nil {
-		return err
-	}
-
-	return nil
+	now := time.Now()
+	purgeAt := now.AddDate(0, 0, recordingTrashDays()).Unix()
+	_, err := a.db.ExecContext(ctx, "UPDATE "+a.app.FormatDBTable("recordings")+" SET deleted_at = ?, purge_at = ? WHERE record_id = ? AND deleted_at = 0", now.Unix(), purgeAt, r.RecordId)
+	return err
 }
 
 type GetDownloadTokenReq struct {
@@ -228,29 +109,58 @@ func (a *authRecording) GetDownloadToken(r *plugnmeet.GetDownloadTokenReq) (stri
 	return jwt.Signed(sig).Claims(cl).CompactSerialize()
 }
 
-// VerifyRecordingToken verify token & provide file path
-func (a *authRecording) VerifyRecordingToken(token string) (string, error) {
-	tok, err := jwt.ParseSigned(token)
+// VerifyRecordingToken verify token & provide file path.
+// For remote storage, it will provide a temporary download url & remote will be true
+func (a *authRecording) VerifyRecordingToken(token string) (file string, remote bool, err error) {
+	out, err := a.parseRecordingToken(token)
 	if err != nil {
-		return "", err
+		return "", false, err
 	}
 
-	out := jwt.Claims{}
-	if err = tok.Claims([]byte(config.AppCnf.Client.Secret), &out); err != nil {
-		return "", err
-	}
+	return a.resolveRecordingFile(out.Subject, a.app.RecorderInfo.TokenValidity)
+}
 
-	if err = out.Validate(jwt.Expected{Issuer: config.AppCnf.Client.ApiKey, Time: time.Now()}); err != nil {
-		return "", err
+// resolveRecordingFile will return local path of the file or
+// a temporary download url if the file is in remote storage
+func (a *authRecording) resolveRecordingFile(filePath string, validity time.Duration) (file string, remote bool, err error) {
+	storage, err := recordingStorageFor(filePath)
+	if err != nil {
+		return "", false, err
+	}
+	if storage != nil {
+		url, err := storage.DownloadUrl(filePath, validity)
+		if err != nil {
+			return "", false, err
+		}
+		return url, true, nil
 	}
 
-	file := fmt.Sprintf("%s/%s", config.AppCnf.RecorderInfo.RecordingFilesPath, out.Subject)
+	file = localRecordingPath(filePath)
 	_, err = os.Lstat(file)
 
 	if err != nil {
 		ms := strings.SplitN(err.Error(), "/", -1)
-		return "", errors.New(ms[len(ms)-1])
+		return "", false, errors.New(ms[len(ms)-1])
+	}
+
+	return file, false, nil
+}
+
+// parseRecordingToken will validate download token & return claims
+func (a *authRecording) parseRecordingToken(token string) (*jwt.Claims, error) {
+	tok, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, err
+	}
+
+	out := new(jwt.Claims)
+	if err = tok.Claims([]byte(config.AppCnf.Client.Secret), out); err != nil {
+		return nil, err
+	}
+
+	if err = out.Validate(jwt.Expected{Issuer: config.AppCnf.Client.ApiKey, Time: time.Now()}); err != nil {
+		return nil, err
 	}
 
-	return file, nil
+	return out, nil
 }
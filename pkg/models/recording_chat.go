@@ -0,0 +1,207 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// recordingChatKey keeps public chat messages of the running recording
+	recordingChatKey = "pnm:recording_chat:"
+	// recordingChatCueDuration is how long a message will be shown in the vtt file
+	recordingChatCueDuration = 5
+)
+
+type RecordingChatMessage struct {
+	// Offset in seconds from the beginning of the recorded file
+	Offset int64  `json:"offset"`
+	SentAt int64  `json:"sent_at"`
+	UserId string `json:"user_id"`
+	Name   string `json:"name"`
+	Msg    string `json:"msg"`
+}
+
+// RecordingChatFiles are locations same as file path, empty if there wasn't any message
+type RecordingChatFiles struct {
+	Json string `json:"json"`
+	Vtt  string `json:"vtt"`
+}
+
+// CaptureRecordingChat will keep public chat messages while the recording is running,
+// so that those can be replayed alongside the video
+func CaptureRecordingChat(roomId string, msg *plugnmeet.DataMessage) {
+	if msg.Type != plugnmeet.DataMsgType_USER || msg.Body == nil || msg.Body.Type != plugnmeet.DataMsgBodyType_CHAT {
+		return
+	}
+	// we won't keep private messages
+	if msg.Body.IsPrivate != nil && *msg.Body.IsPrivate == 1 {
+		return
+	}
+
+	// room sid of the message was set by the client, so we won't use it
+	room, _ := NewRoomModel().GetRoomInfo(roomId, "", 1)
+	if room.Id == 0 || room.IsRecording == 0 {
+		return
+	}
+
+	rm := NewRecordingModel()
+	segments, err := rm.LoadRecordingSegments(room.Sid)
+	if err != nil || segments.EndedAt > 0 || segments.IsPaused() {
+		return
+	}
+
+	m := &RecordingChatMessage{
+		Offset: segments.RecordedDuration(),
+		SentAt: time.Now().Unix(),
+		Msg:    msg.Body.Msg,
+	}
+	if msg.Body.From != nil {
+		m.UserId = msg.Body.From.UserId
+		if msg.Body.From.Name != nil {
+			m.Name = *msg.Body.From.Name
+		}
+	}
+	marshal, err := json.Marshal(m)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	key := recordingChatKey + room.Sid
+	pp := rm.rds.Pipeline()
+	pp.RPush(rm.ctx, key, marshal)
+	pp.Expire(rm.ctx, key, recordingSegmentsKeyTTL)
+	if _, err = pp.Exec(rm.ctx); err != nil {
+		log.Errorln(err)
+	}
+}
+
+func (rm *recordingModel) loadRecordingChat(roomSid string) []*RecordingChatMessage {
+	var messages []*RecordingChatMessage
+	result, err := rm.rds.LRange(rm.ctx, recordingChatKey+roomSid, 0, -1).Result()
+	if err != nil {
+		log.Errorln(err)
+		return messages
+	}
+
+	for _, data := range result {
+		m := new(RecordingChatMessage)
+		if err = json.Unmarshal([]byte(data), m); err != nil {
+			continue
+		}
+		messages = append(messages, m)
+	}
+	return messages
+}
+
+// resetRecordingChat should be called when new recording starts
+func (rm *recordingModel) resetRecordingChat(roomSid string) {
+	if err := rm.rds.Del(rm.ctx, recordingChatKey+roomSid).Err(); err != nil {
+		log.Errorln(err)
+	}
+}
+
+// storeRecordingChat will write chat messages as json & vtt files next to the recording,
+// upload those to the storage same as recording & save locations in DB.
+// It should be called before uploading the recording, because file path will be changed.
+func (rm *recordingModel) storeRecordingChat(r *plugnmeet.RecorderToPlugNmeet) {
+	messages := rm.loadRecordingChat(r.RoomSid)
+	if len(messages) == 0 {
+		return
+	}
+
+	// e.g. room/record_id.mp4 => room/record_id_chat.json
+	prefix := strings.TrimSuffix(r.FilePath, path.Ext(r.FilePath))
+	files := new(RecordingChatFiles)
+
+	marshal, err := json.Marshal(messages)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	if files.Json, err = writeRecordingFile(prefix+"_chat.json", marshal); err != nil {
+		log.Errorln(err)
+	}
+	if files.Vtt, err = writeRecordingFile(prefix+"_chat.vtt", recordingChatToVtt(messages)); err != nil {
+		log.Errorln(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err = rm.db.ExecContext(ctx, "UPDATE "+rm.app.FormatDBTable("recordings")+" SET chat_json = ?, chat_vtt = ? WHERE record_id = ?", files.Json, files.Vtt, r.RecordingId)
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+func writeRecordingFile(file string, data []byte) (string, error) {
+	localFile := localRecordingPath(file)
+	if err := os.MkdirAll(filepath.Dir(localFile), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(localFile, data, 0644); err != nil {
+		return "", err
+	}
+
+	location, err := uploadRecordingFile(file)
+	if err != nil {
+		// local file will be used
+		log.Errorln(err)
+	}
+	return location, nil
+}
+
+var vttEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func recordingChatToVtt(messages []*RecordingChatMessage) []byte {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+
+	for i, m := range messages {
+		end := m.Offset + recordingChatCueDuration
+		// blank line will end the cue, so message will be in single line
+		text := vttEscaper.Replace(strings.Join(strings.Fields(m.Msg), " "))
+		b.WriteString(fmt.Sprintf("\n%d\n%s --> %s\n", i+1, vttTimestamp(m.Offset), vttTimestamp(end)))
+		if m.Name != "" {
+			b.WriteString(fmt.Sprintf("<v %s>", vttEscaper.Replace(m.Name)))
+		}
+		b.WriteString(text + "\n")
+	}
+
+	return []byte(b.String())
+}
+
+func vttTimestamp(seconds int64) string {
+	return fmt.Sprintf("%02d:%02d:%02d.000", seconds/3600, seconds%3600/60, seconds%60)
+}
+
+// deleteRecordingChat will remove chat files of the recording, errors will be logged only
+func deleteRecordingChat(recordId string) {
+	app := config.AppCnf
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	files := new(RecordingChatFiles)
+	row := app.DB.QueryRowContext(ctx, "SELECT chat_json, chat_vtt FROM "+app.FormatDBTable("recordings")+" WHERE record_id = ?", recordId)
+	if err := row.Scan(&files.Json, &files.Vtt); err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	var list []string
+	for _, f := range []string{files.Json, files.Vtt} {
+		if f != "" {
+			list = append(list, f)
+		}
+	}
+	deleteStoredFiles(ctx, list)
+}
@@ -0,0 +1,310 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+	"github.com/livekit/protocol/livekit"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"time"
+)
+
+const (
+	// recordingConsentPendingKey keeps the recording request until consent timeout
+	recordingConsentPendingKey = "pnm:recording_consent_pending:"
+	// recordingConsentsKey keeps responses of the participants, field is user id
+	recordingConsentsKey = "pnm:recording_consents:"
+	// recordingConsentDeadlinesKey is shared by all servers, member is room sid & score is the deadline
+	recordingConsentDeadlinesKey = "pnm:recording_consent_deadlines"
+	recordingConsentsTTL         = 7 * 24 * time.Hour
+	recordingConsentTimeout      = 30 * time.Second
+	recordingConsentPromptMsg    = "notifications.recording-consent-prompt"
+)
+
+type pendingRecordingConsent struct {
+	RoomId       string                  `json:"room_id"`
+	RoomSid      string                  `json:"room_sid"`
+	CustomDesign *string                 `json:"custom_design,omitempty"`
+	Output       *RecordingOutputOptions `json:"output,omitempty"`
+	Individual   bool                    `json:"individual"`
+	RequestedAt  int64                   `json:"requested_at"`
+}
+
+// RecordingConsentMsg will be sent to clients to ask for consent
+type RecordingConsentMsg struct {
+	Msg string `json:"msg"`
+	// Timeout in seconds, recording will start after it even if someone didn't respond
+	Timeout int64 `json:"timeout"`
+}
+
+type RecordingConsent struct {
+	UserId      string `json:"user_id"`
+	Name        string `json:"name"`
+	Consented   bool   `json:"consented"`
+	RespondedAt int64  `json:"responded_at"`
+}
+
+type SubmitRecordingConsentReq struct {
+	RoomId    string `json:"-"`
+	UserId    string `json:"-"`
+	Consented bool   `json:"consented"`
+}
+
+// IsRecordingConsentRequired will check if participants should acknowledge before recording
+func (rm *recordingModel) IsRecordingConsentRequired(roomId string) bool {
+	return rm.roomService.LoadRoomOptions(roomId).RequireRecordingConsent
+}
+
+// RequestRecordingConsent will ask everyone of the room for consent.
+// Recording will start when all participants have responded or after the timeout.
+func (rm *recordingModel) RequestRecordingConsent(roomId, roomSid string, individual bool) error {
+	p := &pendingRecordingConsent{
+		RoomId:      roomId,
+		RoomSid:     roomSid,
+		Output:      rm.Output,
+		Individual:  individual,
+		RequestedAt: time.Now().Unix(),
+	}
+	if rm.RecordingReq != nil {
+		p.CustomDesign = rm.RecordingReq.CustomDesign
+	}
+	marshal, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	ok, err := rm.rds.SetNX(rm.ctx, recordingConsentPendingKey+roomSid, marshal, 2*recordingConsentTimeout).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("notifications.recording-consent-pending")
+	}
+
+	// responses of the previous recording shouldn't be counted
+	if err = rm.rds.Del(rm.ctx, recordingConsentsKey+roomSid).Err(); err != nil {
+		log.Errorln(err)
+	}
+
+	broadcastSystemMsg(roomId, DataMsgBodyType_RECORDING_CONSENT_REQUEST, &RecordingConsentMsg{
+		Msg:     recordingConsentPromptMsg,
+		Timeout: int64(recordingConsentTimeout.Seconds()),
+	})
+
+	// any server can start the recording after the timeout, even if this one has restarted
+	err = rm.rds.ZAdd(rm.ctx, recordingConsentDeadlinesKey, &redis.Z{
+		Score:  float64(time.Now().Add(recordingConsentTimeout).Unix()),
+		Member: roomSid,
+	}).Err()
+	if err != nil {
+		log.Errorln(err)
+	}
+
+	return nil
+}
+
+// SubmitRecordingConsent will store response of the participant
+func (rm *recordingModel) SubmitRecordingConsent(r *SubmitRecordingConsentReq) error {
+	room, _ := NewRoomModel().GetRoomInfo(r.RoomId, "", 1)
+	if room.Id == 0 {
+		return errors.New("notifications.room-not-active")
+	}
+
+	pending, _ := rm.rds.Exists(rm.ctx, recordingConsentPendingKey+room.Sid).Result()
+	if pending == 0 && room.IsRecording == 0 {
+		return errors.New("notifications.recording-not-running")
+	}
+
+	c := &RecordingConsent{
+		UserId:      r.UserId,
+		Consented:   r.Consented,
+		RespondedAt: time.Now().Unix(),
+	}
+	if p, err := rm.roomService.LoadParticipantInfo(r.RoomId, r.UserId); err == nil {
+		c.Name = p.Name
+	}
+	marshal, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	key := recordingConsentsKey + room.Sid
+	pp := rm.rds.Pipeline()
+	pp.HSet(rm.ctx, key, r.UserId, marshal)
+	pp.Expire(rm.ctx, key, recordingConsentsTTL)
+	if _, err = pp.Exec(rm.ctx); err != nil {
+		return err
+	}
+
+	// no need to wait for timeout if everyone has responded
+	if pending > 0 && rm.allRespondedToConsent(r.RoomId, room.Sid) {
+		go rm.startRecordingAfterConsent(room.Sid)
+	}
+
+	return nil
+}
+
+func (rm *recordingModel) allRespondedToConsent(roomId, roomSid string) bool {
+	participants, err := rm.roomService.LoadParticipants(roomId)
+	if err != nil {
+		return false
+	}
+	responded, err := rm.rds.HGetAll(rm.ctx, recordingConsentsKey+roomSid).Result()
+	if err != nil {
+		return false
+	}
+
+	for _, p := range participants {
+		if p.Identity == config.RECORDER_BOT || p.Identity == config.RTMP_BOT {
+			continue
+		}
+		if _, ok := responded[p.Identity]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// startRecordingAfterConsent will start the recording only once,
+// either by the timeout or when the last participant has responded
+func (rm *recordingModel) startRecordingAfterConsent(roomSid string) {
+	rm.rds.ZRem(rm.ctx, recordingConsentDeadlinesKey, roomSid)
+	result, err := rm.rds.GetDel(rm.ctx, recordingConsentPendingKey+roomSid).Result()
+	if err == redis.Nil {
+		return
+	} else if err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	p := new(pendingRecordingConsent)
+	if err = json.Unmarshal([]byte(result), p); err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	if p.Individual {
+		rm.StartIndividualRecording(p.RoomId, p.RoomSid)
+		return
+	}
+
+	rm.RecordingReq = &plugnmeet.RecordingReq{
+		Task:         plugnmeet.RecordingTasks_START_RECORDING,
+		Sid:          p.RoomSid,
+		CustomDesign: p.CustomDesign,
+	}
+	rm.Output = p.Output
+	if err = rm.SendMsgToRecorder(plugnmeet.RecordingTasks_START_RECORDING, p.RoomId, p.RoomSid, nil); err != nil {
+		log.Errorln(err)
+		rm.sendRecordingNotification(p.RoomId, plugnmeet.DataMsgBodyType_ALERT, err.Error())
+	}
+}
+
+// checkRecordingConsentDeadlines will start the recordings those consent timeout has passed
+func (s *scheduler) checkRecordingConsentDeadlines() {
+	sids, err := s.rc.ZRangeByScore(s.ctx, recordingConsentDeadlinesKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().Unix(), 10),
+	}).Result()
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	for _, sid := range sids {
+		// only one server will be able to remove it
+		if n, err := s.rc.ZRem(s.ctx, recordingConsentDeadlinesKey, sid).Result(); err != nil || n == 0 {
+			continue
+		}
+		go NewRecordingModel().startRecordingAfterConsent(sid)
+	}
+}
+
+// sendRecordingConsentPrompt will ask late joiner for consent if recording is pending or running
+func (rm *recordingModel) sendRecordingConsentPrompt(roomId, roomSid string, p *livekit.ParticipantInfo) {
+	if p.Identity == config.RECORDER_BOT || p.Identity == config.RTMP_BOT {
+		return
+	}
+	if !rm.IsRecordingConsentRequired(roomId) {
+		return
+	}
+
+	pending, _ := rm.rds.Exists(rm.ctx, recordingConsentPendingKey+roomSid).Result()
+	if pending == 0 {
+		room, _ := NewRoomModel().GetRoomInfo(roomId, roomSid, 1)
+		if room.Id == 0 || room.IsRecording == 0 {
+			return
+		}
+	}
+	// user may have responded before reconnecting
+	if responded, _ := rm.rds.HExists(rm.ctx, recordingConsentsKey+roomSid, p.Identity).Result(); responded {
+		return
+	}
+
+	sendSystemMsgToUser(roomId, p.Identity, DataMsgBodyType_RECORDING_CONSENT_REQUEST, &RecordingConsentMsg{
+		Msg: recordingConsentPromptMsg,
+	})
+}
+
+func (rm *recordingModel) loadRecordingConsents(roomSid string) []*RecordingConsent {
+	consents := []*RecordingConsent{}
+	result, err := rm.rds.HGetAll(rm.ctx, recordingConsentsKey+roomSid).Result()
+	if err != nil {
+		log.Errorln(err)
+		return consents
+	}
+
+	for _, data := range result {
+		c := new(RecordingConsent)
+		if err = json.Unmarshal([]byte(data), c); err != nil {
+			continue
+		}
+		consents = append(consents, c)
+	}
+	return consents
+}
+
+// saveRecordingConsents will store responses of the session with the recording
+func (rm *recordingModel) saveRecordingConsents(recordId, roomSid string) {
+	consents := rm.loadRecordingConsents(roomSid)
+	if len(consents) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	for _, c := range consents {
+		_, err := rm.db.ExecContext(ctx, "INSERT INTO "+rm.app.FormatDBTable("recording_consents")+
+			" (record_id, room_sid, user_id, user_name, consented, responded_at) VALUES (?, ?, ?, ?, ?, ?)",
+			recordId, roomSid, c.UserId, c.Name, c.Consented, c.RespondedAt)
+		if err != nil {
+			log.Errorln(err)
+		}
+	}
+}
+
+// getRecordingConsents will return stored responses of the recording
+func (a *authRecording) getRecordingConsents(ctx context.Context, recordId string) ([]*RecordingConsent, error) {
+	rows, err := a.db.QueryContext(ctx, "SELECT user_id, user_name, consented, responded_at FROM "+a.app.FormatDBTable("recording_consents")+" WHERE record_id = ? ORDER BY responded_at ASC", recordId)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("query error: %s", err.Error()))
+	}
+	defer rows.Close()
+
+	consents := []*RecordingConsent{}
+	for rows.Next() {
+		c := new(RecordingConsent)
+		if err = rows.Scan(&c.UserId, &c.Name, &c.Consented, &c.RespondedAt); err != nil {
+			return nil, err
+		}
+		consents = append(consents, c)
+	}
+
+	return consents, rows.Err()
+}
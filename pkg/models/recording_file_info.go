@@ -0,0 +1,122 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const ffprobePath = "/usr/bin/ffprobe"
+
+// RecordingFileInfo will be sent with recording_proceeded webhook,
+// so that integrators won't need to fetch the recording again
+type RecordingFileInfo struct {
+	RoomSid string `json:"room_sid"`
+	// FileSize in MB
+	FileSize float32 `json:"file_size"`
+	// Duration in seconds excluding paused segments
+	Duration int64 `json:"duration"`
+	Width    int   `json:"width,omitempty"`
+	Height   int   `json:"height,omitempty"`
+	// Checksum is SHA-256 of the file in hex
+	Checksum string `json:"checksum,omitempty"`
+	// StorageDriver is the configured storage, where the recording will be uploaded
+	// after sending the webhook. Location of the object can be fetched later using recording info.
+	StorageDriver string `json:"storage_driver"`
+	// Location is file path of the recording in local disk
+	Location string `json:"location"`
+}
+
+// recordingProceededNotifyEvent will add details of the file with common notify event
+type recordingProceededNotifyEvent struct {
+	*plugnmeet.CommonNotifyEvent
+	Recording *RecordingFileInfo `json:"recording"`
+}
+
+// inspectRecordingFile should be called before uploading to the storage
+// because local file may be deleted after that
+func (rm *recordingModel) inspectRecordingFile(r *plugnmeet.RecorderToPlugNmeet) *RecordingFileInfo {
+	info := &RecordingFileInfo{
+		RoomSid:  r.RoomSid,
+		FileSize: r.FileSize,
+	}
+	if s, err := rm.LoadRecordingSegments(r.RoomSid); err == nil {
+		info.Duration = s.RecordedDuration()
+	}
+
+	localFile := localRecordingPath(r.FilePath)
+	checksum, err := fileChecksum(localFile)
+	if err != nil {
+		// file may not be accessible from this server
+		log.Warnln(err)
+		return info
+	}
+	info.Checksum = checksum
+	info.Width, info.Height = probeVideoResolution(localFile)
+
+	return info
+}
+
+func fileChecksum(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// probeVideoResolution will use ffprobe if it was installed, otherwise 0 will be returned
+func probeVideoResolution(file string) (int, int) {
+	if _, err := os.Stat(ffprobePath); err != nil {
+		return 0, 0
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, ffprobePath, "-v", "error", "-select_streams", "v:0", "-show_entries", "stream=width,height", "-of", "json", file).Output()
+	if err != nil {
+		log.Errorln(err)
+		return 0, 0
+	}
+
+	res := struct {
+		Streams []struct {
+			Width  int `json:"width"`
+			Height int `json:"height"`
+		} `json:"streams"`
+	}{}
+	if err = json.Unmarshal(out, &res); err != nil || len(res.Streams) == 0 {
+		return 0, 0
+	}
+	return res.Streams[0].Width, res.Streams[0].Height
+}
+
+// sendRecordingProceededWebhook will send recording_proceeded with details of the file
+func (rm *recordingModel) sendRecordingProceededWebhook(r *plugnmeet.RecorderToPlugNmeet, info *RecordingFileInfo) {
+	info.Location = r.FilePath
+	info.StorageDriver = rm.app.RecorderInfo.Storage.Driver
+	if info.StorageDriver == "" {
+		info.StorageDriver = RecordingStorageLocal
+	}
+
+	msg := &recordingProceededNotifyEvent{
+		CommonNotifyEvent: rm.recordingNotifyEvent(r),
+		Recording:         info,
+	}
+	if err := NewWebhookNotifier().Notify(r.RoomSid, msg); err != nil {
+		log.Errorln(err)
+	}
+}
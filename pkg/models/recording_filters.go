@@ -0,0 +1,161 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+// recordingTagsKey keeps tags of the room for the session, because
+// room options will be removed before the recorder finished processing
+const recordingTagsKey = "pnm:recording_tags:"
+
+// RecordingFilters can be sent with /auth/recording/fetch in addition to plugnmeet.FetchRecordingsReq
+type RecordingFilters struct {
+	// CreatedAfter & CreatedBefore are unix timestamp of creation time of the recording
+	CreatedAfter  int64 `json:"created_after" validate:"min=0"`
+	CreatedBefore int64 `json:"created_before" validate:"min=0"`
+	// MinDuration & MaxDuration in seconds
+	MinDuration int64 `json:"min_duration" validate:"min=0"`
+	MaxDuration int64 `json:"max_duration" validate:"min=0"`
+	// MinSize & MaxSize in MB
+	MinSize float64 `json:"min_size" validate:"min=0"`
+	MaxSize float64 `json:"max_size" validate:"min=0"`
+	// Tag of the room, which was set during create room
+	Tag string `json:"tag" validate:"max=100"`
+	// SortBy: creation_time (default), duration, size or room_id. Direction will be order_by
+	SortBy string `json:"sort_by" validate:"omitempty,oneof=creation_time duration size room_id"`
+}
+
+var recordingSortColumns = map[string]string{
+	"creation_time": "id",
+	"duration":      "duration",
+	"size":          "size",
+	"room_id":       "room_id",
+}
+
+// FilterRecordings will return recordings matching the filters with total count for pagination
+func (a *authRecording) FilterRecordings(r *plugnmeet.FetchRecordingsReq, f *RecordingFilters) (*plugnmeet.FetchRecordingsRes, error) {
+	if f == nil {
+		f = new(RecordingFilters)
+	}
+	ctx, cancel := context.WithTimeout(a.ctx, 3*time.Second)
+	defer cancel()
+
+	limit := r.Limit
+	orderBy := "DESC"
+	if limit == 0 {
+		limit = 20
+	}
+	if r.OrderBy == "ASC" {
+		orderBy = "ASC"
+	}
+	sortBy, ok := recordingSortColumns[f.SortBy]
+	if !ok {
+		sortBy = "id"
+	}
+
+	where := []string{"deleted_at = 0"}
+	var args []interface{}
+	if len(r.RoomIds) > 0 {
+		where = append(where, "room_id IN (?"+strings.Repeat(",?", len(r.RoomIds)-1)+")")
+		for _, rd := range r.RoomIds {
+			args = append(args, rd)
+		}
+	}
+	if f.CreatedAfter > 0 {
+		where = append(where, "creation_time >= ?")
+		args = append(args, f.CreatedAfter)
+	}
+	if f.CreatedBefore > 0 {
+		where = append(where, "creation_time <= ?")
+		args = append(args, f.CreatedBefore)
+	}
+	if f.MinDuration > 0 {
+		where = append(where, "duration >= ?")
+		args = append(args, f.MinDuration)
+	}
+	if f.MaxDuration > 0 {
+		where = append(where, "duration <= ?")
+		args = append(args, f.MaxDuration)
+	}
+	if f.MinSize > 0 {
+		where = append(where, "size >= ?")
+		args = append(args, f.MinSize)
+	}
+	if f.MaxSize > 0 {
+		where = append(where, "size <= ?")
+		args = append(args, f.MaxSize)
+	}
+	if f.Tag != "" {
+		where = append(where, "FIND_IN_SET(?, tags) > 0")
+		args = append(args, f.Tag)
+	}
+	cond := strings.Join(where, " AND ")
+
+	// get total number of recordings
+	var total int64
+	row := a.db.QueryRowContext(ctx, "SELECT COUNT(*) AS total FROM "+a.app.FormatDBTable("recordings")+" WHERE "+cond, args...)
+	if err := row.Scan(&total); err != nil {
+		return nil, err
+	}
+
+	result := &plugnmeet.FetchRecordingsRes{
+		TotalRecordings: total,
+		From:            r.From,
+		Limit:           limit,
+		OrderBy:         orderBy,
+	}
+	if total == 0 {
+		return result, nil
+	}
+
+	query := "SELECT record_id, room_id, room_sid, file_path, size, creation_time, room_creation_time FROM " + a.app.FormatDBTable("recordings") + " WHERE " + cond + " ORDER BY " + sortBy + " " + orderBy + ", id " + orderBy + " LIMIT ?,?"
+	rows, err := a.db.QueryContext(ctx, query, append(args, r.From, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var recording plugnmeet.RecordingInfo
+		var rSid sql.NullString
+
+		err = rows.Scan(&recording.RecordId, &recording.RoomId, &rSid, &recording.FilePath, &recording.FileSize, &recording.CreationTime, &recording.RoomCreationTime)
+		if err != nil {
+			log.Errorln(err)
+			continue
+		}
+		recording.RoomSid = rSid.String
+		result.RecordingsList = append(result.RecordingsList, &recording)
+	}
+
+	return result, rows.Err()
+}
+
+// saveRecordingTags should be called when the room is active
+func (rm *recordingModel) saveRecordingTags(roomId, roomSid string) {
+	var tags []string
+	for _, t := range rm.roomService.LoadRoomOptions(roomId).Tags {
+		// comma is the separator in DB
+		if t = strings.TrimSpace(strings.ReplaceAll(t, ",", "")); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	if len(tags) == 0 {
+		return
+	}
+
+	err := rm.rds.Set(rm.ctx, recordingTagsKey+roomSid, strings.Join(tags, ","), recordingRetentionKeyTTL).Err()
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+func (rm *recordingModel) recordingTags(roomSid string) string {
+	tags, _ := rm.rds.Get(rm.ctx, recordingTagsKey+roomSid).Result()
+	return tags
+}
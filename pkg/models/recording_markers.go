@@ -0,0 +1,111 @@
+package models
+
+import (
+	"errors"
+	"github.com/goccy/go-json"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"time"
+)
+
+const (
+	// recordingMarkersKey keeps markers of the running recording
+	recordingMarkersKey = "pnm:recording_markers:"
+	maxRecordingMarkers = 200
+)
+
+type RecordingMarker struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	// Offset in seconds from the beginning of the recorded file
+	Offset    int64  `json:"offset"`
+	CreatedBy string `json:"created_by"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type AddRecordingMarkerReq struct {
+	RoomId string `json:"-"`
+	UserId string `json:"-"`
+	Name   string `json:"name" validate:"required,max=100"`
+}
+
+// AddRecordingMarker will add a named marker at the current position of the running recording
+func (rm *recordingModel) AddRecordingMarker(r *AddRecordingMarkerReq) (*RecordingMarker, error) {
+	room, _ := NewRoomModel().GetRoomInfo(r.RoomId, "", 1)
+	if room.Id == 0 {
+		return nil, errors.New("notifications.room-not-active")
+	}
+	if room.IsRecording == 0 {
+		return nil, errors.New("notifications.recording-not-running")
+	}
+
+	segments, err := rm.LoadRecordingSegments(room.Sid)
+	if err != nil {
+		return nil, err
+	}
+
+	key := recordingMarkersKey + room.Sid
+	total, err := rm.rds.LLen(rm.ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if total >= maxRecordingMarkers {
+		return nil, errors.New("maximum number of markers reached")
+	}
+
+	now := time.Now()
+	marker := &RecordingMarker{
+		Id:        strconv.FormatInt(now.UnixMilli(), 10),
+		Name:      r.Name,
+		Offset:    segments.RecordedDuration(),
+		CreatedBy: r.UserId,
+		CreatedAt: now.Unix(),
+	}
+	marshal, err := json.Marshal(marker)
+	if err != nil {
+		return nil, err
+	}
+
+	pp := rm.rds.Pipeline()
+	pp.RPush(rm.ctx, key, marshal)
+	pp.Expire(rm.ctx, key, recordingSegmentsKeyTTL)
+	if _, err = pp.Exec(rm.ctx); err != nil {
+		return nil, err
+	}
+
+	return marker, nil
+}
+
+// GetRecordingMarkers will return markers of the running recording of the room
+func (rm *recordingModel) GetRecordingMarkers(roomId string) ([]*RecordingMarker, error) {
+	room, _ := NewRoomModel().GetRoomInfo(roomId, "", 1)
+	if room.Id == 0 {
+		return nil, errors.New("notifications.room-not-active")
+	}
+	return rm.loadRecordingMarkers(room.Sid), nil
+}
+
+func (rm *recordingModel) loadRecordingMarkers(roomSid string) []*RecordingMarker {
+	markers := []*RecordingMarker{}
+	result, err := rm.rds.LRange(rm.ctx, recordingMarkersKey+roomSid, 0, -1).Result()
+	if err != nil {
+		log.Errorln(err)
+		return markers
+	}
+
+	for _, data := range result {
+		m := new(RecordingMarker)
+		if err = json.Unmarshal([]byte(data), m); err != nil {
+			continue
+		}
+		markers = append(markers, m)
+	}
+	return markers
+}
+
+// resetRecordingMarkers should be called when new recording starts
+func (rm *recordingModel) resetRecordingMarkers(roomSid string) {
+	if err := rm.rds.Del(rm.ctx, recordingMarkersKey+roomSid).Err(); err != nil {
+		log.Errorln(err)
+	}
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"errors"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+)
+
+const (
+	RecordingContainerMp4  = "mp4"
+	RecordingContainerWebm = "webm"
+)
+
+// RecordingOutputOptions will be sent to the recorder with START_RECORDING,
+// for START_RTMP layout will be used only.
+// Those can't be sent as part of plugnmeet.RecordingReq,
+// so client will send as query of /api/recording
+type RecordingOutputOptions struct {
+	Container  string `json:"container,omitempty" query:"container" validate:"omitempty,oneof=mp4 webm"`
+	VideoCodec string `json:"video_codec,omitempty" query:"video_codec" validate:"omitempty,oneof=h264 vp9"`
+	AudioCodec string `json:"audio_codec,omitempty" query:"audio_codec" validate:"omitempty,oneof=aac opus"`
+	Width      int    `json:"width,omitempty" query:"width" validate:"min=0,max=3840"`
+	Height     int    `json:"height,omitempty" query:"height" validate:"min=0,max=2160"`
+	// Layout of the composite: grid, speaker or presentation
+	Layout string `json:"layout,omitempty" query:"layout" validate:"omitempty,oneof=grid speaker presentation"`
+}
+
+func (o *RecordingOutputOptions) isEmpty() bool {
+	return o.Container == "" && o.VideoCodec == "" && o.AudioCodec == "" && o.Width == 0 && o.Height == 0 && o.Layout == ""
+}
+
+// ResolveRecordingOutput will fill missing values from config & validate the combination
+func ResolveRecordingOutput(o *RecordingOutputOptions) (*RecordingOutputOptions, error) {
+	conf := config.AppCnf.RecorderInfo.Output
+	out := &RecordingOutputOptions{
+		Container:  conf.Container,
+		VideoCodec: conf.VideoCodec,
+		AudioCodec: conf.AudioCodec,
+		Width:      conf.Width,
+		Height:     conf.Height,
+		Layout:     conf.Layout,
+	}
+	if o != nil {
+		if o.Container != "" {
+			out.Container = o.Container
+			// codecs of the default container may not fit
+			out.VideoCodec, out.AudioCodec = "", ""
+		}
+		if o.VideoCodec != "" {
+			out.VideoCodec = o.VideoCodec
+		}
+		if o.AudioCodec != "" {
+			out.AudioCodec = o.AudioCodec
+		}
+		if o.Width > 0 || o.Height > 0 {
+			out.Width, out.Height = o.Width, o.Height
+		}
+		if o.Layout != "" {
+			out.Layout = o.Layout
+		}
+	}
+
+	switch out.Container {
+	case RecordingContainerWebm:
+		if out.VideoCodec == "" {
+			out.VideoCodec = "vp9"
+		}
+		if out.AudioCodec == "" {
+			out.AudioCodec = "opus"
+		}
+		if out.VideoCodec != "vp9" || out.AudioCodec != "opus" {
+			return nil, errors.New("webm supports vp9 & opus only")
+		}
+	case RecordingContainerMp4:
+		if out.VideoCodec == "" {
+			out.VideoCodec = "h264"
+		}
+		if out.AudioCodec == "" {
+			out.AudioCodec = "aac"
+		}
+	}
+
+	if (out.Width > 0) != (out.Height > 0) {
+		return nil, errors.New("both width & height are required")
+	}
+	if out.Width%2 != 0 || out.Height%2 != 0 {
+		return nil, errors.New("width & height must be even numbers")
+	}
+
+	return out, nil
+}
+
+// withRecordingOutput will add output options in the payload for the recorder.
+// Nothing will be added if no option was set, so older recorders will keep working.
+func withRecordingOutput(payload []byte, o *RecordingOutputOptions) []byte {
+	if o == nil || o.isEmpty() {
+		return payload
+	}
+	return withRecorderPayloadField(payload, "output", o)
+}
+
+// withRecorderPayloadField will add the value in the payload for the recorder,
+// payload will be returned as it is in case of any error
+func withRecorderPayloadField(payload []byte, field string, v interface{}) []byte {
+	msg := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		return payload
+	}
+	value, err := json.Marshal(v)
+	if err != nil {
+		return payload
+	}
+	msg[field] = value
+
+	marshal, err := json.Marshal(msg)
+	if err != nil {
+		return payload
+	}
+	return marshal
+}
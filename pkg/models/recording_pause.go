@@ -0,0 +1,272 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// tasks those aren't part of plugnmeet.RecordingTasks yet.
+// Those will be sent to the recorder as number using the same channel
+// & recorder should reply with the same task after pausing or resuming.
+const (
+	RecordingTasksPauseRecording  plugnmeet.RecordingTasks = 8
+	RecordingTasksResumeRecording plugnmeet.RecordingTasks = 9
+)
+
+const (
+	// recordingSegmentsKey keeps paused segments of the running recording
+	recordingSegmentsKey    = "pnm:recording_segments:"
+	recordingSegmentsKeyTTL = 7 * 24 * time.Hour
+)
+
+type RecordingPausedSegment struct {
+	PausedAt  int64 `json:"paused_at"`
+	ResumedAt int64 `json:"resumed_at"`
+}
+
+type RecordingSegments struct {
+	StartedAt int64 `json:"started_at"`
+	EndedAt   int64 `json:"ended_at,omitempty"`
+	// PausedAt is set while the recording is paused
+	PausedAt       int64                     `json:"paused_at,omitempty"`
+	PausedSegments []*RecordingPausedSegment `json:"paused_segments"`
+}
+
+func (s *RecordingSegments) IsPaused() bool {
+	return s.PausedAt > 0
+}
+
+// RecordedDuration in seconds excluding paused segments
+func (s *RecordingSegments) RecordedDuration() int64 {
+	if s.StartedAt == 0 {
+		return 0
+	}
+	end := s.EndedAt
+	if end == 0 {
+		end = time.Now().Unix()
+	}
+
+	duration := end - s.StartedAt
+	for _, p := range s.PausedSegments {
+		duration -= p.ResumedAt - p.PausedAt
+	}
+	if s.PausedAt > 0 {
+		duration -= end - s.PausedAt
+	}
+	if duration < 0 {
+		return 0
+	}
+	return duration
+}
+
+// recordingTaskName will return name of the task including the tasks those aren't part of protocol
+func recordingTaskName(task plugnmeet.RecordingTasks) string {
+	switch task {
+	case RecordingTasksPauseRecording:
+		return "PAUSE_RECORDING"
+	case RecordingTasksResumeRecording:
+		return "RESUME_RECORDING"
+	}
+	return task.String()
+}
+
+func (rm *recordingModel) LoadRecordingSegments(roomSid string) (*RecordingSegments, error) {
+	result, err := rm.rds.Get(rm.ctx, recordingSegmentsKey+roomSid).Result()
+	if err == redis.Nil {
+		return nil, errors.New("no recording info found")
+	} else if err != nil {
+		return nil, err
+	}
+
+	s := new(RecordingSegments)
+	err = json.Unmarshal([]byte(result), s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (rm *recordingModel) saveRecordingSegments(roomSid string, s *RecordingSegments) {
+	marshal, err := json.Marshal(s)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	err = rm.rds.Set(rm.ctx, recordingSegmentsKey+roomSid, marshal, recordingSegmentsKeyTTL).Err()
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+// IsRecordingPaused will check if the running recording of the session was paused
+func (rm *recordingModel) IsRecordingPaused(roomSid string) bool {
+	s, err := rm.LoadRecordingSegments(roomSid)
+	if err != nil {
+		return false
+	}
+	return s.IsPaused()
+}
+
+// startRecordingSegments will reset segments as recording has been started
+func (rm *recordingModel) startRecordingSegments(roomSid string) {
+	rm.saveRecordingSegments(roomSid, &RecordingSegments{
+		StartedAt:      time.Now().Unix(),
+		PausedSegments: []*RecordingPausedSegment{},
+	})
+}
+
+// endRecordingSegments will close the pause, if any
+func (rm *recordingModel) endRecordingSegments(roomSid string) {
+	s, err := rm.LoadRecordingSegments(roomSid)
+	if err != nil {
+		return
+	}
+	now := time.Now().Unix()
+	if s.IsPaused() {
+		s.PausedSegments = append(s.PausedSegments, &RecordingPausedSegment{
+			PausedAt:  s.PausedAt,
+			ResumedAt: now,
+		})
+		s.PausedAt = 0
+	}
+	s.EndedAt = now
+	rm.saveRecordingSegments(roomSid, s)
+}
+
+// recordingPaused will be called when recorder has paused the recording
+func (rm *recordingModel) recordingPaused(r *plugnmeet.RecorderToPlugNmeet) {
+	if !r.Status {
+		rm.sendRecordingNotification(r.RoomId, plugnmeet.DataMsgBodyType_ALERT, "notifications.recording-pause-failed")
+		return
+	}
+
+	s, err := rm.LoadRecordingSegments(r.RoomSid)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	if !s.IsPaused() {
+		s.PausedAt = time.Now().Unix()
+		rm.saveRecordingSegments(r.RoomSid, s)
+	}
+
+	rm.sendRecordingNotification(r.RoomId, plugnmeet.DataMsgBodyType_INFO, "notifications.recording-paused")
+}
+
+// recordingResumed will be called when recorder has resumed the recording
+func (rm *recordingModel) recordingResumed(r *plugnmeet.RecorderToPlugNmeet) {
+	if !r.Status {
+		rm.sendRecordingNotification(r.RoomId, plugnmeet.DataMsgBodyType_ALERT, "notifications.recording-resume-failed")
+		return
+	}
+
+	s, err := rm.LoadRecordingSegments(r.RoomSid)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	if s.IsPaused() {
+		s.PausedSegments = append(s.PausedSegments, &RecordingPausedSegment{
+			PausedAt:  s.PausedAt,
+			ResumedAt: time.Now().Unix(),
+		})
+		s.PausedAt = 0
+		rm.saveRecordingSegments(r.RoomSid, s)
+	}
+
+	rm.sendRecordingNotification(r.RoomId, plugnmeet.DataMsgBodyType_INFO, "notifications.recording-resumed")
+}
+
+func (rm *recordingModel) sendRecordingNotification(roomId string, msgType plugnmeet.DataMsgBodyType, msg string) {
+	dm := NewDataMessageModel()
+	err := dm.SendDataMessage(&plugnmeet.DataMessageReq{
+		MsgBodyType: msgType,
+		Msg:         msg,
+		RoomId:      roomId,
+	})
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+type RecordingDetails struct {
+	*plugnmeet.RecordingInfo
+	// Duration in seconds excluding paused segments
+	Duration       int64                     `json:"duration"`
+	PausedSegments []*RecordingPausedSegment `json:"paused_segments"`
+	// Markers can be used by players to build chapter list
+	Markers []*RecordingMarker `json:"markers"`
+	// Consents of the participants if the room required it
+	Consents []*RecordingConsent `json:"consents"`
+	// Poster & Thumbnails are locations same as file path, empty if not generated
+	Poster     string   `json:"poster"`
+	Thumbnails []string `json:"thumbnails"`
+	// Chat is the public chat of the session as json & vtt files
+	Chat *RecordingChatFiles `json:"chat"`
+	// Renditions are transcoded variants of the recording
+	Renditions []*RecordingRendition `json:"renditions"`
+	// Whiteboard is the final board of the session as pdf, empty if not attached
+	Whiteboard string `json:"whiteboard"`
+	// TranscriptStatus: pending, processing, completed or failed. Empty if not requested
+	TranscriptStatus string `json:"transcript_status"`
+}
+
+type GetRecordingInfoReq struct {
+	RecordId string `json:"record_id" validate:"required"`
+}
+
+// GetRecordingInfo will return recording with duration & paused segments
+func (a *authRecording) GetRecordingInfo(r *GetRecordingInfoReq) (*RecordingDetails, error) {
+	recording, err := a.FetchRecording(r.RecordId)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(a.ctx, 3*time.Second)
+	defer cancel()
+
+	details := &RecordingDetails{
+		RecordingInfo:  recording,
+		PausedSegments: []*RecordingPausedSegment{},
+		Markers:        []*RecordingMarker{},
+		Thumbnails:     []string{},
+		Chat:           new(RecordingChatFiles),
+	}
+	var segments, markers, thumbnails sql.NullString
+	row := a.db.QueryRowContext(ctx, "SELECT duration, paused_segments, markers, poster, thumbnails, chat_json, chat_vtt, whiteboard, transcript_status FROM "+a.app.FormatDBTable("recordings")+" WHERE record_id = ?", r.RecordId)
+	if err = row.Scan(&details.Duration, &segments, &markers, &details.Poster, &thumbnails, &details.Chat.Json, &details.Chat.Vtt, &details.Whiteboard, &details.TranscriptStatus); err != nil {
+		return nil, errors.New(fmt.Sprintf("query error: %s", err.Error()))
+	}
+	if segments.String != "" {
+		if err = json.Unmarshal([]byte(segments.String), &details.PausedSegments); err != nil {
+			log.Errorln(err)
+		}
+	}
+	if markers.String != "" {
+		if err = json.Unmarshal([]byte(markers.String), &details.Markers); err != nil {
+			log.Errorln(err)
+		}
+	}
+	if thumbnails.String != "" {
+		if err = json.Unmarshal([]byte(thumbnails.String), &details.Thumbnails); err != nil {
+			log.Errorln(err)
+		}
+	}
+	details.Consents, err = a.getRecordingConsents(ctx, r.RecordId)
+	if err != nil {
+		return nil, err
+	}
+	details.Renditions, err = a.getRecordingRenditions(ctx, r.RecordId)
+	if err != nil {
+		return nil, err
+	}
+
+	return details, nil
+}
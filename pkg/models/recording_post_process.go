@@ -0,0 +1,95 @@
+package models
+
+import (
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/proto"
+	"time"
+)
+
+const (
+	// recordingPostProcessQueueKey is shared by all servers, so the job won't be lost
+	// if the server which received recording_proceeded stops before processing
+	recordingPostProcessQueueKey = "pnm:recording_post_process_queue"
+	recordingPostProcessWorkers  = 2
+)
+
+type recordingPostProcessJob struct {
+	// Recording is the event from recorder in protobuf format
+	Recording []byte `json:"recording"`
+	// Added will be false if the recording couldn't be saved in DB
+	Added    bool  `json:"added"`
+	Duration int64 `json:"duration"`
+}
+
+// enqueueRecordingPostProcess will add the recording in queue
+// to generate thumbnails, upload to storage etc.
+func (rm *recordingModel) enqueueRecordingPostProcess(r *plugnmeet.RecorderToPlugNmeet, added bool, duration int64) {
+	recording, err := proto.Marshal(r)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	marshal, err := json.Marshal(&recordingPostProcessJob{
+		Recording: recording,
+		Added:     added,
+		Duration:  duration,
+	})
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	if err = rm.rds.RPush(rm.ctx, recordingPostProcessQueueKey, marshal).Err(); err != nil {
+		log.Errorln(err)
+	}
+}
+
+// startRecordingPostProcessWorkers will start workers those will wait for jobs in the queue
+func (s *scheduler) startRecordingPostProcessWorkers() {
+	for i := 0; i < recordingPostProcessWorkers; i++ {
+		go s.recordingPostProcessWorker()
+	}
+}
+
+func (s *scheduler) recordingPostProcessWorker() {
+	for {
+		result, err := s.rc.BLPop(s.ctx, 5*time.Second, recordingPostProcessQueueKey).Result()
+		if err != nil {
+			// redis.Nil means timeout, so we'll wait again
+			continue
+		}
+
+		job := new(recordingPostProcessJob)
+		if err = json.Unmarshal([]byte(result[1]), job); err != nil {
+			log.Errorln(err)
+			continue
+		}
+		r := new(plugnmeet.RecorderToPlugNmeet)
+		if err = proto.Unmarshal(job.Recording, r); err != nil {
+			log.Errorln(err)
+			continue
+		}
+		NewRecordingModel().processRecordingPostProcessJob(r, job)
+	}
+}
+
+func (rm *recordingModel) processRecordingPostProcessJob(r *plugnmeet.RecorderToPlugNmeet, job *recordingPostProcessJob) {
+	if job.Added {
+		rm.generateRecordingThumbnails(r, job.Duration)
+		rm.storeRecordingChat(r)
+		rm.storeRecordingWhiteboard(r)
+	}
+
+	// upload to remote storage, if configured
+	location, err := rm.storeRecording(r.RecordingId, r.FilePath)
+	if err != nil {
+		log.Errorln(err)
+	}
+	r.FilePath = location
+
+	if job.Added {
+		rm.enqueueTranscription(r)
+		rm.enqueueTranscode(r)
+	}
+}
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	// recordingApiKeyKey keeps api key which created the room for the session, because
+	// room options will be removed before the recorder finished processing
+	recordingApiKeyKey          = "pnm:recording_api_key:"
+	recordingQuotaExceededEvent = "recording_quota_exceeded"
+)
+
+type GetRecordingUsageReq struct {
+	RoomId string `json:"room_id" validate:"required_without=ApiKey"`
+	ApiKey string `json:"api_key" validate:"required_without=RoomId"`
+}
+
+type RecordingUsage struct {
+	RoomId string `json:"room_id,omitempty"`
+	ApiKey string `json:"api_key,omitempty"`
+	// UsedMB is total size of the recordings those haven't been deleted
+	UsedMB     float64 `json:"used_mb"`
+	Recordings int64   `json:"recordings"`
+	// QuotaMB 0 means unlimited
+	QuotaMB  int64 `json:"quota_mb"`
+	Exceeded bool  `json:"exceeded"`
+}
+
+// roomRecordingQuota will return quota of the room id. Room level value will get priority
+func roomRecordingQuota(opts *RoomOptions) int64 {
+	if opts != nil && opts.RecordingQuotaMB > 0 {
+		return opts.RecordingQuotaMB
+	}
+	return config.AppCnf.RecorderInfo.Quota.RoomMB
+}
+
+// apiKeyRecordingQuota will return quota of the api key, otherwise default value
+func apiKeyRecordingQuota(apiKey string) int64 {
+	info, err := NewApiKeysModel().LookupApiKey(apiKey)
+	if err == nil && info.RecordingQuotaMB > 0 {
+		return info.RecordingQuotaMB
+	}
+	return config.AppCnf.RecorderInfo.Quota.ApiKeyMB
+}
+
+// GetRecordingUsage will return storage usage of the room id or api key
+func (rm *recordingModel) GetRecordingUsage(r *GetRecordingUsageReq) (*RecordingUsage, error) {
+	usage := &RecordingUsage{
+		RoomId: r.RoomId,
+		ApiKey: r.ApiKey,
+	}
+
+	var err error
+	if r.RoomId != "" {
+		usage.QuotaMB = roomRecordingQuota(rm.roomService.LoadRoomOptions(r.RoomId))
+		usage.UsedMB, usage.Recordings, err = rm.recordingStorageUsed("room_id", r.RoomId)
+	} else {
+		usage.QuotaMB = apiKeyRecordingQuota(r.ApiKey)
+		usage.UsedMB, usage.Recordings, err = rm.recordingStorageUsed("api_key", r.ApiKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	usage.Exceeded = usage.QuotaMB > 0 && usage.UsedMB >= float64(usage.QuotaMB)
+
+	return usage, nil
+}
+
+func (rm *recordingModel) recordingStorageUsed(column, value string) (float64, int64, error) {
+	ctx, cancel := context.WithTimeout(rm.ctx, 3*time.Second)
+	defer cancel()
+
+	var used float64
+	var total int64
+	row := rm.db.QueryRowContext(ctx, "SELECT COALESCE(SUM(size), 0), COUNT(*) FROM "+rm.app.FormatDBTable("recordings")+" WHERE deleted_at = 0 AND "+column+" = ?", value)
+	if err := row.Scan(&used, &total); err != nil {
+		return 0, 0, errors.New(fmt.Sprintf("query error: %s", err.Error()))
+	}
+	return used, total, nil
+}
+
+// CheckRecordingQuota will return error if the room id or the api key
+// which created the room has exceeded the quota
+func (rm *recordingModel) CheckRecordingQuota(roomId, roomSid string) error {
+	opts := rm.roomService.LoadRoomOptions(roomId)
+
+	checks := []*GetRecordingUsageReq{{RoomId: roomId}}
+	if opts.ApiKey != "" {
+		checks = append(checks, &GetRecordingUsageReq{ApiKey: opts.ApiKey})
+	}
+	for _, c := range checks {
+		usage, err := rm.GetRecordingUsage(c)
+		if err != nil {
+			log.Errorln(err)
+			continue
+		}
+		if usage.Exceeded {
+			go rm.sendQuotaExceededWebhook(roomId, roomSid, usage)
+			return errors.New("notifications.recording-quota-exceeded")
+		}
+	}
+
+	return nil
+}
+
+func (rm *recordingModel) sendQuotaExceededWebhook(roomId, roomSid string, usage *RecordingUsage) {
+	event := recordingQuotaExceededEvent
+	reason := fmt.Sprintf("room quota of %d MB has been exceeded, used %.2f MB", usage.QuotaMB, usage.UsedMB)
+	if usage.ApiKey != "" {
+		reason = fmt.Sprintf("api key quota of %d MB has been exceeded, used %.2f MB", usage.QuotaMB, usage.UsedMB)
+	}
+	msg := &plugnmeet.CommonNotifyEvent{
+		Event: &event,
+		Room: &plugnmeet.NotifyEventRoom{
+			Sid:    &roomSid,
+			RoomId: &roomId,
+		},
+		RecordingInfo: &plugnmeet.RecordingInfoEvent{
+			RecorderMsg: reason,
+		},
+	}
+	if err := NewWebhookNotifier().Notify(roomSid, msg); err != nil {
+		log.Errorln(err)
+	}
+}
+
+// saveRecordingApiKey should be called when the room is active
+func (rm *recordingModel) saveRecordingApiKey(roomId, roomSid string) {
+	apiKey := rm.roomService.LoadRoomOptions(roomId).ApiKey
+	if apiKey == "" {
+		return
+	}
+	err := rm.rds.Set(rm.ctx, recordingApiKeyKey+roomSid, apiKey, recordingRetentionKeyTTL).Err()
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+func (rm *recordingModel) recordingApiKey(roomSid string) string {
+	apiKey, _ := rm.rds.Get(rm.ctx, recordingApiKeyKey+roomSid).Result()
+	return apiKey
+}
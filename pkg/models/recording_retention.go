@@ -0,0 +1,186 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	// recordingRetentionKey keeps retention days of the session, because
+	// room options will be removed before the recorder finished processing
+	recordingRetentionKey    = "pnm:recording_retention:"
+	recordingRetentionKeyTTL = 7 * 24 * time.Hour
+	recordingRetentionLock   = "pnm:recording_retention_lock"
+
+	recordingExpiredEvent         = "recording_expired"
+	defaultRetentionCheckInterval = time.Hour
+	retentionBatchSize            = 100
+)
+
+// recordingRetentionDays will return retention of the room.
+// Room level value will get priority, then api key which created the room & then default.
+// 0 means recordings will be kept forever.
+func recordingRetentionDays(opts *RoomOptions) int {
+	if opts.RecordingRetentionDays > 0 {
+		return opts.RecordingRetentionDays
+	}
+	if opts.ApiKey != "" {
+		info, err := NewApiKeysModel().LookupApiKey(opts.ApiKey)
+		if err == nil && info.RecordingRetentionDays > 0 {
+			return info.RecordingRetentionDays
+		}
+	}
+	return config.AppCnf.RecorderInfo.Retention.DefaultDays
+}
+
+// saveRecordingRetention should be called when the room is active
+func (rm *recordingModel) saveRecordingRetention(roomId, roomSid string) {
+	days := recordingRetentionDays(rm.roomService.LoadRoomOptions(roomId))
+	err := rm.rds.Set(rm.ctx, recordingRetentionKey+roomSid, days, recordingRetentionKeyTTL).Err()
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+// recordingExpiresAt will return unix time when the recording should be deleted
+func (rm *recordingModel) recordingExpiresAt(roomSid string) int64 {
+	days := config.AppCnf.RecorderInfo.Retention.DefaultDays
+	if v, err := rm.rds.Get(rm.ctx, recordingRetentionKey+roomSid).Result(); err == nil {
+		days, _ = strconv.Atoi(v)
+	}
+	if days <= 0 {
+		return 0
+	}
+	return time.Now().AddDate(0, 0, days).Unix()
+}
+
+type expiredRecording struct {
+	recordId string
+	roomId   string
+	roomSid  string
+	filePath string
+	size     float32
+}
+
+// DeleteExpiredRecordings will remove files of expired recordings & soft delete those from DB.
+// Only one server will perform it at a time.
+func (s *scheduler) DeleteExpiredRecordings() {
+	locked, err := s.rc.SetNX(s.ctx, recordingRetentionLock, time.Now().Unix(), 30*time.Minute).Result()
+	if err != nil || !locked {
+		return
+	}
+	defer s.rc.Del(s.ctx, recordingRetentionLock)
+
+	app := config.AppCnf
+	for {
+		recordings, err := s.fetchExpiredRecordings()
+		if err != nil {
+			log.Errorln(err)
+			return
+		}
+
+		for _, r := range recordings {
+			if err = s.deleteExpiredRecording(r); err != nil {
+				log.Errorln("can't delete expired recording " + r.recordId + ": " + err.Error())
+				// we'll try again in next run
+				ctx, cancel := context.WithTimeout(s.ctx, 3*time.Second)
+				_, _ = app.DB.ExecContext(ctx, "UPDATE "+app.FormatDBTable("recordings")+" SET expires_at = ? WHERE record_id = ?", time.Now().Add(s.retentionCheckInterval()).Unix(), r.recordId)
+				cancel()
+			}
+		}
+
+		if len(recordings) < retentionBatchSize {
+			return
+		}
+	}
+}
+
+func (s *scheduler) fetchExpiredRecordings() ([]*expiredRecording, error) {
+	app := config.AppCnf
+	ctx, cancel := context.WithTimeout(s.ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := app.DB.QueryContext(ctx, "SELECT record_id, room_id, room_sid, file_path, size FROM "+app.FormatDBTable("recordings")+" WHERE deleted_at = 0 AND expires_at > 0 AND expires_at <= ? ORDER BY expires_at ASC LIMIT ?", time.Now().Unix(), retentionBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recordings []*expiredRecording
+	for rows.Next() {
+		r := new(expiredRecording)
+		var sid sql.NullString
+		if err = rows.Scan(&r.recordId, &r.roomId, &sid, &r.filePath, &r.size); err != nil {
+			return nil, err
+		}
+		r.roomSid = sid.String
+		recordings = append(recordings, r)
+	}
+
+	return recordings, rows.Err()
+}
+
+func (s *scheduler) deleteExpiredRecording(r *expiredRecording) error {
+	storage, err := recordingStorageFor(r.filePath)
+	if err != nil {
+		return err
+	}
+
+	if storage != nil {
+		ctx, cancel := context.WithTimeout(s.ctx, 30*time.Second)
+		defer cancel()
+		if err = storage.Delete(ctx, r.filePath); err != nil {
+			return err
+		}
+	} else {
+		path := localRecordingPath(r.filePath)
+		if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		_ = os.Remove(path + ".fiber.gz")
+	}
+	deleteRecordingThumbnails(r.recordId)
+	deleteRecordingChat(r.recordId)
+	deleteRecordingWhiteboard(r.recordId)
+	deleteRecordingRenditions(r.recordId)
+
+	app := config.AppCnf
+	ctx, cancel := context.WithTimeout(s.ctx, 3*time.Second)
+	defer cancel()
+	_, err = app.DB.ExecContext(ctx, "UPDATE "+app.FormatDBTable("recordings")+" SET deleted_at = ? WHERE record_id = ?", time.Now().Unix(), r.recordId)
+	if err != nil {
+		return err
+	}
+
+	event := recordingExpiredEvent
+	msg := &plugnmeet.CommonNotifyEvent{
+		Event: &event,
+		Room: &plugnmeet.NotifyEventRoom{
+			Sid:    &r.roomSid,
+			RoomId: &r.roomId,
+		},
+		RecordingInfo: &plugnmeet.RecordingInfoEvent{
+			RecordId: r.recordId,
+			FilePath: &r.filePath,
+			FileSize: &r.size,
+		},
+	}
+	if err = NewWebhookNotifier().Notify(r.roomSid, msg); err != nil {
+		log.Errorln(err)
+	}
+
+	return nil
+}
+
+func (s *scheduler) retentionCheckInterval() time.Duration {
+	if interval := config.AppCnf.RecorderInfo.Retention.CheckInterval; interval > 0 {
+		return interval
+	}
+	return defaultRetentionCheckInterval
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const defaultSignedUrlMaxValidity = 24 * time.Hour
+
+type GetSignedUrlReq struct {
+	RecordId string `json:"record_id" validate:"required"`
+	// ExpiresIn seconds, default recorder_info.token_validity
+	ExpiresIn int64 `json:"expires_in" validate:"min=0"`
+	// Playback will serve the file inline instead of attachment
+	Playback bool `json:"playback"`
+}
+
+type SignedUrlRes struct {
+	Url       string `json:"url"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// GetSignedUrl will return a short-lived url to download or play the recording.
+// The url doesn't expose file path of the recording, so it can be embedded.
+func (a *authRecording) GetSignedUrl(r *GetSignedUrlReq, baseUrl string) (*SignedUrlRes, error) {
+	// make sure recording exists
+	if _, err := a.FetchRecording(r.RecordId); err != nil {
+		return nil, err
+	}
+
+	maxValidity := a.app.RecorderInfo.SignedUrlMaxValidity
+	if maxValidity <= 0 {
+		maxValidity = defaultSignedUrlMaxValidity
+	}
+	validity := a.app.RecorderInfo.TokenValidity
+	if r.ExpiresIn > 0 {
+		validity = time.Duration(r.ExpiresIn) * time.Second
+	}
+	if validity > maxValidity {
+		return nil, errors.New(fmt.Sprintf("expires_in can't be more than %d seconds", int64(maxValidity.Seconds())))
+	}
+
+	expiresAt := time.Now().Add(validity).Unix()
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expiresAt, 10))
+	q.Set("sig", a.signRecordingUrl(r.RecordId, expiresAt))
+	if r.Playback {
+		q.Set("playback", "1")
+	}
+
+	return &SignedUrlRes{
+		Url:       fmt.Sprintf("%s/download/recording/signed/%s?%s", baseUrl, url.PathEscape(r.RecordId), q.Encode()),
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// VerifySignedUrl will validate signature & expiry then provide the file same as VerifyRecordingToken
+func (a *authRecording) VerifySignedUrl(recordId, expires, sig string) (file string, remote bool, err error) {
+	remaining, err := a.checkSignedUrl(recordId, expires, sig, time.Now())
+	if err != nil {
+		return "", false, err
+	}
+
+	recording, err := a.FetchRecording(recordId)
+	if err != nil {
+		return "", false, err
+	}
+
+	// remote url shouldn't live longer than the signed url
+	return a.resolveRecordingFile(recording.FilePath, remaining)
+}
+
+// checkSignedUrl will return the remaining validity if signature & expiry are valid
+func (a *authRecording) checkSignedUrl(recordId, expires, sig string, now time.Time) (time.Duration, error) {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid expires")
+	}
+	remaining := time.Unix(expiresAt, 0).Sub(now)
+	if remaining <= 0 {
+		return 0, errors.New("url has expired")
+	}
+
+	expected := a.signRecordingUrl(recordId, expiresAt)
+	if !hmac.Equal([]byte(expected), []byte(sig)) {
+		return 0, errors.New("invalid signature")
+	}
+
+	return remaining, nil
+}
+
+// signRecordingUrl will return HMAC-SHA256 of record id & expiry
+func (a *authRecording) signRecordingUrl(recordId string, expiresAt int64) string {
+	h := hmac.New(sha256.New, []byte(a.app.Client.Secret))
+	h.Write([]byte(recordId + ":" + strconv.FormatInt(expiresAt, 10)))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"testing"
+	"time"
+)
+
+func TestAuthRecording_SignRecordingUrl(t *testing.T) {
+	a := &authRecording{app: &config.AppConfig{Client: config.ClientInfo{Secret: "zumyyYWqv7KR2kUqvYdq4z4sXg7XTBD2ljT6"}}}
+
+	tests := []struct {
+		recordId  string
+		expiresAt int64
+		want      string
+	}{
+		// hmac sha256 of RM_abc123-1:1700000600
+		{"RM_abc123-1", 1700000600, "de47c9725ebc253ffe4851ee72f4df940705543d09257b68aa7137ae22ea4032"},
+	}
+
+	for _, tt := range tests {
+		got := a.signRecordingUrl(tt.recordId, tt.expiresAt)
+		if got != tt.want {
+			t.Errorf("signRecordingUrl(%s, %d) = %s, want %s", tt.recordId, tt.expiresAt, got, tt.want)
+		}
+	}
+}
+
+func TestAuthRecording_CheckSignedUrl(t *testing.T) {
+	a := &authRecording{app: &config.AppConfig{Client: config.ClientInfo{Secret: "zumyyYWqv7KR2kUqvYdq4z4sXg7XTBD2ljT6"}}}
+	now := time.Unix(1700000000, 0)
+	sig := "de47c9725ebc253ffe4851ee72f4df940705543d09257b68aa7137ae22ea4032"
+
+	tests := []struct {
+		name          string
+		recordId      string
+		expires       string
+		sig           string
+		now           time.Time
+		wantRemaining time.Duration
+		wantErr       string
+	}{
+		{"valid", "RM_abc123-1", "1700000600", sig, now, 10 * time.Minute, ""},
+		{"last second", "RM_abc123-1", "1700000600", sig, time.Unix(1700000599, 0), time.Second, ""},
+		{"expired", "RM_abc123-1", "1700000600", sig, time.Unix(1700000600, 0), 0, "url has expired"},
+		{"expires changed", "RM_abc123-1", "1700009999", sig, now, 0, "invalid signature"},
+		{"another recording", "RM_abc123-2", "1700000600", sig, now, 0, "invalid signature"},
+		{"empty signature", "RM_abc123-1", "1700000600", "", now, 0, "invalid signature"},
+		{"invalid expires", "RM_abc123-1", "soon", sig, now, 0, "invalid expires"},
+		{"empty expires", "RM_abc123-1", "", sig, now, 0, "invalid expires"},
+	}
+
+	for _, tt := range tests {
+		remaining, err := a.checkSignedUrl(tt.recordId, tt.expires, tt.sig, tt.now)
+		gotErr := ""
+		if err != nil {
+			gotErr = err.Error()
+		}
+		if gotErr != tt.wantErr || remaining != tt.wantRemaining {
+			t.Errorf("%s: checkSignedUrl() = %s, %q, want %s, %q", tt.name, remaining, gotErr, tt.wantRemaining, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RecordingStorageLocal = "local"
+	RecordingStorageS3    = "s3"
+	RecordingStorageGcs   = "gcs"
+	RecordingStorageAzure = "azure"
+
+	defaultStoragePartSize   = 8 << 20
+	defaultStorageMaxRetries = 3
+)
+
+var (
+	recordingStorages     = make(map[string]RecordingStorage)
+	recordingStoragesLock sync.Mutex
+)
+
+// RecordingStorage stores finished recordings.
+// Location of remote objects will be saved in file_path
+// using scheme of the driver, e.g. s3://bucket/key
+type RecordingStorage interface {
+	// Upload will upload local file & return location
+	Upload(ctx context.Context, localFile, key string) (string, error)
+	// Delete will remove the object of the location
+	Delete(ctx context.Context, location string) error
+	// DownloadUrl will return a temporary url to download the object
+	DownloadUrl(location string, expiry time.Duration) (string, error)
+}
+
+// newRecordingStorage will return storage of the configured driver
+func newRecordingStorage() (RecordingStorage, error) {
+	driver := config.AppCnf.RecorderInfo.Storage.Driver
+	if driver == "" || driver == RecordingStorageLocal {
+		return nil, nil
+	}
+	return getRecordingStorage(driver)
+}
+
+// recordingStorageFor will return storage of the location,
+// nil means the file is in local disk
+func recordingStorageFor(location string) (RecordingStorage, error) {
+	i := strings.Index(location, "://")
+	if i < 0 {
+		return nil, nil
+	}
+	return getRecordingStorage(location[:i])
+}
+
+// getRecordingStorage will create the driver once, so that
+// clients & credentials can be reused
+func getRecordingStorage(driver string) (RecordingStorage, error) {
+	recordingStoragesLock.Lock()
+	defer recordingStoragesLock.Unlock()
+
+	if s, ok := recordingStorages[driver]; ok {
+		return s, nil
+	}
+
+	var s RecordingStorage
+	var err error
+	conf := config.AppCnf.RecorderInfo.Storage
+	switch driver {
+	case RecordingStorageS3:
+		s, err = newS3Storage(conf.S3)
+	case RecordingStorageGcs:
+		s, err = newGcsStorage(conf.Gcs)
+	case RecordingStorageAzure:
+		s, err = newAzureStorage(conf.Azure)
+	default:
+		return nil, errors.New("unknown recording storage driver: " + driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	recordingStorages[driver] = s
+	return s, nil
+}
+
+// storagePartSize will return size of each part for multipart upload
+func storagePartSize() int64 {
+	if size := config.AppCnf.RecorderInfo.Storage.PartSize; size > 0 {
+		return size
+	}
+	return defaultStoragePartSize
+}
+
+type storageStatusError struct {
+	method string
+	status int
+	body   string
+}
+
+func (e *storageStatusError) Error() string {
+	return fmt.Sprintf("storage %s failed with status %d: %s", e.method, e.status, e.body)
+}
+
+// storageDo will send the request & return error for unexpected status.
+// Body of the response must be closed by the caller.
+func storageDo(client *http.Client, req *http.Request) (*http.Response, error) {
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= 300 {
+		defer res.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, &storageStatusError{
+			method: req.Method,
+			status: res.StatusCode,
+			body:   string(body),
+		}
+	}
+	return res, nil
+}
+
+// storageMaxRetries will return how many times a failed request will be retried
+func storageMaxRetries() int {
+	if n := config.AppCnf.RecorderInfo.Storage.MaxRetries; n > 0 {
+		return n
+	}
+	return defaultStorageMaxRetries
+}
+
+func localRecordingPath(filePath string) string {
+	return fmt.Sprintf("%s/%s", config.AppCnf.RecorderInfo.RecordingFilesPath, filePath)
+}
+
+// storeRecording will upload the recording to the configured storage
+// & update file_path of the recording to the object location
+func (rm *recordingModel) storeRecording(recordId, filePath string) (string, error) {
+	location, err := uploadRecordingFile(filePath)
+	if err != nil {
+		// we'll try again later, so the file won't be lost
+		queueRecordingUpload(&RecordingUploadJob{
+			RecordId: recordId,
+			FilePath: filePath,
+		}, err)
+		return filePath, err
+	}
+	if location == filePath {
+		return filePath, nil
+	}
+
+	dbCtx, dbCancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer dbCancel()
+	_, err = rm.db.ExecContext(dbCtx, "UPDATE "+rm.app.FormatDBTable("recordings")+" SET file_path = ? WHERE record_id = ?", location, recordId)
+	if err != nil {
+		return filePath, err
+	}
+
+	return location, nil
+}
+
+// uploadRecordingFile will upload the file to the configured storage & return location.
+// For local storage the file path will be returned as it is.
+func uploadRecordingFile(filePath string) (string, error) {
+	storage, err := newRecordingStorage()
+	if err != nil || storage == nil {
+		return filePath, err
+	}
+
+	localFile := localRecordingPath(filePath)
+	key := strings.TrimPrefix(filePath, "/")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 6*time.Hour)
+	defer cancel()
+	location, err := storage.Upload(ctx, localFile, key)
+	if err != nil {
+		return filePath, err
+	}
+
+	if config.AppCnf.RecorderInfo.Storage.DeleteLocal {
+		if err = os.Remove(localFile); err != nil {
+			log.Errorln(err)
+		}
+		_ = os.Remove(localFile + ".fiber.gz")
+	}
+
+	return location, nil
+}
+
+// deleteStoredFiles will remove additional files of the recording
+// from local disk or remote storage, errors will be logged only
+func deleteStoredFiles(ctx context.Context, files []string) {
+	for _, f := range files {
+		storage, err := recordingStorageFor(f)
+		if err != nil {
+			log.Errorln(err)
+			continue
+		}
+		if storage != nil {
+			err = storage.Delete(ctx, f)
+		} else if err = os.Remove(localRecordingPath(f)); os.IsNotExist(err) {
+			err = nil
+		}
+		if err != nil {
+			log.Errorln(err)
+		}
+	}
+}
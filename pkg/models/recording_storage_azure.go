@@ -0,0 +1,110 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"os"
+	"strings"
+	"time"
+)
+
+// azureStorage works with Azure Blob storage using shared key of the account.
+// Recordings are uploaded as block blob in multiple blocks.
+type azureStorage struct {
+	conf   config.AzureStorageConf
+	client *azblob.Client
+}
+
+func newAzureStorage(conf config.AzureStorageConf) (*azureStorage, error) {
+	if conf.AccountName == "" || conf.AccountKey == "" || conf.Container == "" {
+		return nil, errors.New("azure account_name, account_key & container are required")
+	}
+	cred, err := azblob.NewSharedKeyCredential(conf.AccountName, conf.AccountKey)
+	if err != nil {
+		return nil, errors.New("invalid azure account_key")
+	}
+
+	endpoint := conf.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", conf.AccountName)
+	}
+
+	client, err := azblob.NewClientWithSharedKeyCredential(strings.TrimSuffix(endpoint, "/")+"/", cred, &azblob.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Retry: policy.RetryOptions{
+				MaxRetries: int32(storageMaxRetries()),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &azureStorage{
+		conf:   conf,
+		client: client,
+	}, nil
+}
+
+func (a *azureStorage) Upload(ctx context.Context, localFile, key string) (string, error) {
+	f, err := os.Open(localFile)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	key = strings.TrimPrefix(a.conf.Prefix+"/"+key, "/")
+	opts := &azblob.UploadFileOptions{
+		BlockSize: storagePartSize(),
+	}
+	if a.conf.EncryptionScope != "" {
+		opts.CPKScopeInfo = &blob.CPKScopeInfo{
+			EncryptionScope: &a.conf.EncryptionScope,
+		}
+	}
+	if _, err = a.client.UploadFile(ctx, a.conf.Container, key, f, opts); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s://%s/%s", RecordingStorageAzure, a.conf.Container, key), nil
+}
+
+func (a *azureStorage) Delete(ctx context.Context, location string) error {
+	key, err := a.keyFromLocation(location)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.client.DeleteBlob(ctx, a.conf.Container, key, nil)
+	return err
+}
+
+// DownloadUrl will return url with read only SAS
+func (a *azureStorage) DownloadUrl(location string, expiry time.Duration) (string, error) {
+	key, err := a.keyFromLocation(location)
+	if err != nil {
+		return "", err
+	}
+
+	// small clock difference with server shouldn't fail the request
+	start := time.Now().Add(-5 * time.Minute).UTC()
+	return a.client.ServiceClient().NewContainerClient(a.conf.Container).NewBlobClient(key).
+		GetSASURL(sas.BlobPermissions{Read: true}, time.Now().Add(expiry).UTC(), &blob.GetSASURLOptions{
+			StartTime: &start,
+		})
+}
+
+func (a *azureStorage) keyFromLocation(location string) (string, error) {
+	prefix := fmt.Sprintf("%s://%s/", RecordingStorageAzure, a.conf.Container)
+	if !strings.HasPrefix(location, prefix) {
+		return "", errors.New("location doesn't belong to the configured container")
+	}
+	return strings.TrimPrefix(location, prefix), nil
+}
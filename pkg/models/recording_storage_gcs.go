@@ -0,0 +1,98 @@
+package models
+
+import (
+	"cloud.google.com/go/storage"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"google.golang.org/api/option"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// gcsStorage works with Google Cloud Storage using service account credentials.
+// Recordings are uploaded in chunks using resumable upload.
+type gcsStorage struct {
+	conf   config.GcsStorageConf
+	client *storage.Client
+}
+
+func newGcsStorage(conf config.GcsStorageConf) (*gcsStorage, error) {
+	if conf.Bucket == "" {
+		return nil, errors.New("gcs bucket is required")
+	}
+	if conf.CredentialsFile == "" {
+		return nil, errors.New("gcs credentials_file is required")
+	}
+
+	client, err := storage.NewClient(context.Background(), option.WithCredentialsFile(conf.CredentialsFile))
+	if err != nil {
+		return nil, err
+	}
+	// recordings are uploaded & deleted by us only, so retrying non-idempotent requests is safe
+	client.SetRetry(storage.WithPolicy(storage.RetryAlways))
+
+	return &gcsStorage{
+		conf:   conf,
+		client: client,
+	}, nil
+}
+
+func (g *gcsStorage) Upload(ctx context.Context, localFile, key string) (string, error) {
+	f, err := os.Open(localFile)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	key = strings.TrimPrefix(g.conf.Prefix+"/"+key, "/")
+	w := g.client.Bucket(g.conf.Bucket).Object(key).NewWriter(ctx)
+	// will be rounded up to multiple of 256 KiB by the client
+	w.ChunkSize = int(storagePartSize())
+	w.KMSKeyName = g.conf.KmsKeyName
+
+	if _, err = io.Copy(w, f); err != nil {
+		_ = w.Close()
+		return "", err
+	}
+	if err = w.Close(); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s://%s/%s", RecordingStorageGcs, g.conf.Bucket, key), nil
+}
+
+func (g *gcsStorage) Delete(ctx context.Context, location string) error {
+	key, err := g.keyFromLocation(location)
+	if err != nil {
+		return err
+	}
+
+	return g.client.Bucket(g.conf.Bucket).Object(key).Delete(ctx)
+}
+
+// DownloadUrl will return V4 signed url
+func (g *gcsStorage) DownloadUrl(location string, expiry time.Duration) (string, error) {
+	key, err := g.keyFromLocation(location)
+	if err != nil {
+		return "", err
+	}
+
+	return g.client.Bucket(g.conf.Bucket).SignedURL(key, &storage.SignedURLOptions{
+		Scheme:  storage.SigningSchemeV4,
+		Method:  http.MethodGet,
+		Expires: time.Now().Add(expiry),
+	})
+}
+
+func (g *gcsStorage) keyFromLocation(location string) (string, error) {
+	prefix := fmt.Sprintf("%s://%s/", RecordingStorageGcs, g.conf.Bucket)
+	if !strings.HasPrefix(location, prefix) {
+		return "", errors.New("location doesn't belong to the configured bucket")
+	}
+	return strings.TrimPrefix(location, prefix), nil
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"os"
+	"strings"
+	"time"
+)
+
+// s3Storage works with AWS S3 & compatible servers, e.g. MinIO.
+type s3Storage struct {
+	conf     config.S3StorageConf
+	client   *s3.Client
+	uploader *manager.Uploader
+	presign  *s3.PresignClient
+}
+
+func newS3Storage(conf config.S3StorageConf) (*s3Storage, error) {
+	if conf.Bucket == "" {
+		return nil, errors.New("s3 bucket is required")
+	}
+	if conf.Region == "" {
+		conf.Region = "us-east-1"
+	}
+
+	opts := s3.Options{
+		Region:           conf.Region,
+		Credentials:      credentials.NewStaticCredentialsProvider(conf.AccessKey, conf.SecretKey, ""),
+		UsePathStyle:     conf.UsePathStyle,
+		RetryMaxAttempts: storageMaxRetries() + 1,
+	}
+	if conf.Endpoint != "" {
+		opts.EndpointResolver = s3.EndpointResolverFunc(func(region string, _ s3.EndpointResolverOptions) (aws.Endpoint, error) {
+			return aws.Endpoint{
+				URL:           conf.Endpoint,
+				SigningRegion: region,
+				Source:        aws.EndpointSourceCustom,
+			}, nil
+		})
+	}
+	client := s3.New(opts)
+
+	return &s3Storage{
+		conf:   conf,
+		client: client,
+		uploader: manager.NewUploader(client, func(u *manager.Uploader) {
+			u.PartSize = storagePartSize()
+		}),
+		presign: s3.NewPresignClient(client),
+	}, nil
+}
+
+// Upload will use multipart upload for large files
+func (s *s3Storage) Upload(ctx context.Context, localFile, key string) (string, error) {
+	key = strings.TrimPrefix(s.conf.Prefix+"/"+key, "/")
+
+	f, err := os.Open(localFile)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.conf.Bucket),
+		Key:    aws.String(key),
+		Body:   f,
+	}
+	if s.conf.ServerSideEncryption != "" {
+		input.ServerSideEncryption = types.ServerSideEncryption(s.conf.ServerSideEncryption)
+	}
+	if _, err = s.uploader.Upload(ctx, input); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s://%s/%s", RecordingStorageS3, s.conf.Bucket, key), nil
+}
+
+func (s *s3Storage) Delete(ctx context.Context, location string) error {
+	key, err := s.keyFromLocation(location)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.conf.Bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+// DownloadUrl will return presigned url
+func (s *s3Storage) DownloadUrl(location string, expiry time.Duration) (string, error) {
+	key, err := s.keyFromLocation(location)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := s.presign.PresignGetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(s.conf.Bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
+
+func (s *s3Storage) keyFromLocation(location string) (string, error) {
+	prefix := fmt.Sprintf("%s://%s/", RecordingStorageS3, s.conf.Bucket)
+	if !strings.HasPrefix(location, prefix) {
+		return "", errors.New("location doesn't belong to the configured bucket")
+	}
+	return strings.TrimPrefix(location, prefix), nil
+}
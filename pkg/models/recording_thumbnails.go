@@ -0,0 +1,143 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ffmpegPath                = "/usr/bin/ffmpeg"
+	defaultThumbnailCount     = 3
+	defaultThumbnailWidth     = 320
+	defaultPosterWidth        = 1280
+	thumbnailFrameTimeout     = time.Minute
+	defaultThumbnailTimestamp = 1
+)
+
+// generateRecordingThumbnails will take frames from the recording using ffmpeg,
+// upload those to the storage same as recording & save locations in DB.
+// It should be called before uploading the recording, because local file may be deleted.
+func (rm *recordingModel) generateRecordingThumbnails(r *plugnmeet.RecorderToPlugNmeet, duration int64) {
+	conf := rm.app.RecorderInfo.Thumbnails
+	if !conf.Enabled {
+		return
+	}
+	if _, err := os.Stat(ffmpegPath); err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	count := conf.Count
+	if count <= 0 {
+		count = defaultThumbnailCount
+	}
+	width := conf.Width
+	if width <= 0 {
+		width = defaultThumbnailWidth
+	}
+	posterWidth := conf.PosterWidth
+	if posterWidth <= 0 {
+		posterWidth = defaultPosterWidth
+	}
+
+	// e.g. room/record_id.mp4 => room/record_id_thumb_1.jpg
+	prefix := strings.TrimSuffix(r.FilePath, path.Ext(r.FilePath))
+	video := localRecordingPath(r.FilePath)
+
+	var thumbnails []string
+	for i := 1; i <= count; i++ {
+		at := duration * int64(i) / int64(count+1)
+		if at <= 0 {
+			at = defaultThumbnailTimestamp
+		}
+		file := prefix + "_thumb_" + strconv.Itoa(i) + ".jpg"
+		if err := extractVideoFrame(video, localRecordingPath(file), at, width); err != nil {
+			log.Errorln(err)
+			continue
+		}
+		thumbnails = append(thumbnails, rm.storeThumbnail(file))
+	}
+
+	var poster string
+	file := prefix + "_poster.jpg"
+	if err := extractVideoFrame(video, localRecordingPath(file), duration/2, posterWidth); err != nil {
+		log.Errorln(err)
+	} else {
+		poster = rm.storeThumbnail(file)
+	}
+
+	if len(thumbnails) == 0 && poster == "" {
+		return
+	}
+	marshal, err := json.Marshal(thumbnails)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err = rm.db.ExecContext(ctx, "UPDATE "+rm.app.FormatDBTable("recordings")+" SET poster = ?, thumbnails = ? WHERE record_id = ?", poster, string(marshal), r.RecordingId)
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+// storeThumbnail will upload the image to the configured storage & return the location
+func (rm *recordingModel) storeThumbnail(file string) string {
+	location, err := uploadRecordingFile(file)
+	if err != nil {
+		log.Errorln(err)
+		return file
+	}
+	return location
+}
+
+// extractVideoFrame will save the frame of the position as jpeg image
+func extractVideoFrame(video, output string, at int64, width int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), thumbnailFrameTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, ffmpegPath, "-y", "-v", "error", "-ss", strconv.FormatInt(at, 10), "-i", video,
+		"-frames:v", "1", "-vf", fmt.Sprintf("scale=%d:-2", width), output)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return errors.New(fmt.Sprintf("ffmpeg failed: %s %s", err.Error(), strings.TrimSpace(string(out))))
+	}
+	return nil
+}
+
+// deleteRecordingThumbnails will remove images of the recording, errors will be logged only
+func deleteRecordingThumbnails(recordId string) {
+	app := config.AppCnf
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var poster, thumbnails sql.NullString
+	row := app.DB.QueryRowContext(ctx, "SELECT poster, thumbnails FROM "+app.FormatDBTable("recordings")+" WHERE record_id = ?", recordId)
+	if err := row.Scan(&poster, &thumbnails); err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	var files []string
+	if thumbnails.String != "" {
+		_ = json.Unmarshal([]byte(thumbnails.String), &files)
+	}
+	if poster.String != "" {
+		files = append(files, poster.String)
+	}
+
+	deleteStoredFiles(ctx, files)
+}
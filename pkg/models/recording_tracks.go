@@ -0,0 +1,356 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+	"github.com/livekit/protocol/livekit"
+	lksdk "github.com/livekit/server-sdk-go"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	RecordingModeComposite  = "composite"
+	RecordingModeIndividual = "individual"
+	RecordingModeBoth       = "both"
+
+	// trackRecordingKey keeps the running track recording of the session
+	trackRecordingKey = "pnm:track_recording:"
+	// trackEgressesKey keeps started track egresses of the session,
+	// those will be removed when livekit informs us that the egress has ended
+	trackEgressesKey    = "pnm:track_egresses:"
+	trackRecordingTTL   = 7 * 24 * time.Hour
+	trackRecorderId     = "livekit-egress"
+	trackProceededEvent = "recording_track_proceeded"
+)
+
+type trackRecordingSession struct {
+	RecordId string `json:"record_id"`
+	RoomId   string `json:"room_id"`
+	Mode     string `json:"mode"`
+}
+
+// RecordingTrack is an entry of the manifest of individual track recording
+type RecordingTrack struct {
+	RecordId  string  `json:"record_id"`
+	EgressId  string  `json:"egress_id"`
+	UserId    string  `json:"user_id"`
+	UserName  string  `json:"user_name"`
+	TrackSid  string  `json:"track_sid"`
+	Kind      string  `json:"kind"`
+	Source    string  `json:"source"`
+	FilePath  string  `json:"file_path"`
+	FileSize  float32 `json:"file_size"`
+	Duration  int64   `json:"duration"`
+	StartedAt int64   `json:"started_at"`
+	EndedAt   int64   `json:"ended_at"`
+}
+
+// recordingMode will return mode of the room. Room level value will get priority
+func (rm *recordingModel) recordingMode(roomId string) string {
+	if mode := rm.roomService.LoadRoomOptions(roomId).RecordingMode; mode != "" {
+		return mode
+	}
+	switch mode := config.AppCnf.RecorderInfo.TrackRecording.Mode; mode {
+	case RecordingModeIndividual, RecordingModeBoth:
+		return mode
+	}
+	return RecordingModeComposite
+}
+
+// IsIndividualRecordingMode will check if the composite recorder shouldn't be used for the room
+func (rm *recordingModel) IsIndividualRecordingMode(roomId string) bool {
+	return rm.recordingMode(roomId) == RecordingModeIndividual
+}
+
+func (rm *recordingModel) loadTrackRecordingSession(roomSid string) (*trackRecordingSession, error) {
+	result, err := rm.rds.Get(rm.ctx, trackRecordingKey+roomSid).Result()
+	if err == redis.Nil {
+		return nil, errors.New("no track recording is running")
+	} else if err != nil {
+		return nil, err
+	}
+
+	s := new(trackRecordingSession)
+	if err = json.Unmarshal([]byte(result), s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// StartIndividualRecording will start track egresses without the recorder.
+// It will be handled same as recorder has started the recording.
+func (rm *recordingModel) StartIndividualRecording(roomId, roomSid string) {
+	rm.HandleRecorderResp(&plugnmeet.RecorderToPlugNmeet{
+		From:        "plugnmeet",
+		Status:      true,
+		Task:        plugnmeet.RecordingTasks_START_RECORDING,
+		Msg:         "success",
+		RecordingId: roomSid + "-" + strconv.FormatInt(time.Now().UnixMilli(), 10),
+		RoomId:      roomId,
+		RoomSid:     roomSid,
+		RecorderId:  trackRecorderId,
+	})
+}
+
+// StopIndividualRecording will stop track egresses of the session
+func (rm *recordingModel) StopIndividualRecording(roomId, roomSid string) {
+	r := &plugnmeet.RecorderToPlugNmeet{
+		From:       "plugnmeet",
+		Status:     true,
+		Task:       plugnmeet.RecordingTasks_END_RECORDING,
+		Msg:        "success",
+		RoomId:     roomId,
+		RoomSid:    roomSid,
+		RecorderId: trackRecorderId,
+	}
+	if s, err := rm.loadTrackRecordingSession(roomSid); err == nil {
+		r.RecordingId = s.RecordId
+	}
+	rm.HandleRecorderResp(r)
+}
+
+// startTrackRecording will start egress for all published tracks if the mode requires it
+func (rm *recordingModel) startTrackRecording(r *plugnmeet.RecorderToPlugNmeet) {
+	mode := rm.recordingMode(r.RoomId)
+	if mode == RecordingModeComposite {
+		return
+	}
+
+	s := &trackRecordingSession{
+		RecordId: r.RecordingId,
+		RoomId:   r.RoomId,
+		Mode:     mode,
+	}
+	marshal, err := json.Marshal(s)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	// in case of retry from the recorder, we shouldn't start again
+	ok, err := rm.rds.SetNX(rm.ctx, trackRecordingKey+r.RoomSid, marshal, trackRecordingTTL).Result()
+	if err != nil || !ok {
+		return
+	}
+
+	participants, err := rm.roomService.LoadParticipants(r.RoomId)
+	if err != nil {
+		return
+	}
+	for _, p := range participants {
+		for _, t := range p.Tracks {
+			rm.startTrackEgress(s, r.RoomSid, p, t)
+		}
+	}
+}
+
+// TrackPublished will start egress for the new track if track recording is running
+func (rm *recordingModel) TrackPublished(roomSid string, p *livekit.ParticipantInfo, t *livekit.TrackInfo) {
+	if p == nil || t == nil {
+		return
+	}
+	s, err := rm.loadTrackRecordingSession(roomSid)
+	if err != nil {
+		return
+	}
+	rm.startTrackEgress(s, roomSid, p, t)
+}
+
+func (rm *recordingModel) startTrackEgress(s *trackRecordingSession, roomSid string, p *livekit.ParticipantInfo, t *livekit.TrackInfo) {
+	if t.Type == livekit.TrackType_DATA {
+		return
+	}
+
+	// livekit will add extension based on codec
+	filePath := fmt.Sprintf("%s/tracks/%s/%s_%s", roomSid, s.RecordId, p.Identity, t.Sid)
+	egressPath := strings.TrimSuffix(config.AppCnf.RecorderInfo.TrackRecording.EgressFilesPath, "/") + "/" + filePath
+
+	ctx, cancel := context.WithTimeout(rm.ctx, 10*time.Second)
+	defer cancel()
+	info, err := newEgressClient().StartTrackEgress(ctx, &livekit.TrackEgressRequest{
+		RoomName: s.RoomId,
+		TrackId:  t.Sid,
+		Output: &livekit.TrackEgressRequest_File{
+			File: &livekit.DirectFileOutput{
+				Filepath: egressPath,
+			},
+		},
+	})
+	if err != nil {
+		log.Errorln("can't start egress of track " + t.Sid + ": " + err.Error())
+		return
+	}
+
+	track := &RecordingTrack{
+		RecordId:  s.RecordId,
+		EgressId:  info.EgressId,
+		UserId:    p.Identity,
+		UserName:  p.Name,
+		TrackSid:  t.Sid,
+		Kind:      strings.ToLower(t.Type.String()),
+		Source:    strings.ToLower(t.Source.String()),
+		StartedAt: time.Now().Unix(),
+	}
+	marshal, err := json.Marshal(track)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	pp := rm.rds.Pipeline()
+	pp.HSet(rm.ctx, trackEgressesKey+roomSid, info.EgressId, marshal)
+	pp.Expire(rm.ctx, trackEgressesKey+roomSid, trackRecordingTTL)
+	if _, err = pp.Exec(rm.ctx); err != nil {
+		log.Errorln(err)
+	}
+}
+
+// stopTrackRecording will stop all running egresses of the session.
+// Manifest will be updated when livekit informs us that the egress has ended.
+func (rm *recordingModel) stopTrackRecording(roomSid string) {
+	deleted, err := rm.rds.Del(rm.ctx, trackRecordingKey+roomSid).Result()
+	if err != nil || deleted == 0 {
+		return
+	}
+
+	egresses, err := rm.rds.HKeys(rm.ctx, trackEgressesKey+roomSid).Result()
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	client := newEgressClient()
+	for _, id := range egresses {
+		ctx, cancel := context.WithTimeout(rm.ctx, 10*time.Second)
+		_, err = client.StopEgress(ctx, &livekit.StopEgressRequest{
+			EgressId: id,
+		})
+		cancel()
+		if err != nil {
+			// egress may already end as track was unpublished
+			log.Warnln("can't stop egress " + id + ": " + err.Error())
+		}
+	}
+}
+
+// TrackEgressEnded will add the file to the manifest of the recording
+func (rm *recordingModel) TrackEgressEnded(e *livekit.EgressInfo) {
+	if e == nil {
+		return
+	}
+
+	result, err := rm.rds.HGet(rm.ctx, trackEgressesKey+e.RoomId, e.EgressId).Result()
+	if err != nil {
+		// not started by us
+		return
+	}
+	rm.rds.HDel(rm.ctx, trackEgressesKey+e.RoomId, e.EgressId)
+
+	track := new(RecordingTrack)
+	if err = json.Unmarshal([]byte(result), track); err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	file := e.GetFile()
+	if file == nil || file.Filename == "" || e.Status == livekit.EgressStatus_EGRESS_FAILED {
+		log.Errorln(fmt.Sprintf("egress %s of track %s failed: %s", e.EgressId, track.TrackSid, e.Error))
+		return
+	}
+
+	egressPath := strings.TrimSuffix(config.AppCnf.RecorderInfo.TrackRecording.EgressFilesPath, "/") + "/"
+	track.FilePath = strings.TrimPrefix(file.Filename, egressPath)
+	track.FileSize = float32(file.Size) / (1024 * 1024)
+	track.Duration = int64(time.Duration(file.Duration).Seconds())
+	track.EndedAt = time.Now().Unix()
+
+	location, err := uploadRecordingFile(track.FilePath)
+	if err != nil {
+		log.Errorln(err)
+		queueRecordingUpload(&RecordingUploadJob{
+			RecordId: track.RecordId,
+			EgressId: track.EgressId,
+			FilePath: track.FilePath,
+		}, err)
+	}
+	track.FilePath = location
+
+	if err = rm.addRecordingTrack(track); err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	event := trackProceededEvent
+	msg := &plugnmeet.CommonNotifyEvent{
+		Event: &event,
+		Room: &plugnmeet.NotifyEventRoom{
+			Sid:    &e.RoomId,
+			RoomId: &e.RoomName,
+		},
+		Participant: &livekit.ParticipantInfo{
+			Identity: track.UserId,
+			Name:     track.UserName,
+		},
+		Track: &livekit.TrackInfo{
+			Sid:  track.TrackSid,
+			Type: livekit.TrackType(livekit.TrackType_value[strings.ToUpper(track.Kind)]),
+		},
+		RecordingInfo: &plugnmeet.RecordingInfoEvent{
+			RecordId:   track.RecordId,
+			RecorderId: trackRecorderId,
+			FilePath:   &track.FilePath,
+			FileSize:   &track.FileSize,
+		},
+	}
+	if err = NewWebhookNotifier().Notify(e.RoomId, msg); err != nil {
+		log.Errorln(err)
+	}
+}
+
+func (rm *recordingModel) addRecordingTrack(t *RecordingTrack) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := rm.db.ExecContext(ctx, "INSERT INTO "+rm.app.FormatDBTable("recording_tracks")+
+		" (record_id, egress_id, user_id, user_name, track_sid, kind, source, file_path, size, duration, started_at, ended_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		t.RecordId, t.EgressId, t.UserId, t.UserName, t.TrackSid, t.Kind, t.Source, t.FilePath, fmt.Sprintf("%.2f", t.FileSize), t.Duration, t.StartedAt, t.EndedAt)
+	return err
+}
+
+type GetRecordingTracksReq struct {
+	RecordId string `json:"record_id" validate:"required"`
+}
+
+// GetRecordingTracks will return manifest of individual track recording
+func (a *authRecording) GetRecordingTracks(r *GetRecordingTracksReq) ([]*RecordingTrack, error) {
+	ctx, cancel := context.WithTimeout(a.ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := a.db.QueryContext(ctx, "SELECT record_id, egress_id, user_id, user_name, track_sid, kind, source, file_path, size, duration, started_at, ended_at FROM "+a.app.FormatDBTable("recording_tracks")+" WHERE record_id = ? ORDER BY started_at ASC", r.RecordId)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("query error: %s", err.Error()))
+	}
+	defer rows.Close()
+
+	tracks := []*RecordingTrack{}
+	for rows.Next() {
+		t := new(RecordingTrack)
+		if err = rows.Scan(&t.RecordId, &t.EgressId, &t.UserId, &t.UserName, &t.TrackSid, &t.Kind, &t.Source, &t.FilePath, &t.FileSize, &t.Duration, &t.StartedAt, &t.EndedAt); err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, t)
+	}
+
+	return tracks, rows.Err()
+}
+
+func newEgressClient() *lksdk.EgressClient {
+	return lksdk.NewEgressClient(config.AppCnf.LivekitInfo.Host, config.AppCnf.LivekitInfo.ApiKey, config.AppCnf.LivekitInfo.Secret)
+}
@@ -0,0 +1,272 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	RenditionStatusProcessing = "processing"
+	RenditionStatusCompleted  = "completed"
+	RenditionStatusFailed     = "failed"
+	RenditionStatusSkipped    = "skipped"
+
+	// transcodeQueueKey is shared by all servers, so any of them can process the job
+	transcodeQueueKey       = "pnm:transcode_queue"
+	recordingTranscodeEvent = "recording_transcoded"
+	transcodeTimeout        = 6 * time.Hour
+)
+
+type transcodeJob struct {
+	RecordId string `json:"record_id"`
+	RoomId   string `json:"room_id"`
+	RoomSid  string `json:"room_sid"`
+	FilePath string `json:"file_path"`
+}
+
+// RecordingRendition is a transcoded variant of the recording
+type RecordingRendition struct {
+	Name     string  `json:"name"`
+	Width    int     `json:"width"`
+	Height   int     `json:"height"`
+	FilePath string  `json:"file_path"`
+	FileSize float64 `json:"file_size"`
+	Status   string  `json:"status"`
+}
+
+// enqueueTranscode will add the recording in queue if transcode was enabled
+func (rm *recordingModel) enqueueTranscode(r *plugnmeet.RecorderToPlugNmeet) {
+	conf := rm.app.RecorderInfo.Transcode
+	if !conf.Enabled || len(conf.Profiles) == 0 {
+		return
+	}
+
+	marshal, err := json.Marshal(&transcodeJob{
+		RecordId: r.RecordingId,
+		RoomId:   r.RoomId,
+		RoomSid:  r.RoomSid,
+		FilePath: r.FilePath,
+	})
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	if err = rm.rds.RPush(rm.ctx, transcodeQueueKey, marshal).Err(); err != nil {
+		log.Errorln(err)
+	}
+}
+
+// startTranscodeWorkers will start workers those will wait for jobs in the queue
+func (s *scheduler) startTranscodeWorkers() {
+	conf := config.AppCnf.RecorderInfo.Transcode
+	if !conf.Enabled || len(conf.Profiles) == 0 {
+		return
+	}
+	if _, err := os.Stat(ffmpegPath); err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	workers := conf.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go s.transcodeWorker()
+	}
+}
+
+func (s *scheduler) transcodeWorker() {
+	for {
+		result, err := s.rc.BLPop(s.ctx, 5*time.Second, transcodeQueueKey).Result()
+		if err != nil {
+			// redis.Nil means timeout, so we'll wait again
+			continue
+		}
+
+		job := new(transcodeJob)
+		if err = json.Unmarshal([]byte(result[1]), job); err != nil {
+			log.Errorln(err)
+			continue
+		}
+		NewRecordingModel().processTranscodeJob(job)
+	}
+}
+
+func (rm *recordingModel) processTranscodeJob(job *transcodeJob) {
+	input, err := transcodeInput(job.FilePath)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	_, sourceHeight := probeVideoResolution(input)
+
+	// file path may be the location of remote object
+	filePath := job.FilePath
+	if i := strings.Index(filePath, "://"); i > 0 {
+		filePath = filePath[i+3:]
+	}
+	prefix := strings.TrimSuffix(filePath, path.Ext(filePath))
+
+	for _, p := range rm.app.RecorderInfo.Transcode.Profiles {
+		rendition := &RecordingRendition{
+			Name:   p.Name,
+			Height: p.Height,
+			Status: RenditionStatusProcessing,
+		}
+		// we won't upscale
+		if sourceHeight > 0 && p.Height >= sourceHeight {
+			rendition.Status = RenditionStatusSkipped
+			rm.saveRecordingRendition(job.RecordId, rendition)
+			continue
+		}
+		rm.saveRecordingRendition(job.RecordId, rendition)
+
+		file := prefix + "_" + p.Name + ".mp4"
+		if err = transcodeRecording(input, localRecordingPath(file), p); err != nil {
+			log.Errorln(fmt.Sprintf("transcode of %s to %s failed: %s", job.RecordId, p.Name, err.Error()))
+			rendition.Status = RenditionStatusFailed
+			rm.saveRecordingRendition(job.RecordId, rendition)
+			continue
+		}
+
+		rendition.Width, rendition.Height = probeVideoResolution(localRecordingPath(file))
+		if stat, err := os.Stat(localRecordingPath(file)); err == nil {
+			rendition.FileSize = float64(stat.Size()) / (1024 * 1024)
+		}
+		rendition.FilePath, err = uploadRecordingFile(file)
+		if err != nil {
+			log.Errorln(err)
+		}
+		rendition.Status = RenditionStatusCompleted
+		rm.saveRecordingRendition(job.RecordId, rendition)
+	}
+
+	event := recordingTranscodeEvent
+	msg := &plugnmeet.CommonNotifyEvent{
+		Event: &event,
+		Room: &plugnmeet.NotifyEventRoom{
+			Sid:    &job.RoomSid,
+			RoomId: &job.RoomId,
+		},
+		RecordingInfo: &plugnmeet.RecordingInfoEvent{
+			RecordId: job.RecordId,
+			FilePath: &job.FilePath,
+		},
+	}
+	if err = NewWebhookNotifier().Notify(job.RoomSid, msg); err != nil {
+		log.Errorln(err)
+	}
+}
+
+// transcodeInput will return local file or temporary url of the remote object,
+// ffmpeg can read both
+func transcodeInput(filePath string) (string, error) {
+	storage, err := recordingStorageFor(filePath)
+	if err != nil {
+		return "", err
+	}
+	if storage == nil {
+		return localRecordingPath(filePath), nil
+	}
+	return storage.DownloadUrl(filePath, transcodeTimeout)
+}
+
+func transcodeRecording(input, output string, p config.TranscodeProfile) error {
+	if p.Height <= 0 {
+		return errors.New("height of the profile is required")
+	}
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return err
+	}
+
+	args := []string{"-y", "-v", "error", "-i", input, "-vf", fmt.Sprintf("scale=-2:%d", p.Height), "-c:v", "libx264", "-preset", "veryfast"}
+	if p.VideoBitrate != "" {
+		args = append(args, "-b:v", p.VideoBitrate)
+	}
+	args = append(args, "-c:a", "aac")
+	if p.AudioBitrate != "" {
+		args = append(args, "-b:a", p.AudioBitrate)
+	}
+	args = append(args, "-movflags", "+faststart", output)
+
+	ctx, cancel := context.WithTimeout(context.Background(), transcodeTimeout)
+	defer cancel()
+	if out, err := exec.CommandContext(ctx, ffmpegPath, args...).CombinedOutput(); err != nil {
+		_ = os.Remove(output)
+		return errors.New(fmt.Sprintf("ffmpeg failed: %s %s", err.Error(), strings.TrimSpace(string(out))))
+	}
+	return nil
+}
+
+func (rm *recordingModel) saveRecordingRendition(recordId string, r *RecordingRendition) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := rm.db.ExecContext(ctx, "INSERT INTO "+rm.app.FormatDBTable("recording_renditions")+
+		" (record_id, name, width, height, file_path, size, status) VALUES (?, ?, ?, ?, ?, ?, ?)"+
+		" ON DUPLICATE KEY UPDATE width = VALUES(width), height = VALUES(height), file_path = VALUES(file_path), size = VALUES(size), status = VALUES(status)",
+		recordId, r.Name, r.Width, r.Height, r.FilePath, fmt.Sprintf("%.2f", r.FileSize), r.Status)
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+// getRecordingRenditions will return variants of the recording
+func (a *authRecording) getRecordingRenditions(ctx context.Context, recordId string) ([]*RecordingRendition, error) {
+	rows, err := a.db.QueryContext(ctx, "SELECT name, width, height, file_path, size, status FROM "+a.app.FormatDBTable("recording_renditions")+" WHERE record_id = ? ORDER BY height DESC", recordId)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("query error: %s", err.Error()))
+	}
+	defer rows.Close()
+
+	renditions := []*RecordingRendition{}
+	for rows.Next() {
+		r := new(RecordingRendition)
+		if err = rows.Scan(&r.Name, &r.Width, &r.Height, &r.FilePath, &r.FileSize, &r.Status); err != nil {
+			return nil, err
+		}
+		renditions = append(renditions, r)
+	}
+
+	return renditions, rows.Err()
+}
+
+// deleteRecordingRenditions will remove files of the variants, errors will be logged only
+func deleteRecordingRenditions(recordId string) {
+	app := config.AppCnf
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	rows, err := app.DB.QueryContext(ctx, "SELECT file_path FROM "+app.FormatDBTable("recording_renditions")+" WHERE record_id = ? AND file_path != ''", recordId)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	var files []string
+	for rows.Next() {
+		var f string
+		if err = rows.Scan(&f); err == nil {
+			files = append(files, f)
+		}
+	}
+	rows.Close()
+
+	deleteStoredFiles(ctx, files)
+
+	_, err = app.DB.ExecContext(ctx, "DELETE FROM "+app.FormatDBTable("recording_renditions")+" WHERE record_id = ?", recordId)
+	if err != nil {
+		log.Errorln(err)
+	}
+}
@@ -0,0 +1,273 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	TranscriptStatusPending    = "pending"
+	TranscriptStatusProcessing = "processing"
+	TranscriptStatusCompleted  = "completed"
+	TranscriptStatusFailed     = "failed"
+
+	TranscriptionProviderWhisper = "whisper"
+	TranscriptionProviderAzure   = "azure"
+	TranscriptionProviderGoogle  = "google"
+
+	// transcriptionQueueKey is shared by all servers, so any of them can process the job
+	transcriptionQueueKey        = "pnm:transcription_queue"
+	transcriptReadyEvent         = "transcript_ready"
+	transcriptFailedEvent        = "transcript_failed"
+	defaultTranscriptionAttempts = 3
+	transcriptionTimeout         = 2 * time.Hour
+)
+
+// TranscriptionProvider converts audio of the recording to text
+type TranscriptionProvider interface {
+	Transcribe(ctx context.Context, job *transcriptionJob) (string, error)
+}
+
+type transcriptionJob struct {
+	RecordId string `json:"record_id"`
+	RoomId   string `json:"room_id"`
+	RoomSid  string `json:"room_sid"`
+	FilePath string `json:"file_path"`
+	Attempts int    `json:"attempts"`
+}
+
+func newTranscriptionProvider() (TranscriptionProvider, error) {
+	conf := config.AppCnf.RecorderInfo.Transcription
+	switch conf.Provider {
+	case TranscriptionProviderWhisper:
+		return newWhisperTranscriber(conf.Whisper)
+	case TranscriptionProviderAzure:
+		return newAzureTranscriber(conf.Azure)
+	case TranscriptionProviderGoogle:
+		return newGoogleTranscriber(conf.Google)
+	}
+	return nil, errors.New("unknown transcription provider: " + conf.Provider)
+}
+
+// enqueueTranscription will add the recording in queue if transcription was enabled
+func (rm *recordingModel) enqueueTranscription(r *plugnmeet.RecorderToPlugNmeet) {
+	if !config.AppCnf.RecorderInfo.Transcription.Enabled {
+		return
+	}
+
+	job := &transcriptionJob{
+		RecordId: r.RecordingId,
+		RoomId:   r.RoomId,
+		RoomSid:  r.RoomSid,
+		FilePath: r.FilePath,
+	}
+	if err := pushTranscriptionJob(job); err != nil {
+		log.Errorln(err)
+		return
+	}
+	updateTranscriptStatus(job.RecordId, TranscriptStatusPending, nil)
+}
+
+func pushTranscriptionJob(job *transcriptionJob) error {
+	marshal, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return config.AppCnf.RDS.RPush(context.Background(), transcriptionQueueKey, marshal).Err()
+}
+
+// startTranscriptionWorkers will start workers those will wait for jobs in the queue
+func (s *scheduler) startTranscriptionWorkers() {
+	conf := config.AppCnf.RecorderInfo.Transcription
+	if !conf.Enabled {
+		return
+	}
+	if _, err := newTranscriptionProvider(); err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	workers := conf.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go s.transcriptionWorker()
+	}
+}
+
+func (s *scheduler) transcriptionWorker() {
+	for {
+		result, err := s.rc.BLPop(s.ctx, 5*time.Second, transcriptionQueueKey).Result()
+		if err != nil {
+			// redis.Nil means timeout, so we'll wait again
+			continue
+		}
+
+		job := new(transcriptionJob)
+		if err = json.Unmarshal([]byte(result[1]), job); err != nil {
+			log.Errorln(err)
+			continue
+		}
+		processTranscriptionJob(job)
+	}
+}
+
+func processTranscriptionJob(job *transcriptionJob) {
+	updateTranscriptStatus(job.RecordId, TranscriptStatusProcessing, nil)
+
+	provider, err := newTranscriptionProvider()
+	if err != nil {
+		log.Errorln(err)
+		updateTranscriptStatus(job.RecordId, TranscriptStatusFailed, nil)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), transcriptionTimeout)
+	defer cancel()
+	transcript, err := provider.Transcribe(ctx, job)
+	if err != nil {
+		job.Attempts++
+		log.Errorln(fmt.Sprintf("transcription of %s failed, attempt %d: %s", job.RecordId, job.Attempts, err.Error()))
+
+		maxAttempts := config.AppCnf.RecorderInfo.Transcription.MaxAttempts
+		if maxAttempts <= 0 {
+			maxAttempts = defaultTranscriptionAttempts
+		}
+		if job.Attempts < maxAttempts {
+			updateTranscriptStatus(job.RecordId, TranscriptStatusPending, nil)
+			time.AfterFunc(time.Duration(job.Attempts)*time.Minute, func() {
+				if err := pushTranscriptionJob(job); err != nil {
+					log.Errorln(err)
+				}
+			})
+			return
+		}
+
+		updateTranscriptStatus(job.RecordId, TranscriptStatusFailed, nil)
+		sendTranscriptNotification(transcriptFailedEvent, job)
+		return
+	}
+
+	updateTranscriptStatus(job.RecordId, TranscriptStatusCompleted, &transcript)
+	sendTranscriptNotification(transcriptReadyEvent, job)
+}
+
+func updateTranscriptStatus(recordId, status string, transcript *string) {
+	app := config.AppCnf
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var err error
+	if transcript != nil {
+		_, err = app.DB.ExecContext(ctx, "UPDATE "+app.FormatDBTable("recordings")+" SET transcript_status = ?, transcript = ? WHERE record_id = ?", status, *transcript, recordId)
+	} else {
+		_, err = app.DB.ExecContext(ctx, "UPDATE "+app.FormatDBTable("recordings")+" SET transcript_status = ? WHERE record_id = ?", status, recordId)
+	}
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+func sendTranscriptNotification(event string, job *transcriptionJob) {
+	msg := &plugnmeet.CommonNotifyEvent{
+		Event: &event,
+		Room: &plugnmeet.NotifyEventRoom{
+			Sid:    &job.RoomSid,
+			RoomId: &job.RoomId,
+		},
+		RecordingInfo: &plugnmeet.RecordingInfoEvent{
+			RecordId: job.RecordId,
+			FilePath: &job.FilePath,
+		},
+	}
+	if err := NewWebhookNotifier().Notify(job.RoomSid, msg); err != nil {
+		log.Errorln(err)
+	}
+}
+
+// openRecordingFile will open local file or download it from the remote storage
+func openRecordingFile(ctx context.Context, filePath string) (io.ReadCloser, error) {
+	storage, err := recordingStorageFor(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if storage == nil {
+		return os.Open(localRecordingPath(filePath))
+	}
+
+	u, err := storage.DownloadUrl(filePath, transcriptionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := storageDo(http.DefaultClient, req)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// transcriptionDo will send the request & return body of the response
+func transcriptionDo(client *http.Client, req *http.Request) ([]byte, error) {
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 300 {
+		if len(body) > 1024 {
+			body = body[:1024]
+		}
+		return nil, errors.New(fmt.Sprintf("transcription request failed with status %d: %s", res.StatusCode, string(body)))
+	}
+	return body, nil
+}
+
+type DownloadTranscriptRes struct {
+	FileName   string
+	Transcript string
+}
+
+// GetTranscriptByToken will return transcript of the recording using download token
+func (a *authRecording) GetTranscriptByToken(token string) (*DownloadTranscriptRes, error) {
+	claims, err := a.parseRecordingToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(a.ctx, 3*time.Second)
+	defer cancel()
+
+	var status string
+	var transcript, recordId string
+	row := a.db.QueryRowContext(ctx, "SELECT record_id, transcript_status, COALESCE(transcript, '') FROM "+a.app.FormatDBTable("recordings")+" WHERE file_path = ? AND deleted_at = 0", claims.Subject)
+	if err = row.Scan(&recordId, &status, &transcript); err != nil {
+		return nil, errors.New("no info found")
+	}
+	if status != TranscriptStatusCompleted {
+		return nil, errors.New("transcript isn't available")
+	}
+
+	return &DownloadTranscriptRes{
+		FileName:   recordId + ".txt",
+		Transcript: transcript,
+	}, nil
+}
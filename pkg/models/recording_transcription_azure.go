@@ -0,0 +1,85 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"net/http"
+	"path"
+	"strings"
+)
+
+const azureSpeechApiVersion = "2024-11-15"
+
+// azureTranscriber uses fast transcription API of Azure AI Speech
+type azureTranscriber struct {
+	conf   config.AzureTranscribeConf
+	client *http.Client
+}
+
+func newAzureTranscriber(conf config.AzureTranscribeConf) (*azureTranscriber, error) {
+	if conf.Region == "" || conf.SubscriptionKey == "" {
+		return nil, errors.New("azure region & subscription_key are required for transcription")
+	}
+	return &azureTranscriber{
+		conf:   conf,
+		client: &http.Client{},
+	}, nil
+}
+
+func (a *azureTranscriber) Transcribe(ctx context.Context, job *transcriptionJob) (string, error) {
+	file, err := openRecordingFile(ctx, job.FilePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	// empty locales will let azure identify the language
+	definition := struct {
+		Locales []string `json:"locales,omitempty"`
+	}{}
+	if lang := config.AppCnf.RecorderInfo.Transcription.Language; lang != "" {
+		definition.Locales = []string{lang}
+	}
+	def, err := json.Marshal(definition)
+	if err != nil {
+		return "", err
+	}
+	body, contentType := multipartBody("audio", path.Base(job.FilePath), file, map[string]string{
+		"definition": string(def),
+	})
+
+	u := fmt.Sprintf("https://%s.api.cognitive.microsoft.com/speechtotext/transcriptions:transcribe?api-version=%s", a.conf.Region, azureSpeechApiVersion)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Ocp-Apim-Subscription-Key", a.conf.SubscriptionKey)
+
+	res, err := transcriptionDo(a.client, req)
+	if err != nil {
+		return "", err
+	}
+
+	out := struct {
+		CombinedPhrases []struct {
+			Text string `json:"text"`
+		} `json:"combinedPhrases"`
+	}{}
+	if err = json.Unmarshal(res, &out); err != nil {
+		return "", err
+	}
+
+	var texts []string
+	for _, p := range out.CombinedPhrases {
+		texts = append(texts, p.Text)
+	}
+	transcript := strings.TrimSpace(strings.Join(texts, "\n"))
+	if transcript == "" {
+		return "", errors.New("azure returned empty transcript")
+	}
+	return transcript, nil
+}
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	googleSpeechEndpoint = "https://speech.googleapis.com/v1"
+	// google accepts inline audio up to 10MB, bigger files must be in gcs
+	googleInlineAudioLimit = 10 << 20
+	googlePollInterval     = 10 * time.Second
+)
+
+// googleTranscriber uses long running recognition of Google Speech-to-Text v1
+type googleTranscriber struct {
+	conf   config.GoogleTranscribeConf
+	client *http.Client
+}
+
+func newGoogleTranscriber(conf config.GoogleTranscribeConf) (*googleTranscriber, error) {
+	if conf.ApiKey == "" {
+		return nil, errors.New("google api_key is required for transcription")
+	}
+	return &googleTranscriber{
+		conf:   conf,
+		client: &http.Client{},
+	}, nil
+}
+
+type googleRecognizeRes struct {
+	Results []struct {
+		Alternatives []struct {
+			Transcript string `json:"transcript"`
+		} `json:"alternatives"`
+	} `json:"results"`
+}
+
+func (g *googleTranscriber) Transcribe(ctx context.Context, job *transcriptionJob) (string, error) {
+	audio, err := g.audio(ctx, job.FilePath)
+	if err != nil {
+		return "", err
+	}
+
+	lang := config.AppCnf.RecorderInfo.Transcription.Language
+	if lang == "" {
+		// language is required by google
+		lang = "en-US"
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"config": map[string]interface{}{
+			"languageCode":               lang,
+			"enableAutomaticPunctuation": true,
+		},
+		"audio": audio,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, googleSpeechEndpoint+"/speech:longrunningrecognize?key="+url.QueryEscape(g.conf.ApiKey), bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := transcriptionDo(g.client, req)
+	if err != nil {
+		return "", err
+	}
+
+	op := struct {
+		Name string `json:"name"`
+	}{}
+	if err = json.Unmarshal(res, &op); err != nil {
+		return "", err
+	}
+	if op.Name == "" {
+		return "", errors.New("google didn't return operation")
+	}
+
+	result, err := g.wait(ctx, op.Name)
+	if err != nil {
+		return "", err
+	}
+
+	var texts []string
+	for _, r := range result.Results {
+		if len(r.Alternatives) > 0 {
+			texts = append(texts, strings.TrimSpace(r.Alternatives[0].Transcript))
+		}
+	}
+	transcript := strings.TrimSpace(strings.Join(texts, "\n"))
+	if transcript == "" {
+		return "", errors.New("google returned empty transcript")
+	}
+	return transcript, nil
+}
+
+// audio will use gcs uri if the recording is in gcs, otherwise content of the file
+func (g *googleTranscriber) audio(ctx context.Context, filePath string) (map[string]string, error) {
+	prefix := RecordingStorageGcs + "://"
+	if strings.HasPrefix(filePath, prefix) {
+		return map[string]string{
+			"uri": "gs://" + strings.TrimPrefix(filePath, prefix),
+		}, nil
+	}
+
+	file, err := openRecordingFile(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(io.LimitReader(file, googleInlineAudioLimit+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > googleInlineAudioLimit {
+		return nil, errors.New("recording is too large for google, use gcs storage")
+	}
+
+	return map[string]string{
+		"content": base64.StdEncoding.EncodeToString(content),
+	}, nil
+}
+
+// wait will poll the operation until it has been done
+func (g *googleTranscriber) wait(ctx context.Context, name string) (*googleRecognizeRes, error) {
+	u := googleSpeechEndpoint + "/operations/" + url.PathEscape(name) + "?key=" + url.QueryEscape(g.conf.ApiKey)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(googlePollInterval):
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		res, err := transcriptionDo(g.client, req)
+		if err != nil {
+			return nil, err
+		}
+
+		op := struct {
+			Done  bool `json:"done"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+			Response *googleRecognizeRes `json:"response"`
+		}{}
+		if err = json.Unmarshal(res, &op); err != nil {
+			return nil, err
+		}
+		if !op.Done {
+			continue
+		}
+		if op.Error != nil {
+			return nil, errors.New("google transcription failed: " + op.Error.Message)
+		}
+		if op.Response == nil {
+			return new(googleRecognizeRes), nil
+		}
+		return op.Response, nil
+	}
+}
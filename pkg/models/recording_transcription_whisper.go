@@ -0,0 +1,96 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// whisperTranscriber works with OpenAI & compatible servers,
+// e.g. self-hosted faster-whisper server
+type whisperTranscriber struct {
+	conf   config.WhisperTranscribeConf
+	client *http.Client
+}
+
+func newWhisperTranscriber(conf config.WhisperTranscribeConf) (*whisperTranscriber, error) {
+	if conf.Url == "" {
+		conf.Url = "https://api.openai.com/v1"
+	}
+	if conf.Model == "" {
+		conf.Model = "whisper-1"
+	}
+	return &whisperTranscriber{
+		conf:   conf,
+		client: &http.Client{},
+	}, nil
+}
+
+func (w *whisperTranscriber) Transcribe(ctx context.Context, job *transcriptionJob) (string, error) {
+	file, err := openRecordingFile(ctx, job.FilePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	fields := map[string]string{
+		"model":           w.conf.Model,
+		"response_format": "text",
+	}
+	if lang := config.AppCnf.RecorderInfo.Transcription.Language; lang != "" {
+		// whisper accepts ISO-639-1 code only
+		fields["language"] = strings.SplitN(lang, "-", 2)[0]
+	}
+	body, contentType := multipartBody("file", path.Base(job.FilePath), file, fields)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(w.conf.Url, "/")+"/audio/transcriptions", body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if w.conf.ApiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+w.conf.ApiKey)
+	}
+
+	res, err := transcriptionDo(w.client, req)
+	if err != nil {
+		return "", err
+	}
+	transcript := strings.TrimSpace(string(res))
+	if transcript == "" {
+		return "", errors.New("whisper returned empty transcript")
+	}
+	return transcript, nil
+}
+
+// multipartBody will stream the file as multipart form without loading it in memory
+func multipartBody(fileField, fileName string, file io.Reader, fields map[string]string) (io.Reader, string) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+
+	go func() {
+		for k, v := range fields {
+			if err := mw.WriteField(k, v); err != nil {
+				_ = pw.CloseWithError(err)
+				return
+			}
+		}
+		part, err := mw.CreateFormFile(fileField, fileName)
+		if err != nil {
+			_ = pw.CloseWithError(err)
+			return
+		}
+		if _, err = io.Copy(part, file); err != nil {
+			_ = pw.CloseWithError(err)
+			return
+		}
+		_ = pw.CloseWithError(mw.Close())
+	}()
+
+	return pr, mw.FormDataContentType()
+}
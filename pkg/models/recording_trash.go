@@ -0,0 +1,128 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRecordingTrashDays = 7
+	recordingTrashLock        = "pnm:recording_trash_lock"
+)
+
+type RestoreRecordingReq struct {
+	RecordId string `json:"record_id" validate:"required"`
+}
+
+func recordingTrashDays() int {
+	if days := config.AppCnf.RecorderInfo.TrashDays; days > 0 {
+		return days
+	}
+	return defaultRecordingTrashDays
+}
+
+// RestoreRecording will bring back the recording from trash.
+// Expired recordings can't be restored because files were removed.
+func (a *authRecording) RestoreRecording(r *RestoreRecordingReq) error {
+	ctx, cancel := context.WithTimeout(a.ctx, 3*time.Second)
+	defer cancel()
+
+	res, err := a.db.ExecContext(ctx, "UPDATE "+a.app.FormatDBTable("recordings")+" SET deleted_at = 0, purge_at = 0 WHERE record_id = ? AND deleted_at > 0 AND purge_at > 0", r.RecordId)
+	if err != nil {
+		return err
+	}
+	if affected, _ := res.RowsAffected(); affected == 0 {
+		return errors.New("no deleted recording found to restore")
+	}
+	return nil
+}
+
+// PurgeRecording will remove files of the recording & the record from DB permanently
+func (a *authRecording) PurgeRecording(recordId string) error {
+	ctx, cancel := context.WithTimeout(a.ctx, 3*time.Second)
+	defer cancel()
+
+	var filePath string
+	row := a.db.QueryRowContext(ctx, "SELECT file_path FROM "+a.app.FormatDBTable("recordings")+" WHERE record_id = ?", recordId)
+	if err := row.Scan(&filePath); err != nil {
+		return err
+	}
+
+	storage, err := recordingStorageFor(filePath)
+	if err != nil {
+		return err
+	}
+
+	if storage != nil {
+		sCtx, sCancel := context.WithTimeout(a.ctx, 30*time.Second)
+		defer sCancel()
+		if err = storage.Delete(sCtx, filePath); err != nil {
+			return err
+		}
+	} else {
+		path := localRecordingPath(filePath)
+
+		// delete main file
+		err = os.Remove(path)
+		if err != nil {
+			// if file not exist then we can delete it from record without showing any error
+			if !os.IsNotExist(err) {
+				ms := strings.SplitN(err.Error(), "/", -1)
+				return errors.New(ms[3])
+			}
+		}
+
+		// delete compressed, if any
+		_ = os.Remove(path + ".fiber.gz")
+	}
+
+	deleteRecordingThumbnails(recordId)
+	deleteRecordingChat(recordId)
+	deleteRecordingWhiteboard(recordId)
+	deleteRecordingRenditions(recordId)
+
+	// no error, so we'll delete record from DB
+	_, err = a.db.ExecContext(ctx, "DELETE FROM "+a.app.FormatDBTable("recordings")+" WHERE record_id = ?", recordId)
+	return err
+}
+
+// PurgeDeletedRecordings will permanently remove recordings those were in trash for trash days.
+// Only one server will perform it at a time.
+func (s *scheduler) PurgeDeletedRecordings() {
+	locked, err := s.rc.SetNX(s.ctx, recordingTrashLock, time.Now().Unix(), 30*time.Minute).Result()
+	if err != nil || !locked {
+		return
+	}
+	defer s.rc.Del(s.ctx, recordingTrashLock)
+
+	app := config.AppCnf
+	ctx, cancel := context.WithTimeout(s.ctx, 3*time.Second)
+	rows, err := app.DB.QueryContext(ctx, "SELECT record_id FROM "+app.FormatDBTable("recordings")+" WHERE deleted_at > 0 AND purge_at > 0 AND purge_at <= ? ORDER BY purge_at ASC LIMIT ?", time.Now().Unix(), retentionBatchSize)
+	if err != nil {
+		cancel()
+		log.Errorln(err)
+		return
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	_ = rows.Close()
+	cancel()
+
+	ra := NewRecordingAuth()
+	for _, id := range ids {
+		if err = ra.PurgeRecording(id); err != nil {
+			// we'll try again in next run
+			log.Errorln("can't purge deleted recording " + id + ": " + err.Error())
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"time"
+)
+
+const (
+	// recordingUploadRetryKey is a sorted set of failed uploads, score is the time of the next attempt
+	recordingUploadRetryKey = "pnm:recording_upload_retry"
+	// recordingUploadFailedKey keeps uploads those failed in all attempts
+	recordingUploadFailedKey  = "pnm:recording_upload_failed"
+	recordingUploadRetryLock  = "pnm:recording_upload_retry_lock"
+	defaultUploadAttempts     = 5
+	maxRecordingUploadsFailed = 1000
+	uploadRetryBaseDelay      = time.Minute
+	uploadRetryMaxDelay       = time.Hour
+)
+
+// RecordingUploadJob is a finished recording which couldn't be uploaded to the storage
+type RecordingUploadJob struct {
+	RecordId string `json:"record_id"`
+	// EgressId will be set for individual track recording
+	EgressId  string `json:"egress_id,omitempty"`
+	FilePath  string `json:"file_path"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error"`
+	FailedAt  int64  `json:"failed_at"`
+}
+
+func uploadAttempts() int {
+	if n := config.AppCnf.RecorderInfo.Storage.UploadAttempts; n > 0 {
+		return n
+	}
+	return defaultUploadAttempts
+}
+
+// uploadRetryDelay will double the delay for each attempt
+func uploadRetryDelay(attempts int) time.Duration {
+	delay := uploadRetryBaseDelay
+	for i := 1; i < attempts && delay < uploadRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > uploadRetryMaxDelay {
+		delay = uploadRetryMaxDelay
+	}
+	return delay
+}
+
+// queueRecordingUpload will schedule the job for retry
+// or move it to the failed list if all attempts have been used
+func queueRecordingUpload(job *RecordingUploadJob, uploadErr error) {
+	rc := config.AppCnf.RDS
+	ctx := context.Background()
+
+	job.Attempts++
+	job.LastError = uploadErr.Error()
+	job.FailedAt = time.Now().Unix()
+	marshal, err := json.Marshal(job)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	if job.Attempts >= uploadAttempts() {
+		log.Errorln("giving up upload of recording " + job.RecordId + " after " + strconv.Itoa(job.Attempts) + " attempts: " + job.LastError)
+		pp := rc.Pipeline()
+		pp.LPush(ctx, recordingUploadFailedKey, marshal)
+		pp.LTrim(ctx, recordingUploadFailedKey, 0, maxRecordingUploadsFailed-1)
+		if _, err = pp.Exec(ctx); err != nil {
+			log.Errorln(err)
+		}
+		return
+	}
+
+	next := time.Now().Add(uploadRetryDelay(job.Attempts))
+	err = rc.ZAdd(ctx, recordingUploadRetryKey, &redis.Z{
+		Score:  float64(next.Unix()),
+		Member: marshal,
+	}).Err()
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+// RetryRecordingUploads will be called by the scheduler to upload due jobs
+func (s *scheduler) RetryRecordingUploads() {
+	locked, err := s.rc.SetNX(s.ctx, recordingUploadRetryLock, time.Now().Unix(), time.Minute).Result()
+	if err != nil || !locked {
+		return
+	}
+	defer s.rc.Del(s.ctx, recordingUploadRetryLock)
+
+	jobs, err := s.rc.ZRangeByScore(s.ctx, recordingUploadRetryKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().Unix(), 10),
+	}).Result()
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	for _, data := range jobs {
+		// other server may have taken it already
+		if removed, _ := s.rc.ZRem(s.ctx, recordingUploadRetryKey, data).Result(); removed == 0 {
+			continue
+		}
+		job := new(RecordingUploadJob)
+		if err = json.Unmarshal([]byte(data), job); err != nil {
+			log.Errorln(err)
+			continue
+		}
+		go NewRecordingModel().retryRecordingUpload(job)
+	}
+}
+
+func (rm *recordingModel) retryRecordingUpload(job *RecordingUploadJob) {
+	location, err := uploadRecordingFile(job.FilePath)
+	if err != nil {
+		queueRecordingUpload(job, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if job.EgressId != "" {
+		_, err = rm.db.ExecContext(ctx, "UPDATE "+rm.app.FormatDBTable("recording_tracks")+" SET file_path = ? WHERE egress_id = ?", location, job.EgressId)
+	} else {
+		_, err = rm.db.ExecContext(ctx, "UPDATE "+rm.app.FormatDBTable("recordings")+" SET file_path = ? WHERE record_id = ?", location, job.RecordId)
+	}
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	log.Infoln("recording " + job.RecordId + " has been uploaded after " + strconv.Itoa(job.Attempts) + " failed attempts")
+}
+
+// GetFailedRecordingUploads will return uploads those failed in all attempts, latest first
+func (rm *recordingModel) GetFailedRecordingUploads() ([]*RecordingUploadJob, error) {
+	result, err := rm.rds.LRange(rm.ctx, recordingUploadFailedKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := []*RecordingUploadJob{}
+	for _, data := range result {
+		job := new(RecordingUploadJob)
+		if err = json.Unmarshal([]byte(data), job); err != nil {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+type RetryRecordingUploadReq struct {
+	RecordId string `json:"record_id" validate:"required"`
+}
+
+// RetryFailedRecordingUpload will move the failed upload back to the queue with fresh attempts
+func (rm *recordingModel) RetryFailedRecordingUpload(r *RetryRecordingUploadReq) error {
+	result, err := rm.rds.LRange(rm.ctx, recordingUploadFailedKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, data := range result {
+		job := new(RecordingUploadJob)
+		if err = json.Unmarshal([]byte(data), job); err != nil || job.RecordId != r.RecordId {
+			continue
+		}
+		if err = rm.rds.LRem(rm.ctx, recordingUploadFailedKey, 1, data).Err(); err != nil {
+			return err
+		}
+		found = true
+		job.Attempts = 0
+		go rm.retryRecordingUpload(job)
+	}
+	if !found {
+		return errors.New("no failed upload found for the recording")
+	}
+
+	return nil
+}
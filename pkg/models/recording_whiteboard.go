@@ -0,0 +1,62 @@
+package models
+
+import (
+	"context"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"path"
+	"strings"
+	"time"
+)
+
+// storeRecordingWhiteboard will write the final board of the session as pdf next to the recording,
+// upload it to the storage same as recording & save the location in DB.
+// It should be called before uploading the recording, because file path will be changed.
+func (rm *recordingModel) storeRecordingWhiteboard(r *plugnmeet.RecorderToPlugNmeet) {
+	conf := rm.app.Client.WhiteboardState
+	if !conf.Enabled || !conf.AttachToRecordings {
+		return
+	}
+
+	data, err := NewWhiteboardExportModel().RenderWhiteboardPdf(r.RoomId, r.RoomSid)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	if len(data) == 0 {
+		return
+	}
+
+	// e.g. room/record_id.mp4 => room/record_id_whiteboard.pdf
+	file := strings.TrimSuffix(r.FilePath, path.Ext(r.FilePath)) + "_whiteboard.pdf"
+	location, err := writeRecordingFile(file, data)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err = rm.db.ExecContext(ctx, "UPDATE "+rm.app.FormatDBTable("recordings")+" SET whiteboard = ? WHERE record_id = ?", location, r.RecordingId)
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+// deleteRecordingWhiteboard will remove whiteboard file of the recording, errors will be logged only
+func deleteRecordingWhiteboard(recordId string) {
+	app := config.AppCnf
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var file string
+	row := app.DB.QueryRowContext(ctx, "SELECT whiteboard FROM "+app.FormatDBTable("recordings")+" WHERE record_id = ?", recordId)
+	if err := row.Scan(&file); err != nil {
+		log.Errorln(err)
+		return
+	}
+	if file != "" {
+		deleteStoredFiles(ctx, []string{file})
+	}
+}
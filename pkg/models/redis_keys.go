@@ -0,0 +1,270 @@
+package models
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRoomKeyTTL       = 24 * time.Hour
+	defaultRoomKeyTTLMargin = 30 * time.Minute
+	// roomDynamicKeysKey + roomId is a set of the keys those names aren't based on room id only,
+	// e.g. respondents of a poll, so that we can refresh them without scanning
+	roomDynamicKeysKey = "pnm:room_dynamic_keys:"
+)
+
+// redisKeyFamilies holds the patterns of the keys which this server stores in redis
+var redisKeyFamilies = map[string]string{
+	"block_users_list":          BlockedUsersList + "*",
+	"block_ips_list":            BlockedIpsList + "*",
+	"participants_ip":           ParticipantsIpKey + "*",
+	"presence":                  participantsPresenceKey + "*",
+	"room_options":              roomOptionsKey + "*",
+	"polls":                     pollsKey + "*",
+	"breakout_rooms":            breakoutRoomKey + "*",
+	"etherpad":                  EtherpadKey + "*",
+	"speaker_queue":             speakerQueueKey + "*",
+	"raised_hands":              raisedHandsKey + "*",
+	"room_timeline":             roomTimelineKey + "*",
+	"room_end_reason":           roomEndReasonKey + "*",
+	"room_stats":                roomStatsKey + "*",
+	"single_use_tokens":         singleUseTokenKey + "*",
+	"verify_nonce":              verifyNonceKey + "*",
+	"request_nonce":             requestNonceKey + "*",
+	"active_identities":         activeIdentitiesKey + "*",
+	"webhook_queues":            webhookQueueKey + "*",
+	"webhook_pending_queues":    webhookPendingQueuesKey,
+	"webhook_queue_locks":       webhookQueueLockKey + "*",
+	"identity_clients":          identityClientsKey + "*",
+	"identity_replaces":         identityReplacesKey + "*",
+	"suffixed_identities":       suffixedIdentitiesKey + "*",
+	"host_joined":               hostJoinedKey + "*",
+	"waiting_for_host":          waitingForHostKey + "*",
+	"issued_tokens":             issuedTokensKey + "*",
+	"revoked_identities":        revokedIdentitiesKey + "*",
+	"rate_limit":                rateLimitKey + "*",
+	"recording_retention":       recordingRetentionKey + "*",
+	"recording_segments":        recordingSegmentsKey + "*",
+	"recording_tags":            recordingTagsKey + "*",
+	"recording_api_key":         recordingApiKeyKey + "*",
+	"recording_markers":         recordingMarkersKey + "*",
+	"recording_chat":            recordingChatKey + "*",
+	"recording_consent_pending": recordingConsentPendingKey + "*",
+	"recording_consents":        recordingConsentsKey + "*",
+	"track_recording":           trackRecordingKey + "*",
+	"track_egresses":            trackEgressesKey + "*",
+	"rtmp_destinations":         rtmpDestinationsKey + "*",
+	"rtmp_egress":               rtmpEgressKey + "*",
+	"hls_egress":                hlsEgressKey + "*",
+	"broadcast_health":          broadcastHealthKey + "*",
+	"broadcast_reconnect":       broadcastReconnectKey + "*",
+	"chat_mute":                 chatMuteKey + "*",
+	"chat_attachments":          chatAttachmentsKey + "*",
+	"chat_messages":             chatMessagesKey + "*",
+	"chat_translation_lang":     chatTranslationLangKey + "*",
+	"chat_translation":          chatTranslationKey + "*",
+	"chat_history":              chatHistoryKey + "*",
+	"chat_pins":                 chatPinsKey + "*",
+	"link_preview":              linkPreviewKey + "*",
+	"room_dynamic_keys":         roomDynamicKeysKey + "*",
+	"whiteboard":                whiteboardStateKey + "*",
+	"whiteboard_elements":       whiteboardElementsKey + "*",
+	"whiteboard_files":          whiteboardFilesKey + "*",
+	"whiteboard_conversion":     whiteboardConversionStatusKey + "*",
+	"whiteboard_draw":           whiteboardDrawKey + "*",
+	"screen_annotation":         screenAnnotationKey + "*",
+	"screen_annotations":        screenAnnotationsKey + "*",
+	"poll_deadlines":            pollDeadlinesKey,
+	"room_merge_ends":           roomMergeEndsKey,
+	"reactions":                 reactionsKey + "*",
+	"reactions_broadcast":       reactionsBroadcastLock + "*",
+	"chat_rate_limit":           chatRateLimitKey + "*",
+	"chat_rate_violations":      chatRateViolationsKey + "*",
+	"revoked_tokens":            revokedTokensKey,
+	"recorders":                 recordersKey,
+	"recorder_jobs":             recorderJobsKey + "*",
+	"transcription_queue":       transcriptionQueueKey,
+	"transcode_queue":           transcodeQueueKey,
+	"recording_post_process":    recordingPostProcessQueueKey,
+	"recording_upload_retry":    recordingUploadRetryKey,
+	"recording_upload_failed":   recordingUploadFailedKey,
+}
+
+// RoomKeyTTL will calculate how long the transient keys of a room should live.
+// If the room has duration then we'll use the remaining time, otherwise default value.
+// In both cases, the margin will be added.
+func (r *RoomService) RoomKeyTTL(roomId string) time.Duration {
+	ttl := config.AppCnf.RedisInfo.DefaultKeyTTL
+	if ttl == 0 {
+		ttl = defaultRoomKeyTTL
+	}
+	margin := config.AppCnf.RedisInfo.KeyTTLMargin
+	if margin == 0 {
+		margin = defaultRoomKeyTTLMargin
+	}
+
+	_, meta, err := r.LoadRoomWithMetadata(roomId)
+	if err == nil && meta.RoomFeatures.RoomDuration != nil && *meta.RoomFeatures.RoomDuration > 0 {
+		duration := time.Duration(*meta.RoomFeatures.RoomDuration) * time.Minute
+		if meta.StartedAt > 0 {
+			duration = time.Until(time.Unix(int64(meta.StartedAt), 0).Add(duration))
+		}
+		if duration > 0 {
+			ttl = duration
+		}
+	}
+
+	return ttl + margin
+}
+
+// SetRoomKeysExpiry will set expiry to the provided keys of the room
+func (r *RoomService) SetRoomKeysExpiry(roomId string, keys ...string) {
+	ttl := r.RoomKeyTTL(roomId)
+
+	pp := r.rc.Pipeline()
+	for _, k := range keys {
+		pp.Expire(r.ctx, k, ttl)
+	}
+	_, err := pp.Exec(r.ctx)
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+// RefreshRoomKeysExpiry will extend expiry of all the transient keys of an active room
+// otherwise, keys of a long-running session may expire before the session end
+func (r *RoomService) RefreshRoomKeysExpiry(roomId string) {
+	keys := []string{
+		BlockedUsersList + roomId,
+		BlockedIpsList + roomId,
+		ParticipantsIpKey + roomId,
+		participantsPresenceKey + roomId,
+		roomOptionsKey + roomId,
+		pollsKey + roomId,
+		breakoutRoomKey + roomId,
+		speakerQueueKey + roomId,
+		raisedHandsKey + roomId,
+		roomTimelineKey + roomId,
+		roomStatsKey + roomId,
+		activeIdentitiesKey + roomId,
+		identityClientsKey + roomId,
+		identityReplacesKey + roomId,
+		suffixedIdentitiesKey + roomId,
+		hostJoinedKey + roomId,
+		waitingForHostKey + roomId,
+		chatMessagesKey + roomId,
+		chatTranslationLangKey + roomId,
+		chatHistoryKey + roomId,
+		chatPinsKey + roomId,
+		whiteboardStateKey + roomId,
+		whiteboardFilesKey + roomId,
+		whiteboardDrawKey + roomId,
+		screenAnnotationKey + roomId,
+		screenAnnotationsKey + roomId,
+		roomDynamicKeysKey + roomId,
+	}
+
+	dynamicKeys, err := r.rc.SMembers(r.ctx, roomDynamicKeysKey+roomId).Result()
+	if err != nil {
+		log.Errorln(err)
+	}
+	keys = append(keys, dynamicKeys...)
+
+	r.SetRoomKeysExpiry(roomId, keys...)
+}
+
+// releaseRedisLockScript will delete the lock only if it's still owned by the token,
+// otherwise a slow holder may delete the lock acquired by another one after expiry
+var releaseRedisLockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func releaseRedisLock(ctx context.Context, rc *redis.Client, key, token string) {
+	if err := releaseRedisLockScript.Run(ctx, rc, []string{key}, token).Err(); err != nil {
+		log.Errorln(err)
+	}
+}
+
+// trackRoomDynamicKeys will add the keys in the set of the room within the pipeline.
+// Keys those don't exist anymore will be ignored by expire, so we don't need to remove them.
+func (r *RoomService) trackRoomDynamicKeys(pp redis.Pipeliner, roomId string, ttl time.Duration, keys ...string) {
+	members := make([]interface{}, len(keys))
+	for i, k := range keys {
+		members[i] = k
+	}
+	pp.SAdd(r.ctx, roomDynamicKeysKey+roomId, members...)
+	pp.Expire(r.ctx, roomDynamicKeysKey+roomId, ttl)
+}
+
+func (r *RoomService) DeleteRoomDynamicKeys(roomId string) (int64, error) {
+	return r.rc.Del(r.ctx, roomDynamicKeysKey+roomId).Result()
+}
+
+type RedisKeyFamilyUsage struct {
+	Family string `json:"family"`
+	Keys   int64  `json:"keys"`
+	Memory int64  `json:"memory"`
+}
+
+type RedisMemoryUsage struct {
+	UsedMemory int64                  `json:"used_memory"`
+	Families   []*RedisKeyFamilyUsage `json:"families"`
+}
+
+type redisUsageModel struct {
+	rc  *redis.Client
+	ctx context.Context
+}
+
+func NewRedisUsageModel() *redisUsageModel {
+	return &redisUsageModel{
+		rc:  config.AppCnf.RDS,
+		ctx: context.Background(),
+	}
+}
+
+// GetMemoryUsage will count keys & memory in bytes per key family
+func (m *redisUsageModel) GetMemoryUsage() (*RedisMemoryUsage, error) {
+	res := new(RedisMemoryUsage)
+
+	info, err := m.rc.Info(m.ctx, "memory").Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range strings.Split(info, "\r\n") {
+		if strings.HasPrefix(l, "used_memory:") {
+			res.UsedMemory, _ = strconv.ParseInt(strings.TrimPrefix(l, "used_memory:"), 10, 64)
+			break
+		}
+	}
+
+	for family, pattern := range redisKeyFamilies {
+		u := &RedisKeyFamilyUsage{
+			Family: family,
+		}
+
+		iter := m.rc.Scan(m.ctx, 0, pattern, 100).Iterator()
+		for iter.Next(m.ctx) {
+			u.Keys++
+			mem, err := m.rc.MemoryUsage(m.ctx, iter.Val()).Result()
+			if err == nil {
+				u.Memory += mem
+			}
+		}
+		if err = iter.Err(); err != nil {
+			log.Errorln(err)
+		}
+
+		res.Families = append(res.Families, u)
+	}
+
+	return res, nil
+}
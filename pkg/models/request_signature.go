@@ -0,0 +1,84 @@
+package models
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRequestMaxSkew = 5 * time.Minute
+	// requestNonceKey + apiKey:nonce will be kept till the timestamp can be accepted
+	requestNonceKey     = "pnm:request_nonce:"
+	maxRequestNonceSize = 128
+)
+
+// RequestSignaturePayload will return the data which should be signed.
+// Without timestamp, only the body will be signed for backward compatibility,
+// otherwise method, path with query string, timestamp, nonce & body separated by new line.
+func RequestSignaturePayload(method, path, timestamp, nonce string, body []byte) []byte {
+	if timestamp == "" {
+		return body
+	}
+	payload := []byte(strings.Join([]string{strings.ToUpper(method), path, timestamp, nonce}, "\n") + "\n")
+	return append(payload, body...)
+}
+
+// VerifyRequestSignature will check HASH-SIGNATURE of the request.
+// Signature is hex encoded hmac sha256 of the payload using the secret.
+func VerifyRequestSignature(secret string, payload []byte, signature string) bool {
+	if signature == "" {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	expectedSignature := hex.EncodeToString(mac.Sum(nil))
+
+	return subtle.ConstantTimeCompare([]byte(expectedSignature), []byte(signature)) == 1
+}
+
+// ConsumeRequestNonce will reject the nonce if it was used within the max skew,
+// so that a captured request can't be replayed even within the allowed time
+func ConsumeRequestNonce(apiKey, nonce string, maxSkew time.Duration) error {
+	if nonce == "" || len(nonce) > maxRequestNonceSize {
+		return errors.New("missing or invalid hash nonce")
+	}
+	if maxSkew == 0 {
+		maxSkew = defaultRequestMaxSkew
+	}
+
+	// timestamp can be older or newer within max skew
+	ok, err := config.AppCnf.RDS.SetNX(context.Background(), requestNonceKey+apiKey+":"+nonce, time.Now().Unix(), 2*maxSkew).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("hash nonce was already used")
+	}
+	return nil
+}
+
+// IsValidRequestTimestamp will check HASH-TIMESTAMP (unix seconds) is within max skew,
+// so that captured requests can't be replayed later
+func IsValidRequestTimestamp(timestamp string, maxSkew time.Duration, now time.Time) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if maxSkew == 0 {
+		maxSkew = defaultRequestMaxSkew
+	}
+
+	diff := now.Sub(time.Unix(ts, 0))
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= maxSkew
+}
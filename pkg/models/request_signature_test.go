@@ -0,0 +1,78 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVerifyRequestSignature(t *testing.T) {
+	secret := "zumyyYWqv7KR2kUqvYdq4z4sXg7XTBD2ljT6"
+	body := []byte(`{"room_id":"room01"}`)
+	path := "/auth/room/isRoomActive"
+	// hmac sha256 of body & POST\n/auth/room/isRoomActive\n1700000000\nn0nce\nbody
+	signed := "4b60a468df680c0739f6c7255626b31c8934efc1293b9e40667575b964bf24b5"
+	signedWithTimestamp := "0b522c1447b7ec8d04b8f72c4ad72579ab3286b95d10d1ac57929e9f9b891f45"
+
+	tests := []struct {
+		name      string
+		secret    string
+		method    string
+		path      string
+		body      []byte
+		timestamp string
+		nonce     string
+		signature string
+		want      bool
+	}{
+		{"valid without timestamp", secret, "POST", path, body, "", "", signed, true},
+		{"valid with timestamp", secret, "POST", path, body, "1700000000", "n0nce", signedWithTimestamp, true},
+		{"lowercase method", secret, "post", path, body, "1700000000", "n0nce", signedWithTimestamp, true},
+		{"empty signature", secret, "POST", path, body, "", "", "", false},
+		{"uppercase signature", secret, "POST", path, body, "", "", "4B60A468DF680C0739F6C7255626B31C8934EFC1293B9E40667575B964BF24B5", false},
+		{"timestamp changed", secret, "POST", path, body, "1700000001", "n0nce", signedWithTimestamp, false},
+		{"nonce changed", secret, "POST", path, body, "1700000000", "n0nce2", signedWithTimestamp, false},
+		{"method changed", secret, "GET", path, body, "1700000000", "n0nce", signedWithTimestamp, false},
+		{"path changed", secret, "POST", "/auth/room/endRoom", body, "1700000000", "n0nce", signedWithTimestamp, false},
+		{"query added", secret, "POST", path + "?room_id=room02", body, "1700000000", "n0nce", signedWithTimestamp, false},
+		{"timestamp not signed", secret, "POST", path, body, "1700000000", "n0nce", signed, false},
+		{"timestamp removed", secret, "POST", path, body, "", "", signedWithTimestamp, false},
+		{"body changed", secret, "POST", path, []byte(`{"room_id":"room02"}`), "", "", signed, false},
+		{"another secret", "another-secret", "POST", path, body, "", "", signed, false},
+	}
+
+	for _, tt := range tests {
+		payload := RequestSignaturePayload(tt.method, tt.path, tt.timestamp, tt.nonce, tt.body)
+		got := VerifyRequestSignature(tt.secret, payload, tt.signature)
+		if got != tt.want {
+			t.Errorf("%s: VerifyRequestSignature() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsValidRequestTimestamp(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		timestamp string
+		maxSkew   time.Duration
+		want      bool
+	}{
+		{"1700000000", 0, true},
+		{"1699999700", 0, true},
+		{"1699999699", 0, false},
+		{"1700000300", 0, true},
+		{"1700000301", 0, false},
+		{"1699999990", 10 * time.Second, true},
+		{"1699999989", 10 * time.Second, false},
+		{"", 0, false},
+		{"1700000000.5", 0, false},
+		{"abc", 0, false},
+	}
+
+	for _, tt := range tests {
+		got := IsValidRequestTimestamp(tt.timestamp, tt.maxSkew, now)
+		if got != tt.want {
+			t.Errorf("IsValidRequestTimestamp(%s, %s) = %v, want %v", tt.timestamp, tt.maxSkew, got, tt.want)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package models
 
 import (
-	"github.com/goccy/go-json"
 	"github.com/livekit/protocol/livekit"
 	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
 	"github.com/mynaparrot/plugnmeet-protocol/utils"
@@ -21,7 +20,7 @@ func NewRoomAuthModel() *roomAuthModel {
 	}
 }
 
-func (am *roomAuthModel) CreateRoom(r *plugnmeet.CreateRoomReq) (bool, string, *livekit.Room) {
+func (am *roomAuthModel) CreateRoom(r *plugnmeet.CreateRoomReq, opts *RoomOptions) (bool, string, *livekit.Room) {
 	roomDbInfo, _ := am.rm.GetRoomInfo(r.RoomId, "", 1)
 
 	if roomDbInfo.Id > 0 {
@@ -38,6 +37,11 @@ func (am *roomAuthModel) CreateRoom(r *plugnmeet.CreateRoomReq) (bool, string, *
 		// we can just update the DB row. No need to create new one
 	}
 
+	err := ValidateRoomMetadata(r.Metadata)
+	if err != nil {
+		return false, err.Error(), nil
+	}
+
 	// we'll set default values otherwise client got confused if data is missing
 	utils.PrepareDefaultRoomFeatures(r)
 	utils.SetCreateRoomDefaultValues(r, config.AppCnf.UploadFileSettings.MaxSize, config.AppCnf.UploadFileSettings.AllowedTypes, config.AppCnf.SharedNotePad.Enabled)
@@ -53,12 +57,20 @@ func (am *roomAuthModel) CreateRoom(r *plugnmeet.CreateRoomReq) (bool, string, *
 		r.Metadata.CopyrightConf = config.AppCnf.Client.CopyrightConf
 	}
 
-	meta, err := json.Marshal(r.Metadata)
+	if opts == nil {
+		opts = new(RoomOptions)
+	}
+	opts.prepareViewerLimits(r)
+	meta, err := marshalRoomMetadata(r.Metadata, opts)
 	if err != nil {
 		return false, "Error: " + err.Error(), nil
 	}
 
-	room, err := am.rs.CreateRoom(r.RoomId, r.EmptyTimeout, r.MaxParticipants, string(meta))
+	nodeId := opts.ResolveNodeId()
+	// record the placement
+	opts.NodeId = nodeId
+
+	room, err := am.rs.CreateRoom(r.RoomId, r.EmptyTimeout, r.MaxParticipants, string(meta), nodeId)
 	if err != nil {
 		return false, "Error: " + err.Error(), nil
 	}
@@ -92,6 +104,11 @@ func (am *roomAuthModel) CreateRoom(r *plugnmeet.CreateRoomReq) (bool, string, *
 		return false, "Error: " + err.Error(), nil
 	}
 
+	err = am.rs.SaveRoomOptions(r.RoomId, opts)
+	if err != nil {
+		return false, "Error: " + err.Error(), nil
+	}
+
 	return true, "room created", room
 }
 
@@ -182,14 +199,14 @@ func (am *roomAuthModel) GetActiveRoomsInfo() (bool, string, []*plugnmeet.Active
 	return true, "success", res
 }
 
-func (am *roomAuthModel) EndRoom(r *plugnmeet.RoomEndReq) (bool, string) {
+func (am *roomAuthModel) EndRoom(r *plugnmeet.RoomEndReq, reason string) (bool, string) {
 	roomDbInfo, _ := am.rm.GetRoomInfo(r.RoomId, "", 1)
 
 	if roomDbInfo.Id == 0 {
 		return false, "room not active"
 	}
 
-	_, err := am.rs.EndRoom(r.RoomId)
+	_, err := am.rs.EndRoomWithReason(r.RoomId, reason)
 	if err != nil {
 		return false, "can't end room"
 	}
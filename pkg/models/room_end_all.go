@@ -0,0 +1,101 @@
+package models
+
+import (
+	"errors"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"sync"
+)
+
+const endAllRoomsWorkers = 10
+
+type EndAllRoomsReq struct {
+	RoomIds []string `json:"room_ids"`
+	// Tags will match rooms those were created with any of the tags
+	Tags []string `json:"tags"`
+}
+
+type EndRoomResult struct {
+	RoomId string `json:"room_id"`
+	Status bool   `json:"status"`
+	Msg    string `json:"msg"`
+}
+
+// EndAllRooms will end the requested rooms concurrently & return result for each room
+func (am *roomAuthModel) EndAllRooms(r *EndAllRoomsReq, reason string) ([]*EndRoomResult, error) {
+	if len(r.RoomIds) == 0 && len(r.Tags) == 0 {
+		return nil, errors.New("room_ids or tags required")
+	}
+
+	roomIds := r.RoomIds
+	if len(r.Tags) > 0 {
+		ids, err := am.findActiveRoomIdsByTags(r.Tags)
+		if err != nil {
+			return nil, err
+		}
+		roomIds = append(roomIds, ids...)
+	}
+	roomIds = uniqueStrings(roomIds)
+
+	results := make([]*EndRoomResult, len(roomIds))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	workers := endAllRoomsWorkers
+	if len(roomIds) < workers {
+		workers = len(roomIds)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				status, msg := am.EndRoom(&plugnmeet.RoomEndReq{
+					RoomId: roomIds[i],
+				}, reason)
+				results[i] = &EndRoomResult{
+					RoomId: roomIds[i],
+					Status: status,
+					Msg:    msg,
+				}
+			}
+		}()
+	}
+
+	for i := range roomIds {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results, nil
+}
+
+func (am *roomAuthModel) findActiveRoomIdsByTags(tags []string) ([]string, error) {
+	rooms, err := am.rm.GetActiveRoomsInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, ri := range rooms {
+		opts := am.rs.LoadRoomOptions(ri.RoomId)
+		if opts.HasAnyTag(tags) {
+			ids = append(ids, ri.RoomId)
+		}
+	}
+
+	return ids, nil
+}
+
+func uniqueStrings(s []string) []string {
+	seen := make(map[string]bool)
+	var res []string
+	for _, v := range s {
+		if v == "" || seen[v] {
+			continue
+		}
+		seen[v] = true
+		res = append(res, v)
+	}
+	return res
+}
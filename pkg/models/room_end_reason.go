@@ -0,0 +1,60 @@
+package models
+
+import (
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	log "github.com/sirupsen/logrus"
+)
+
+const roomEndReasonKey = "pnm:room_end_reason:"
+
+const (
+	RoomEndReasonDurationExpired  = "duration_expired"
+	RoomEndReasonEndedByModerator = "ended_by_moderator"
+	RoomEndReasonEndedByApi       = "ended_by_api"
+	RoomEndReasonParentRoomEnded  = "parent_room_ended"
+	RoomEndReasonMerged           = "merged"
+	// RoomEndReasonIdleTimeout will be used if room was closed by livekit
+	// because of empty timeout or any other reason which we don't know
+	RoomEndReasonIdleTimeout = "idle_timeout"
+)
+
+type RoomEndReason struct {
+	Reason string `json:"reason"`
+	RoomExitUrls
+}
+
+// roomFinishedNotifyEvent will add end reason with common notify event
+type roomFinishedNotifyEvent struct {
+	*plugnmeet.CommonNotifyEvent
+	EndReason string `json:"end_reason"`
+}
+
+// EndRoomWithReason will record the reason & let clients know before ending the room
+// so that the same reason can be shown to users & send with webhook
+func (r *RoomService) EndRoomWithReason(roomId, reason string) (string, error) {
+	_, err := r.rc.Set(r.ctx, roomEndReasonKey+roomId, reason, r.RoomKeyTTL(roomId)).Result()
+	if err != nil {
+		log.Errorln(err)
+	}
+
+	broadcastSystemMsg(roomId, DataMsgBodyType_ROOM_END_REASON, &RoomEndReason{
+		Reason:       reason,
+		RoomExitUrls: r.LoadRoomOptions(roomId).RoomExitUrls,
+	})
+
+	return r.EndRoom(roomId)
+}
+
+// GetRoomEndReason will return the recorded reason,
+// if nothing was recorded then it was closed by livekit
+func (r *RoomService) GetRoomEndReason(roomId string) string {
+	reason, err := r.rc.Get(r.ctx, roomEndReasonKey+roomId).Result()
+	if err != nil || reason == "" {
+		return RoomEndReasonIdleTimeout
+	}
+	return reason
+}
+
+func (r *RoomService) DeleteRoomEndReason(roomId string) (int64, error) {
+	return r.rc.Del(r.ctx, roomEndReasonKey+roomId).Result()
+}
@@ -0,0 +1,224 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/livekit/protocol/livekit"
+	lksdk "github.com/livekit/server-sdk-go"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+const (
+	// IngressIdentityPrefix is used for the participants those were published by ingress
+	IngressIdentityPrefix    = "ingress_"
+	defaultMaxIngressPerRoom = 3
+	ingressRequestTimeout    = 10 * time.Second
+)
+
+type CreateIngressReq struct {
+	RoomId string `json:"room_id" validate:"required,require-valid-Id"`
+	// Name will be shown as the name of the participant in the room
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+type GetRoomIngressReq struct {
+	RoomId string `json:"room_id" validate:"required,require-valid-Id"`
+}
+
+type IngressReq struct {
+	RoomId    string `json:"room_id" validate:"required,require-valid-Id"`
+	IngressId string `json:"ingress_id" validate:"required"`
+}
+
+type RoomIngress struct {
+	IngressId string `json:"ingress_id"`
+	Name      string `json:"name"`
+	// Url & StreamKey should be used in the encoder, e.g. OBS
+	Url       string `json:"url"`
+	StreamKey string `json:"stream_key"`
+	// Identity of the participant in the room
+	Identity string `json:"identity"`
+	// Status: inactive, buffering, publishing or error
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type roomIngressModel struct {
+	ctx    context.Context
+	rm     *roomModel
+	client *lksdk.IngressClient
+}
+
+func NewRoomIngressModel() *roomIngressModel {
+	return &roomIngressModel{
+		ctx:    context.Background(),
+		rm:     NewRoomModel(),
+		client: lksdk.NewIngressClient(config.AppCnf.LivekitInfo.Host, config.AppCnf.LivekitInfo.ApiKey, config.AppCnf.LivekitInfo.Secret),
+	}
+}
+
+func maxIngressPerRoom() int {
+	if m := config.AppCnf.Ingress.MaxPerRoom; m > 0 {
+		return m
+	}
+	return defaultMaxIngressPerRoom
+}
+
+func newRoomIngress(info *livekit.IngressInfo) *RoomIngress {
+	i := &RoomIngress{
+		IngressId: info.IngressId,
+		Name:      info.ParticipantName,
+		Url:       info.Url,
+		StreamKey: info.StreamKey,
+		Identity:  info.ParticipantIdentity,
+		Status:    "inactive",
+	}
+	if s := info.State; s != nil {
+		i.Status = strings.ToLower(strings.TrimPrefix(s.Status.String(), "ENDPOINT_"))
+		i.Error = s.Error
+	}
+	return i
+}
+
+// CreateIngress will mint rtmp url & stream key for the active room. When an encoder connects,
+// the stream will be published in the room as a participant.
+func (m *roomIngressModel) CreateIngress(r *CreateIngressReq) (*RoomIngress, error) {
+	if !config.AppCnf.Ingress.Enabled {
+		return nil, errors.New("ingress isn't enabled")
+	}
+	room, _ := m.rm.GetRoomInfo(r.RoomId, "", 1)
+	if room.Id == 0 {
+		return nil, errors.New("notifications.room-not-active")
+	}
+
+	existing, err := m.listIngress(r.RoomId)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxIngressPerRoom() {
+		return nil, errors.New(fmt.Sprintf("maximum %d ingress can be created for a room", maxIngressPerRoom()))
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, ingressRequestTimeout)
+	defer cancel()
+	// livekit protocol has rtmp input only, srt can be used after upgrading it
+	info, err := m.client.CreateIngress(ctx, &livekit.CreateIngressRequest{
+		InputType:           livekit.IngressInput_RTMP_INPUT,
+		Name:                r.Name,
+		RoomName:            r.RoomId,
+		ParticipantIdentity: IngressIdentityPrefix + uuid.NewString(),
+		ParticipantName:     r.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return newRoomIngress(info), nil
+}
+
+// GetRoomIngress will return all ingress of the room with state
+func (m *roomIngressModel) GetRoomIngress(roomId string) ([]*RoomIngress, error) {
+	items, err := m.listIngress(roomId)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []*RoomIngress{}
+	for _, info := range items {
+		list = append(list, newRoomIngress(info))
+	}
+	return list, nil
+}
+
+// DeleteIngress will invalidate the stream key, participant will be disconnected if publishing
+func (m *roomIngressModel) DeleteIngress(r *IngressReq) error {
+	items, err := m.listIngress(r.RoomId)
+	if err != nil {
+		return err
+	}
+	for _, info := range items {
+		if info.IngressId == r.IngressId {
+			return m.deleteIngress(info.IngressId)
+		}
+	}
+	return errors.New("no ingress found")
+}
+
+// DeleteAllRoomIngress should be called when the room has ended,
+// so that stream keys can't be used in the next session
+func (m *roomIngressModel) DeleteAllRoomIngress(roomId string) {
+	if !config.AppCnf.Ingress.Enabled {
+		return
+	}
+	items, err := m.listIngress(roomId)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	for _, info := range items {
+		if err = m.deleteIngress(info.IngressId); err != nil {
+			log.Errorln(err)
+		}
+	}
+}
+
+func (m *roomIngressModel) listIngress(roomId string) ([]*livekit.IngressInfo, error) {
+	ctx, cancel := context.WithTimeout(m.ctx, ingressRequestTimeout)
+	defer cancel()
+	res, err := m.client.ListIngress(ctx, &livekit.ListIngressRequest{
+		RoomName: roomId,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res.Items, nil
+}
+
+func (m *roomIngressModel) deleteIngress(ingressId string) error {
+	ctx, cancel := context.WithTimeout(m.ctx, ingressRequestTimeout)
+	defer cancel()
+	_, err := m.client.DeleteIngress(ctx, &livekit.DeleteIngressRequest{
+		IngressId: ingressId,
+	})
+	return err
+}
+
+// IsIngressParticipant will check if the participant was published by ingress
+func IsIngressParticipant(identity string) bool {
+	return strings.HasPrefix(identity, IngressIdentityPrefix)
+}
+
+// setIngressParticipantMetadata will add metadata to the ingress participant,
+// because livekit ingress won't do it & client requires it.
+// Participant can publish media only, all other features will be locked.
+func (r *RoomService) setIngressParticipantMetadata(roomId string, p *livekit.ParticipantInfo) {
+	if !IsIngressParticipant(p.Identity) {
+		return
+	}
+
+	unlock := new(bool)
+	lock := new(bool)
+	*lock = true
+	meta := &plugnmeet.UserMetadata{
+		LockSettings: &plugnmeet.LockSettings{
+			LockMicrophone:      unlock,
+			LockWebcam:          unlock,
+			LockScreenSharing:   lock,
+			LockChat:            lock,
+			LockChatSendMessage: lock,
+			LockChatFileShare:   lock,
+			LockWhiteboard:      lock,
+			LockSharedNotepad:   lock,
+			LockPrivateChat:     lock,
+		},
+	}
+	if _, err := r.UpdateParticipantMetadataByStruct(roomId, p.Identity, meta); err != nil {
+		log.Errorln(err)
+	}
+}
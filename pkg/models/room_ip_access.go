@@ -0,0 +1,73 @@
+package models
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+// RoomIpAccess will restrict joining the room based on the ip of the user.
+// Values can be CIDR or single ip. Deny list will be checked first.
+type RoomIpAccess struct {
+	IpAllowlist []string `json:"ip_allowlist,omitempty"`
+	IpDenylist  []string `json:"ip_denylist,omitempty"`
+}
+
+// Validate will make sure all the values are valid CIDR or ip
+func (a *RoomIpAccess) Validate() error {
+	for _, v := range append(a.IpAllowlist, a.IpDenylist...) {
+		if _, err := parseCIDR(v); err != nil {
+			return errors.New("invalid ip or CIDR: " + v)
+		}
+	}
+	return nil
+}
+
+// IsIpAllowed will return false if ip was in deny list
+// or allow list was set but ip isn't there
+func (a *RoomIpAccess) IsIpAllowed(ip string) bool {
+	if len(a.IpAllowlist) == 0 && len(a.IpDenylist) == 0 {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	if ipInList(parsed, a.IpDenylist) {
+		return false
+	}
+	if len(a.IpAllowlist) > 0 {
+		return ipInList(parsed, a.IpAllowlist)
+	}
+	return true
+}
+
+func ipInList(ip net.IP, list []string) bool {
+	for _, v := range list {
+		n, err := parseCIDR(v)
+		if err == nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCIDR will accept single ip as well
+func parseCIDR(v string) (*net.IPNet, error) {
+	v = strings.TrimSpace(v)
+	if !strings.Contains(v, "/") {
+		ip := net.ParseIP(v)
+		if ip == nil {
+			return nil, errors.New("invalid ip")
+		}
+		bits := 128
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, n, err := net.ParseCIDR(v)
+	return n, err
+}
@@ -0,0 +1,86 @@
+package models
+
+import "testing"
+
+func TestRoomIpAccess_IsIpAllowed(t *testing.T) {
+	tests := []struct {
+		access *RoomIpAccess
+		ip     string
+		want   bool
+	}{
+		{&RoomIpAccess{}, "10.0.0.1", true},
+		{&RoomIpAccess{}, "invalid", true},
+		{&RoomIpAccess{IpAllowlist: []string{"10.0.0.0/8"}}, "10.20.30.40", true},
+		{&RoomIpAccess{IpAllowlist: []string{"10.0.0.0/8"}}, "192.168.1.1", false},
+		{&RoomIpAccess{IpAllowlist: []string{"10.0.0.0/8"}}, "invalid", false},
+		{&RoomIpAccess{IpAllowlist: []string{"192.168.1.10"}}, "192.168.1.10", true},
+		{&RoomIpAccess{IpAllowlist: []string{"192.168.1.10"}}, "192.168.1.11", false},
+		{&RoomIpAccess{IpAllowlist: []string{" 192.168.1.10 "}}, "192.168.1.10", true},
+		{&RoomIpAccess{IpDenylist: []string{"192.168.1.0/24"}}, "192.168.1.99", false},
+		{&RoomIpAccess{IpDenylist: []string{"192.168.1.0/24"}}, "192.168.2.1", true},
+		// deny list will be checked first
+		{&RoomIpAccess{IpAllowlist: []string{"10.0.0.0/8"}, IpDenylist: []string{"10.1.0.0/16"}}, "10.1.2.3", false},
+		{&RoomIpAccess{IpAllowlist: []string{"10.0.0.0/8"}, IpDenylist: []string{"10.1.0.0/16"}}, "10.2.2.3", true},
+		{&RoomIpAccess{IpAllowlist: []string{"2001:db8::/32"}}, "2001:db8::1", true},
+		{&RoomIpAccess{IpAllowlist: []string{"2001:db8::/32"}}, "2001:db9::1", false},
+		{&RoomIpAccess{IpDenylist: []string{"::1"}}, "::1", false},
+		// ipv4 mapped ipv6 address
+		{&RoomIpAccess{IpAllowlist: []string{"10.0.0.1"}}, "::ffff:10.0.0.1", true},
+	}
+
+	for _, tt := range tests {
+		got := tt.access.IsIpAllowed(tt.ip)
+		if got != tt.want {
+			t.Errorf("IsIpAllowed(%s) with %+v = %v, want %v", tt.ip, tt.access, got, tt.want)
+		}
+	}
+}
+
+func TestParseCIDR(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"10.0.0.0/8", "10.0.0.0/8", false},
+		{"10.1.2.3/8", "10.0.0.0/8", false},
+		{"192.168.1.10", "192.168.1.10/32", false},
+		{" 192.168.1.10 ", "192.168.1.10/32", false},
+		{"2001:db8::1", "2001:db8::1/128", false},
+		{"2001:db8::/32", "2001:db8::/32", false},
+		{"10.0.0.0/33", "", true},
+		{"300.0.0.1", "", true},
+		{"invalid", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseCIDR(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCIDR(%s) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.String() != tt.want {
+			t.Errorf("parseCIDR(%s) = %s, want %s", tt.value, got.String(), tt.want)
+		}
+	}
+}
+
+func TestRoomIpAccess_Validate(t *testing.T) {
+	tests := []struct {
+		access  *RoomIpAccess
+		wantErr bool
+	}{
+		{&RoomIpAccess{}, false},
+		{&RoomIpAccess{IpAllowlist: []string{"10.0.0.0/8", "192.168.1.1"}, IpDenylist: []string{"::1"}}, false},
+		{&RoomIpAccess{IpAllowlist: []string{"10.0.0.0/8", "invalid"}}, true},
+		{&RoomIpAccess{IpDenylist: []string{"10.0.0.0/40"}}, true},
+	}
+
+	for _, tt := range tests {
+		err := tt.access.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Validate() with %+v error = %v, wantErr %v", tt.access, err, tt.wantErr)
+		}
+	}
+}
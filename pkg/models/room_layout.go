@@ -0,0 +1,119 @@
+package models
+
+import (
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// layout modes those can be controlled by moderator
+const (
+	RoomLayoutModeDefault   = "default"
+	RoomLayoutModePin       = "pin"
+	RoomLayoutModeSpotlight = "spotlight"
+)
+
+// RoomLayout will be included in room metadata,
+// so that all the clients including recorder render the same layout
+type RoomLayout struct {
+	Mode      string `json:"mode"`
+	UserId    string `json:"user_id,omitempty"`
+	UpdatedBy string `json:"updated_by,omitempty"`
+	UpdatedAt int64  `json:"updated_at,omitempty"`
+}
+
+var errRoomLayoutUnchanged = errors.New("room layout is unchanged")
+
+type SetRoomLayoutReq struct {
+	RoomId          string `json:"-"`
+	RequestedUserId string `json:"-"`
+	Mode            string `json:"mode" validate:"required,oneof=default pin spotlight"`
+	UserId          string `json:"user_id"`
+}
+
+// SetRoomLayout will store the layout & update room metadata
+func (r *RoomService) SetRoomLayout(req *SetRoomLayoutReq) (*RoomLayout, error) {
+	layout := &RoomLayout{
+		Mode:      req.Mode,
+		UpdatedBy: req.RequestedUserId,
+		UpdatedAt: time.Now().Unix(),
+	}
+
+	if req.Mode != RoomLayoutModeDefault {
+		if req.UserId == "" {
+			return nil, errors.New("user_id required")
+		}
+		_, err := r.LoadParticipantInfo(req.RoomId, req.UserId)
+		if err != nil {
+			return nil, errors.New("user isn't active now")
+		}
+		layout.UserId = req.UserId
+	}
+
+	err := r.applyRoomLayout(req.RoomId, layout, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return layout, nil
+}
+
+// applyRoomLayout will store the layout, update room metadata & notify everyone.
+// check will be called with the current layout & nothing will be changed if it returns error.
+func (r *RoomService) applyRoomLayout(roomId string, layout *RoomLayout, check func(current *RoomLayout) error) error {
+	_, err := r.UpdateRoomOptions(roomId, func(o *RoomOptions) error {
+		if check != nil {
+			if err := check(o.Layout); err != nil {
+				return err
+			}
+		}
+		o.Layout = layout
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// metadata will include the layout from room options
+	_, meta, err := r.LoadRoomWithMetadata(roomId)
+	if err != nil {
+		return err
+	}
+	_, err = r.UpdateRoomMetadataByStruct(roomId, meta)
+	if err != nil {
+		return err
+	}
+
+	broadcastSystemMsg(roomId, DataMsgBodyType_ROOM_LAYOUT_UPDATED, layout)
+	r.AddRoomTimelineEvent(roomId, &RoomTimelineEvent{
+		Type:   "room_layout_changed",
+		UserId: layout.UserId,
+		Msg:    layout.Mode,
+	})
+
+	return nil
+}
+
+// clearRoomLayoutOfParticipant will reset the layout to default
+// if the participant who left was pinned or in spotlight
+func (r *RoomService) clearRoomLayoutOfParticipant(roomId, userId string) {
+	layout := r.LoadRoomOptions(roomId).Layout
+	if layout == nil || layout.Mode == RoomLayoutModeDefault || layout.UserId != userId {
+		return
+	}
+
+	err := r.applyRoomLayout(roomId, &RoomLayout{
+		Mode:      RoomLayoutModeDefault,
+		UpdatedBy: "system",
+		UpdatedAt: time.Now().Unix(),
+	}, func(current *RoomLayout) error {
+		// may have been changed in between
+		if current == nil || current.Mode == RoomLayoutModeDefault || current.UserId != userId {
+			return errRoomLayoutUnchanged
+		}
+		return nil
+	})
+	if err != nil && err != errRoomLayoutUnchanged {
+		log.Errorln(err)
+	}
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"time"
+)
+
+const (
+	// roomMergeGracePeriod will give clients time to receive
+	// the move notification before ending the source room
+	roomMergeGracePeriod = 5 * time.Second
+	// roomMergeEndsKey is shared by all servers, member is source room id & score is the time to end it
+	roomMergeEndsKey = "pnm:room_merge_ends"
+)
+
+type MergeRoomsReq struct {
+	SourceRoomId string `json:"source_room_id" validate:"required,require-valid-Id"`
+	TargetRoomId string `json:"target_room_id" validate:"required,require-valid-Id"`
+	// CarryFiles will copy uploaded files if target room allows file upload
+	CarryFiles bool `json:"carry_files"`
+}
+
+type MoveToRoomMsg struct {
+	RoomId string `json:"room_id"`
+	Token  string `json:"token"`
+}
+
+type roomMergeModel struct {
+	rs             *RoomService
+	rm             *roomModel
+	authTokenModel *authTokenModel
+}
+
+func NewRoomMergeModel() *roomMergeModel {
+	return &roomMergeModel{
+		rs:             NewRoomService(),
+		rm:             NewRoomModel(),
+		authTokenModel: NewAuthTokenModel(),
+	}
+}
+
+// MergeRooms will move all the participants of source room to target room
+// & end the source room.
+func (m *roomMergeModel) MergeRooms(r *MergeRoomsReq) error {
+	if r.SourceRoomId == r.TargetRoomId {
+		return errors.New("source & target rooms are same")
+	}
+
+	source, _ := m.rm.GetRoomInfo(r.SourceRoomId, "", 1)
+	if source.Id == 0 {
+		return errors.New("source room not active")
+	}
+	target, _ := m.rm.GetRoomInfo(r.TargetRoomId, "", 1)
+	if target.Id == 0 {
+		return errors.New("target room not active")
+	}
+
+	_, targetMeta, err := m.rs.LoadRoomWithMetadata(r.TargetRoomId)
+	if err != nil {
+		return err
+	}
+
+	participants, err := m.rs.LoadParticipants(r.SourceRoomId)
+	if err != nil {
+		return err
+	}
+
+	moved := make(map[string]bool)
+	for _, p := range participants {
+		if p.Identity == config.RECORDER_BOT || p.Identity == config.RTMP_BOT {
+			continue
+		}
+
+		meta := new(plugnmeet.UserMetadata)
+		_ = json.Unmarshal([]byte(p.Metadata), meta)
+
+		token, err := m.authTokenModel.DoGenerateToken(&plugnmeet.GenerateTokenReq{
+			RoomId: r.TargetRoomId,
+			UserInfo: &plugnmeet.UserInfo{
+				UserId:  p.Identity,
+				Name:    p.Name,
+				IsAdmin: meta.IsAdmin,
+				UserMetadata: &plugnmeet.UserMetadata{
+					ProfilePic: meta.ProfilePic,
+					IsAdmin:    meta.IsAdmin,
+				},
+			},
+		})
+		if err != nil {
+			// user may be blocked in target room
+			log.Errorln(err)
+			continue
+		}
+
+		sendSystemMsgToUser(r.SourceRoomId, p.Identity, DataMsgBodyType_MOVE_TO_ROOM, &MoveToRoomMsg{
+			RoomId: r.TargetRoomId,
+			Token:  token,
+		})
+		moved[p.Identity] = true
+	}
+
+	m.migratePinnedUser(r.SourceRoomId, r.TargetRoomId, moved)
+
+	if r.CarryFiles && targetMeta.RoomFeatures.ChatFeatures.AllowFileUpload {
+		f := NewManageFileModel(&ManageFile{
+			Sid: source.Sid,
+		})
+		err = f.CopyRoomUploadedDir(target.Sid)
+		if err != nil {
+			log.Errorln(err)
+		}
+	}
+
+	m.rs.AddRoomTimelineEvent(r.TargetRoomId, &RoomTimelineEvent{
+		Type: "room_merged",
+		Msg:  r.SourceRoomId,
+	})
+
+	// scheduler will end the source room, so it will be ended even if this server has stopped
+	_, err = m.rs.rc.ZAdd(m.rs.ctx, roomMergeEndsKey, &redis.Z{
+		Score:  float64(time.Now().Add(roomMergeGracePeriod).Unix()),
+		Member: r.SourceRoomId,
+	}).Result()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// migratePinnedUser will pin the user in target room too if the user was pinned in source room,
+// layout of the target room won't be changed if it has pinned someone already
+func (m *roomMergeModel) migratePinnedUser(sourceRoomId, targetRoomId string, moved map[string]bool) {
+	layout := m.rs.LoadRoomOptions(sourceRoomId).Layout
+	if layout == nil || layout.Mode == RoomLayoutModeDefault || !moved[layout.UserId] {
+		return
+	}
+
+	err := m.rs.applyRoomLayout(targetRoomId, &RoomLayout{
+		Mode:      layout.Mode,
+		UserId:    layout.UserId,
+		UpdatedBy: layout.UpdatedBy,
+		UpdatedAt: time.Now().Unix(),
+	}, func(current *RoomLayout) error {
+		if current != nil && current.Mode != RoomLayoutModeDefault {
+			return errRoomLayoutUnchanged
+		}
+		return nil
+	})
+	if err != nil && err != errRoomLayoutUnchanged {
+		log.Errorln(err)
+	}
+}
+
+// endMergedRooms will end the source rooms those grace period is over
+func (s *scheduler) endMergedRooms() {
+	roomIds, err := s.rc.ZRangeByScore(s.ctx, roomMergeEndsKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().Unix(), 10),
+	}).Result()
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	for _, roomId := range roomIds {
+		// only one server will be able to remove it
+		if n, err := s.rc.ZRem(s.ctx, roomMergeEndsKey, roomId).Result(); err != nil || n == 0 {
+			continue
+		}
+		if ok, msg := s.ra.EndRoom(&plugnmeet.RoomEndReq{
+			RoomId: roomId,
+		}, RoomEndReasonMerged); !ok {
+			log.Errorln("ending merged room " + roomId + " failed: " + msg)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-protocol/utils"
+	"net/url"
+)
+
+// CurrentRoomMetadataVersion should be increased whenever the structure of
+// room metadata changes & a migration needs to be added in roomMetadataMigrations
+const CurrentRoomMetadataVersion = 1
+
+// roomMetadataMigrations will upgrade metadata from index version to the next one
+var roomMetadataMigrations = []func(meta *plugnmeet.RoomMetadata){
+	// 0 -> 1: metadata without version may not have all the feature structs
+	func(meta *plugnmeet.RoomMetadata) {
+		if meta.RoomFeatures == nil {
+			meta.RoomFeatures = new(plugnmeet.RoomCreateFeatures)
+		}
+		if meta.DefaultLockSettings == nil {
+			meta.DefaultLockSettings = new(plugnmeet.LockSettings)
+		}
+		r := &plugnmeet.CreateRoomReq{
+			Metadata: meta,
+		}
+		utils.PrepareDefaultRoomFeatures(r)
+		utils.SetRoomDefaultLockSettings(r)
+	},
+}
+
+// roomMetadataWithOptions will let clients know about room options
+// which aren't part of plugnmeet.RoomMetadata
+type roomMetadataWithOptions struct {
+	*plugnmeet.RoomMetadata
+	MetadataVersion     int                `json:"metadata_version"`
+	DuplicateJoinPolicy string             `json:"duplicate_join_policy"`
+	Layout              *RoomLayout        `json:"layout,omitempty"`
+	CompositeLayout     string             `json:"composite_layout,omitempty"`
+	BroadcastBranding   *BroadcastBranding `json:"broadcast_branding,omitempty"`
+	BroadcastSlate      *BroadcastSlate    `json:"broadcast_slate,omitempty"`
+	PersistChat         bool               `json:"persist_chat,omitempty"`
+	PrivateChatPolicy   string             `json:"private_chat_policy"`
+}
+
+type roomMetadataVersion struct {
+	MetadataVersion int `json:"metadata_version"`
+}
+
+func marshalRoomMetadata(meta *plugnmeet.RoomMetadata, opts *RoomOptions) ([]byte, error) {
+	return json.Marshal(&roomMetadataWithOptions{
+		RoomMetadata:        meta,
+		MetadataVersion:     CurrentRoomMetadataVersion,
+		DuplicateJoinPolicy: opts.GetDuplicateJoinPolicy(),
+		Layout:              opts.Layout,
+		CompositeLayout:     opts.CompositeLayout,
+		BroadcastBranding:   opts.BroadcastBranding,
+		BroadcastSlate:      opts.BroadcastSlate,
+		PersistChat:         opts.PersistChat,
+		PrivateChatPolicy:   opts.GetPrivateChatPolicy(),
+	})
+}
+
+// ParseRoomMetadata should be used instead of unmarshalling room metadata directly,
+// it will migrate older stored metadata to the current version.
+func ParseRoomMetadata(metadata string) (*plugnmeet.RoomMetadata, error) {
+	if metadata == "" {
+		return nil, errors.New("empty metadata")
+	}
+
+	v := new(roomMetadataVersion)
+	err := json.Unmarshal([]byte(metadata), v)
+	if err != nil {
+		return nil, err
+	}
+	if v.MetadataVersion > CurrentRoomMetadataVersion {
+		return nil, fmt.Errorf("unsupported metadata_version: %d", v.MetadataVersion)
+	}
+
+	meta := new(plugnmeet.RoomMetadata)
+	err = json.Unmarshal([]byte(metadata), meta)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := v.MetadataVersion; i < CurrentRoomMetadataVersion; i++ {
+		roomMetadataMigrations[i](meta)
+	}
+
+	return meta, nil
+}
+
+// ValidateRoomMetadata will be used during room creation to reject malformed metadata
+func ValidateRoomMetadata(meta *plugnmeet.RoomMetadata) error {
+	if meta == nil {
+		return errors.New("room metadata information required")
+	}
+	if meta.RoomFeatures == nil {
+		return errors.New("room features information required")
+	}
+	if len(meta.RoomTitle) > 255 {
+		return errors.New("room_title can't be more than 255 characters")
+	}
+	if meta.IsBreakoutRoom && meta.ParentRoomId == "" {
+		return errors.New("parent_room_id required for breakout room")
+	}
+	if meta.WebhookUrl != "" {
+		u, err := url.ParseRequestURI(meta.WebhookUrl)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return errors.New("invalid webhook_url")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package models
+
+import (
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"net/url"
+)
+
+const roomOptionsKey = "pnm:room_options:"
+
+// RoomOptions keeps room level settings those aren't part of
+// plugnmeet.RoomMetadata. It will be stored in redis during the session.
+type RoomOptions struct {
+	// Moderators user ids will be treated as admin during token generation
+	Moderators []string `json:"moderators,omitempty"`
+	// Tags can be used to filter rooms, e.g. to end rooms of a tenant
+	Tags []string `json:"tags,omitempty"`
+	// DuplicateJoinPolicy: replace_old (default), reject_new or allow_suffix
+	DuplicateJoinPolicy string `json:"duplicate_join_policy,omitempty" validate:"omitempty,oneof=replace_old reject_new allow_suffix"`
+	// WebhookBatching will let per meeting webhook url receive composite events
+	WebhookBatching bool `json:"webhook_batching,omitempty"`
+	// WaitForHost will hold non-moderators without publishing permission until the first moderator joined
+	WaitForHost bool `json:"wait_for_host,omitempty"`
+	// Layout will be set by moderator during the session
+	Layout *RoomLayout `json:"layout,omitempty"`
+	// MediaPermissions of the users those were restricted during token generation
+	MediaPermissions map[string]*UserMediaPermissions `json:"media_permissions,omitempty"`
+	// CompositeLayout of the recording & broadcasting: grid, speaker or presentation
+	CompositeLayout string `json:"composite_layout,omitempty"`
+	// BroadcastBranding will be passed to the recorder
+	BroadcastBranding *BroadcastBranding `json:"broadcast_branding,omitempty"`
+	// BroadcastSlate will be set when the broadcast was paused
+	BroadcastSlate *BroadcastSlate `json:"broadcast_slate,omitempty"`
+	// LdapGroups will override moderator & attendee groups of ldap config
+	LdapGroups *RoomLdapGroups `json:"ldap_groups,omitempty"`
+	// ApiKey which was used to create the room, webhooks will be signed using it
+	ApiKey string `json:"api_key,omitempty"`
+	// RecordingRetentionDays will override retention of the api key & default
+	RecordingRetentionDays int `json:"recording_retention_days,omitempty" validate:"min=0"`
+	// RecordingQuotaMB will override storage quota of the room id
+	RecordingQuotaMB int64 `json:"recording_quota_mb,omitempty" validate:"min=0"`
+	// RecordingMode: composite, individual or both. Default from recorder_info.track_recording
+	RecordingMode string `json:"recording_mode,omitempty" validate:"omitempty,oneof=composite individual both"`
+	// RequireRecordingConsent will ask participants for consent before the recording starts
+	RequireRecordingConsent bool `json:"require_recording_consent,omitempty"`
+	// PersistChat will store chat messages of the room in DB
+	PersistChat bool `json:"persist_chat,omitempty"`
+	// PrivateChatPolicy: everyone (default), moderators or disabled. Moderators can always chat privately
+	PrivateChatPolicy string `json:"private_chat_policy,omitempty" validate:"omitempty,oneof=everyone moderators disabled"`
+	// ChatFilterWords will be added with words of chat_filter config, mode of the config will be used
+	ChatFilterWords []string `json:"chat_filter_words,omitempty" validate:"max=500"`
+	// ChatHistoryCount of public messages sent to late joiners, 0 means default of chat_history config
+	ChatHistoryCount int `json:"chat_history_count,omitempty" validate:"min=0"`
+	// WhiteboardUpload can only lower the limits of upload_file_settings.whiteboard
+	WhiteboardUpload *WhiteboardUploadLimits `json:"whiteboard_upload,omitempty"`
+	RoomPlacement
+	RoomExitUrls
+	RoomIpAccess
+	RoomViewerOptions
+}
+
+// RoomExitUrls will be sent to clients when the room ends or the user was removed
+// so that clients can redirect users to the correct place
+type RoomExitUrls struct {
+	LogoutUrl   string `json:"logout_url,omitempty"`
+	FeedbackUrl string `json:"feedback_url,omitempty"`
+}
+
+// Validate will make sure urls are absolute http or https urls,
+// otherwise clients may be redirected to a javascript: or relative url
+func (u *RoomExitUrls) Validate() error {
+	for _, v := range []string{u.LogoutUrl, u.FeedbackUrl} {
+		if v == "" {
+			continue
+		}
+		parsed, err := url.Parse(v)
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return errors.New("invalid http or https url: " + v)
+		}
+	}
+	return nil
+}
+
+// RoomPlacement is used to pin the room to a livekit node.
+// Region will be resolved to node id using livekit_info.regions config
+type RoomPlacement struct {
+	NodeId string `json:"node_id,omitempty"`
+	Region string `json:"region,omitempty"`
+}
+
+// ResolveNodeId will return the node id where the room should be created
+// empty value means livekit will select the node
+func (p *RoomPlacement) ResolveNodeId() string {
+	if p.NodeId != "" {
+		return p.NodeId
+	}
+	if p.Region != "" {
+		nodeId, ok := config.AppCnf.LivekitInfo.Regions[p.Region]
+		if !ok {
+			log.Warnln("no livekit node was configured for region: " + p.Region)
+		}
+		return nodeId
+	}
+	return ""
+}
+
+func (o *RoomOptions) IsModerator(userId string) bool {
+	for _, id := range o.Moderators {
+		if id == userId {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *RoomOptions) HasAnyTag(tags []string) bool {
+	for _, t := range tags {
+		for _, tag := range o.Tags {
+			if t == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r *RoomService) SaveRoomOptions(roomId string, o *RoomOptions) error {
+	marshal, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.rc.Set(r.ctx, roomOptionsKey+roomId, marshal, r.RoomKeyTTL(roomId)).Result()
+	if err != nil {
+		log.Errorln(err)
+	}
+
+	return err
+}
+
+// UpdateRoomOptions will load, modify & save the options atomically,
+// so that concurrent updates of different fields won't overwrite each other.
+// If fn returns error then nothing will be saved.
+func (r *RoomService) UpdateRoomOptions(roomId string, fn func(o *RoomOptions) error) (*RoomOptions, error) {
+	key := roomOptionsKey + roomId
+	o := new(RoomOptions)
+
+	for i := 0; i < 5; i++ {
+		err := r.rc.Watch(r.ctx, func(tx *redis.Tx) error {
+			o = new(RoomOptions)
+			result, err := tx.Get(r.ctx, key).Result()
+			if err != nil && err != redis.Nil {
+				return err
+			}
+			if result != "" {
+				if err = json.Unmarshal([]byte(result), o); err != nil {
+					return err
+				}
+			}
+			if err = fn(o); err != nil {
+				return err
+			}
+
+			marshal, err := json.Marshal(o)
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(r.ctx, func(pipe redis.Pipeliner) error {
+				pipe.Set(r.ctx, key, marshal, r.RoomKeyTTL(roomId))
+				return nil
+			})
+			return err
+		}, key)
+
+		// changed by another request, so we'll try again
+		if err == redis.TxFailedErr {
+			continue
+		}
+		return o, err
+	}
+
+	return nil, errors.New("room options were changed concurrently, please try again")
+}
+
+// LoadRoomOptions will always return RoomOptions
+// if nothing was stored for the room then it will be empty
+func (r *RoomService) LoadRoomOptions(roomId string) *RoomOptions {
+	o := new(RoomOptions)
+
+	result, err := r.rc.Get(r.ctx, roomOptionsKey+roomId).Result()
+	if err != nil || result == "" {
+		return o
+	}
+
+	err = json.Unmarshal([]byte(result), o)
+	if err != nil {
+		log.Errorln(err)
+	}
+
+	return o
+}
+
+func (r *RoomService) DeleteRoomOptions(roomId string) (int64, error) {
+	return r.rc.Del(r.ctx, roomOptionsKey+roomId).Result()
+}
@@ -0,0 +1,23 @@
+package models
+
+import "testing"
+
+func TestRoomExitUrlsValidate(t *testing.T) {
+	tests := []struct {
+		urls    RoomExitUrls
+		wantErr bool
+	}{
+		{RoomExitUrls{}, false},
+		{RoomExitUrls{LogoutUrl: "https://example.com/logout", FeedbackUrl: "http://example.com/feedback?id=1"}, false},
+		{RoomExitUrls{LogoutUrl: "javascript:alert(1)"}, true},
+		{RoomExitUrls{LogoutUrl: "/logout"}, true},
+		{RoomExitUrls{FeedbackUrl: "//example.com/feedback"}, true},
+		{RoomExitUrls{FeedbackUrl: "ftp://example.com/feedback"}, true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.urls.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) error = %v, wantErr %v", tt.urls, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"github.com/goccy/go-json"
+	"github.com/livekit/protocol/livekit"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+const participantsPresenceKey = "pnm:participants_presence:"
+
+// ParticipantPresence will be stored in redis during the session
+type ParticipantPresence struct {
+	UserId   string `json:"user_id"`
+	Name     string `json:"name,omitempty"`
+	JoinedAt int64  `json:"joined_at,omitempty"`
+	LeftAt   int64  `json:"left_at,omitempty"`
+	Device   string `json:"device,omitempty"` // user agent of the client
+}
+
+type PublishedTrack struct {
+	Sid    string `json:"sid"`
+	Source string `json:"source"`
+	Muted  bool   `json:"muted"`
+}
+
+type ActiveParticipant struct {
+	*ParticipantPresence
+	Metadata        *plugnmeet.UserMetadata `json:"metadata"`
+	PublishedTracks []*PublishedTrack       `json:"published_tracks"`
+}
+
+type RoomPresence struct {
+	Active []*ActiveParticipant   `json:"active"`
+	Left   []*ParticipantPresence `json:"left"`
+}
+
+// UpdateParticipantPresence will load the presence of the user & save after changing by fn
+func (r *RoomService) UpdateParticipantPresence(roomId, userId string, fn func(p *ParticipantPresence)) {
+	key := participantsPresenceKey + roomId
+	p := &ParticipantPresence{
+		UserId: userId,
+	}
+
+	result, err := r.rc.HGet(r.ctx, key, userId).Result()
+	if err == nil && result != "" {
+		_ = json.Unmarshal([]byte(result), p)
+	}
+	fn(p)
+
+	marshal, err := json.Marshal(p)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	pp := r.rc.Pipeline()
+	pp.HSet(r.ctx, key, userId, marshal)
+	pp.Expire(r.ctx, key, r.RoomKeyTTL(roomId))
+	_, err = pp.Exec(r.ctx)
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+func (r *RoomService) getParticipantsPresence(roomId string) map[string]*ParticipantPresence {
+	presence := make(map[string]*ParticipantPresence)
+
+	result, err := r.rc.HGetAll(r.ctx, participantsPresenceKey+roomId).Result()
+	if err != nil {
+		log.Errorln(err)
+		return presence
+	}
+
+	for userId, v := range result {
+		p := new(ParticipantPresence)
+		err = json.Unmarshal([]byte(v), p)
+		if err != nil {
+			continue
+		}
+		presence[userId] = p
+	}
+
+	return presence
+}
+
+func (r *RoomService) DeleteParticipantsPresence(roomId string) (int64, error) {
+	return r.rc.Del(r.ctx, participantsPresenceKey+roomId).Result()
+}
+
+// GetRoomPresence will return who is in the room right now & since when
+// as well as the users who have left the room.
+func (r *RoomService) GetRoomPresence(roomId string) (*RoomPresence, error) {
+	participants, err := r.LoadParticipants(roomId)
+	if err != nil {
+		return nil, err
+	}
+	presence := r.getParticipantsPresence(roomId)
+
+	res := &RoomPresence{
+		Active: []*ActiveParticipant{},
+		Left:   []*ParticipantPresence{},
+	}
+	active := make(map[string]bool)
+
+	for _, p := range participants {
+		if p.Identity == config.RECORDER_BOT || p.Identity == config.RTMP_BOT {
+			continue
+		}
+		if p.State != livekit.ParticipantInfo_ACTIVE && p.State != livekit.ParticipantInfo_JOINED {
+			continue
+		}
+		active[p.Identity] = true
+
+		pr, ok := presence[p.Identity]
+		if !ok {
+			pr = &ParticipantPresence{
+				UserId: p.Identity,
+			}
+		}
+		pr.Name = p.Name
+		pr.LeftAt = 0
+		if pr.JoinedAt == 0 {
+			pr.JoinedAt = p.JoinedAt
+		}
+
+		meta := new(plugnmeet.UserMetadata)
+		_ = json.Unmarshal([]byte(p.Metadata), meta)
+
+		ap := &ActiveParticipant{
+			ParticipantPresence: pr,
+			Metadata:            meta,
+			PublishedTracks:     []*PublishedTrack{},
+		}
+		for _, t := range p.Tracks {
+			ap.PublishedTracks = append(ap.PublishedTracks, &PublishedTrack{
+				Sid:    t.Sid,
+				Source: t.Source.String(),
+				Muted:  t.Muted,
+			})
+		}
+		res.Active = append(res.Active, ap)
+	}
+
+	for userId, pr := range presence {
+		if !active[userId] && pr.LeftAt > 0 {
+			res.Left = append(res.Left, pr)
+		}
+	}
+
+	return res, nil
+}
+
+// participantPresenceJoined & participantPresenceLeft will be used from webhook
+func (r *RoomService) participantPresenceJoined(roomId string, p *livekit.ParticipantInfo) {
+	r.UpdateParticipantPresence(roomId, r.GetUserIdByIdentity(roomId, p.Identity), func(pr *ParticipantPresence) {
+		pr.Name = p.Name
+		pr.JoinedAt = p.JoinedAt
+		if pr.JoinedAt == 0 {
+			pr.JoinedAt = time.Now().Unix()
+		}
+		pr.LeftAt = 0
+	})
+}
+
+func (r *RoomService) participantPresenceLeft(roomId string, p *livekit.ParticipantInfo) {
+	r.UpdateParticipantPresence(roomId, r.GetUserIdByIdentity(roomId, p.Identity), func(pr *ParticipantPresence) {
+		pr.Name = p.Name
+		pr.LeftAt = time.Now().Unix()
+	})
+}
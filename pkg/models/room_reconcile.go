@@ -0,0 +1,226 @@
+package models
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"github.com/livekit/protocol/livekit"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+const (
+	reconcileLockKey = "pnm:reconcile_lock"
+	reconcileLockTTL = time.Minute
+	// reconcileGracePeriod to ignore rooms those were created recently,
+	// creation may still be in progress, so DB & livekit can be different for a moment
+	reconcileGracePeriod = 2 * time.Minute
+)
+
+// roomScopedKeyPrefixes are the keys those belong to a single room,
+// those will be deleted after ending the room during reconciliation.
+// Whiteboard & chat keys aren't here, those will be archived or removed by room_finished.
+var roomScopedKeyPrefixes = []string{
+	BlockedUsersList,
+	BlockedIpsList,
+	ParticipantsIpKey,
+	participantsPresenceKey,
+	roomOptionsKey,
+	pollsKey,
+	breakoutRoomKey,
+	speakerQueueKey,
+	raisedHandsKey,
+	roomTimelineKey,
+	roomEndReasonKey,
+	roomStatsKey,
+	activeIdentitiesKey,
+	identityClientsKey,
+	identityReplacesKey,
+	suffixedIdentitiesKey,
+	hostJoinedKey,
+	waitingForHostKey,
+	screenAnnotationKey,
+	screenAnnotationsKey,
+	roomDynamicKeysKey,
+}
+
+// reconcileKeptKeyPrefixes won't be deleted even if those are part of dynamic keys of the room
+var reconcileKeptKeyPrefixes = []string{
+	chatMessagesKey,
+	chatTranslationLangKey,
+	chatHistoryKey,
+	chatPinsKey,
+	whiteboardStateKey,
+	whiteboardElementsKey,
+	whiteboardFilesKey,
+	whiteboardConversionStatusKey,
+	whiteboardDrawKey,
+}
+
+type ReconcileResult struct {
+	// EndedRooms were active in DB but not exist in livekit
+	EndedRooms []string `json:"ended_rooms"`
+	// RecoveredRooms were running in livekit but server lost track of
+	RecoveredRooms []string `json:"recovered_rooms"`
+	// DeletedKeys of the ended rooms
+	DeletedKeys []string `json:"deleted_keys"`
+}
+
+// ReconcileRooms will compare our records with actual livekit rooms & repair divergence.
+// Only one server will perform it at a time.
+func (s *scheduler) ReconcileRooms() (*ReconcileResult, error) {
+	token := uuid.NewString()
+	locked, err := s.rc.SetNX(s.ctx, reconcileLockKey, token, reconcileLockTTL).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !locked {
+		return nil, errors.New("reconciliation is already running")
+	}
+	defer releaseRedisLock(s.ctx, s.rc, reconcileLockKey, token)
+
+	// DB first, so a room created in between will be in livekit
+	// & it will be ignored because of grace period
+	// no error means there are active rooms in DB
+	activeRooms, _ := s.ra.rm.GetActiveRoomsInfo()
+
+	res, err := s.ra.rs.livekitClient.ListRooms(s.ctx, &livekit.ListRoomsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	livekitRooms := make(map[string]*livekit.Room)
+	for _, r := range res.Rooms {
+		livekitRooms[r.Name] = r
+	}
+
+	result := &ReconcileResult{
+		EndedRooms:     []string{},
+		RecoveredRooms: []string{},
+		DeletedKeys:    []string{},
+	}
+
+	activeInDB := make(map[string]bool)
+	for _, ar := range activeRooms {
+		activeInDB[ar.RoomId] = true
+		lr, ok := livekitRooms[ar.RoomId]
+		if ok && lr.Sid == ar.Sid {
+			continue
+		}
+		if isWithinReconcileGracePeriod(ar.CreationTime) {
+			continue
+		}
+
+		dynamicKeys, _ := s.rc.SMembers(s.ctx, roomDynamicKeysKey+ar.RoomId).Result()
+		// room is gone, so we'll perform the same tasks as room_finished
+		NewWebhookModel(&livekit.WebhookEvent{
+			Event: "room_finished",
+			Room: &livekit.Room{
+				Name:         ar.RoomId,
+				Sid:          ar.Sid,
+				CreationTime: ar.CreationTime,
+			},
+			CreatedAt: time.Now().Unix(),
+		})
+		result.EndedRooms = append(result.EndedRooms, ar.RoomId)
+
+		// another session of the room may be running in livekit
+		if !ok {
+			result.DeletedKeys = append(result.DeletedKeys, s.deleteEndedRoomKeys(ar.RoomId, dynamicKeys)...)
+		}
+	}
+
+	for roomId, lr := range livekitRooms {
+		if activeInDB[roomId] || isWithinReconcileGracePeriod(lr.CreationTime) {
+			continue
+		}
+		if !s.isCreatedByUs(lr) {
+			continue
+		}
+		err = s.recoverRoom(lr)
+		if err != nil {
+			log.Errorln(err)
+			continue
+		}
+		result.RecoveredRooms = append(result.RecoveredRooms, roomId)
+	}
+
+	return result, nil
+}
+
+func isWithinReconcileGracePeriod(creationTime int64) bool {
+	return time.Since(time.Unix(creationTime, 0)) < reconcileGracePeriod
+}
+
+// isCreatedByUs will check if we've record of the session,
+// other rooms of the same livekit server won't be touched
+func (s *scheduler) isCreatedByUs(lr *livekit.Room) bool {
+	ri, _ := s.ra.rm.GetRoomInfo("", lr.Sid, 0)
+	if ri != nil && ri.Id > 0 {
+		return true
+	}
+	exist, err := s.rc.Exists(s.ctx, roomOptionsKey+lr.Name).Result()
+	return err == nil && exist > 0
+}
+
+// recoverRoom will add the livekit room in DB without changing its metadata
+func (s *scheduler) recoverRoom(lr *livekit.Room) error {
+	ri := &RoomInfo{
+		RoomId:       lr.Name,
+		Sid:          lr.Sid,
+		IsRunning:    1,
+		CreationTime: lr.CreationTime,
+		Created:      time.Now().Format("2006-01-02 15:04:05"),
+	}
+
+	meta, err := ParseRoomMetadata(lr.Metadata)
+	if err == nil {
+		ri.RoomTitle = meta.RoomTitle
+		ri.WebhookUrl = meta.WebhookUrl
+		ri.ParentRoomId = meta.ParentRoomId
+		if meta.IsBreakoutRoom {
+			ri.IsBreakoutRoom = 1
+		}
+
+		if meta.RoomFeatures.RoomDuration != nil && *meta.RoomFeatures.RoomDuration > 0 {
+			config.AppCnf.AddRoomWithDurationMap(lr.Name, config.RoomWithDuration{
+				RoomSid:   lr.Sid,
+				Duration:  *meta.RoomFeatures.RoomDuration,
+				StartedAt: meta.StartedAt,
+			})
+		}
+	}
+
+	_, err = s.ra.rm.InsertOrUpdateRoomData(ri, false)
+	return err
+}
+
+// deleteEndedRoomKeys will delete the keys of the room those were left after room_finished
+func (s *scheduler) deleteEndedRoomKeys(roomId string, dynamicKeys []string) []string {
+	keys := make([]string, 0, len(roomScopedKeyPrefixes)+len(dynamicKeys))
+	for _, prefix := range roomScopedKeyPrefixes {
+		keys = append(keys, prefix+roomId)
+	}
+	for _, key := range dynamicKeys {
+		if !hasAnyPrefix(key, reconcileKeptKeyPrefixes) {
+			keys = append(keys, key)
+		}
+	}
+
+	var deleted []string
+	for _, key := range keys {
+		if n, err := s.rc.Del(s.ctx, key).Result(); err == nil && n > 0 {
+			deleted = append(deleted, key)
+		}
+	}
+	return deleted
+}
+
+func hasAnyPrefix(key string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
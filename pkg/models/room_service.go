@@ -13,7 +13,9 @@ import (
 )
 
 const (
-	BlockedUsersList = "pnm:block_users_list:"
+	BlockedUsersList  = "pnm:block_users_list:"
+	BlockedIpsList    = "pnm:block_ips_list:"
+	ParticipantsIpKey = "pnm:participants_ip:"
 )
 
 type RoomService struct {
@@ -86,9 +88,10 @@ func (r *RoomService) LoadParticipantInfo(roomId string, identity string) (*live
 	return participant, nil
 }
 
-func (r *RoomService) CreateRoom(roomId string, emptyTimeout *uint32, maxParticipants *uint32, metadata string) (*livekit.Room, error) {
+func (r *RoomService) CreateRoom(roomId string, emptyTimeout *uint32, maxParticipants *uint32, metadata string, nodeId string) (*livekit.Room, error) {
 	req := &livekit.CreateRoomRequest{
-		Name: roomId,
+		Name:   roomId,
+		NodeId: nodeId,
 	}
 	if emptyTimeout != nil && *emptyTimeout > 0 {
 		req.EmptyTimeout = *emptyTimeout
@@ -139,6 +142,11 @@ func (r *RoomService) EndRoom(roomId string) (string, error) {
 }
 
 func (r *RoomService) UpdateParticipantMetadata(roomId string, userId string, metadata string) (*livekit.ParticipantInfo, error) {
+	// keep the preferences which were added during token generation
+	if p, err := r.LoadParticipantInfo(roomId, userId); err == nil {
+		metadata = mergeUserPreferences(metadata, p.Metadata)
+	}
+
 	data := livekit.UpdateParticipantRequest{
 		Room:     roomId,
 		Identity: userId,
@@ -221,21 +229,72 @@ func (r *RoomService) SendData(roomId string, data []byte, dataPacket_Kind livek
 
 func (r *RoomService) AddUserToBlockList(roomId, userId string) (int64, error) {
 	key := BlockedUsersList + roomId
-	return r.rc.SAdd(r.ctx, key, userId).Result()
+	res, err := r.rc.SAdd(r.ctx, key, userId).Result()
+	if err != nil {
+		return res, err
+	}
+	r.SetRoomKeysExpiry(roomId, key)
+
+	return res, nil
 }
 
+// IsUserExistInBlockList will be true for banned users
+// & users whose tokens were revoked until those have expired
 func (r *RoomService) IsUserExistInBlockList(roomId, userId string) bool {
 	key := BlockedUsersList + roomId
 	exist, err := r.rc.SIsMember(r.ctx, key, userId).Result()
 	if err != nil {
 		return false
 	}
-	return exist
+	if exist {
+		return true
+	}
+	return NewTokenRevocationModel().IsIdentityRevoked(roomId, userId)
 }
 
 func (r *RoomService) DeleteRoomBlockList(roomId string) (int64, error) {
-	key := BlockedUsersList + roomId
-	return r.rc.Del(r.ctx, key).Result()
+	return r.rc.Del(r.ctx, BlockedUsersList+roomId, BlockedIpsList+roomId, ParticipantsIpKey+roomId).Result()
+}
+
+func (r *RoomService) AddIpToBlockList(roomId, ip string) (int64, error) {
+	key := BlockedIpsList + roomId
+	res, err := r.rc.SAdd(r.ctx, key, ip).Result()
+	if err != nil {
+		return res, err
+	}
+	r.SetRoomKeysExpiry(roomId, key)
+
+	return res, nil
+}
+
+func (r *RoomService) IsIpExistInBlockList(roomId, ip string) bool {
+	key := BlockedIpsList + roomId
+	exist, err := r.rc.SIsMember(r.ctx, key, ip).Result()
+	if err != nil {
+		return false
+	}
+	return exist
+}
+
+// SaveParticipantIp will keep the last known ip of the user
+// so that we can block by ip if required.
+func (r *RoomService) SaveParticipantIp(roomId, userId, ip string) error {
+	key := ParticipantsIpKey + roomId
+	_, err := r.rc.HSet(r.ctx, key, userId, ip).Result()
+	if err != nil {
+		return err
+	}
+	r.SetRoomKeysExpiry(roomId, key)
+
+	return nil
+}
+
+func (r *RoomService) GetParticipantIp(roomId, userId string) string {
+	ip, err := r.rc.HGet(r.ctx, ParticipantsIpKey+roomId, userId).Result()
+	if err != nil {
+		return ""
+	}
+	return ip
 }
 
 func (r *RoomService) LoadRoomWithMetadata(roomId string) (*livekit.Room, *plugnmeet.RoomMetadata, error) {
@@ -244,12 +303,7 @@ func (r *RoomService) LoadRoomWithMetadata(roomId string) (*livekit.Room, *plugn
 		return nil, nil, err
 	}
 
-	if room.Metadata == "" {
-		return room, nil, errors.New("empty metadata")
-	}
-
-	meta := new(plugnmeet.RoomMetadata)
-	err = json.Unmarshal([]byte(room.Metadata), meta)
+	meta, err := ParseRoomMetadata(room.Metadata)
 	if err != nil {
 		log.Errorln(err)
 		return room, nil, err
@@ -259,7 +313,7 @@ func (r *RoomService) LoadRoomWithMetadata(roomId string) (*livekit.Room, *plugn
 }
 
 func (r *RoomService) UpdateRoomMetadataByStruct(roomId string, meta *plugnmeet.RoomMetadata) (*livekit.Room, error) {
-	marshal, err := json.Marshal(meta)
+	marshal, err := marshalRoomMetadata(meta, r.LoadRoomOptions(roomId))
 	if err != nil {
 		log.Errorln(err)
 		return nil, err
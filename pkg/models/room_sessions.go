@@ -0,0 +1,186 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/goccy/go-json"
+	"github.com/livekit/protocol/livekit"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+type RoomSession struct {
+	Id               int64            `json:"id"`
+	RoomId           string           `json:"room_id"`
+	RoomSid          string           `json:"room_sid"`
+	RoomTitle        string           `json:"room_title"`
+	IsBreakoutRoom   int              `json:"is_breakout_room"`
+	ParentRoomId     string           `json:"parent_room_id"`
+	Started          string           `json:"started"`
+	Ended            string           `json:"ended"`
+	Duration         int64            `json:"duration"` // in seconds
+	PeakParticipants int64            `json:"peak_participants"`
+	TotalJoins       int64            `json:"total_joins"`
+	FeaturesUsed     map[string]int64 `json:"features_used"`
+	RecordingUsed    int              `json:"recording_used"`
+	RtmpUsed         int              `json:"rtmp_used"`
+	EndReason        string           `json:"end_reason"`
+}
+
+type FetchSessionsReq struct {
+	RoomId string `query:"room_id"`
+	// From & To format: 2006-01-02 or 2006-01-02 15:04:05
+	From   string `query:"from"`
+	To     string `query:"to"`
+	Limit  uint64 `query:"limit"`
+	Offset uint64 `query:"offset"`
+}
+
+type roomSessionsModel struct {
+	app *config.AppConfig
+	db  *sql.DB
+	ctx context.Context
+	rs  *RoomService
+}
+
+func NewRoomSessionsModel() *roomSessionsModel {
+	return &roomSessionsModel{
+		app: config.AppCnf,
+		db:  config.AppCnf.DB,
+		ctx: context.Background(),
+		rs:  NewRoomService(),
+	}
+}
+
+// ArchiveSession will write the summary of the session after room_finished
+func (m *roomSessionsModel) ArchiveSession(room *livekit.Room, endReason string) error {
+	stats := m.rs.GetRoomStats(room.Name)
+	defer m.rs.DeleteRoomStats(room.Name)
+
+	s := &RoomSession{
+		RoomId:           room.Name,
+		RoomSid:          room.Sid,
+		PeakParticipants: stats.PeakParticipants,
+		TotalJoins:       stats.TotalJoins,
+		FeaturesUsed:     stats.FeaturesUsed,
+		EndReason:        endReason,
+	}
+	if stats.FeaturesUsed[RoomFeatureRecording] > 0 {
+		s.RecordingUsed = 1
+	}
+	if stats.FeaturesUsed[RoomFeatureRtmp] > 0 {
+		s.RtmpUsed = 1
+	}
+
+	if room.Metadata != "" {
+		meta, err := ParseRoomMetadata(room.Metadata)
+		if err == nil {
+			s.RoomTitle = meta.RoomTitle
+			s.ParentRoomId = meta.ParentRoomId
+			if meta.IsBreakoutRoom {
+				s.IsBreakoutRoom = 1
+			}
+		}
+	}
+
+	started := time.Unix(room.CreationTime, 0)
+	ended := time.Now()
+	s.Started = started.Format("2006-01-02 15:04:05")
+	s.Ended = ended.Format("2006-01-02 15:04:05")
+	s.Duration = int64(ended.Sub(started).Seconds())
+
+	features, err := json.Marshal(s.FeaturesUsed)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, 3*time.Second)
+	defer cancel()
+
+	query := "INSERT INTO " + m.app.FormatDBTable("room_sessions") + " (room_id, room_sid, room_title, is_breakout_room, parent_room_id, started, ended, duration, peak_participants, total_joins, features_used, recording_used, rtmp_used, end_reason) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE ended = VALUES(ended), duration = VALUES(duration), end_reason = VALUES(end_reason)"
+
+	_, err = m.db.ExecContext(ctx, query, s.RoomId, s.RoomSid, s.RoomTitle, s.IsBreakoutRoom, s.ParentRoomId, s.Started, s.Ended, s.Duration, s.PeakParticipants, s.TotalJoins, string(features), s.RecordingUsed, s.RtmpUsed, s.EndReason)
+
+	return err
+}
+
+// FetchSessions will return archived sessions in descending order
+func (m *roomSessionsModel) FetchSessions(r *FetchSessionsReq) ([]*RoomSession, int64, error) {
+	var where string
+	var args []interface{}
+
+	if r.RoomId != "" {
+		where += " AND room_id = ?"
+		args = append(args, r.RoomId)
+	}
+	if r.From != "" {
+		from, err := parseSessionDate(r.From, false)
+		if err != nil {
+			return nil, 0, err
+		}
+		where += " AND started >= ?"
+		args = append(args, from)
+	}
+	if r.To != "" {
+		to, err := parseSessionDate(r.To, true)
+		if err != nil {
+			return nil, 0, err
+		}
+		where += " AND started <= ?"
+		args = append(args, to)
+	}
+
+	if r.Limit == 0 || r.Limit > 100 {
+		r.Limit = 20
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, 3*time.Second)
+	defer cancel()
+
+	var total int64
+	row := m.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+m.app.FormatDBTable("room_sessions")+" WHERE 1=1"+where, args...)
+	err := row.Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := m.db.QueryContext(ctx, "SELECT id, room_id, room_sid, room_title, is_breakout_room, parent_room_id, started, ended, duration, peak_participants, total_joins, features_used, recording_used, rtmp_used, end_reason FROM "+m.app.FormatDBTable("room_sessions")+" WHERE 1=1"+where+" ORDER BY id DESC LIMIT ? OFFSET ?", append(args, r.Limit, r.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var sessions []*RoomSession
+	for rows.Next() {
+		s := new(RoomSession)
+		var features string
+		err = rows.Scan(&s.Id, &s.RoomId, &s.RoomSid, &s.RoomTitle, &s.IsBreakoutRoom, &s.ParentRoomId, &s.Started, &s.Ended, &s.Duration, &s.PeakParticipants, &s.TotalJoins, &features, &s.RecordingUsed, &s.RtmpUsed, &s.EndReason)
+		if err != nil {
+			log.Errorln(err)
+			continue
+		}
+		_ = json.Unmarshal([]byte(features), &s.FeaturesUsed)
+		sessions = append(sessions, s)
+	}
+
+	return sessions, total, nil
+}
+
+func parseSessionDate(v string, endOfDay bool) (string, error) {
+	t, err := time.Parse("2006-01-02 15:04:05", v)
+	if err == nil {
+		return t.Format("2006-01-02 15:04:05"), nil
+	}
+
+	t, err = time.Parse("2006-01-02", v)
+	if err != nil {
+		return "", errors.New("invalid date format: " + v)
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+
+	return t.Format("2006-01-02 15:04:05"), nil
+}
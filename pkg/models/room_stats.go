@@ -0,0 +1,113 @@
+package models
+
+import (
+	"github.com/go-redis/redis/v8"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+)
+
+const roomStatsKey = "pnm:room_stats:"
+
+const (
+	roomStatCurrentParticipants = "current_participants"
+	roomStatPeakParticipants    = "peak_participants"
+	roomStatTotalJoins          = "total_joins"
+	roomStatFeaturePrefix       = "feature:"
+	roomStatReactionPrefix      = "reaction:"
+)
+
+// features those will be counted during the session
+const (
+	RoomFeatureRecording     = "recording"
+	RoomFeatureRtmp          = "rtmp"
+	RoomFeaturePolls         = "polls"
+	RoomFeatureBreakoutRooms = "breakout_rooms"
+	RoomFeatureSharedNotepad = "shared_notepad"
+	RoomFeatureSpeakerQueue  = "speaker_queue"
+	RoomFeatureReactions     = "reactions"
+)
+
+type RoomStats struct {
+	PeakParticipants int64            `json:"peak_participants"`
+	TotalJoins       int64            `json:"total_joins"`
+	FeaturesUsed     map[string]int64 `json:"features_used"`
+	// Reactions total count of each emoji during the session
+	Reactions map[string]int64 `json:"reactions"`
+}
+
+// IncrRoomFeatureUsage will count the usage of the feature during the session
+func (r *RoomService) IncrRoomFeatureUsage(roomId, feature string) {
+	key := roomStatsKey + roomId
+	pp := r.rc.Pipeline()
+	pp.HIncrBy(r.ctx, key, roomStatFeaturePrefix+feature, 1)
+	pp.Expire(r.ctx, key, r.RoomKeyTTL(roomId))
+	_, _ = pp.Exec(r.ctx)
+}
+
+// roomParticipantsJoinedScript will count the join & update the peak in one step,
+// so that concurrent joins from different servers can't lose the peak.
+// ARGV: ttl in seconds
+var roomParticipantsJoinedScript = redis.NewScript(`
+local current = redis.call('HINCRBY', KEYS[1], '` + roomStatCurrentParticipants + `', 1)
+redis.call('HINCRBY', KEYS[1], '` + roomStatTotalJoins + `', 1)
+local peak = tonumber(redis.call('HGET', KEYS[1], '` + roomStatPeakParticipants + `') or 0)
+if current > peak then
+	redis.call('HSET', KEYS[1], '` + roomStatPeakParticipants + `', current)
+end
+redis.call('EXPIRE', KEYS[1], ARGV[1])
+return current
+`)
+
+// updateRoomParticipantsStats will be used from webhook to calculate peak participants,
+// recorder & rtmp bots won't be counted
+func (r *RoomService) updateRoomParticipantsStats(roomId, identity string, joined bool) {
+	if identity == config.RECORDER_BOT || identity == config.RTMP_BOT {
+		return
+	}
+
+	key := roomStatsKey + roomId
+	if !joined {
+		r.rc.HIncrBy(r.ctx, key, roomStatCurrentParticipants, -1)
+		return
+	}
+
+	ttl := int64(r.RoomKeyTTL(roomId).Seconds())
+	err := roomParticipantsJoinedScript.Run(r.ctx, r.rc, []string{key}, ttl).Err()
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+func (r *RoomService) GetRoomStats(roomId string) *RoomStats {
+	stats := &RoomStats{
+		FeaturesUsed: make(map[string]int64),
+		Reactions:    make(map[string]int64),
+	}
+
+	result, err := r.rc.HGetAll(r.ctx, roomStatsKey+roomId).Result()
+	if err != nil {
+		return stats
+	}
+
+	for k, v := range result {
+		n, _ := strconv.ParseInt(v, 10, 64)
+		switch {
+		case k == roomStatPeakParticipants:
+			stats.PeakParticipants = n
+		case k == roomStatTotalJoins:
+			stats.TotalJoins = n
+		case strings.HasPrefix(k, roomStatFeaturePrefix):
+			stats.FeaturesUsed[strings.TrimPrefix(k, roomStatFeaturePrefix)] = n
+		case strings.HasPrefix(k, roomStatReactionPrefix):
+			stats.Reactions[strings.TrimPrefix(k, roomStatReactionPrefix)] = n
+		}
+	}
+
+	return stats
+}
+
+func (r *RoomService) DeleteRoomStats(roomId string) (int64, error) {
+	return r.rc.Del(r.ctx, roomStatsKey+roomId).Result()
+}
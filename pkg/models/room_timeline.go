@@ -0,0 +1,58 @@
+package models
+
+import (
+	"github.com/goccy/go-json"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+const roomTimelineKey = "pnm:room_timeline:"
+
+// RoomTimelineEvent is a single entry of the session timeline
+type RoomTimelineEvent struct {
+	Type   string `json:"type"`
+	UserId string `json:"user_id,omitempty"`
+	Msg    string `json:"msg,omitempty"`
+	Time   int64  `json:"time"`
+}
+
+// AddRoomTimelineEvent will append the event to the timeline of the session
+func (r *RoomService) AddRoomTimelineEvent(roomId string, e *RoomTimelineEvent) {
+	if e.Time == 0 {
+		e.Time = time.Now().Unix()
+	}
+	marshal, err := json.Marshal(e)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	pp := r.rc.Pipeline()
+	pp.RPush(r.ctx, roomTimelineKey+roomId, marshal)
+	pp.Expire(r.ctx, roomTimelineKey+roomId, r.RoomKeyTTL(roomId))
+	_, err = pp.Exec(r.ctx)
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+// GetRoomTimeline will return all the events of the session in order
+func (r *RoomService) GetRoomTimeline(roomId string) ([]*RoomTimelineEvent, error) {
+	result, err := r.rc.LRange(r.ctx, roomTimelineKey+roomId, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var events []*RoomTimelineEvent
+	for _, v := range result {
+		e := new(RoomTimelineEvent)
+		err = json.Unmarshal([]byte(v), e)
+		if err != nil {
+			log.Errorln(err)
+			continue
+		}
+		events = append(events, e)
+	}
+
+	return events, nil
+}
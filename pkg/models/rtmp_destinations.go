@@ -0,0 +1,519 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/livekit/protocol/livekit"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	RtmpDestinationStatusActive   = "active"
+	RtmpDestinationStatusFinished = "finished"
+	RtmpDestinationStatusFailed   = "failed"
+
+	// rtmpDestinationsKey keeps destinations of the session, field is id of the destination
+	rtmpDestinationsKey = "pnm:rtmp_destinations:"
+	// rtmpEgressKey keeps id of the egress which is streaming to the destinations
+	rtmpEgressKey               = "pnm:rtmp_egress:"
+	rtmpDestinationEndedEvent   = "rtmp_destination_ended"
+	defaultRtmpLayout           = "speaker-dark"
+	defaultMaxRtmpDestinations  = 5
+	rtmpDestinationsRequestTime = 10 * time.Second
+)
+
+type RtmpDestination struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	// Url includes stream key, so it will be masked in responses
+	Url       string `json:"url"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	StartedAt int64  `json:"started_at"`
+	EndedAt   int64  `json:"ended_at,omitempty"`
+}
+
+type RtmpDestinationReq struct {
+	// Name to identify the destination, e.g. YouTube
+	Name string `json:"name" validate:"max=50"`
+	// Url can be rtmp(s):// or srt://
+	Url string `json:"url" validate:"required_without=DestinationId,max=1024"`
+	// DestinationId of saved destination of the api key which created the room,
+	// can be used instead of Url
+	DestinationId string `json:"destination_id"`
+	// Passphrase & Latency (in milliseconds) are for srt only,
+	// otherwise default values of config will be used
+	Passphrase string `json:"passphrase" validate:"omitempty,min=10,max=79"`
+	Latency    int    `json:"latency" validate:"min=0,max=60000"`
+}
+
+type StartRtmpDestinationsReq struct {
+	RoomId       string                `json:"-"`
+	Destinations []*RtmpDestinationReq `json:"destinations" validate:"required,min=1,dive"`
+	// Encoding will be used when the egress starts, default values of config will be used for empty values
+	Encoding *BroadcastEncoding `json:"encoding"`
+}
+
+type StopRtmpDestinationReq struct {
+	RoomId string `json:"-"`
+	// Id of the destination, empty will stop all destinations
+	Id string `json:"id"`
+}
+
+// rtmpDestinationEndedNotifyEvent will add the destination with common notify event
+type rtmpDestinationEndedNotifyEvent struct {
+	*plugnmeet.CommonNotifyEvent
+	Destination *RtmpDestination `json:"destination"`
+}
+
+func maxRtmpDestinations() int {
+	if m := config.AppCnf.RecorderInfo.Rtmp.MaxDestinations; m > 0 {
+		return m
+	}
+	return defaultMaxRtmpDestinations
+}
+
+// masked will return copy of the destination without stream key,
+// query will be removed too as srt keeps streamid & passphrase there
+func (d *RtmpDestination) masked() *RtmpDestination {
+	c := *d
+	u, err := url.Parse(d.Url)
+	if err != nil || u.Host == "" {
+		c.Url = ""
+		return &c
+	}
+	// stream key is the last part of the path
+	if i := strings.LastIndex(u.Path, "/"); i >= 0 {
+		u.Path = u.Path[:i+1] + "****"
+	}
+	u.RawQuery = ""
+	u.User = nil
+	c.Url = u.String()
+	return &c
+}
+
+func streamUrlProtocol(streamUrl string) string {
+	if strings.HasPrefix(streamUrl, "srt://") {
+		return "srt"
+	}
+	return "rtmp"
+}
+
+// streamProtocol will let egress choose based on urls for srt,
+// as livekit protocol doesn't have value for it
+func streamProtocol(protocol string) livekit.StreamProtocol {
+	if protocol == "srt" {
+		return livekit.StreamProtocol_DEFAULT_PROTOCOL
+	}
+	return livekit.StreamProtocol_RTMP
+}
+
+// buildStreamUrl will validate the url & add passphrase, latency for srt
+func buildStreamUrl(d *RtmpDestinationReq) (string, error) {
+	u, err := url.Parse(d.Url)
+	if err != nil || u.Host == "" {
+		return "", errors.New("invalid url")
+	}
+
+	switch u.Scheme {
+	case "rtmp", "rtmps":
+		return d.Url, nil
+	case "srt":
+		conf := config.AppCnf.RecorderInfo.Rtmp
+		q := u.Query()
+		passphrase := d.Passphrase
+		if passphrase == "" {
+			passphrase = conf.SrtPassphrase
+		}
+		if passphrase != "" && q.Get("passphrase") == "" {
+			q.Set("passphrase", passphrase)
+		}
+		latency := d.Latency
+		if latency == 0 {
+			latency = conf.SrtLatency
+		}
+		if latency > 0 && q.Get("latency") == "" {
+			q.Set("latency", strconv.Itoa(latency))
+		}
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	}
+
+	return "", errors.New("invalid rtmp or srt url")
+}
+
+// resolveSavedDestination will set url & srt options from the saved destination,
+// so that clients won't need to know the stream key
+func resolveSavedDestination(roomId string, d *RtmpDestinationReq) error {
+	apiKey := NewRoomService().LoadRoomOptions(roomId).ApiKey
+	saved, err := NewStreamDestinationsModel().GetDestination(apiKey, d.DestinationId)
+	if err != nil {
+		return err
+	}
+	d.Url = saved.streamUrl()
+	if d.Name == "" {
+		d.Name = saved.Name
+	}
+	if d.Passphrase == "" {
+		d.Passphrase = saved.Passphrase
+	}
+	if d.Latency == 0 {
+		d.Latency = saved.Latency
+	}
+	return nil
+}
+
+// StartRtmpDestinations will broadcast the room to multiple destinations using livekit egress.
+// New destinations will be added to the running egress of the session.
+func (rm *recordingModel) StartRtmpDestinations(r *StartRtmpDestinationsReq) ([]*RtmpDestination, error) {
+	room, _ := NewRoomModel().GetRoomInfo(r.RoomId, "", 1)
+	if room.Id == 0 {
+		return nil, errors.New("notifications.room-not-active")
+	}
+
+	egressId, _ := rm.rds.Get(rm.ctx, rtmpEgressKey+room.Sid).Result()
+	if egressId == "" && room.IsActiveRTMP == 1 {
+		// broadcasting by the recorder
+		return nil, errors.New("RTMP broadcasting already running")
+	}
+
+	// all destinations are streamed by the same egress
+	if egressId != "" && r.Encoding != nil {
+		return nil, errors.New("encoding can't be changed while broadcasting")
+	}
+
+	existing := rm.loadRtmpDestinations(room.Sid)
+	active := 0
+	urls := make(map[string]bool)
+	for _, d := range existing {
+		if d.Status == RtmpDestinationStatusActive {
+			active++
+			urls[d.Url] = true
+		}
+	}
+	if active+len(r.Destinations) > maxRtmpDestinations() {
+		return nil, errors.New(fmt.Sprintf("maximum %d destinations can be used", maxRtmpDestinations()))
+	}
+
+	// egress can stream using one protocol only
+	protocol := ""
+	for u := range urls {
+		protocol = streamUrlProtocol(u)
+	}
+	var newUrls []string
+	for _, d := range r.Destinations {
+		if d.DestinationId != "" {
+			if err := resolveSavedDestination(room.RoomId, d); err != nil {
+				return nil, err
+			}
+		}
+		streamUrl, err := buildStreamUrl(d)
+		if err != nil {
+			return nil, errors.New(err.Error() + " of " + d.Name)
+		}
+		if protocol != "" && streamUrlProtocol(streamUrl) != protocol {
+			return nil, errors.New("rtmp & srt destinations can't be used together")
+		}
+		protocol = streamUrlProtocol(streamUrl)
+		if urls[streamUrl] {
+			return nil, errors.New("duplicate url of " + d.Name)
+		}
+		urls[streamUrl] = true
+		d.Url = streamUrl
+		newUrls = append(newUrls, streamUrl)
+	}
+
+	ctx, cancel := context.WithTimeout(rm.ctx, rtmpDestinationsRequestTime)
+	defer cancel()
+	client := newEgressClient()
+	if egressId == "" {
+		layout := rm.egressLayout(room.RoomId, config.AppCnf.RecorderInfo.Rtmp.Layout)
+		encoding, err := ResolveBroadcastEncoding(r.Encoding)
+		if err != nil {
+			return nil, err
+		}
+		info, err := client.StartRoomCompositeEgress(ctx, &livekit.RoomCompositeEgressRequest{
+			RoomName: room.RoomId,
+			Layout:   layout,
+			Options: &livekit.RoomCompositeEgressRequest_Advanced{
+				Advanced: encoding.egressEncodingOptions(),
+			},
+			Output: &livekit.RoomCompositeEgressRequest_Stream{
+				Stream: &livekit.StreamOutput{
+					Protocol: streamProtocol(protocol),
+					Urls:     newUrls,
+				},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		egressId = info.EgressId
+
+		// destinations of the previous broadcast won't be needed
+		pp := rm.rds.Pipeline()
+		pp.Del(rm.ctx, rtmpDestinationsKey+room.Sid)
+		pp.Set(rm.ctx, rtmpEgressKey+room.Sid, egressId, trackRecordingTTL)
+		if _, err = pp.Exec(rm.ctx); err != nil {
+			log.Errorln(err)
+		}
+
+		// it will be handled same as recorder has started rtmp
+		rm.HandleRecorderResp(&plugnmeet.RecorderToPlugNmeet{
+			From:       "plugnmeet",
+			Status:     true,
+			Task:       plugnmeet.RecordingTasks_START_RTMP,
+			Msg:        "success",
+			RoomId:     room.RoomId,
+			RoomSid:    room.Sid,
+			RecorderId: trackRecorderId,
+		})
+	} else {
+		_, err := client.UpdateStream(ctx, &livekit.UpdateStreamRequest{
+			EgressId:      egressId,
+			AddOutputUrls: newUrls,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now().Unix()
+	var started []*RtmpDestination
+	for _, d := range r.Destinations {
+		dest := &RtmpDestination{
+			Id:        uuid.NewString(),
+			Name:      d.Name,
+			Url:       d.Url,
+			Status:    RtmpDestinationStatusActive,
+			StartedAt: now,
+		}
+		rm.saveRtmpDestination(room.Sid, dest)
+		started = append(started, dest.masked())
+	}
+
+	return started, nil
+}
+
+// StopRtmpDestination will remove the destination from the egress.
+// Egress will be stopped if it was the last active destination.
+func (rm *recordingModel) StopRtmpDestination(r *StopRtmpDestinationReq) error {
+	room, _ := NewRoomModel().GetRoomInfo(r.RoomId, "", 1)
+	if room.Id == 0 {
+		return errors.New("notifications.room-not-active")
+	}
+	egressId, _ := rm.rds.Get(rm.ctx, rtmpEgressKey+room.Sid).Result()
+	if egressId == "" {
+		return errors.New("RTMP broadcasting not running")
+	}
+
+	var dest *RtmpDestination
+	othersActive := false
+	for _, d := range rm.loadRtmpDestinations(room.Sid) {
+		if d.Status != RtmpDestinationStatusActive {
+			continue
+		}
+		if d.Id == r.Id {
+			dest = d
+		} else {
+			othersActive = true
+		}
+	}
+	if r.Id != "" && dest == nil {
+		return errors.New("no active destination found")
+	}
+
+	if r.Id == "" || !othersActive {
+		// destinations will be updated when livekit informs us that the egress has ended
+		return rm.stopRtmpEgress(egressId)
+	}
+
+	ctx, cancel := context.WithTimeout(rm.ctx, rtmpDestinationsRequestTime)
+	defer cancel()
+	_, err := newEgressClient().UpdateStream(ctx, &livekit.UpdateStreamRequest{
+		EgressId:         egressId,
+		RemoveOutputUrls: []string{dest.Url},
+	})
+	if err != nil {
+		return err
+	}
+
+	rm.endRtmpDestination(room.RoomId, room.Sid, dest, RtmpDestinationStatusFinished, "")
+	return nil
+}
+
+// HasRtmpDestinations will check if the session is broadcasting using destinations
+func (rm *recordingModel) HasRtmpDestinations(roomSid string) bool {
+	egressId, _ := rm.rds.Get(rm.ctx, rtmpEgressKey+roomSid).Result()
+	return egressId != ""
+}
+
+func (rm *recordingModel) stopRtmpEgress(egressId string) error {
+	ctx, cancel := context.WithTimeout(rm.ctx, rtmpDestinationsRequestTime)
+	defer cancel()
+	_, err := newEgressClient().StopEgress(ctx, &livekit.StopEgressRequest{
+		EgressId: egressId,
+	})
+	return err
+}
+
+// GetRtmpDestinations will return destinations of the current session of the room
+func (rm *recordingModel) GetRtmpDestinations(roomId string) ([]*RtmpDestination, error) {
+	room, _ := NewRoomModel().GetRoomInfo(roomId, "", 1)
+	if room.Id == 0 {
+		return nil, errors.New("notifications.room-not-active")
+	}
+
+	destinations := []*RtmpDestination{}
+	for _, d := range rm.loadRtmpDestinations(room.Sid) {
+		destinations = append(destinations, d.masked())
+	}
+	return destinations, nil
+}
+
+func (rm *recordingModel) loadRtmpDestinations(roomSid string) []*RtmpDestination {
+	var destinations []*RtmpDestination
+	result, err := rm.rds.HGetAll(rm.ctx, rtmpDestinationsKey+roomSid).Result()
+	if err != nil {
+		log.Errorln(err)
+		return destinations
+	}
+
+	for _, data := range result {
+		d := new(RtmpDestination)
+		if err = json.Unmarshal([]byte(data), d); err != nil {
+			continue
+		}
+		destinations = append(destinations, d)
+	}
+	sort.Slice(destinations, func(i, j int) bool {
+		return destinations[i].StartedAt < destinations[j].StartedAt
+	})
+	return destinations
+}
+
+func (rm *recordingModel) saveRtmpDestination(roomSid string, d *RtmpDestination) {
+	marshal, err := json.Marshal(d)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	pp := rm.rds.Pipeline()
+	pp.HSet(rm.ctx, rtmpDestinationsKey+roomSid, d.Id, marshal)
+	pp.Expire(rm.ctx, rtmpDestinationsKey+roomSid, trackRecordingTTL)
+	if _, err = pp.Exec(rm.ctx); err != nil {
+		log.Errorln(err)
+	}
+}
+
+func (rm *recordingModel) endRtmpDestination(roomId, roomSid string, d *RtmpDestination, status, reason string) {
+	d.Status = status
+	d.Error = reason
+	d.EndedAt = time.Now().Unix()
+	rm.saveRtmpDestination(roomSid, d)
+
+	event := rtmpDestinationEndedEvent
+	msg := &rtmpDestinationEndedNotifyEvent{
+		CommonNotifyEvent: &plugnmeet.CommonNotifyEvent{
+			Event: &event,
+			Room: &plugnmeet.NotifyEventRoom{
+				Sid:    &roomSid,
+				RoomId: &roomId,
+			},
+		},
+		Destination: d.masked(),
+	}
+	if err := NewWebhookNotifier().Notify(roomSid, msg); err != nil {
+		log.Errorln(err)
+	}
+
+	if status == RtmpDestinationStatusFailed {
+		rm.sendBroadcastHealthWebhook(broadcastDisconnectedEvent, &BroadcastHealth{
+			RoomId:       roomId,
+			RoomSid:      roomSid,
+			State:        BroadcastStateDisconnected,
+			Reasons:      []string{"destination " + d.Name + " failed"},
+			Error:        reason,
+			UpdatedAt:    d.EndedAt,
+			Destinations: []*RtmpDestination{d.masked()},
+		})
+	}
+}
+
+// RtmpEgressUpdated will update state of each destination from the egress info.
+// When the egress has ended, rtmp will be ended same as recorder has ended it.
+func (rm *recordingModel) RtmpEgressUpdated(e *livekit.EgressInfo) {
+	if e == nil {
+		return
+	}
+	egressId, _ := rm.rds.Get(rm.ctx, rtmpEgressKey+e.RoomId).Result()
+	if egressId == "" || egressId != e.EgressId {
+		// not started by us
+		return
+	}
+
+	streams := make(map[string]*livekit.StreamInfo)
+	for _, s := range e.GetStream().GetInfo() {
+		streams[s.Url] = s
+	}
+
+	var ended bool
+	switch e.Status {
+	case livekit.EgressStatus_EGRESS_COMPLETE, livekit.EgressStatus_EGRESS_FAILED,
+		livekit.EgressStatus_EGRESS_ABORTED, livekit.EgressStatus_EGRESS_LIMIT_REACHED:
+		ended = true
+	}
+
+	for _, d := range rm.loadRtmpDestinations(e.RoomId) {
+		if d.Status != RtmpDestinationStatusActive {
+			continue
+		}
+		if s, ok := streams[d.Url]; ok {
+			switch s.Status {
+			case livekit.StreamInfo_FAILED:
+				rm.endRtmpDestination(e.RoomName, e.RoomId, d, RtmpDestinationStatusFailed, "stream failed")
+				continue
+			case livekit.StreamInfo_FINISHED:
+				rm.endRtmpDestination(e.RoomName, e.RoomId, d, RtmpDestinationStatusFinished, "")
+				continue
+			}
+		}
+		if ended {
+			if e.Status == livekit.EgressStatus_EGRESS_COMPLETE {
+				rm.endRtmpDestination(e.RoomName, e.RoomId, d, RtmpDestinationStatusFinished, "")
+			} else {
+				rm.endRtmpDestination(e.RoomName, e.RoomId, d, RtmpDestinationStatusFailed, e.Error)
+			}
+		}
+	}
+
+	if !ended {
+		return
+	}
+	rm.rds.Del(rm.ctx, rtmpEgressKey+e.RoomId)
+
+	msg := "success"
+	if e.Error != "" {
+		msg = e.Error
+	}
+	rm.HandleRecorderResp(&plugnmeet.RecorderToPlugNmeet{
+		From:       "plugnmeet",
+		Status:     e.Status == livekit.EgressStatus_EGRESS_COMPLETE,
+		Task:       plugnmeet.RecordingTasks_END_RTMP,
+		Msg:        msg,
+		RoomId:     e.RoomName,
+		RoomSid:    e.RoomId,
+		RecorderId: trackRecorderId,
+	})
+}
@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"errors"
 	"github.com/go-redis/redis/v8"
 	"github.com/goccy/go-json"
 	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
@@ -14,6 +15,8 @@ type scheduler struct {
 	rc          *redis.Client
 	ctx         context.Context
 	ra          *roomAuthModel
+	sq          *speakerQueueModel
+	pm          *newPollsModel
 	closeTicker chan bool
 }
 
@@ -22,11 +25,18 @@ func NewSchedulerModel() *scheduler {
 		rc:  config.AppCnf.RDS,
 		ctx: context.Background(),
 		ra:  NewRoomAuthModel(),
+		sq:  NewSpeakerQueueModel(),
+		pm:  NewPollsModel(),
 	}
 }
 
 func (s *scheduler) StartScheduler() {
 	go s.subscribeRedisRoomDurationChecker()
+	s.startTranscriptionWorkers()
+	s.startTranscodeWorkers()
+	s.startRecordingPostProcessWorkers()
+	s.startWhiteboardConversionWorkers()
+	s.startWebhookDispatcher()
 
 	s.closeTicker = make(chan bool)
 	checkRoomDuration := time.NewTicker(5 * time.Second)
@@ -35,14 +45,44 @@ func (s *scheduler) StartScheduler() {
 	roomChecker := time.NewTicker(5 * time.Minute)
 	defer roomChecker.Stop()
 
+	retentionChecker := time.NewTicker(s.retentionCheckInterval())
+	defer retentionChecker.Stop()
+
+	recorderChecker := time.NewTicker(10 * time.Second)
+	defer recorderChecker.Stop()
+
+	uploadRetryChecker := time.NewTicker(30 * time.Second)
+	defer uploadRetryChecker.Stop()
+
+	broadcastReconnectChecker := time.NewTicker(2 * time.Second)
+	defer broadcastReconnectChecker.Stop()
+
 	for {
 		select {
 		case <-s.closeTicker:
 			return
 		case <-checkRoomDuration.C:
 			s.checkRoomWithDuration()
+			s.sq.CheckTimeLimits()
+			s.pm.CloseExpiredPolls()
+			s.checkRecordingConsentDeadlines()
+			s.endMergedRooms()
 		case <-roomChecker.C:
+			// reconcile first, so that dead rooms will be cleaned properly
+			if _, err := s.ReconcileRooms(); err != nil {
+				log.Errorln(err)
+			}
 			s.activeRoomChecker()
+		case <-retentionChecker.C:
+			go s.DeleteExpiredRecordings()
+			go s.PurgeDeletedRecordings()
+		case <-recorderChecker.C:
+			s.CheckRecorderNodes()
+			s.CheckBroadcastHealth()
+		case <-uploadRetryChecker.C:
+			s.RetryRecordingUploads()
+		case <-broadcastReconnectChecker.C:
+			s.CheckBroadcastReconnects()
 		}
 	}
 }
@@ -73,6 +113,8 @@ func (s *scheduler) subscribeRedisRoomDurationChecker() {
 			config.AppCnf.DeleteRoomFromRoomWithDurationMap(req.RoomId)
 		} else if req.Type == "increaseDuration" {
 			s.increaseRoomDuration(req.RoomId, req.Duration)
+		} else if req.Type == "fastForward" {
+			config.AppCnf.FastForwardRoomDuration(req.RoomId, req.Duration)
 		}
 	}
 }
@@ -86,14 +128,59 @@ func (s *scheduler) checkRoomWithDuration() {
 		now := uint64(time.Now().Unix())
 		valid := r.StartedAt + (r.Duration * 60)
 		if now > valid {
-			_, err := s.ra.rs.EndRoom(i)
-			if err != nil {
-				log.Errorln(err)
-			}
+			s.endRoomForExpiry(i)
 		}
 	}
 }
 
+func (s *scheduler) endRoomForExpiry(roomId string) {
+	_, err := s.ra.rs.EndRoomWithReason(roomId, RoomEndReasonDurationExpired)
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+type SimulateRoomExpiryReq struct {
+	RoomId  string `json:"room_id" validate:"required,require-valid-Id"`
+	Minutes uint64 `json:"minutes"`
+}
+
+// SimulateRoomExpiry will fast-forward the duration clock of the room by minutes.
+// If minutes is 0 then the room will be ended immediately using the same path as duration expiry.
+// This is useful for integrators to test their room_finished handling.
+func (s *scheduler) SimulateRoomExpiry(r *SimulateRoomExpiryReq) error {
+	roomDbInfo, _ := s.ra.rm.GetRoomInfo(r.RoomId, "", 1)
+	if roomDbInfo.Id == 0 {
+		return errors.New("room not active")
+	}
+
+	if r.Minutes == 0 {
+		s.endRoomForExpiry(r.RoomId)
+		return nil
+	}
+
+	_, meta, err := s.ra.rs.LoadRoomWithMetadata(r.RoomId)
+	if err != nil {
+		return err
+	}
+	if meta.RoomFeatures.RoomDuration == nil || *meta.RoomFeatures.RoomDuration == 0 {
+		return errors.New("room doesn't have any duration to fast-forward")
+	}
+
+	// room duration map can be in any server, so we'll publish it
+	req := &RedisRoomDurationCheckerReq{
+		Type:     "fastForward",
+		RoomId:   r.RoomId,
+		Duration: r.Minutes,
+	}
+	marshal, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	return s.rc.Publish(s.ctx, "plug-n-meet-room-duration-checker", marshal).Err()
+}
+
 func (s *scheduler) increaseRoomDuration(roomId string, duration uint64) {
 	newDuration := config.AppCnf.IncreaseRoomDuration(roomId, duration)
 	if newDuration == 0 {
@@ -141,6 +228,9 @@ func (s *scheduler) activeRoomChecker() {
 			continue
 		}
 
+		// room still active, so we'll extend expiry of transient keys
+		s.ra.rs.RefreshRoomKeysExpiry(room.RoomId)
+
 		pp, err := s.ra.rs.LoadParticipants(room.RoomId)
 		if err != nil {
 			continue
@@ -163,7 +253,7 @@ func (s *scheduler) activeRoomChecker() {
 				// we can close the room
 				s.ra.EndRoom(&plugnmeet.RoomEndReq{
 					RoomId: room.RoomId,
-				})
+				}, RoomEndReasonIdleTimeout)
 			}
 		}
 	}
@@ -0,0 +1,264 @@
+package models
+
+import (
+	"errors"
+	"github.com/goccy/go-json"
+	"github.com/livekit/protocol/livekit"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"time"
+)
+
+const (
+	// screenAnnotationKey keeps the active annotation session of the room
+	screenAnnotationKey = "pnm:screen_annotation:"
+	// screenAnnotationsKey keeps annotations of the active session for late joiners
+	screenAnnotationsKey = "pnm:screen_annotations:"
+	maxScreenAnnotations = 1000
+)
+
+type StartScreenAnnotationReq struct {
+	RoomId          string `json:"-"`
+	RequestedUserId string `json:"-"`
+	IsAdmin         bool   `json:"-"`
+	TrackSid        string `json:"track_sid" validate:"required"`
+	// AllowEveryone will let everyone annotate, otherwise only the sharer & moderators
+	AllowEveryone bool `json:"allow_everyone"`
+}
+
+type ScreenAnnotationReq struct {
+	RoomId          string `json:"-"`
+	RequestedUserId string `json:"-"`
+	IsAdmin         bool   `json:"-"`
+}
+
+// ScreenAnnotationSession is tied to a screen share track, Active false means it has ended
+type ScreenAnnotationSession struct {
+	Active        bool              `json:"active"`
+	TrackSid      string            `json:"track_sid,omitempty"`
+	UserId        string            `json:"user_id,omitempty"`
+	AllowEveryone bool              `json:"allow_everyone"`
+	StartedAt     int64             `json:"started_at,omitempty"`
+	Annotations   []json.RawMessage `json:"annotations,omitempty"`
+}
+
+type screenAnnotationsCleared struct {
+	TrackSid  string `json:"track_sid"`
+	ClearedBy string `json:"cleared_by"`
+}
+
+// screenAnnotationMsg is the body of annotation messages sent by the clients
+type screenAnnotationMsg struct {
+	TrackSid string `json:"track_sid"`
+}
+
+func (r *RoomService) getScreenAnnotationSession(roomId string) (*ScreenAnnotationSession, error) {
+	fields, err := r.rc.HGetAll(r.ctx, screenAnnotationKey+roomId).Result()
+	if err != nil {
+		return nil, err
+	}
+	s := new(ScreenAnnotationSession)
+	if fields["track_sid"] == "" {
+		return s, nil
+	}
+	s.Active = true
+	s.TrackSid = fields["track_sid"]
+	s.UserId = fields["user_id"]
+	s.AllowEveryone = fields["allow_everyone"] == "1"
+	s.StartedAt, _ = strconv.ParseInt(fields["started_at"], 10, 64)
+	return s, nil
+}
+
+// GetScreenAnnotationSession will return the active session with annotations
+func (r *RoomService) GetScreenAnnotationSession(roomId string) (*ScreenAnnotationSession, error) {
+	s, err := r.getScreenAnnotationSession(roomId)
+	if err != nil || !s.Active {
+		return s, err
+	}
+	list, err := r.rc.LRange(r.ctx, screenAnnotationsKey+roomId, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range list {
+		s.Annotations = append(s.Annotations, json.RawMessage(a))
+	}
+	return s, nil
+}
+
+// screenShareOwner will return identity of the user who is publishing the screen share track
+func (r *RoomService) screenShareOwner(roomId, trackSid string) (string, error) {
+	participants, err := r.LoadParticipants(roomId)
+	if err != nil {
+		return "", err
+	}
+	for _, p := range participants {
+		for _, t := range p.Tracks {
+			if t.Sid == trackSid && t.Source == livekit.TrackSource_SCREEN_SHARE {
+				return p.Identity, nil
+			}
+		}
+	}
+	return "", errors.New("no active screen share found")
+}
+
+// StartScreenAnnotation will start annotation of the screen share,
+// it can be started by moderators or the user who is sharing the screen
+func (r *RoomService) StartScreenAnnotation(req *StartScreenAnnotationReq) (*ScreenAnnotationSession, error) {
+	owner, err := r.screenShareOwner(req.RoomId, req.TrackSid)
+	if err != nil {
+		return nil, err
+	}
+	if !req.IsAdmin && owner != req.RequestedUserId {
+		return nil, errors.New("only moderators or the presenter can perform this task")
+	}
+
+	s := &ScreenAnnotationSession{
+		Active:        true,
+		TrackSid:      req.TrackSid,
+		UserId:        owner,
+		AllowEveryone: req.AllowEveryone,
+		StartedAt:     time.Now().Unix(),
+	}
+	allowEveryone := "0"
+	if s.AllowEveryone {
+		allowEveryone = "1"
+	}
+
+	key := screenAnnotationKey + req.RoomId
+	pp := r.rc.TxPipeline()
+	pp.Del(r.ctx, key, screenAnnotationsKey+req.RoomId)
+	pp.HSet(r.ctx, key, "track_sid", s.TrackSid, "user_id", s.UserId, "allow_everyone", allowEveryone, "started_at", s.StartedAt)
+	pp.Expire(r.ctx, key, r.RoomKeyTTL(req.RoomId))
+	if _, err = pp.Exec(r.ctx); err != nil {
+		return nil, err
+	}
+
+	broadcastSystemMsg(req.RoomId, DataMsgBodyType_SCREEN_ANNOTATION_UPDATED, s)
+	return s, nil
+}
+
+// checkScreenAnnotationOwner will return the session if the user can manage it
+func (r *RoomService) checkScreenAnnotationOwner(req *ScreenAnnotationReq) (*ScreenAnnotationSession, error) {
+	s, err := r.getScreenAnnotationSession(req.RoomId)
+	if err != nil {
+		return nil, err
+	}
+	if !s.Active {
+		return nil, errors.New("no active screen annotation found")
+	}
+	if !req.IsAdmin && s.UserId != req.RequestedUserId {
+		return nil, errors.New("only moderators or the presenter can perform this task")
+	}
+	return s, nil
+}
+
+// StopScreenAnnotation will end the session & remove annotations
+func (r *RoomService) StopScreenAnnotation(req *ScreenAnnotationReq) error {
+	if _, err := r.checkScreenAnnotationOwner(req); err != nil {
+		return err
+	}
+	return r.endScreenAnnotation(req.RoomId)
+}
+
+func (r *RoomService) endScreenAnnotation(roomId string) error {
+	if err := r.DeleteScreenAnnotations(roomId); err != nil {
+		return err
+	}
+	broadcastSystemMsg(roomId, DataMsgBodyType_SCREEN_ANNOTATION_UPDATED, &ScreenAnnotationSession{})
+	return nil
+}
+
+// ClearScreenAnnotations will remove all annotations, the session will continue
+func (r *RoomService) ClearScreenAnnotations(req *ScreenAnnotationReq) error {
+	s, err := r.checkScreenAnnotationOwner(req)
+	if err != nil {
+		return err
+	}
+	if err = r.rc.Del(r.ctx, screenAnnotationsKey+req.RoomId).Err(); err != nil {
+		return err
+	}
+
+	broadcastSystemMsg(req.RoomId, DataMsgBodyType_SCREEN_ANNOTATIONS_CLEARED, &screenAnnotationsCleared{
+		TrackSid:  s.TrackSid,
+		ClearedBy: req.RequestedUserId,
+	})
+	return nil
+}
+
+// ScreenShareUnpublished will end the annotation session of the track
+func (r *RoomService) ScreenShareUnpublished(roomId string, track *livekit.TrackInfo) {
+	if track == nil || track.Source != livekit.TrackSource_SCREEN_SHARE {
+		return
+	}
+	trackSid, err := r.rc.HGet(r.ctx, screenAnnotationKey+roomId, "track_sid").Result()
+	if err != nil || trackSid != track.Sid {
+		return
+	}
+	if err = r.endScreenAnnotation(roomId); err != nil {
+		log.Errorln(err)
+	}
+}
+
+// AllowScreenAnnotation will drop annotations those don't belong to the active session
+// or the user doesn't have permission. Annotations are always for everyone.
+func AllowScreenAnnotation(roomId, userId string, isAdmin bool, msg *plugnmeet.DataMessage) bool {
+	if msg.Type != plugnmeet.DataMsgType_USER || msg.Body == nil || msg.Body.Type != DataMsgBodyType_SCREEN_ANNOTATION {
+		return true
+	}
+	if msg.Body.From == nil || msg.Body.From.UserId != userId {
+		return false
+	}
+
+	a := new(screenAnnotationMsg)
+	if err := json.Unmarshal([]byte(msg.Body.Msg), a); err != nil {
+		return false
+	}
+	s, err := NewRoomService().getScreenAnnotationSession(roomId)
+	if err != nil {
+		log.Errorln(err)
+		return false
+	}
+	if !s.Active || s.TrackSid != a.TrackSid {
+		return false
+	}
+	if !isAdmin && !s.AllowEveryone && s.UserId != userId {
+		return false
+	}
+
+	msg.To = nil
+	return true
+}
+
+// PersistScreenAnnotation will keep the annotation for late joiners
+func PersistScreenAnnotation(roomId string, msg *plugnmeet.DataMessage) {
+	if msg.Type != plugnmeet.DataMsgType_USER || msg.Body == nil || msg.Body.Type != DataMsgBodyType_SCREEN_ANNOTATION {
+		return
+	}
+	rs := NewRoomService()
+	key := screenAnnotationsKey + roomId
+	pp := rs.rc.Pipeline()
+	pp.RPush(rs.ctx, key, msg.Body.Msg)
+	pp.LTrim(rs.ctx, key, -maxScreenAnnotations, -1)
+	pp.Expire(rs.ctx, key, rs.RoomKeyTTL(roomId))
+	if _, err := pp.Exec(rs.ctx); err != nil {
+		log.Errorln(err)
+	}
+}
+
+// SendScreenAnnotations will send the active session to the user who has just joined
+func SendScreenAnnotations(roomId, userId string) {
+	s, err := NewRoomService().GetScreenAnnotationSession(roomId)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	if !s.Active {
+		return
+	}
+	sendSystemMsgToUser(roomId, userId, DataMsgBodyType_SCREEN_ANNOTATION_UPDATED, s)
+}
+
+func (r *RoomService) DeleteScreenAnnotations(roomId string) error {
+	return r.rc.Del(r.ctx, screenAnnotationKey+roomId, screenAnnotationsKey+roomId).Err()
+}
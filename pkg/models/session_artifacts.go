@@ -0,0 +1,201 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// artifacts of a session those can be deleted individually
+const (
+	SessionArtifactChat       = "chat"
+	SessionArtifactAnalytics  = "analytics"
+	SessionArtifactWhiteboard = "whiteboard"
+	SessionArtifactRecordings = "recordings"
+)
+
+type DeleteSessionArtifactReq struct {
+	RoomSid      string `json:"room_sid" validate:"required"`
+	ArtifactType string `json:"artifact_type" validate:"required,oneof=chat analytics whiteboard recordings"`
+	// RequestedBy will be used in audit log
+	RequestedBy string `json:"requested_by"`
+	Reason      string `json:"reason"`
+}
+
+type DeleteSessionArtifactRes struct {
+	RoomId  string `json:"room_id"`
+	Deleted int64  `json:"deleted"`
+}
+
+// DeleteSessionArtifact will delete only the requested type of artifact of the session
+// & write audit log regardless of the result.
+func (m *roomSessionsModel) DeleteSessionArtifact(r *DeleteSessionArtifactReq, actorIp string) (*DeleteSessionArtifactRes, error) {
+	res := new(DeleteSessionArtifactRes)
+	roomId, err := m.findRoomIdBySid(r.RoomSid)
+	if err != nil {
+		return nil, err
+	}
+	res.RoomId = roomId
+
+	switch r.ArtifactType {
+	case SessionArtifactChat:
+		// chat messages aren't stored in server,
+		// only files those were attached during chat
+		res.Deleted, err = m.deleteChatAttachments(r.RoomSid)
+	case SessionArtifactWhiteboard:
+		// converted whiteboard files are stored in sub directories
+		res.Deleted, err = m.deleteWhiteboardFiles(r.RoomSid)
+		if err == nil {
+			var exports int64
+			exports, err = NewWhiteboardExportModel().DeleteWhiteboardExports(r.RoomSid)
+			res.Deleted += exports
+		}
+	case SessionArtifactAnalytics:
+		res.Deleted, err = m.deleteAnalytics(r.RoomSid)
+	case SessionArtifactRecordings:
+		res.Deleted, err = m.deleteRecordings(r.RoomSid)
+	default:
+		err = errors.New("invalid artifact type")
+	}
+
+	details := fmt.Sprintf("delete %s artifact, deleted: %d", r.ArtifactType, res.Deleted)
+	if r.Reason != "" {
+		details += ", reason: " + r.Reason
+	}
+	if err != nil {
+		details += ", error: " + err.Error()
+	}
+	NewAuditLogModel().AddAuditLog(&AuditLog{
+		Action:    "delete_session_artifact",
+		RoomId:    roomId,
+		RoomSid:   r.RoomSid,
+		Actor:     r.RequestedBy,
+		ActorIp:   actorIp,
+		Details:   details,
+		Succeeded: err == nil,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (m *roomSessionsModel) findRoomIdBySid(sid string) (string, error) {
+	ctx, cancel := context.WithTimeout(m.ctx, 3*time.Second)
+	defer cancel()
+
+	var roomId string
+	row := m.db.QueryRowContext(ctx, "SELECT roomId FROM "+m.app.FormatDBTable("room_info")+" WHERE sid = ?", sid)
+	err := row.Scan(&roomId)
+	if err != nil {
+		return "", errors.New("no session found with this sid")
+	}
+
+	return roomId, nil
+}
+
+// deleteChatAttachments will delete the attachments directory & remote attachments.
+// Other uploaded files of the session are shared with the whiteboard, so those will be kept.
+func (m *roomSessionsModel) deleteChatAttachments(sid string) (int64, error) {
+	dir := fmt.Sprintf("%s/%s/%s", m.app.UploadFileSettings.Path, sid, chatAttachmentsDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	deleted := int64(len(entries))
+	if len(entries) > 0 {
+		if err = os.RemoveAll(dir); err != nil {
+			return 0, err
+		}
+	}
+
+	f := NewManageFileModel(&ManageFile{
+		Sid: sid,
+	})
+	deleted += int64(f.DeleteChatAttachments())
+
+	return deleted, nil
+}
+
+// deleteWhiteboardFiles will delete the converted files, those are in sub directories
+// except the directory of chat attachments
+func (m *roomSessionsModel) deleteWhiteboardFiles(sid string) (int64, error) {
+	dir := fmt.Sprintf("%s/%s", m.app.UploadFileSettings.Path, sid)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var deleted int64
+	for _, e := range entries {
+		if !e.IsDir() || e.Name() == chatAttachmentsDir {
+			continue
+		}
+		err = os.RemoveAll(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+// deleteAnalytics will delete analytics of the ended session only,
+// otherwise live stats & presence of the running session will be broken
+func (m *roomSessionsModel) deleteAnalytics(sid string) (int64, error) {
+	ri, _ := NewRoomModel().GetRoomInfo("", sid, 1)
+	if ri.Id > 0 {
+		return 0, errors.New("session is still running, analytics can be deleted after it has ended")
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, 3*time.Second)
+	defer cancel()
+
+	result, err := m.db.ExecContext(ctx, "DELETE FROM "+m.app.FormatDBTable("room_sessions")+" WHERE room_sid = ?", sid)
+	if err != nil {
+		return 0, err
+	}
+	deleted, _ := result.RowsAffected()
+
+	return deleted, nil
+}
+
+func (m *roomSessionsModel) deleteRecordings(sid string) (int64, error) {
+	ctx, cancel := context.WithTimeout(m.ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, "SELECT record_id FROM "+m.app.FormatDBTable("recordings")+" WHERE room_sid = ? AND deleted_at = 0", sid)
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	_ = rows.Close()
+
+	var deleted int64
+	ra := NewRecordingAuth()
+	for _, id := range ids {
+		// artifacts should be removed permanently
+		err = ra.PurgeRecording(id)
+		if err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
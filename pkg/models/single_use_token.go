@@ -0,0 +1,121 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+	"time"
+)
+
+const (
+	singleUseTokenKey    = "pnm:single_use_token:"
+	singleUseTokenUnused = "unused"
+	// singleUseTokenRejoinWindow lets the same user verify the used token again after leaving,
+	// e.g. reloading the page
+	singleUseTokenRejoinWindow = 2 * time.Minute
+)
+
+// MarkTokenSingleUse will store the nonce of the token until its expiry,
+// so that the token can be verified only once
+func (a *authTokenModel) MarkTokenSingleUse(token string) error {
+	return a.markTokenSingleUse(token, a.joinTokenValidity())
+}
+
+func (a *authTokenModel) markTokenSingleUse(token string, expiry time.Duration) error {
+	_, err := a.rs.rc.Set(a.rs.ctx, singleUseTokenKey+singleUseTokenNonce(token), singleUseTokenUnused, expiry).Result()
+	return err
+}
+
+// TokenSession will return the fingerprint of the client session.
+// After consuming, single-use token can be used only from the same session,
+// so a shared link will be useless for others even before it's expired.
+func TokenSession(ip, userAgent string) string {
+	sum := sha256.Sum256([]byte(ip + "|" + userAgent))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkSingleUseTokenSession will be used during token validation,
+// consumed single-use token will be accepted from the session which consumed it only
+func (a *authTokenModel) checkSingleUseTokenSession(token, session string) error {
+	v, err := a.rs.rc.Get(a.rs.ctx, singleUseTokenKey+singleUseTokenNonce(token)).Result()
+	if err == redis.Nil {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if v != singleUseTokenUnused && v != session {
+		return errors.New("this join link was already used")
+	}
+	return nil
+}
+
+// CheckSingleUseToken will return error if the token was used already by another join.
+// rejoin will be true if the same session is verifying the used token again while in the room
+// or shortly after leaving, in that case the token shouldn't be consumed again.
+func (a *authTokenModel) CheckSingleUseToken(token, session, roomId, userId string) (rejoin bool, err error) {
+	v, err := a.rs.rc.Get(a.rs.ctx, singleUseTokenKey+singleUseTokenNonce(token)).Result()
+	if err == redis.Nil {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if v == singleUseTokenUnused {
+		return false, nil
+	}
+
+	if v == session {
+		if a.rs.isIdentityActive(roomId, userId) {
+			return true, nil
+		}
+		p, err := a.rs.rc.HGet(a.rs.ctx, participantsPresenceKey+roomId, userId).Result()
+		if err == nil {
+			presence := new(ParticipantPresence)
+			if json.Unmarshal([]byte(p), presence) == nil && presence.LeftAt > 0 &&
+				time.Since(time.Unix(presence.LeftAt, 0)) <= singleUseTokenRejoinWindow {
+				return true, nil
+			}
+		}
+	}
+
+	return false, errors.New("this join link was already used")
+}
+
+// ConsumeSingleUseToken will mark the nonce as used by the session atomically.
+// It should be called after all the checks have passed, so that a failed join won't use the link.
+// If the token wasn't generated as single-use then nothing will happen.
+func (a *authTokenModel) ConsumeSingleUseToken(token, session string) error {
+	key := singleUseTokenKey + singleUseTokenNonce(token)
+
+	err := a.rs.rc.Watch(a.rs.ctx, func(tx *redis.Tx) error {
+		v, err := tx.Get(a.rs.ctx, key).Result()
+		if err == redis.Nil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if v != singleUseTokenUnused {
+			return errors.New("this join link was already used")
+		}
+
+		_, err = tx.TxPipelined(a.rs.ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(a.rs.ctx, key, session, redis.KeepTTL)
+			return nil
+		})
+		return err
+	}, key)
+
+	// another request consumed it at the same time
+	if err == redis.TxFailedErr {
+		return errors.New("this join link was already used")
+	}
+	return err
+}
+
+func singleUseTokenNonce(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
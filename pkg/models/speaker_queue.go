@@ -0,0 +1,383 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	speakerQueueKey = "pnm:speaker_queue:"
+	// speakerQueueRoomsKey is a set of rooms those have enabled the queue,
+	// so that we won't need to scan all the keys to check time limits
+	speakerQueueRoomsKey = "pnm:speaker_queue_rooms"
+)
+
+var (
+	errSpeakerQueueNotEnabled = errors.New("speaker queue isn't enabled")
+	errNotInSpeakerQueue      = errors.New("user isn't in the queue")
+)
+
+// SpeakerQueue holds the state of floor control of a room.
+// Only the current speaker is allowed to publish microphone.
+type SpeakerQueue struct {
+	TimeLimit      uint64   `json:"time_limit"` // in seconds, 0 means unlimited
+	CurrentSpeaker string   `json:"current_speaker,omitempty"`
+	SpeakingSince  int64    `json:"speaking_since,omitempty"`
+	Queue          []string `json:"queue"`
+}
+
+type SpeakerQueueReq struct {
+	RoomId          string `json:"-"`
+	UserId          string `json:"user_id"`
+	TimeLimit       uint64 `json:"time_limit"`
+	RequestedUserId string `json:"-"`
+	IsAdmin         bool   `json:"-"`
+}
+
+type speakerQueueModel struct {
+	rc  *redis.Client
+	ctx context.Context
+	rs  *RoomService
+	um  *userModel
+}
+
+func NewSpeakerQueueModel() *speakerQueueModel {
+	return &speakerQueueModel{
+		rc:  config.AppCnf.RDS,
+		ctx: context.Background(),
+		rs:  NewRoomService(),
+		um:  NewUserModel(),
+	}
+}
+
+// Enable will start floor control mode. All the microphones will be muted & locked
+func (m *speakerQueueModel) Enable(r *SpeakerQueueReq) error {
+	q := &SpeakerQueue{
+		TimeLimit: r.TimeLimit,
+		Queue:     []string{},
+	}
+	marshal, err := json.Marshal(q)
+	if err != nil {
+		return err
+	}
+
+	ok, err := m.rc.SetNX(m.ctx, speakerQueueKey+r.RoomId, marshal, m.rs.RoomKeyTTL(r.RoomId)).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("speaker queue already enabled")
+	}
+	_, err = m.rc.SAdd(m.ctx, speakerQueueRoomsKey, r.RoomId).Result()
+	if err != nil {
+		log.Errorln(err)
+	}
+
+	lock := true
+	err = m.um.MuteAllMics(&MuteAllMicsReq{
+		RoomId:          r.RoomId,
+		LockMicrophone:  &lock,
+		RequestedUserId: r.RequestedUserId,
+	})
+	if err != nil {
+		// otherwise it won't be possible to enable again
+		if _, dErr := m.DeleteQueue(r.RoomId); dErr != nil {
+			log.Errorln(dErr)
+		}
+		return err
+	}
+
+	m.rs.IncrRoomFeatureUsage(r.RoomId, RoomFeatureSpeakerQueue)
+	m.rs.AddRoomTimelineEvent(r.RoomId, &RoomTimelineEvent{
+		Type:   "speaker_queue_enabled",
+		UserId: r.RequestedUserId,
+	})
+	m.broadcastQueue(r.RoomId, q)
+
+	return nil
+}
+
+// Disable will stop floor control mode & unlock microphones
+func (m *speakerQueueModel) Disable(r *SpeakerQueueReq) error {
+	q, err := m.GetQueue(r.RoomId)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.DeleteQueue(r.RoomId)
+	if err != nil {
+		return err
+	}
+
+	if q.CurrentSpeaker != "" {
+		m.addFloorReleasedEvent(r.RoomId, q)
+	}
+
+	lock := false
+	err = m.um.MuteAllMics(&MuteAllMicsReq{
+		RoomId:          r.RoomId,
+		LockMicrophone:  &lock,
+		RequestedUserId: r.RequestedUserId,
+	})
+	if err != nil {
+		return err
+	}
+
+	m.rs.AddRoomTimelineEvent(r.RoomId, &RoomTimelineEvent{
+		Type:   "speaker_queue_disabled",
+		UserId: r.RequestedUserId,
+	})
+	m.broadcastQueue(r.RoomId, &SpeakerQueue{Queue: []string{}})
+
+	return nil
+}
+
+// GetQueue will return the current state of the queue
+func (m *speakerQueueModel) GetQueue(roomId string) (*SpeakerQueue, error) {
+	result, err := m.rc.Get(m.ctx, speakerQueueKey+roomId).Result()
+	if err == redis.Nil {
+		return nil, errSpeakerQueueNotEnabled
+	} else if err != nil {
+		return nil, err
+	}
+
+	q := new(SpeakerQueue)
+	err = json.Unmarshal([]byte(result), q)
+	if err != nil {
+		return nil, err
+	}
+
+	return q, nil
+}
+
+// RequestFloor will add the requested user at the end of the queue.
+// If nobody has the floor then the user will get it immediately.
+func (m *speakerQueueModel) RequestFloor(r *SpeakerQueueReq) error {
+	return m.update(r.RoomId, func(q *SpeakerQueue) error {
+		if q.CurrentSpeaker == r.RequestedUserId {
+			return errors.New("you already have the floor")
+		}
+		for _, id := range q.Queue {
+			if id == r.RequestedUserId {
+				return errors.New("you are already in the queue")
+			}
+		}
+
+		q.Queue = append(q.Queue, r.RequestedUserId)
+		if q.CurrentSpeaker == "" {
+			q.nextSpeaker()
+		}
+		return nil
+	})
+}
+
+// Withdraw will remove the user from the queue.
+// If the user has the floor then it will be given to the next one.
+// Admin can withdraw any user, others only themselves.
+func (m *speakerQueueModel) Withdraw(r *SpeakerQueueReq) error {
+	userId := r.RequestedUserId
+	if r.IsAdmin && r.UserId != "" {
+		userId = r.UserId
+	}
+
+	return m.update(r.RoomId, func(q *SpeakerQueue) error {
+		if q.CurrentSpeaker == userId {
+			q.nextSpeaker()
+			return nil
+		}
+
+		for i, id := range q.Queue {
+			if id == userId {
+				q.Queue = append(q.Queue[:i], q.Queue[i+1:]...)
+				return nil
+			}
+		}
+
+		return errNotInSpeakerQueue
+	})
+}
+
+// ParticipantLeft will remove the departed user from the queue.
+// If the user had the floor then it will be given to the next one.
+func (m *speakerQueueModel) ParticipantLeft(roomId, userId string) {
+	err := m.Withdraw(&SpeakerQueueReq{
+		RoomId:          roomId,
+		RequestedUserId: userId,
+	})
+	if err != nil && err != errSpeakerQueueNotEnabled && err != errNotInSpeakerQueue {
+		log.Errorln(err)
+	}
+}
+
+// Next will give the floor to the next user of the queue
+func (m *speakerQueueModel) Next(r *SpeakerQueueReq) error {
+	return m.update(r.RoomId, func(q *SpeakerQueue) error {
+		if q.CurrentSpeaker == "" && len(q.Queue) == 0 {
+			return errors.New("queue is empty")
+		}
+		q.nextSpeaker()
+		return nil
+	})
+}
+
+// CheckTimeLimits will move the floor to the next user
+// if the current speaker has crossed the time limit
+func (m *speakerQueueModel) CheckTimeLimits() {
+	roomIds, err := m.rc.SMembers(m.ctx, speakerQueueRoomsKey).Result()
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	for _, roomId := range roomIds {
+		q, err := m.GetQueue(roomId)
+		if err == errSpeakerQueueNotEnabled {
+			// key was expired with the room
+			m.rc.SRem(m.ctx, speakerQueueRoomsKey, roomId)
+			continue
+		}
+		if err != nil || q.TimeLimit == 0 || q.CurrentSpeaker == "" {
+			continue
+		}
+		if time.Now().Unix() < q.SpeakingSince+int64(q.TimeLimit) {
+			continue
+		}
+
+		err = m.update(roomId, func(nq *SpeakerQueue) error {
+			// may be already changed by other server or request
+			if nq.CurrentSpeaker != q.CurrentSpeaker || nq.SpeakingSince != q.SpeakingSince {
+				return errors.New("floor already changed")
+			}
+			nq.nextSpeaker()
+			return nil
+		})
+		if err != nil {
+			log.Infoln(err)
+		}
+	}
+}
+
+func (q *SpeakerQueue) nextSpeaker() {
+	q.CurrentSpeaker = ""
+	q.SpeakingSince = 0
+	if len(q.Queue) > 0 {
+		q.CurrentSpeaker = q.Queue[0]
+		q.SpeakingSince = time.Now().Unix()
+		q.Queue = q.Queue[1:]
+	}
+}
+
+// update will change the queue in a transaction
+// & apply the permissions if the floor has changed
+func (m *speakerQueueModel) update(roomId string, fn func(q *SpeakerQueue) error) error {
+	key := speakerQueueKey + roomId
+	var prev, q *SpeakerQueue
+
+	err := m.rc.Watch(m.ctx, func(tx *redis.Tx) error {
+		result, err := tx.Get(m.ctx, key).Result()
+		if err == redis.Nil {
+			return errSpeakerQueueNotEnabled
+		} else if err != nil {
+			return err
+		}
+
+		prev = new(SpeakerQueue)
+		q = new(SpeakerQueue)
+		_ = json.Unmarshal([]byte(result), prev)
+		_ = json.Unmarshal([]byte(result), q)
+
+		err = fn(q)
+		if err != nil {
+			return err
+		}
+
+		marshal, err := json.Marshal(q)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(m.ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(m.ctx, key, marshal, redis.KeepTTL)
+			return nil
+		})
+		return err
+	}, key)
+	if err != nil {
+		return err
+	}
+
+	if prev.CurrentSpeaker != q.CurrentSpeaker || prev.SpeakingSince != q.SpeakingSince {
+		if prev.CurrentSpeaker != "" {
+			m.addFloorReleasedEvent(roomId, prev)
+			m.changeFloor(roomId, prev.CurrentSpeaker, true)
+		}
+		if q.CurrentSpeaker != "" {
+			m.rs.AddRoomTimelineEvent(roomId, &RoomTimelineEvent{
+				Type:   "speaker_floor_granted",
+				UserId: q.CurrentSpeaker,
+			})
+			m.changeFloor(roomId, q.CurrentSpeaker, false)
+		}
+	}
+	m.broadcastQueue(roomId, q)
+
+	return nil
+}
+
+// changeFloor will grant or revoke microphone of the user.
+// Admins are never locked, so we won't change anything for them.
+func (m *speakerQueueModel) changeFloor(roomId, userId string, lock bool) {
+	p, meta, err := m.rs.LoadParticipantWithMetadata(roomId, userId)
+	if err != nil || meta.IsAdmin {
+		return
+	}
+
+	direction := "unlock"
+	if lock {
+		direction = "lock"
+		_ = m.um.MuteUnMuteTrack(&plugnmeet.MuteUnMuteTrackReq{
+			RoomId: roomId,
+			UserId: userId,
+			Muted:  true,
+		})
+	}
+
+	err = m.um.updateParticipantLockMetadata(updateParticipantLockMetadata{
+		participantInfo: p,
+		roomId:          roomId,
+		service:         "mic",
+		direction:       direction,
+	})
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+func (m *speakerQueueModel) addFloorReleasedEvent(roomId string, q *SpeakerQueue) {
+	m.rs.AddRoomTimelineEvent(roomId, &RoomTimelineEvent{
+		Type:   "speaker_floor_released",
+		UserId: q.CurrentSpeaker,
+		Msg:    time.Since(time.Unix(q.SpeakingSince, 0)).Round(time.Second).String(),
+	})
+}
+
+func (m *speakerQueueModel) broadcastQueue(roomId string, q *SpeakerQueue) {
+	broadcastSystemMsg(roomId, DataMsgBodyType_SPEAKER_QUEUE_UPDATED, q)
+}
+
+func (m *speakerQueueModel) DeleteQueue(roomId string) (int64, error) {
+	pp := m.rc.Pipeline()
+	pp.SRem(m.ctx, speakerQueueRoomsKey, roomId)
+	del := pp.Del(m.ctx, speakerQueueKey+roomId)
+	_, err := pp.Exec(m.ctx)
+	if err != nil {
+		return 0, err
+	}
+	return del.Val(), nil
+}
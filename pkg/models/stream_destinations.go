@@ -0,0 +1,299 @@
+package models
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type StreamDestination struct {
+	DestinationId string `json:"destination_id"`
+	Name          string `json:"name"`
+	// Platform is for reference only, e.g. youtube, facebook or custom
+	Platform string `json:"platform"`
+	// Url without stream key, rtmp(s):// or srt://
+	Url string `json:"url"`
+	// StreamKey will be masked in responses
+	StreamKey string `json:"stream_key"`
+	// Passphrase & Latency are for srt only
+	Passphrase string `json:"passphrase,omitempty"`
+	Latency    int    `json:"latency,omitempty"`
+	CreatedAt  int64  `json:"created_at"`
+	ModifiedAt int64  `json:"modified_at"`
+}
+
+type AddStreamDestinationReq struct {
+	Name       string `json:"name" validate:"required,max=100"`
+	Platform   string `json:"platform" validate:"max=50"`
+	Url        string `json:"url" validate:"required,max=1024"`
+	StreamKey  string `json:"stream_key" validate:"required,max=512"`
+	Passphrase string `json:"passphrase" validate:"omitempty,min=10,max=79"`
+	Latency    int    `json:"latency" validate:"min=0,max=60000"`
+}
+
+// UpdateStreamDestinationReq will keep existing stream key & passphrase if those are empty
+type UpdateStreamDestinationReq struct {
+	DestinationId string `json:"destination_id" validate:"required"`
+	Name          string `json:"name" validate:"required,max=100"`
+	Platform      string `json:"platform" validate:"max=50"`
+	Url           string `json:"url" validate:"required,max=1024"`
+	StreamKey     string `json:"stream_key" validate:"max=512"`
+	Passphrase    string `json:"passphrase" validate:"omitempty,min=10,max=79"`
+	Latency       int    `json:"latency" validate:"min=0,max=60000"`
+}
+
+type DeleteStreamDestinationReq struct {
+	DestinationId string `json:"destination_id" validate:"required"`
+}
+
+type streamDestinationsModel struct {
+	app *config.AppConfig
+	db  *sql.DB
+	ctx context.Context
+}
+
+func NewStreamDestinationsModel() *streamDestinationsModel {
+	return &streamDestinationsModel{
+		app: config.AppCnf,
+		db:  config.AppCnf.DB,
+		ctx: context.Background(),
+	}
+}
+
+func validateStreamDestinationUrl(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Host == "" {
+		return errors.New("invalid url")
+	}
+	switch parsed.Scheme {
+	case "rtmp", "rtmps", "srt":
+		return nil
+	}
+	return errors.New("invalid rtmp or srt url")
+}
+
+// AddDestination will save the destination for the api key, stream key & passphrase will be encrypted
+func (m *streamDestinationsModel) AddDestination(apiKey string, r *AddStreamDestinationReq) (*StreamDestination, error) {
+	if err := validateStreamDestinationUrl(r.Url); err != nil {
+		return nil, err
+	}
+	streamKey, err := encryptStreamSecret(r.StreamKey)
+	if err != nil {
+		return nil, err
+	}
+	passphrase, err := encryptStreamSecret(r.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &StreamDestination{
+		DestinationId: uuid.NewString(),
+		Name:          r.Name,
+		Platform:      r.Platform,
+		Url:           r.Url,
+		StreamKey:     r.StreamKey,
+		Passphrase:    r.Passphrase,
+		Latency:       r.Latency,
+		CreatedAt:     time.Now().Unix(),
+	}
+	d.ModifiedAt = d.CreatedAt
+
+	ctx, cancel := context.WithTimeout(m.ctx, 3*time.Second)
+	defer cancel()
+	query := "INSERT INTO " + m.app.FormatDBTable("stream_destinations") + " (destination_id, api_key, name, platform, url, stream_key, passphrase, latency, created_at, modified_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+	_, err = m.db.ExecContext(ctx, query, d.DestinationId, apiKey, d.Name, d.Platform, d.Url, streamKey, passphrase, d.Latency, d.CreatedAt, d.ModifiedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.masked(), nil
+}
+
+// ListDestinations will return destinations of the api key with masked stream keys
+func (m *streamDestinationsModel) ListDestinations(apiKey string) ([]*StreamDestination, error) {
+	ctx, cancel := context.WithTimeout(m.ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, "SELECT destination_id, name, platform, url, latency, created_at, modified_at FROM "+m.app.FormatDBTable("stream_destinations")+" WHERE api_key = ? ORDER BY id", apiKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []*StreamDestination{}
+	for rows.Next() {
+		d := new(StreamDestination)
+		if err = rows.Scan(&d.DestinationId, &d.Name, &d.Platform, &d.Url, &d.Latency, &d.CreatedAt, &d.ModifiedAt); err != nil {
+			return nil, err
+		}
+		d.StreamKey = "****"
+		list = append(list, d)
+	}
+
+	return list, rows.Err()
+}
+
+// UpdateDestination will update the destination of the api key
+func (m *streamDestinationsModel) UpdateDestination(apiKey string, r *UpdateStreamDestinationReq) (*StreamDestination, error) {
+	d, err := m.GetDestination(apiKey, r.DestinationId)
+	if err != nil {
+		return nil, err
+	}
+	if err = validateStreamDestinationUrl(r.Url); err != nil {
+		return nil, err
+	}
+
+	d.Name = r.Name
+	d.Platform = r.Platform
+	d.Url = r.Url
+	d.Latency = r.Latency
+	if r.StreamKey != "" {
+		d.StreamKey = r.StreamKey
+	}
+	if r.Passphrase != "" {
+		d.Passphrase = r.Passphrase
+	}
+	d.ModifiedAt = time.Now().Unix()
+
+	streamKey, err := encryptStreamSecret(d.StreamKey)
+	if err != nil {
+		return nil, err
+	}
+	passphrase, err := encryptStreamSecret(d.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, 3*time.Second)
+	defer cancel()
+	query := "UPDATE " + m.app.FormatDBTable("stream_destinations") + " SET name = ?, platform = ?, url = ?, stream_key = ?, passphrase = ?, latency = ?, modified_at = ? WHERE destination_id = ? AND api_key = ?"
+	_, err = m.db.ExecContext(ctx, query, d.Name, d.Platform, d.Url, streamKey, passphrase, d.Latency, d.ModifiedAt, d.DestinationId, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.masked(), nil
+}
+
+func (m *streamDestinationsModel) DeleteDestination(apiKey, destinationId string) error {
+	ctx, cancel := context.WithTimeout(m.ctx, 3*time.Second)
+	defer cancel()
+
+	res, err := m.db.ExecContext(ctx, "DELETE FROM "+m.app.FormatDBTable("stream_destinations")+" WHERE destination_id = ? AND api_key = ?", destinationId, apiKey)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New("no destination found")
+	}
+	return nil
+}
+
+// GetDestination will return the destination of the api key with decrypted stream key
+func (m *streamDestinationsModel) GetDestination(apiKey, destinationId string) (*StreamDestination, error) {
+	ctx, cancel := context.WithTimeout(m.ctx, 3*time.Second)
+	defer cancel()
+
+	d := new(StreamDestination)
+	var streamKey, passphrase string
+	row := m.db.QueryRowContext(ctx, "SELECT destination_id, name, platform, url, stream_key, passphrase, latency, created_at, modified_at FROM "+m.app.FormatDBTable("stream_destinations")+" WHERE destination_id = ? AND api_key = ?", destinationId, apiKey)
+	err := row.Scan(&d.DestinationId, &d.Name, &d.Platform, &d.Url, &streamKey, &passphrase, &d.Latency, &d.CreatedAt, &d.ModifiedAt)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, errors.New("no destination found")
+	case err != nil:
+		return nil, err
+	}
+
+	if d.StreamKey, err = decryptStreamSecret(streamKey); err != nil {
+		return nil, err
+	}
+	if d.Passphrase, err = decryptStreamSecret(passphrase); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *StreamDestination) masked() *StreamDestination {
+	c := *d
+	c.StreamKey = "****"
+	c.Passphrase = ""
+	return &c
+}
+
+// streamUrl will add stream key with the url, for srt it will be used as streamid
+func (d *StreamDestination) streamUrl() string {
+	if streamUrlProtocol(d.Url) == "srt" {
+		u, err := url.Parse(d.Url)
+		if err != nil {
+			return d.Url
+		}
+		q := u.Query()
+		q.Set("streamid", d.StreamKey)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return strings.TrimSuffix(d.Url, "/") + "/" + d.StreamKey
+}
+
+func streamSecretCipher() (cipher.AEAD, error) {
+	secret := config.AppCnf.RecorderInfo.Rtmp.EncryptionKey
+	if secret == "" {
+		secret = config.AppCnf.Client.Secret
+	}
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptStreamSecret will encrypt using AES-GCM, nonce will be prepended with the result
+func encryptStreamSecret(plain string) (string, error) {
+	if plain == "" {
+		return "", nil
+	}
+	gcm, err := streamSecretCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(plain), nil)), nil
+}
+
+func decryptStreamSecret(encrypted string) (string, error) {
+	if encrypted == "" {
+		return "", nil
+	}
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := streamSecretCipher()
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("invalid encrypted data")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("unable to decrypt stream key, encryption key may have been changed")
+	}
+	return string(plain), nil
+}
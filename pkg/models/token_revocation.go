@@ -0,0 +1,182 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"time"
+)
+
+const (
+	// revokedTokensKey is a sorted set of jti, score is the expiry of the token
+	revokedTokensKey = "pnm:revoked_tokens"
+	// issuedTokensKey keeps jti of the tokens issued for a user of a room
+	issuedTokensKey = "pnm:issued_tokens:"
+	// revokedIdentitiesKey + roomId is a sorted set of user ids, score is the time till the user is blocked
+	revokedIdentitiesKey = "pnm:revoked_identities:"
+)
+
+type RevokeTokenReq struct {
+	// Token will be revoked, or all the tokens of the user if empty
+	Token  string `json:"token"`
+	RoomId string `json:"room_id" validate:"required_without=Token"`
+	UserId string `json:"user_id" validate:"required_without=Token"`
+	Msg    string `json:"msg"`
+}
+
+type tokenRevocationModel struct {
+	rc  *redis.Client
+	ctx context.Context
+}
+
+func NewTokenRevocationModel() *tokenRevocationModel {
+	return &tokenRevocationModel{
+		rc:  config.AppCnf.RDS,
+		ctx: context.Background(),
+	}
+}
+
+func (m *tokenRevocationModel) IsTokenRevoked(jti string) bool {
+	_, err := m.rc.ZScore(m.ctx, revokedTokensKey, jti).Result()
+	if err == redis.Nil {
+		return false
+	} else if err != nil {
+		log.Errorln(err)
+		return false
+	}
+	return true
+}
+
+// RevokeToken will revoke the token or all the tokens issued for the user
+// & remove the user from the room if online. Returns number of revoked tokens.
+func (m *tokenRevocationModel) RevokeToken(r *RevokeTokenReq) (int, error) {
+	tokens := make(map[string]float64)
+	if r.Token != "" {
+		claims, out, err := NewAuthTokenModel().parseJoinTokenClaims(r.Token, renewTokenLeeway)
+		if err != nil {
+			return 0, err
+		}
+		if out.ID == "" || out.Expiry == nil || claims.Video == nil {
+			return 0, errors.New("token can't be revoked")
+		}
+		tokens[out.ID] = float64(out.Expiry.Time().Unix())
+		r.RoomId = claims.Video.Room
+		r.UserId = claims.Identity
+	} else {
+		res, err := m.rc.ZRangeByScoreWithScores(m.ctx, issuedTokensKey+r.RoomId+":"+r.UserId, &redis.ZRangeBy{
+			Min: strconv.FormatInt(time.Now().Add(-renewTokenLeeway).Unix(), 10),
+			Max: "+inf",
+		}).Result()
+		if err != nil {
+			return 0, err
+		}
+		for _, z := range res {
+			tokens[z.Member.(string)] = z.Score
+		}
+	}
+
+	if len(tokens) > 0 {
+		var until float64
+		pp := m.rc.Pipeline()
+		for jti, expiry := range tokens {
+			// expired token can be renewed within leeway, so we'll keep it longer
+			pp.ZAdd(m.ctx, revokedTokensKey, &redis.Z{
+				Score:  expiry + renewTokenLeeway.Seconds(),
+				Member: jti,
+			})
+			if expiry+renewTokenLeeway.Seconds() > until {
+				until = expiry + renewTokenLeeway.Seconds()
+			}
+		}
+		_, err := pp.Exec(m.ctx)
+		if err != nil {
+			log.Errorln(err)
+			return 0, err
+		}
+		m.removeExpired(revokedTokensKey)
+
+		// same as ban, user can't get a new token or join with another one
+		// until the revoked tokens have expired
+		err = m.blockIdentityUntil(r.RoomId, r.UserId, int64(until))
+		if err != nil {
+			log.Errorln(err)
+			return 0, err
+		}
+	}
+
+	// now force disconnect, user may not be online
+	msg := r.Msg
+	if msg == "" {
+		msg = "your access to this session has been revoked"
+	}
+	err := NewUserModel().RemoveParticipant(&plugnmeet.RemoveParticipantReq{
+		RoomId: r.RoomId,
+		UserId: r.UserId,
+		Msg:    msg,
+	})
+	if err != nil {
+		log.Infoln("revoked user wasn't removed from room " + r.RoomId + ": " + err.Error())
+	}
+
+	return len(tokens), nil
+}
+
+// blockIdentityUntil will keep the later time if the user was blocked already
+func (m *tokenRevocationModel) blockIdentityUntil(roomId, userId string, until int64) error {
+	key := revokedIdentitiesKey + roomId
+	current, err := m.rc.ZScore(m.ctx, key, userId).Result()
+	if err == nil && int64(current) >= until {
+		return nil
+	}
+
+	_, err = m.rc.ZAdd(m.ctx, key, &redis.Z{
+		Score:  float64(until),
+		Member: userId,
+	}).Result()
+	if err != nil {
+		return err
+	}
+	m.removeExpired(key)
+	return nil
+}
+
+// IsIdentityRevoked will be true until the revoked tokens of the user have expired
+func (m *tokenRevocationModel) IsIdentityRevoked(roomId, userId string) bool {
+	until, err := m.rc.ZScore(m.ctx, revokedIdentitiesKey+roomId, userId).Result()
+	if err != nil {
+		return false
+	}
+	return int64(until) > time.Now().Unix()
+}
+
+// trackIssuedToken will keep jti of the user until token expiry
+// so that all the tokens of the user can be revoked later
+func (m *tokenRevocationModel) trackIssuedToken(roomId, userId, jti string, expiry time.Time) {
+	key := issuedTokensKey + roomId + ":" + userId
+	_, err := m.rc.ZAdd(m.ctx, key, &redis.Z{
+		Score:  float64(expiry.Unix()),
+		Member: jti,
+	}).Result()
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	m.removeExpired(key)
+}
+
+// removeExpired will clean expired tokens from the set
+// and extend expiry of the key to the last token expiry
+func (m *tokenRevocationModel) removeExpired(key string) {
+	now := time.Now().Unix()
+	m.rc.ZRemRangeByScore(m.ctx, key, "-inf", strconv.FormatInt(now, 10))
+
+	last, err := m.rc.ZRevRangeWithScores(m.ctx, key, 0, 0).Result()
+	if err != nil || len(last) == 0 {
+		return
+	}
+	m.rc.ExpireAt(m.ctx, key, time.Unix(int64(last[0].Score), 0))
+}
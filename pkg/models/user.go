@@ -8,6 +8,8 @@ import (
 	"github.com/livekit/protocol/livekit"
 	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
 	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/twitchtv/twirp"
 )
 
 type userModel struct {
@@ -81,17 +83,15 @@ func (u *userModel) updateLockSettingsAllUsers(r *plugnmeet.UpdateUserLockSettin
 	if err != nil {
 		return err
 	}
-	meta := make([]byte, len(info.Metadata))
-	copy(meta, info.Metadata)
-
-	m := new(plugnmeet.RoomMetadata)
-	_ = json.Unmarshal(meta, m)
+	m, err := ParseRoomMetadata(info.Metadata)
+	if err != nil {
+		return err
+	}
 
 	l := u.changeLockSettingsMetadata(r.Service, r.Direction, m.DefaultLockSettings)
 	m.DefaultLockSettings = l
 
-	newMeta, _ := json.Marshal(m)
-	_, err = u.roomService.UpdateRoomMetadata(r.RoomId, string(newMeta))
+	_, err = u.roomService.UpdateRoomMetadataByStruct(r.RoomId, m)
 
 	return err
 }
@@ -111,6 +111,8 @@ func (u *userModel) updateParticipantLockMetadata(um updateParticipantLockMetada
 		m := new(plugnmeet.UserMetadata)
 		_ = json.Unmarshal(meta, m)
 		l := u.changeLockSettingsMetadata(um.service, um.direction, m.LockSettings)
+		// sources those were denied during token generation will be kept locked
+		u.roomService.loadUserMediaPermissions(um.roomId, um.participantInfo.Identity).applyToLockSettings(l)
 		m.LockSettings = l
 
 		newMeta, _ := json.Marshal(m)
@@ -223,8 +225,38 @@ func (u *userModel) muteUnmuteAllMic(r *plugnmeet.MuteUnMuteTrackReq) error {
 	return nil
 }
 
+type UserRemovedMsg struct {
+	Msg     string `json:"msg"`
+	Blocked bool   `json:"blocked"`
+	RoomExitUrls
+}
+
 func (u *userModel) RemoveParticipant(r *plugnmeet.RemoveParticipantReq) error {
-	p, err := u.roomService.LoadParticipantInfo(r.RoomId, r.UserId)
+	// with allow_suffix policy the user may have joined using more identities
+	var removed bool
+	var err error
+	for _, identity := range u.roomService.getUserIdentities(r.RoomId, r.UserId) {
+		e := u.removeParticipantIdentity(r, identity)
+		if e == nil {
+			removed = true
+		} else if identity == r.UserId {
+			err = e
+		}
+	}
+	if !removed {
+		return err
+	}
+
+	// finally check if requested to block as well as
+	if r.BlockUser {
+		_, _ = u.roomService.AddUserToBlockList(r.RoomId, r.UserId)
+	}
+
+	return nil
+}
+
+func (u *userModel) removeParticipantIdentity(r *plugnmeet.RemoveParticipantReq, identity string) error {
+	p, err := u.roomService.LoadParticipantInfo(r.RoomId, identity)
 	if err != nil {
 		return err
 	}
@@ -242,20 +274,152 @@ func (u *userModel) RemoveParticipant(r *plugnmeet.RemoveParticipantReq) error {
 		SendTo:      []string{p.Sid},
 	})
 
+	// let the client know where to redirect
+	sendSystemMsgToUser(r.RoomId, identity, DataMsgBodyType_USER_REMOVED, &UserRemovedMsg{
+		Msg:          r.Msg,
+		Blocked:      r.BlockUser,
+		RoomExitUrls: u.roomService.LoadRoomOptions(r.RoomId).RoomExitUrls,
+	})
+
 	// now remove
-	_, err = u.roomService.RemoveParticipant(r.RoomId, r.UserId)
+	_, err = u.roomService.RemoveParticipant(r.RoomId, identity)
+	return err
+}
+
+type MuteAllMicsReq struct {
+	RoomId          string `json:"room_id"`
+	LockMicrophone  *bool  `json:"lock_microphone"`
+	RequestedUserId string `json:"-"`
+}
+
+// MuteAllMics will mute microphone of all the participants except requested user.
+// If lock_microphone was set then the room default lock settings will be changed too
+// & microphone of non-admin participants will be muted as soon as published until unlock.
+func (u *userModel) MuteAllMics(r *MuteAllMicsReq) error {
+	err := u.muteUnmuteAllMic(&plugnmeet.MuteUnMuteTrackReq{
+		RoomId:          r.RoomId,
+		UserId:          "all",
+		Muted:           true,
+		RequestedUserId: r.RequestedUserId,
+	})
 	if err != nil {
 		return err
 	}
 
-	// finally check if requested to block as well as
-	if r.BlockUser {
-		_, _ = u.roomService.AddUserToBlockList(r.RoomId, r.UserId)
+	if r.LockMicrophone == nil {
+		return nil
+	}
+
+	direction := "unlock"
+	if *r.LockMicrophone {
+		direction = "lock"
+	}
+	err = u.updateLockSettingsAllUsers(&plugnmeet.UpdateUserLockSettingsReq{
+		RoomId:          r.RoomId,
+		UserId:          "all",
+		Service:         "mic",
+		Direction:       direction,
+		RequestedUserId: r.RequestedUserId,
+	})
+	if err != nil {
+		return err
 	}
 
 	return nil
 }
 
+// EnforcePublishLocks will mute the published track if the source was locked or denied for the user.
+// Livekit permission can't deny a single source, so lock settings of the metadata & media permissions
+// will be enforced when a modified client has published the track.
+func (u *userModel) EnforcePublishLocks(roomId string, p *livekit.ParticipantInfo, track *livekit.TrackInfo) {
+	if p == nil || track == nil || track.Muted {
+		return
+	}
+	denied := u.roomService.loadUserMediaPermissions(roomId, p.Identity).isSourceDenied(track.Source)
+	if !denied {
+		meta := new(plugnmeet.UserMetadata)
+		if err := json.Unmarshal([]byte(p.Metadata), meta); err != nil || meta.IsAdmin || meta.LockSettings == nil {
+			return
+		}
+		if !isTrackSourceLocked(meta.LockSettings, track.Source) {
+			return
+		}
+	}
+
+	_, err := u.roomService.MuteUnMuteTrack(roomId, p.Identity, track.Sid, true)
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+func isTrackSourceLocked(ls *plugnmeet.LockSettings, source livekit.TrackSource) bool {
+	var lock *bool
+	switch source {
+	case livekit.TrackSource_MICROPHONE:
+		lock = ls.LockMicrophone
+	case livekit.TrackSource_CAMERA:
+		lock = ls.LockWebcam
+	case livekit.TrackSource_SCREEN_SHARE, livekit.TrackSource_SCREEN_SHARE_AUDIO:
+		lock = ls.LockScreenSharing
+	}
+	return lock != nil && *lock
+}
+
+type BanParticipantReq struct {
+	RoomId string `json:"room_id"`
+	UserId string `json:"user_id" validate:"required"`
+	Msg    string `json:"msg"`
+	BanIp  bool   `json:"ban_ip"`
+}
+
+// BanParticipant will remove the user & add to the block list
+// so that the user can't rejoin using a new token until the session end.
+// Optionally, the last known ip of the user will be blocked too.
+// Ip ban is best-effort because the user was already removed & blocked,
+// so ipBanned will report whether the ip was added to the block list.
+func (u *userModel) BanParticipant(r *BanParticipantReq) (ipBanned bool, err error) {
+	// block first, so the user can be banned even after leaving the session
+	_, err = u.roomService.AddUserToBlockList(r.RoomId, r.UserId)
+	if err != nil {
+		return false, err
+	}
+
+	err = u.RemoveParticipant(&plugnmeet.RemoveParticipantReq{
+		RoomId:    r.RoomId,
+		UserId:    r.UserId,
+		Msg:       r.Msg,
+		BlockUser: true,
+	})
+	if err != nil && !isParticipantNotFound(err) {
+		return false, err
+	}
+
+	if !r.BanIp {
+		return false, nil
+	}
+	ip := u.roomService.GetParticipantIp(r.RoomId, r.UserId)
+	if ip == "" {
+		log.Infoln("no ip found to ban of user " + r.UserId + " in room " + r.RoomId)
+		return false, nil
+	}
+	_, err = u.roomService.AddIpToBlockList(r.RoomId, ip)
+	if err != nil {
+		log.Errorln(err)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// isParticipantNotFound will check if livekit has returned not found error
+func isParticipantNotFound(err error) bool {
+	var te twirp.Error
+	if errors.As(err, &te) {
+		return te.Code() == twirp.NotFound
+	}
+	return false
+}
+
 func (u *userModel) SwitchPresenter(r *plugnmeet.SwitchPresenterReq) error {
 	participants, err := u.roomService.LoadParticipants(r.RoomId)
 	if err != nil {
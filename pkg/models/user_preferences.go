@@ -0,0 +1,148 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	"time"
+)
+
+type UserPreferences struct {
+	PreferredLanguage string                   `json:"preferred_language,omitempty"`
+	Captions          *CaptionPreferences      `json:"captions,omitempty"`
+	Notifications     *NotificationPreferences `json:"notifications,omitempty"`
+}
+
+type CaptionPreferences struct {
+	Enabled  bool   `json:"enabled"`
+	Language string `json:"language,omitempty"`
+	FontSize string `json:"font_size,omitempty"`
+}
+
+type NotificationPreferences struct {
+	Sound        bool `json:"sound"`
+	ChatMessages bool `json:"chat_messages"`
+	UserJoined   bool `json:"user_joined"`
+}
+
+type UserPreferencesReq struct {
+	UserId      string           `json:"user_id" validate:"required,require-valid-Id"`
+	Preferences *UserPreferences `json:"preferences"`
+}
+
+// userMetadataWithPreferences will add preferences with user metadata.
+// UserMetadata is part of protocol, so we'll keep preferences beside it.
+type userMetadataWithPreferences struct {
+	*plugnmeet.UserMetadata
+	Preferences *UserPreferences `json:"preferences,omitempty"`
+}
+
+type userPreferencesModel struct {
+	app *config.AppConfig
+	db  *sql.DB
+	ctx context.Context
+}
+
+func NewUserPreferencesModel() *userPreferencesModel {
+	return &userPreferencesModel{
+		app: config.AppCnf,
+		db:  config.AppCnf.DB,
+		ctx: context.Background(),
+	}
+}
+
+// preferencesApiKey will return the key to namespace preferences, so that
+// users of different api keys won't share them. Primary key uses empty value,
+// so that preferences stored before namespacing will be kept.
+func preferencesApiKey(apiKey string) string {
+	if apiKey == config.AppCnf.Client.ApiKey {
+		return ""
+	}
+	return apiKey
+}
+
+// GetPreferences will return nil if user doesn't have any preferences
+func (m *userPreferencesModel) GetPreferences(apiKey, userId string) (*UserPreferences, error) {
+	ctx, cancel := context.WithTimeout(m.ctx, 3*time.Second)
+	defer cancel()
+
+	var preferences string
+	row := m.db.QueryRowContext(ctx, "SELECT preferences FROM "+m.app.FormatDBTable("user_preferences")+" WHERE api_key = ? AND user_id = ?", preferencesApiKey(apiKey), userId)
+	err := row.Scan(&preferences)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	p := new(UserPreferences)
+	err = json.Unmarshal([]byte(preferences), p)
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (m *userPreferencesModel) SetPreferences(apiKey string, r *UserPreferencesReq) error {
+	if r.Preferences == nil {
+		r.Preferences = new(UserPreferences)
+	}
+	preferences, err := json.Marshal(r.Preferences)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, 3*time.Second)
+	defer cancel()
+
+	query := "INSERT INTO " + m.app.FormatDBTable("user_preferences") + " (api_key, user_id, preferences) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE preferences = VALUES(preferences)"
+	_, err = m.db.ExecContext(ctx, query, preferencesApiKey(apiKey), r.UserId, string(preferences))
+
+	return err
+}
+
+// marshalMetadataWithPreferences will include stored preferences of the user
+// with metadata, so that returning user will get the same settings.
+func (m *userPreferencesModel) marshalMetadataWithPreferences(apiKey, userId string, metadata *plugnmeet.UserMetadata) ([]byte, error) {
+	p, err := m.GetPreferences(apiKey, userId)
+	if err != nil || p == nil {
+		return json.Marshal(metadata)
+	}
+
+	return json.Marshal(&userMetadataWithPreferences{
+		UserMetadata: metadata,
+		Preferences:  p,
+	})
+}
+
+// mergeUserPreferences will carry preferences of the current metadata over to the new one.
+// UserMetadata of the protocol doesn't have preferences, so those will be lost otherwise.
+func mergeUserPreferences(newMetadata, currentMetadata string) string {
+	n := make(map[string]json.RawMessage)
+	if err := json.Unmarshal([]byte(newMetadata), &n); err != nil {
+		return newMetadata
+	}
+	if _, ok := n["preferences"]; ok {
+		return newMetadata
+	}
+
+	c := make(map[string]json.RawMessage)
+	if err := json.Unmarshal([]byte(currentMetadata), &c); err != nil {
+		return newMetadata
+	}
+	p, ok := c["preferences"]
+	if !ok {
+		return newMetadata
+	}
+
+	n["preferences"] = p
+	marshal, err := json.Marshal(n)
+	if err != nil {
+		return newMetadata
+	}
+	return string(marshal)
+}
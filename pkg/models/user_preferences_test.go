@@ -0,0 +1,23 @@
+package models
+
+import "testing"
+
+func TestMergeUserPreferences(t *testing.T) {
+	tests := []struct {
+		newMeta     string
+		currentMeta string
+		want        string
+	}{
+		{`{"raised_hand":true}`, `{"raised_hand":false,"preferences":{"preferred_language":"de"}}`, `{"preferences":{"preferred_language":"de"},"raised_hand":true}`},
+		{`{"raised_hand":true,"preferences":{"preferred_language":"en"}}`, `{"preferences":{"preferred_language":"de"}}`, `{"raised_hand":true,"preferences":{"preferred_language":"en"}}`},
+		{`{"raised_hand":true}`, `{"raised_hand":false}`, `{"raised_hand":true}`},
+		{`{"raised_hand":true}`, ``, `{"raised_hand":true}`},
+		{`invalid`, `{"preferences":{}}`, `invalid`},
+	}
+
+	for _, tt := range tests {
+		if got := mergeUserPreferences(tt.newMeta, tt.currentMeta); got != tt.want {
+			t.Errorf("mergeUserPreferences(%s, %s) = %s, want %s", tt.newMeta, tt.currentMeta, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+const (
+	verifyNonceKey = "pnm:verify_nonce:"
+	verifyNonceTTL = time.Minute
+)
+
+// GenerateVerifyNonce will issue a short-lived nonce bound to the token & user agent.
+// Client must echo it during verify token, so captured request can't be replayed.
+func (a *authTokenModel) GenerateVerifyNonce(token, userAgent string) (string, error) {
+	nonce := randomHex(16)
+	_, err := a.rs.rc.Set(a.rs.ctx, verifyNonceKey+nonce, verifyNonceBinding(token, userAgent), verifyNonceTTL).Result()
+	if err != nil {
+		return "", err
+	}
+
+	return nonce, nil
+}
+
+// ConsumeVerifyNonce will delete the nonce in the same transaction,
+// so it can be used only once
+func (a *authTokenModel) ConsumeVerifyNonce(nonce, token, userAgent string) error {
+	if nonce == "" {
+		return errors.New("verification nonce required")
+	}
+
+	key := verifyNonceKey + nonce
+	var get *redis.StringCmd
+	_, err := a.rs.rc.TxPipelined(a.rs.ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(a.rs.ctx, key)
+		pipe.Del(a.rs.ctx, key)
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	if get.Val() == "" || get.Val() != verifyNonceBinding(token, userAgent) {
+		return errors.New("invalid or expired verification nonce")
+	}
+	return nil
+}
+
+func verifyNonceBinding(token, userAgent string) string {
+	sum := sha256.Sum256([]byte(token + "|" + userAgent))
+	return hex.EncodeToString(sum[:])
+}
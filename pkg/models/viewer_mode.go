@@ -0,0 +1,144 @@
+package models
+
+import (
+	"errors"
+	"github.com/goccy/go-json"
+	"github.com/livekit/protocol/auth"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+)
+
+// JoinModeViewer will join as passive audience, user can only subscribe
+const JoinModeViewer = "viewer"
+
+// RoomViewerOptions will let the room serve large passive audience using WebRTC
+type RoomViewerOptions struct {
+	AllowViewers bool `json:"allow_viewers,omitempty"`
+	// MaxViewers 0 means unlimited
+	MaxViewers int `json:"max_viewers,omitempty" validate:"min=0"`
+	// MaxInteractiveParticipants will be set by us from max_participants of the room,
+	// because livekit will count viewers too
+	MaxInteractiveParticipants uint32 `json:"max_interactive_participants,omitempty"`
+}
+
+// viewerMetadata is simplified metadata of the viewer,
+// so that clients can render view only interface
+type viewerMetadata struct {
+	*plugnmeet.UserMetadata
+	JoinMode string `json:"join_mode"`
+}
+
+// prepareViewerLimits will move max participants of the room to interactive participants,
+// so that viewers won't be counted against it
+func (o *RoomViewerOptions) prepareViewerLimits(r *plugnmeet.CreateRoomReq) {
+	o.MaxInteractiveParticipants = 0
+	if !o.AllowViewers || r.MaxParticipants == nil || *r.MaxParticipants == 0 {
+		return
+	}
+
+	o.MaxInteractiveParticipants = *r.MaxParticipants
+	// viewers will be checked by us, so livekit shouldn't reject them
+	max := uint32(0)
+	if o.MaxViewers > 0 {
+		max = o.MaxInteractiveParticipants + uint32(o.MaxViewers)
+	}
+	r.MaxParticipants = &max
+}
+
+func isViewerMetadata(metadata string) bool {
+	if metadata == "" {
+		return false
+	}
+	m := new(struct {
+		JoinMode string `json:"join_mode"`
+	})
+	_ = json.Unmarshal([]byte(metadata), m)
+	return m.JoinMode == JoinModeViewer
+}
+
+// DoGenerateViewerToken will generate subscribe only token.
+// Viewers will be hidden & won't get any lock settings, preferences or presenter role.
+func (a *authTokenModel) DoGenerateViewerToken(g *plugnmeet.GenerateTokenReq) (string, error) {
+	opts := a.rs.LoadRoomOptions(g.RoomId)
+	if !opts.AllowViewers {
+		return "", errors.New("viewer mode isn't allowed for this room")
+	}
+	if a.rs.IsUserExistInBlockList(g.RoomId, g.UserInfo.UserId) {
+		return "", errors.New("this user is blocked to join this session")
+	}
+
+	lock := new(bool)
+	*lock = true
+	metadata, err := json.Marshal(&viewerMetadata{
+		UserMetadata: &plugnmeet.UserMetadata{
+			LockSettings: &plugnmeet.LockSettings{
+				LockMicrophone:      lock,
+				LockWebcam:          lock,
+				LockScreenSharing:   lock,
+				LockChat:            lock,
+				LockChatSendMessage: lock,
+				LockChatFileShare:   lock,
+				LockWhiteboard:      lock,
+				LockSharedNotepad:   lock,
+				LockPrivateChat:     lock,
+			},
+		},
+		JoinMode: JoinModeViewer,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	canPublish := false
+	return a.signJoinToken(&auth.ClaimGrants{
+		Identity: g.UserInfo.UserId,
+		Name:     g.UserInfo.Name,
+		Video: &auth.VideoGrant{
+			RoomJoin:       true,
+			Room:           g.RoomId,
+			Hidden:         true,
+			CanPublish:     &canPublish,
+			CanPublishData: &canPublish,
+		},
+		Metadata: string(metadata),
+	}, a.joinTokenValidity())
+}
+
+// checkParticipantLimits will check interactive participants & viewers separately,
+// rejoining user won't be counted.
+func (a *authTokenModel) checkParticipantLimits(roomId, identity string, viewer bool) error {
+	opts := a.rs.LoadRoomOptions(roomId)
+	if !opts.AllowViewers {
+		// livekit will check max participants
+		return nil
+	}
+	if viewer && opts.MaxViewers == 0 {
+		return nil
+	}
+	if !viewer && opts.MaxInteractiveParticipants == 0 {
+		return nil
+	}
+
+	participants, err := a.rs.LoadParticipants(roomId)
+	if err != nil {
+		return err
+	}
+	viewers, interactive := 0, 0
+	for _, p := range participants {
+		if p.Identity == identity || p.Identity == config.RECORDER_BOT || p.Identity == config.RTMP_BOT {
+			continue
+		}
+		if isViewerMetadata(p.Metadata) {
+			viewers++
+		} else {
+			interactive++
+		}
+	}
+
+	if viewer && viewers >= opts.MaxViewers {
+		return errors.New("notifications.max-viewers-reached")
+	} else if !viewer && interactive >= int(opts.MaxInteractiveParticipants) {
+		return errors.New("notifications.max-participants-reached")
+	}
+	return nil
+}
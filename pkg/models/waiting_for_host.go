@@ -0,0 +1,128 @@
+package models
+
+import (
+	"github.com/goccy/go-json"
+	"github.com/livekit/protocol/livekit"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	hostJoinedKey      = "pnm:host_joined:"
+	waitingForHostKey  = "pnm:waiting_for_host:"
+	waitingForHostNote = "notifications.waiting-for-host"
+)
+
+type WaitingForHostMsg struct {
+	Waiting bool   `json:"waiting"`
+	Msg     string `json:"msg,omitempty"`
+}
+
+// IsWaitingForHost will return true if the room was created with wait_for_host
+// & no moderator has joined yet
+func (r *RoomService) IsWaitingForHost(roomId string) bool {
+	if !r.LoadRoomOptions(roomId).WaitForHost {
+		return false
+	}
+	exist, err := r.rc.Exists(r.ctx, hostJoinedKey+roomId).Result()
+	if err != nil {
+		log.Errorln(err)
+		return false
+	}
+	return exist == 0
+}
+
+// holdForHost will record the user, so that we can release after host joined
+func (r *RoomService) holdForHost(roomId, userId string) {
+	key := waitingForHostKey + roomId
+	pp := r.rc.Pipeline()
+	pp.SAdd(r.ctx, key, userId)
+	pp.Expire(r.ctx, key, r.RoomKeyTTL(roomId))
+	_, err := pp.Exec(r.ctx)
+	if err != nil {
+		log.Errorln(err)
+	}
+}
+
+// handleWaitingForHost will be used from webhook when participant joined
+func (r *RoomService) handleWaitingForHost(roomId string, p *livekit.ParticipantInfo) {
+	if !r.LoadRoomOptions(roomId).WaitForHost {
+		return
+	}
+
+	meta := new(plugnmeet.UserMetadata)
+	_ = json.Unmarshal([]byte(p.Metadata), meta)
+
+	if meta.IsAdmin {
+		// only first moderator will release others
+		ok, err := r.rc.SetNX(r.ctx, hostJoinedKey+roomId, p.Identity, r.RoomKeyTTL(roomId)).Result()
+		if err != nil || !ok {
+			return
+		}
+		r.releaseWaitingParticipants(roomId)
+		broadcastSystemMsg(roomId, DataMsgBodyType_WAITING_FOR_HOST, &WaitingForHostMsg{
+			Waiting: false,
+		})
+		return
+	}
+
+	waiting, _ := r.rc.SIsMember(r.ctx, waitingForHostKey+roomId, p.Identity).Result()
+	if !waiting {
+		return
+	}
+
+	if r.IsWaitingForHost(roomId) {
+		sendSystemMsgToUser(roomId, p.Identity, DataMsgBodyType_WAITING_FOR_HOST, &WaitingForHostMsg{
+			Waiting: true,
+			Msg:     waitingForHostNote,
+		})
+		return
+	}
+
+	// host joined before this user connected with the old token
+	r.releaseParticipant(roomId, p)
+	sendSystemMsgToUser(roomId, p.Identity, DataMsgBodyType_WAITING_FOR_HOST, &WaitingForHostMsg{
+		Waiting: false,
+	})
+}
+
+func (r *RoomService) releaseWaitingParticipants(roomId string) {
+	userIds, err := r.rc.SMembers(r.ctx, waitingForHostKey+roomId).Result()
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	for _, userId := range userIds {
+		p, err := r.LoadParticipantInfo(roomId, userId)
+		if err != nil {
+			// user isn't connected now
+			continue
+		}
+		r.releaseParticipant(roomId, p)
+	}
+}
+
+// releaseParticipant will allow to publish again, locked sources will be kept muted by lock settings
+func (r *RoomService) releaseParticipant(roomId string, p *livekit.ParticipantInfo) {
+	permission := p.Permission
+	if permission == nil {
+		permission = &livekit.ParticipantPermission{
+			CanSubscribe:   true,
+			CanPublishData: true,
+		}
+	}
+	// user may not be allowed to publish at all
+	permission.CanPublish = r.loadUserMediaPermissions(roomId, p.Identity).canPublish()
+
+	_, err := r.UpdateParticipantPermission(roomId, p.Identity, permission)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	r.rc.SRem(r.ctx, waitingForHostKey+roomId, p.Identity)
+}
+
+func (r *RoomService) DeleteWaitingForHost(roomId string) (int64, error) {
+	return r.rc.Del(r.ctx, hostJoinedKey+roomId, waitingForHostKey+roomId).Result()
+}
@@ -50,6 +50,11 @@ func NewWebhookModel(e *livekit.WebhookEvent) {
 		w.trackPublished()
 	case "track_unpublished":
 		w.trackUnpublished()
+
+	case "egress_updated":
+		w.egressUpdated()
+	case "egress_ended":
+		w.egressEnded()
 	}
 
 }
@@ -73,8 +78,7 @@ func (w *webhookEvent) roomStarted() {
 	}
 
 	if event.Room.Metadata != "" {
-		info := new(plugnmeet.RoomMetadata)
-		err = json.Unmarshal([]byte(event.Room.Metadata), info)
+		info, err := ParseRoomMetadata(event.Room.Metadata)
 		if err == nil {
 			info.StartedAt = uint64(time.Now().Unix())
 			if info.RoomFeatures.RoomDuration != nil && *info.RoomFeatures.RoomDuration > 0 {
@@ -89,10 +93,7 @@ func (w *webhookEvent) roomStarted() {
 				bm := NewBreakoutRoomModel()
 				_ = bm.PostTaskAfterRoomStartWebhook(room.RoomId, info)
 			}
-			marshal, err := json.Marshal(info)
-			if err == nil {
-				_, _ = w.roomService.UpdateRoomMetadata(room.RoomId, string(marshal))
-			}
+			_, _ = w.roomService.UpdateRoomMetadataByStruct(room.RoomId, info)
 		}
 	}
 }
@@ -100,8 +101,23 @@ func (w *webhookEvent) roomStarted() {
 func (w *webhookEvent) roomFinished() {
 	event := w.event
 
-	// webhook notification
-	go w.sendToWebhookNotifier(event)
+	endReason := w.roomService.GetRoomEndReason(event.Room.Name)
+	w.roomService.AddRoomTimelineEvent(event.Room.Name, &RoomTimelineEvent{
+		Type: "room_ended",
+		Msg:  endReason,
+	})
+
+	// webhook notification with end reason
+	go func() {
+		msg := &roomFinishedNotifyEvent{
+			CommonNotifyEvent: utils.PrepareCommonWebhookNotifyEvent(event),
+			EndReason:         endReason,
+		}
+		err := w.notifier.Notify(event.Room.Sid, msg)
+		if err != nil {
+			log.Errorln(err)
+		}
+	}()
 
 	room := &RoomInfo{
 		Sid:       event.Room.Sid,
@@ -123,9 +139,21 @@ func (w *webhookEvent) roomFinished() {
 				Sid: event.Room.Sid,
 			})
 			_ = f.DeleteRoomUploadedDir()
+			f.DeleteChatAttachments()
 		}()
 	}
 
+	_, _ = w.roomService.DeleteTrackedChatMessages(event.Room.Name)
+	_, _ = w.roomService.DeleteChatTranslationLanguages(event.Room.Name)
+	_, _ = w.roomService.DeleteChatHistory(event.Room.Name)
+	_, _ = w.roomService.DeleteChatPins(event.Room.Name)
+	if err := w.roomService.ArchiveWhiteboardState(event.Room.Name, event.Room.Sid); err != nil {
+		log.Errorln(err)
+	}
+	_ = w.roomService.DeleteWhiteboardState(event.Room.Name)
+	_ = w.roomService.DeleteWhiteboardDrawPermissions(event.Room.Name)
+	_ = w.roomService.DeleteScreenAnnotations(event.Room.Name)
+
 	// clear chatroom from memory
 	msg := &WebsocketToRedis{
 		Type:   "deleteRoom",
@@ -154,9 +182,40 @@ func (w *webhookEvent) roomFinished() {
 	// clear users block list
 	_, _ = w.roomService.DeleteRoomBlockList(event.Room.Name)
 
+	// stream keys shouldn't be valid for the next session
+	go NewRoomIngressModel().DeleteAllRoomIngress(event.Room.Name)
+
+	// write session summary
+	sm := NewRoomSessionsModel()
+	err = sm.ArchiveSession(event.Room, endReason)
+	if err != nil {
+		log.Errorln(err)
+	}
+
+	// clear room options
+	_, _ = w.roomService.DeleteRoomOptions(event.Room.Name)
+	_, _ = w.roomService.DeleteRoomEndReason(event.Room.Name)
+
+	// clear participants presence
+	_, _ = w.roomService.DeleteParticipantsPresence(event.Room.Name)
+	_, _ = w.roomService.DeleteActiveIdentities(event.Room.Name)
+	_, _ = w.roomService.DeleteWaitingForHost(event.Room.Name)
+
+	// clear speaker queue
+	sq := NewSpeakerQueueModel()
+	_, _ = sq.DeleteQueue(event.Room.Name)
+
+	// clear raised hands
+	rh := NewRaiseHandModel()
+	_, _ = rh.DeleteQueue(event.Room.Name)
+
 	// clean polls
 	pm := NewPollsModel()
+	if err := pm.ArchivePollResults(event.Room.Name, event.Room.Sid); err != nil {
+		log.Errorln(err)
+	}
 	_ = pm.CleanUpPolls(event.Room.Name)
+	_, _ = w.roomService.DeleteRoomDynamicKeys(event.Room.Name)
 
 	// remove all breakout rooms
 	go func() {
@@ -182,6 +241,18 @@ func (w *webhookEvent) participantJoined() {
 	if err != nil {
 		log.Errorln(err)
 	}
+
+	// livekit token of banned or revoked user may still be valid
+	if w.roomService.IsUserExistInBlockList(event.Room.Name, w.roomService.GetUserIdByIdentity(event.Room.Name, event.Participant.Identity)) {
+		_, _ = w.roomService.RemoveParticipant(event.Room.Name, event.Participant.Identity)
+	}
+
+	w.roomService.setIngressParticipantMetadata(event.Room.Name, event.Participant)
+	w.roomService.participantPresenceJoined(event.Room.Name, event.Participant)
+	w.roomService.trackActiveIdentity(event.Room.Name, event.Participant.Identity, event.Participant.Sid)
+	w.roomService.handleWaitingForHost(event.Room.Name, event.Participant)
+	w.recordingModel.sendRecordingConsentPrompt(event.Room.Name, event.Room.Sid, event.Participant)
+	w.roomService.updateRoomParticipantsStats(event.Room.Name, event.Participant.Identity, true)
 }
 
 func (w *webhookEvent) participantLeft() {
@@ -201,18 +272,48 @@ func (w *webhookEvent) participantLeft() {
 	if err != nil {
 		log.Errorln(err)
 	}
+
+	w.roomService.participantPresenceLeft(event.Room.Name, event.Participant)
+	w.roomService.untrackActiveIdentity(event.Room.Name, event.Participant.Identity, event.Participant.Sid)
+	w.roomService.updateRoomParticipantsStats(event.Room.Name, event.Participant.Identity, false)
+	NewSpeakerQueueModel().ParticipantLeft(event.Room.Name, w.roomService.GetUserIdByIdentity(event.Room.Name, event.Participant.Identity))
+	w.roomService.clearRoomLayoutOfParticipant(event.Room.Name, event.Participant.Identity)
 }
 
 func (w *webhookEvent) trackPublished() {
+	event := w.event
+	if event.Room != nil {
+		// start egress of the track if individual recording is running
+		go w.recordingModel.TrackPublished(event.Room.Sid, event.Participant, event.Track)
+		go w.userModel.EnforcePublishLocks(event.Room.Name, event.Participant, event.Track)
+	}
+
 	// webhook notification
 	go w.sendToWebhookNotifier(w.event)
 }
 
 func (w *webhookEvent) trackUnpublished() {
+	if w.event.Room != nil {
+		go w.roomService.ScreenShareUnpublished(w.event.Room.Name, w.event.Track)
+	}
+
 	// webhook notification
 	go w.sendToWebhookNotifier(w.event)
 }
 
+// egressUpdated will update state of the rtmp destinations
+func (w *webhookEvent) egressUpdated() {
+	go w.recordingModel.RtmpEgressUpdated(w.event.EgressInfo)
+}
+
+// egressEnded will add file of the track egress to the recording manifest
+// or end the rtmp destinations & hls stream
+func (w *webhookEvent) egressEnded() {
+	go w.recordingModel.TrackEgressEnded(w.event.EgressInfo)
+	go w.recordingModel.RtmpEgressUpdated(w.event.EgressInfo)
+	go w.recordingModel.HlsEgressEnded(w.event.EgressInfo)
+}
+
 func (w *webhookEvent) sendToWebhookNotifier(event *livekit.WebhookEvent) {
 	msg := utils.PrepareCommonWebhookNotifyEvent(event)
 
@@ -0,0 +1,390 @@
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWebhookMinInterval  = 50 * time.Millisecond
+	defaultWebhookBatchWindow  = 2 * time.Second
+	defaultWebhookMaxQueueSize = 1000
+	defaultWebhookConcurrency  = 4
+	// webhookQueueKey keeps deliveries of a room for an url in order,
+	// it's stored in redis so that pending events won't be lost on restart
+	webhookQueueKey = "pnm:webhook_queue:"
+	// webhookPendingQueuesKey is a sorted set of queues with the best priority of their events as score
+	webhookPendingQueuesKey = "pnm:webhook_pending_queues"
+	// webhookQueueLockKey will make sure only one instance is delivering events of a queue
+	webhookQueueLockKey = "pnm:webhook_queue_lock:"
+	webhookQueueTTL     = 24 * time.Hour
+	webhookMaxBatchSize = 100
+	// webhookUrlIdleTimeout after which the limiter of the url will be removed
+	webhookUrlIdleTimeout = 5 * time.Minute
+)
+
+// priorities of webhook events, lower value will be delivered first
+const (
+	webhookPriorityCritical = iota
+	webhookPriorityHigh
+	webhookPriorityNormal
+	webhookPriorityLow
+)
+
+var webhookEventPriorities = map[string]int{
+	"room_finished":             webhookPriorityCritical,
+	"room_started":              webhookPriorityCritical,
+	"start_recording":           webhookPriorityHigh,
+	"end_recording":             webhookPriorityHigh,
+	"pause_recording":           webhookPriorityHigh,
+	"resume_recording":          webhookPriorityHigh,
+	"recording_proceeded":       webhookPriorityHigh,
+	"recording_expired":         webhookPriorityNormal,
+	"recording_track_proceeded": webhookPriorityHigh,
+	"transcript_ready":          webhookPriorityNormal,
+	"transcript_failed":         webhookPriorityNormal,
+	"recording_quota_exceeded":  webhookPriorityHigh,
+	"recording_transcoded":      webhookPriorityNormal,
+	"start_rtmp":                webhookPriorityHigh,
+	"end_rtmp":                  webhookPriorityHigh,
+	"rtmp_destination_ended":    webhookPriorityHigh,
+	"hls_started":               webhookPriorityHigh,
+	"hls_ended":                 webhookPriorityHigh,
+	"broadcast_unhealthy":       webhookPriorityHigh,
+	"broadcast_disconnected":    webhookPriorityHigh,
+	"broadcast_paused":          webhookPriorityHigh,
+	"broadcast_resumed":         webhookPriorityHigh,
+	"poll_created":              webhookPriorityNormal,
+	"poll_closed":               webhookPriorityNormal,
+	"participant_joined":        webhookPriorityNormal,
+	"participant_left":          webhookPriorityNormal,
+	"track_published":           webhookPriorityLow,
+	"track_unpublished":         webhookPriorityLow,
+}
+
+func webhookEventPriority(event string) int {
+	if p, ok := webhookEventPriorities[strings.ToLower(event)]; ok {
+		return p
+	}
+	return webhookPriorityNormal
+}
+
+type webhookDelivery struct {
+	Url      string          `json:"url"`
+	RoomSid  string          `json:"room_sid"`
+	Event    string          `json:"event"`
+	Priority int             `json:"priority"`
+	ApiKey   string          `json:"api_key"`
+	Batching bool            `json:"batching"`
+	QueuedAt int64           `json:"queued_at"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+// CompositeWebhookEvent will be sent to the receivers who opted in for batching
+type CompositeWebhookEvent struct {
+	Event   string            `json:"event"`
+	RoomSid string            `json:"room_sid"`
+	Events  []json.RawMessage `json:"events"`
+}
+
+// webhookEnqueueScript will add the delivery at the end of the room queue
+// if it isn't full & keep the best priority of the queue as score
+var webhookEnqueueScript = redis.NewScript(`
+if redis.call('LLEN', KEYS[1]) >= tonumber(ARGV[3]) then
+	return 0
+end
+redis.call('RPUSH', KEYS[1], ARGV[1])
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+local score = redis.call('ZSCORE', KEYS[2], KEYS[1])
+if not score or tonumber(score) > tonumber(ARGV[2]) then
+	redis.call('ZADD', KEYS[2], ARGV[2], KEYS[1])
+end
+return 1
+`)
+
+// webhookAckScript will remove delivered events from the queue
+// & remove the queue from pending list if nothing left
+var webhookAckScript = redis.NewScript(`
+redis.call('LTRIM', KEYS[1], ARGV[1], -1)
+if redis.call('LLEN', KEYS[1]) == 0 then
+	redis.call('ZREM', KEYS[2], KEYS[1])
+	return 0
+end
+return 1
+`)
+
+var webhookDispatcherWake = make(chan struct{}, 1)
+
+func webhookQueueKeyOf(url, roomSid string) string {
+	sum := sha256.Sum256([]byte(url))
+	return webhookQueueKey + hex.EncodeToString(sum[:8]) + ":" + roomSid
+}
+
+// enqueueWebhookDelivery will add the delivery in the queue of the room for the url.
+// Events of a room will be delivered in order, events of different rooms in parallel.
+func enqueueWebhookDelivery(rc *redis.Client, d *webhookDelivery) error {
+	conf := config.AppCnf.Client.WebhookConf
+	max := conf.MaxQueueSize
+	if max <= 0 {
+		max = defaultWebhookMaxQueueSize
+	}
+	// some room is reserved, so that critical events e.g. room_finished won't be dropped
+	if d.Priority <= webhookPriorityHigh {
+		max += max / 10
+	}
+
+	d.QueuedAt = time.Now().UnixMilli()
+	marshal, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	added, err := webhookEnqueueScript.Run(context.Background(), rc, []string{webhookQueueKeyOf(d.Url, d.RoomSid), webhookPendingQueuesKey}, marshal, d.Priority, max, int(webhookQueueTTL.Seconds())).Int()
+	if err != nil {
+		return err
+	}
+	if added == 0 {
+		return errors.New("webhook queue is full, dropped event: " + d.Event)
+	}
+
+	select {
+	case webhookDispatcherWake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// webhookUrlLimiter will limit the concurrent deliveries & the rate for a single url,
+// so that a burst (e.g. during room end) won't trip the rate limit of the receiver
+type webhookUrlLimiter struct {
+	slots  chan struct{}
+	nextAt time.Time
+	usedAt time.Time
+}
+
+type webhookDispatcher struct {
+	sync.Mutex
+	rc       *redis.Client
+	ctx      context.Context
+	n        *notifier
+	running  map[string]bool
+	limiters map[string]*webhookUrlLimiter
+}
+
+func (s *scheduler) startWebhookDispatcher() {
+	if !config.AppCnf.Client.WebhookConf.Enable {
+		return
+	}
+	d := &webhookDispatcher{
+		rc:       s.rc,
+		ctx:      s.ctx,
+		n:        NewWebhookNotifier(),
+		running:  make(map[string]bool),
+		limiters: make(map[string]*webhookUrlLimiter),
+	}
+	go d.run()
+}
+
+func (d *webhookDispatcher) run() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-webhookDispatcherWake:
+		case <-ticker.C:
+			d.removeIdleLimiters()
+		}
+		d.dispatch()
+	}
+}
+
+// dispatch will start delivering the pending queues,
+// queues with higher priority events will get the free slots first
+func (d *webhookDispatcher) dispatch() {
+	keys, err := d.rc.ZRange(d.ctx, webhookPendingQueuesKey, 0, 999).Result()
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	for _, key := range keys {
+		d.Lock()
+		running := d.running[key]
+		d.Unlock()
+		if running {
+			continue
+		}
+
+		token := uuid.NewString()
+		locked, err := d.rc.SetNX(d.ctx, webhookQueueLockKey+key, token, d.lockTTL()).Result()
+		if err != nil || !locked {
+			continue
+		}
+
+		d.Lock()
+		d.running[key] = true
+		d.Unlock()
+		go d.processQueue(key, token)
+	}
+}
+
+func (d *webhookDispatcher) lockTTL() time.Duration {
+	timeout := config.AppCnf.Client.WebhookConf.Timeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	return 2*timeout + time.Minute
+}
+
+// processQueue will deliver the events of the queue one by one in order.
+// It will return if the url doesn't have free slot or batch window isn't over yet,
+// next dispatch will continue from there.
+func (d *webhookDispatcher) processQueue(key, token string) {
+	defer func() {
+		d.Lock()
+		delete(d.running, key)
+		d.Unlock()
+		releaseRedisLock(d.ctx, d.rc, webhookQueueLockKey+key, token)
+	}()
+
+	window := config.AppCnf.Client.WebhookConf.BatchWindow
+	if window == 0 {
+		window = defaultWebhookBatchWindow
+	}
+
+	for {
+		items, err := d.rc.LRange(d.ctx, key, 0, webhookMaxBatchSize-1).Result()
+		if err != nil {
+			log.Errorln(err)
+			return
+		}
+		if len(items) == 0 {
+			_, _ = webhookAckScript.Run(d.ctx, d.rc, []string{key, webhookPendingQueuesKey}, 0).Result()
+			return
+		}
+
+		deliveries := make([]*webhookDelivery, 0, len(items))
+		for _, item := range items {
+			w := new(webhookDelivery)
+			if err = json.Unmarshal([]byte(item), w); err == nil {
+				deliveries = append(deliveries, w)
+			}
+		}
+		first := new(webhookDelivery)
+		if len(deliveries) > 0 {
+			first = deliveries[0]
+		}
+
+		count := 1
+		payload := []byte(first.Payload)
+		if first.Batching && first.RoomSid != "" {
+			// wait for related events of the room, those will be sent together in the same order
+			if time.Since(time.UnixMilli(first.QueuedAt)) < window {
+				return
+			}
+			count = len(items)
+			if len(deliveries) > 1 {
+				payload, err = buildCompositeWebhook(first.RoomSid, deliveries)
+				if err != nil {
+					log.Errorln(err)
+				}
+			}
+		}
+
+		if first.Url != "" && len(payload) > 0 {
+			l := d.acquireLimiter(first.Url)
+			if l == nil {
+				return
+			}
+			d.n.postToUrl(first.Url, first.ApiKey, payload)
+			<-l.slots
+		}
+
+		left, err := webhookAckScript.Run(d.ctx, d.rc, []string{key, webhookPendingQueuesKey}, count).Int()
+		if err != nil {
+			log.Errorln(err)
+			return
+		}
+		if left == 0 {
+			return
+		}
+		d.rc.Expire(d.ctx, webhookQueueLockKey+key, d.lockTTL())
+	}
+}
+
+// buildCompositeWebhook will keep the order of the events,
+// so that e.g. room_finished won't overtake the earlier events of the room
+func buildCompositeWebhook(roomSid string, deliveries []*webhookDelivery) ([]byte, error) {
+	c := &CompositeWebhookEvent{
+		Event:   "composite",
+		RoomSid: roomSid,
+	}
+	for _, d := range deliveries {
+		c.Events = append(c.Events, d.Payload)
+	}
+	return json.Marshal(c)
+}
+
+// acquireLimiter will return nil if the url doesn't have free slot,
+// otherwise it will wait for the turn of the delivery based on min_interval
+func (d *webhookDispatcher) acquireLimiter(url string) *webhookUrlLimiter {
+	conf := config.AppCnf.Client.WebhookConf
+	interval := conf.MinInterval
+	if interval == 0 {
+		interval = defaultWebhookMinInterval
+	}
+
+	d.Lock()
+	l, ok := d.limiters[url]
+	if !ok {
+		concurrency := conf.Concurrency
+		if concurrency <= 0 {
+			concurrency = defaultWebhookConcurrency
+		}
+		l = &webhookUrlLimiter{
+			slots: make(chan struct{}, concurrency),
+		}
+		d.limiters[url] = l
+	}
+	select {
+	case l.slots <- struct{}{}:
+	default:
+		d.Unlock()
+		return nil
+	}
+
+	now := time.Now()
+	at := l.nextAt
+	if at.Before(now) {
+		at = now
+	}
+	l.nextAt = at.Add(interval)
+	l.usedAt = now
+	d.Unlock()
+
+	// only this delivery will wait for its turn, others can still be in flight
+	time.Sleep(time.Until(at))
+	return l
+}
+
+func (d *webhookDispatcher) removeIdleLimiters() {
+	d.Lock()
+	defer d.Unlock()
+
+	for url, l := range d.limiters {
+		if len(l.slots) == 0 && time.Since(l.usedAt) > webhookUrlIdleTimeout {
+			delete(d.limiters, url)
+		}
+	}
+}
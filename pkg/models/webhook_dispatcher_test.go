@@ -0,0 +1,42 @@
+package models
+
+import (
+	"github.com/goccy/go-json"
+	"testing"
+)
+
+func TestBuildCompositeWebhookKeepsOrder(t *testing.T) {
+	deliveries := []*webhookDelivery{
+		{Event: "participant_left", Priority: webhookEventPriority("participant_left"), Payload: json.RawMessage(`{"event":"participant_left"}`)},
+		{Event: "end_recording", Priority: webhookEventPriority("end_recording"), Payload: json.RawMessage(`{"event":"end_recording"}`)},
+		{Event: "room_finished", Priority: webhookEventPriority("room_finished"), Payload: json.RawMessage(`{"event":"room_finished"}`)},
+	}
+
+	payload, err := buildCompositeWebhook("RM_1", deliveries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := new(CompositeWebhookEvent)
+	if err = json.Unmarshal(payload, c); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Event != "composite" || c.RoomSid != "RM_1" || len(c.Events) != len(deliveries) {
+		t.Fatalf("unexpected composite event: %s", payload)
+	}
+	for i, e := range c.Events {
+		if string(e) != string(deliveries[i].Payload) {
+			t.Errorf("event %d = %s, want %s", i, e, deliveries[i].Payload)
+		}
+	}
+}
+
+func TestWebhookQueueKeyOf(t *testing.T) {
+	a := webhookQueueKeyOf("https://example.com/hook", "RM_1")
+	if a != webhookQueueKeyOf("https://example.com/hook", "RM_1") {
+		t.Error("key of the same url & room should be same")
+	}
+	if a == webhookQueueKeyOf("https://example.com/hook", "RM_2") || a == webhookQueueKeyOf("https://example.org/hook", "RM_1") {
+		t.Error("key of different url or room should be different")
+	}
+}
@@ -4,95 +4,192 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/base64"
+	"github.com/go-redis/redis/v8"
 	"github.com/goccy/go-json"
 	"github.com/livekit/protocol/auth"
 	"github.com/mynaparrot/plugnmeet-server/pkg/config"
 	log "github.com/sirupsen/logrus"
 	"net/http"
+	"sync"
 	"time"
 )
 
+const defaultWebhookTimeout = 10 * time.Second
+
+var (
+	webhookClient     *http.Client
+	webhookClientOnce sync.Once
+)
+
+// webhookHttpClient will return the client with timeout,
+// so that a slow receiver won't block deliveries of the queue forever
+func webhookHttpClient() *http.Client {
+	webhookClientOnce.Do(func() {
+		timeout := config.AppCnf.Client.WebhookConf.Timeout
+		if timeout <= 0 {
+			timeout = defaultWebhookTimeout
+		}
+		webhookClient = &http.Client{Timeout: timeout}
+	})
+	return webhookClient
+}
+
+// notifyRoomCacheTTL is short, because the room options can be changed during the session
+const notifyRoomCacheTTL = time.Minute
+
+// notifyRoom holds the information of the room required to deliver the events,
+// it's cached, so that we won't need to load the room for every event
+type notifyRoom struct {
+	roomId          string
+	webhookUrl      string
+	apiKey          string
+	webhookBatching bool
+	loadedAt        time.Time
+}
+
+var (
+	notifyRooms   = make(map[string]*notifyRoom)
+	notifyRoomsMu sync.Mutex
+)
+
 type notifier struct {
-	apiKey      string
-	apiSecret   string
-	urls        []string
+	rc          *redis.Client
 	webhookConf config.WebhookConf
 	roomModel   *roomModel
 }
 
 func NewWebhookNotifier() *notifier {
 	return &notifier{
-		apiKey:      config.AppCnf.Client.ApiKey,
-		apiSecret:   config.AppCnf.Client.Secret,
+		rc:          config.AppCnf.RDS,
 		webhookConf: config.AppCnf.Client.WebhookConf,
 		roomModel:   NewRoomModel(),
 	}
 }
 
 func (n *notifier) Notify(roomSid string, msg interface{}) error {
+	room := n.loadRoom(roomSid)
+
+	// stream to analytics pipelines, it's independent of webhook settings
+	NewEventStreamModel().PublishEvent(room, roomSid, msg)
+
 	if !n.webhookConf.Enable {
 		return nil
 	}
 
+	var receivers []webhookReceiver
 	if n.webhookConf.Url != "" {
-		n.urls = append(n.urls, n.webhookConf.Url)
+		receivers = append(receivers, webhookReceiver{
+			url:      n.webhookConf.Url,
+			batching: n.webhookConf.EnableBatching,
+		})
 	}
 
-	if n.webhookConf.EnableForPerMeeting {
-		// if we set roomSid then it will avoid the value of isRunning
-		roomInfo, _ := n.roomModel.GetRoomInfo("", roomSid, 0)
-		if roomInfo.WebhookUrl != "" {
-			n.urls = append(n.urls, roomInfo.WebhookUrl)
-		}
+	if n.webhookConf.EnableForPerMeeting && room.webhookUrl != "" {
+		receivers = append(receivers, webhookReceiver{
+			url:      room.webhookUrl,
+			batching: room.webhookBatching,
+		})
 	}
 
-	if len(n.urls) > 0 {
-		err := n._notify(msg)
+	if len(receivers) == 0 {
+		return nil
+	}
 
+	encoded, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	e := new(struct {
+		Event string `json:"event"`
+	})
+	_ = json.Unmarshal(encoded, e)
+
+	for _, r := range receivers {
+		err = enqueueWebhookDelivery(n.rc, &webhookDelivery{
+			Url:      r.url,
+			RoomSid:  roomSid,
+			Event:    e.Event,
+			Priority: webhookEventPriority(e.Event),
+			ApiKey:   room.apiKey,
+			Batching: r.batching,
+			Payload:  encoded,
+		})
 		if err != nil {
-			return err
+			log.Errorln(err)
 		}
 	}
 
 	return nil
 }
 
-func (n *notifier) _notify(msg interface{}) error {
-	var encoded []byte
-	var err error
+// loadRoom will return the room from cache or load it
+func (n *notifier) loadRoom(roomSid string) *notifyRoom {
+	notifyRoomsMu.Lock()
+	defer notifyRoomsMu.Unlock()
 
-	encoded, err = json.Marshal(msg)
-	if err != nil {
-		return err
+	if r, ok := notifyRooms[roomSid]; ok && time.Since(r.loadedAt) < notifyRoomCacheTTL {
+		return r
 	}
+	for sid, r := range notifyRooms {
+		if time.Since(r.loadedAt) >= notifyRoomCacheTTL {
+			delete(notifyRooms, sid)
+		}
+	}
+
+	r := &notifyRoom{
+		loadedAt: time.Now(),
+	}
+	// if we set roomSid then it will avoid the value of isRunning
+	roomInfo, _ := n.roomModel.GetRoomInfo("", roomSid, 0)
+	if roomInfo == nil || roomInfo.RoomId == "" {
+		// room may not be created yet, so won't cache
+		return r
+	}
+	opts := NewRoomService().LoadRoomOptions(roomInfo.RoomId)
+
+	r.roomId = roomInfo.RoomId
+	r.webhookUrl = roomInfo.WebhookUrl
+	r.apiKey = opts.ApiKey
+	r.webhookBatching = opts.WebhookBatching
+	notifyRooms[roomSid] = r
 
+	return r
+}
+
+type webhookReceiver struct {
+	url      string
+	batching bool
+}
+
+// postToUrl will sign the payload & send to the url.
+// The payload will be signed using the api key which created the room,
+// if it's empty or not active anymore then the primary key will be used.
+func (n *notifier) postToUrl(url, apiKey string, encoded []byte) {
 	// sign payload
 	sum := sha256.Sum256(encoded)
 	b64 := base64.StdEncoding.EncodeToString(sum[:])
 
-	at := auth.NewAccessToken(n.apiKey, n.apiSecret).
+	key, secret := NewApiKeysModel().ResolveSigningKey(apiKey)
+	at := auth.NewAccessToken(key, secret).
 		SetValidFor(5 * time.Minute).
 		SetSha256(b64)
 	token, err := at.ToJWT()
-
 	if err != nil {
-		return err
+		log.Errorln(err)
+		return
 	}
 
-	for _, url := range n.urls {
-		r, err := http.NewRequest("POST", url, bytes.NewReader(encoded))
-		if err != nil {
-			// ignore and continue
-			log.Errorln(err, "could not create request", "url", url)
-			continue
-		}
-		r.Header.Set("Authorization", token)
-		r.Header.Set("content-type", "application/json")
-		_, err = http.DefaultClient.Do(r)
-		if err != nil {
-			log.Errorln(err, "could not post to webhook", "url", url)
-		}
+	r, err := http.NewRequest("POST", url, bytes.NewReader(encoded))
+	if err != nil {
+		log.Errorln(err, "could not create request", "url", url)
+		return
 	}
-
-	return nil
+	r.Header.Set("Authorization", token)
+	r.Header.Set("content-type", "application/json")
+	res, err := webhookHttpClient().Do(r)
+	if err != nil {
+		log.Errorln(err, "could not post to webhook", "url", url)
+		return
+	}
+	_ = res.Body.Close()
 }
@@ -13,6 +13,8 @@ type WebsocketToRedis struct {
 	DataMsg *plugnmeet.DataMessage `json:"data_msg,omitempty"`
 	RoomId  string                 `json:"room_id,omitempty"`
 	IsAdmin bool                   `json:"is_admin,omitempty"`
+	// Session of the sender, required to renew single-use token
+	Session string `json:"session,omitempty"`
 }
 
 func DistributeWebsocketMsgToRedisChannel(payload *WebsocketToRedis) {
@@ -102,6 +104,7 @@ func SubscribeToSystemWebsocketChannel() {
 		if err != nil {
 			log.Errorln(err)
 		}
+		m.session = res.Session
 		m.HandleDataMessages(res.DataMsg, res.RoomId, res.IsAdmin)
 	}
 }
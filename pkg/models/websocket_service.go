@@ -15,6 +15,7 @@ type websocketService struct {
 	rSid    string                 // room sid
 	isAdmin bool
 	roomId  string
+	session string // session of the sender
 }
 
 func NewWebsocketService() *websocketService {
@@ -52,8 +53,11 @@ func (w *websocketService) HandleDataMessages(payload *plugnmeet.DataMessage, ro
 
 func (w *websocketService) userMessages() {
 	switch w.pl.Body.Type {
-	case plugnmeet.DataMsgBodyType_CHAT:
-		w.handleChat()
+	case plugnmeet.DataMsgBodyType_CHAT,
+		DataMsgBodyType_REACTION,
+		DataMsgBodyType_EDIT_MESSAGE,
+		DataMsgBodyType_SCREEN_ANNOTATION:
+		w.handleChat() // reactions & annotations are always for everyone, edits for receivers of the original
 	}
 }
 
@@ -75,7 +79,11 @@ func (w *websocketService) handleSystemMessages() {
 		plugnmeet.DataMsgBodyType_POLL_CLOSED:
 		w.handlePollsNotifications()
 	case plugnmeet.DataMsgBodyType_JOIN_BREAKOUT_ROOM:
-		w.handleSendBreakoutRoomNotification()
+		w.handleRelaySystemMsg()
+	default:
+		if isRelaySystemMsgType(w.pl.Body.Type) {
+			w.handleRelaySystemMsg()
+		}
 	}
 }
 
@@ -83,6 +91,7 @@ func (w *websocketService) handleWhiteboardMessages() {
 	switch w.pl.Body.Type {
 	case plugnmeet.DataMsgBodyType_SCENE_UPDATE,
 		plugnmeet.DataMsgBodyType_POINTER_UPDATE,
+		DataMsgBodyType_LASER_POINTER,
 		plugnmeet.DataMsgBodyType_ADD_WHITEBOARD_FILE,
 		plugnmeet.DataMsgBodyType_ADD_WHITEBOARD_OFFICE_FILE,
 		plugnmeet.DataMsgBodyType_PAGE_CHANGE,
@@ -154,9 +163,10 @@ func (w *websocketService) handleSendChatMsgs() {
 
 func (w *websocketService) handleRenewToken() {
 	req := &ValidateTokenReq{
-		Token:  w.pl.Body.Msg,
-		RoomId: w.pl.RoomId,
-		Sid:    w.pl.RoomSid,
+		Token:   w.pl.Body.Msg,
+		RoomId:  w.pl.RoomId,
+		Sid:     w.pl.RoomSid,
+		Session: w.session,
 	}
 	m := NewAuthTokenModel()
 	token, err := m.DoRenewToken(req)
@@ -321,7 +331,9 @@ func (w *websocketService) handlePollsNotifications() {
 	}
 }
 
-func (w *websocketService) handleSendBreakoutRoomNotification() {
+// handleRelaySystemMsg will send the message to the user of To field,
+// if it's empty then to everyone of the room
+func (w *websocketService) handleRelaySystemMsg() {
 	jm, err := proto.Marshal(w.pl)
 	if err != nil {
 		return
@@ -0,0 +1,289 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	WhiteboardConversionQueued     = "queued"
+	WhiteboardConversionConverting = "converting"
+	WhiteboardConversionRendering  = "rendering"
+	WhiteboardConversionCompleted  = "completed"
+	WhiteboardConversionFailed     = "failed"
+
+	// whiteboardConversionQueueKey is shared by all servers, so upload path should be shared too
+	whiteboardConversionQueueKey     = "pnm:whiteboard_conversion_queue"
+	whiteboardConversionStatusKey    = "pnm:whiteboard_conversion:"
+	whiteboardConversionStatusTTL    = time.Hour
+	defaultWhiteboardConversionLimit = 5 * time.Minute
+)
+
+type whiteboardConversionJob struct {
+	JobId    string `json:"job_id"`
+	RoomId   string `json:"room_id"`
+	Sid      string `json:"sid"`
+	UserId   string `json:"user_id"`
+	FilePath string `json:"file_path"`
+}
+
+// WhiteboardConversionStatus will be sent to the user who has requested the conversion
+type WhiteboardConversionStatus struct {
+	JobId    string                    `json:"job_id"`
+	Status   string                    `json:"status"`
+	Progress int                       `json:"progress"`
+	Page     int                       `json:"page,omitempty"`
+	Total    int                       `json:"total,omitempty"`
+	Error    string                    `json:"error,omitempty"`
+	File     *ConvertWhiteboardFileRes `json:"file,omitempty"`
+}
+
+// EnqueueWhiteboardConversion will add the file in queue,
+// progress will be sent over websocket to the user
+func (m *ManageFile) EnqueueWhiteboardConversion() (*WhiteboardConversionStatus, error) {
+	file := fmt.Sprintf("%s/%s", m.uploadFileSettings.Path, m.FilePath)
+	info, err := os.Stat(file)
+	if err != nil {
+		log.Errorln(err)
+		return nil, err
+	}
+	mtype, err := mimetype.DetectFile(file)
+	if err != nil {
+		return nil, err
+	}
+	// so that user will get the error at once, pages will be checked by the worker
+	limits := m.whiteboardUploadLimits()
+	if err = limits.checkFile(info, mtype); err != nil {
+		return nil, err
+	}
+
+	job := &whiteboardConversionJob{
+		JobId:    uuid.NewString(),
+		RoomId:   m.RoomId,
+		Sid:      m.Sid,
+		UserId:   m.UserId,
+		FilePath: m.FilePath,
+	}
+	marshal, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &WhiteboardConversionStatus{
+		JobId:  job.JobId,
+		Status: WhiteboardConversionQueued,
+	}
+	if err = m.rs.saveWhiteboardConversionStatus(job.RoomId, status); err != nil {
+		return nil, err
+	}
+	if err = m.rs.rc.RPush(m.rs.ctx, whiteboardConversionQueueKey, marshal).Err(); err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+func (r *RoomService) saveWhiteboardConversionStatus(roomId string, status *WhiteboardConversionStatus) error {
+	marshal, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return r.rc.Set(r.ctx, whiteboardConversionStatusKey+roomId+":"+status.JobId, marshal, whiteboardConversionStatusTTL).Err()
+}
+
+// GetWhiteboardConversionStatus can be used if the client has missed websocket messages
+func (r *RoomService) GetWhiteboardConversionStatus(roomId, jobId string) (*WhiteboardConversionStatus, error) {
+	result, err := r.rc.Get(r.ctx, whiteboardConversionStatusKey+roomId+":"+jobId).Result()
+	if err != nil {
+		return nil, errors.New("no conversion found")
+	}
+
+	status := new(WhiteboardConversionStatus)
+	err = json.Unmarshal([]byte(result), status)
+	return status, err
+}
+
+// startWhiteboardConversionWorkers will start workers those will wait for files in the queue
+func (s *scheduler) startWhiteboardConversionWorkers() {
+	if _, err := os.Stat("/usr/bin/mutool"); err != nil {
+		log.Errorln(err)
+		return
+	}
+
+	workers := config.AppCnf.UploadFileSettings.OfficeConversion.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go s.whiteboardConversionWorker()
+	}
+}
+
+func (s *scheduler) whiteboardConversionWorker() {
+	for {
+		result, err := s.rc.BLPop(s.ctx, 5*time.Second, whiteboardConversionQueueKey).Result()
+		if err != nil {
+			// redis.Nil means timeout, so we'll wait again
+			continue
+		}
+
+		job := new(whiteboardConversionJob)
+		if err = json.Unmarshal([]byte(result[1]), job); err != nil {
+			log.Errorln(err)
+			continue
+		}
+		processWhiteboardConversion(job)
+	}
+}
+
+func processWhiteboardConversion(job *whiteboardConversionJob) {
+	m := NewManageFileModel(&ManageFile{
+		Sid:      job.Sid,
+		RoomId:   job.RoomId,
+		UserId:   job.UserId,
+		FilePath: job.FilePath,
+	})
+	status := &WhiteboardConversionStatus{
+		JobId:  job.JobId,
+		Status: WhiteboardConversionConverting,
+	}
+	progress := func() {
+		if err := m.rs.saveWhiteboardConversionStatus(job.RoomId, status); err != nil {
+			log.Errorln(err)
+		}
+		sendSystemMsgToUser(job.RoomId, job.UserId, DataMsgBodyType_WHITEBOARD_CONVERSION_PROGRESS, status)
+	}
+	progress()
+
+	res, err := m.convertWhiteboardFileWithProgress(job.JobId, status, progress)
+	if err != nil {
+		log.Errorln(fmt.Sprintf("whiteboard conversion of %s failed: %s", job.FilePath, err.Error()))
+		status.Status = WhiteboardConversionFailed
+		status.Error = err.Error()
+		progress()
+		return
+	}
+
+	// clients will load the pages from metadata
+	if err = m.updateRoomMetadataWithOfficeFile(res); err != nil {
+		log.Errorln(err)
+	}
+	status.Status = WhiteboardConversionCompleted
+	status.Progress = 100
+	status.File = res
+	progress()
+}
+
+// convertWhiteboardFileWithProgress is same as ConvertWhiteboardFile,
+// but pages will be rendered one by one to report the progress
+func (m *ManageFile) convertWhiteboardFileWithProgress(jobId string, status *WhiteboardConversionStatus, progress func()) (*ConvertWhiteboardFileRes, error) {
+	timeout := config.AppCnf.UploadFileSettings.OfficeConversion.Timeout
+	if timeout <= 0 {
+		timeout = defaultWhiteboardConversionLimit
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	file := fmt.Sprintf("%s/%s", m.uploadFileSettings.Path, m.FilePath)
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	mtype, err := mimetype.DetectFile(file)
+	if err != nil {
+		return nil, err
+	}
+	limits := m.whiteboardUploadLimits()
+	if err = limits.checkFile(info, mtype); err != nil {
+		return nil, err
+	}
+
+	fileId := jobId
+	outputDir := fmt.Sprintf("%s/%s/%s", m.uploadFileSettings.Path, m.Sid, fileId)
+	if err = os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	if variant, ok := officePdfExportVariant(mtype.Extension()); ok {
+		if _, err = os.Stat("/usr/bin/soffice"); err != nil {
+			_ = os.RemoveAll(outputDir)
+			return nil, err
+		}
+		// concurrent soffice processes can't share the same profile
+		profile := filepath.Join(os.TempDir(), "pnm_soffice_"+fileId)
+		defer os.RemoveAll(profile)
+
+		cmd := exec.CommandContext(ctx, "/usr/bin/soffice", "-env:UserInstallation=file://"+profile, "--headless", "--invisible", "--nologo", "--nolockcheck", "--convert-to", variant, "--outdir", outputDir, file)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			log.Errorln(string(output))
+			_ = os.RemoveAll(outputDir)
+			return nil, err
+		}
+		file = fmt.Sprintf("%s/%s", outputDir, strings.Replace(info.Name(), mtype.Extension(), ".pdf", 1))
+	}
+
+	status.Status = WhiteboardConversionRendering
+	status.Progress = 10
+	status.Total, err = limits.checkPdfPages(ctx, file)
+	if err != nil {
+		_ = os.RemoveAll(outputDir)
+		return nil, err
+	}
+	progress()
+
+	if status.Total == 0 {
+		// we couldn't count pages, so all of them will be rendered at once
+		cmd := exec.CommandContext(ctx, "/usr/bin/mutool", "convert", "-o", outputDir+"/page_%d.png", file)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			log.Errorln(string(output))
+			_ = os.RemoveAll(outputDir)
+			return nil, err
+		}
+	}
+	for i := 1; i <= status.Total; i++ {
+		cmd := exec.CommandContext(ctx, "/usr/bin/mutool", "draw", "-o", fmt.Sprintf("%s/page_%d.png", outputDir, i), file, strconv.Itoa(i))
+		if output, err := cmd.CombinedOutput(); err != nil {
+			log.Errorln(string(output))
+			_ = os.RemoveAll(outputDir)
+			return nil, err
+		}
+		status.Page = i
+		status.Progress = 10 + i*89/status.Total
+		progress()
+	}
+
+	totalPages, _ := filepath.Glob(filepath.Join(outputDir, "*.png"))
+	// page count wasn't available before rendering
+	if err = limits.checkPages(len(totalPages)); err != nil {
+		_ = os.RemoveAll(outputDir)
+		return nil, err
+	}
+	return &ConvertWhiteboardFileRes{
+		FileName:   info.Name(),
+		FilePath:   fmt.Sprintf("%s/%s", m.Sid, fileId),
+		FileId:     fileId,
+		TotalPages: len(totalPages),
+	}, nil
+}
+
+// countPdfPages will return 0 if mutool couldn't read the count
+func countPdfPages(ctx context.Context, file string) int {
+	output, err := exec.CommandContext(ctx, "/usr/bin/mutool", "show", file, "trailer/Root/Pages/Count").Output()
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(string(output)))
+	return n
+}
@@ -0,0 +1,352 @@
+package models
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	WhiteboardExportFormatPdf = "pdf"
+	WhiteboardExportFormatPng = "png"
+
+	// whiteboardExportsDir is inside upload path, but it won't be deleted with the session files
+	whiteboardExportsDir            = "whiteboard_exports"
+	defaultWhiteboardExportUrlTTL   = 24 * time.Hour
+	whiteboardExportCommandDeadline = 2 * time.Minute
+)
+
+// ExportWhiteboardReq will use the room of the token for /api,
+// RoomSid of a finished session will load the board from DB
+type ExportWhiteboardReq struct {
+	RoomId  string `json:"-"`
+	RoomSid string `json:"room_sid"`
+	Format  string `json:"format" validate:"omitempty,oneof=pdf png"`
+}
+
+type ListWhiteboardExportsReq struct {
+	RoomSid string `json:"room_sid" validate:"required"`
+}
+
+type WhiteboardExport struct {
+	ExportId  string   `json:"export_id"`
+	RoomId    string   `json:"room_id"`
+	RoomSid   string   `json:"room_sid"`
+	Format    string   `json:"format"`
+	Files     []string `json:"files"`
+	Urls      []string `json:"urls,omitempty"`
+	CreatedAt int64    `json:"created_at"`
+}
+
+func whiteboardExportUrlTTL() time.Duration {
+	if ttl := config.AppCnf.Client.WhiteboardState.ExportUrlTTL; ttl > 0 {
+		return ttl
+	}
+	return defaultWhiteboardExportUrlTTL
+}
+
+func whiteboardExportDir(roomSid, exportId string) string {
+	return filepath.Join(config.AppCnf.UploadFileSettings.Path, whiteboardExportsDir, roomSid, exportId)
+}
+
+func signWhiteboardExportUrl(roomSid, exportId, file string, expiresAt int64) string {
+	h := hmac.New(sha256.New, []byte(config.AppCnf.Client.Secret))
+	h.Write([]byte(roomSid + ":" + exportId + ":" + file + ":" + strconv.FormatInt(expiresAt, 10)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// signUrls will set download urls of the files
+func (e *WhiteboardExport) signUrls(baseUrl string) {
+	expiresAt := time.Now().Add(whiteboardExportUrlTTL()).Unix()
+	e.Urls = make([]string, 0, len(e.Files))
+	for _, f := range e.Files {
+		e.Urls = append(e.Urls, fmt.Sprintf("%s/download/whiteboardExport/%s/%s/%s?expires=%d&sig=%s",
+			baseUrl, e.RoomSid, e.ExportId, url.PathEscape(f), expiresAt, signWhiteboardExportUrl(e.RoomSid, e.ExportId, f, expiresAt)))
+	}
+}
+
+// VerifyWhiteboardExportUrl will validate signature & expiry, it will return the local file path
+func VerifyWhiteboardExportUrl(roomSid, exportId, file, expires, sig string) (string, error) {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return "", errors.New("invalid expires")
+	}
+	if time.Now().Unix() >= expiresAt {
+		return "", errors.New("url has expired")
+	}
+	if !hmac.Equal([]byte(signWhiteboardExportUrl(roomSid, exportId, file, expiresAt)), []byte(sig)) {
+		return "", errors.New("invalid signature")
+	}
+	if filepath.Base(file) != file {
+		return "", errors.New("invalid file")
+	}
+
+	path := filepath.Join(whiteboardExportDir(roomSid, exportId), file)
+	if _, err = os.Stat(path); err != nil {
+		return "", errors.New("file not found")
+	}
+	return path, nil
+}
+
+type whiteboardExportModel struct {
+	app *config.AppConfig
+	db  *sql.DB
+	ctx context.Context
+	rs  *RoomService
+}
+
+func NewWhiteboardExportModel() *whiteboardExportModel {
+	return &whiteboardExportModel{
+		app: config.AppCnf,
+		db:  config.AppCnf.DB,
+		ctx: context.Background(),
+		rs:  NewRoomService(),
+	}
+}
+
+// ExportWhiteboard will render the pages using mutool & store the files with the session
+func (m *whiteboardExportModel) ExportWhiteboard(r *ExportWhiteboardReq, baseUrl string) (*WhiteboardExport, error) {
+	if !m.app.Client.WhiteboardState.Enabled {
+		return nil, errors.New("whiteboard state isn't enabled")
+	}
+	if _, err := os.Stat("/usr/bin/mutool"); err != nil {
+		log.Errorln(err)
+		return nil, err
+	}
+
+	state, err := m.loadWhiteboardState(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(state.Pages) == 0 {
+		return nil, errors.New("whiteboard is empty")
+	}
+
+	e := &WhiteboardExport{
+		ExportId:  uuid.NewString(),
+		RoomId:    r.RoomId,
+		RoomSid:   r.RoomSid,
+		Format:    r.Format,
+		CreatedAt: time.Now().Unix(),
+	}
+	if e.Format == "" {
+		e.Format = WhiteboardExportFormatPdf
+	}
+
+	dir := whiteboardExportDir(e.RoomSid, e.ExportId)
+	if err = os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	e.Files, err = m.renderWhiteboard(dir, e.Format, state)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	files, err := json.Marshal(e.Files)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(m.ctx, 3*time.Second)
+	defer cancel()
+	query := "INSERT INTO " + m.app.FormatDBTable("whiteboard_exports") + " (export_id, room_id, room_sid, format, files, created_at) VALUES (?, ?, ?, ?, ?, ?)"
+	if _, err = m.db.ExecContext(ctx, query, e.ExportId, e.RoomId, e.RoomSid, e.Format, string(files), e.CreatedAt); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	e.signUrls(baseUrl)
+	return e, nil
+}
+
+// renderWhiteboard will write svg of every page & convert using mutool.
+// It will return names of the files in the directory.
+func (m *whiteboardExportModel) renderWhiteboard(dir, format string, state *WhiteboardState) ([]string, error) {
+	var pages []int
+	for p := range state.Pages {
+		if n, err := strconv.Atoi(p); err == nil {
+			pages = append(pages, n)
+		}
+	}
+	sort.Ints(pages)
+
+	ctx, cancel := context.WithTimeout(m.ctx, whiteboardExportCommandDeadline)
+	defer cancel()
+
+	renderer := newWhiteboardRenderer(m.app.UploadFileSettings.Path, state)
+	var files, pdfs []string
+	for _, p := range pages {
+		svg := filepath.Join(dir, fmt.Sprintf("page_%d.svg", p))
+		if err := os.WriteFile(svg, renderer.RenderPage(state.Pages[strconv.Itoa(p)]), 0644); err != nil {
+			return nil, err
+		}
+
+		name := fmt.Sprintf("page_%d.%s", p, format)
+		out := filepath.Join(dir, name)
+		var cmd *exec.Cmd
+		if format == WhiteboardExportFormatPng {
+			cmd = exec.CommandContext(ctx, "/usr/bin/mutool", "draw", "-r", "96", "-o", out, svg)
+		} else {
+			cmd = exec.CommandContext(ctx, "/usr/bin/mutool", "convert", "-o", out, svg)
+		}
+		if output, err := cmd.CombinedOutput(); err != nil {
+			log.Errorln(string(output))
+			return nil, err
+		}
+		_ = os.Remove(svg)
+
+		if format == WhiteboardExportFormatPng {
+			files = append(files, name)
+		} else {
+			pdfs = append(pdfs, out)
+		}
+	}
+
+	if format == WhiteboardExportFormatPng {
+		return files, nil
+	}
+
+	// all pages in one pdf
+	name := "whiteboard.pdf"
+	args := append([]string{"merge", "-o", filepath.Join(dir, name)}, pdfs...)
+	if output, err := exec.CommandContext(ctx, "/usr/bin/mutool", args...).CombinedOutput(); err != nil {
+		log.Errorln(string(output))
+		return nil, err
+	}
+	for _, f := range pdfs {
+		_ = os.Remove(f)
+	}
+	return []string{name}, nil
+}
+
+// RenderWhiteboardPdf will return the board of the session as pdf, nil if the board is empty
+func (m *whiteboardExportModel) RenderWhiteboardPdf(roomId, roomSid string) ([]byte, error) {
+	if _, err := os.Stat("/usr/bin/mutool"); err != nil {
+		return nil, err
+	}
+	state, err := m.loadWhiteboardState(&ExportWhiteboardReq{
+		RoomId:  roomId,
+		RoomSid: roomSid,
+	})
+	if err != nil {
+		// board may not be archived yet if the session has just ended
+		if state, err = m.rs.GetWhiteboardState(roomId); err != nil {
+			return nil, err
+		}
+	}
+	if len(state.Pages) == 0 {
+		return nil, nil
+	}
+
+	dir, err := os.MkdirTemp("", "pnm_whiteboard_")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	files, err := m.renderWhiteboard(dir, WhiteboardExportFormatPdf, state)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(filepath.Join(dir, files[0]))
+}
+
+// loadWhiteboardState will set RoomId & RoomSid of the request.
+// Board of the running session will be taken from redis.
+func (m *whiteboardExportModel) loadWhiteboardState(r *ExportWhiteboardReq) (*WhiteboardState, error) {
+	rm := NewRoomModel()
+	var room *RoomInfo
+	if r.RoomSid != "" {
+		room, _ = rm.GetRoomInfo("", r.RoomSid, 1)
+	} else if r.RoomId != "" {
+		room, _ = rm.GetRoomInfo(r.RoomId, "", 1)
+	} else {
+		return nil, errors.New("room_sid required")
+	}
+
+	if room.Id > 0 {
+		if r.RoomId != "" && r.RoomId != room.RoomId {
+			return nil, errors.New("no whiteboard found")
+		}
+		r.RoomId, r.RoomSid = room.RoomId, room.Sid
+		return m.rs.GetWhiteboardState(r.RoomId)
+	}
+	if r.RoomSid == "" {
+		return nil, errors.New("room isn't active")
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, 10*time.Second)
+	defer cancel()
+
+	var roomId, data string
+	err := m.db.QueryRowContext(ctx, "SELECT room_id, state FROM "+m.app.FormatDBTable("whiteboard_states")+" WHERE room_sid = ?", r.RoomSid).Scan(&roomId, &data)
+	if err == sql.ErrNoRows || (err == nil && r.RoomId != "" && r.RoomId != roomId) {
+		return nil, errors.New("no whiteboard found")
+	} else if err != nil {
+		return nil, err
+	}
+	r.RoomId = roomId
+
+	state := new(WhiteboardState)
+	err = json.Unmarshal([]byte(data), state)
+	return state, err
+}
+
+// ListExports will return exports of the session with new download urls
+func (m *whiteboardExportModel) ListExports(roomSid, baseUrl string) ([]*WhiteboardExport, error) {
+	ctx, cancel := context.WithTimeout(m.ctx, 3*time.Second)
+	defer cancel()
+
+	rows, err := m.db.QueryContext(ctx, "SELECT export_id, room_id, room_sid, format, files, created_at FROM "+m.app.FormatDBTable("whiteboard_exports")+" WHERE room_sid = ? ORDER BY id", roomSid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []*WhiteboardExport{}
+	for rows.Next() {
+		e := new(WhiteboardExport)
+		var files string
+		if err = rows.Scan(&e.ExportId, &e.RoomId, &e.RoomSid, &e.Format, &files, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal([]byte(files), &e.Files); err != nil {
+			log.Errorln(err)
+		}
+		e.signUrls(baseUrl)
+		list = append(list, e)
+	}
+
+	return list, rows.Err()
+}
+
+// DeleteWhiteboardExports will delete files & records of the session
+func (m *whiteboardExportModel) DeleteWhiteboardExports(roomSid string) (int64, error) {
+	dir := filepath.Join(m.app.UploadFileSettings.Path, whiteboardExportsDir, roomSid)
+	if err := os.RemoveAll(dir); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, 3*time.Second)
+	defer cancel()
+	res, err := m.db.ExecContext(ctx, "DELETE FROM "+m.app.FormatDBTable("whiteboard_exports")+" WHERE room_sid = ?", roomSid)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
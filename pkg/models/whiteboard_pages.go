@@ -0,0 +1,228 @@
+package models
+
+import (
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+)
+
+const (
+	WhiteboardPageActionAdd    = "add"
+	WhiteboardPageActionDelete = "delete"
+	WhiteboardPageActionMove   = "move"
+	WhiteboardPageActionSwitch = "switch"
+
+	whiteboardTotalPagesField = "total_pages"
+	whiteboardMaxPages        = 500
+)
+
+// whiteboardMovePagesScript will move elements of the pages at once.
+// KEYS: state key, then source & destination of every page.
+// ARGV: ttl, current page, total pages, then 1 for every page which should be deleted.
+var whiteboardMovePagesScript = redis.NewScript(`
+local ttl = tonumber(ARGV[1])
+local pages = {}
+for i = 2, #KEYS, 2 do
+	pages[i] = redis.call('HGETALL', KEYS[i])
+	redis.call('DEL', KEYS[i])
+end
+for i = 2, #KEYS, 2 do
+	local elements = pages[i]
+	if ARGV[3 + i / 2] ~= '1' and #elements > 0 then
+		for j = 1, #elements, 2 do
+			redis.call('HSET', KEYS[i + 1], elements[j], elements[j + 1])
+		end
+		redis.call('EXPIRE', KEYS[i + 1], ttl)
+	end
+end
+redis.call('HSET', KEYS[1], 'current_page', ARGV[2], 'total_pages', ARGV[3])
+redis.call('EXPIRE', KEYS[1], ttl)
+return 1
+`)
+
+type WhiteboardPageReq struct {
+	RoomId string `json:"-"`
+	Action string `json:"action" validate:"required,oneof=add delete move switch"`
+	// Page to delete, move or switch, for add new page will be inserted at the position.
+	// 0 will add the page at the end
+	Page int `json:"page" validate:"gte=0"`
+	// To is the new position of the page for move
+	To int `json:"to" validate:"gte=0"`
+}
+
+// WhiteboardPages will be sent to everyone when the active page or number of pages has changed
+type WhiteboardPages struct {
+	CurrentPage int `json:"current_page"`
+	TotalPages  int `json:"total_pages"`
+}
+
+// whiteboardOfficeFile has the fields of office file sent by the clients
+type whiteboardOfficeFile struct {
+	TotalPages int `json:"totalPages"`
+}
+
+// whiteboardTotalPages will use the highest page if pages weren't managed using the API yet
+func (r *RoomService) whiteboardTotalPages(roomId string, fields map[string]string) (int, error) {
+	total, _ := strconv.Atoi(fields[whiteboardTotalPagesField])
+	if p, _ := strconv.Atoi(fields[whiteboardCurrentPageField]); p > total {
+		total = p
+	}
+	if v := fields[whiteboardOfficeFileField]; v != "" {
+		f := new(whiteboardOfficeFile)
+		if err := json.Unmarshal([]byte(v), f); err == nil && f.TotalPages > total {
+			total = f.TotalPages
+		}
+	}
+
+	keys, err := r.whiteboardPageKeys(roomId)
+	if err != nil {
+		return 0, err
+	}
+	prefix := whiteboardElementsKey + roomId + ":"
+	for _, key := range keys {
+		if p, _ := strconv.Atoi(strings.TrimPrefix(key, prefix)); p > total {
+			total = p
+		}
+	}
+	if total < 1 {
+		total = 1
+	}
+	return total, nil
+}
+
+// GetWhiteboardPages will return the active page & number of pages of the room
+func (r *RoomService) GetWhiteboardPages(roomId string) (*WhiteboardPages, error) {
+	fields, err := r.rc.HGetAll(r.ctx, whiteboardStateKey+roomId).Result()
+	if err != nil {
+		return nil, err
+	}
+	total, err := r.whiteboardTotalPages(roomId, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := &WhiteboardPages{
+		CurrentPage: 1,
+		TotalPages:  total,
+	}
+	if p, _ := strconv.Atoi(fields[whiteboardCurrentPageField]); p > 0 {
+		pages.CurrentPage = p
+	}
+	return pages, nil
+}
+
+// ManageWhiteboardPage will change the pages & notify everyone of the room.
+// Elements of the pages will be moved with the pages, but office file pages will keep their order.
+func (r *RoomService) ManageWhiteboardPage(req *WhiteboardPageReq) (*WhiteboardPages, error) {
+	if !config.AppCnf.Client.WhiteboardState.Enabled {
+		return nil, errors.New("whiteboard state isn't enabled")
+	}
+	pages, err := r.GetWhiteboardPages(req.RoomId)
+	if err != nil {
+		return nil, err
+	}
+	total := pages.TotalPages
+
+	// mapping of the old page to new page, 0 means deleted
+	mapping := make(map[int]int, total)
+	for p := 1; p <= total; p++ {
+		mapping[p] = p
+	}
+
+	switch req.Action {
+	case WhiteboardPageActionSwitch:
+		if req.Page < 1 || req.Page > total {
+			return nil, errors.New("invalid page")
+		}
+		if err = r.setWhiteboardField(req.RoomId, whiteboardCurrentPageField, req.Page, whiteboardTotalPagesField, total); err != nil {
+			return nil, err
+		}
+		pages.CurrentPage = req.Page
+		broadcastSystemMsg(req.RoomId, DataMsgBodyType_WHITEBOARD_PAGES_UPDATED, pages)
+		return pages, nil
+
+	case WhiteboardPageActionAdd:
+		if total >= whiteboardMaxPages {
+			return nil, errors.New("maximum number of pages reached")
+		}
+		at := req.Page
+		if at < 1 || at > total {
+			at = total + 1
+		}
+		for p := at; p <= total; p++ {
+			mapping[p] = p + 1
+		}
+		pages.TotalPages = total + 1
+
+	case WhiteboardPageActionDelete:
+		if req.Page < 1 || req.Page > total {
+			return nil, errors.New("invalid page")
+		}
+		mapping[req.Page] = 0
+		for p := req.Page + 1; p <= total; p++ {
+			mapping[p] = p - 1
+		}
+		pages.TotalPages = total - 1
+		if pages.TotalPages < 1 {
+			pages.TotalPages = 1
+		}
+
+	case WhiteboardPageActionMove:
+		if req.Page < 1 || req.Page > total || req.To < 1 || req.To > total {
+			return nil, errors.New("invalid page")
+		}
+		mapping[req.Page] = req.To
+		for p := req.To; p < req.Page; p++ {
+			mapping[p] = p + 1
+		}
+		for p := req.Page + 1; p <= req.To; p++ {
+			mapping[p] = p - 1
+		}
+
+	default:
+		return nil, errors.New("invalid action")
+	}
+
+	if newPage := mapping[pages.CurrentPage]; newPage > 0 {
+		pages.CurrentPage = newPage
+	} else if pages.CurrentPage > pages.TotalPages {
+		pages.CurrentPage = pages.TotalPages
+	}
+
+	keys := []string{whiteboardStateKey + req.RoomId}
+	args := []interface{}{int(r.RoomKeyTTL(req.RoomId).Seconds()), pages.CurrentPage, pages.TotalPages}
+	for p := 1; p <= total; p++ {
+		to := mapping[p]
+		if to == p {
+			continue
+		}
+		deleted := "0"
+		if to == 0 {
+			to, deleted = p, "1"
+		}
+		keys = append(keys, whiteboardPageKey(req.RoomId, p), whiteboardPageKey(req.RoomId, to))
+		args = append(args, deleted)
+	}
+	if err = whiteboardMovePagesScript.Run(r.ctx, r.rc, keys, args...).Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) > 1 {
+		pp := r.rc.Pipeline()
+		r.trackRoomDynamicKeys(pp, req.RoomId, r.RoomKeyTTL(req.RoomId), keys[1:]...)
+		if _, err = pp.Exec(r.ctx); err != nil {
+			log.Errorln(err)
+		}
+	}
+
+	// elements of the pages have changed, so clients will need the full board
+	state, err := r.GetWhiteboardState(req.RoomId)
+	if err != nil {
+		return nil, err
+	}
+	broadcastSystemMsg(req.RoomId, DataMsgBodyType_WHITEBOARD_STATE, state)
+	return pages, nil
+}
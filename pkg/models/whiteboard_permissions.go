@@ -0,0 +1,191 @@
+package models
+
+import (
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	log "github.com/sirupsen/logrus"
+	"strings"
+)
+
+const (
+	// WhiteboardDrawModeEveryone will let everyone draw except revoked users
+	WhiteboardDrawModeEveryone = "everyone"
+	// WhiteboardDrawModePresenters will let only presenter & granted users draw.
+	// Presenter is always a moderator, so moderators won't be restricted.
+	WhiteboardDrawModePresenters = "presenters"
+
+	// whiteboardDrawKey keeps draw mode & permission of users those were granted or revoked
+	whiteboardDrawKey       = "pnm:whiteboard_draw:"
+	whiteboardDrawModeField = "mode"
+	whiteboardDrawUserField = "user:"
+)
+
+type WhiteboardDrawModeReq struct {
+	RoomId string `json:"-"`
+	Mode   string `json:"mode" validate:"required,oneof=everyone presenters"`
+}
+
+type WhiteboardDrawPermissionReq struct {
+	RoomId string `json:"-"`
+	UserId string `json:"user_id" validate:"required"`
+	Allow  bool   `json:"allow"`
+}
+
+// WhiteboardDrawPermissions will be sent to everyone when it has changed
+type WhiteboardDrawPermissions struct {
+	Mode string `json:"mode"`
+	// Users those were granted (true) or revoked (false) by moderators
+	Users map[string]bool `json:"users"`
+}
+
+// isWhiteboardDrawMsg will return true for the messages those change the board
+func isWhiteboardDrawMsg(msg *plugnmeet.DataMessage) bool {
+	if msg.Type != plugnmeet.DataMsgType_WHITEBOARD || msg.Body == nil {
+		return false
+	}
+	switch msg.Body.Type {
+	case plugnmeet.DataMsgBodyType_SCENE_UPDATE,
+		plugnmeet.DataMsgBodyType_ADD_WHITEBOARD_FILE,
+		plugnmeet.DataMsgBodyType_ADD_WHITEBOARD_OFFICE_FILE,
+		plugnmeet.DataMsgBodyType_PAGE_CHANGE,
+		plugnmeet.DataMsgBodyType_WHITEBOARD_APP_STATE_CHANGE:
+		return true
+	}
+	return false
+}
+
+// AllowWhiteboardMessage will drop draw events of the users those don't have permission
+func AllowWhiteboardMessage(roomId, userId string, isAdmin bool, msg *plugnmeet.DataMessage) bool {
+	if isAdmin || !isWhiteboardDrawMsg(msg) {
+		return true
+	}
+
+	rs := NewRoomService()
+	values, err := rs.rc.HMGet(rs.ctx, whiteboardDrawKey+roomId, whiteboardDrawModeField, whiteboardDrawUserField+userId).Result()
+	if err != nil && err != redis.Nil {
+		log.Errorln(err)
+		return true
+	}
+
+	allowed := true
+	if mode, ok := values[0].(string); ok && mode == WhiteboardDrawModePresenters {
+		allowed = false
+	}
+	if v, ok := values[1].(string); ok {
+		allowed = v == "1"
+	}
+	if !allowed {
+		log.Debugln("whiteboard message of " + userId + " in room " + roomId + " was dropped")
+	}
+	return allowed
+}
+
+// GetWhiteboardDrawPermissions will return the mode & users those were granted or revoked
+func (r *RoomService) GetWhiteboardDrawPermissions(roomId string) (*WhiteboardDrawPermissions, error) {
+	fields, err := r.rc.HGetAll(r.ctx, whiteboardDrawKey+roomId).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &WhiteboardDrawPermissions{
+		Mode:  WhiteboardDrawModeEveryone,
+		Users: make(map[string]bool),
+	}
+	for f, v := range fields {
+		if f == whiteboardDrawModeField {
+			p.Mode = v
+		} else if strings.HasPrefix(f, whiteboardDrawUserField) {
+			p.Users[strings.TrimPrefix(f, whiteboardDrawUserField)] = v == "1"
+		}
+	}
+	return p, nil
+}
+
+// SetWhiteboardDrawMode will lock the board to presenters or let everyone draw,
+// permission of the individual users will be kept
+func (r *RoomService) SetWhiteboardDrawMode(req *WhiteboardDrawModeReq) (*WhiteboardDrawPermissions, error) {
+	key := whiteboardDrawKey + req.RoomId
+	pp := r.rc.Pipeline()
+	pp.HSet(r.ctx, key, whiteboardDrawModeField, req.Mode)
+	pp.Expire(r.ctx, key, r.RoomKeyTTL(req.RoomId))
+	if _, err := pp.Exec(r.ctx); err != nil {
+		return nil, err
+	}
+
+	r.AddRoomTimelineEvent(req.RoomId, &RoomTimelineEvent{
+		Type: "whiteboard_draw_mode_updated",
+		Msg:  req.Mode,
+	})
+	return r.broadcastWhiteboardDrawPermissions(req.RoomId)
+}
+
+// SetWhiteboardDrawPermission will grant or revoke drawing of the user,
+// lock settings of the user will be updated too so that clients can hide the tools
+func (r *RoomService) SetWhiteboardDrawPermission(req *WhiteboardDrawPermissionReq) (*WhiteboardDrawPermissions, error) {
+	p, meta, err := r.LoadParticipantWithMetadata(req.RoomId, req.UserId)
+	if err != nil {
+		return nil, errors.New("user isn't active now")
+	}
+	if meta.IsAdmin {
+		return nil, errors.New("moderator's whiteboard can't be locked")
+	}
+
+	allow := "0"
+	direction := "lock"
+	if req.Allow {
+		allow, direction = "1", "unlock"
+	}
+	key := whiteboardDrawKey + req.RoomId
+	pp := r.rc.Pipeline()
+	pp.HSet(r.ctx, key, whiteboardDrawUserField+req.UserId, allow)
+	pp.Expire(r.ctx, key, r.RoomKeyTTL(req.RoomId))
+	if _, err = pp.Exec(r.ctx); err != nil {
+		return nil, err
+	}
+
+	err = NewUserModel().updateParticipantLockMetadata(updateParticipantLockMetadata{
+		participantInfo: p,
+		roomId:          req.RoomId,
+		service:         "whiteboard",
+		direction:       direction,
+	})
+	if err != nil {
+		log.Errorln(err)
+	}
+
+	r.AddRoomTimelineEvent(req.RoomId, &RoomTimelineEvent{
+		Type:   "whiteboard_draw_permission_updated",
+		UserId: req.UserId,
+		Msg:    direction,
+	})
+	return r.broadcastWhiteboardDrawPermissions(req.RoomId)
+}
+
+func (r *RoomService) broadcastWhiteboardDrawPermissions(roomId string) (*WhiteboardDrawPermissions, error) {
+	p, err := r.GetWhiteboardDrawPermissions(roomId)
+	if err != nil {
+		return nil, err
+	}
+	broadcastSystemMsg(roomId, DataMsgBodyType_WHITEBOARD_DRAW_PERMISSIONS, p)
+	return p, nil
+}
+
+// SendWhiteboardDrawPermissions will send permissions to the user who has just joined,
+// nothing will be sent if moderators haven't changed anything
+func SendWhiteboardDrawPermissions(roomId, userId string) {
+	p, err := NewRoomService().GetWhiteboardDrawPermissions(roomId)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	if p.Mode == WhiteboardDrawModeEveryone && len(p.Users) == 0 {
+		return
+	}
+	sendSystemMsgToUser(roomId, userId, DataMsgBodyType_WHITEBOARD_DRAW_PERMISSIONS, p)
+}
+
+// DeleteWhiteboardDrawPermissions will be used when the session has ended
+func (r *RoomService) DeleteWhiteboardDrawPermissions(roomId string) error {
+	return r.rc.Del(r.ctx, whiteboardDrawKey+roomId).Err()
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultWhiteboardPointerRate = 20
+
+type pointerThrottle struct {
+	last time.Time
+	// pending is the latest message within the interval, it will be sent when the interval ends
+	pending *WebsocketToRedis
+	timer   *time.Timer
+}
+
+var (
+	pointerThrottlesLock sync.Mutex
+	// websocket of the user is connected to one server only, so we don't need to use redis here
+	pointerThrottles = make(map[string]*pointerThrottle)
+)
+
+func isWhiteboardPointerMsg(msg *plugnmeet.DataMessage) bool {
+	if msg.Type != plugnmeet.DataMsgType_WHITEBOARD || msg.Body == nil {
+		return false
+	}
+	return msg.Body.Type == plugnmeet.DataMsgBodyType_POINTER_UPDATE || msg.Body.Type == DataMsgBodyType_LASER_POINTER
+}
+
+// AllowWhiteboardPointer will relay pointer messages of the user at most configured times per second.
+// Messages within the interval will be dropped except the latest one, which will be sent
+// when the interval ends, so that clients don't miss the final position.
+// Laser pointer can be used by the presenter only, presenter is always a moderator.
+func AllowWhiteboardPointer(userId string, payload *WebsocketToRedis) bool {
+	msg := payload.DataMsg
+	if !isWhiteboardPointerMsg(msg) {
+		return true
+	}
+	if msg.Body.From == nil || msg.Body.From.UserId != userId {
+		return false
+	}
+	if msg.Body.Type == DataMsgBodyType_LASER_POINTER && !payload.IsAdmin {
+		return false
+	}
+
+	rate := config.AppCnf.Client.WhiteboardPointer.MaxPerSecond
+	if rate <= 0 {
+		rate = defaultWhiteboardPointerRate
+	}
+	interval := time.Second / time.Duration(rate)
+	key := pointerThrottleKey(payload.RoomId, userId) + strconv.Itoa(int(msg.Body.Type))
+
+	pointerThrottlesLock.Lock()
+	t, ok := pointerThrottles[key]
+	if !ok {
+		t = new(pointerThrottle)
+		pointerThrottles[key] = t
+	}
+	now := time.Now()
+	if wait := interval - now.Sub(t.last); wait > 0 {
+		t.pending = payload
+		if t.timer == nil {
+			t.timer = time.AfterFunc(wait, func() {
+				flushWhiteboardPointer(key)
+			})
+		}
+		pointerThrottlesLock.Unlock()
+		return false
+	}
+	t.last = now
+	pointerThrottlesLock.Unlock()
+
+	return prepareWhiteboardPointer(payload)
+}
+
+func pointerThrottleKey(roomId, userId string) string {
+	return roomId + ":" + userId + ":"
+}
+
+func flushWhiteboardPointer(key string) {
+	pointerThrottlesLock.Lock()
+	t, ok := pointerThrottles[key]
+	if !ok {
+		pointerThrottlesLock.Unlock()
+		return
+	}
+	payload := t.pending
+	t.pending, t.timer = nil, nil
+	t.last = time.Now()
+	pointerThrottlesLock.Unlock()
+
+	if payload != nil && prepareWhiteboardPointer(payload) {
+		DistributeWebsocketMsgToRedisChannel(payload)
+	}
+}
+
+// prepareWhiteboardPointer will send laser pointer to everyone with the active page of the board,
+// so that clients those are on another page can hide it
+func prepareWhiteboardPointer(payload *WebsocketToRedis) bool {
+	msg := payload.DataMsg
+	if msg.Body.Type != DataMsgBodyType_LASER_POINTER {
+		return true
+	}
+	msg.To = nil
+	if !config.AppCnf.Client.WhiteboardState.Enabled {
+		// server doesn't know the active page, so we'll keep the page sent by the client
+		return true
+	}
+
+	body := make(map[string]json.RawMessage)
+	if err := json.Unmarshal([]byte(msg.Body.Msg), &body); err != nil {
+		return false
+	}
+	body["page"] = json.RawMessage(strconv.Itoa(NewRoomService().currentWhiteboardPage(payload.RoomId)))
+	marshal, err := json.Marshal(body)
+	if err != nil {
+		log.Errorln(err)
+		return false
+	}
+	msg.Body.Msg = string(marshal)
+	return true
+}
+
+// ForgetWhiteboardPointers will be used when the user has disconnected, pending messages will be dropped
+func ForgetWhiteboardPointers(roomId, userId string) {
+	prefix := pointerThrottleKey(roomId, userId)
+
+	pointerThrottlesLock.Lock()
+	defer pointerThrottlesLock.Unlock()
+	for key, t := range pointerThrottles {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if t.timer != nil {
+			t.timer.Stop()
+		}
+		delete(pointerThrottles, key)
+	}
+}
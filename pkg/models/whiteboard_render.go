@@ -0,0 +1,295 @@
+package models
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/goccy/go-json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	whiteboardRenderPadding    = 20
+	whiteboardArrowheadSize    = 15
+	whiteboardDefaultFontSize  = 20
+	whiteboardLineHeightFactor = 1.25
+)
+
+// whiteboardRenderElement has the fields of excalidraw element those are needed for rendering
+type whiteboardRenderElement struct {
+	Type            string      `json:"type"`
+	X               float64     `json:"x"`
+	Y               float64     `json:"y"`
+	Width           float64     `json:"width"`
+	Height          float64     `json:"height"`
+	Angle           float64     `json:"angle"`
+	StrokeColor     string      `json:"strokeColor"`
+	BackgroundColor string      `json:"backgroundColor"`
+	StrokeWidth     float64     `json:"strokeWidth"`
+	StrokeStyle     string      `json:"strokeStyle"`
+	Opacity         *float64    `json:"opacity"`
+	Points          [][]float64 `json:"points"`
+	Text            string      `json:"text"`
+	FontSize        float64     `json:"fontSize"`
+	FontFamily      int         `json:"fontFamily"`
+	TextAlign       string      `json:"textAlign"`
+	FileId          string      `json:"fileId"`
+	IsDeleted       bool        `json:"isDeleted"`
+	Roundness       *struct{}   `json:"roundness"`
+	StartArrowhead  *string     `json:"startArrowhead"`
+	EndArrowhead    *string     `json:"endArrowhead"`
+}
+
+// whiteboardRenderFile can be excalidraw file with dataURL or uploaded file with filePath
+type whiteboardRenderFile struct {
+	Id       string `json:"id"`
+	DataURL  string `json:"dataURL"`
+	FilePath string `json:"filePath"`
+}
+
+type whiteboardAppState struct {
+	ViewBackgroundColor string `json:"viewBackgroundColor"`
+}
+
+// whiteboardRenderer will convert elements of a page to SVG, hand-drawn style
+// & fill patterns of excalidraw will be rendered as plain strokes & solid fills
+type whiteboardRenderer struct {
+	uploadPath string
+	background string
+	files      map[string]*whiteboardRenderFile
+	dataURLs   map[string]string
+}
+
+func newWhiteboardRenderer(uploadPath string, state *WhiteboardState) *whiteboardRenderer {
+	r := &whiteboardRenderer{
+		uploadPath: uploadPath,
+		background: "#ffffff",
+		files:      make(map[string]*whiteboardRenderFile),
+		dataURLs:   make(map[string]string),
+	}
+	if len(state.AppState) > 0 {
+		as := new(whiteboardAppState)
+		if err := json.Unmarshal(state.AppState, as); err == nil && as.ViewBackgroundColor != "" {
+			r.background = as.ViewBackgroundColor
+		}
+	}
+	for _, raw := range state.Files {
+		f := new(whiteboardRenderFile)
+		if err := json.Unmarshal(raw, f); err == nil && f.Id != "" {
+			r.files[f.Id] = f
+		}
+	}
+	return r
+}
+
+// imageDataURL will return data url of the file, uploaded files will be read from upload path
+func (r *whiteboardRenderer) imageDataURL(fileId string) string {
+	if v, ok := r.dataURLs[fileId]; ok {
+		return v
+	}
+	f, ok := r.files[fileId]
+	if !ok {
+		return ""
+	}
+
+	v := f.DataURL
+	if v == "" && f.FilePath != "" {
+		// path shouldn't be able to go outside of upload path
+		file := filepath.Join(r.uploadPath, filepath.Clean("/"+f.FilePath))
+		if data, err := os.ReadFile(file); err == nil {
+			v = "data:" + mimetype.Detect(data).String() + ";base64," + base64.StdEncoding.EncodeToString(data)
+		}
+	}
+	r.dataURLs[fileId] = v
+	return v
+}
+
+func parseWhiteboardRenderElements(elements []json.RawMessage) []*whiteboardRenderElement {
+	list := make([]*whiteboardRenderElement, 0, len(elements))
+	for _, raw := range elements {
+		e := new(whiteboardRenderElement)
+		if err := json.Unmarshal(raw, e); err != nil || e.IsDeleted {
+			continue
+		}
+		list = append(list, e)
+	}
+	return list
+}
+
+// bounds will return the area of all elements, rotation will be covered by the padding
+func whiteboardBounds(elements []*whiteboardRenderElement) (minX, minY, maxX, maxY float64) {
+	minX, minY = math.Inf(1), math.Inf(1)
+	maxX, maxY = math.Inf(-1), math.Inf(-1)
+	extend := func(x, y float64) {
+		minX, minY = math.Min(minX, x), math.Min(minY, y)
+		maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+	}
+	for _, e := range elements {
+		if len(e.Points) > 0 {
+			for _, p := range e.Points {
+				if len(p) == 2 {
+					extend(e.X+p[0], e.Y+p[1])
+				}
+			}
+			continue
+		}
+		extend(e.X, e.Y)
+		extend(e.X+e.Width, e.Y+e.Height)
+	}
+	if math.IsInf(minX, 1) {
+		return 0, 0, 800, 600
+	}
+	return minX, minY, maxX, maxY
+}
+
+// RenderPage will return SVG of the page
+func (r *whiteboardRenderer) RenderPage(raw []json.RawMessage) []byte {
+	elements := parseWhiteboardRenderElements(raw)
+	minX, minY, maxX, maxY := whiteboardBounds(elements)
+	minX -= whiteboardRenderPadding
+	minY -= whiteboardRenderPadding
+	width := maxX - minX + whiteboardRenderPadding
+	height := maxY - minY + whiteboardRenderPadding
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="%.0f" height="%.0f" viewBox="%.2f %.2f %.2f %.2f">`+"\n", width, height, minX, minY, width, height)
+	fmt.Fprintf(buf, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="%s"/>`+"\n", minX, minY, width, height, svgAttr(r.background))
+	for _, e := range elements {
+		r.renderElement(buf, e)
+	}
+	buf.WriteString("</svg>\n")
+	return buf.Bytes()
+}
+
+func (r *whiteboardRenderer) renderElement(buf *bytes.Buffer, e *whiteboardRenderElement) {
+	opacity := 1.0
+	if e.Opacity != nil {
+		opacity = *e.Opacity / 100
+	}
+	fmt.Fprintf(buf, `<g transform="translate(%.2f %.2f) rotate(%.2f %.2f %.2f)" opacity="%.2f">`, e.X, e.Y, e.Angle*180/math.Pi, e.Width/2, e.Height/2, opacity)
+
+	stroke := svgStroke(e)
+	fill := svgColor(e.BackgroundColor)
+	switch e.Type {
+	case "rectangle", "frame":
+		rx := 0.0
+		if e.Roundness != nil {
+			rx = math.Min(e.Width, e.Height) / 4
+		}
+		fmt.Fprintf(buf, `<rect width="%.2f" height="%.2f" rx="%.2f" fill="%s" %s/>`, e.Width, e.Height, rx, fill, stroke)
+	case "ellipse":
+		fmt.Fprintf(buf, `<ellipse cx="%.2f" cy="%.2f" rx="%.2f" ry="%.2f" fill="%s" %s/>`, e.Width/2, e.Height/2, e.Width/2, e.Height/2, fill, stroke)
+	case "diamond":
+		fmt.Fprintf(buf, `<polygon points="%.2f,0 %.2f,%.2f %.2f,%.2f 0,%.2f" fill="%s" %s/>`, e.Width/2, e.Width, e.Height/2, e.Width/2, e.Height, e.Height/2, fill, stroke)
+	case "line", "arrow", "freedraw":
+		r.renderLinear(buf, e, fill, stroke)
+	case "text":
+		r.renderText(buf, e)
+	case "image":
+		if data := r.imageDataURL(e.FileId); data != "" {
+			fmt.Fprintf(buf, `<image width="%.2f" height="%.2f" preserveAspectRatio="none" xlink:href="%s"/>`, e.Width, e.Height, svgAttr(data))
+		}
+	}
+	buf.WriteString("</g>\n")
+}
+
+func (r *whiteboardRenderer) renderLinear(buf *bytes.Buffer, e *whiteboardRenderElement, fill, stroke string) {
+	var pts []string
+	for _, p := range e.Points {
+		if len(p) == 2 {
+			pts = append(pts, fmt.Sprintf("%.2f,%.2f", p[0], p[1]))
+		}
+	}
+	if len(pts) == 0 {
+		return
+	}
+	// only closed lines can have background
+	if e.Type == "freedraw" || len(e.Points) < 3 {
+		fill = "none"
+	}
+	fmt.Fprintf(buf, `<polyline points="%s" fill="%s" stroke-linecap="round" stroke-linejoin="round" %s/>`, strings.Join(pts, " "), fill, stroke)
+
+	if e.Type != "arrow" || len(e.Points) < 2 {
+		return
+	}
+	n := len(e.Points)
+	if e.EndArrowhead != nil && len(e.Points[n-1]) == 2 && len(e.Points[n-2]) == 2 {
+		renderArrowhead(buf, e.Points[n-2], e.Points[n-1], stroke)
+	}
+	if e.StartArrowhead != nil && len(e.Points[0]) == 2 && len(e.Points[1]) == 2 {
+		renderArrowhead(buf, e.Points[1], e.Points[0], stroke)
+	}
+}
+
+// renderArrowhead will draw two lines at the tip, direction is from -> tip
+func renderArrowhead(buf *bytes.Buffer, from, tip []float64, stroke string) {
+	angle := math.Atan2(tip[1]-from[1], tip[0]-from[0])
+	for _, d := range []float64{math.Pi / 6, -math.Pi / 6} {
+		x := tip[0] - whiteboardArrowheadSize*math.Cos(angle+d)
+		y := tip[1] - whiteboardArrowheadSize*math.Sin(angle+d)
+		fmt.Fprintf(buf, `<line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f" stroke-linecap="round" %s/>`, tip[0], tip[1], x, y, stroke)
+	}
+}
+
+func (r *whiteboardRenderer) renderText(buf *bytes.Buffer, e *whiteboardRenderElement) {
+	size := e.FontSize
+	if size <= 0 {
+		size = whiteboardDefaultFontSize
+	}
+	family := "Helvetica, Arial, sans-serif"
+	switch e.FontFamily {
+	case 1:
+		family = "Virgil, Segoe UI Emoji, cursive"
+	case 3:
+		family = "Cascadia, Courier New, monospace"
+	}
+
+	x, anchor := 0.0, "start"
+	switch e.TextAlign {
+	case "center":
+		x, anchor = e.Width/2, "middle"
+	case "right":
+		x, anchor = e.Width, "end"
+	}
+
+	fmt.Fprintf(buf, `<text font-size="%.2f" font-family="%s" fill="%s" text-anchor="%s">`, size, svgAttr(family), svgColor(e.StrokeColor), anchor)
+	for i, line := range strings.Split(e.Text, "\n") {
+		fmt.Fprintf(buf, `<tspan x="%.2f" y="%.2f">`, x, float64(i)*size*whiteboardLineHeightFactor+size)
+		_ = xml.EscapeText(buf, []byte(line))
+		buf.WriteString("</tspan>")
+	}
+	buf.WriteString("</text>")
+}
+
+func svgStroke(e *whiteboardRenderElement) string {
+	width := e.StrokeWidth
+	if width <= 0 {
+		width = 1
+	}
+	s := fmt.Sprintf(`stroke="%s" stroke-width="%.2f"`, svgColor(e.StrokeColor), width)
+	switch e.StrokeStyle {
+	case "dashed":
+		s += fmt.Sprintf(` stroke-dasharray="%.2f %.2f"`, width*4, width*4)
+	case "dotted":
+		s += fmt.Sprintf(` stroke-dasharray="%.2f %.2f"`, width, width*3)
+	}
+	return s
+}
+
+func svgColor(c string) string {
+	if c == "" || c == "transparent" {
+		return "none"
+	}
+	return svgAttr(c)
+}
+
+func svgAttr(s string) string {
+	buf := new(bytes.Buffer)
+	_ = xml.EscapeText(buf, []byte(s))
+	return buf.String()
+}
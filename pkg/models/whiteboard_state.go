@@ -0,0 +1,386 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/goccy/go-json"
+	"github.com/mynaparrot/plugnmeet-protocol/plugnmeet"
+	"github.com/mynaparrot/plugnmeet-server/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// whiteboardStateKey keeps current page, app state & office file of the room
+	whiteboardStateKey = "pnm:whiteboard:"
+	// whiteboardElementsKey + roomId:page keeps elements of the page, field is id of the element
+	whiteboardElementsKey = "pnm:whiteboard_elements:"
+	// whiteboardFilesKey keeps files added to the whiteboard, field is id of the file
+	whiteboardFilesKey         = "pnm:whiteboard_files:"
+	defaultWhiteboardElements  = 5000
+	whiteboardCurrentPageField = "current_page"
+	whiteboardAppStateField    = "app_state"
+	whiteboardOfficeFileField  = "office_file"
+)
+
+// whiteboardMergeScript will keep the element only if the version is newer,
+// same as excalidraw reconciliation. New elements will be ignored after the limit.
+// ARGV: max elements, then id, version & element for every element
+var whiteboardMergeScript = redis.NewScript(`
+local max = tonumber(ARGV[1])
+local changed = 0
+for i = 2, #ARGV, 3 do
+	local id = ARGV[i]
+	local version = tonumber(ARGV[i + 1])
+	local current = redis.call('HGET', KEYS[1], id)
+	local save = false
+	if current then
+		local ok, e = pcall(cjson.decode, current)
+		save = not ok or tonumber(e['version'] or 0) < version
+	else
+		save = redis.call('HLEN', KEYS[1]) < max
+	end
+	if save then
+		redis.call('HSET', KEYS[1], id, ARGV[i + 2])
+		changed = changed + 1
+	end
+end
+return changed
+`)
+
+// WhiteboardState is the full board, elements & files are stored as received from clients
+type WhiteboardState struct {
+	CurrentPage int                          `json:"current_page"`
+	TotalPages  int                          `json:"total_pages,omitempty"`
+	AppState    json.RawMessage              `json:"app_state,omitempty"`
+	OfficeFile  json.RawMessage              `json:"office_file,omitempty"`
+	Pages       map[string][]json.RawMessage `json:"pages"`
+	Files       []json.RawMessage            `json:"files,omitempty"`
+}
+
+type RestoreWhiteboardReq struct {
+	RoomId string `json:"-"`
+	// RoomSid of the finished session
+	RoomSid string `json:"room_sid" validate:"required"`
+}
+
+type whiteboardElement struct {
+	Id      string `json:"id"`
+	Version int64  `json:"version"`
+}
+
+func whiteboardPageKey(roomId string, page int) string {
+	return whiteboardElementsKey + roomId + ":" + strconv.Itoa(page)
+}
+
+// PersistWhiteboardMessage will keep whiteboard changes received from the websocket
+func PersistWhiteboardMessage(roomId string, msg *plugnmeet.DataMessage) {
+	if !config.AppCnf.Client.WhiteboardState.Enabled || msg.Body == nil {
+		return
+	}
+	rs := NewRoomService()
+
+	var err error
+	switch msg.Body.Type {
+	case plugnmeet.DataMsgBodyType_SCENE_UPDATE:
+		err = rs.mergeWhiteboardElements(roomId, msg.Body.Msg)
+	case plugnmeet.DataMsgBodyType_PAGE_CHANGE:
+		page, e := strconv.Atoi(strings.TrimSpace(msg.Body.Msg))
+		if e != nil {
+			return
+		}
+		err = rs.setWhiteboardField(roomId, whiteboardCurrentPageField, page)
+	case plugnmeet.DataMsgBodyType_WHITEBOARD_APP_STATE_CHANGE:
+		err = rs.setWhiteboardField(roomId, whiteboardAppStateField, msg.Body.Msg)
+	case plugnmeet.DataMsgBodyType_ADD_WHITEBOARD_FILE:
+		err = rs.addWhiteboardFile(roomId, msg.Body.Msg)
+	case plugnmeet.DataMsgBodyType_ADD_WHITEBOARD_OFFICE_FILE:
+		// clients will start with empty pages for the new file
+		if err = rs.deleteWhiteboardPages(roomId); err == nil {
+			err = rs.setWhiteboardField(roomId, whiteboardOfficeFileField, msg.Body.Msg, whiteboardCurrentPageField, 1)
+		}
+	}
+	if err != nil {
+		log.Errorln("persisting whiteboard of room " + roomId + " failed: " + err.Error())
+	}
+}
+
+func (r *RoomService) setWhiteboardField(roomId string, values ...interface{}) error {
+	key := whiteboardStateKey + roomId
+	pp := r.rc.Pipeline()
+	pp.HSet(r.ctx, key, values...)
+	pp.Expire(r.ctx, key, r.RoomKeyTTL(roomId))
+	_, err := pp.Exec(r.ctx)
+	return err
+}
+
+func (r *RoomService) currentWhiteboardPage(roomId string) int {
+	page, _ := r.rc.HGet(r.ctx, whiteboardStateKey+roomId, whiteboardCurrentPageField).Int()
+	if page < 1 {
+		page = 1
+	}
+	return page
+}
+
+// mergeWhiteboardElements will merge elements of the current page
+func (r *RoomService) mergeWhiteboardElements(roomId, data string) error {
+	var elements []json.RawMessage
+	if err := json.Unmarshal([]byte(data), &elements); err != nil {
+		return err
+	}
+	if len(elements) == 0 {
+		return nil
+	}
+
+	limit := config.AppCnf.Client.WhiteboardState.MaxElements
+	if limit <= 0 {
+		limit = defaultWhiteboardElements
+	}
+	args := make([]interface{}, 0, len(elements)*3+1)
+	args = append(args, limit)
+	for _, raw := range elements {
+		e := new(whiteboardElement)
+		if err := json.Unmarshal(raw, e); err != nil || e.Id == "" {
+			continue
+		}
+		args = append(args, e.Id, e.Version, string(raw))
+	}
+	if len(args) == 1 {
+		return nil
+	}
+
+	key := whiteboardPageKey(roomId, r.currentWhiteboardPage(roomId))
+	if err := whiteboardMergeScript.Run(r.ctx, r.rc, []string{key}, args...).Err(); err != nil {
+		return err
+	}
+	ttl := r.RoomKeyTTL(roomId)
+	pp := r.rc.Pipeline()
+	pp.Expire(r.ctx, key, ttl)
+	r.trackRoomDynamicKeys(pp, roomId, ttl, key)
+	_, err := pp.Exec(r.ctx)
+	return err
+}
+
+func (r *RoomService) addWhiteboardFile(roomId, data string) error {
+	f := new(whiteboardElement)
+	if err := json.Unmarshal([]byte(data), f); err != nil || f.Id == "" {
+		return errors.New("invalid whiteboard file")
+	}
+	key := whiteboardFilesKey + roomId
+	pp := r.rc.Pipeline()
+	pp.HSet(r.ctx, key, f.Id, data)
+	pp.Expire(r.ctx, key, r.RoomKeyTTL(roomId))
+	_, err := pp.Exec(r.ctx)
+	return err
+}
+
+func (r *RoomService) whiteboardPageKeys(roomId string) ([]string, error) {
+	var keys []string
+	iter := r.rc.Scan(r.ctx, 0, whiteboardElementsKey+roomId+":*", 0).Iterator()
+	for iter.Next(r.ctx) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
+
+func (r *RoomService) deleteWhiteboardPages(roomId string) error {
+	keys, err := r.whiteboardPageKeys(roomId)
+	if err != nil {
+		return err
+	}
+	pp := r.rc.Pipeline()
+	if len(keys) > 0 {
+		pp.Del(r.ctx, keys...)
+	}
+	pp.HDel(r.ctx, whiteboardStateKey+roomId, whiteboardTotalPagesField)
+	_, err = pp.Exec(r.ctx)
+	return err
+}
+
+// GetWhiteboardState will return the board of the running session
+func (r *RoomService) GetWhiteboardState(roomId string) (*WhiteboardState, error) {
+	state := &WhiteboardState{
+		CurrentPage: 1,
+		Pages:       make(map[string][]json.RawMessage),
+	}
+
+	fields, err := r.rc.HGetAll(r.ctx, whiteboardStateKey+roomId).Result()
+	if err != nil {
+		return nil, err
+	}
+	if p, err := strconv.Atoi(fields[whiteboardCurrentPageField]); err == nil && p > 0 {
+		state.CurrentPage = p
+	}
+	if v := fields[whiteboardAppStateField]; v != "" {
+		state.AppState = json.RawMessage(v)
+	}
+	if v := fields[whiteboardOfficeFileField]; v != "" {
+		state.OfficeFile = json.RawMessage(v)
+	}
+	if state.TotalPages, err = r.whiteboardTotalPages(roomId, fields); err != nil {
+		return nil, err
+	}
+
+	keys, err := r.whiteboardPageKeys(roomId)
+	if err != nil {
+		return nil, err
+	}
+	prefix := whiteboardElementsKey + roomId + ":"
+	for _, key := range keys {
+		elements, err := r.rc.HVals(r.ctx, key).Result()
+		if err != nil {
+			return nil, err
+		}
+		page := strings.TrimPrefix(key, prefix)
+		for _, e := range elements {
+			state.Pages[page] = append(state.Pages[page], json.RawMessage(e))
+		}
+	}
+
+	files, err := r.rc.HVals(r.ctx, whiteboardFilesKey+roomId).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		state.Files = append(state.Files, json.RawMessage(f))
+	}
+
+	return state, nil
+}
+
+// saveWhiteboardState will replace the board of the room
+func (r *RoomService) saveWhiteboardState(roomId string, state *WhiteboardState) error {
+	if err := r.DeleteWhiteboardState(roomId); err != nil {
+		return err
+	}
+	ttl := r.RoomKeyTTL(roomId)
+
+	pp := r.rc.Pipeline()
+	key := whiteboardStateKey + roomId
+	pp.HSet(r.ctx, key, whiteboardCurrentPageField, state.CurrentPage)
+	if state.TotalPages > 0 {
+		pp.HSet(r.ctx, key, whiteboardTotalPagesField, state.TotalPages)
+	}
+	if len(state.AppState) > 0 {
+		pp.HSet(r.ctx, key, whiteboardAppStateField, string(state.AppState))
+	}
+	if len(state.OfficeFile) > 0 {
+		pp.HSet(r.ctx, key, whiteboardOfficeFileField, string(state.OfficeFile))
+	}
+	pp.Expire(r.ctx, key, ttl)
+
+	for page, elements := range state.Pages {
+		p, err := strconv.Atoi(page)
+		if err != nil || len(elements) == 0 {
+			continue
+		}
+		pKey := whiteboardPageKey(roomId, p)
+		for _, raw := range elements {
+			e := new(whiteboardElement)
+			if err = json.Unmarshal(raw, e); err == nil && e.Id != "" {
+				pp.HSet(r.ctx, pKey, e.Id, string(raw))
+			}
+		}
+		pp.Expire(r.ctx, pKey, ttl)
+		r.trackRoomDynamicKeys(pp, roomId, ttl, pKey)
+	}
+
+	fKey := whiteboardFilesKey + roomId
+	for _, raw := range state.Files {
+		f := new(whiteboardElement)
+		if err := json.Unmarshal(raw, f); err == nil && f.Id != "" {
+			pp.HSet(r.ctx, fKey, f.Id, string(raw))
+		}
+	}
+	pp.Expire(r.ctx, fKey, ttl)
+
+	_, err := pp.Exec(r.ctx)
+	return err
+}
+
+func (r *RoomService) DeleteWhiteboardState(roomId string) error {
+	keys, err := r.whiteboardPageKeys(roomId)
+	if err != nil {
+		return err
+	}
+	keys = append(keys, whiteboardStateKey+roomId, whiteboardFilesKey+roomId)
+	return r.rc.Del(r.ctx, keys...).Err()
+}
+
+// SendWhiteboardState will send the full board to the user who has just joined
+func SendWhiteboardState(roomId, userId string) {
+	if !config.AppCnf.Client.WhiteboardState.Enabled {
+		return
+	}
+	state, err := NewRoomService().GetWhiteboardState(roomId)
+	if err != nil {
+		log.Errorln(err)
+		return
+	}
+	// user should land on the active page even if the board is empty
+	if len(state.Pages) == 0 && len(state.Files) == 0 && len(state.OfficeFile) == 0 && state.CurrentPage == 1 && state.TotalPages == 1 {
+		return
+	}
+	sendSystemMsgToUser(roomId, userId, DataMsgBodyType_WHITEBOARD_STATE, state)
+}
+
+// ArchiveWhiteboardState will store the board of the session in DB, so that it can be restored later
+func (r *RoomService) ArchiveWhiteboardState(roomId, roomSid string) error {
+	if !config.AppCnf.Client.WhiteboardState.Enabled {
+		return nil
+	}
+	state, err := r.GetWhiteboardState(roomId)
+	if err != nil {
+		return err
+	}
+	if len(state.Pages) == 0 && len(state.Files) == 0 {
+		return nil
+	}
+	marshal, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(r.ctx, 10*time.Second)
+	defer cancel()
+	query := "INSERT IGNORE INTO " + config.AppCnf.FormatDBTable("whiteboard_states") + " (room_id, room_sid, api_key, state, created_at) VALUES (?, ?, ?, ?, ?)"
+	_, err = config.AppCnf.DB.ExecContext(ctx, query, roomId, roomSid, r.LoadRoomOptions(roomId).ApiKey, string(marshal), time.Now().Unix())
+	return err
+}
+
+// RestoreWhiteboard will replace the board of the room with the board of a finished session.
+// The session should belong to the same room or api key.
+func (r *RoomService) RestoreWhiteboard(req *RestoreWhiteboardReq) (*WhiteboardState, error) {
+	if !config.AppCnf.Client.WhiteboardState.Enabled {
+		return nil, errors.New("whiteboard state isn't enabled")
+	}
+
+	ctx, cancel := context.WithTimeout(r.ctx, 10*time.Second)
+	defer cancel()
+
+	var roomId, apiKey, data string
+	err := config.AppCnf.DB.QueryRowContext(ctx, "SELECT room_id, api_key, state FROM "+config.AppCnf.FormatDBTable("whiteboard_states")+" WHERE room_sid = ?", req.RoomSid).Scan(&roomId, &apiKey, &data)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("no whiteboard found")
+	} else if err != nil {
+		return nil, err
+	}
+	if roomId != req.RoomId && apiKey != r.LoadRoomOptions(req.RoomId).ApiKey {
+		return nil, errors.New("no whiteboard found")
+	}
+
+	state := new(WhiteboardState)
+	if err = json.Unmarshal([]byte(data), state); err != nil {
+		return nil, err
+	}
+	if err = r.saveWhiteboardState(req.RoomId, state); err != nil {
+		return nil, err
+	}
+
+	broadcastSystemMsg(req.RoomId, DataMsgBodyType_WHITEBOARD_STATE, state)
+	return state, nil
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gabriel-vasile/mimetype"
+	"os"
+	"strings"
+	"time"
+)
+
+// WhiteboardUploadLimits of whiteboard documents, empty values of the room will use the config
+type WhiteboardUploadLimits struct {
+	// MaxSize in MB
+	MaxSize      uint64   `json:"max_size,omitempty"`
+	MaxPages     int      `json:"max_pages,omitempty" validate:"min=0"`
+	AllowedTypes []string `json:"allowed_types,omitempty"`
+}
+
+// whiteboardUploadLimits will merge limits of the room with upload_file_settings.
+// Room can only lower the limits, so that the deployment quota can't be bypassed.
+func (m *ManageFile) whiteboardUploadLimits() *WhiteboardUploadLimits {
+	conf := m.uploadFileSettings
+	l := &WhiteboardUploadLimits{
+		MaxSize:      conf.MaxSize,
+		MaxPages:     whiteboardMaxPages,
+		AllowedTypes: conf.AllowedTypes,
+	}
+	if conf.Whiteboard.MaxSize > 0 {
+		l.MaxSize = conf.Whiteboard.MaxSize
+	}
+	if conf.Whiteboard.MaxPages > 0 {
+		l.MaxPages = conf.Whiteboard.MaxPages
+	}
+	if len(conf.Whiteboard.AllowedTypes) > 0 {
+		l.AllowedTypes = conf.Whiteboard.AllowedTypes
+	}
+
+	o := m.rs.LoadRoomOptions(m.RoomId).WhiteboardUpload
+	if o == nil {
+		return l
+	}
+	if o.MaxSize > 0 && o.MaxSize < l.MaxSize {
+		l.MaxSize = o.MaxSize
+	}
+	if o.MaxPages > 0 && o.MaxPages < l.MaxPages {
+		l.MaxPages = o.MaxPages
+	}
+	if len(o.AllowedTypes) > 0 {
+		var allowedTypes []string
+		for _, t := range o.AllowedTypes {
+			if l.allowsType(t) {
+				allowedTypes = append(allowedTypes, t)
+			}
+		}
+		l.AllowedTypes = allowedTypes
+	}
+	return l
+}
+
+func (l *WhiteboardUploadLimits) checkSize(size int64) error {
+	if size > int64(l.MaxSize*1024*1024) {
+		return errors.New(fmt.Sprintf("file is too big. Max allow %dMB", l.MaxSize))
+	}
+	return nil
+}
+
+func (l *WhiteboardUploadLimits) allowsType(ext string) bool {
+	ext = strings.TrimPrefix(ext, ".")
+	for _, t := range l.AllowedTypes {
+		if ext == t {
+			return true
+		}
+	}
+	return false
+}
+
+// checkType will accept extension with or without dot
+func (l *WhiteboardUploadLimits) checkType(ext string) error {
+	if l.allowsType(ext) {
+		return nil
+	}
+	if ext == "" {
+		return errors.New("invalid file")
+	}
+	return errors.New("." + strings.TrimPrefix(ext, ".") + " file type not allow")
+}
+
+// checkFile will validate size & type of the uploaded document
+func (l *WhiteboardUploadLimits) checkFile(info os.FileInfo, mtype *mimetype.MIME) error {
+	if err := l.checkSize(info.Size()); err != nil {
+		return err
+	}
+	return l.checkType(mtype.Extension())
+}
+
+func (l *WhiteboardUploadLimits) checkPages(pages int) error {
+	if pages > l.MaxPages {
+		return errors.New(fmt.Sprintf("document has %d pages. Max allow %d pages", pages, l.MaxPages))
+	}
+	return nil
+}
+
+// checkPdfPages will count pages before rendering, 0 will be returned if mutool couldn't read the count
+func (l *WhiteboardUploadLimits) checkPdfPages(ctx context.Context, file string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	pages := countPdfPages(ctx, file)
+	return pages, l.checkPages(pages)
+}
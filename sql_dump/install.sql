@@ -36,12 +36,262 @@ CREATE TABLE IF NOT EXISTS `pnm_recordings` (
   `published` int(1) NOT NULL DEFAULT 1,
   `creation_time` int(10) NOT NULL DEFAULT 0,
   `room_creation_time` int(10) NOT NULL DEFAULT 0,
+  `expires_at` int(10) NOT NULL DEFAULT 0,
+  `deleted_at` int(10) NOT NULL DEFAULT 0,
+  `purge_at` int(10) NOT NULL DEFAULT 0,
+  `duration` int(10) NOT NULL DEFAULT 0,
+  `paused_segments` text COLLATE utf8mb4_unicode_ci DEFAULT NULL,
+  `transcript_status` varchar(20) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `transcript` mediumtext COLLATE utf8mb4_unicode_ci DEFAULT NULL,
+  `markers` text COLLATE utf8mb4_unicode_ci DEFAULT NULL,
+  `tags` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `api_key` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `poster` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `thumbnails` text COLLATE utf8mb4_unicode_ci DEFAULT NULL,
   `created` datetime NOT NULL DEFAULT current_timestamp(),
   `modified` datetime NOT NULL DEFAULT '0000-00-00 00:00:00' ON UPDATE current_timestamp(),
   PRIMARY KEY (`id`),
   UNIQUE KEY `record_id` (`record_id`),
   KEY `room_id` (`room_id`),
+  KEY `expires_at` (`expires_at`),
+  KEY `creation_time` (`creation_time`),
+  KEY `api_key` (`api_key`),
+  KEY `purge_at` (`purge_at`),
   FOREIGN KEY (room_sid) REFERENCES `pnm_room_info` (sid)
      ON DELETE SET NULL
      ON UPDATE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `pnm_room_sessions` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `room_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `room_sid` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `room_title` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `is_breakout_room` int(1) NOT NULL DEFAULT 0,
+  `parent_room_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `started` datetime NOT NULL DEFAULT current_timestamp(),
+  `ended` datetime NOT NULL DEFAULT current_timestamp(),
+  `duration` int(10) NOT NULL DEFAULT 0,
+  `peak_participants` int(10) NOT NULL DEFAULT 0,
+  `total_joins` int(10) NOT NULL DEFAULT 0,
+  `features_used` text COLLATE utf8mb4_unicode_ci NOT NULL,
+  `recording_used` int(1) NOT NULL DEFAULT 0,
+  `rtmp_used` int(1) NOT NULL DEFAULT 0,
+  `end_reason` varchar(50) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `created` datetime NOT NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `room_sid` (`room_sid`),
+  KEY `room_id` (`room_id`),
+  KEY `started` (`started`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `pnm_user_preferences` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `api_key` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `user_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `preferences` text COLLATE utf8mb4_unicode_ci NOT NULL,
+  `created` datetime NOT NULL DEFAULT current_timestamp(),
+  `modified` datetime NOT NULL DEFAULT '0000-00-00 00:00:00' ON UPDATE current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `api_key_user_id` (`api_key`, `user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- for existing installation
+ALTER TABLE `pnm_user_preferences`
+  ADD COLUMN IF NOT EXISTS `api_key` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' AFTER `id`,
+  DROP INDEX IF EXISTS `user_id`,
+  ADD UNIQUE INDEX IF NOT EXISTS `api_key_user_id` (`api_key`, `user_id`);
+
+CREATE TABLE IF NOT EXISTS `pnm_audit_logs` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `action` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `room_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `room_sid` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `actor` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `actor_ip` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `details` text COLLATE utf8mb4_unicode_ci NOT NULL,
+  `succeeded` int(1) NOT NULL DEFAULT 0,
+  `created` datetime NOT NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  KEY `action` (`action`),
+  KEY `room_sid` (`room_sid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `pnm_recording_tracks` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `record_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `egress_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `user_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `user_name` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `track_sid` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `kind` varchar(10) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `source` varchar(20) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `file_path` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `size` double NOT NULL DEFAULT 0,
+  `duration` int(10) NOT NULL DEFAULT 0,
+  `started_at` int(10) NOT NULL DEFAULT 0,
+  `ended_at` int(10) NOT NULL DEFAULT 0,
+  `created` datetime NOT NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `egress_id` (`egress_id`),
+  KEY `record_id` (`record_id`),
+  KEY `user_id` (`user_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `pnm_recording_renditions` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `record_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `name` varchar(50) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `width` int(10) NOT NULL DEFAULT 0,
+  `height` int(10) NOT NULL DEFAULT 0,
+  `file_path` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `size` double NOT NULL DEFAULT 0,
+  `status` varchar(20) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `created` datetime NOT NULL DEFAULT current_timestamp(),
+  `modified` datetime NOT NULL DEFAULT '0000-00-00 00:00:00' ON UPDATE current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `record_id_name` (`record_id`, `name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `pnm_recording_consents` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `record_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `room_sid` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `user_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `user_name` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `consented` tinyint(1) NOT NULL DEFAULT 0,
+  `responded_at` int(10) NOT NULL DEFAULT 0,
+  `created` datetime NOT NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  KEY `record_id` (`record_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `pnm_stream_destinations` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `destination_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `api_key` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `name` varchar(100) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `platform` varchar(50) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `url` varchar(1024) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `stream_key` text COLLATE utf8mb4_unicode_ci NOT NULL,
+  `passphrase` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `latency` int(10) NOT NULL DEFAULT 0,
+  `created_at` int(10) NOT NULL DEFAULT 0,
+  `modified_at` int(10) NOT NULL DEFAULT 0,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `destination_id` (`destination_id`),
+  KEY `api_key` (`api_key`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- for existing installation
+ALTER TABLE `pnm_recordings`
+  ADD COLUMN IF NOT EXISTS `expires_at` int(10) NOT NULL DEFAULT 0 AFTER `room_creation_time`,
+  ADD COLUMN IF NOT EXISTS `deleted_at` int(10) NOT NULL DEFAULT 0 AFTER `expires_at`,
+  ADD COLUMN IF NOT EXISTS `purge_at` int(10) NOT NULL DEFAULT 0 AFTER `deleted_at`,
+  ADD COLUMN IF NOT EXISTS `duration` int(10) NOT NULL DEFAULT 0 AFTER `purge_at`,
+  ADD COLUMN IF NOT EXISTS `paused_segments` text COLLATE utf8mb4_unicode_ci DEFAULT NULL AFTER `duration`,
+  ADD COLUMN IF NOT EXISTS `transcript_status` varchar(20) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' AFTER `paused_segments`,
+  ADD COLUMN IF NOT EXISTS `transcript` mediumtext COLLATE utf8mb4_unicode_ci DEFAULT NULL AFTER `transcript_status`,
+  ADD COLUMN IF NOT EXISTS `markers` text COLLATE utf8mb4_unicode_ci DEFAULT NULL AFTER `transcript`,
+  ADD COLUMN IF NOT EXISTS `tags` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' AFTER `markers`,
+  ADD COLUMN IF NOT EXISTS `api_key` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' AFTER `tags`,
+  ADD COLUMN IF NOT EXISTS `poster` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' AFTER `api_key`,
+  ADD COLUMN IF NOT EXISTS `thumbnails` text COLLATE utf8mb4_unicode_ci DEFAULT NULL AFTER `poster`,
+  ADD COLUMN IF NOT EXISTS `chat_json` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' AFTER `thumbnails`,
+  ADD COLUMN IF NOT EXISTS `chat_vtt` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' AFTER `chat_json`,
+  ADD COLUMN IF NOT EXISTS `whiteboard` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '' AFTER `chat_vtt`,
+  ADD INDEX IF NOT EXISTS `expires_at` (`expires_at`),
+  ADD INDEX IF NOT EXISTS `creation_time` (`creation_time`),
+  ADD INDEX IF NOT EXISTS `api_key` (`api_key`),
+  ADD INDEX IF NOT EXISTS `purge_at` (`purge_at`);
+
+CREATE TABLE IF NOT EXISTS `pnm_chat_messages` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `message_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `room_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `room_sid` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `sender_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `sender_name` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `to_user_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `visibility` varchar(10) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT 'public',
+  `msg` text COLLATE utf8mb4_unicode_ci NOT NULL,
+  `sent_at` int(10) NOT NULL DEFAULT 0,
+  `edited_at` int(10) NOT NULL DEFAULT 0,
+  `pinned_at` int(10) NOT NULL DEFAULT 0,
+  `deleted_by` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `deleted_at` int(10) NOT NULL DEFAULT 0,
+  `created` datetime NOT NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `message_id` (`message_id`),
+  KEY `room_sid` (`room_sid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `pnm_announcements` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `announcement_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `room_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `room_sid` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `sender_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `sender_name` varchar(255) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `msg` text COLLATE utf8mb4_unicode_ci NOT NULL,
+  `sent_at` int(10) NOT NULL DEFAULT 0,
+  `created` datetime NOT NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `announcement_id` (`announcement_id`),
+  KEY `room_sid` (`room_sid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `pnm_poll_results` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `poll_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `room_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `room_sid` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `result` mediumtext COLLATE utf8mb4_unicode_ci NOT NULL,
+  `created_at` int(10) NOT NULL DEFAULT 0,
+  `created` datetime NOT NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `poll_id` (`poll_id`),
+  KEY `room_sid` (`room_sid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `pnm_poll_templates` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `template_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `api_key` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `name` varchar(100) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `question` text COLLATE utf8mb4_unicode_ci NOT NULL,
+  `options` text COLLATE utf8mb4_unicode_ci NOT NULL,
+  `settings` text COLLATE utf8mb4_unicode_ci NOT NULL,
+  `created_at` int(10) NOT NULL DEFAULT 0,
+  `modified_at` int(10) NOT NULL DEFAULT 0,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `template_id` (`template_id`),
+  KEY `api_key` (`api_key`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `pnm_whiteboard_states` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `room_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `room_sid` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `api_key` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL DEFAULT '',
+  `state` longtext COLLATE utf8mb4_unicode_ci NOT NULL,
+  `created_at` int(10) NOT NULL DEFAULT 0,
+  `created` datetime NOT NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `room_sid` (`room_sid`),
+  KEY `room_id` (`room_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS `pnm_whiteboard_exports` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `export_id` varchar(36) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `room_id` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `room_sid` varchar(64) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `format` varchar(10) COLLATE utf8mb4_unicode_ci NOT NULL,
+  `files` text COLLATE utf8mb4_unicode_ci NOT NULL,
+  `created_at` int(10) NOT NULL DEFAULT 0,
+  `created` datetime NOT NULL DEFAULT current_timestamp(),
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `export_id` (`export_id`),
+  KEY `room_sid` (`room_sid`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;